@@ -0,0 +1,158 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ApprovalPatterns are the regular expressions used by an ApprovalGate
+// without Patterns. They match commands that destroy data or stop the
+// host: recursive forced removals, creating file systems, writing to
+// devices with dd, and shutting down or rebooting. The recursive and
+// force options of rm are matched in any order, combined or separate,
+// and in their long forms, e.g., "rm -r -f" and "rm --force -R".
+var ApprovalPatterns = []string{
+	`\brm\s(.*\s)?(` +
+		`-[a-zA-Z]*([rR][a-zA-Z]*f|f[a-zA-Z]*[rR])[a-zA-Z]*|` +
+		`(-[a-zA-Z]*[rR][a-zA-Z]*|--recursive)\s(.*\s)?(-[a-zA-Z]*f[a-zA-Z]*|--force)|` +
+		`(-[a-zA-Z]*f[a-zA-Z]*|--force)\s(.*\s)?(-[a-zA-Z]*[rR][a-zA-Z]*|--recursive)` +
+		`)(\s|$)`,
+	`\bmkfs\b`,
+	`\bdd\s.*\bof=/dev/`,
+	`\b(shutdown|reboot|poweroff|halt)\b`,
+}
+
+// ApprovalRequest describes a command that needs approval before it is
+// run.
+type ApprovalRequest struct {
+	// Host identifies the host the command would run on.
+	Host string
+
+	// Command is the command as logged.
+	Command string
+
+	// Pattern is the pattern of the ApprovalGate the command
+	// matched.
+	Pattern string
+}
+
+// ApprovalGate requires commands matching Patterns to be approved by
+// Approve before they are run. See SetApprovalGate().
+type ApprovalGate struct {
+	// Patterns are regular expressions matched against the command
+	// line of each command, i.e., the command and its arguments
+	// joined by spaces or the shell command. If empty,
+	// ApprovalPatterns are used.
+	Patterns []string
+
+	// Approve is called with each command matching one of the
+	// Patterns, e.g., to prompt the operator or check that a
+	// change ticket is open. The command is run if it returns
+	// nil. Otherwise the command is denied and the error is the
+	// reason. Approve may be called concurrently by the runners of
+	// a Pool.
+	Approve func(req ApprovalRequest) error
+}
+
+// ApprovalDeniedError is returned for commands that were denied by the
+// Approve function of the ApprovalGate.
+type ApprovalDeniedError struct {
+	// Request describes the denied command.
+	Request ApprovalRequest
+
+	// Reason is the error returned by Approve.
+	Reason error
+}
+
+// Error returns a string representation of the error.
+func (e *ApprovalDeniedError) Error() string {
+	return fmt.Sprintf("approval denied: %s: %v", e.Request.Command, e.Reason)
+}
+
+// Unwrap returns the reason the command was denied.
+func (e *ApprovalDeniedError) Unwrap() error {
+	return e.Reason
+}
+
+// approvalGate is an ApprovalGate with compiled patterns.
+type approvalGate struct {
+	patterns []*regexp.Regexp
+	approve  func(req ApprovalRequest) error
+}
+
+// SetApprovalGate requires the commands run by the LogRun, including
+// those run by helpers such as Rsync(), to be approved by gate if
+// they match its Patterns, e.g.,
+//
+//	err := runner.SetApprovalGate(&logrun.ApprovalGate{
+//		Approve: func(req logrun.ApprovalRequest) error {
+//			if !confirm("Run " + req.Command + "?") {
+//				return errors.New("declined by operator")
+//			}
+//			return nil
+//		},
+//	})
+//
+// Denied commands are logged and not run, and an *ApprovalDeniedError
+// is returned. Commands are only checked when they would be run, so
+// Dryrun and check mode do not ask for approval. A nil gate removes
+// the gate. An error is returned if a pattern is invalid.
+func (r *LogRun) SetApprovalGate(gate *ApprovalGate) error {
+	if gate == nil {
+		r.approval = nil
+		return nil
+	}
+	if gate.Approve == nil {
+		return fmt.Errorf("approval gate has no Approve function")
+	}
+	patterns := gate.Patterns
+	if len(patterns) == 0 {
+		patterns = ApprovalPatterns
+	}
+	g := &approvalGate{approve: gate.Approve}
+	for _, p := range patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return fmt.Errorf("invalid approval pattern: %w", err)
+		}
+		g.patterns = append(g.patterns, re)
+	}
+	r.approval = g
+
+	return nil
+}
+
+// approve asks the ApprovalGate, if any, to approve the command
+// described by spec if it matches one of the patterns. Denials are
+// logged and returned as an *ApprovalDeniedError.
+func (r *LogRun) approve(spec execSpec) error {
+	if r.approval == nil {
+		return nil
+	}
+	line := spec.cmd
+	if !spec.shell && len(spec.args) > 0 {
+		line += " " + strings.Join(spec.args, " ")
+	}
+	for _, re := range r.approval.patterns {
+		if !re.MatchString(line) {
+			continue
+		}
+		req := ApprovalRequest{
+			Host:    r.Host().String(),
+			Command: r.format(spec),
+			Pattern: re.String(),
+		}
+		if err := r.approval.approve(req); err != nil {
+			r.log(trf("denied: %s (%v)", req.Command, err))
+			return &ApprovalDeniedError{Request: req, Reason: err}
+		}
+		return nil
+	}
+
+	return nil
+}
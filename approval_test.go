@@ -0,0 +1,133 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/apatters/go-logrun"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogRun_SetApprovalGate(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "data")
+	require.NoError(t, os.Mkdir(target, 0755))
+
+	log, out, _ := newLogger()
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{LogFunc: log.Println})
+	declined := errors.New("declined by operator")
+	var requests []logrun.ApprovalRequest
+	require.NoError(t, l.SetApprovalGate(&logrun.ApprovalGate{
+		Approve: func(req logrun.ApprovalRequest) error {
+			requests = append(requests, req)
+			return declined
+		},
+	}))
+
+	// Commands not matching a pattern are run without approval.
+	_, _, code := l.Run("/bin/true")
+	assert.Equal(t, 0, code)
+	assert.Empty(t, requests)
+
+	res, err := l.RunResult("rm", "-rf", target)
+	t.Logf("out = %q", out)
+	t.Logf("err = %v", err)
+	var denied *logrun.ApprovalDeniedError
+	require.True(t, errors.As(err, &denied))
+	assert.True(t, errors.Is(err, declined))
+	assert.Equal(t, logrun.ApprovalPatterns[0], denied.Request.Pattern)
+	assert.Equal(t, "rm -rf "+target, denied.Request.Command)
+	assert.Equal(t, 0, res.ExitCode)
+	assert.DirExists(t, target)
+	assert.Contains(t, out.String(), "denied: rm -rf "+target+" (declined by operator)\n")
+	require.Len(t, requests, 1)
+
+	_, stderr, code := l.Shell("sudo shutdown -h now")
+	assert.Equal(t, logrun.ExitErrorExecute, code)
+	assert.Contains(t, stderr, "approval denied: ")
+	require.Len(t, requests, 2)
+
+	// Approved commands are run.
+	require.NoError(t, l.SetApprovalGate(&logrun.ApprovalGate{
+		Patterns: []string{`^rm `},
+		Approve:  func(logrun.ApprovalRequest) error { return nil },
+	}))
+	_, err = l.RunResult("rm", "-r", target)
+	require.NoError(t, err)
+	_, err = os.Stat(target)
+	assert.True(t, os.IsNotExist(err))
+
+	// Dryrun does not ask for approval.
+	require.NoError(t, l.SetApprovalGate(&logrun.ApprovalGate{
+		Approve: func(req logrun.ApprovalRequest) error {
+			t.Errorf("approval requested for %s", req.Command)
+			return nil
+		},
+	}))
+	l.SetDryrun(true)
+	_, _, code = l.Shell("mkfs.ext4 /dev/sdb1")
+	assert.Equal(t, 0, code)
+
+	assert.Error(t, l.SetApprovalGate(&logrun.ApprovalGate{
+		Patterns: []string{"("},
+		Approve:  func(logrun.ApprovalRequest) error { return nil },
+	}))
+	assert.Error(t, l.SetApprovalGate(&logrun.ApprovalGate{}))
+	assert.NoError(t, l.SetApprovalGate(nil))
+}
+
+func TestApprovalPatterns(t *testing.T) {
+	missing := filepath.Join(t.TempDir(), "missing")
+	tests := []struct {
+		args     []string
+		approval bool
+	}{
+		{[]string{"-rf", missing}, true},
+		{[]string{"-fr", missing}, true},
+		{[]string{"-rfv", missing}, true},
+		{[]string{"-r", "-f", missing}, true},
+		{[]string{"-f", "-r", missing}, true},
+		{[]string{"-R", "-v", "-f", missing}, true},
+		{[]string{"--recursive", "--force", missing}, true},
+		{[]string{"--force", "--recursive", missing}, true},
+		{[]string{"-r", "--force", missing}, true},
+		{[]string{"--recursive", "-f", missing}, true},
+		{[]string{missing, "-r", "-f"}, true},
+		{[]string{"-f", missing}, false},
+		{[]string{"-r", missing}, false},
+		{[]string{"--force", missing}, false},
+		{[]string{missing}, false},
+	}
+	for _, tt := range tests {
+		for _, cmd := range []string{"rm", "/bin/rm"} {
+			l := logrun.NewLocalLogRun(logrun.LocalConfig{})
+			asked := false
+			require.NoError(t, l.SetApprovalGate(&logrun.ApprovalGate{
+				Approve: func(logrun.ApprovalRequest) error {
+					asked = true
+					return errors.New("denied")
+				},
+			}))
+			_, err := l.RunResult(cmd, tt.args...)
+			t.Logf("%s %q: err = %v", cmd, tt.args, err)
+			assert.Equal(t, tt.approval, asked, "%s %q", cmd, tt.args)
+
+			asked = false
+			l.Shell(cmd + " " + strings.Join(tt.args, " "))
+			assert.Equal(t, tt.approval, asked, "shell %s %q", cmd, tt.args)
+		}
+	}
+
+	for _, line := range []string{"firm -rf x", "echo rm", "rmdir -p x"} {
+		assert.False(t, regexp.MustCompile(logrun.ApprovalPatterns[0]).MatchString(line), line)
+	}
+}
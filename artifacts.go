@@ -0,0 +1,198 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	// TarCmd is the external command used to archive the paths
+	// collected by CollectArtifacts(). This command has been
+	// tested on RHEL/CentOS 7 and Ubuntu 18.04.
+	TarCmd = "/bin/tar"
+
+	// TarCmdOptions are the command-line options added to TarCmd
+	// to write an archive to standard output. This command (and
+	// options) has been tested on RHEL/CentOS 7 and Ubuntu 18.04.
+	TarCmdOptions = []string{"-c", "-f", "-"}
+)
+
+// tarExitDiffer is the exit code of GNU tar when some files changed
+// while they were archived, e.g., log files being written to.
+const tarExitDiffer = 1
+
+// CollectArtifacts copies the files and directories matching the glob
+// patterns from the host to destDir on the controller, i.e., the host
+// running the program, e.g., to gather logs, core dumps, and reports
+// after a test or deploy run:
+//
+//	files, err := runner.CollectArtifacts([]string{
+//		"/var/log/app/*.log",
+//		"/var/crash/core.*",
+//	}, "artifacts/web1")
+//
+// The matching paths are archived on the host with TarCmd and
+// extracted while they are transferred, so they are collected using a
+// single command. They keep their path relative to the root directory,
+// or to the working directory for relative patterns, so the example
+// above creates artifacts/web1/var/log/app/*.log. Directories are
+// collected recursively. Symbolic links, devices, and FIFOs are not
+// collected. Patterns that match no paths are ignored.
+// Files that changed while they were archived are still collected.
+// The paths of the files created in destDir are returned. Only
+// logging is performed if Dryrun is true. Collecting artifacts is not
+// supported on Windows hosts.
+func (r *LogRun) CollectArtifacts(patterns []string, destDir string) (_ []string, err error) {
+	r = r.beginHelper()
+	defer r.addErrorContext(&err, r.commandSeq())
+	if r.windowsRunner() != nil {
+		return nil, fmt.Errorf("collecting artifacts is not supported on Windows hosts")
+	}
+	var relPaths, absPaths []string
+	seen := make(map[string]bool)
+	for _, pattern := range patterns {
+		matches, err := r.Glob(pattern)
+		if errors.Is(err, ErrGlobFailed) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range matches {
+			m = path.Clean(m)
+			if seen[m] {
+				continue
+			}
+			seen[m] = true
+			if path.IsAbs(m) {
+				absPaths = append(absPaths, strings.TrimPrefix(m, "/"))
+			} else {
+				relPaths = append(relPaths, m)
+			}
+		}
+	}
+	if len(relPaths) == 0 && len(absPaths) == 0 {
+		return nil, nil
+	}
+
+	// Relative paths come first so they are archived relative to
+	// the working directory, before -C changes it.
+	cmdArgs := append(append([]string{}, TarCmdOptions...), relPaths...)
+	if len(absPaths) > 0 {
+		cmdArgs = append(append(cmdArgs, "-C", "/"), absPaths...)
+	}
+	r.logRun(TarCmd, cmdArgs...)
+	if r.Dryrun {
+		return nil, nil
+	}
+
+	pr, pw := io.Pipe()
+	archive := &countingReader{rd: pr}
+	type extracted struct {
+		files []string
+		err   error
+	}
+	done := make(chan extracted, 1)
+	go func() {
+		files, err := extractArtifacts(archive, destDir)
+		if err != nil {
+			pr.CloseWithError(err) // nolint: errcheck
+		} else {
+			io.Copy(ioutil.Discard, archive) // nolint: errcheck
+		}
+		done <- extracted{files, err}
+	}()
+	var stderr bytes.Buffer
+	_, _, code, err := r.execute(execSpec{
+		cmd:    TarCmd,
+		args:   cmdArgs,
+		stdout: pw,
+		stderr: &stderr,
+	})
+	pw.Close() // nolint: errcheck
+	res := <-done
+	if r.localRunner() == nil {
+		r.recordTransfer("CollectArtifacts", destDir, 0, archive.n)
+	}
+	if res.err != nil {
+		return res.files, fmt.Errorf("could not extract artifacts to %s: %w", destDir, res.err)
+	}
+	if err != nil {
+		return res.files, err
+	}
+	if code != 0 && code != tarExitDiffer {
+		return res.files, fmt.Errorf("could not collect artifacts: %s", strings.TrimSpace(stderr.String()))
+	}
+
+	return res.files, nil
+}
+
+// extractArtifacts extracts the tar archive read from rd into destDir
+// and returns the paths of the files it created.
+func extractArtifacts(rd io.Reader, destDir string) ([]string, error) {
+	var files []string
+	tr := tar.NewReader(rd)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return files, nil
+		}
+		if err != nil {
+			return files, err
+		}
+		name := filepath.FromSlash(path.Clean(hdr.Name))
+		if filepath.IsAbs(name) || name == ".." || strings.HasPrefix(name, ".."+string(filepath.Separator)) {
+			return files, fmt.Errorf("invalid path %s in archive", hdr.Name)
+		}
+		dest := filepath.Join(destDir, name)
+		mode := os.FileMode(hdr.Mode).Perm()
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(dest, 0755); err != nil {
+				return files, err
+			}
+			continue
+		case tar.TypeReg:
+			if err := writeArtifact(dest, tr, mode); err != nil {
+				return files, err
+			}
+			os.Chtimes(dest, hdr.ModTime, hdr.ModTime) // nolint: errcheck
+		default:
+			// Links are not extracted, so the archive
+			// cannot write outside destDir, and devices
+			// and FIFOs are not worth collecting.
+			continue
+		}
+		files = append(files, dest)
+	}
+}
+
+// writeArtifact writes the contents read from rd to the file dest with
+// permission bits mode, creating its parent directories.
+func writeArtifact(dest string, rd io.Reader, mode os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, rd); err != nil {
+		f.Close() // nolint: errcheck
+		return err
+	}
+
+	return f.Close()
+}
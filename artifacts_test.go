@@ -0,0 +1,113 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/apatters/go-logrun"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// makeArtifacts creates log files and a report directory in a new
+// temporary directory and returns its path.
+func makeArtifacts(t *testing.T) string {
+	srcDir, err := ioutil.TempDir("", "logrun")
+	require.NoError(t, err)
+	for name, content := range map[string]string{
+		"logs/app.log":            "started\n",
+		"logs/db.log":             "ready\n",
+		"logs/skip.txt":           "ignored\n",
+		"reports/junit/unit.xml":  "<testsuite/>\n",
+		"reports/junit/extra.xml": "<testsuite/>\n",
+	} {
+		p := filepath.Join(srcDir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(p), 0755))
+		require.NoError(t, ioutil.WriteFile(p, []byte(content), 0640))
+	}
+
+	return srcDir
+}
+
+func testCollectArtifacts(t *testing.T, l *logrun.LogRun, srcDir string) {
+	destDir, err := ioutil.TempDir("", "logrun")
+	require.NoError(t, err)
+	defer os.RemoveAll(destDir)
+
+	l.PushDir(srcDir)
+	files, err := l.CollectArtifacts([]string{
+		filepath.Join(srcDir, "logs", "*.log"),
+		"reports",
+		"cores/core.*",
+	}, destDir)
+	l.PopDir()
+	t.Logf("files = %q", files)
+	require.NoError(t, err)
+	rel := strings.TrimPrefix(srcDir, "/")
+	assert.ElementsMatch(t, []string{
+		filepath.Join(destDir, rel, "logs", "app.log"),
+		filepath.Join(destDir, rel, "logs", "db.log"),
+		filepath.Join(destDir, "reports", "junit", "unit.xml"),
+		filepath.Join(destDir, "reports", "junit", "extra.xml"),
+	}, files)
+	content, err := ioutil.ReadFile(filepath.Join(destDir, rel, "logs", "app.log"))
+	require.NoError(t, err)
+	assert.Equal(t, "started\n", string(content))
+	fi, err := os.Stat(filepath.Join(destDir, "reports", "junit", "unit.xml"))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0640), fi.Mode().Perm())
+	_, err = os.Stat(filepath.Join(destDir, rel, "logs", "skip.txt"))
+	assert.True(t, os.IsNotExist(err))
+
+	// Nothing is collected if no pattern matches.
+	files, err = l.CollectArtifacts([]string{filepath.Join(srcDir, "missing", "*")}, destDir)
+	require.NoError(t, err)
+	assert.Empty(t, files)
+}
+
+func TestLocalLogRun_CollectArtifacts(t *testing.T) {
+	srcDir := makeArtifacts(t)
+	defer os.RemoveAll(srcDir)
+
+	log, out, _ := newLogger()
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{LogFunc: log.Println})
+	testCollectArtifacts(t, l, srcDir)
+	t.Logf("out = %q", out)
+	assert.Contains(t, out.String(), "/bin/tar -c -f - reports -C / "+strings.TrimPrefix(srcDir, "/")+"/logs/app.log ")
+}
+
+func TestRemoteLogRun_CollectArtifacts(t *testing.T) {
+	srcDir := makeArtifacts(t)
+	defer os.RemoveAll(srcDir)
+	s := newTestSSHServer(t, nil)
+	defer s.Close()
+
+	r, err := logrun.NewRemoteLogRun(logrun.RemoteConfig{
+		Credentials: s.Credentials(),
+	})
+	require.NoError(t, err)
+	testCollectArtifacts(t, r, srcDir)
+}
+
+func TestLocalLogRun_CollectArtifactsDryrun(t *testing.T) {
+	srcDir := makeArtifacts(t)
+	defer os.RemoveAll(srcDir)
+	destDir := filepath.Join(srcDir, "dest")
+
+	log, out, _ := newLogger()
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{LogFunc: log.Println, Dryrun: true})
+	files, err := l.CollectArtifacts([]string{filepath.Join(srcDir, "logs", "*.log")}, destDir)
+	t.Logf("out = %q", out)
+	require.NoError(t, err)
+	assert.Empty(t, files)
+	assert.Contains(t, out.String(), "/bin/tar -c -f - -C / ")
+	_, err = os.Stat(destDir)
+	assert.True(t, os.IsNotExist(err))
+}
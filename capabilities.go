@@ -0,0 +1,185 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+var (
+	// CapabilitiesCmd is the shell command used to probe the
+	// capabilities of a host. Each successful probe outputs the
+	// name of the capability on a separate line. If empty, the
+	// default, the probe runs FileExistsCmd, GlobCmd, and RsyncCmd,
+	// i.e., the commands the helper methods use.
+	CapabilitiesCmd = ""
+
+	// BSDStatCmdOptions are the command-line options added to
+	// FileExistsCmd and DirExistsCmd on hosts that have a BSD
+	// stat command rather than a GNU one. Used only when
+	// capability probing is enabled.
+	BSDStatCmdOptions = []string{
+		"-L",
+		"-f",
+		"%N:%HT",
+	}
+
+	// PosixGlobCmdOptions are the command-line options added to
+	// GlobCmd on hosts whose ls command does not support the
+	// --directory option. Used only when capability probing is
+	// enabled.
+	PosixGlobCmdOptions = []string{
+		"-1",
+		"-d",
+	}
+)
+
+// Capabilities describes the presence and behavior of the external
+// commands that LogRun's helper methods depend on.
+type Capabilities struct {
+	// GNUStat is true if stat supports the GNU --format option.
+	GNUStat bool
+
+	// BSDStat is true if stat supports the BSD -f option.
+	BSDStat bool
+
+	// LsDirectory is true if ls supports the --directory option.
+	LsDirectory bool
+
+	// Rsync is true if the rsync command is available.
+	Rsync bool
+}
+
+// capsCache holds the probed capabilities of a host. It is shared by
+// copies of a LogRun.
+type capsCache struct {
+	mu   sync.Mutex
+	caps *Capabilities
+}
+
+// defaultCapabilities are assumed when probing is disabled or when
+// running in dryrun mode. They match the hosts the default commands
+// have been tested on.
+var defaultCapabilities = Capabilities{
+	GNUStat:     true,
+	LsDirectory: true,
+	Rsync:       true,
+}
+
+// capabilitiesCmd returns the shell command used to probe the
+// capabilities of a host.
+func capabilitiesCmd() string {
+	if CapabilitiesCmd != "" {
+		return CapabilitiesCmd
+	}
+	stat := ShellQuote(FileExistsCmd)
+
+	return strings.Join([]string{
+		stat + " --dereference --format %n / >/dev/null 2>&1 && echo gnu-stat",
+		stat + " -L -f %N / >/dev/null 2>&1 && echo bsd-stat",
+		ShellQuote(GlobCmd) + " -1 --directory / >/dev/null 2>&1 && echo ls-directory",
+		ShellQuote(RsyncCmd) + " --version >/dev/null 2>&1 && echo rsync",
+		"true",
+	}, "; ")
+}
+
+// Capabilities probes the host for the capabilities of the external
+// commands the helper methods use. The host is only probed once per
+// LogRun; later calls return the cached result. In dryrun mode the
+// probe is logged but not run and the capabilities of a RHEL/CentOS 7
+// or Ubuntu 18.04 host are returned without being cached.
+func (r *LogRun) Capabilities() (_ Capabilities, err error) {
+	r = r.beginHelper()
+	defer r.addErrorContext(&err, r.commandSeq())
+	if r.caps == nil {
+		r.caps = new(capsCache)
+	}
+	r.caps.mu.Lock()
+	defer r.caps.mu.Unlock()
+	if r.caps.caps != nil {
+		return *r.caps.caps, nil
+	}
+	cmd := capabilitiesCmd()
+	r.logShell(cmd)
+	if r.Dryrun {
+		return defaultCapabilities, nil
+	}
+	stdout, stderr, code := r.shell(cmd)
+	if code != 0 {
+		return Capabilities{}, fmt.Errorf("could not probe capabilities: %s", strings.TrimSpace(stderr))
+	}
+	var caps Capabilities
+	for _, line := range strings.Split(stdout, "\n") {
+		switch strings.TrimSpace(line) {
+		case "gnu-stat":
+			caps.GNUStat = true
+		case "bsd-stat":
+			caps.BSDStat = true
+		case "ls-directory":
+			caps.LsDirectory = true
+		case "rsync":
+			caps.Rsync = true
+		}
+	}
+	r.caps.caps = &caps
+
+	return caps, nil
+}
+
+// SetProbeCapabilities enables/disables the automatic selection of
+// helper command implementations based on the host's Capabilities.
+// When disabled, the commands in FileExistsCmdOptions,
+// DirExistsCmdOptions, and GlobCmdOptions are always used on remote
+// hosts and the local host is assumed to have the userland of its
+// operating system, see LocalCapabilities(). Probing is disabled by
+// default since it runs an additional command, which is logged and
+// added to the DryrunPlan, on each host before its first helper
+// command, and the default commands suit the RHEL/CentOS 7 and Ubuntu
+// 18.04 hosts they have been tested on.
+func (r *LogRun) SetProbeCapabilities(probe bool) {
+	r.probeCaps = probe
+}
+
+// helperCapabilities returns the capabilities used to select helper
+// implementations.
+func (r *LogRun) helperCapabilities() (Capabilities, error) {
+	if !r.probeCaps {
+		if r.localRunner() != nil {
+			return localCapabilities, nil
+		}
+		return defaultCapabilities, nil
+	}
+
+	return r.Capabilities()
+}
+
+// statOptions returns the stat options used to determine the type of
+// a file. defaults are the options used on GNU hosts.
+func (r *LogRun) statOptions(defaults []string) ([]string, error) {
+	caps, err := r.helperCapabilities()
+	if err != nil {
+		return nil, err
+	}
+	if !caps.GNUStat && caps.BSDStat {
+		return BSDStatCmdOptions, nil
+	}
+
+	return defaults, nil
+}
+
+// globOptions returns the ls options used to expand glob patterns.
+func (r *LogRun) globOptions() ([]string, error) {
+	caps, err := r.helperCapabilities()
+	if err != nil {
+		return nil, err
+	}
+	if !caps.LsDirectory {
+		return PosixGlobCmdOptions, nil
+	}
+
+	return GlobCmdOptions, nil
+}
@@ -0,0 +1,112 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun_test
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/apatters/go-logrun"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalLogRun_Capabilities(t *testing.T) {
+	log, out, errOut := newLogger()
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{
+		LogFunc: log.Println,
+	})
+	caps, err := l.Capabilities()
+	t.Logf("caps = %+v", caps)
+	t.Logf("err = %v", err)
+	t.Logf("out = %q", out)
+	require.NoError(t, err)
+	assert.True(t, caps.GNUStat)
+	assert.True(t, caps.LsDirectory)
+	// The probe runs the commands used by the helper methods.
+	assert.Contains(t, out.String(), logrun.FileExistsCmd+" --dereference")
+	assert.Contains(t, out.String(), logrun.GlobCmd+" -1 --directory")
+	assert.Contains(t, out.String(), logrun.RsyncCmd+" --version")
+	assert.Empty(t, errOut.String())
+	out.Reset()
+
+	// The result is cached.
+	cached, err := l.Capabilities()
+	require.NoError(t, err)
+	assert.Equal(t, caps, cached)
+	assert.Empty(t, out.String())
+}
+
+func TestLocalLogRun_CapabilitiesDryrun(t *testing.T) {
+	log, out, _ := newLogger()
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{
+		LogFunc: log.Println,
+		Dryrun:  true,
+	})
+	caps, err := l.Capabilities()
+	require.NoError(t, err)
+	assert.Equal(t, logrun.Capabilities{GNUStat: true, LsDirectory: true, Rsync: true}, caps)
+	assert.NotEmpty(t, out.String())
+}
+
+func TestLocalLogRun_ProbeCapabilities(t *testing.T) {
+	orig := logrun.CapabilitiesCmd
+	logrun.CapabilitiesCmd = "echo bsd-stat"
+	defer func() { logrun.CapabilitiesCmd = orig }()
+
+	log, out, _ := newLogger()
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{
+		LogFunc:           log.Println,
+		ProbeCapabilities: true,
+	})
+	_, _ = l.FileExists("/bin/true")
+	_, _ = l.DirExists("/bin")
+	_, _ = l.Glob("/bin/true*")
+	err := l.Rsync("/bin/true", "/tmp/")
+	t.Logf("err = %v", err)
+	t.Logf("out = %q", out)
+	assert.Error(t, err)
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	// Local file operations do not use external commands, so
+	// only Rsync() probes the capabilities.
+	assert.Equal(t, []string{
+		`stat /bin/true`,
+		`stat /bin`,
+		`glob /bin/true*`,
+		`/bin/sh -c "echo bsd-stat"`,
+	}, lines)
+
+	l.SetProbeCapabilities(false)
+	out.Reset()
+	_, _ = l.FileExists("/bin/true")
+	assert.EqualValues(t, "stat /bin/true\n", out.String())
+}
+
+func TestLocalCapabilities(t *testing.T) {
+	caps := logrun.LocalCapabilities()
+	t.Logf("GOOS = %s", runtime.GOOS)
+	t.Logf("caps = %+v", caps)
+	switch runtime.GOOS {
+	case "linux":
+		assert.True(t, caps.GNUStat)
+		assert.True(t, caps.LsDirectory)
+		assert.True(t, caps.Rsync)
+	case "windows":
+		assert.Equal(t, logrun.Capabilities{}, caps)
+	default:
+		assert.True(t, caps.BSDStat)
+		assert.False(t, caps.GNUStat)
+	}
+
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{})
+	if runtime.GOOS == "windows" {
+		assert.Equal(t, "cmd.exe", l.Config().ShellExecutable)
+		assert.Equal(t, `cmd.exe /c "echo hi"`, l.FormatShell("echo hi"))
+	} else {
+		assert.Equal(t, logrun.DefaultShellExecutable, l.Config().ShellExecutable)
+		assert.Equal(t, `/bin/sh -c "echo hi"`, l.FormatShell("echo hi"))
+	}
+}
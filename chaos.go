@@ -0,0 +1,196 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// ErrChaosConnectionDropped is returned for commands failed by a
+// ChaosRunner as if the connection to the host was lost.
+var ErrChaosConnectionDropped = errors.New("chaos: connection dropped")
+
+// ChaosConfig is used to set the faults injected by a ChaosRunner.
+// Rates are probabilities between 0 and 1 evaluated independently for
+// each command.
+type ChaosConfig struct {
+	// Latency is added before each command is run.
+	Latency time.Duration
+
+	// LatencyJitter is the maximum random delay added to Latency.
+	LatencyJitter time.Duration
+
+	// DropRate is the probability that a command is not run and
+	// fails with ErrChaosConnectionDropped, which a LogRun reports
+	// with exit code ExitErrorExecute.
+	DropRate float64
+
+	// ExitRate is the probability that a command is not run and
+	// exits with ExitCode instead.
+	ExitRate float64
+
+	// ExitCode is the exit code of the commands failed due to
+	// ExitRate. If zero, 1 is used.
+	ExitCode int
+
+	// Seed seeds the random number generator so the injected
+	// faults are reproducible. If zero, the current time is used.
+	Seed int64
+
+	// Clock is used to wait for the latency. If nil, RealClock is
+	// used.
+	Clock Clock
+}
+
+// ChaosStats counts the commands run by a ChaosRunner and the faults
+// injected into them.
+type ChaosStats struct {
+	Commands int
+	Dropped  int
+	Failed   int
+	Delay    time.Duration
+}
+
+// ChaosRunner is a Runner for test environments that wraps another
+// Runner and injects latency, dropped connections, and non-zero exits
+// into the commands it runs, e.g., to verify retry and rollback
+// behavior. Install it with SetRunner():
+//
+//	r.SetRunner(logrun.NewChaosRunner(r.Runner, logrun.ChaosConfig{
+//		DropRate: 0.1,
+//		ExitRate: 0.1,
+//	}))
+//
+// Commands run using the Runner interface are affected as well as
+// those using per-command I/O, working directories, and cancellation
+// if the wrapped Runner supports them. Formatting is not affected.
+// Note that a LogRun with a ChaosRunner runs its file helpers using
+// external commands rather than in-process, even for local hosts.
+type ChaosRunner struct {
+	runner Runner
+	config ChaosConfig
+	clock  Clock
+
+	mu    sync.Mutex
+	rand  *rand.Rand
+	stats ChaosStats
+}
+
+// NewChaosRunner returns a ChaosRunner that runs commands using runner
+// and injects the faults described by config.
+func NewChaosRunner(runner Runner, config ChaosConfig) *ChaosRunner {
+	seed := config.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	clock := config.Clock
+	if clock == nil {
+		clock = RealClock{}
+	}
+	if config.ExitCode == 0 {
+		config.ExitCode = 1
+	}
+
+	return &ChaosRunner{
+		runner: runner,
+		config: config,
+		clock:  clock,
+		rand:   rand.New(rand.NewSource(seed)), // nolint: gosec
+	}
+}
+
+// Stats returns the number of commands run so far and the faults
+// injected into them.
+func (c *ChaosRunner) Stats() ChaosStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.stats
+}
+
+// Run runs a command using the wrapped Runner unless a fault is
+// injected.
+func (c *ChaosRunner) Run(cmd string, args ...string) (string, string, int, error) {
+	return c.execute(&execSpec{cmd: cmd, args: args})
+}
+
+// FormatRun returns the FormatRun() of the wrapped Runner.
+func (c *ChaosRunner) FormatRun(cmd string, args ...string) string {
+	return c.runner.FormatRun(cmd, args...)
+}
+
+// Shell runs a shell command using the wrapped Runner unless a fault
+// is injected.
+func (c *ChaosRunner) Shell(cmd string) (string, string, int, error) {
+	return c.execute(&execSpec{cmd: cmd, shell: true})
+}
+
+// FormatShell returns the FormatShell() of the wrapped Runner.
+func (c *ChaosRunner) FormatShell(cmd string) string {
+	return c.runner.FormatShell(cmd)
+}
+
+// fault decides the faults injected into the next command.
+func (c *ChaosRunner) fault() (delay time.Duration, drop bool, fail bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delay = c.config.Latency
+	if c.config.LatencyJitter > 0 {
+		delay += time.Duration(c.rand.Int63n(int64(c.config.LatencyJitter) + 1))
+	}
+	drop = c.rand.Float64() < c.config.DropRate
+	fail = !drop && c.rand.Float64() < c.config.ExitRate
+	c.stats.Commands++
+	c.stats.Delay += delay
+	if drop {
+		c.stats.Dropped++
+	}
+	if fail {
+		c.stats.Failed++
+	}
+
+	return delay, drop, fail
+}
+
+func (c *ChaosRunner) execute(spec *execSpec) (string, string, int, error) {
+	delay, drop, fail := c.fault()
+	if delay > 0 {
+		ctx := spec.ctx
+		if ctx == nil {
+			ctx = context.Background()
+		}
+		select {
+		case <-c.clock.After(delay):
+		case <-ctx.Done():
+			return "", "", 0, ctx.Err()
+		}
+	}
+	switch {
+	case drop:
+		return "", "", 0, ErrChaosConnectionDropped
+	case fail:
+		return "", "chaos: injected failure\n", c.config.ExitCode, nil
+	}
+	if e, ok := c.runner.(executor); ok {
+		return e.execute(spec)
+	}
+
+	return executePlain(c.runner, spec)
+}
+
+func (c *ChaosRunner) format(spec *execSpec) string {
+	if e, ok := c.runner.(executor); ok {
+		return e.format(spec)
+	}
+	if spec.shell {
+		return c.runner.FormatShell(spec.cmd)
+	}
+
+	return c.runner.FormatRun(spec.cmd, spec.args...)
+}
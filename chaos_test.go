@@ -0,0 +1,109 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apatters/go-logrun"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChaosRunner_NoFaults(t *testing.T) {
+	log, out, _ := newLogger()
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{LogFunc: log.Println})
+	chaos := logrun.NewChaosRunner(l.Runner, logrun.ChaosConfig{Seed: 1})
+	l.SetRunner(chaos)
+	stdout, _, code := l.Run("/bin/echo", "hello")
+	t.Logf("stdout = %q", stdout)
+	t.Logf("out = %q", out)
+	require.Zero(t, code)
+	assert.Equal(t, "hello\n", stdout)
+	assert.Equal(t, "/bin/echo hello\n", out.String())
+
+	// Per-command options are passed to the wrapped runner.
+	var buf strings.Builder
+	_, _, code = l.With(logrun.WithStdout(&buf)).Shell("echo world")
+	t.Logf("buf = %q", buf.String())
+	require.Zero(t, code)
+	assert.Equal(t, "world\n", buf.String())
+	assert.Equal(t, logrun.ChaosStats{Commands: 2}, chaos.Stats())
+}
+
+func TestChaosRunner_Faults(t *testing.T) {
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{})
+	chaos := logrun.NewChaosRunner(l.Runner, logrun.ChaosConfig{DropRate: 1})
+	l.SetRunner(chaos)
+	stdout, stderr, code := l.Run("/bin/echo", "hello")
+	t.Logf("stdout = %q", stdout)
+	t.Logf("stderr = %q", stderr)
+	t.Logf("code = %d", code)
+	assert.Empty(t, stdout)
+	assert.Equal(t, logrun.ErrChaosConnectionDropped.Error(), stderr)
+	assert.Equal(t, logrun.ExitErrorExecute, code)
+
+	local := logrun.NewLocalLogRun(logrun.LocalConfig{}).Runner
+	chaos = logrun.NewChaosRunner(local, logrun.ChaosConfig{
+		ExitRate: 1,
+		ExitCode: 3,
+	})
+	l.SetRunner(chaos)
+	stdout, stderr, code = l.Shell("echo hello")
+	t.Logf("stdout = %q", stdout)
+	t.Logf("stderr = %q", stderr)
+	t.Logf("code = %d", code)
+	assert.Empty(t, stdout)
+	assert.Contains(t, stderr, "injected failure")
+	assert.Equal(t, 3, code)
+	assert.Equal(t, logrun.ChaosStats{Commands: 1, Failed: 1}, chaos.Stats())
+
+	// Retries see the injected faults.
+	chaos = logrun.NewChaosRunner(local, logrun.ChaosConfig{
+		ExitRate: 0.5,
+		Seed:     42,
+	})
+	l.SetRunner(chaos)
+	failed := 0
+	for i := 0; i < 100; i++ {
+		if _, _, code := l.Run("/bin/true"); code != 0 {
+			failed++
+		}
+	}
+	t.Logf("stats = %+v", chaos.Stats())
+	assert.Equal(t, failed, chaos.Stats().Failed)
+	assert.True(t, failed > 25 && failed < 75)
+}
+
+func TestChaosRunner_Latency(t *testing.T) {
+	c := logrun.NewFakeClock(time.Now())
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{})
+	chaos := logrun.NewChaosRunner(l.Runner, logrun.ChaosConfig{
+		Latency:       time.Hour,
+		LatencyJitter: time.Minute,
+		Clock:         c,
+	})
+	l.SetRunner(chaos)
+	go func() {
+		c.BlockUntil(1)
+		c.Advance(time.Hour + time.Minute)
+	}()
+	_, _, code := l.Run("/bin/true")
+	t.Logf("stats = %+v", chaos.Stats())
+	require.Zero(t, code)
+	assert.True(t, chaos.Stats().Delay >= time.Hour)
+	assert.True(t, chaos.Stats().Delay <= time.Hour+time.Minute)
+
+	// The latency is interrupted by cancellation.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, stderr, code := l.RunContext(ctx, "/bin/true")
+	t.Logf("stderr = %q", stderr)
+	assert.Equal(t, logrun.ExitErrorExecute, code)
+	assert.Contains(t, stderr, "canceled")
+}
@@ -0,0 +1,232 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// RsyncCheckCmdOptions are the command-line options added to
+// RsyncCmdOptions in check mode to list the changes rsync would make
+// without making them. This command and options has been tested on
+// RHEL/CentOS 7 and Ubuntu 18.04.
+var RsyncCheckCmdOptions = []string{
+	"--dry-run",
+	"--itemize-changes",
+}
+
+// ChangeAction is the kind of change recorded in a ChangeReport.
+type ChangeAction int
+
+const (
+	// ChangeCreate indicates a file or directory would be
+	// created.
+	ChangeCreate ChangeAction = iota
+
+	// ChangeModify indicates the contents or attributes of a file
+	// or directory would be modified.
+	ChangeModify
+
+	// ChangeDelete indicates a file or directory would be
+	// deleted.
+	ChangeDelete
+
+	// ChangeRun indicates a command would be run. The effect of
+	// arbitrary commands cannot be determined in advance.
+	ChangeRun
+)
+
+// String returns the name of the action.
+func (a ChangeAction) String() string {
+	switch a {
+	case ChangeCreate:
+		return "create"
+	case ChangeModify:
+		return "modify"
+	case ChangeDelete:
+		return "delete"
+	case ChangeRun:
+		return "run"
+	}
+
+	return "unknown"
+}
+
+// symbol returns the single character used to mark the action in a
+// report.
+func (a ChangeAction) symbol() string {
+	switch a {
+	case ChangeCreate:
+		return "+"
+	case ChangeModify:
+		return "~"
+	case ChangeDelete:
+		return "-"
+	}
+
+	return "!"
+}
+
+// Change is a single change that would be made by a task.
+type Change struct {
+	// Action is the kind of change.
+	Action ChangeAction
+
+	// Target is the path of the file or directory that would be
+	// changed, or the logged command that would be run.
+	Target string
+
+	// Detail optionally describes the change, e.g., "mode 644 ->
+	// 600".
+	Detail string
+
+	// Diff is the unified diff of the contents of a file written by
+	// PutFileString, if known.
+	Diff string
+}
+
+// String returns a one line description of the change.
+func (c Change) String() string {
+	s := fmt.Sprintf("%s %s %s", c.Action.symbol(), tr(c.Action.String()), c.Target)
+	if c.Detail != "" {
+		s += " (" + c.Detail + ")"
+	}
+
+	return s
+}
+
+// ChangeReport records the changes that would be made by the
+// commands run in check mode. It is safe for concurrent use.
+type ChangeReport struct {
+	mu      sync.Mutex
+	changes []Change
+}
+
+func (c *ChangeReport) add(change Change) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.changes = append(c.changes, change)
+}
+
+// Changes returns the recorded changes in the order they were made.
+func (c *ChangeReport) Changes() []Change {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return append([]Change{}, c.changes...)
+}
+
+// Count returns the number of recorded changes of kind action.
+func (c *ChangeReport) Count(action ChangeAction) int {
+	n := 0
+	for _, change := range c.Changes() {
+		if change.Action == action {
+			n++
+		}
+	}
+
+	return n
+}
+
+// HasChanges returns true if any change was recorded.
+func (c *ChangeReport) HasChanges() bool {
+	return len(c.Changes()) > 0
+}
+
+// Diff returns the concatenated unified diffs of the recorded
+// changes, e.g., to review the configuration files a task would write.
+func (c *ChangeReport) Diff() string {
+	var b strings.Builder
+	for _, change := range c.Changes() {
+		b.WriteString(change.Diff)
+	}
+
+	return b.String()
+}
+
+// String returns the report with one change per line followed by a
+// summary line, e.g.,
+//
+//	~ modify /etc/hosts (content)
+//	+ create /etc/app.conf
+//	! run ssh root@db1 systemctl restart app
+//	Plan: 1 to create, 1 to modify, 0 to delete, 1 commands to run.
+func (c *ChangeReport) String() string {
+	var b strings.Builder
+	for _, change := range c.Changes() {
+		b.WriteString(change.String())
+		b.WriteString("\n")
+	}
+	fmt.Fprintf(&b, tr("Plan: %d to create, %d to modify, %d to delete, %d commands to run."),
+		c.Count(ChangeCreate),
+		c.Count(ChangeModify),
+		c.Count(ChangeDelete),
+		c.Count(ChangeRun))
+
+	return b.String()
+}
+
+// SetCheckMode enables check mode if report is not nil. In check
+// mode, read-only operations (FileExists, DirExists, Glob,
+// GetFileString, and the checks made by PutFileString) are run as
+// usual, while operations that would change the host are only logged
+// and recorded in report. Rsync is run with RsyncCheckCmdOptions so
+// the individual files it would change are recorded. Commands run
+// with Run() and Shell() are recorded as ChangeRun since their
+// effects are unknown. Dryrun takes precedence over check mode.
+func (r *LogRun) SetCheckMode(report *ChangeReport) {
+	r.check = report
+}
+
+// Check runs task in check mode using a copy of the LogRun and
+// returns the report of the changes the task would make, similar to
+// "terraform plan". The error returned by task is passed on.
+func (r *LogRun) Check(task func(r *LogRun) error) (*ChangeReport, error) {
+	report := new(ChangeReport)
+	c := r.With()
+	c.SetCheckMode(report)
+	err := task(c)
+
+	return report, err
+}
+
+// checking returns true if commands that change the host should only
+// be recorded.
+func (r *LogRun) checking() bool {
+	return r.check != nil && !r.Dryrun
+}
+
+// parseRsyncItemized converts the output of rsync --itemize-changes
+// into changes. Names are reported relative to dest.
+func parseRsyncItemized(dest string, output string) []Change {
+	var changes []Change
+	prefix := strings.TrimSuffix(dest, "/") + "/"
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if strings.HasPrefix(line, "*deleting") {
+			name := strings.TrimSpace(strings.TrimPrefix(line, "*deleting"))
+			changes = append(changes, Change{Action: ChangeDelete, Target: prefix + name})
+			continue
+		}
+		// Itemized lines are an 11 character change summary,
+		// a space, and the name.
+		if len(line) < 13 || line[11] != ' ' || !strings.ContainsAny(line[:1], "<>ch.") {
+			continue
+		}
+		flags, name := line[:11], line[12:]
+		if name == "./" {
+			continue
+		}
+		action := ChangeModify
+		if strings.Trim(flags[2:], "+") == "" {
+			action = ChangeCreate
+		}
+		changes = append(changes, Change{Action: action, Target: prefix + name})
+	}
+
+	return changes
+}
@@ -0,0 +1,141 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/apatters/go-logrun"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalLogRun_Check(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-logrun-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	same := filepath.Join(dir, "same.conf")
+	modified := filepath.Join(dir, "modified.conf")
+	chmoded := filepath.Join(dir, "chmoded.conf")
+	created := filepath.Join(dir, "created.conf")
+	require.NoError(t, ioutil.WriteFile(same, []byte("a = 1\n"), 0644))
+	require.NoError(t, ioutil.WriteFile(modified, []byte("a = 1\n"), 0644))
+	require.NoError(t, ioutil.WriteFile(chmoded, []byte("a = 1\n"), 0644))
+	require.NoError(t, os.Chmod(chmoded, 0644))
+
+	log, out, _ := newLogger()
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{
+		LogFunc: log.Println,
+	})
+	report, err := l.Check(func(r *logrun.LogRun) error {
+		exists, err := r.FileExists(same)
+		assert.NoError(t, err)
+		assert.True(t, exists)
+		for _, f := range []struct {
+			path    string
+			mode    os.FileMode
+			changed bool
+		}{
+			{same, 0644, false},
+			{modified, 0644, true},
+			{chmoded, 0600, true},
+			{created, 0640, true},
+		} {
+			content := "a = 1\n"
+			if f.path == modified {
+				content = "a = 2\n"
+			}
+			changed, err := r.PutFileString(f.path, content, f.mode)
+			assert.NoError(t, err)
+			assert.Equal(t, f.changed, changed, f.path)
+		}
+		_, _, code := r.Run("/bin/rm", same)
+		assert.Zero(t, code)
+		return nil
+	})
+	require.NoError(t, err)
+	t.Logf("out = %q", out)
+	t.Logf("report =\n%s", report)
+
+	// Nothing was changed.
+	content, err := ioutil.ReadFile(modified)
+	require.NoError(t, err)
+	assert.Equal(t, "a = 1\n", string(content))
+	info, err := os.Stat(chmoded)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0644), info.Mode().Perm())
+	assert.FileExists(t, same)
+	_, err = os.Stat(created)
+	assert.True(t, os.IsNotExist(err))
+
+	assert.Equal(t, []logrun.Change{
+		{
+			Action: logrun.ChangeModify,
+			Target: modified,
+			Detail: "content",
+			Diff:   "--- " + modified + "\n+++ " + modified + "\n@@ -1 +1 @@\n-a = 1\n+a = 2\n",
+		},
+		{Action: logrun.ChangeModify, Target: chmoded, Detail: "mode 644 -> 600"},
+		{
+			Action: logrun.ChangeCreate,
+			Target: created,
+			Detail: "mode 640",
+			Diff:   "--- /dev/null\n+++ " + created + "\n@@ -0,0 +1 @@\n+a = 1\n",
+		},
+		{Action: logrun.ChangeRun, Target: "/bin/rm " + same},
+	}, report.Changes())
+	assert.True(t, report.HasChanges())
+	assert.Contains(t, report.String(), "~ modify "+modified+" (content)\n")
+	assert.Contains(t, report.String(), "Plan: 1 to create, 2 to modify, 0 to delete, 1 commands to run.")
+}
+
+func TestLocalLogRun_CheckRsync(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-logrun-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	script := filepath.Join(dir, "rsync")
+	err = ioutil.WriteFile(script, []byte(`#!/bin/sh
+echo "$@" > `+filepath.Join(dir, "args")+`
+echo '.d..t...... ./'
+echo 'cd+++++++++ etc/'
+echo '>f+++++++++ etc/new.conf'
+echo '>f.st...... etc/old.conf'
+echo '*deleting   etc/gone.conf'
+`), 0755)
+	require.NoError(t, err)
+	orig := logrun.RsyncCmd
+	logrun.RsyncCmd = script
+	defer func() { logrun.RsyncCmd = orig }()
+
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{})
+	report, err := l.Check(func(r *logrun.LogRun) error {
+		return r.Rsync("/src/", "/dest/")
+	})
+	require.NoError(t, err)
+	t.Logf("report =\n%s", report)
+	args, err := ioutil.ReadFile(filepath.Join(dir, "args"))
+	require.NoError(t, err)
+	assert.Contains(t, string(args), "--dry-run --itemize-changes /src/ /dest/")
+	assert.Equal(t, []logrun.Change{
+		{Action: logrun.ChangeCreate, Target: "/dest/etc/"},
+		{Action: logrun.ChangeCreate, Target: "/dest/etc/new.conf"},
+		{Action: logrun.ChangeModify, Target: "/dest/etc/old.conf"},
+		{Action: logrun.ChangeDelete, Target: "/dest/etc/gone.conf"},
+	}, report.Changes())
+}
+
+func TestLocalLogRun_CheckDryrun(t *testing.T) {
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{Dryrun: true})
+	report, err := l.Check(func(r *logrun.LogRun) error {
+		_, _, code := r.Run("/bin/false")
+		assert.Zero(t, code)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.False(t, report.HasChanges())
+}
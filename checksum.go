@@ -0,0 +1,129 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun
+
+import (
+	"crypto/md5" // nolint: gosec
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"strings"
+)
+
+// Md5Cmd is the external command used by Checksum() to compute the MD5
+// digest of files on hosts. This command has been tested on
+// RHEL/CentOS 7 and Ubuntu 18.04.
+var Md5Cmd = "/usr/bin/md5sum"
+
+// ChecksumAlgorithm is the hash function used by Checksum().
+type ChecksumAlgorithm string
+
+const (
+	// ChecksumMD5 computes MD5 digests using Md5Cmd. MD5 is not
+	// collision resistant, so it should only be used to compare
+	// with checksums published in that format.
+	ChecksumMD5 ChecksumAlgorithm = "md5"
+
+	// ChecksumSHA256 computes SHA-256 digests using Sha256Cmd.
+	ChecksumSHA256 ChecksumAlgorithm = "sha256"
+)
+
+// Checksum returns the hex-encoded digest of the contents of the file
+// at path computed on the host using algo, so the contents of files
+// can be compared without transferring them. Local runners use the
+// crypto packages instead of Md5Cmd and Sha256Cmd. If path does not
+// exist, the returned error matches ErrNotFound. Only logging is
+// performed if Dryrun is true, in which case the empty string is
+// returned.
+func (r *LogRun) Checksum(path string, algo ChecksumAlgorithm) (_ string, err error) {
+	r = r.beginHelper()
+	defer r.addErrorContext(&err, r.commandSeq())
+	if _, err := checksumCmd(algo); err != nil {
+		return "", err
+	}
+	if r.Dryrun {
+		if r.localRunner() != nil {
+			r.log("hash " + path)
+		} else {
+			cmd, _ := checksumCmd(algo)
+			r.logRun(cmd, path)
+		}
+		return "", nil
+	}
+	digest, exists, err := r.fileChecksum(path, algo)
+	if err != nil {
+		return "", err
+	}
+	if !exists {
+		return "", notFoundError("could not hash", path)
+	}
+
+	return digest, nil
+}
+
+// checksumCmd returns the external command used to compute digests
+// using algo.
+func checksumCmd(algo ChecksumAlgorithm) (string, error) {
+	switch algo {
+	case ChecksumMD5:
+		return Md5Cmd, nil
+	case ChecksumSHA256:
+		return Sha256Cmd, nil
+	}
+
+	return "", fmt.Errorf("unsupported checksum algorithm %q", algo)
+}
+
+// newChecksumHash returns the hash computing digests using algo.
+func newChecksumHash(algo ChecksumAlgorithm) hash.Hash {
+	if algo == ChecksumMD5 {
+		return md5.New() // nolint: gosec
+	}
+
+	return sha256.New()
+}
+
+// fileChecksum returns the digest of the file at path computed using
+// algo and whether or not it exists.
+func (r *LogRun) fileChecksum(path string, algo ChecksumAlgorithm) (string, bool, error) {
+	cmd, err := checksumCmd(algo)
+	if err != nil {
+		return "", false, err
+	}
+	if local := r.localRunner(); local != nil {
+		full := r.localPath(local, path)
+		r.log("hash " + path)
+		f, err := os.Open(full)
+		if os.IsNotExist(err) {
+			return "", false, nil
+		}
+		if err != nil {
+			return "", false, fmt.Errorf("could not hash %s: %w", path, err)
+		}
+		defer f.Close() // nolint: errcheck
+		h := newChecksumHash(algo)
+		if _, err := io.Copy(h, f); err != nil {
+			return "", false, fmt.Errorf("could not hash %s: %w", path, err)
+		}
+		return hex.EncodeToString(h.Sum(nil)), true, nil
+	}
+	r.logRun(cmd, path)
+	stdout, stderr, code := r.run(cmd, path)
+	if code != 0 {
+		if strings.Contains(stderr, "No such file or directory") {
+			return "", false, nil
+		}
+		return "", false, fmt.Errorf("could not hash %s: %w", path, commandError(stderr, code))
+	}
+	fields := strings.Fields(stdout)
+	if len(fields) == 0 {
+		return "", false, fmt.Errorf("could not hash %s: unexpected output %q", path, stdout)
+	}
+
+	return fields[0], true, nil
+}
@@ -0,0 +1,68 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/apatters/go-logrun"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testChecksum(t *testing.T, r *logrun.LogRun) {
+	tmpDir, err := ioutil.TempDir("", "logrun")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmpDir)
+	file := filepath.Join(tmpDir, "hello.txt")
+	require.NoError(t, ioutil.WriteFile(file, []byte("hello\n"), 0644))
+
+	sum, err := r.Checksum(file, logrun.ChecksumSHA256)
+	require.NoError(t, err)
+	assert.Equal(t, "5891b5b522d5df086d0ff0b110fbd9d21bb4fc7163af34d08286a2e846f6be03", sum)
+	sum, err = r.Checksum(file, logrun.ChecksumMD5)
+	require.NoError(t, err)
+	assert.Equal(t, "b1946ac92492d2347c6235b4d2611184", sum)
+
+	_, err = r.Checksum(filepath.Join(tmpDir, "missing"), logrun.ChecksumSHA256)
+	t.Logf("err = %v", err)
+	assert.True(t, errors.Is(err, logrun.ErrNotFound))
+	_, err = r.Checksum(file, "crc32")
+	t.Logf("err = %v", err)
+	assert.Error(t, err)
+}
+
+func TestLocalLogRun_Checksum(t *testing.T) {
+	log, out, _ := newLogger()
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{LogFunc: log.Println})
+	testChecksum(t, l)
+	assert.Contains(t, out.String(), "hash ")
+}
+
+func TestRemoteLogRun_Checksum(t *testing.T) {
+	s := newTestSSHServer(t, nil)
+	defer s.Close()
+	log, out, _ := newLogger()
+	r, err := logrun.NewRemoteLogRun(logrun.RemoteConfig{
+		LogFunc:     log.Println,
+		Credentials: s.Credentials(),
+	})
+	require.NoError(t, err)
+	testChecksum(t, r)
+	t.Logf("out = %q", out)
+	assert.Contains(t, out.String(), logrun.Sha256Cmd+" ")
+	assert.Contains(t, out.String(), logrun.Md5Cmd+" ")
+
+	out.Reset()
+	r.SetDryrun(true)
+	sum, err := r.Checksum("/etc/hosts", logrun.ChecksumMD5)
+	assert.NoError(t, err)
+	assert.Empty(t, sum)
+	assert.Contains(t, out.String(), logrun.Md5Cmd+" /etc/hosts")
+}
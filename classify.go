@@ -0,0 +1,186 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// StderrLevel is the severity assigned to a line of standard error
+// output by a StderrClassifier.
+type StderrLevel int
+
+const (
+	// StderrError indicates the line reports a real error.
+	StderrError StderrLevel = iota
+
+	// StderrWarning indicates the line reports a benign warning.
+	StderrWarning
+
+	// StderrInfo indicates the line is informational, e.g.,
+	// progress output written to stderr.
+	StderrInfo
+)
+
+// String returns the name of the level.
+func (l StderrLevel) String() string {
+	switch l {
+	case StderrError:
+		return "error"
+	case StderrWarning:
+		return "warning"
+	case StderrInfo:
+		return "info"
+	}
+
+	return "unknown"
+}
+
+// MarshalText encodes the level as its name, e.g., in the results
+// written by JSONResultStore.
+func (l StderrLevel) MarshalText() ([]byte, error) {
+	return []byte(l.String()), nil
+}
+
+// UnmarshalText decodes a level encoded by MarshalText().
+func (l *StderrLevel) UnmarshalText(text []byte) error {
+	for _, level := range []StderrLevel{StderrError, StderrWarning, StderrInfo} {
+		if level.String() == string(text) {
+			*l = level
+			return nil
+		}
+	}
+
+	return fmt.Errorf("unknown stderr level %q", text)
+}
+
+// StderrRule assigns Level to stderr lines matching Pattern.
+type StderrRule struct {
+	Pattern *regexp.Regexp
+	Level   StderrLevel
+}
+
+// StderrLine is a single classified line of standard error output.
+type StderrLine struct {
+	Text  string      `json:"text"`
+	Level StderrLevel `json:"level"`
+}
+
+// StderrLines is the classified standard error output of a command.
+type StderrLines []StderrLine
+
+// Errors returns the text of the lines classified as errors.
+func (s StderrLines) Errors() []string {
+	return s.filter(StderrError)
+}
+
+// Warnings returns the text of the lines classified as warnings.
+func (s StderrLines) Warnings() []string {
+	return s.filter(StderrWarning)
+}
+
+// HasErrors returns true if any line was classified as an error.
+func (s StderrLines) HasErrors() bool {
+	return len(s.Errors()) > 0
+}
+
+func (s StderrLines) filter(level StderrLevel) []string {
+	var lines []string
+	for _, l := range s {
+		if l.Level == level {
+			lines = append(lines, l.Text)
+		}
+	}
+
+	return lines
+}
+
+// StderrClassifier tags the lines of a command's standard error
+// output as errors, warnings, or informational. Rules are selected
+// by tool, the base name of the command that was run, e.g.,
+// "rsync". Rules for the tool are tried first, then the rules in
+// Common. Lines that match no rule are assigned DefaultLevel.
+type StderrClassifier struct {
+	// Tools maps a tool name to the rules used for it.
+	Tools map[string][]StderrRule
+
+	// Common are the rules used for every tool.
+	Common []StderrRule
+
+	// DefaultLevel is assigned to lines that match no rule.
+	DefaultLevel StderrLevel
+}
+
+// DefaultStderrClassifier is the classifier used by LogRun when one
+// has not been set. It knows about the benign warnings of the
+// external commands used by LogRun.
+var DefaultStderrClassifier = &StderrClassifier{
+	Tools: map[string][]StderrRule{
+		"rsync": {
+			{regexp.MustCompile(`^file has vanished: `), StderrWarning},
+			{regexp.MustCompile(`some files vanished before they could be transferred`), StderrWarning},
+		},
+	},
+	Common: []StderrRule{
+		{regexp.MustCompile(`(?i)^warning: permanently added .* to the list of known hosts`), StderrInfo},
+		{regexp.MustCompile(`(?i)^warning:`), StderrWarning},
+	},
+	DefaultLevel: StderrError,
+}
+
+// ToolName returns the tool name of cmd used to select classifier
+// rules. For shell command lines, the first word is used.
+func ToolName(cmd string) string {
+	fields := strings.Fields(cmd)
+	if len(fields) == 0 {
+		return ""
+	}
+
+	return filepath.Base(fields[0])
+}
+
+// Classify splits stderr into lines and classifies each non-empty
+// line using the rules for tool.
+func (c *StderrClassifier) Classify(tool string, stderr string) StderrLines {
+	rules := append(append([]StderrRule{}, c.Tools[tool]...), c.Common...)
+	var lines StderrLines
+	for _, text := range strings.Split(stderr, "\n") {
+		text = strings.TrimRight(text, "\r")
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+		level := c.DefaultLevel
+		for _, rule := range rules {
+			if rule.Pattern.MatchString(text) {
+				level = rule.Level
+				break
+			}
+		}
+		lines = append(lines, StderrLine{Text: text, Level: level})
+	}
+
+	return lines
+}
+
+// SetStderrClassifier sets the classifier used by ClassifyStderr().
+func (r *LogRun) SetStderrClassifier(c *StderrClassifier) {
+	r.stderrClassifier = c
+}
+
+// ClassifyStderr classifies the standard error output of cmd, which
+// is either a command passed to Run() or a command line passed to
+// Shell(). DefaultStderrClassifier is used if a classifier has not
+// been set.
+func (r *LogRun) ClassifyStderr(cmd string, stderr string) StderrLines {
+	c := r.stderrClassifier
+	if c == nil {
+		c = DefaultStderrClassifier
+	}
+
+	return c.Classify(ToolName(cmd), stderr)
+}
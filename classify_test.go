@@ -0,0 +1,78 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun_test
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/apatters/go-logrun"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestToolName(t *testing.T) {
+	assert.Equal(t, "rsync", logrun.ToolName("/usr/bin/rsync"))
+	assert.Equal(t, "ls", logrun.ToolName("ls -l /tmp | grep x"))
+	assert.Equal(t, "", logrun.ToolName("  "))
+}
+
+func TestStderrClassifier_Default(t *testing.T) {
+	stderr := "file has vanished: \"/src/tmp.123\"\n" +
+		"rsync warning: some files vanished before they could be transferred (code 24)\n" +
+		"rsync: read errors mapping \"/src/bad\": Input/output error (5)\n" +
+		"\n"
+	lines := logrun.DefaultStderrClassifier.Classify("rsync", stderr)
+	t.Logf("lines = %v", lines)
+	assert.Len(t, lines, 3)
+	assert.Equal(t, []string{
+		"file has vanished: \"/src/tmp.123\"",
+		"rsync warning: some files vanished before they could be transferred (code 24)",
+	}, lines.Warnings())
+	assert.Equal(t, []string{
+		"rsync: read errors mapping \"/src/bad\": Input/output error (5)",
+	}, lines.Errors())
+	assert.True(t, lines.HasErrors())
+
+	// Rsync rules are not used for other tools.
+	lines = logrun.DefaultStderrClassifier.Classify("cp", "file has vanished: x\n")
+	assert.Equal(t, logrun.StderrError, lines[0].Level)
+
+	lines = logrun.DefaultStderrClassifier.Classify(
+		"ssh",
+		"Warning: Permanently added 'host' (ECDSA) to the list of known hosts.\r\n")
+	assert.Equal(t, logrun.StderrInfo, lines[0].Level)
+	assert.False(t, lines.HasErrors())
+}
+
+func TestLocalLogRun_ClassifyStderr(t *testing.T) {
+	c := &logrun.StderrClassifier{
+		Tools: map[string][]logrun.StderrRule{
+			"make": {
+				{regexp.MustCompile(`: warning: `), logrun.StderrWarning},
+			},
+		},
+		DefaultLevel: logrun.StderrInfo,
+	}
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{
+		StderrClassifier: c,
+	})
+	cmd := "echo 'x.c:1: warning: unused' >&2; echo 'x.c:2: error: bad' >&2"
+	_, stderr, _ := l.Shell(cmd)
+	lines := l.ClassifyStderr(cmd, stderr)
+	t.Logf("lines = %v", lines)
+	assert.Equal(t, logrun.StderrLines{
+		{Text: "x.c:1: warning: unused", Level: logrun.StderrInfo},
+		{Text: "x.c:2: error: bad", Level: logrun.StderrInfo},
+	}, lines)
+
+	lines = l.ClassifyStderr("/usr/bin/make", stderr)
+	assert.Equal(t, logrun.StderrWarning, lines[0].Level)
+	assert.Equal(t, logrun.StderrInfo, lines[1].Level)
+	assert.Equal(t, "warning", lines[0].Level.String())
+
+	l.SetStderrClassifier(nil)
+	lines = l.ClassifyStderr("/usr/bin/make", stderr)
+	assert.Equal(t, logrun.StderrError, lines[0].Level)
+}
@@ -0,0 +1,83 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/apatters/go-logrun"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFakeClock(t *testing.T) {
+	start := time.Date(2019, 2, 28, 14, 12, 10, 0, time.UTC)
+	c := logrun.NewFakeClock(start)
+	assert.Equal(t, start, c.Now())
+
+	ch := c.After(time.Minute)
+	timer := c.NewTimer(time.Hour)
+	c.BlockUntil(2)
+
+	c.Advance(30 * time.Second)
+	assert.Equal(t, 30*time.Second, c.Since(start))
+	select {
+	case <-ch:
+		t.Fatal("timer fired early")
+	default:
+	}
+
+	c.Advance(30 * time.Second)
+	select {
+	case now := <-ch:
+		assert.Equal(t, start.Add(time.Minute), now)
+	default:
+		t.Fatal("timer did not fire")
+	}
+
+	assert.True(t, timer.Stop())
+	assert.False(t, timer.Stop())
+	c.Advance(time.Hour)
+	select {
+	case <-timer.C():
+		t.Fatal("stopped timer fired")
+	default:
+	}
+
+	done := make(chan struct{})
+	go func() {
+		c.Sleep(time.Second)
+		close(done)
+	}()
+	c.BlockUntil(1)
+	c.Advance(time.Second)
+	<-done
+}
+
+func TestLocalLogRun_FakeClockTimeout(t *testing.T) {
+	c := logrun.NewFakeClock(time.Now())
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{
+		Timeout: time.Hour,
+		Clock:   c,
+	})
+
+	// The command is killed as soon as the fake clock passes the
+	// timeout rather than after an hour.
+	go func() {
+		c.BlockUntil(1)
+		c.Advance(time.Hour)
+	}()
+	start := time.Now()
+	stdout, stderr, code := l.Run("/bin/sleep", "30")
+	elapsed := time.Since(start)
+	t.Logf("stdout = %q", stdout)
+	t.Logf("stderr = %q", stderr)
+	t.Logf("code = %d", code)
+	t.Logf("elapsed = %s", elapsed)
+	assert.Empty(t, stdout)
+	assert.EqualValues(t, "command timed out after 1h0m0s", stderr)
+	assert.Equal(t, logrun.ExitErrorExecute, code)
+	assert.True(t, elapsed < 30*time.Second)
+}
@@ -0,0 +1,106 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun
+
+import (
+	"strings"
+)
+
+// Command is a structured representation of a command as formatted by
+// FormatRun() and FormatShell(), so tooling can re-render, compare, or
+// redact commands without parsing the logged string.
+type Command struct {
+	// Host identifies the host the command is run on, e.g.,
+	// "root@host:22".
+	Host string
+
+	// Program is the command that is run. For shell commands it
+	// is the shell command line.
+	Program string
+
+	// Args are the arguments of the command. They are empty for
+	// shell commands.
+	Args []string
+
+	// Shell is true if the command is run in a shell.
+	Shell bool
+
+	// Wrapped is the command as logged, including the working
+	// directory, environment, user, and redirections it is run
+	// with. It is the string returned by FormatRun() or
+	// FormatShell().
+	Wrapped string
+}
+
+// String returns the command as logged.
+func (c Command) String() string {
+	return c.Wrapped
+}
+
+// Equal returns true if c and other describe the same command run on
+// the same host.
+func (c Command) Equal(other Command) bool {
+	if c.Host != other.Host || c.Program != other.Program ||
+		c.Shell != other.Shell || c.Wrapped != other.Wrapped ||
+		len(c.Args) != len(other.Args) {
+		return false
+	}
+	for i := range c.Args {
+		if c.Args[i] != other.Args[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Redact returns a copy of c with every occurrence of secrets in its
+// Program, Args, and Wrapped replaced by MaskedSecret. Empty secrets
+// are ignored.
+func (c Command) Redact(secrets ...string) Command {
+	var pairs []string
+	for _, s := range secrets {
+		if s != "" {
+			pairs = append(pairs, s, MaskedSecret)
+		}
+	}
+	if len(pairs) == 0 {
+		return c
+	}
+	replacer := strings.NewReplacer(pairs...)
+	redacted := c
+	redacted.Program = replacer.Replace(c.Program)
+	redacted.Wrapped = replacer.Replace(c.Wrapped)
+	if c.Args != nil {
+		redacted.Args = make([]string, len(c.Args))
+		for i, arg := range c.Args {
+			redacted.Args[i] = replacer.Replace(arg)
+		}
+	}
+
+	return redacted
+}
+
+// FormatRunCommand is like FormatRun() but returns the command as a
+// Command.
+func (r *LogRun) FormatRunCommand(cmd string, args ...string) Command {
+	return Command{
+		Host:    r.Host().String(),
+		Program: cmd,
+		Args:    append([]string(nil), args...),
+		Wrapped: r.FormatRun(cmd, args...),
+	}
+}
+
+// FormatShellCommand is like FormatShell() but returns the command as
+// a Command.
+func (r *LogRun) FormatShellCommand(cmd string) Command {
+	return Command{
+		Host:    r.Host().String(),
+		Program: r.shellCmd(cmd),
+		Shell:   true,
+		Wrapped: r.FormatShell(cmd),
+	}
+}
@@ -0,0 +1,46 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun_test
+
+import (
+	"testing"
+
+	"github.com/apatters/go-logrun"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogRun_FormatRunCommand(t *testing.T) {
+	r, err := logrun.NewRemoteLogRun(logrun.RemoteConfig{})
+	require.NoError(t, err)
+	r.PushDir("/tmp")
+	c := r.FormatRunCommand("/usr/bin/mysql", "-p", "hunter2", "app")
+	t.Logf("c = %+v", c)
+	assert.Equal(t, r.Host().String(), c.Host)
+	assert.Equal(t, "/usr/bin/mysql", c.Program)
+	assert.Equal(t, []string{"-p", "hunter2", "app"}, c.Args)
+	assert.False(t, c.Shell)
+	assert.Equal(t, r.FormatRun("/usr/bin/mysql", "-p", "hunter2", "app"), c.Wrapped)
+	assert.Equal(t, c.Wrapped, c.String())
+	assert.True(t, c.Equal(r.FormatRunCommand("/usr/bin/mysql", "-p", "hunter2", "app")))
+	assert.False(t, c.Equal(r.FormatRunCommand("/usr/bin/mysql", "-p", "hunter2")))
+
+	redacted := c.Redact("hunter2", "")
+	assert.Equal(t, []string{"-p", logrun.MaskedSecret, "app"}, redacted.Args)
+	assert.NotContains(t, redacted.Wrapped, "hunter2")
+	assert.Contains(t, redacted.Wrapped, "cd '/tmp' && ")
+	assert.Equal(t, "hunter2", c.Args[1], "the original command was modified")
+}
+
+func TestLogRun_FormatShellCommand(t *testing.T) {
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{})
+	c := l.With(logrun.WithPipefail()).FormatShellCommand("ls | wc -l")
+	t.Logf("c = %+v", c)
+	assert.True(t, c.Shell)
+	assert.Equal(t, "set -o pipefail; ls | wc -l", c.Program)
+	assert.Empty(t, c.Args)
+	assert.Equal(t, `/bin/sh -c "set -o pipefail; ls | wc -l"`, c.Wrapped)
+	assert.Equal(t, l.FormatShell("echo hi"), logrun.FormatShellCommand("echo hi").Wrapped)
+}
@@ -0,0 +1,281 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun
+
+import (
+	"strings"
+	"time"
+)
+
+// MaskedSecret replaces passwords and other secrets in the
+// EffectiveConfig returned by Config().
+const MaskedSecret = "********"
+
+// EffectiveConfig is a snapshot of the fully resolved configuration a
+// LogRun runs commands with, including defaults. Secrets are replaced
+// by MaskedSecret, so it is safe to log or embed in audit records.
+type EffectiveConfig struct {
+	// Local is true for LogRuns created by NewLocalLogRun().
+	Local bool
+
+	// Host identifies the host commands are run on.
+	Host string
+
+	// Credentials are the SSH credentials of remote LogRuns with
+	// the password masked.
+	Credentials Credentials
+
+	// ConnectTimeout, ConnectDelay, and DisableConnectionReuse are
+	// the connection settings of remote LogRuns.
+	ConnectTimeout         time.Duration
+	ConnectDelay           time.Duration
+	DisableConnectionReuse bool
+
+	// HostKeyPolicy and KnownHostsFile select how remote LogRuns
+	// check host keys.
+	HostKeyPolicy  HostKeyPolicy
+	KnownHostsFile string
+
+	// UseSFTP is true if remote file operations use SFTP.
+	UseSFTP bool
+
+	// RemoteOS is the operating system of the host of remote
+	// LogRuns.
+	RemoteOS RemoteOS
+
+	// ShellExecutable is the shell used to run shell commands.
+	ShellExecutable string
+
+	// RunAs is the user commands are run as, if not the user the
+	// LogRun runs commands as.
+	RunAs string
+
+	// Sudo are the sudo options of remote LogRuns used to run
+	// commands as RunAs.
+	Sudo SudoOptions
+
+	// Env is the environment of local commands or the variables
+	// added to the environment of remote commands. Values of
+	// variables whose names contain PASSWORD, SECRET, TOKEN, or
+	// KEY are masked. If nil, local commands inherit the
+	// environment of the program.
+	Env []string
+
+	// Dir is the effective working directory of commands, i.e.,
+	// the innermost directory entered using PushDir() or, if
+	// none, the directory the LogRun was configured with.
+	Dir string
+
+	// Verbosity selects how much is logged about each command.
+	Verbosity Verbosity
+
+	Dryrun         bool
+	CheckMode      bool
+	Timeout        time.Duration
+	OutputEncoding OutputEncoding
+	NormalizeCRLF  bool
+	Section        string
+
+	// Vars are the variables referenced by command templates.
+	Vars map[string]string
+
+	// Tags are the tags of the host used to select it in a Pool.
+	Tags map[string]string
+
+	// Commands and CommandOptions are the values of the
+	// package's *Cmd and *CmdOptions variables, i.e., the
+	// external commands used by the helper methods, keyed by
+	// variable name.
+	Commands       map[string]string
+	CommandOptions map[string][]string
+}
+
+// Config returns a snapshot of the effective configuration of the
+// LogRun, e.g., to answer "why did it run that" questions when
+// debugging.
+func (r *LogRun) Config() EffectiveConfig {
+	c := EffectiveConfig{
+		Host:           r.Host().String(),
+		Dir:            r.execDir(),
+		Verbosity:      r.verbosity,
+		Dryrun:         r.Dryrun,
+		CheckMode:      r.checking(),
+		Timeout:        r.commandTimeout(),
+		OutputEncoding: r.outputEncoding,
+		NormalizeCRLF:  r.normalizeCRLF,
+		Section:        r.Section(),
+		Vars:           r.Vars(),
+		Tags:           r.Tags(),
+		RunAs:          r.runAs(),
+		Commands:       make(map[string]string),
+		CommandOptions: make(map[string][]string),
+	}
+	switch runner := r.Runner.(type) {
+	case *localRunner:
+		c.Local = true
+		c.ShellExecutable = runner.shellExecutable
+		c.Env = maskEnv(runner.env)
+		if c.Dir == "" {
+			c.Dir = runner.dir
+		}
+	case *remoteRunner:
+		c.Credentials = runner.credentials
+		if c.Credentials.Password != "" {
+			c.Credentials.Password = MaskedSecret
+		}
+		c.ConnectTimeout = runner.connectTimeout
+		c.ConnectDelay = runner.connectDelay
+		c.DisableConnectionReuse = !runner.conns.reuse
+		c.UseSFTP = runner.useSFTP
+		if runner.hostKeys != nil {
+			c.HostKeyPolicy = runner.hostKeys.policy
+			c.KnownHostsFile = runner.hostKeys.filename
+		}
+		c.RemoteOS = runner.remoteOS
+		c.Sudo = runner.sudo
+		c.Env = maskEnv(runner.env)
+		if c.Dir == "" {
+			c.Dir = runner.dir
+		}
+		c.ShellExecutable = runner.shellExecutable
+	case *dockerRunner:
+		c.Env = maskEnv(runner.env)
+		if c.Dir == "" {
+			c.Dir = runner.dir
+		}
+		c.ShellExecutable = runner.shellExecutable
+	case *winrmRunner:
+		c.Credentials = runner.credentials
+		if c.Credentials.Password != "" {
+			c.Credentials.Password = MaskedSecret
+		}
+		c.ConnectTimeout = runner.connectTimeout
+		c.RemoteOS = RemoteWindows
+		c.Env = maskEnv(runner.env)
+		if c.Dir == "" {
+			c.Dir = runner.dir
+		}
+		c.ShellExecutable = runner.shellExecutable
+	}
+	for name, value := range commandVars() {
+		switch v := value.(type) {
+		case string:
+			c.Commands[name] = v
+		case []string:
+			c.CommandOptions[name] = append([]string{}, v...)
+		}
+	}
+
+	return c
+}
+
+// commandVars returns the current values of the package's *Cmd and
+// *CmdOptions variables keyed by name.
+func commandVars() map[string]interface{} {
+	return map[string]interface{}{
+		"BSDStatCmdOptions":        BSDStatCmdOptions,
+		"BSDStatInfoCmdOptions":    BSDStatInfoCmdOptions,
+		"BlockDevicesCmd":          BlockDevicesCmd,
+		"BlockDevicesCmdOptions":   BlockDevicesCmdOptions,
+		"CapabilitiesCmd":          CapabilitiesCmd,
+		"ChmodCmd":                 ChmodCmd,
+		"ChownCmd":                 ChownCmd,
+		"DateCmd":                  DateCmd,
+		"DateCmdOptions":           DateCmdOptions,
+		"DetachCmd":                DetachCmd,
+		"DetectVirtCmd":            DetectVirtCmd,
+		"DirExistsCmd":             DirExistsCmd,
+		"DirExistsCmdOptions":      DirExistsCmdOptions,
+		"DiskUsageCmd":             DiskUsageCmd,
+		"DiskUsageCmdOptions":      DiskUsageCmdOptions,
+		"EnvCmd":                   EnvCmd,
+		"EnvCmdOptions":            EnvCmdOptions,
+		"FileExistsCmd":            FileExistsCmd,
+		"FileExistsCmdOptions":     FileExistsCmdOptions,
+		"FileLockCmd":              FileLockCmd,
+		"FileLockCmdOptions":       FileLockCmdOptions,
+		"FileModeCmd":              FileModeCmd,
+		"FileOwnerCmd":             FileOwnerCmd,
+		"FileOwnerCmdOptions":      FileOwnerCmdOptions,
+		"FileModeCmdOptions":       FileModeCmdOptions,
+		"GlobCmd":                  GlobCmd,
+		"GlobCmdOptions":           GlobCmdOptions,
+		"HTTPProbeCmd":             HTTPProbeCmd,
+		"HeadCmd":                  HeadCmd,
+		"IPAddressesCmd":           IPAddressesCmd,
+		"IPAddressesCmdOptions":    IPAddressesCmdOptions,
+		"JournalCmd":               JournalCmd,
+		"JournalCmdOptions":        JournalCmdOptions,
+		"KillCmd":                  KillCmd,
+		"ListeningPortsCmd":        ListeningPortsCmd,
+		"ListeningPortsCmdOptions": ListeningPortsCmdOptions,
+		"LsmodCmd":                 LsmodCmd,
+		"Md5Cmd":                   Md5Cmd,
+		"MemoryCmd":                MemoryCmd,
+		"MemoryCmdOptions":         MemoryCmdOptions,
+		"MkdirCmd":                 MkdirCmd,
+		"MkdirCmdOptions":          MkdirCmdOptions,
+		"ModprobeCmd":              ModprobeCmd,
+		"PackagesCmd":              PackagesCmd,
+		"PeerCopyCmd":              PeerCopyCmd,
+		"PeerCopyCmdOptions":       PeerCopyCmdOptions,
+		"PosixGlobCmdOptions":      PosixGlobCmdOptions,
+		"PowerShellCmd":            PowerShellCmd,
+		"PowerShellCmdOptions":     PowerShellCmdOptions,
+		"ProcessesCmd":             ProcessesCmd,
+		"ProcessesCmdOptions":      ProcessesCmdOptions,
+		"ReadFileCmd":              ReadFileCmd,
+		"RemoteKillCmd":            RemoteKillCmd,
+		"RemoveAllCmdOptions":      RemoveAllCmdOptions,
+		"RemoveFileCmd":            RemoveFileCmd,
+		"RemoveFileCmdOptions":     RemoveFileCmdOptions,
+		"RsyncCheckCmdOptions":     RsyncCheckCmdOptions,
+		"RsyncCmd":                 RsyncCmd,
+		"RsyncCmdOptions":          RsyncCmdOptions,
+		"RsyncProgressCmdOptions":  RsyncProgressCmdOptions,
+		"RsyncStatsCmdOptions":     RsyncStatsCmdOptions,
+		"RunAsCmd":                 RunAsCmd,
+		"RunAsCmdOptions":          RunAsCmdOptions,
+		"Sha256Cmd":                Sha256Cmd,
+		"StatCmd":                  StatCmd,
+		"StatCmdOptions":           StatCmdOptions,
+		"SymlinkCmd":               SymlinkCmd,
+		"SymlinkCmdOptions":        SymlinkCmdOptions,
+		"SysctlCmd":                SysctlCmd,
+		"SysctlCmdOptions":         SysctlCmdOptions,
+		"TCPProbeCmd":              TCPProbeCmd,
+		"TailCmd":                  TailCmd,
+		"TarCmd":                   TarCmd,
+		"TarCmdOptions":            TarCmdOptions,
+		"TimedatectlCmd":           TimedatectlCmd,
+		"UnitStatusCmd":            UnitStatusCmd,
+		"UnitStatusCmdOptions":     UnitStatusCmdOptions,
+	}
+}
+
+// maskEnv returns a copy of env with the values of variables that
+// look like they hold secrets replaced by MaskedSecret.
+func maskEnv(env []string) []string {
+	if env == nil {
+		return nil
+	}
+	masked := make([]string, len(env))
+	for i, kv := range env {
+		masked[i] = kv
+		parts := strings.SplitN(kv, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		name := strings.ToUpper(parts[0])
+		for _, s := range []string{"PASSWORD", "SECRET", "TOKEN", "KEY"} {
+			if strings.Contains(name, s) {
+				masked[i] = parts[0] + "=" + MaskedSecret
+				break
+			}
+		}
+	}
+
+	return masked
+}
@@ -0,0 +1,68 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/apatters/go-logrun"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalLogRun_Config(t *testing.T) {
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{
+		Env:     []string{"PATH=/bin", "API_TOKEN=abc", "MALFORMED"},
+		Dir:     "/tmp",
+		Timeout: time.Minute,
+		Vars:    map[string]string{"role": "web"},
+	})
+	c := l.Config()
+	t.Logf("config = %+v", c)
+	assert.True(t, c.Local)
+	assert.Equal(t, "/bin/sh", c.ShellExecutable)
+	assert.Equal(t, []string{"PATH=/bin", "API_TOKEN=" + logrun.MaskedSecret, "MALFORMED"}, c.Env)
+	assert.Equal(t, "/tmp", c.Dir)
+	assert.Equal(t, time.Minute, c.Timeout)
+	assert.Equal(t, map[string]string{"role": "web"}, c.Vars)
+	assert.False(t, c.Dryrun)
+	assert.Equal(t, logrun.ReadFileCmd, c.Commands["ReadFileCmd"])
+	assert.Equal(t, logrun.RsyncCmdOptions, c.CommandOptions["RsyncCmdOptions"])
+
+	l.PushDir("/var")
+	l.SetDryrun(true)
+	c = l.Config()
+	assert.Equal(t, "/var", c.Dir)
+	assert.True(t, c.Dryrun)
+
+	// The snapshot does not change with the configuration.
+	orig := logrun.ReadFileCmd
+	defer func() { logrun.ReadFileCmd = orig }()
+	logrun.ReadFileCmd = "/usr/bin/cat"
+	assert.Equal(t, orig, c.Commands["ReadFileCmd"])
+	assert.Equal(t, "/usr/bin/cat", l.Config().Commands["ReadFileCmd"])
+}
+
+func TestRemoteLogRun_Config(t *testing.T) {
+	r, err := logrun.NewRemoteLogRun(logrun.RemoteConfig{
+		Credentials: logrun.Credentials{
+			Hostname: "example.com",
+			Username: "deploy",
+			Password: "secret",
+		},
+	})
+	require.NoError(t, err)
+	c := r.Config()
+	t.Logf("config = %+v", c)
+	assert.False(t, c.Local)
+	assert.Equal(t, "deploy", c.Credentials.Username)
+	assert.Equal(t, "example.com", c.Credentials.Hostname)
+	assert.Equal(t, 22, c.Credentials.Port)
+	assert.Equal(t, logrun.MaskedSecret, c.Credentials.Password)
+	assert.Equal(t, logrun.DefaultConnectTimeout, c.ConnectTimeout)
+	assert.False(t, c.DisableConnectionReuse)
+	assert.Nil(t, c.Env)
+}
@@ -0,0 +1,237 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// ConnectionLimits controls the reuse of SSH connections by remote
+// runners. Zero values mean no limit.
+type ConnectionLimits struct {
+	// MaxSessions is the maximum number of commands run
+	// concurrently over one connection. Commands wait for a free
+	// session once the limit is reached. Set this to at most the
+	// MaxSessions setting of the SSH server.
+	MaxSessions int
+
+	// MaxCommands is the maximum number of commands run over one
+	// connection before a new connection is opened.
+	MaxCommands int
+
+	// MaxAge is the maximum age of a connection according to the
+	// Clock of the RemoteConfig. Commands started after a
+	// connection reaches MaxAge use a new connection.
+	MaxAge time.Duration
+}
+
+// sshConn is a reusable SSH connection.
+type sshConn struct {
+	client   *ssh.Client
+	created  time.Time
+	commands int
+	sessions int
+	retired  bool
+
+	// closed is true once the connManager has closed the
+	// connection and broken is true if it was closed because it
+	// failed.
+	closed bool
+	broken bool
+
+	// slots limits the number of concurrent sessions. It is nil
+	// if the number is not limited.
+	slots chan struct{}
+}
+
+// connManager hands out SSH connections to remote commands. If reuse
+// is disabled, each command gets a new connection that is closed when
+// the command completes.
+type connManager struct {
+	reuse  bool
+	limits ConnectionLimits
+	dial   func(ctx context.Context) (*ssh.Client, error)
+	clock  Clock
+
+	// onDisconnect, if not nil, is called when a connection is
+	// closed with the error that closed it. The error is nil if
+	// the connection was closed by the connManager.
+	onDisconnect func(err error)
+
+	mu      sync.Mutex
+	current *sshConn
+
+	// dialing, if not nil, is closed when the connection being
+	// dialed for reuse has been established or has failed.
+	dialing chan struct{}
+}
+
+// acquire returns a connection with a free session for one command.
+// The connection must be returned using release(). New connections
+// are dialed without m.mu held. While a connection for reuse is being
+// dialed, other commands wait for it so they share one connection.
+// fresh is true if the connection was dialed for the command.
+func (m *connManager) acquire(ctx context.Context) (c *sshConn, fresh bool, err error) {
+	m.mu.Lock()
+	for {
+		c = m.current
+		if c != nil && m.expired(c) {
+			m.retire(c)
+			c = nil
+		}
+		if c != nil || !m.reuse || m.dialing == nil {
+			break
+		}
+		dialing := m.dialing
+		m.mu.Unlock()
+		select {
+		case <-dialing:
+		case <-ctx.Done():
+			return nil, false, ctx.Err()
+		}
+		m.mu.Lock()
+	}
+	if c == nil {
+		var dialing chan struct{}
+		if m.reuse {
+			dialing = make(chan struct{})
+			m.dialing = dialing
+		}
+		m.mu.Unlock()
+		client, err := m.dial(ctx)
+		m.mu.Lock()
+		if dialing != nil {
+			m.dialing = nil
+			close(dialing)
+		}
+		if err != nil {
+			m.mu.Unlock()
+			return nil, false, err
+		}
+		fresh = true
+		c = &sshConn{client: client, created: m.now()}
+		if m.limits.MaxSessions > 0 {
+			c.slots = make(chan struct{}, m.limits.MaxSessions)
+		}
+		if m.reuse {
+			m.current = c
+		} else {
+			c.retired = true
+		}
+		if m.onDisconnect != nil {
+			go m.watch(c)
+		}
+	}
+	c.commands++
+	c.sessions++
+	m.mu.Unlock()
+
+	if c.slots != nil {
+		select {
+		case c.slots <- struct{}{}:
+		case <-ctx.Done():
+			m.abandon(c)
+			return nil, false, ctx.Err()
+		}
+	}
+
+	return c, fresh, nil
+}
+
+// release returns c after a command completes. If broken is true, c
+// is not used for further commands.
+func (m *connManager) release(c *sshConn, broken bool) {
+	if c.slots != nil {
+		select {
+		case <-c.slots:
+		default:
+		}
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	c.sessions--
+	if broken {
+		c.retired = true
+		c.broken = true
+		if m.current == c {
+			m.current = nil
+		}
+	}
+	if c.retired && c.sessions == 0 {
+		m.closeConn(c)
+	}
+}
+
+// abandon gives up c, which was acquired for a command that did not
+// get a session slot. Unlike release(), no slot is returned.
+func (m *connManager) abandon(c *sshConn) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	c.sessions--
+	if c.retired && c.sessions == 0 {
+		m.closeConn(c)
+	}
+}
+
+// closeConn closes c. m.mu must be held.
+func (m *connManager) closeConn(c *sshConn) {
+	c.closed = true
+	c.client.Close() // nolint: errcheck
+}
+
+// watch waits for c to be closed and reports it to m.onDisconnect.
+func (m *connManager) watch(c *sshConn) {
+	err := c.client.Wait()
+	m.mu.Lock()
+	if c.closed && !c.broken {
+		err = nil
+	}
+	m.mu.Unlock()
+	m.onDisconnect(err)
+}
+
+// expired returns true if c has reached its command limit or age.
+func (m *connManager) expired(c *sshConn) bool {
+	if m.limits.MaxCommands > 0 && c.commands >= m.limits.MaxCommands {
+		return true
+	}
+
+	return m.limits.MaxAge > 0 && m.now().Sub(c.created) >= m.limits.MaxAge
+}
+
+// now returns the current time according to the Clock of m, which
+// defaults to RealClock.
+func (m *connManager) now() time.Time {
+	if m.clock == nil {
+		return RealClock{}.Now()
+	}
+
+	return m.clock.Now()
+}
+
+// retire stops c from being used for new commands. It is closed once
+// its last session completes. m.mu must be held.
+func (m *connManager) retire(c *sshConn) {
+	c.retired = true
+	if m.current == c {
+		m.current = nil
+	}
+	if c.sessions == 0 {
+		m.closeConn(c)
+	}
+}
+
+// close closes the current connection once its commands complete.
+func (m *connManager) close() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.current != nil {
+		m.retire(m.current)
+	}
+}
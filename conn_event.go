@@ -0,0 +1,100 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun
+
+import (
+	"strings"
+	"time"
+)
+
+// ConnectionEventType is the kind of a ConnectionEvent.
+type ConnectionEventType int
+
+const (
+	// ConnectionConnected is reported when the first connection to
+	// the remote host has been established.
+	ConnectionConnected ConnectionEventType = iota
+
+	// ConnectionReconnected is reported when a connection has been
+	// established after an earlier one, e.g., because the previous
+	// connection was closed or reached its ConnectionLimits.
+	ConnectionReconnected
+
+	// ConnectionAuthFailed is reported when the SSH server rejected
+	// the credentials.
+	ConnectionAuthFailed
+
+	// ConnectionDisconnected is reported when a connection has
+	// been closed.
+	ConnectionDisconnected
+)
+
+var connectionEventTypeNames = map[ConnectionEventType]string{
+	ConnectionConnected:    "connected",
+	ConnectionReconnected:  "reconnected",
+	ConnectionAuthFailed:   "authentication failed",
+	ConnectionDisconnected: "disconnected",
+}
+
+// String returns the name of the event type, e.g., "connected".
+func (t ConnectionEventType) String() string {
+	if name, ok := connectionEventTypeNames[t]; ok {
+		return name
+	}
+
+	return "unknown"
+}
+
+// ConnectionEvent is a change of the availability of a remote host
+// reported to RemoteConfig.OnConnectionEvent, e.g., to show hosts that
+// can not be reached separately from commands that failed.
+type ConnectionEvent struct {
+	// Type is the kind of event.
+	Type ConnectionEventType
+
+	// Host describes the remote host. Address, ServerVersion, and
+	// Banner are those of the last successful connection.
+	Host HostInfo
+
+	// Time is when the event occurred according to the Clock of
+	// the LogRun.
+	Time time.Time
+
+	// Err is the error that caused the event. It is nil for
+	// connects and for connections closed by the LogRun, e.g., by
+	// Close() or because of ConnectionLimits.
+	Err error
+}
+
+// String returns a description of the event, e.g.,
+// "root@web1: disconnected: EOF".
+func (e ConnectionEvent) String() string {
+	s := trf("%s@%s: %s", e.Host.Username, e.Host.Hostname, tr(e.Type.String()))
+	if e.Err != nil {
+		s += ": " + e.Err.Error()
+	}
+
+	return s
+}
+
+// connectionEvent reports an event of type typ caused by err to the
+// OnConnectionEvent function, if any.
+func (r *remoteRunner) connectionEvent(typ ConnectionEventType, err error) {
+	if r.onEvent == nil {
+		return
+	}
+	r.onEvent(ConnectionEvent{
+		Type: typ,
+		Host: r.hostInfo(),
+		Time: r.clock.Now(),
+		Err:  err,
+	})
+}
+
+// isAuthFailure returns true if err reports that the SSH server
+// rejected the credentials.
+func isAuthFailure(err error) bool {
+	return strings.Contains(err.Error(), "unable to authenticate")
+}
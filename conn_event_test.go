@@ -0,0 +1,113 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/apatters/go-logrun"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// connectionEvents collects the ConnectionEvents of a remote LogRun.
+type connectionEvents struct {
+	mu     sync.Mutex
+	events []logrun.ConnectionEvent
+}
+
+func (c *connectionEvents) add(e logrun.ConnectionEvent) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.events = append(c.events, e)
+}
+
+// waitFor waits until n events have been collected and returns them.
+func (c *connectionEvents) waitFor(t *testing.T, n int) []logrun.ConnectionEvent {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		c.mu.Lock()
+		events := append([]logrun.ConnectionEvent{}, c.events...)
+		c.mu.Unlock()
+		if len(events) >= n {
+			for _, e := range events {
+				t.Logf("event = %s", e)
+			}
+			return events
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("%d connection events were not reported", n)
+
+	return nil
+}
+
+func eventTypes(events []logrun.ConnectionEvent) []logrun.ConnectionEventType {
+	var types []logrun.ConnectionEventType
+	for _, e := range events {
+		types = append(types, e.Type)
+	}
+
+	return types
+}
+
+func TestRemoteLogRun_ConnectionEvents(t *testing.T) {
+	s := newTestSSHServer(t, nil)
+	defer s.Close()
+	var events connectionEvents
+	r, err := logrun.NewRemoteLogRun(logrun.RemoteConfig{
+		Credentials:       s.Credentials(),
+		OnConnectionEvent: events.add,
+	})
+	require.NoError(t, err)
+
+	_, _, code := r.Run("true")
+	require.Equal(t, 0, code)
+	got := events.waitFor(t, 1)
+	assert.Equal(t, logrun.ConnectionConnected, got[0].Type)
+	assert.Equal(t, "127.0.0.1", got[0].Host.Hostname)
+	assert.NotEmpty(t, got[0].Host.ServerVersion)
+	assert.NoError(t, got[0].Err)
+
+	s.DropConnections()
+	got = events.waitFor(t, 2)
+	assert.Equal(t, logrun.ConnectionDisconnected, got[1].Type)
+	assert.Error(t, got[1].Err)
+
+	_, _, code = r.Run("true")
+	require.Equal(t, 0, code)
+	require.NoError(t, r.Close())
+	got = events.waitFor(t, 4)
+	assert.Equal(t, []logrun.ConnectionEventType{
+		logrun.ConnectionConnected,
+		logrun.ConnectionDisconnected,
+		logrun.ConnectionReconnected,
+		logrun.ConnectionDisconnected,
+	}, eventTypes(got))
+	assert.NoError(t, got[3].Err)
+}
+
+func TestRemoteLogRun_ConnectionEventsAuthFailed(t *testing.T) {
+	s := newTestSSHServer(t, nil)
+	defer s.Close()
+	var events connectionEvents
+	creds := s.Credentials()
+	creds.Password = "wrong"
+	r, err := logrun.NewRemoteLogRun(logrun.RemoteConfig{
+		Credentials:       creds,
+		OnConnectionEvent: events.add,
+	})
+	require.NoError(t, err)
+
+	_, _, code := r.Run("true")
+	assert.Equal(t, logrun.ExitErrorExecute, code)
+	got := events.waitFor(t, 1)
+	require.Len(t, got, 1)
+	assert.Equal(t, logrun.ConnectionAuthFailed, got[0].Type)
+	assert.Error(t, got[0].Err)
+	assert.Contains(t, got[0].String(), "authentication failed")
+}
@@ -0,0 +1,170 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/apatters/go-logrun"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRemoteLogRun_NoConnectionReuse(t *testing.T) {
+	s := newTestSSHServer(t, nil)
+	defer s.Close()
+
+	r, err := logrun.NewRemoteLogRun(logrun.RemoteConfig{
+		Credentials:            s.Credentials(),
+		DisableConnectionReuse: true,
+	})
+	require.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		_, _, code := r.Run("/bin/true")
+		require.Zero(t, code)
+	}
+	assert.Equal(t, 3, s.Connections())
+	assert.True(t, r.Config().DisableConnectionReuse)
+}
+
+func TestRemoteLogRun_DefaultConnectionReuse(t *testing.T) {
+	s := newTestSSHServer(t, nil)
+	defer s.Close()
+
+	r, err := logrun.NewRemoteLogRun(logrun.RemoteConfig{
+		Credentials: s.Credentials(),
+	})
+	require.NoError(t, err)
+	defer r.Close() // nolint: errcheck
+	for i := 0; i < 3; i++ {
+		_, _, code := r.Run("/bin/true")
+		require.Zero(t, code)
+		exists, err := r.FileExists("/bin/true")
+		require.NoError(t, err)
+		assert.True(t, exists)
+	}
+	t.Logf("connections = %d", s.Connections())
+	assert.Equal(t, 1, s.Connections())
+	assert.False(t, r.Config().DisableConnectionReuse)
+}
+
+func TestRemoteLogRun_ConnectionReuse(t *testing.T) {
+	s := newTestSSHServer(t, nil)
+	defer s.Close()
+
+	r, err := logrun.NewRemoteLogRun(logrun.RemoteConfig{
+		Credentials: s.Credentials(),
+		ConnectionLimits: logrun.ConnectionLimits{
+			MaxCommands: 2,
+		},
+	})
+	require.NoError(t, err)
+	for i := 0; i < 5; i++ {
+		stdout, _, code := r.Run("echo", "hello")
+		require.Zero(t, code)
+		assert.Equal(t, "hello\n", stdout)
+	}
+	assert.Equal(t, 3, s.Connections())
+
+	// Close forces a new connection.
+	require.NoError(t, r.Close())
+	_, _, code := r.Run("/bin/true")
+	require.Zero(t, code)
+	assert.Equal(t, 4, s.Connections())
+}
+
+func TestRemoteLogRun_ConnectionMaxAge(t *testing.T) {
+	s := newTestSSHServer(t, nil)
+	defer s.Close()
+
+	r, err := logrun.NewRemoteLogRun(logrun.RemoteConfig{
+		Credentials: s.Credentials(),
+		ConnectionLimits: logrun.ConnectionLimits{
+			MaxAge: 100 * time.Millisecond,
+		},
+	})
+	require.NoError(t, err)
+	defer r.Close() // nolint: errcheck
+	_, _, code := r.Run("/bin/true")
+	require.Zero(t, code)
+	_, _, code = r.Run("/bin/true")
+	require.Zero(t, code)
+	assert.Equal(t, 1, s.Connections())
+	time.Sleep(150 * time.Millisecond)
+	_, _, code = r.Run("/bin/true")
+	require.Zero(t, code)
+	assert.Equal(t, 2, s.Connections())
+}
+
+func TestRemoteLogRun_ConnectionMaxSessions(t *testing.T) {
+	s := newTestSSHServer(t, nil)
+	defer s.Close()
+
+	r, err := logrun.NewRemoteLogRun(logrun.RemoteConfig{
+		Credentials: s.Credentials(),
+		ConnectionLimits: logrun.ConnectionLimits{
+			MaxSessions: 1,
+		},
+	})
+	require.NoError(t, err)
+	defer r.Close() // nolint: errcheck
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _, code := r.Shell("sleep 0.2")
+			assert.Zero(t, code)
+		}()
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+	t.Logf("elapsed = %s", elapsed)
+	assert.Equal(t, 1, s.Connections())
+	assert.True(t, elapsed >= 600*time.Millisecond)
+}
+
+func TestRemoteLogRun_ConnectionMaxSessionsCanceled(t *testing.T) {
+	s := newTestSSHServer(t, nil)
+	defer s.Close()
+
+	r, err := logrun.NewRemoteLogRun(logrun.RemoteConfig{
+		Credentials: s.Credentials(),
+		ConnectionLimits: logrun.ConnectionLimits{
+			MaxSessions: 1,
+		},
+	})
+	require.NoError(t, err)
+	defer r.Close() // nolint: errcheck
+	_, _, code := r.Run("/bin/true")
+	require.Zero(t, code)
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		_, _, code := r.Shell("sleep 0.4")
+		assert.Zero(t, code)
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	// A command giving up while waiting for the session does not
+	// free the session of the running command.
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, _, code = r.ShellContext(ctx, "true")
+	assert.NotZero(t, code)
+	_, _, code = r.Shell("true")
+	assert.Zero(t, code)
+	elapsed := time.Since(start)
+	t.Logf("elapsed = %s", elapsed)
+	assert.True(t, elapsed >= 400*time.Millisecond)
+	wg.Wait()
+}
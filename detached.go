@@ -0,0 +1,164 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// DetachCmd is the shell command used by RunDetached() to
+	// start a command that keeps running after the connection is
+	// closed. The %[1]s verb is replaced with the command line to
+	// run, quoted as the argument of the -c option of /bin/sh, and
+	// %[2]s with the quoted path of the log file.
+	// The command must output the PID of the detached process.
+	// This command has been tested on RHEL/CentOS 7 and Ubuntu
+	// 18.04.
+	DetachCmd = "nohup setsid /bin/sh -c %[1]s > %[2]s 2>&1 < /dev/null & echo $!"
+
+	// DetachedStatusSuffix is appended to the log path of a
+	// detached command to form the path of the file its exit code
+	// is written to when it completes.
+	DetachedStatusSuffix = ".status"
+)
+
+// DetachedJob is a command started by RunDetached().
+type DetachedJob struct {
+	// PID is the process ID of the detached command on the host.
+	PID int
+
+	// LogPath is the path of the file on the host that the
+	// standard output and error of the command are written to.
+	LogPath string
+
+	// StatusPath is the path of the file on the host that the
+	// exit code of the command is written to when it completes.
+	StatusPath string
+
+	r *LogRun
+}
+
+// RunDetached starts cmd with args in the background under nohup and
+// setsid, so it keeps running after the connection is closed, and
+// returns immediately. The standard output and error of the command
+// are written to logPath on the host. Use the returned DetachedJob to
+// poll for completion, e.g., from a later invocation of a short-lived
+// controller by recreating it with the same PID and paths. Only
+// logging is performed if Dryrun is true.
+func (r *LogRun) RunDetached(logPath string, cmd string, args ...string) (_ *DetachedJob, err error) {
+	r = r.beginHelper()
+	defer r.addErrorContext(&err, r.commandSeq())
+	job := r.DetachedJob(0, logPath)
+	words := []string{shellQuote(cmd)}
+	for _, arg := range args {
+		words = append(words, shellQuote(arg))
+	}
+	inner := fmt.Sprintf("%s; echo $? > %s", strings.Join(words, " "), shellQuote(job.StatusPath))
+	shellCmd := fmt.Sprintf(DetachCmd, shellCommandArg(inner), shellQuote(logPath))
+	r.logChangeShell(shellCmd)
+	if r.Dryrun {
+		return job, nil
+	}
+	stdout, stderr, code := r.shell(shellCmd)
+	if code != 0 {
+		return nil, fmt.Errorf("could not start %s: %s", cmd, strings.TrimSpace(stderr))
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(stdout))
+	if err != nil {
+		return nil, fmt.Errorf("could not start %s: unexpected output %q", cmd, stdout)
+	}
+	job.PID = pid
+
+	return job, nil
+}
+
+// DetachedJob returns the DetachedJob for a command previously started
+// by RunDetached() with the given PID and log path, e.g., by an
+// earlier invocation of the program.
+func (r *LogRun) DetachedJob(pid int, logPath string) *DetachedJob {
+	return &DetachedJob{
+		PID:        pid,
+		LogPath:    logPath,
+		StatusPath: logPath + DetachedStatusSuffix,
+		r:          r,
+	}
+}
+
+// Done checks whether the detached command has completed. If it has,
+// its exit code is also returned. An error is returned if the command
+// is no longer running but did not record an exit code, e.g., because
+// it was killed.
+func (j *DetachedJob) Done() (bool, int, error) {
+	content, exists, err := j.r.readFile(j.StatusPath)
+	if err != nil {
+		return false, 0, err
+	}
+	if exists {
+		code, err := strconv.Atoi(strings.TrimSpace(content))
+		if err != nil {
+			return true, 0, fmt.Errorf("%s does not contain an exit code: %q", j.StatusPath, content)
+		}
+		return true, code, nil
+	}
+	if j.r.Dryrun {
+		return true, 0, nil
+	}
+	running, err := j.r.ProcessRunning(j.PID)
+	if err != nil {
+		return false, 0, err
+	}
+	if !running {
+		// The command may have completed after the status
+		// file was checked.
+		content, exists, err = j.r.readFile(j.StatusPath)
+		if err == nil && exists {
+			code, _ := strconv.Atoi(strings.TrimSpace(content))
+			return true, code, nil
+		}
+		return true, 0, fmt.Errorf("process %d exited without recording an exit code", j.PID)
+	}
+
+	return false, 0, nil
+}
+
+// Wait polls the detached command every interval using Done() until it
+// completes and returns its exit code. The LogRun's Clock is used to
+// wait between polls.
+func (j *DetachedJob) Wait(interval time.Duration) (int, error) {
+	for {
+		done, code, err := j.Done()
+		if done || err != nil {
+			return code, err
+		}
+		j.r.getClock().Sleep(interval)
+	}
+}
+
+// Log returns the output the detached command has written to its log
+// file so far.
+func (j *DetachedJob) Log() (string, error) {
+	return j.r.GetFileString(j.LogPath)
+}
+
+// Stop kills the detached command and the processes it started, which
+// share its process group since it was started by setsid, using
+// RemoteKillCmd. Only logging is performed if Dryrun is true.
+func (j *DetachedJob) Stop() error {
+	cmd := fmt.Sprintf(RemoteKillCmd, j.PID)
+	j.r.logShell(cmd)
+	if j.r.Dryrun {
+		return nil
+	}
+	_, stderr, code := j.r.shell(cmd)
+	if code != 0 {
+		return fmt.Errorf("could not stop process %d: %s", j.PID, strings.TrimSpace(stderr))
+	}
+
+	return nil
+}
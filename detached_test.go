@@ -0,0 +1,105 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/apatters/go-logrun"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalLogRun_RunDetached(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-logrun-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	logPath := filepath.Join(dir, "job's.log")
+
+	log, out, _ := newLogger()
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{
+		LogFunc: log.Println,
+	})
+	job, err := l.RunDetached(logPath, "/bin/sh", "-c", "echo started; sleep 0.2; echo failed >&2; exit 3")
+	t.Logf("out = %q", out)
+	require.NoError(t, err)
+	t.Logf("job = %+v", job)
+	assert.NotZero(t, job.PID)
+	assert.Equal(t, logPath+".status", job.StatusPath)
+
+	// A later invocation can poll the job.
+	later := l.DetachedJob(job.PID, logPath)
+	done, _, err := later.Done()
+	require.NoError(t, err)
+	assert.False(t, done)
+	code, err := later.Wait(50 * time.Millisecond)
+	require.NoError(t, err)
+	assert.Equal(t, 3, code)
+	output, err := later.Log()
+	require.NoError(t, err)
+	assert.Equal(t, "started\nfailed\n", output)
+}
+
+func TestRemoteLogRun_RunDetached(t *testing.T) {
+	s := newTestSSHServer(t, nil)
+	defer s.Close()
+	dir, err := ioutil.TempDir("", "go-logrun-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	logPath := filepath.Join(dir, "job's.log")
+
+	r, err := logrun.NewRemoteLogRun(logrun.RemoteConfig{Credentials: s.Credentials()})
+	require.NoError(t, err)
+	defer r.Close() // nolint: errcheck
+	job, err := r.RunDetached(logPath, "/bin/sh", "-c", "echo \"$0\" started; sleep 0.2; exit 3")
+	require.NoError(t, err)
+	t.Logf("job = %+v", job)
+	running, err := r.ProcessRunning(job.PID)
+	require.NoError(t, err)
+	assert.True(t, running)
+	code, err := job.Wait(50 * time.Millisecond)
+	require.NoError(t, err)
+	assert.Equal(t, 3, code)
+	output, err := job.Log()
+	require.NoError(t, err)
+	assert.Equal(t, "/bin/sh started\n", output)
+}
+
+func TestLocalLogRun_RunDetachedStop(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-logrun-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{})
+	job, err := l.RunDetached(filepath.Join(dir, "sleep.log"), "sleep", "30")
+	require.NoError(t, err)
+	require.NoError(t, job.Stop())
+	_, err = job.Wait(50 * time.Millisecond)
+	t.Logf("err = %v", err)
+	assert.Error(t, err)
+}
+
+func TestLocalLogRun_RunDetachedDryrun(t *testing.T) {
+	log, out, _ := newLogger()
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{
+		LogFunc: log.Println,
+		Dryrun:  true,
+	})
+	job, err := l.RunDetached("/var/log/job.log", "make", "all")
+	t.Logf("out = %q", out)
+	require.NoError(t, err)
+	assert.Zero(t, job.PID)
+	assert.Contains(t, out.String(), "nohup setsid /bin/sh -c ")
+	assert.Contains(t, out.String(), "/var/log/job.log.status")
+	assert.Contains(t, out.String(), "> '/var/log/job.log' 2>&1 < /dev/null & echo \\$!")
+	done, code, err := job.Done()
+	assert.NoError(t, err)
+	assert.True(t, done)
+	assert.Zero(t, code)
+}
@@ -0,0 +1,180 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultConnectTimeout is the default time allowed for a TCP
+	// connection to a single address of a remote host.
+	DefaultConnectTimeout = 10 * time.Second
+
+	// DefaultConnectDelay is the default time to wait for a
+	// connection attempt to succeed before starting an attempt to
+	// the next address of a remote host.
+	DefaultConnectDelay = 250 * time.Millisecond
+)
+
+// Resolver resolves the hostname of a remote host to the addresses
+// used to connect to it. *net.Resolver implements Resolver.
+type Resolver interface {
+	LookupHost(ctx context.Context, host string) ([]string, error)
+}
+
+// ResolverFunc is an adapter to allow the use of ordinary functions
+// as Resolvers.
+type ResolverFunc func(ctx context.Context, host string) ([]string, error)
+
+// LookupHost calls f(ctx, host).
+func (f ResolverFunc) LookupHost(ctx context.Context, host string) ([]string, error) {
+	return f(ctx, host)
+}
+
+// dialResult is the outcome of a connection attempt to addr.
+type dialResult struct {
+	addr string
+	conn net.Conn
+	err  error
+}
+
+// resolveAddrs returns the addresses of host ordered for connection
+// attempts. IP literals are returned as is. If resolver is not nil,
+// it is used to resolve host; any names it returns rather than
+// addresses are resolved using the system resolver.
+func resolveAddrs(ctx context.Context, resolver Resolver, host string) ([]string, error) {
+	names := []string{host}
+	if resolver != nil {
+		var err error
+		names, err = resolver.LookupHost(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		if len(names) == 0 {
+			return nil, fmt.Errorf("no addresses found for %s", host)
+		}
+	}
+	var addrs []string
+	for _, name := range names {
+		if ip := net.ParseIP(name); ip != nil {
+			addrs = append(addrs, ip.String())
+			continue
+		}
+		ipAddrs, err := net.DefaultResolver.LookupIPAddr(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		for _, ipAddr := range ipAddrs {
+			addrs = append(addrs, ipAddr.String())
+		}
+	}
+
+	return interleaveAddrs(addrs), nil
+}
+
+// interleaveAddrs orders addrs so that the address families
+// alternate, starting with the family of the first address, as
+// recommended by RFC 8305. The order within each family is
+// preserved.
+func interleaveAddrs(addrs []string) []string {
+	var first, second []string
+	firstIsV4 := len(addrs) > 0 && !strings.Contains(addrs[0], ":")
+	for _, addr := range addrs {
+		if !strings.Contains(addr, ":") == firstIsV4 {
+			first = append(first, addr)
+		} else {
+			second = append(second, addr)
+		}
+	}
+	ordered := make([]string, 0, len(addrs))
+	for i := 0; i < len(first) || i < len(second); i++ {
+		if i < len(first) {
+			ordered = append(ordered, first[i])
+		}
+		if i < len(second) {
+			ordered = append(ordered, second[i])
+		}
+	}
+
+	return ordered
+}
+
+// dialAddrs connects to port on one of addrs. Connection attempts are
+// started in order, each delay after the previous one or as soon as
+// the previous one fails, so an unreachable address does not hold up
+// the others. Each attempt is given timeout to complete. The first
+// successful connection is returned along with the address used; the
+// other connections are closed.
+func dialAddrs(
+	ctx context.Context,
+	addrs []string,
+	port int,
+	timeout time.Duration,
+	delay time.Duration) (net.Conn, string, error) {
+
+	if len(addrs) == 0 {
+		return nil, "", fmt.Errorf("no addresses to connect to")
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	results := make(chan dialResult, len(addrs))
+	attempt := func(addr string) {
+		dialer := net.Dialer{Timeout: timeout}
+		conn, err := dialer.DialContext(ctx, "tcp", addr)
+		results <- dialResult{addr: addr, conn: conn, err: err}
+	}
+
+	var errs []string
+	next, pending := 0, 0
+	for {
+		if next < len(addrs) {
+			go attempt(net.JoinHostPort(addrs[next], strconv.Itoa(port)))
+			next++
+			pending++
+		}
+		var fallback <-chan time.Time
+		var timer *time.Timer
+		if next < len(addrs) {
+			timer = time.NewTimer(delay)
+			fallback = timer.C
+		}
+		select {
+		case res := <-results:
+			pending--
+			if timer != nil {
+				timer.Stop()
+			}
+			if res.err == nil {
+				cancel()
+				go closeDials(results, pending)
+				return res.conn, res.addr, nil
+			}
+			errs = append(errs, res.err.Error())
+			if pending == 0 && next == len(addrs) {
+				return nil, "", fmt.Errorf("%s", strings.Join(errs, "; "))
+			}
+		case <-fallback:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+}
+
+// closeDials closes the connections of the n attempts still pending
+// once a connection has been chosen.
+func closeDials(results <-chan dialResult, n int) {
+	for ; n > 0; n-- {
+		if res := <-results; res.conn != nil {
+			res.conn.Close() // nolint: errcheck
+		}
+	}
+}
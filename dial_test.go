@@ -0,0 +1,123 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/apatters/go-logrun"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// closingListener accepts connections on an IPv4 loopback port and
+// closes them immediately.
+func closingListener(t *testing.T) (net.Listener, int) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close() // nolint: errcheck
+		}
+	}()
+
+	return l, l.Addr().(*net.TCPAddr).Port
+}
+
+func TestRemoteLogRun_ConnectMultipleAddresses(t *testing.T) {
+	l, port := closingListener(t)
+	defer l.Close() // nolint: errcheck
+
+	// localhost may also resolve to ::1 where nothing is
+	// listening; the IPv4 address must still be used.
+	r, err := logrun.NewRemoteLogRun(logrun.RemoteConfig{
+		Credentials: logrun.Credentials{
+			Hostname: "localhost",
+			Port:     port,
+			Password: "secret",
+		},
+		ConnectTimeout: time.Second,
+		ConnectDelay:   10 * time.Millisecond,
+	})
+	require.NoError(t, err)
+	_, stderr, code := r.Run("/bin/true")
+	t.Logf("stderr = %q, code = %d", stderr, code)
+	assert.Equal(t, logrun.ExitErrorExecute, code)
+	addr := net.JoinHostPort("127.0.0.1", strconv.Itoa(port))
+	assert.Contains(t, stderr, addr)
+	assert.Equal(t, addr, r.Host().Address)
+}
+
+func TestRemoteLogRun_ConnectRefused(t *testing.T) {
+	l, port := closingListener(t)
+	l.Close() // nolint: errcheck
+
+	r, err := logrun.NewRemoteLogRun(logrun.RemoteConfig{
+		Credentials: logrun.Credentials{
+			Hostname: "127.0.0.1",
+			Port:     port,
+			Password: "secret",
+		},
+	})
+	require.NoError(t, err)
+	_, stderr, code := r.Run("/bin/true")
+	t.Logf("stderr = %q, code = %d", stderr, code)
+	assert.Equal(t, logrun.ExitErrorExecute, code)
+	assert.Contains(t, stderr, "connection refused")
+	assert.Empty(t, r.Host().Address)
+}
+
+func TestRemoteLogRun_Resolver(t *testing.T) {
+	l, port := closingListener(t)
+	defer l.Close() // nolint: errcheck
+
+	var looked []string
+	r, err := logrun.NewRemoteLogRun(logrun.RemoteConfig{
+		Credentials: logrun.Credentials{
+			Hostname: "db1.service.consul",
+			Port:     port,
+			Password: "secret",
+		},
+		Resolver: logrun.ResolverFunc(func(ctx context.Context, host string) ([]string, error) {
+			looked = append(looked, host)
+			return []string{"127.0.0.1"}, nil
+		}),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "ssh "+username()+"@db1.service.consul /bin/true", r.Runner.FormatRun("/bin/true"))
+	_, stderr, code := r.Run("/bin/true")
+	t.Logf("stderr = %q, code = %d", stderr, code)
+	assert.Equal(t, logrun.ExitErrorExecute, code)
+	assert.Equal(t, []string{"db1.service.consul"}, looked)
+	assert.Contains(t, stderr, "db1.service.consul")
+	assert.Equal(t, net.JoinHostPort("127.0.0.1", strconv.Itoa(port)), r.Host().Address)
+	assert.Equal(t, "db1.service.consul", r.Host().Hostname)
+}
+
+func TestRemoteLogRun_ResolverError(t *testing.T) {
+	r, err := logrun.NewRemoteLogRun(logrun.RemoteConfig{
+		Credentials: logrun.Credentials{
+			Hostname: "db1.service.consul",
+			Password: "secret",
+		},
+		Resolver: logrun.ResolverFunc(func(ctx context.Context, host string) ([]string, error) {
+			return nil, errors.New("service db1 not registered")
+		}),
+	})
+	require.NoError(t, err)
+	_, stderr, code := r.Run("/bin/true")
+	t.Logf("stderr = %q, code = %d", stderr, code)
+	assert.Equal(t, logrun.ExitErrorExecute, code)
+	assert.Contains(t, stderr, "service db1 not registered")
+}
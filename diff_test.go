@@ -0,0 +1,65 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun_test
+
+import (
+	"testing"
+
+	"github.com/apatters/go-logrun"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	tests := []struct {
+		name     string
+		old, new string
+		expected string
+	}{
+		{"identical", "a\nb\n", "a\nb\n", ""},
+		{
+			"modify",
+			"a\nb\nc\n",
+			"a\nB\nc\n",
+			"--- old\n+++ new\n@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n",
+		},
+		{
+			"create",
+			"",
+			"a\nb\n",
+			"--- old\n+++ new\n@@ -0,0 +1,2 @@\n+a\n+b\n",
+		},
+		{
+			"delete",
+			"a\n",
+			"",
+			"--- old\n+++ new\n@@ -1 +0,0 @@\n-a\n",
+		},
+		{
+			"no newline",
+			"a\nb",
+			"a\nb\n",
+			"--- old\n+++ new\n@@ -1,2 +1,2 @@\n a\n-b\n\\ No newline at end of file\n+b\n",
+		},
+		{
+			"context",
+			"1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n13\n14\n15\n16\n",
+			"1\nTWO\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n13\n14\n15\nSIXTEEN\n",
+			"--- old\n+++ new\n" +
+				"@@ -1,5 +1,5 @@\n 1\n-2\n+TWO\n 3\n 4\n 5\n" +
+				"@@ -13,4 +13,4 @@\n 13\n 14\n 15\n-16\n+SIXTEEN\n",
+		},
+		{
+			"merged hunks",
+			"1\n2\n3\n4\n5\n6\n7\n8\n",
+			"ONE\n2\n3\n4\n5\n6\n7\nEIGHT\n",
+			"--- old\n+++ new\n@@ -1,8 +1,8 @@\n-1\n+ONE\n 2\n 3\n 4\n 5\n 6\n 7\n-8\n+EIGHT\n",
+		},
+	}
+	for _, test := range tests {
+		diff := logrun.UnifiedDiff("old", "new", test.old, test.new)
+		t.Logf("%s: diff = %q", test.name, diff)
+		assert.Equal(t, test.expected, diff, test.name)
+	}
+}
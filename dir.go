@@ -0,0 +1,52 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun
+
+import (
+	"fmt"
+)
+
+// PushDir makes dir the working directory of the commands run by the
+// LogRun until the matching PopDir() call. A relative dir is relative
+// to the current working directory of the LogRun. The effective
+// directory is included in logged commands, e.g.,
+//
+//	runner.PushDir("/srv/app")
+//	defer runner.PopDir() // nolint: errcheck
+//	runner.Run("make", "all") // logs "cd '/srv/app' && make all"
+func (r *LogRun) PushDir(dir string) {
+	if cur := r.Dir(); cur != "" && !r.isAbsPath(dir) {
+		dir = r.joinPath(cur, dir)
+	}
+	// Always copy the stack so copies made by With() do not share
+	// it.
+	dirs := make([]string, len(r.dirs), len(r.dirs)+1)
+	copy(dirs, r.dirs)
+	r.dirs = append(dirs, dir)
+}
+
+// PopDir restores the working directory that was in effect before the
+// last PushDir() call and returns the directory that was popped. An
+// error is returned if the directory stack is empty.
+func (r *LogRun) PopDir() (string, error) {
+	if len(r.dirs) == 0 {
+		return "", fmt.Errorf("directory stack is empty")
+	}
+	dir := r.dirs[len(r.dirs)-1]
+	r.dirs = r.dirs[:len(r.dirs)-1]
+
+	return dir, nil
+}
+
+// Dir returns the working directory set by PushDir(). The empty
+// string is returned if the directory stack is empty, in which case
+// commands run in the directory the LogRun was constructed with.
+func (r *LogRun) Dir() string {
+	if len(r.dirs) == 0 {
+		return ""
+	}
+
+	return r.dirs[len(r.dirs)-1]
+}
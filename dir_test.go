@@ -0,0 +1,87 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/apatters/go-logrun"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalLogRun_PushDir(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "logrun")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmpDir) // nolint: errcheck
+	require.NoError(t, os.Mkdir(filepath.Join(tmpDir, "sub"), 0755))
+
+	var logged []string
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{
+		LogFunc: func(v ...interface{}) {
+			logged = append(logged, v[0].(string))
+		},
+	})
+	assert.Equal(t, "", l.Dir())
+
+	l.PushDir(tmpDir)
+	assert.Equal(t, tmpDir, l.Dir())
+	stdout, _, code := l.Run("/bin/pwd")
+	assert.Zero(t, code)
+	assert.Equal(t, tmpDir, strings.TrimSpace(stdout))
+
+	l.PushDir("sub")
+	assert.Equal(t, filepath.Join(tmpDir, "sub"), l.Dir())
+	stdout, _, code = l.Shell("pwd")
+	assert.Zero(t, code)
+	assert.Equal(t, filepath.Join(tmpDir, "sub"), strings.TrimSpace(stdout))
+	exists, err := l.DirExists("../sub")
+	assert.NoError(t, err)
+	assert.True(t, exists)
+
+	dir, err := l.PopDir()
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(tmpDir, "sub"), dir)
+	dir, err = l.PopDir()
+	assert.NoError(t, err)
+	assert.Equal(t, tmpDir, dir)
+	_, err = l.PopDir()
+	assert.Error(t, err)
+
+	for _, msg := range logged {
+		t.Logf("logged = %q", msg)
+	}
+	require.True(t, len(logged) >= 2)
+	assert.Equal(t, "cd '"+tmpDir+"' && /bin/pwd", logged[0])
+	assert.Equal(t, "cd '"+tmpDir+"/sub' && /bin/sh -c \"pwd\"", logged[1])
+}
+
+func TestLocalLogRun_PushDirWith(t *testing.T) {
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{})
+	l.PushDir("/tmp")
+	c := l.With()
+	c.PushDir("/var")
+	assert.Equal(t, "/tmp", l.Dir())
+	assert.Equal(t, "/var", c.Dir())
+	assert.Equal(t, "cd '/var' && ls -l", c.FormatRun("ls", "-l"))
+	_, err := l.PopDir()
+	assert.NoError(t, err)
+	assert.Equal(t, "ls -l", l.FormatRun("ls", "-l"))
+}
+
+func TestRemoteLogRun_PushDir(t *testing.T) {
+	r, err := logrun.NewRemoteLogRun(logrun.RemoteConfig{})
+	require.NoError(t, err)
+	r.PushDir("/tmp")
+	assert.Equal(t, "ssh "+username()+"@localhost cd '/tmp' && /bin/pwd", r.FormatRun("/bin/pwd"))
+	stdout, stderr, code := r.Run("/bin/pwd")
+	t.Logf("stdout = %q, stderr = %q, code = %d", stdout, stderr, code)
+	assert.Zero(t, code)
+	assert.Equal(t, "/tmp", strings.TrimSpace(stdout))
+}
@@ -0,0 +1,597 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+var (
+	// DefaultDockerHost is the address of the Docker daemon used
+	// if neither DockerConfig.Host nor the DOCKER_HOST environment
+	// variable is set.
+	DefaultDockerHost = "unix:///var/run/docker.sock"
+
+	// DockerAPIVersion is the version of the Docker Engine API
+	// requested. Version 1.35 (Docker 17.12) is the first to
+	// support working directories for exec.
+	DockerAPIVersion = "1.35"
+)
+
+// dockerExitPollInterval is the interval at which the exit code of a
+// command is polled if the daemon has not recorded it yet when its
+// output ends.
+const dockerExitPollInterval = 10 * time.Millisecond
+
+// DockerConfig is used to set options in the NewDockerLogRun
+// constructor. The fields not described here have the same meaning as
+// in LocalConfig.
+type DockerConfig struct {
+	LogFunc       LogFunc
+	LogHook       LogHook
+	ResultStore   ResultStore
+	ResultLogFunc LogFunc
+	Verbosity     Verbosity
+
+	// Host is the address of the Docker daemon, e.g.,
+	// "unix:///var/run/docker.sock" or "tcp://10.0.0.5:2375". If
+	// empty, the DOCKER_HOST environment variable or, if it is
+	// not set, DefaultDockerHost is used. TLS is not supported.
+	Host string
+
+	// ShellExecutable is the shell in the container used to run
+	// shell commands. If empty, DefaultShellExecutable is used.
+	ShellExecutable string
+
+	// RunAs is the user or UID commands are run as, like the
+	// --user option of docker exec. If empty, the user of the
+	// container is used.
+	RunAs string
+
+	// Env holds "KEY=VALUE" environment variables set for every
+	// command in addition to the environment of the container.
+	Env []string
+
+	// Dir, if not empty, is the working directory of commands.
+	Dir string
+
+	Stdout io.Writer
+	Stderr io.Writer
+
+	Dryrun           bool
+	OutputEncoding   OutputEncoding
+	NormalizeCRLF    bool
+	Timeout          time.Duration
+	Clock            Clock
+	StderrClassifier *StderrClassifier
+	Vars             map[string]string
+	Tags             map[string]string
+}
+
+// NewDockerLogRun is the constructor for a LogRun that runs commands
+// in the running container with the ID or name containerID using the
+// Docker Engine API, like docker exec. The file helpers run commands
+// in the container like for remote hosts, and Rsync() copies files
+// from the controller into the container like docker cp. No
+// connection is made until the first command is run.
+//
+// Commands can be canceled and given per-command I/O, environments,
+// and working directories, but since Docker cannot stop exec'd
+// processes, canceled commands keep running in the container.
+// Detached commands and process tracking are not supported.
+func NewDockerLogRun(containerID string, config DockerConfig) (*LogRun, error) {
+	d, err := newDockerRunner(containerID, config)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewLogRun(d, LogRunConfig{
+		LogFunc:          config.LogFunc,
+		LogHook:          config.LogHook,
+		ResultStore:      config.ResultStore,
+		ResultLogFunc:    config.ResultLogFunc,
+		Verbosity:        config.Verbosity,
+		Dryrun:           config.Dryrun,
+		OutputEncoding:   config.OutputEncoding,
+		NormalizeCRLF:    config.NormalizeCRLF,
+		Timeout:          config.Timeout,
+		Clock:            config.Clock,
+		StderrClassifier: config.StderrClassifier,
+		Vars:             config.Vars,
+		Tags:             config.Tags,
+	}), nil
+}
+
+// dockerRunner runs commands in a container using the Docker Engine
+// API.
+type dockerRunner struct {
+	containerID     string
+	host            string
+	network         string
+	address         string
+	client          *http.Client
+	shellExecutable string
+	runAs           string
+	env             []string
+	dir             string
+	stdout          io.Writer
+	stderr          io.Writer
+}
+
+func newDockerRunner(containerID string, config DockerConfig) (*dockerRunner, error) {
+	if containerID == "" {
+		return nil, fmt.Errorf("no container specified")
+	}
+	d := &dockerRunner{
+		containerID:     containerID,
+		host:            config.Host,
+		shellExecutable: config.ShellExecutable,
+		runAs:           config.RunAs,
+		env:             config.Env,
+		dir:             config.Dir,
+		stdout:          config.Stdout,
+		stderr:          config.Stderr,
+	}
+	if d.host == "" {
+		d.host = os.Getenv("DOCKER_HOST")
+	}
+	if d.host == "" {
+		d.host = DefaultDockerHost
+	}
+	if d.shellExecutable == "" {
+		d.shellExecutable = DefaultShellExecutable
+	}
+	switch {
+	case strings.HasPrefix(d.host, "unix://"):
+		d.network, d.address = "unix", strings.TrimPrefix(d.host, "unix://")
+	case strings.HasPrefix(d.host, "tcp://"):
+		d.network, d.address = "tcp", strings.TrimPrefix(d.host, "tcp://")
+	default:
+		return nil, fmt.Errorf("unsupported Docker host %q", d.host)
+	}
+	d.client = &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return d.dial(ctx)
+			},
+		},
+	}
+
+	return d, nil
+}
+
+func (d *dockerRunner) dial(ctx context.Context) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: DefaultConnectTimeout}
+
+	return dialer.DialContext(ctx, d.network, d.address)
+}
+
+func (d *dockerRunner) hostInfo() HostInfo {
+	return HostInfo{
+		Hostname: d.containerID,
+		Username: d.runAs,
+	}
+}
+
+// Run runs a command like glibc's exec() call. It returns the
+// standard out, standard error, and exit code of the command when it
+// completes.
+func (d *dockerRunner) Run(cmd string, args ...string) (string, string, int, error) {
+	return d.execute(&execSpec{ctx: context.Background(), cmd: cmd, args: args})
+}
+
+// FormatRun returns a string representation of the what command would
+// be run using Run(). Useful for logging commands.
+func (d *dockerRunner) FormatRun(cmd string, args ...string) string {
+	return d.format(&execSpec{cmd: cmd, args: args})
+}
+
+// Shell runs a command in a shell. The command is passed to the shell
+// as the -c option. It returns the standard out, standard error, and
+// exit code of the command when it completes.
+func (d *dockerRunner) Shell(cmd string) (string, string, int, error) {
+	return d.execute(&execSpec{ctx: context.Background(), cmd: cmd, shell: true})
+}
+
+// FormatShell returns a string representation of the what command
+// would be run using Shell(). Useful for logging commands.
+func (d *dockerRunner) FormatShell(cmd string) string {
+	return d.format(&execSpec{cmd: cmd, shell: true})
+}
+
+// format returns a string representation of the command described by
+// spec using the options of docker exec.
+func (d *dockerRunner) format(spec *execSpec) string {
+	words := []string{"docker", "exec"}
+	if user := runAsUser(spec, d.runAs); user != "" {
+		words = append(words, "-u", shellQuote(user))
+	}
+	if dir := d.workDir(spec); dir != "" {
+		words = append(words, "-w", shellQuote(dir))
+	}
+	for _, kv := range maskEnv(appendEnv(d.env, spec.env)) {
+		words = append(words, "-e", shellQuote(kv))
+	}
+	words = append(words, d.containerID)
+	if spec.shell {
+		words = append(words, fmt.Sprintf(`%s -c %s`, d.shellExecutable, shellCommandArg(spec.cmd)))
+	} else {
+		words = append(words, shellCommandLine(spec.cmd, spec.args))
+	}
+
+	return strings.TrimSpace(strings.Join(words, " "))
+}
+
+// workDir returns the working directory of the command described by
+// spec, which is relative to the Dir the runner was configured with.
+func (d *dockerRunner) workDir(spec *execSpec) string {
+	switch {
+	case spec.dir == "":
+		return d.dir
+	case d.dir == "" || path.IsAbs(spec.dir):
+		return spec.dir
+	}
+
+	return path.Join(d.dir, spec.dir)
+}
+
+func (d *dockerRunner) execute(spec *execSpec) (string, string, int, error) {
+	if spec.onStart != nil || spec.pidFile != "" {
+		return "", "", 0, fmt.Errorf("process tracking is not supported in Docker containers")
+	}
+	ctx := spec.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	// Hook up standard files.
+	var stdoutBuf, stderrBuf bytes.Buffer
+	stdout, stderr := d.stdout, d.stderr
+	if spec.capture {
+		stdout, stderr = nil, nil
+	}
+	if spec.stdout != nil {
+		stdout = spec.stdout
+	}
+	if stdout == nil {
+		stdout = &stdoutBuf
+	}
+	if spec.stderr != nil {
+		stderr = spec.stderr
+	}
+	if stderr == nil {
+		stderr = &stderrBuf
+	}
+
+	argv := append([]string{spec.cmd}, spec.args...)
+	if spec.shell {
+		argv = []string{d.shellExecutable, "-c", spec.cmd}
+	}
+	var created struct {
+		ID string `json:"Id"`
+	}
+	err := d.call(ctx, http.MethodPost, "/containers/"+url.PathEscape(d.containerID)+"/exec", map[string]interface{}{
+		"AttachStdin":  spec.stdin != nil,
+		"AttachStdout": true,
+		"AttachStderr": true,
+		"Tty":          false,
+		"Cmd":          argv,
+		"Env":          appendEnv(d.env, spec.env),
+		"WorkingDir":   d.workDir(spec),
+		"User":         runAsUser(spec, d.runAs),
+	}, &created)
+	if err != nil {
+		return "", "", 0, err
+	}
+	if err := d.startExec(ctx, created.ID, spec.stdin, stdout, stderr); err != nil {
+		if ctx.Err() != nil {
+			return "", "", 0, ctx.Err()
+		}
+		return "", "", 0, err
+	}
+	code, err := d.exitCode(ctx, created.ID)
+	if err != nil {
+		return "", "", 0, err
+	}
+
+	return stdoutBuf.String(), stderrBuf.String(), code, nil
+}
+
+// startExec starts the exec instance with id, sends stdin, if not nil,
+// to it, and copies its output to stdout and stderr until it ends.
+// The connection is hijacked by the daemon, so the request is written
+// to it directly.
+func (d *dockerRunner) startExec(ctx context.Context, id string, stdin io.Reader, stdout io.Writer, stderr io.Writer) error {
+	conn, err := d.dial(ctx)
+	if err != nil {
+		return d.apiError(err)
+	}
+	defer conn.Close() // nolint: errcheck
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close() // nolint: errcheck
+		case <-stop:
+		}
+	}()
+
+	body := []byte(`{"Detach":false,"Tty":false}`)
+	req, err := http.NewRequest(http.MethodPost, d.apiURL("/exec/"+url.PathEscape(id)+"/start"), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "tcp")
+	if err := req.Write(conn); err != nil {
+		return d.apiError(err)
+	}
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		return d.apiError(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols && resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close() // nolint: errcheck
+		return d.statusError(resp)
+	}
+	if stdin != nil {
+		go func() {
+			io.Copy(conn, stdin) // nolint: errcheck
+			if cw, ok := conn.(interface{ CloseWrite() error }); ok {
+				cw.CloseWrite() // nolint: errcheck
+			}
+		}()
+	}
+	var output io.Reader = br
+	if resp.StatusCode == http.StatusOK {
+		output = resp.Body
+	}
+
+	return demuxDockerStream(output, stdout, stderr)
+}
+
+// demuxDockerStream copies the frames of the multiplexed stdout and
+// stderr stream r of a command without a TTY to stdout and stderr.
+// Each frame has an 8 byte header holding the stream (1 for stdout, 2
+// for stderr) and the big-endian length of its payload.
+func demuxDockerStream(r io.Reader, stdout io.Writer, stderr io.Writer) error {
+	var header [8]byte
+	for {
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		w := stdout
+		if header[0] == 2 {
+			w = stderr
+		}
+		if _, err := io.CopyN(w, r, int64(binary.BigEndian.Uint32(header[4:]))); err != nil {
+			return err
+		}
+	}
+}
+
+// exitCode returns the exit code of the exec instance with id once it
+// has finished.
+func (d *dockerRunner) exitCode(ctx context.Context, id string) (int, error) {
+	for {
+		var inspect struct {
+			Running  bool
+			ExitCode int
+		}
+		if err := d.call(ctx, http.MethodGet, "/exec/"+url.PathEscape(id)+"/json", nil, &inspect); err != nil {
+			return 0, err
+		}
+		if !inspect.Running {
+			return inspect.ExitCode, nil
+		}
+		select {
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		case <-time.After(dockerExitPollInterval):
+		}
+	}
+}
+
+// copyTo copies src on the controller into the directory dest in the
+// container like docker cp. If src is a directory ending with a
+// slash, its contents are copied like rsync.
+func (d *dockerRunner) copyTo(src string, dest string) (int64, error) {
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(writeTar(pw, src)) // nolint: errcheck
+	}()
+	defer pr.Close() // nolint: errcheck
+	body := &countingReader{rd: pr}
+	p := "/containers/" + url.PathEscape(d.containerID) + "/archive?path=" + url.QueryEscape(dest)
+	req, err := http.NewRequest(http.MethodPut, d.apiURL(p), body)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/x-tar")
+	err = d.do(req, nil)
+
+	return body.n, err
+}
+
+// formatCopy returns a string representation of copyTo() suitable for
+// logging.
+func (d *dockerRunner) formatCopy(src string, dest string) string {
+	return fmt.Sprintf("docker cp %s %s:%s", shellQuote(src), d.containerID, shellQuote(dest))
+}
+
+// writeTar writes a tar archive of src to w. Entries are named
+// relative to the parent of src or, if src ends with a slash, to src.
+func writeTar(w io.Writer, src string) error {
+	tw := tar.NewWriter(w)
+	base := filepath.Dir(filepath.Clean(src))
+	if strings.HasSuffix(src, "/") || strings.HasSuffix(src, string(filepath.Separator)) {
+		base = filepath.Clean(src)
+	}
+	err := filepath.Walk(src, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		name, err := filepath.Rel(base, p)
+		if err != nil || name == "." {
+			return err
+		}
+		var link string
+		if info.Mode()&os.ModeSymlink != 0 {
+			if link, err = os.Readlink(p); err != nil {
+				return err
+			}
+		}
+		header, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(name)
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close() // nolint: errcheck
+		_, err = io.Copy(tw, f)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	return tw.Close()
+}
+
+// call sends a request with a JSON body, if not nil, and decodes the
+// JSON response into out, if not nil.
+func (d *dockerRunner) call(ctx context.Context, method string, p string, body interface{}, out interface{}) error {
+	var rd io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		rd = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, d.apiURL(p), rd)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	return d.do(req, out)
+}
+
+func (d *dockerRunner) do(req *http.Request, out interface{}) error {
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return d.apiError(err)
+	}
+	defer resp.Body.Close() // nolint: errcheck
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return d.statusError(resp)
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return d.apiError(fmt.Errorf("invalid response: %w", err))
+	}
+
+	return nil
+}
+
+// statusError returns the error reported by the daemon in resp. A
+// missing container or path is reported as ErrNotFound.
+func (d *dockerRunner) statusError(resp *http.Response) error {
+	var msg struct {
+		Message string `json:"message"`
+	}
+	data, _ := ioutil.ReadAll(resp.Body)
+	if json.Unmarshal(data, &msg) != nil || msg.Message == "" {
+		msg.Message = strings.TrimSpace(string(data))
+	}
+	if msg.Message == "" {
+		msg.Message = resp.Status
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return d.apiError(fmt.Errorf("%s: %w", msg.Message, ErrNotFound))
+	}
+
+	return d.apiError(fmt.Errorf("%s", msg.Message))
+}
+
+// apiError describes an error talking to the Docker daemon.
+func (d *dockerRunner) apiError(err error) error {
+	return fmt.Errorf("docker %s: %w", d.containerID, err)
+}
+
+// apiURL returns the URL of the API endpoint p. The host is ignored
+// since connections are made using dial().
+func (d *dockerRunner) apiURL(p string) string {
+	return "http://docker/v" + DockerAPIVersion + p
+}
+
+// dockerRunner returns the Runner of r if it runs commands in a Docker
+// container, and nil otherwise.
+func (r *LogRun) dockerRunner() *dockerRunner {
+	if d, ok := r.Runner.(*dockerRunner); ok {
+		return d
+	}
+
+	return nil
+}
+
+// dockerCopy logs and copies src on the controller into dest in the
+// container. Only logging is performed if Dryrun is true. In check
+// mode the copy is recorded as a command since the files it would
+// change are not known.
+func (r *LogRun) dockerCopy(d *dockerRunner, src string, dest string) error {
+	msg := d.formatCopy(src, dest)
+	r.log(msg)
+	if r.Dryrun {
+		return nil
+	}
+	if r.checking() {
+		r.check.add(Change{Action: ChangeRun, Target: msg})
+		return nil
+	}
+	sent, err := d.copyTo(src, dest)
+	r.recordTransfer("Rsync", dest, sent, 0)
+	if err != nil {
+		return fmt.Errorf("copy to container failed: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,337 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun_test
+
+import (
+	"archive/tar"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/apatters/go-logrun"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testDockerDaemon implements the parts of the Docker Engine API used
+// by logrun on a Unix socket. Commands are run locally in the
+// container named "app".
+type testDockerDaemon struct {
+	socket string
+	mu     sync.Mutex
+	execs  map[string]*testDockerExec
+}
+
+type testDockerExec struct {
+	config struct {
+		AttachStdin bool
+		Cmd         []string
+		Env         []string
+		WorkingDir  string
+		User        string
+	}
+	exitCode int
+}
+
+func newTestDockerDaemon(t *testing.T) *testDockerDaemon {
+	d := &testDockerDaemon{
+		socket: filepath.Join(t.TempDir(), "docker.sock"),
+		execs:  make(map[string]*testDockerExec),
+	}
+	l, err := net.Listen("unix", d.socket)
+	require.NoError(t, err)
+	server := &http.Server{Handler: http.HandlerFunc(d.serve)}
+	go server.Serve(l) // nolint: errcheck
+	t.Cleanup(func() {
+		server.Close() // nolint: errcheck
+	})
+
+	return d
+}
+
+// Host returns the address of the daemon.
+func (d *testDockerDaemon) Host() string {
+	return "unix://" + d.socket
+}
+
+// lastExec returns the most recently created exec instance.
+func (d *testDockerDaemon) lastExec() *testDockerExec {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.execs[strconv.Itoa(len(d.execs)-1)]
+}
+
+func (d *testDockerDaemon) serve(w http.ResponseWriter, req *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(req.URL.Path, "/v1.35/"), "/")
+	switch {
+	case len(parts) == 3 && parts[0] == "containers" && parts[1] != "app":
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"message": "No such container: " + parts[1]}) // nolint: errcheck
+	case len(parts) == 3 && parts[2] == "exec":
+		e := new(testDockerExec)
+		json.NewDecoder(req.Body).Decode(&e.config) // nolint: errcheck
+		d.mu.Lock()
+		id := strconv.Itoa(len(d.execs))
+		d.execs[id] = e
+		d.mu.Unlock()
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]string{"Id": id}) // nolint: errcheck
+	case len(parts) == 3 && parts[2] == "start":
+		d.mu.Lock()
+		e := d.execs[parts[1]]
+		d.mu.Unlock()
+		ioutil.ReadAll(req.Body) // nolint: errcheck
+		d.start(w, e)
+	case len(parts) == 3 && parts[2] == "json":
+		d.mu.Lock()
+		code := d.execs[parts[1]].exitCode
+		d.mu.Unlock()
+		json.NewEncoder(w).Encode(map[string]interface{}{"Running": false, "ExitCode": code}) // nolint: errcheck
+	case len(parts) == 3 && parts[2] == "archive":
+		if err := extractTestTar(req.Body, req.URL.Query().Get("path")); err != nil {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]string{"message": err.Error()}) // nolint: errcheck
+		}
+	default:
+		http.NotFound(w, req)
+	}
+}
+
+// start runs the command of e on the hijacked connection of w.
+func (d *testDockerDaemon) start(w http.ResponseWriter, e *testDockerExec) {
+	conn, rw, err := w.(http.Hijacker).Hijack()
+	if err != nil {
+		return
+	}
+	defer conn.Close()                                // nolint: errcheck
+	io.WriteString(conn, "HTTP/1.1 101 UPGRADED\r\n"+ // nolint: errcheck
+		"Content-Type: application/vnd.docker.raw-stream\r\n"+
+		"Connection: Upgrade\r\nUpgrade: tcp\r\n\r\n")
+	var mu sync.Mutex
+	cmd := exec.Command(e.config.Cmd[0], e.config.Cmd[1:]...)
+	cmd.Env = append(os.Environ(), e.config.Env...)
+	cmd.Dir = e.config.WorkingDir
+	cmd.Stdout = &testDockerFrameWriter{conn: conn, mu: &mu, stream: 1}
+	cmd.Stderr = &testDockerFrameWriter{conn: conn, mu: &mu, stream: 2}
+	if e.config.AttachStdin {
+		cmd.Stdin = rw.Reader
+	}
+	err = cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		d.mu.Lock()
+		e.exitCode = exitErr.ExitCode()
+		d.mu.Unlock()
+	}
+}
+
+type testDockerFrameWriter struct {
+	conn   net.Conn
+	mu     *sync.Mutex
+	stream byte
+}
+
+func (f *testDockerFrameWriter) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	header := make([]byte, 8)
+	header[0] = f.stream
+	binary.BigEndian.PutUint32(header[4:], uint32(len(p)))
+	if _, err := f.conn.Write(append(header, p...)); err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+func extractTestTar(r io.Reader, dir string) error {
+	if _, err := os.Stat(dir); err != nil {
+		return err
+	}
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		p := filepath.Join(dir, header.Name)
+		if header.Typeflag == tar.TypeDir {
+			if err := os.MkdirAll(p, os.FileMode(header.Mode)); err != nil {
+				return err
+			}
+			continue
+		}
+		data, err := ioutil.ReadAll(tr)
+		if err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(p, data, os.FileMode(header.Mode)); err != nil {
+			return err
+		}
+	}
+}
+
+func TestDockerLogRun_Run(t *testing.T) {
+	d := newTestDockerDaemon(t)
+	log, out, errOut := newLogger()
+	r, err := logrun.NewDockerLogRun("app", logrun.DockerConfig{
+		LogFunc: log.Println,
+		Host:    d.Host(),
+	})
+	require.NoError(t, err)
+
+	stdout, stderr, code := r.Run("echo", "hello", "world")
+	t.Logf("stdout = %q", stdout)
+	t.Logf("stderr = %q", stderr)
+	t.Logf("code = %d", code)
+	t.Logf("out = %q", out)
+	assert.Equal(t, "hello world\n", stdout)
+	assert.Empty(t, stderr)
+	assert.Zero(t, code)
+	assert.Equal(t, "docker exec app echo hello world\n", out.String())
+	assert.Empty(t, errOut.String())
+	out.Reset()
+
+	stdout, stderr, code = r.Shell("echo out; echo err >&2; exit 3")
+	t.Logf("stdout = %q", stdout)
+	t.Logf("stderr = %q", stderr)
+	t.Logf("code = %d", code)
+	t.Logf("out = %q", out)
+	assert.Equal(t, "out\n", stdout)
+	assert.Equal(t, "err\n", stderr)
+	assert.Equal(t, 3, code)
+	assert.Equal(t, "docker exec app /bin/sh -c \"echo out; echo err >&2; exit 3\"\n", out.String())
+
+	stdout, _, code = r.With(logrun.WithStdin(strings.NewReader("from stdin\n"))).Run("cat")
+	t.Logf("stdout = %q", stdout)
+	assert.Zero(t, code)
+	assert.Equal(t, "from stdin\n", stdout)
+
+	path := filepath.Join(t.TempDir(), "file")
+	require.NoError(t, ioutil.WriteFile(path, nil, 0644))
+	exists, err := r.FileExists(path)
+	t.Logf("exists = %t", exists)
+	t.Logf("err = %v", err)
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	assert.Equal(t, "app", r.Host().String())
+	assert.Equal(t, "app", r.Config().Host)
+}
+
+func TestDockerLogRun_EnvDirAndUser(t *testing.T) {
+	d := newTestDockerDaemon(t)
+	dir := t.TempDir()
+	log, out, _ := newLogger()
+	r, err := logrun.NewDockerLogRun("app", logrun.DockerConfig{
+		LogFunc: log.Println,
+		Host:    d.Host(),
+		RunAs:   "www-data",
+		Env:     []string{"GREETING=hello", "API_TOKEN=s3cret"},
+		Dir:     dir,
+	})
+	require.NoError(t, err)
+
+	stdout, _, code := r.With(logrun.WithEnv("NAME=world")).Shell("echo $GREETING $NAME $API_TOKEN; pwd")
+	t.Logf("stdout = %q", stdout)
+	t.Logf("out = %q", out)
+	require.Zero(t, code)
+	assert.Equal(t, "hello world s3cret\n"+dir+"\n", stdout)
+	assert.Equal(t,
+		"docker exec -u 'www-data' -w '"+dir+"' -e 'GREETING=hello' -e 'API_TOKEN=********' -e 'NAME=world' app "+
+			"/bin/sh -c 'echo $GREETING $NAME $API_TOKEN; pwd'\n",
+		out.String())
+	assert.Equal(t, "www-data", d.lastExec().config.User)
+	assert.Equal(t, "www-data@app", r.Host().String())
+
+	_, _, code = r.With(logrun.WithRunAs("root")).Run("true")
+	assert.Zero(t, code)
+	assert.Equal(t, "root", d.lastExec().config.User)
+}
+
+func TestDockerLogRun_Rsync(t *testing.T) {
+	d := newTestDockerDaemon(t)
+	src := t.TempDir()
+	dest := t.TempDir()
+	require.NoError(t, os.Mkdir(filepath.Join(src, "conf.d"), 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(src, "conf.d", "app.conf"), []byte("a = 1\n"), 0640))
+
+	log, out, _ := newLogger()
+	r, err := logrun.NewDockerLogRun("app", logrun.DockerConfig{
+		LogFunc: log.Println,
+		Host:    d.Host(),
+	})
+	require.NoError(t, err)
+	err = r.Rsync(src+"/", dest)
+	t.Logf("err = %v", err)
+	t.Logf("out = %q", out)
+	require.NoError(t, err)
+	assert.Equal(t, "docker cp '"+src+"/' app:'"+dest+"'\n", out.String())
+	content, err := ioutil.ReadFile(filepath.Join(dest, "conf.d", "app.conf"))
+	require.NoError(t, err)
+	assert.Equal(t, "a = 1\n", string(content))
+	info, err := os.Stat(filepath.Join(dest, "conf.d", "app.conf"))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0640), info.Mode().Perm())
+
+	err = r.Rsync(src, filepath.Join(dest, "xyzzy"))
+	t.Logf("err = %v", err)
+	assert.Error(t, err)
+
+	report, err := r.Check(func(r *logrun.LogRun) error {
+		return r.Rsync(src, dest)
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 1, report.Count(logrun.ChangeRun))
+	_, err = os.Stat(filepath.Join(dest, filepath.Base(src)))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestDockerLogRun_Errors(t *testing.T) {
+	_, err := logrun.NewDockerLogRun("", logrun.DockerConfig{})
+	t.Logf("err = %v", err)
+	assert.Error(t, err)
+	_, err = logrun.NewDockerLogRun("app", logrun.DockerConfig{Host: "ssh://host"})
+	t.Logf("err = %v", err)
+	assert.Error(t, err)
+
+	d := newTestDockerDaemon(t)
+	r, err := logrun.NewDockerLogRun("xyzzy", logrun.DockerConfig{Host: d.Host()})
+	require.NoError(t, err)
+	_, err = r.RunResult("true")
+	t.Logf("err = %v", err)
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, logrun.ErrNotFound))
+	assert.Contains(t, err.Error(), "No such container: xyzzy")
+
+	r, err = logrun.NewDockerLogRun("app", logrun.DockerConfig{
+		Host:    d.Host(),
+		Timeout: 200 * time.Millisecond,
+	})
+	require.NoError(t, err)
+	start := time.Now()
+	_, err = r.ShellResult("sleep 5")
+	t.Logf("err = %v", err)
+	t.Logf("elapsed = %s", time.Since(start))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "timed out")
+	assert.True(t, time.Since(start) < 4*time.Second)
+}
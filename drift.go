@@ -0,0 +1,113 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// DriftReport tells which hosts of a Pool produced a different output
+// for the same command than the majority of the hosts, e.g., to find
+// the web servers with a different checksum of nginx.conf.
+type DriftReport struct {
+	// Majority is the standard output produced by most hosts. Ties
+	// are broken in favor of the output of the host whose name
+	// sorts first.
+	Majority string
+
+	// MajorityHosts are the hosts, in sorted order, that produced
+	// Majority.
+	MajorityHosts []string
+
+	// Diffs are the unified diffs of Majority and the output of
+	// each host that differs from it keyed by hostname.
+	Diffs map[string]string
+
+	// Failed are the hosts, in sorted order, where the command
+	// could not be run or exited with a non-zero exit code. Their
+	// output is not compared.
+	Failed []string
+}
+
+// Drifted returns the hostnames, in sorted order, of the hosts whose
+// output differs from the majority.
+func (d DriftReport) Drifted() []string {
+	var hosts []string
+	for host := range d.Diffs {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+
+	return hosts
+}
+
+// String returns the hosts that differ from the majority followed by
+// their diffs.
+func (d DriftReport) String() string {
+	var b strings.Builder
+	drifted := d.Drifted()
+	fmt.Fprintf(&b, "%d of %d hosts differ from the majority",
+		len(drifted), len(drifted)+len(d.MajorityHosts))
+	if len(d.Failed) > 0 {
+		fmt.Fprintf(&b, ", %d failed: %s", len(d.Failed), strings.Join(d.Failed, ", "))
+	}
+	b.WriteString("\n")
+	for _, host := range drifted {
+		b.WriteString(d.Diffs[host])
+	}
+
+	return b.String()
+}
+
+// Drift compares the standard output of the successful results and
+// reports the hosts whose output differs from the majority.
+func (pr PoolResults) Drift() DriftReport {
+	d := DriftReport{Diffs: make(map[string]string)}
+	groups := make(map[string][]string)
+	var outputs []string
+	for _, host := range pr.Hosts() {
+		res := pr[host]
+		if !res.Success() {
+			d.Failed = append(d.Failed, host)
+			continue
+		}
+		if _, ok := groups[res.Stdout]; !ok {
+			outputs = append(outputs, res.Stdout)
+		}
+		groups[res.Stdout] = append(groups[res.Stdout], host)
+	}
+	if len(outputs) == 0 {
+		return d
+	}
+	// The outputs are in the order of the first host producing them,
+	// so the stable sort breaks ties by hostname.
+	sort.SliceStable(outputs, func(i, j int) bool {
+		return len(groups[outputs[i]]) > len(groups[outputs[j]])
+	})
+	d.Majority = outputs[0]
+	d.MajorityHosts = groups[d.Majority]
+	for _, output := range outputs[1:] {
+		for _, host := range groups[output] {
+			d.Diffs[host] = UnifiedDiff("majority", host, d.Majority, output)
+		}
+	}
+
+	return d
+}
+
+// CompareRun runs cmd with args on every host of the Pool like Run()
+// and reports the hosts whose output differs from the majority.
+func (p *Pool) CompareRun(cmd string, args ...string) DriftReport {
+	return p.Run(cmd, args...).Drift()
+}
+
+// CompareShell runs cmd in a shell on every host of the Pool like
+// Shell() and reports the hosts whose output differs from the
+// majority.
+func (p *Pool) CompareShell(cmd string) DriftReport {
+	return p.Shell(cmd).Drift()
+}
@@ -0,0 +1,63 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun_test
+
+import (
+	"errors"
+	"net"
+	"strconv"
+	"testing"
+
+	"github.com/apatters/go-logrun"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPoolResults_Drift(t *testing.T) {
+	results := logrun.PoolResults{
+		"web1": {Stdout: "abc  nginx.conf\n"},
+		"web2": {Stdout: "abc  nginx.conf\n"},
+		"web3": {Stdout: "def  nginx.conf\n"},
+		"web4": {Stdout: "abc  nginx.conf\n"},
+		"web5": {ExitCode: 1},
+		"web6": {Err: errors.New("connection refused")},
+	}
+	d := results.Drift()
+	t.Logf("d =\n%s", d)
+	assert.Equal(t, "abc  nginx.conf\n", d.Majority)
+	assert.Equal(t, []string{"web1", "web2", "web4"}, d.MajorityHosts)
+	assert.Equal(t, []string{"web3"}, d.Drifted())
+	assert.Equal(t, "--- majority\n+++ web3\n@@ -1 +1 @@\n-abc  nginx.conf\n+def  nginx.conf\n", d.Diffs["web3"])
+	assert.Equal(t, []string{"web5", "web6"}, d.Failed)
+	assert.Contains(t, d.String(), "1 of 4 hosts differ from the majority, 2 failed: web5, web6\n")
+
+	// Ties are broken by hostname.
+	d = logrun.PoolResults{"b": {Stdout: "1"}, "a": {Stdout: "2"}}.Drift()
+	assert.Equal(t, "2", d.Majority)
+	assert.Equal(t, []string{"b"}, d.Drifted())
+
+	d = logrun.PoolResults{}.Drift()
+	assert.Empty(t, d.Majority)
+	assert.Empty(t, d.Drifted())
+}
+
+func TestPool_CompareShell(t *testing.T) {
+	var configs []logrun.RemoteConfig
+	var hosts []string
+	for i := 0; i < 3; i++ {
+		s := newTestSSHServer(t, nil)
+		defer s.Close()
+		creds := s.Credentials()
+		configs = append(configs, logrun.RemoteConfig{Credentials: creds})
+		hosts = append(hosts, net.JoinHostPort(creds.Hostname, strconv.Itoa(creds.Port)))
+	}
+	p, err := logrun.NewRemotePool(configs, logrun.PoolConfig{})
+	require.NoError(t, err)
+
+	d := p.CompareShell("echo a")
+	t.Logf("d =\n%s", d)
+	assert.Empty(t, d.Drifted())
+	assert.ElementsMatch(t, hosts, d.MajorityHosts)
+}
@@ -0,0 +1,108 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// OutputEncoding specifies how the captured stdout and stderr of a
+// command are converted into the strings returned by Run() and
+// Shell().
+type OutputEncoding int
+
+const (
+	// EncodingRaw returns the output exactly as produced by the
+	// command. No validation or conversion is performed, so the
+	// returned strings may contain invalid UTF-8. This is the
+	// default and is lossless.
+	EncodingRaw OutputEncoding = iota
+
+	// EncodingUTF8 treats the output as UTF-8. Invalid UTF-8
+	// sequences are replaced with the Unicode replacement
+	// character (U+FFFD).
+	EncodingUTF8
+
+	// EncodingLatin1 treats the output as ISO-8859-1 (latin-1)
+	// and converts it to UTF-8.
+	EncodingLatin1
+
+	// EncodingWindows1252 treats the output as Windows code page
+	// 1252 and converts it to UTF-8.
+	EncodingWindows1252
+)
+
+// String returns the name of the encoding.
+func (e OutputEncoding) String() string {
+	switch e {
+	case EncodingRaw:
+		return "raw"
+	case EncodingUTF8:
+		return "utf-8"
+	case EncodingLatin1:
+		return "iso-8859-1"
+	case EncodingWindows1252:
+		return "windows-1252"
+	}
+
+	return "unknown"
+}
+
+// windows1252 maps the bytes 0x80-0x9f of Windows code page 1252 to
+// their Unicode code points. Undefined bytes map to the C1 control
+// code of the same value as is done by most decoders.
+var windows1252 = [32]rune{
+	0x20ac, 0x0081, 0x201a, 0x0192, 0x201e, 0x2026, 0x2020, 0x2021,
+	0x02c6, 0x2030, 0x0160, 0x2039, 0x0152, 0x008d, 0x017d, 0x008f,
+	0x0090, 0x2018, 0x2019, 0x201c, 0x201d, 0x2022, 0x2013, 0x2014,
+	0x02dc, 0x2122, 0x0161, 0x203a, 0x0153, 0x009d, 0x017e, 0x0178,
+}
+
+// Decode converts s from the encoding to a string. See the encoding
+// constants for details.
+func (e OutputEncoding) Decode(s string) string {
+	switch e {
+	case EncodingUTF8:
+		if utf8.ValidString(s) {
+			return s
+		}
+		return strings.ToValidUTF8(s, string(utf8.RuneError))
+	case EncodingLatin1, EncodingWindows1252:
+		var b strings.Builder
+		b.Grow(len(s))
+		for i := 0; i < len(s); i++ {
+			c := s[i]
+			switch {
+			case c < utf8.RuneSelf:
+				b.WriteByte(c)
+			case e == EncodingWindows1252 && c < 0xa0:
+				b.WriteRune(windows1252[c-0x80])
+			default:
+				b.WriteRune(rune(c))
+			}
+		}
+		return b.String()
+	}
+
+	return s
+}
+
+// NormalizeCRLF converts Windows-style CRLF line endings in s to
+// LF line endings.
+func NormalizeCRLF(s string) string {
+	return strings.Replace(s, "\r\n", "\n", -1)
+}
+
+// decodeOutput converts captured output using the encoding and line
+// ending settings of the LogRun.
+func (r *LogRun) decodeOutput(s string) string {
+	s = r.outputEncoding.Decode(s)
+	if r.normalizeCRLF {
+		s = NormalizeCRLF(s)
+	}
+
+	return s
+}
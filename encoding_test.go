@@ -0,0 +1,78 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun_test
+
+import (
+	"testing"
+	"unicode/utf8"
+
+	"github.com/apatters/go-logrun"
+	"github.com/stretchr/testify/assert"
+)
+
+type encodingTestEntry struct {
+	Description string
+	Encoding    logrun.OutputEncoding
+	Input       string
+	Expected    string
+}
+
+var encodingTestTable = []encodingTestEntry{
+	{"Raw ASCII", logrun.EncodingRaw, "hello", "hello"},
+	{"Raw latin-1", logrun.EncodingRaw, "caf\xe9", "caf\xe9"},
+	{"UTF-8 valid", logrun.EncodingUTF8, "café", "café"},
+	{"UTF-8 invalid", logrun.EncodingUTF8, "caf\xe9", "caf�"},
+	{"Latin-1", logrun.EncodingLatin1, "caf\xe9", "café"},
+	{"Latin-1 C1 control", logrun.EncodingLatin1, "\x80", "\u0080"},
+	{"Windows-1252", logrun.EncodingWindows1252, "\x80 caf\xe9 \x93q\x94", "€ café “q”"},
+}
+
+func TestOutputEncoding_Decode(t *testing.T) {
+	for _, e := range encodingTestTable {
+		t.Log(e.Description)
+		t.Logf("Encoding = %s", e.Encoding)
+		t.Logf("Input = %q", e.Input)
+		result := e.Encoding.Decode(e.Input)
+		t.Logf("result = %q", result)
+		assert.Equal(t, e.Expected, result)
+		if e.Encoding != logrun.EncodingRaw {
+			assert.True(t, utf8.ValidString(result))
+		}
+	}
+}
+
+func TestNormalizeCRLF(t *testing.T) {
+	assert.Equal(t, "a\nb\n", logrun.NormalizeCRLF("a\r\nb\r\n"))
+	assert.Equal(t, "a\rb\n", logrun.NormalizeCRLF("a\rb\n"))
+}
+
+func TestLocalLogRun_OutputEncoding(t *testing.T) {
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{})
+	stdout, stderr, code := l.Shell(`printf 'caf\351\r\n'`)
+	t.Logf("stdout = %q", stdout)
+	t.Logf("stderr = %q", stderr)
+	t.Logf("code = %d", code)
+	assert.Equal(t, "caf\xe9\r\n", stdout)
+	assert.Empty(t, stderr)
+	assert.Zero(t, code)
+
+	l = logrun.NewLocalLogRun(logrun.LocalConfig{
+		OutputEncoding: logrun.EncodingLatin1,
+		NormalizeCRLF:  true,
+	})
+	stdout, stderr, code = l.Shell(`printf 'caf\351\r\n'`)
+	t.Logf("stdout = %q", stdout)
+	t.Logf("stderr = %q", stderr)
+	t.Logf("code = %d", code)
+	assert.Equal(t, "café\n", stdout)
+	assert.Empty(t, stderr)
+	assert.Zero(t, code)
+
+	l.SetOutputEncoding(logrun.EncodingUTF8)
+	l.SetNormalizeCRLF(false)
+	stdout, _, _ = l.Shell(`printf 'caf\351\r\n'`)
+	t.Logf("stdout = %q", stdout)
+	assert.Equal(t, "caf�\r\n", stdout)
+}
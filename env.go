@@ -0,0 +1,253 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+var (
+	// EnvCmd is the external command used to capture the
+	// environment of a host. This command has been tested on
+	// RHEL/CentOS 7 and Ubuntu 18.04.
+	EnvCmd = "/usr/bin/env"
+
+	// EnvCmdOptions are the command-line options added to EnvCmd
+	// to output NUL separated variables, so values containing
+	// newlines are captured correctly. This command and options
+	// has been tested on RHEL/CentOS 7 and Ubuntu 18.04.
+	EnvCmdOptions = []string{
+		"-0",
+	}
+
+	// SysctlCmd is the external command used to capture kernel
+	// parameters. This command has been tested on RHEL/CentOS 7
+	// and Ubuntu 18.04.
+	SysctlCmd = "/sbin/sysctl"
+
+	// SysctlCmdOptions are the command-line options added to
+	// SysctlCmd so that unknown parameters are ignored. This
+	// command and options has been tested on RHEL/CentOS 7 and
+	// Ubuntu 18.04.
+	SysctlCmdOptions = []string{
+		"-e",
+	}
+
+	// PackagesCmd is the shell command used to list the installed
+	// packages and their versions, one "name version" pair per
+	// line. This command has been tested on RHEL/CentOS 7 and
+	// Ubuntu 18.04.
+	PackagesCmd = `rpm -qa --queryformat '%{NAME} %{VERSION}-%{RELEASE}\n' 2>/dev/null || dpkg-query --show --showformat '${Package} ${Version}\n'`
+
+	// DefaultSysctls are the kernel parameters captured by
+	// CaptureEnv() if EnvOptions.Sysctls is nil.
+	DefaultSysctls = []string{
+		"kernel.hostname",
+		"fs.file-max",
+		"net.core.somaxconn",
+		"net.ipv4.ip_forward",
+		"vm.swappiness",
+	}
+)
+
+// EnvOptions selects what CaptureEnv() records.
+type EnvOptions struct {
+	// Sysctls are the kernel parameters to capture. If nil,
+	// DefaultSysctls are captured. Use an empty slice to capture
+	// none.
+	Sysctls []string
+
+	// Packages enables capturing the list of installed packages.
+	Packages bool
+}
+
+// EnvSnapshot is the environment of a host captured by CaptureEnv().
+type EnvSnapshot struct {
+	// Options are the options the snapshot was captured with.
+	Options EnvOptions
+
+	// Env maps the names of environment variables to their
+	// values.
+	Env map[string]string
+
+	// Sysctls maps the names of kernel parameters to their
+	// values.
+	Sysctls map[string]string
+
+	// Packages maps the names of installed packages to their
+	// versions. It is nil unless Options.Packages is true.
+	Packages map[string]string
+}
+
+// EnvChange is a single difference between two EnvSnapshots.
+type EnvChange struct {
+	// Category is "env", "sysctl", or "package".
+	Category string
+
+	// Key is the name of the variable, parameter, or package.
+	Key string
+
+	// Before and After are the values in each snapshot. Before
+	// is empty if the key was added and After is empty if the
+	// key was removed.
+	Before string
+	After  string
+}
+
+// String returns a one line description of the change.
+func (c EnvChange) String() string {
+	switch {
+	case c.Before == "":
+		return fmt.Sprintf("+ %s %s=%q", c.Category, c.Key, c.After)
+	case c.After == "":
+		return fmt.Sprintf("- %s %s=%q", c.Category, c.Key, c.Before)
+	}
+
+	return fmt.Sprintf("~ %s %s=%q -> %q", c.Category, c.Key, c.Before, c.After)
+}
+
+// EnvDiff is the list of differences between two EnvSnapshots,
+// sorted by category and key.
+type EnvDiff []EnvChange
+
+// String returns the differences with one change per line.
+func (d EnvDiff) String() string {
+	var lines []string
+	for _, c := range d {
+		lines = append(lines, c.String())
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// Diff returns the differences between s and after.
+func (s *EnvSnapshot) Diff(after *EnvSnapshot) EnvDiff {
+	var diff EnvDiff
+	diff = append(diff, diffMaps("env", s.Env, after.Env)...)
+	diff = append(diff, diffMaps("package", s.Packages, after.Packages)...)
+	diff = append(diff, diffMaps("sysctl", s.Sysctls, after.Sysctls)...)
+
+	return diff
+}
+
+func diffMaps(category string, before map[string]string, after map[string]string) []EnvChange {
+	keys := make(map[string]bool)
+	for k := range before {
+		keys[k] = true
+	}
+	for k := range after {
+		keys[k] = true
+	}
+	var sorted []string
+	for k := range keys {
+		sorted = append(sorted, k)
+	}
+	sort.Strings(sorted)
+	var changes []EnvChange
+	for _, k := range sorted {
+		b, inBefore := before[k]
+		a, inAfter := after[k]
+		if inBefore && inAfter && a == b {
+			continue
+		}
+		changes = append(changes, EnvChange{Category: category, Key: k, Before: b, After: a})
+	}
+
+	return changes
+}
+
+// CaptureEnv records the environment variables, the kernel
+// parameters, and optionally the installed packages of the host. Only
+// logging is performed if Dryrun is true, in which case an empty
+// snapshot is returned.
+func (r *LogRun) CaptureEnv(opts EnvOptions) (_ *EnvSnapshot, err error) {
+	r = r.beginHelper()
+	defer r.addErrorContext(&err, r.commandSeq())
+	if opts.Sysctls == nil {
+		opts.Sysctls = DefaultSysctls
+	}
+	s := &EnvSnapshot{
+		Options: opts,
+		Env:     make(map[string]string),
+		Sysctls: make(map[string]string),
+	}
+
+	r.logRun(EnvCmd, EnvCmdOptions...)
+	if !r.Dryrun {
+		stdout, stderr, code := r.run(EnvCmd, EnvCmdOptions...)
+		if code != 0 {
+			return nil, fmt.Errorf("could not capture environment: %s", strings.TrimSpace(stderr))
+		}
+		for _, v := range strings.Split(stdout, "\x00") {
+			if i := strings.Index(v, "="); i > 0 {
+				s.Env[v[:i]] = v[i+1:]
+			}
+		}
+	}
+
+	if len(opts.Sysctls) > 0 {
+		cmdArgs := append(append([]string{}, SysctlCmdOptions...), opts.Sysctls...)
+		r.logRun(SysctlCmd, cmdArgs...)
+		if !r.Dryrun {
+			stdout, stderr, code := r.run(SysctlCmd, cmdArgs...)
+			if code != 0 {
+				return nil, fmt.Errorf("could not capture kernel parameters: %s", strings.TrimSpace(stderr))
+			}
+			for _, line := range strings.Split(stdout, "\n") {
+				if i := strings.Index(line, " = "); i > 0 {
+					s.Sysctls[line[:i]] = strings.TrimSpace(line[i+3:])
+				}
+			}
+		}
+	}
+
+	if opts.Packages {
+		var err error
+		if s.Packages, err = r.installedPackages(); err != nil {
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+// installedPackages returns the versions of the installed packages
+// keyed by name. Only logging is performed if Dryrun is true, in
+// which case an empty map is returned.
+func (r *LogRun) installedPackages() (map[string]string, error) {
+	packages := make(map[string]string)
+	r.logShell(PackagesCmd)
+	if r.Dryrun {
+		return packages, nil
+	}
+	stdout, stderr, code := r.shell(PackagesCmd)
+	if code != 0 {
+		return nil, fmt.Errorf("could not list packages: %s", strings.TrimSpace(stderr))
+	}
+	for _, line := range strings.Split(stdout, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 {
+			packages[fields[0]] = fields[1]
+		}
+	}
+
+	return packages, nil
+}
+
+// DiffEnv captures the environment of the host using the options
+// before was captured with and returns the differences from before,
+// e.g., to verify that a run changed only what it was supposed to.
+func (r *LogRun) DiffEnv(before *EnvSnapshot) (_ EnvDiff, err error) {
+	r = r.beginHelper()
+	defer r.addErrorContext(&err, r.commandSeq())
+	after, err := r.CaptureEnv(before.Options)
+	if err != nil {
+		return nil, err
+	}
+
+	return before.Diff(after), nil
+}
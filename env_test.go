@@ -0,0 +1,80 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/apatters/go-logrun"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnvSnapshot_Diff(t *testing.T) {
+	before := &logrun.EnvSnapshot{
+		Env:      map[string]string{"PATH": "/bin", "OLD": "1", "SAME": "x"},
+		Sysctls:  map[string]string{"vm.swappiness": "60"},
+		Packages: map[string]string{"nginx": "1.14"},
+	}
+	after := &logrun.EnvSnapshot{
+		Env:      map[string]string{"PATH": "/usr/bin:/bin", "NEW": "2", "SAME": "x"},
+		Sysctls:  map[string]string{"vm.swappiness": "10"},
+		Packages: map[string]string{"nginx": "1.14", "redis": "5.0"},
+	}
+	diff := before.Diff(after)
+	t.Logf("diff =\n%s", diff)
+	assert.Equal(t, logrun.EnvDiff{
+		{Category: "env", Key: "NEW", After: "2"},
+		{Category: "env", Key: "OLD", Before: "1"},
+		{Category: "env", Key: "PATH", Before: "/bin", After: "/usr/bin:/bin"},
+		{Category: "package", Key: "redis", After: "5.0"},
+		{Category: "sysctl", Key: "vm.swappiness", Before: "60", After: "10"},
+	}, diff)
+	assert.Equal(t, `+ env NEW="2"
+- env OLD="1"
+~ env PATH="/bin" -> "/usr/bin:/bin"
++ package redis="5.0"
+~ sysctl vm.swappiness="60" -> "10"`, diff.String())
+	assert.Empty(t, before.Diff(before))
+}
+
+func TestLocalLogRun_CaptureEnv(t *testing.T) {
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{
+		Env: []string{"PATH=" + os.Getenv("PATH"), "MULTI=a\nb"},
+	})
+	before, err := l.CaptureEnv(logrun.EnvOptions{Sysctls: []string{}})
+	require.NoError(t, err)
+	t.Logf("before = %+v", before)
+	assert.Equal(t, "a\nb", before.Env["MULTI"])
+	assert.Empty(t, before.Sysctls)
+	assert.Nil(t, before.Packages)
+
+	l = logrun.NewLocalLogRun(logrun.LocalConfig{
+		Env: []string{"PATH=" + os.Getenv("PATH"), "ADDED=1"},
+	})
+	diff, err := l.DiffEnv(before)
+	require.NoError(t, err)
+	t.Logf("diff =\n%s", diff)
+	assert.Equal(t, logrun.EnvDiff{
+		{Category: "env", Key: "ADDED", After: "1"},
+		{Category: "env", Key: "MULTI", Before: "a\nb"},
+	}, diff)
+}
+
+func TestLocalLogRun_CaptureEnvDryrun(t *testing.T) {
+	log, out, _ := newLogger()
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{
+		LogFunc: log.Println,
+		Dryrun:  true,
+	})
+	s, err := l.CaptureEnv(logrun.EnvOptions{Packages: true})
+	t.Logf("out = %q", out)
+	require.NoError(t, err)
+	assert.Empty(t, s.Env)
+	assert.Contains(t, out.String(), "/usr/bin/env -0\n")
+	assert.Contains(t, out.String(), "/sbin/sysctl -e kernel.hostname")
+	assert.Contains(t, out.String(), "rpm -qa")
+}
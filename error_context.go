@@ -0,0 +1,171 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun
+
+import (
+	"errors"
+	"strings"
+	"sync"
+)
+
+// ErrorContext describes where a helper of a LogRun failed.
+type ErrorContext struct {
+	// Host identifies the host as returned by Host().
+	Host string
+
+	// Command is the last command run by the helper before it
+	// failed, formatted as it is logged. It is empty if the helper
+	// did not run a command, e.g., because it failed to read a
+	// local file or used SFTP.
+	Command string
+
+	// Step is the current section as returned by Section(), e.g.,
+	// the name of the task being run.
+	Step string
+}
+
+// ContextError is an error returned by a helper of a LogRun with
+// error context enabled. It wraps the error of the helper, so
+// errors.Is() and errors.As() work as before, and adds where it
+// occurred.
+type ContextError struct {
+	// Err is the error returned by the helper.
+	Err error
+
+	ctx ErrorContext
+}
+
+// Error returns the message of Err prefixed by the host and step,
+// e.g., "web1: deploy/database: could not read /etc/db.conf: no such
+// file or directory".
+func (e *ContextError) Error() string {
+	parts := []string{e.ctx.Host}
+	if e.ctx.Step != "" {
+		parts = append(parts, e.ctx.Step)
+	}
+
+	return strings.Join(append(parts, e.Err.Error()), ": ")
+}
+
+// Unwrap returns Err.
+func (e *ContextError) Unwrap() error {
+	return e.Err
+}
+
+// Context returns where the error occurred.
+func (e *ContextError) Context() ErrorContext {
+	return e.ctx
+}
+
+// ErrorContextOf returns the context of the first ContextError in the
+// chain of err. False is returned if err does not have one.
+func ErrorContextOf(err error) (ErrorContext, bool) {
+	var ce *ContextError
+	if errors.As(err, &ce) {
+		return ce.Context(), true
+	}
+
+	return ErrorContext{}, false
+}
+
+// errorContextState holds whether error context is enabled. It is
+// shared by copies of a LogRun. The last command run is tracked per
+// call of a helper, so helpers run concurrently, e.g., by a TaskList,
+// report their own commands.
+type errorContextState struct {
+	mu      sync.Mutex
+	enabled bool
+}
+
+// SetErrorContext enables/disables wrapping the errors returned by the
+// helpers of the LogRun, e.g., GetFileString() or UnitStatus(), in a
+// ContextError that tells the host, the command that failed, and the
+// step, so callers do not have to add them using fmt.Errorf(). An
+// error keeps the context of the helper that returned it first, e.g.,
+// Verify() returns the context of the failing UnitStatus(). The setting
+// is shared by copies of the LogRun made by With(). The default is
+// disabled.
+func (r *LogRun) SetErrorContext(enabled bool) {
+	if r.errCtx == nil {
+		r.errCtx = new(errorContextState)
+	}
+	s := r.errCtx
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.enabled = enabled
+}
+
+// errorContextEnabled returns true if error context is enabled.
+func (r *LogRun) errorContextEnabled() bool {
+	s := r.errCtx
+	if s == nil {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.enabled
+}
+
+// recordCommand remembers the command described by spec as the last
+// command run by the current helper call, if any, if error context is
+// enabled.
+func (r *LogRun) recordCommand(spec execSpec) {
+	h := r.helper
+	if h == nil || !r.errorContextEnabled() {
+		return
+	}
+	msg := r.format(spec)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.seq++
+	h.command = msg
+}
+
+// commandSeq returns the number of commands recorded so far by the
+// current helper call, so addErrorContext() can tell whether a helper
+// called by it ran a command.
+func (r *LogRun) commandSeq() uint64 {
+	h := r.helper
+	if h == nil {
+		return 0
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return h.seq
+}
+
+// addErrorContext wraps *err, if not nil, in a ContextError if error
+// context is enabled. seq is the result of commandSeq() when the
+// helper was called. Helpers defer it when they are called, e.g.,
+//
+//	r = r.beginHelper()
+//	defer r.addErrorContext(&err, r.commandSeq())
+func (r *LogRun) addErrorContext(err *error, seq uint64) {
+	if *err == nil || !r.errorContextEnabled() {
+		return
+	}
+	var ce *ContextError
+	if errors.As(*err, &ce) {
+		return
+	}
+	var command string
+	if h := r.helper; h != nil {
+		h.mu.Lock()
+		if h.seq != seq {
+			command = h.command
+		}
+		h.mu.Unlock()
+	}
+	*err = &ContextError{
+		Err: *err,
+		ctx: ErrorContext{
+			Host:    r.Host().String(),
+			Command: command,
+			Step:    r.Section(),
+		},
+	}
+}
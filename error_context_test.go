@@ -0,0 +1,105 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun_test
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/apatters/go-logrun"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogRun_ErrorContextDisabled(t *testing.T) {
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{})
+	_, err := l.GetFileString("/xyzzy")
+	require.Error(t, err)
+	_, ok := logrun.ErrorContextOf(err)
+	assert.False(t, ok)
+	assert.EqualError(t, err, "could not read /xyzzy: no such file or directory")
+}
+
+func TestLocalLogRun_ErrorContext(t *testing.T) {
+	log, _, _ := newLogger()
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{LogFunc: log.Println})
+	l.SetErrorContext(true)
+	l.BeginSection("deploy")
+
+	// ReadFile() calls GetFileString(), which adds the context.
+	_, err := l.With().ReadFile("/xyzzy")
+	t.Logf("err = %v", err)
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, logrun.ErrNotFound))
+	ctx, ok := logrun.ErrorContextOf(err)
+	require.True(t, ok)
+	t.Logf("ctx = %+v", ctx)
+	assert.Equal(t, logrun.ErrorContext{Host: l.Host().String(), Step: "deploy"}, ctx)
+	assert.EqualError(t, err, l.Host().String()+": deploy: could not read /xyzzy: no such file or directory")
+
+	_, err = l.DirExists("/")
+	assert.NoError(t, err)
+}
+
+func TestRemoteLogRun_ErrorContext(t *testing.T) {
+	s := newTestSSHServer(t, nil)
+	defer s.Close()
+	log, _, _ := newLogger()
+	r, err := logrun.NewRemoteLogRun(logrun.RemoteConfig{
+		Credentials: s.Credentials(),
+		LogFunc:     log.Println,
+	})
+	require.NoError(t, err)
+	r.SetErrorContext(true)
+
+	_, err = r.GetFileString("/xyzzy")
+	t.Logf("err = %v", err)
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, logrun.ErrNotFound))
+	var ce *logrun.ContextError
+	require.True(t, errors.As(err, &ce))
+	t.Logf("ctx = %+v", ce.Context())
+	assert.Equal(t, r.Host().String(), ce.Context().Host)
+	assert.Contains(t, ce.Context().Command, "/xyzzy")
+	assert.Empty(t, ce.Context().Step)
+
+	// A helper that runs no command has no command context.
+	_, err = r.Checksum("/xyzzy", "crc32")
+	t.Logf("err = %v", err)
+	require.Error(t, err)
+	ctx, ok := logrun.ErrorContextOf(err)
+	require.True(t, ok)
+	assert.Empty(t, ctx.Command)
+}
+
+func TestRemoteLogRun_ErrorContextConcurrent(t *testing.T) {
+	s := newTestSSHServer(t, nil)
+	defer s.Close()
+	r, err := logrun.NewRemoteLogRun(logrun.RemoteConfig{Credentials: s.Credentials()})
+	require.NoError(t, err)
+	r.SetErrorContext(true)
+
+	// Each helper reports its own command, even when other helpers
+	// run commands on the host at the same time.
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		path := "/xyzzy/" + strconv.Itoa(i)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 5; j++ {
+				_, err := r.With().GetFileString(path)
+				ctx, ok := logrun.ErrorContextOf(err)
+				if assert.True(t, ok) {
+					assert.True(t, strings.HasSuffix(ctx.Command, " "+path), ctx.Command)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
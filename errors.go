@@ -0,0 +1,145 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Errors returned, possibly wrapped, by the file operations of
+// LogRun. Use errors.Is() to test for them.
+var (
+	// ErrNotFound is returned when a file or directory does not
+	// exist.
+	ErrNotFound = errors.New("no such file or directory")
+
+	// ErrNotRegularFile is returned by FileExists() when the path
+	// exists but is not a regular file.
+	ErrNotRegularFile = errors.New("not a regular file")
+
+	// ErrNotDirectory is returned by DirExists() when the path
+	// exists but is not a directory.
+	ErrNotDirectory = errors.New("not a directory")
+
+	// ErrGlobFailed is returned by Glob() when the pattern is
+	// invalid, matches no paths, or could not be expanded.
+	ErrGlobFailed = errors.New("glob failed")
+
+	// ErrPermissionDenied is returned when a path could not be
+	// accessed because of its permissions or those of one of its
+	// parent directories.
+	ErrPermissionDenied = errors.New("permission denied")
+
+	// ErrConnection is returned when a path could not be checked
+	// because the host could not be reached or the command
+	// checking the path could not be run.
+	ErrConnection = errors.New("connection failed")
+)
+
+// wrappedError is an error with its own message that wraps another
+// error, typically a sentinel error, so errors.Is() can be used
+// without changing the message.
+type wrappedError struct {
+	msg string
+	err error
+
+	// cause, if not nil, is also matched by errors.Is(), e.g.,
+	// ErrConnection for a glob that failed because the host could
+	// not be reached.
+	cause error
+}
+
+func (e *wrappedError) Error() string {
+	return e.msg
+}
+
+func (e *wrappedError) Unwrap() error {
+	return e.err
+}
+
+func (e *wrappedError) Is(target error) bool {
+	return e.cause != nil && errors.Is(e.cause, target)
+}
+
+// globError returns an error wrapping ErrGlobFailed reporting why the
+// expansion of pattern failed. If reason is an error, errors.Is() also
+// matches the sentinel errors it wraps.
+func globError(pattern string, reason interface{}) error {
+	cause, _ := reason.(error)
+	if reason == "no matches" {
+		cause = ErrNotFound
+	}
+
+	return &wrappedError{
+		msg:   fmt.Sprintf("glob '%s' failed: %v", pattern, reason),
+		err:   ErrGlobFailed,
+		cause: cause,
+	}
+}
+
+// commandGlobError is like globError() for a command expanding
+// pattern that exited with code and wrote stderr.
+func commandGlobError(pattern string, stderr string, code int) error {
+	return globError(pattern, commandError(stderr, code))
+}
+
+// accessError returns an error reporting that path could not be
+// accessed because of err, which is wrapped. Permission errors also
+// match ErrPermissionDenied.
+func accessError(path string, err error) error {
+	e := &wrappedError{msg: fmt.Sprintf("could not access %s: %v", path, err), err: err}
+	if errors.Is(err, os.ErrPermission) {
+		e.cause = ErrPermissionDenied
+	}
+
+	return e
+}
+
+// commandAccessError returns an error reporting that path could not
+// be accessed by a command that exited with code and wrote stderr.
+func commandAccessError(path string, stderr string, code int) error {
+	return accessError(path, commandError(stderr, code))
+}
+
+// commandError returns the error reported by a command that exited
+// with code and wrote stderr, wrapping ErrConnection if the command
+// could not be run and ErrPermissionDenied if it reported a permission
+// problem. The messages written by the command are checked first
+// since ExitErrorExecute is also the exit code most commands use to
+// report a failure.
+func commandError(stderr string, code int) error {
+	e := &wrappedError{msg: strings.TrimSpace(stderr)}
+	switch {
+	case strings.Contains(stderr, "Permission denied"),
+		strings.Contains(stderr, "Access is denied"),
+		strings.Contains(stderr, "UnauthorizedAccessException"):
+		e.err = ErrPermissionDenied
+	case strings.Contains(stderr, "No such file or directory"):
+		e.err = ErrNotFound
+	case code == ExitErrorExecute:
+		e.err = ErrConnection
+	}
+
+	return e
+}
+
+// connectionError returns err wrapped so it also matches
+// ErrConnection.
+func connectionError(err error) error {
+	if err == nil || errors.Is(err, ErrConnection) {
+		return err
+	}
+
+	return &wrappedError{msg: err.Error(), err: err, cause: ErrConnection}
+}
+
+// notFoundError returns an error wrapping ErrNotFound reporting that
+// what could not be done to path, e.g., "could not read".
+func notFoundError(what string, path string) error {
+	return fmt.Errorf("%s %s: %w", what, path, ErrNotFound)
+}
@@ -0,0 +1,103 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/apatters/go-logrun"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogRun_SentinelErrors(t *testing.T) {
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{})
+
+	_, err := l.FileExists("/etc")
+	t.Logf("err = %v", err)
+	assert.True(t, errors.Is(err, logrun.ErrNotRegularFile))
+	assert.EqualError(t, err, "/etc is not a regular file")
+
+	_, err = l.DirExists("/bin/true")
+	t.Logf("err = %v", err)
+	assert.True(t, errors.Is(err, logrun.ErrNotDirectory))
+	assert.EqualError(t, err, "/bin/true is not a directory")
+
+	_, err = l.Glob("/xyzzy*")
+	t.Logf("err = %v", err)
+	assert.True(t, errors.Is(err, logrun.ErrGlobFailed))
+	assert.EqualError(t, err, "glob '/xyzzy*' failed: no matches")
+
+	_, err = l.GetFileString("/xyzzy")
+	t.Logf("err = %v", err)
+	assert.True(t, errors.Is(err, logrun.ErrNotFound))
+	assert.EqualError(t, err, "could not read /xyzzy: no such file or directory")
+	assert.False(t, errors.Is(err, logrun.ErrNotRegularFile))
+}
+
+func TestSFTP_SentinelErrors(t *testing.T) {
+	s := newTestSSHServer(t, nil)
+	defer s.Close()
+
+	l, _ := newSFTPTestLogRun(t, s)
+	_, err := l.FileExists("/etc")
+	t.Logf("err = %v", err)
+	assert.True(t, errors.Is(err, logrun.ErrNotRegularFile))
+
+	_, err = l.DirExists("/bin/true")
+	t.Logf("err = %v", err)
+	assert.True(t, errors.Is(err, logrun.ErrNotDirectory))
+
+	_, err = l.Glob("/xyzzy*")
+	t.Logf("err = %v", err)
+	assert.True(t, errors.Is(err, logrun.ErrGlobFailed))
+
+	_, err = l.GetFileString("/xyzzy")
+	t.Logf("err = %v", err)
+	assert.True(t, errors.Is(err, logrun.ErrNotFound))
+}
+
+func TestLogRun_SentinelErrorCauses(t *testing.T) {
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{})
+	_, err := l.Glob("/xyzzy*")
+	assert.True(t, errors.Is(err, logrun.ErrNotFound))
+	assert.False(t, errors.Is(err, logrun.ErrConnection))
+
+	if os.Geteuid() != 0 {
+		dir, err := ioutil.TempDir("", "logrun")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+		require.NoError(t, os.Chmod(dir, 0))
+		defer os.Chmod(dir, 0755) // nolint: errcheck
+		_, err = l.FileExists(filepath.Join(dir, "file"))
+		t.Logf("err = %v", err)
+		assert.True(t, errors.Is(err, logrun.ErrPermissionDenied))
+	}
+
+	// The host cannot be reached.
+	r, err := logrun.NewRemoteLogRun(logrun.RemoteConfig{
+		Credentials: logrun.Credentials{
+			Username: "nobody",
+			Password: "secret",
+			Hostname: "127.0.0.1",
+			Port:     1,
+		},
+	})
+	require.NoError(t, err)
+	_, err = r.FileExists("/etc/hosts")
+	t.Logf("err = %v", err)
+	assert.True(t, errors.Is(err, logrun.ErrConnection))
+	assert.False(t, errors.Is(err, logrun.ErrNotFound))
+	_, err = r.DirExists("/etc")
+	assert.True(t, errors.Is(err, logrun.ErrConnection))
+	_, err = r.Glob("/etc/*")
+	t.Logf("err = %v", err)
+	assert.True(t, errors.Is(err, logrun.ErrGlobFailed))
+	assert.True(t, errors.Is(err, logrun.ErrConnection))
+}
@@ -0,0 +1,56 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun
+
+import (
+	"strings"
+	"time"
+)
+
+// Estimate describes the expected cost of an operation, so reviewers
+// of a DryrunPlan can judge it before approving the run. See
+// WithEstimate().
+type Estimate struct {
+	// Duration is the expected time the operation takes. Zero if
+	// not known.
+	Duration time.Duration
+
+	// Impact describes the expected effect of the operation, e.g.,
+	// "rsync ~2.3 GB" or "service restart: brief outage".
+	Impact string
+}
+
+// String returns the estimate as shown in a DryrunPlan, e.g.,
+// "~2m0s, service restart: brief outage". The empty string is
+// returned for a zero Estimate.
+func (e Estimate) String() string {
+	var parts []string
+	if e.Duration > 0 {
+		parts = append(parts, "~"+e.Duration.String())
+	}
+	if e.Impact != "" {
+		parts = append(parts, e.Impact)
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+// WithEstimate declares the expected cost of each command and helper
+// run through the LogRun returned by With(). In a DryrunPlan, the
+// estimate is added as a comment to the line of the command or, for
+// helpers that run several commands, to the line of the first one, so
+// DryrunPlan.Duration() counts it once per call, e.g.,
+//
+//	runner.With(logrun.WithEstimate(logrun.Estimate{
+//		Duration: 5 * time.Minute,
+//		Impact:   "rsync ~2.3 GB",
+//	})).Rsync("/srv/media/", "backup1:/srv/media/")
+//
+// The estimate has no effect on how commands are run.
+func WithEstimate(e Estimate) CallOption {
+	return func(o *callOptions) {
+		o.estimate = e
+	}
+}
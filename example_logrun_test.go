@@ -126,7 +126,7 @@ func ExampleLogRun_Shell() {
 	if err != nil {
 		// Report the error.
 	}
-	stdout, stderr, code = runner.Run("seq 1 3 | grep 2")
+	stdout, stderr, code = runner.Shell("seq 1 3 | grep 2")
 	fmt.Printf("Stdout = %q\n", stdout)
 	fmt.Printf("Stderr = %q\n", stderr)
 	fmt.Printf("Exit code = %d\n", code)
@@ -139,7 +139,7 @@ func ExampleLogRun_Shell() {
 	// Exit code = 0
 	//
 	// Run the "seq 1 3 | grep 2" command remotely.
-	// Debug ssh buildman@localhost seq 1 3 | grep 2
+	// Debug ssh buildman@localhost /bin/sh -c "seq 1 3 | grep 2"
 	// Stdout = "2\n"
 	// Stderr = ""
 	// Exit code = 0
@@ -223,7 +223,7 @@ func ExampleLogRun_FileExists() {
 
 	// Output:
 	// See if /bin/true exists on the local host.
-	// Debug /usr/bin/stat --dereference --format %n:%F /bin/true
+	// Debug stat /bin/true
 	// exists = true
 	//
 	// See if /bin/true exists on the remote host.
@@ -270,7 +270,7 @@ func ExampleLogRun_DirExists() {
 
 	// Output:
 	// See if /etc exists on the local host.
-	// Debug /usr/bin/stat --dereference --format %n:%F /etc
+	// Debug stat /etc
 	// exists = true
 	//
 	// See if /etc exists on the remote host.
@@ -322,7 +322,7 @@ func ExampleLogRun_Glob() {
 
 	// Output:
 	// Glob local passwd files
-	// Debug /bin/sh -c "/bin/ls -1 --directory /etc/passwd*"
+	// Debug glob /etc/passwd*
 	// /etc/passwd
 	// /etc/passwd-
 	//
@@ -359,5 +359,5 @@ func ExampleLogRun_Rsync() {
 
 	// Output:
 	// Copy the contents of directory on a remote host to local temporary directory.
-	// Debug /usr/bin/rsync --rsh ssh -q -o StrictHostKeyChecking=no -o UserKnownHostsFile=/dev/null -o GlobalKnownHostsFile=/dev/null --recursive --links --times localhost:/etc/cron.daily/ /tmp/go-logrun-XXXXX/
+	// Debug /usr/bin/rsync --rsh 'ssh -q -o StrictHostKeyChecking=no -o UserKnownHostsFile=/dev/null -o GlobalKnownHostsFile=/dev/null' --recursive --links --times localhost:/etc/cron.daily/ /tmp/go-logrun-XXXXX/
 }
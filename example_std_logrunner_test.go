@@ -119,24 +119,24 @@ func Example() {
 	// code = 0
 	//
 	// See if a file exists.
-	// Command: /usr/bin/stat --dereference --format %n:%F /bin/true
+	// Command: stat /bin/true
 	// /bin/true exists: true
-	// Command: /usr/bin/stat --dereference --format %n:%F /bin/xyzzy
+	// Command: stat /bin/xyzzy
 	// /bin/xyzzy exists: false
 	//
 	// See if a directory exists.
-	// Command: /usr/bin/stat --dereference --format %n:%F /etc
+	// Command: stat /etc
 	// /bin/etc exists: true
-	// Command: /usr/bin/stat --dereference --format %n:%F /xyzzy
+	// Command: stat /xyzzy
 	// /xyzzy exists: false
 	//
 	// List files using a shell glob pattern.
-	// Command: /bin/sh -c "/bin/ls -1 --directory /etc/passwd*"
+	// Command: glob /etc/passwd*
 	// /etc/passwd
 	// /etc/passwd-
 	//
 	// Copy the contents of a remote directory to a local temporary directory.
-	// Command: /usr/bin/rsync --rsh ssh -q -o StrictHostKeyChecking=no -o UserKnownHostsFile=/dev/null -o GlobalKnownHostsFile=/dev/null --recursive --links --times localhost:/etc/cron.daily/ /tmp/go-logrun-XXXXX/
+	// Command: /usr/bin/rsync --rsh 'ssh -q -o StrictHostKeyChecking=no -o UserKnownHostsFile=/dev/null -o GlobalKnownHostsFile=/dev/null' --recursive --links --times localhost:/etc/cron.daily/ /tmp/go-logrun-XXXXX/
 	//
 	// Log commands but do not execute them.
 	// Command: /usr/bin/seq 1 3
@@ -0,0 +1,284 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync/atomic"
+)
+
+// execSpec describes a single execution of a command by an executor.
+type execSpec struct {
+	// ctx is used to cancel the command. When ctx is done, the
+	// command (and on remote hosts its process group) is killed.
+	ctx context.Context
+
+	// cmd is the command to run. If shell is true, cmd is passed
+	// to the shell as the -c option.
+	cmd   string
+	args  []string
+	shell bool
+
+	// dir, if not empty, is the working directory of the command.
+	// Relative directories are relative to the directory the
+	// runner was configured with.
+	dir string
+
+	// env, if not empty, holds "KEY=VALUE" environment variables
+	// set in addition to those configured in the runner.
+	env []string
+
+	// stdin, stdout, and stderr, if not nil, override the
+	// corresponding files configured in the runner.
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+
+	// capture, if true, captures stdout and stderr unless stdout
+	// or stderr are set, even if the runner is configured with
+	// Stdout or Stderr writers.
+	capture bool
+
+	// onStart, if not nil, is called with the PID of the command
+	// once it has started.
+	onStart func(pid int)
+
+	// pidFile, if not empty, is the path of a file on the host
+	// running the command that the PID of the command is written
+	// to when it starts.
+	pidFile string
+
+	// runAs, if not empty, is the user the command is run as.
+	runAs string
+
+	// lines, if not nil, records the lines of output of the
+	// command along with the time they were received.
+	lines *outputLines
+
+	// planInput, if not empty, is a command whose output is piped
+	// to the command in the script exported from a DryrunPlan,
+	// e.g., printf with the content written by PutFileString().
+	// planOutput, if not empty, is the file the output of the
+	// command is written to in the script, e.g., by Download().
+	planInput  string
+	planOutput string
+
+	// shutdownCtx is true if ctx is only canceled by Shutdown().
+	// Remote runners then run the command as usual and, when ctx
+	// is done, stop waiting for it rather than killing it, which
+	// requires running it through a shell that reports its PID.
+	// Local runners keep the command in the process group of the
+	// caller, so it can still read the terminal, and only kill the
+	// command itself.
+	shutdownCtx bool
+}
+
+// executor is implemented by the runners created by NewLocalLogRun
+// and NewRemoteLogRun. It extends Runner with the ability to cancel
+// commands and to run them in a working directory.
+type executor interface {
+	Runner
+	execute(spec *execSpec) (string, string, int, error)
+	format(spec *execSpec) string
+}
+
+// executePlain runs the command described by spec using the Runner
+// interface only. An error is returned if spec uses features that
+// need an executor.
+func executePlain(runner Runner, spec *execSpec) (string, string, int, error) {
+	if spec.stdin != nil || spec.stdout != nil || spec.stderr != nil {
+		return "", "", 0, fmt.Errorf("runner %T does not support per-command I/O", runner)
+	}
+	if spec.dir != "" {
+		return "", "", 0, fmt.Errorf("runner %T does not support working directories", runner)
+	}
+	if len(spec.env) > 0 {
+		return "", "", 0, fmt.Errorf("runner %T does not support per-command environments", runner)
+	}
+	if spec.onStart != nil || spec.pidFile != "" {
+		return "", "", 0, fmt.Errorf("runner %T does not support process tracking", runner)
+	}
+	if spec.ctx != nil && spec.ctx.Done() != nil {
+		return "", "", 0, fmt.Errorf("runner %T does not support cancellation", runner)
+	}
+	if spec.runAs != "" {
+		return "", "", 0, fmt.Errorf("runner %T does not support running commands as another user", runner)
+	}
+	if spec.shell {
+		return runner.Shell(spec.cmd)
+	}
+
+	return runner.Run(spec.cmd, spec.args...)
+}
+
+// format returns a string representation of the command described by
+// spec suitable for logging. It includes the effective working
+// directory, if any.
+func (r *LogRun) format(spec execSpec) string {
+	if spec.dir == "" {
+		spec.dir = r.execDir()
+	}
+	if spec.runAs == "" {
+		spec.runAs = r.call.runAs
+	}
+	if spec.env == nil {
+		spec.env = r.call.env
+	}
+	if e, ok := r.Runner.(executor); ok {
+		return e.format(&spec) + r.redirections()
+	}
+	if spec.shell {
+		return r.Runner.FormatShell(spec.cmd) + r.redirections()
+	}
+
+	return r.Runner.FormatRun(spec.cmd, spec.args...) + r.redirections()
+}
+
+// appendEnv returns the variables of env followed by those of extra
+// without modifying env.
+func appendEnv(env []string, extra []string) []string {
+	if len(extra) == 0 {
+		return env
+	}
+
+	return append(append([]string{}, env...), extra...)
+}
+
+// formatRun returns the string logged for running cmd with args.
+func (r *LogRun) formatRun(cmd string, args ...string) string {
+	return r.format(execSpec{cmd: cmd, args: args})
+}
+
+// getClock returns the Clock of the LogRun, falling back to RealClock
+// if one has not been set.
+func (r *LogRun) getClock() Clock {
+	if r.clock == nil {
+		return RealClock{}
+	}
+
+	return r.clock
+}
+
+// formatShell returns the string logged for running cmd in a shell.
+func (r *LogRun) formatShell(cmd string) string {
+	return r.format(execSpec{cmd: cmd, shell: true})
+}
+
+// execute runs the command described by spec using the Runner and
+// records it with the Recorder, if any. Commands denied by the
+// ApprovalGate are not run.
+func (r *LogRun) execute(spec execSpec) (string, string, int, error) {
+	if err := r.approve(spec); err != nil {
+		return "", "", 0, err
+	}
+	finish, err := r.beginCommand(&spec)
+	if err != nil {
+		return "", "", 0, err
+	}
+	defer finish()
+	r.recordCommand(spec)
+	if r.recorder == nil && r.resultLogFunc == nil {
+		return r.executeSpec(spec)
+	}
+	var outCount, errCount byteCounter
+	if r.resultLogFunc != nil {
+		r.applyCallOptions(&spec)
+		if spec.stdout != nil {
+			spec.stdout = &countingWriter{w: spec.stdout, n: &outCount}
+		}
+		if spec.stderr != nil {
+			spec.stderr = &countingWriter{w: spec.stderr, n: &errCount}
+		}
+	}
+	clock := r.getClock()
+	start := clock.Now()
+	stdout, stderr, code, err := r.executeSpec(spec)
+	duration := clock.Since(start)
+	if r.recorder != nil {
+		stat := CommandStat{
+			Host:     r.Host().String(),
+			Command:  r.format(spec),
+			Duration: duration,
+			ExitCode: code,
+			Failed:   err != nil || code != 0,
+			Section:  r.Section(),
+		}
+		if err != nil {
+			stat.Error = err.Error()
+		}
+		r.recorder.record(stat)
+	}
+	if r.resultLogFunc != nil {
+		outCount += byteCounter(len(stdout))
+		errCount += byteCounter(len(stderr))
+		r.logResult(r.format(spec), code, duration, int64(outCount), int64(errCount), err)
+	}
+
+	return stdout, stderr, code, err
+}
+
+// executeSpec runs the command described by spec using the Runner.
+// The timeout and spec.ctx are only honored if the Runner is an
+// executor, i.e., it was created by one of the LogRun constructors.
+func (r *LogRun) executeSpec(spec execSpec) (string, string, int, error) {
+	r.applyCallOptions(&spec)
+	closeFiles, err := r.openCallFiles(&spec)
+	if err != nil {
+		return "", "", 0, err
+	}
+	defer closeFiles()
+	if spec.dir == "" {
+		spec.dir = r.execDir()
+	}
+	e, ok := r.Runner.(executor)
+	if !ok {
+		return executePlain(r.Runner, &spec)
+	}
+	if spec.lines != nil {
+		finish := r.stampOutput(&spec)
+		stdout, stderr, code, err := r.executeWithTimeout(e, spec)
+		stdout, stderr, flushErr := finish(stdout, stderr)
+		if err == nil {
+			err = flushErr
+		}
+		return stdout, stderr, code, err
+	}
+
+	return r.executeWithTimeout(e, spec)
+}
+
+// executeWithTimeout runs the command described by spec using e,
+// killing it if it does not complete within the timeout.
+func (r *LogRun) executeWithTimeout(e executor, spec execSpec) (string, string, int, error) {
+	if spec.ctx == nil {
+		spec.ctx = context.Background()
+	}
+	var timedOut int32
+	timeout := r.commandTimeout()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		spec.ctx, cancel = context.WithCancel(spec.ctx)
+		spec.shutdownCtx = false
+		defer cancel()
+		timer := r.getClock().NewTimer(timeout)
+		defer timer.Stop()
+		go func() {
+			select {
+			case <-timer.C():
+				atomic.StoreInt32(&timedOut, 1)
+				cancel()
+			case <-spec.ctx.Done():
+			}
+		}()
+	}
+	stdout, stderr, code, err := e.execute(&spec)
+	if err != nil && atomic.LoadInt32(&timedOut) == 1 {
+		err = fmt.Errorf("command timed out after %s", timeout)
+	}
+
+	return stdout, stderr, code, err
+}
@@ -0,0 +1,346 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// GetFileString returns the contents of the file at path. It is
+// intended for small text files such as configuration files. Only
+// logging is performed if Dryrun is true, in which case the empty
+// string is returned.
+func (r *LogRun) GetFileString(path string) (_ string, err error) {
+	r = r.beginHelper()
+	defer r.addErrorContext(&err, r.commandSeq())
+	content, exists, err := r.readFile(path)
+	if err != nil {
+		return "", err
+	}
+	if !exists && !r.Dryrun {
+		return "", notFoundError("could not read", path)
+	}
+
+	return content, nil
+}
+
+// ReadFile returns the contents of the file at path like
+// GetFileString() but as bytes, so binary files can be read as well.
+// If path does not exist, the returned error matches ErrNotFound. Only
+// logging is performed if Dryrun is true, in which case nil is
+// returned.
+func (r *LogRun) ReadFile(path string) (_ []byte, err error) {
+	r = r.beginHelper()
+	defer r.addErrorContext(&err, r.commandSeq())
+	content, err := r.GetFileString(path)
+	if err != nil || r.Dryrun {
+		return nil, err
+	}
+
+	return []byte(content), nil
+}
+
+// WriteFile writes data to the file at path and sets its permission
+// bits to mode. Unlike PutFileString(), the file is always written
+// and its current contents are not read. The data is written the same
+// way, i.e., using SFTP on remote hosts or the standard input of a
+// shell command otherwise, so only the path and mode are logged. Only
+// logging is performed if Dryrun is true. In check mode, the change
+// is only recorded. The write is recorded as an operation for the
+// Summary().
+func (r *LogRun) WriteFile(path string, data []byte, mode os.FileMode) (err error) {
+	r = r.beginHelper()
+	defer r.addErrorContext(&err, r.commandSeq())
+	unlock, err := r.lockForEdit(path)
+	if err != nil {
+		return err
+	}
+	defer unlock() // nolint: errcheck
+
+	if r.checking() && !r.Dryrun {
+		exists, err := r.FileExists(path)
+		if err != nil {
+			return err
+		}
+		if exists {
+			r.check.add(Change{Action: ChangeModify, Target: path, Detail: "content"})
+		} else {
+			perm := strconv.FormatUint(uint64(mode.Perm()), 8)
+			r.check.add(Change{Action: ChangeCreate, Target: path, Detail: "mode " + perm})
+		}
+		return nil
+	}
+	r.logWriteFile(path, mode, contentInput(string(data)))
+	if r.Dryrun {
+		return nil
+	}
+	if err := r.writeFile(path, string(data), mode); err != nil {
+		return err
+	}
+	r.RecordOperation("WriteFile", path, true)
+
+	return nil
+}
+
+// PutFileString writes content to the file at path and sets its
+// permission bits to mode. The file is only written if its contents
+// or mode differ from content and mode. The returned bool is true if
+// the file was changed. The new contents are written to a temporary
+// file in the same directory which is then renamed, so readers never
+// see a partially written file. Only logging is performed if Dryrun
+// is true, in which case the file is reported as changed. In check
+// mode, the change is only recorded. The outcome is recorded as an
+// operation for the Summary().
+func (r *LogRun) PutFileString(path string, content string, mode os.FileMode) (_ bool, err error) {
+	r = r.beginHelper()
+	defer r.addErrorContext(&err, r.commandSeq())
+	changed, _, err := r.PutFileDiff(path, content, mode)
+
+	return changed, err
+}
+
+// PutFileDiff is like PutFileString() but also returns the unified
+// diff of the current and new contents of the file, so writes can be
+// reviewed. A new file is diffed against /dev/null. The diff is empty
+// if only the mode changed or, since the current contents are not
+// read, if Dryrun is true. In check mode, the diff is recorded in the
+// Change. With WithFileLock(), the lock taken by LockFile() is held
+// while the file is read and written.
+func (r *LogRun) PutFileDiff(path string, content string, mode os.FileMode) (_ bool, _ string, err error) {
+	r = r.beginHelper()
+	defer r.addErrorContext(&err, r.commandSeq())
+	unlock, err := r.lockForEdit(path)
+	if err != nil {
+		return false, "", err
+	}
+	defer unlock() // nolint: errcheck
+
+	changed, diff, err := r.putFileString(path, content, mode)
+	if err == nil {
+		r.RecordOperation("PutFileString", path, changed)
+	}
+
+	return changed, diff, err
+}
+
+func (r *LogRun) putFileString(path string, content string, mode os.FileMode) (bool, string, error) {
+	current, exists, err := r.readFile(path)
+	if err != nil {
+		return false, "", err
+	}
+	perm := strconv.FormatUint(uint64(mode.Perm()), 8)
+	if exists && current == content && !r.Dryrun {
+		currentPerm, err := r.fileMode(path)
+		if err != nil {
+			return false, "", err
+		}
+		if currentPerm == perm {
+			return false, "", nil
+		}
+		if r.checking() {
+			r.check.add(Change{
+				Action: ChangeModify,
+				Target: path,
+				Detail: fmt.Sprintf("mode %s -> %s", currentPerm, perm),
+			})
+			return true, "", nil
+		}
+		if err := r.chmod(path, mode); err != nil {
+			return false, "", err
+		}
+		r.registerFileUndo(path, exists, current, currentPerm)
+		return true, "", nil
+	}
+
+	var diff string
+	switch {
+	case r.Dryrun:
+	case exists:
+		diff = UnifiedDiff(path, path, current, content)
+	default:
+		diff = UnifiedDiff("/dev/null", path, "", content)
+	}
+	var currentPerm string
+	if exists && r.undo != nil && !r.Dryrun && !r.checking() {
+		if currentPerm, err = r.fileMode(path); err != nil {
+			return false, "", err
+		}
+	}
+	r.logWriteFile(path, mode, contentInput(content))
+	if r.Dryrun {
+		return true, "", nil
+	}
+	if r.checking() {
+		if exists {
+			r.check.add(Change{Action: ChangeModify, Target: path, Detail: "content", Diff: diff})
+		} else {
+			r.check.add(Change{Action: ChangeCreate, Target: path, Detail: "mode " + perm, Diff: diff})
+		}
+		return true, diff, nil
+	}
+	if err := r.writeFile(path, content, mode); err != nil {
+		return false, "", err
+	}
+	r.registerFileUndo(path, exists, current, currentPerm)
+
+	return true, diff, nil
+}
+
+// writeFileCmd returns the shell command used to write the contents
+// read from its standard input to p with permission bits mode on hosts
+// without SFTP. The contents are written to a temporary file with a
+// random name in the directory of p, which mktemp creates exclusively,
+// so concurrent writers and planted symbolic links are not written
+// through.
+func writeFileCmd(p string, mode os.FileMode) string {
+	return fmt.Sprintf(
+		`tmp=$(mktemp %[1]s) && { cat > "$tmp" && chmod %[2]s "$tmp" && mv -f "$tmp" %[3]s || { rm -f "$tmp"; exit 1; }; }`,
+		shellQuote(tempFilePattern(p)+".XXXXXX"),
+		strconv.FormatUint(uint64(mode.Perm()), 8),
+		shellQuote(p))
+}
+
+// tempFilePattern returns the prefix of the names of the temporary
+// files used to write p, a hidden file in the directory of p, e.g.,
+// "/etc/.app.conf" for "/etc/app.conf".
+func tempFilePattern(p string) string {
+	return path.Join(path.Dir(p), "."+path.Base(p))
+}
+
+// tempFileName returns a random name of a temporary file used to write
+// p, e.g., "/etc/.app.conf.3f9a0c17b2e4".
+func tempFileName(p string) (string, error) {
+	suffix := make([]byte, 6)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", err
+	}
+
+	return tempFilePattern(p) + "." + hex.EncodeToString(suffix), nil
+}
+
+// logWriteFile logs writing the file at path with permission bits
+// mode. input is the command that outputs the content of the file in
+// the script exported from a DryrunPlan.
+func (r *LogRun) logWriteFile(path string, mode os.FileMode, input string) {
+	spec := execSpec{cmd: writeFileCmd(path, mode), shell: true, planInput: input}
+	if remote := r.sftpRunner(); remote != nil {
+		r.logChange(spec, remote.formatSFTP("put", path))
+	} else if r.localRunner() != nil {
+		r.logChange(spec, "put "+path)
+	} else {
+		r.logChangeEvent(spec, LogEvent{Message: r.format(spec), Command: spec.cmd, Shell: true})
+	}
+}
+
+// writeFile writes content to the file at path with permission bits
+// mode through a temporary file. It is logged by logWriteFile().
+func (r *LogRun) writeFile(path string, content string, mode os.FileMode) error {
+	err := r.writeFileContent(path, content, mode)
+	if err == nil && r.countTransfers() {
+		r.recordTransfer("WriteFile", path, int64(len(content)), 0)
+	}
+
+	return err
+}
+
+func (r *LogRun) writeFileContent(path string, content string, mode os.FileMode) error {
+	if remote := r.sftpRunner(); remote != nil {
+		return r.sftpWriteFile(remote, path, content, mode)
+	}
+	if local := r.localRunner(); local != nil {
+		return r.localWriteFile(local, path, content, mode)
+	}
+	_, stderr, code := r.runSpec(execSpec{
+		cmd:   writeFileCmd(path, mode),
+		shell: true,
+		stdin: strings.NewReader(content),
+	})
+	if code != 0 {
+		return fmt.Errorf("could not write %s: %s", path, strings.TrimSpace(stderr))
+	}
+
+	return nil
+}
+
+// chmod changes the permission bits of the file at path to mode.
+func (r *LogRun) chmod(path string, mode os.FileMode) error {
+	if remote := r.sftpRunner(); remote != nil {
+		return r.sftpChmod(remote, path, mode)
+	}
+	if local := r.localRunner(); local != nil {
+		return r.localChmod(local, path, mode)
+	}
+	_, stderr, code := r.Run(ChmodCmd, strconv.FormatUint(uint64(mode.Perm()), 8), path)
+	if code != 0 {
+		return fmt.Errorf("could not change mode of %s: %s", path, strings.TrimSpace(stderr))
+	}
+
+	return nil
+}
+
+// readFile returns the contents of path and whether or not it exists.
+func (r *LogRun) readFile(path string) (string, bool, error) {
+	content, exists, err := r.readFileContent(path)
+	if err == nil && r.countTransfers() {
+		r.recordTransfer("ReadFile", path, 0, int64(len(content)))
+	}
+
+	return content, exists, err
+}
+
+func (r *LogRun) readFileContent(path string) (string, bool, error) {
+	if remote := r.sftpRunner(); remote != nil {
+		return r.sftpReadFile(remote, path)
+	}
+	if local := r.localRunner(); local != nil {
+		return r.localReadFile(local, path)
+	}
+	r.logRun(ReadFileCmd, path)
+	if r.Dryrun {
+		return "", false, nil
+	}
+	// The contents are not decoded, unlike the output of other
+	// commands, so they are returned exactly.
+	stdout, stderr, code, err := r.execute(execSpec{cmd: ReadFileCmd, args: []string{path}, capture: true})
+	if err != nil {
+		return "", false, fmt.Errorf("could not read %s: %w", path, err)
+	}
+	stderr = r.decodeOutput(stderr)
+	if code != 0 {
+		if strings.Contains(stderr, "No such file or directory") {
+			return "", false, nil
+		}
+		return "", false, fmt.Errorf("could not read %s: %s", path, strings.TrimSpace(stderr))
+	}
+
+	return stdout, true, nil
+}
+
+// fileMode returns the permission bits of path in octal.
+func (r *LogRun) fileMode(path string) (string, error) {
+	if remote := r.sftpRunner(); remote != nil {
+		return r.sftpFileMode(remote, path)
+	}
+	if local := r.localRunner(); local != nil {
+		return r.localFileMode(local, path)
+	}
+	cmdArgs := append(append([]string{}, FileModeCmdOptions...), path)
+	r.logRun(FileModeCmd, cmdArgs...)
+	stdout, stderr, code := r.run(FileModeCmd, cmdArgs...)
+	if code != 0 {
+		if strings.Contains(stderr, "No such file or directory") {
+			return "", notFoundError("could not access", path)
+		}
+		return "", fmt.Errorf("could not access %s: %s", path, strings.TrimSpace(stderr))
+	}
+
+	return strings.TrimSpace(stdout), nil
+}
@@ -0,0 +1,297 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/apatters/go-logrun"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalLogRun_PutFileString(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-logrun-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "it's a file.conf")
+
+	log, out, errOut := newLogger()
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{
+		LogFunc: log.Println,
+	})
+
+	changed, err := l.PutFileString(path, "a = 1\n", 0640)
+	t.Logf("changed = %t", changed)
+	t.Logf("err = %v", err)
+	t.Logf("out = %q", out)
+	require.NoError(t, err)
+	assert.True(t, changed)
+	content, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "a = 1\n", string(content))
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0640), info.Mode().Perm())
+	assert.Empty(t, errOut.String())
+	out.Reset()
+
+	changed, err = l.PutFileString(path, "a = 1\n", 0640)
+	t.Logf("changed = %t", changed)
+	t.Logf("err = %v", err)
+	t.Logf("out = %q", out)
+	require.NoError(t, err)
+	assert.False(t, changed)
+	assert.EqualValues(t,
+		"get "+path+"\nstat "+path+"\n",
+		out.String())
+	out.Reset()
+
+	changed, err = l.PutFileString(path, "a = 1\n", 0600)
+	t.Logf("out = %q", out)
+	require.NoError(t, err)
+	assert.True(t, changed)
+	assert.EqualValues(t,
+		"get "+path+"\nstat "+path+"\nchmod 600 "+path+"\n",
+		out.String())
+	info, err = os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	changed, err = l.PutFileString(path, "a = 2\n", 0600)
+	require.NoError(t, err)
+	assert.True(t, changed)
+	content, err = ioutil.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "a = 2\n", string(content))
+
+	_, err = l.PutFileString(filepath.Join(dir, "xyzzy", "file"), "", 0600)
+	t.Logf("err = %v", err)
+	assert.Error(t, err)
+}
+
+func TestLocalLogRun_GetFileString(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-logrun-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "file")
+	err = ioutil.WriteFile(path, []byte("hello\n"), 0644)
+	require.NoError(t, err)
+
+	log, out, errOut := newLogger()
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{
+		LogFunc: log.Println,
+	})
+	content, err := l.GetFileString(path)
+	t.Logf("content = %q", content)
+	t.Logf("err = %v", err)
+	require.NoError(t, err)
+	assert.Equal(t, "hello\n", content)
+	assert.EqualValues(t, "get "+path+"\n", out.String())
+	assert.Empty(t, errOut.String())
+
+	_, err = l.GetFileString(filepath.Join(dir, "xyzzy"))
+	t.Logf("err = %v", err)
+	assert.Error(t, err)
+}
+
+func TestLocalLogRun_PutFileStringDryrun(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-logrun-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "file")
+
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{
+		Dryrun: true,
+	})
+	changed, err := l.PutFileString(path, "hello\n", 0644)
+	require.NoError(t, err)
+	assert.True(t, changed)
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestLocalLogRun_PutFileDiff(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.conf")
+
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{})
+	changed, diff, err := l.PutFileDiff(path, "a = 1\nb = 2\n", 0644)
+	t.Logf("changed = %t", changed)
+	t.Logf("diff = %q", diff)
+	require.NoError(t, err)
+	assert.True(t, changed)
+	assert.Equal(t, "--- /dev/null\n+++ "+path+"\n@@ -0,0 +1,2 @@\n+a = 1\n+b = 2\n", diff)
+
+	changed, diff, err = l.PutFileDiff(path, "a = 1\nb = 3\n", 0644)
+	t.Logf("changed = %t", changed)
+	t.Logf("diff = %q", diff)
+	require.NoError(t, err)
+	assert.True(t, changed)
+	assert.Equal(t, "--- "+path+"\n+++ "+path+"\n@@ -1,2 +1,2 @@\n a = 1\n-b = 2\n+b = 3\n", diff)
+
+	changed, diff, err = l.PutFileDiff(path, "a = 1\nb = 3\n", 0644)
+	require.NoError(t, err)
+	assert.False(t, changed)
+	assert.Empty(t, diff)
+
+	report, err := l.Check(func(r *logrun.LogRun) error {
+		_, err := r.PutFileString(path, "a = 2\nb = 3\n", 0644)
+		return err
+	})
+	require.NoError(t, err)
+	t.Logf("report diff = %q", report.Diff())
+	require.Len(t, report.Changes(), 1)
+	assert.Equal(t, "--- "+path+"\n+++ "+path+"\n@@ -1,2 +1,2 @@\n-a = 1\n+a = 2\n b = 3\n", report.Changes()[0].Diff)
+	assert.Equal(t, report.Changes()[0].Diff, report.Diff())
+	content, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "a = 1\nb = 3\n", string(content))
+}
+
+func testReadWriteFile(t *testing.T, r *logrun.LogRun) {
+	dir, err := ioutil.TempDir("", "go-logrun-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "app.conf")
+	data := []byte("password = hunter2\n\x00\x01\xff")
+
+	require.NoError(t, r.WriteFile(path, data, 0600))
+	content, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, data, content)
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	content, err = r.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, data, content)
+
+	_, err = r.ReadFile(filepath.Join(dir, "missing"))
+	t.Logf("err = %v", err)
+	assert.True(t, errors.Is(err, logrun.ErrNotFound))
+}
+
+func TestLocalLogRun_ReadWriteFile(t *testing.T) {
+	log, out, _ := newLogger()
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{LogFunc: log.Println})
+	l.SetRecorder(logrun.NewRecorder())
+	testReadWriteFile(t, l)
+	t.Logf("out = %q", out)
+	assert.NotContains(t, out.String(), "hunter2")
+	assert.Equal(t, 1, l.Summary().Changed)
+}
+
+func TestRemoteLogRun_ReadWriteFile(t *testing.T) {
+	s := newTestSSHServer(t, nil)
+	defer s.Close()
+	log, out, _ := newLogger()
+	r, err := logrun.NewRemoteLogRun(logrun.RemoteConfig{
+		LogFunc:     log.Println,
+		Credentials: s.Credentials(),
+	})
+	require.NoError(t, err)
+	testReadWriteFile(t, r)
+	t.Logf("out = %q", out)
+	assert.Contains(t, out.String(), "cat > ")
+	assert.NotContains(t, out.String(), "hunter2")
+}
+
+func TestRemoteLogRun_ReadFileRaw(t *testing.T) {
+	s := newTestSSHServer(t, nil)
+	defer s.Close()
+	dir, err := ioutil.TempDir("", "go-logrun-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "data.bin")
+	data := []byte("line\r\n\xff\xfe\x80\r\n")
+	require.NoError(t, ioutil.WriteFile(path, data, 0644))
+
+	r, err := logrun.NewRemoteLogRun(logrun.RemoteConfig{Credentials: s.Credentials()})
+	require.NoError(t, err)
+	defer r.Close() // nolint: errcheck
+	r.SetOutputEncoding(logrun.EncodingLatin1)
+	r.SetNormalizeCRLF(true)
+
+	// Output decoding applies to commands, not to file contents.
+	content, err := r.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, data, content)
+	stdout, _, code := r.Run("cat", path)
+	require.Zero(t, code)
+	assert.NotEqual(t, string(data), stdout)
+}
+
+// testConcurrentWrites writes one file from several goroutines using
+// r and checks that each write replaced the file as a whole without
+// leaving temporary files behind.
+func testConcurrentWrites(t *testing.T, r *logrun.LogRun) {
+	dir, err := ioutil.TempDir("", "go-logrun-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "app.conf")
+
+	contents := make([]string, 8)
+	var wg sync.WaitGroup
+	for i := range contents {
+		contents[i] = strings.Repeat(strconv.Itoa(i), 100000)
+		wg.Add(1)
+		go func(content string) {
+			defer wg.Done()
+			assert.NoError(t, r.WriteFile(path, []byte(content), 0640))
+		}(contents[i])
+	}
+	wg.Wait()
+	data, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, contents, string(data))
+	fi, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0640), fi.Mode().Perm())
+
+	err = r.WriteFile(filepath.Join(dir, "missing", "app.conf"), []byte("x"), 0640)
+	t.Logf("err = %v", err)
+	assert.Error(t, err)
+	entries, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "app.conf", entries[0].Name())
+}
+
+func TestRemoteLogRun_ConcurrentWrites(t *testing.T) {
+	s := newTestSSHServer(t, nil)
+	defer s.Close()
+	r, err := logrun.NewRemoteLogRun(logrun.RemoteConfig{Credentials: s.Credentials()})
+	require.NoError(t, err)
+	testConcurrentWrites(t, r)
+}
+
+func TestLocalLogRun_WriteFileCheck(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-logrun-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "app.conf")
+
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{})
+	report, err := l.Check(func(r *logrun.LogRun) error {
+		return r.WriteFile(path, []byte("x"), 0644)
+	})
+	require.NoError(t, err)
+	require.Len(t, report.Changes(), 1)
+	assert.Equal(t, logrun.ChangeCreate, report.Changes()[0].Action)
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestLocalLogRun_ConcurrentWrites(t *testing.T) {
+	testConcurrentWrites(t, logrun.NewLocalLogRun(logrun.LocalConfig{}))
+}
@@ -0,0 +1,199 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// FileLockCmd is the external command used to hold an advisory
+	// lock on a file. This command has been tested on RHEL/CentOS 7
+	// and Ubuntu 18.04.
+	FileLockCmd = "/usr/bin/flock"
+
+	// FileLockCmdOptions are the command-line options added to
+	// FileLockCmd to take an exclusive lock. This command and
+	// options has been tested on RHEL/CentOS 7 and Ubuntu 18.04.
+	FileLockCmdOptions = []string{
+		"--exclusive",
+	}
+
+	// FileLockSuffix is appended to the path of a file to name the
+	// file that is locked while it is edited. A separate lock file
+	// is used because files are replaced by renaming a temporary
+	// file, so a lock on the file itself would not be seen by an
+	// editor that opened the file after the rename.
+	FileLockSuffix = ".lock"
+
+	// FileLockTimeout is the maximum amount of time LockFile()
+	// waits for a lock held by another process. A timeout of zero
+	// waits indefinitely.
+	FileLockTimeout = 60 * time.Second
+)
+
+// fileLockHoldCmd is run by FileLockCmd while the lock is held. It
+// reports that the lock was taken and then waits for its standard
+// input to be closed.
+const fileLockHoldCmd = "echo locked && exec cat > /dev/null"
+
+// WithFileLock makes PutFileString(), PutFileDiff(), and EditFile()
+// hold the lock taken by LockFile() while they read, compare, and
+// write a file, so programs editing the same file on a host, e.g.,
+// /etc/exports, cannot interleave their changes. The lock is advisory
+// and only excludes other editors that lock the file the same way.
+func WithFileLock() CallOption {
+	return func(o *callOptions) {
+		o.fileLock = true
+	}
+}
+
+// LockFile takes an exclusive advisory lock for editing the file at
+// path, waiting up to FileLockTimeout for a lock held by another
+// process. The lock is taken with FileLockCmd on the file named by
+// appending FileLockSuffix to path, which is created if it does not
+// exist and is left in place. The returned function releases the lock
+// and must be called once the edit is done, e.g.,
+//
+//	unlock, err := runner.LockFile("/etc/exports")
+//	if err != nil {
+//		return err
+//	}
+//	defer unlock()
+//
+// Locks are not reentrant, so do not also use WithFileLock() while
+// holding the lock for the same file. Only logging is performed if
+// Dryrun is true or in check mode. Locking is not supported on Windows
+// hosts.
+func (r *LogRun) LockFile(path string) (_ func() error, err error) {
+	r = r.beginHelper()
+	defer r.addErrorContext(&err, r.commandSeq())
+	if r.windowsRunner() != nil {
+		return nil, fmt.Errorf("file locking is not supported on Windows hosts")
+	}
+	cmdArgs := append([]string{}, FileLockCmdOptions...)
+	if FileLockTimeout > 0 {
+		secs := (FileLockTimeout + time.Second - 1) / time.Second
+		cmdArgs = append(cmdArgs, "--timeout", strconv.Itoa(int(secs)))
+	}
+	cmdArgs = append(cmdArgs, path+FileLockSuffix, "--command", fileLockHoldCmd)
+	r.logRun(FileLockCmd, cmdArgs...)
+	if r.Dryrun || r.checking() {
+		return func() error { return nil }, nil
+	}
+
+	stdin, release := io.Pipe()
+	locked := &lockedWriter{locked: make(chan struct{})}
+	done := make(chan error, 1)
+	go func() {
+		var stderr bytes.Buffer
+		_, _, code, err := r.execute(execSpec{
+			cmd:    FileLockCmd,
+			args:   cmdArgs,
+			stdin:  stdin,
+			stdout: locked,
+			stderr: &stderr,
+		})
+		if err == nil && code != 0 {
+			err = fmt.Errorf("could not lock %s: %s", path, strings.TrimSpace(stderr.String()))
+		}
+		done <- err
+	}()
+	select {
+	case <-locked.locked:
+	case err := <-done:
+		release.Close() // nolint: errcheck
+		if err == nil {
+			err = fmt.Errorf("could not lock %s: %s exited before taking the lock", path, FileLockCmd)
+		}
+		return nil, err
+	}
+
+	var once sync.Once
+	var unlockErr error
+	unlock := func() error {
+		once.Do(func() {
+			release.Close() // nolint: errcheck
+			unlockErr = <-done
+		})
+		return unlockErr
+	}
+
+	return unlock, nil
+}
+
+// lockForEdit takes the lock for editing path if WithFileLock() is in
+// effect. The returned function releases the lock, if any.
+func (r *LogRun) lockForEdit(path string) (func() error, error) {
+	if !r.call.fileLock {
+		return func() error { return nil }, nil
+	}
+
+	return r.LockFile(path)
+}
+
+// EditFile passes the contents of the file at path to edit and writes
+// the returned contents with permission bits mode like
+// PutFileString(). A file that does not exist is passed as the empty
+// string and is only created if edit returns non-empty contents. The
+// returned bool is true if the file was changed. With WithFileLock(),
+// the lock taken by LockFile() is held from reading the file until it
+// is written, e.g.,
+//
+//	runner.With(logrun.WithFileLock()).EditFile("/etc/exports", 0644,
+//		func(content string) (string, error) {
+//			return content + "/srv 10.0.0.0/8(ro)\n", nil
+//		})
+//
+// An error returned by edit is returned without changing the file.
+// The outcome is recorded as an operation for the Summary().
+func (r *LogRun) EditFile(path string, mode os.FileMode, edit func(content string) (string, error)) (_ bool, err error) {
+	r = r.beginHelper()
+	defer r.addErrorContext(&err, r.commandSeq())
+	unlock, err := r.lockForEdit(path)
+	if err != nil {
+		return false, err
+	}
+	defer unlock() // nolint: errcheck
+
+	content, exists, err := r.readFile(path)
+	if err != nil {
+		return false, err
+	}
+	newContent, err := edit(content)
+	if err != nil {
+		return false, err
+	}
+	changed := false
+	if exists || newContent != "" {
+		if changed, _, err = r.putFileString(path, newContent, mode); err != nil {
+			return false, err
+		}
+	}
+	r.RecordOperation("EditFile", path, changed)
+
+	return changed, nil
+}
+
+// lockedWriter discards what FileLockCmd writes to its standard
+// output and closes locked on the first write, which reports that
+// the lock was taken.
+type lockedWriter struct {
+	once   sync.Once
+	locked chan struct{}
+}
+
+func (w *lockedWriter) Write(p []byte) (int, error) {
+	w.once.Do(func() { close(w.locked) })
+
+	return len(p), nil
+}
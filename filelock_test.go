@@ -0,0 +1,164 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/apatters/go-logrun"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalLogRun_LockFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-logrun-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "exports")
+
+	log, out, errOut := newLogger()
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{
+		LogFunc: log.Println,
+	})
+
+	unlock, err := l.LockFile(path)
+	t.Logf("err = %v", err)
+	t.Logf("out = %q", out)
+	require.NoError(t, err)
+	assert.Contains(t, out.String(), logrun.FileLockCmd+" --exclusive --timeout 60 "+path+".lock --command ")
+	assert.FileExists(t, path+".lock")
+
+	acquired := make(chan error, 1)
+	go func() {
+		unlock2, err := logrun.NewLocalLogRun(logrun.LocalConfig{}).LockFile(path)
+		if err == nil {
+			err = unlock2()
+		}
+		acquired <- err
+	}()
+	select {
+	case err := <-acquired:
+		t.Fatalf("second lock taken while the first was held: %v", err)
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	err = unlock()
+	t.Logf("unlock err = %v", err)
+	require.NoError(t, err)
+	assert.NoError(t, unlock())
+	select {
+	case err := <-acquired:
+		t.Logf("second lock err = %v", err)
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("second lock not taken after the first was released")
+	}
+	assert.Empty(t, errOut.String())
+}
+
+func TestLocalLogRun_LockFileDryrun(t *testing.T) {
+	path := filepath.Join(os.TempDir(), "go-logrun-does-not-exist")
+	log, out, _ := newLogger()
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{
+		LogFunc: log.Println,
+		Dryrun:  true,
+	})
+
+	unlock, err := l.LockFile(path)
+	t.Logf("out = %q", out)
+	require.NoError(t, err)
+	assert.NoError(t, unlock())
+	assert.Contains(t, out.String(), path+".lock")
+	_, err = os.Stat(path + ".lock")
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestLocalLogRun_EditFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-logrun-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "counter")
+
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{}).With(logrun.WithFileLock())
+	increment := func(content string) (string, error) {
+		n, _ := strconv.Atoi(strings.TrimSpace(content))
+		time.Sleep(10 * time.Millisecond)
+		return strconv.Itoa(n+1) + "\n", nil
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 5)
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := l.EditFile(path, 0644, increment)
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		assert.NoError(t, err)
+	}
+	content, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	t.Logf("content = %q", content)
+	assert.Equal(t, "5\n", string(content))
+
+	changed, err := l.EditFile(path, 0644, func(content string) (string, error) {
+		return content, nil
+	})
+	require.NoError(t, err)
+	assert.False(t, changed)
+
+	editErr := errors.New("edit failed")
+	changed, err = l.EditFile(path, 0644, func(content string) (string, error) {
+		return "", editErr
+	})
+	assert.Equal(t, editErr, err)
+	assert.False(t, changed)
+
+	missing := filepath.Join(dir, "missing")
+	changed, err = l.EditFile(missing, 0644, func(content string) (string, error) {
+		return content, nil
+	})
+	require.NoError(t, err)
+	assert.False(t, changed)
+	_, err = os.Stat(missing)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestLocalLogRun_PutFileStringWithFileLock(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-logrun-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "exports")
+
+	log, out, _ := newLogger()
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{
+		LogFunc: log.Println,
+	})
+
+	changed, err := l.With(logrun.WithFileLock()).PutFileString(path, "/srv *(ro)\n", 0644)
+	t.Logf("out = %q", out)
+	require.NoError(t, err)
+	assert.True(t, changed)
+	assert.True(t, strings.HasPrefix(out.String(), logrun.FileLockCmd+" "))
+	out.Reset()
+
+	_, err = l.PutFileString(path, "/srv *(rw)\n", 0644)
+	t.Logf("out = %q", out)
+	require.NoError(t, err)
+	assert.NotContains(t, out.String(), logrun.FileLockCmd)
+}
@@ -0,0 +1,242 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+)
+
+var (
+	// MkdirCmd is the external command used to create
+	// directories. This command has been tested on RHEL/CentOS 7
+	// and Ubuntu 18.04.
+	MkdirCmd = "/bin/mkdir"
+
+	// MkdirCmdOptions are the command-line options added to
+	// MkdirCmd by MkdirAll(). This command and options has been
+	// tested on RHEL/CentOS 7 and Ubuntu 18.04.
+	MkdirCmdOptions = []string{"-p"}
+
+	// RemoveAllCmdOptions are the command-line options added to
+	// RemoveFileCmd by RemoveAll(). This command and options has
+	// been tested on RHEL/CentOS 7 and Ubuntu 18.04.
+	RemoveAllCmdOptions = []string{"-r", "-f"}
+
+	// ChownCmd is the external command used to change the owner
+	// of a file. This command has been tested on RHEL/CentOS 7 and
+	// Ubuntu 18.04.
+	ChownCmd = "/bin/chown"
+
+	// SymlinkCmd is the external command used to create symbolic
+	// links. This command has been tested on RHEL/CentOS 7 and
+	// Ubuntu 18.04.
+	SymlinkCmd = "/bin/ln"
+
+	// SymlinkCmdOptions are the command-line options added to
+	// SymlinkCmd. This command and options has been tested on
+	// RHEL/CentOS 7 and Ubuntu 18.04.
+	SymlinkCmdOptions = []string{"-s", "-n"}
+)
+
+// The methods below change files on the host. Local runners use the
+// os package and log a pseudo-command, e.g., "mkdir -p /var/lib/app",
+// like the local file operations in local_files.go. Other runners run
+// the external commands above. Only logging is performed if Dryrun is
+// true. In check mode, the change is only recorded. Each change is
+// recorded as an operation for the Summary().
+
+// MkdirAll creates the directory path with permission bits mode along
+// with any missing parents, like "mkdir -p -m". It is not an error if
+// path already exists, in which case its mode is not changed.
+func (r *LogRun) MkdirAll(path string, mode os.FileMode) (err error) {
+	r = r.beginHelper()
+	defer r.addErrorContext(&err, r.commandSeq())
+	perm := strconv.FormatUint(uint64(mode.Perm()), 8)
+	args := append(append([]string{}, MkdirCmdOptions...), "-m", perm, path)
+	return r.changeFile("MkdirAll", "create", path,
+		Change{Action: ChangeCreate, Target: path, Detail: "directory mode " + perm},
+		MkdirCmd, args,
+		func(p string) error {
+			if _, err := os.Stat(p); err == nil {
+				return nil
+			}
+			if err := os.MkdirAll(p, mode.Perm()); err != nil {
+				return err
+			}
+			// The permissions given to MkdirAll() are subject to
+			// the umask.
+			return os.Chmod(p, mode.Perm())
+		})
+}
+
+// Remove removes the file or symbolic link at path. It is not an
+// error if path does not exist. Use RemoveAll() to remove
+// directories.
+func (r *LogRun) Remove(path string) (err error) {
+	r = r.beginHelper()
+	defer r.addErrorContext(&err, r.commandSeq())
+	args := append(append([]string{}, RemoveFileCmdOptions...), path)
+	return r.changeFile("Remove", "remove", path,
+		Change{Action: ChangeDelete, Target: path},
+		RemoveFileCmd, args,
+		func(p string) error {
+			if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			return nil
+		})
+}
+
+// RemoveAll removes path and everything it contains, like
+// os.RemoveAll(). It is not an error if path does not exist.
+func (r *LogRun) RemoveAll(path string) (err error) {
+	r = r.beginHelper()
+	defer r.addErrorContext(&err, r.commandSeq())
+	args := append(append([]string{}, RemoveAllCmdOptions...), path)
+	return r.changeFile("RemoveAll", "remove", path,
+		Change{Action: ChangeDelete, Target: path, Detail: "recursive"},
+		RemoveFileCmd, args, os.RemoveAll)
+}
+
+// Chmod changes the permission bits of path to mode.
+func (r *LogRun) Chmod(path string, mode os.FileMode) (err error) {
+	r = r.beginHelper()
+	defer r.addErrorContext(&err, r.commandSeq())
+	perm := strconv.FormatUint(uint64(mode.Perm()), 8)
+	return r.changeFile("Chmod", "change mode of", path,
+		Change{Action: ChangeModify, Target: path, Detail: "mode " + perm},
+		ChmodCmd, []string{perm, path},
+		func(p string) error { return os.Chmod(p, mode.Perm()) })
+}
+
+// Chown changes the owner of path to owner, which is either a user or
+// a user and group separated by a colon, e.g., "nginx:nginx", like
+// chown(1).
+func (r *LogRun) Chown(path string, owner string) (err error) {
+	r = r.beginHelper()
+	defer r.addErrorContext(&err, r.commandSeq())
+	if owner == "" || strings.HasPrefix(owner, ":") {
+		return fmt.Errorf("could not change owner of %s: invalid owner %q", path, owner)
+	}
+	return r.changeFile("Chown", "change owner of", path,
+		Change{Action: ChangeModify, Target: path, Detail: "owner " + owner},
+		ChownCmd, []string{owner, path},
+		func(p string) error {
+			uid, gid, err := lookupOwner(owner)
+			if err != nil {
+				return err
+			}
+			return os.Chown(p, uid, gid)
+		})
+}
+
+// Symlink creates newname as a symbolic link to oldname, like
+// os.Symlink(). It is an error if newname already exists, even if it
+// is a symbolic link to a directory.
+func (r *LogRun) Symlink(oldname string, newname string) (err error) {
+	r = r.beginHelper()
+	defer r.addErrorContext(&err, r.commandSeq())
+	args := append(append([]string{}, SymlinkCmdOptions...), oldname, newname)
+	return r.changeFile("Symlink", "create", newname,
+		Change{Action: ChangeCreate, Target: newname, Detail: "symlink to " + oldname},
+		SymlinkCmd, args,
+		func(p string) error { return os.Symlink(oldname, p) })
+}
+
+// changeFile logs and performs the change of path described by
+// change. Local runners call local with the path resolved against the
+// working directory and log cmd with args without its directory.
+// Other runners run cmd with args. Errors are reported as "could not
+// <verb> <path>".
+func (r *LogRun) changeFile(
+	op string,
+	verb string,
+	path string,
+	change Change,
+	cmd string,
+	args []string,
+	local func(p string) error) error {
+	lr := r.localRunner()
+	switch {
+	case lr != nil:
+		r.logChange(execSpec{cmd: cmd, args: args}, strings.Join(append([]string{baseName(cmd)}, args...), " "))
+	case r.windowsRunner() != nil:
+		return fmt.Errorf("could not %s %s: not supported on Windows hosts", verb, path)
+	default:
+		r.logChangeRun(cmd, args...)
+	}
+	if r.Dryrun {
+		return nil
+	}
+	if r.checking() {
+		r.check.add(change)
+		return nil
+	}
+	if lr != nil {
+		if err := local(r.localPath(lr, path)); err != nil {
+			return accessFailure(verb, path, err)
+		}
+	} else {
+		_, stderr, code := r.run(cmd, args...)
+		if code != 0 {
+			return accessFailure(verb, path, commandError(stderr, code))
+		}
+	}
+	r.RecordOperation(op, path, true)
+
+	return nil
+}
+
+// accessFailure returns err wrapped in an error reporting that path
+// could not be changed.
+func accessFailure(verb string, path string, err error) error {
+	e := &wrappedError{msg: fmt.Sprintf("could not %s %s: %v", verb, path, err), err: err}
+	if errors.Is(err, os.ErrPermission) {
+		e.cause = ErrPermissionDenied
+	}
+
+	return e
+}
+
+// baseName returns the last element of the slash-separated path p.
+func baseName(p string) string {
+	return p[strings.LastIndex(p, "/")+1:]
+}
+
+// lookupOwner returns the uid and gid of owner, a user optionally
+// followed by a colon and a group. The gid is -1, i.e., unchanged, if
+// owner does not include a group. Numeric IDs are used as is.
+func lookupOwner(owner string) (int, int, error) {
+	parts := strings.SplitN(owner, ":", 2)
+	uid, err := strconv.Atoi(parts[0])
+	if err != nil {
+		u, err := user.Lookup(parts[0])
+		if err != nil {
+			return 0, 0, err
+		}
+		if uid, err = strconv.Atoi(u.Uid); err != nil {
+			return 0, 0, fmt.Errorf("user %s has non-numeric uid %s", parts[0], u.Uid)
+		}
+	}
+	gid := -1
+	if len(parts) == 2 && parts[1] != "" {
+		if gid, err = strconv.Atoi(parts[1]); err != nil {
+			g, err := user.LookupGroup(parts[1])
+			if err != nil {
+				return 0, 0, err
+			}
+			if gid, err = strconv.Atoi(g.Gid); err != nil {
+				return 0, 0, fmt.Errorf("group %s has non-numeric gid %s", parts[1], g.Gid)
+			}
+		}
+	}
+
+	return uid, gid, nil
+}
@@ -0,0 +1,121 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun_test
+
+import (
+	"io/ioutil"
+	"os"
+	"os/user"
+	"path/filepath"
+	"testing"
+
+	"github.com/apatters/go-logrun"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testFileChanges creates, changes, and removes files in a temporary
+// directory using r.
+func testFileChanges(t *testing.T, r *logrun.LogRun) string {
+	tmpDir, err := ioutil.TempDir("", "logrun")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmpDir)
+
+	dir := filepath.Join(tmpDir, "a", "b")
+	require.NoError(t, r.MkdirAll(dir, 0750))
+	require.NoError(t, r.MkdirAll(dir, 0700))
+	fi, err := os.Stat(dir)
+	require.NoError(t, err)
+	assert.True(t, fi.IsDir())
+	assert.Equal(t, os.FileMode(0750), fi.Mode().Perm())
+
+	file := filepath.Join(dir, "app.conf")
+	require.NoError(t, ioutil.WriteFile(file, []byte("x"), 0644))
+	require.NoError(t, r.Chmod(file, 0600))
+	fi, err = os.Stat(file)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), fi.Mode().Perm())
+
+	u, err := user.Current()
+	require.NoError(t, err)
+	require.NoError(t, r.Chown(file, u.Username))
+	assert.Error(t, r.Chown(file, ""))
+
+	link := filepath.Join(tmpDir, "current")
+	require.NoError(t, r.Symlink(dir, link))
+	target, err := os.Readlink(link)
+	require.NoError(t, err)
+	assert.Equal(t, dir, target)
+	err = r.Symlink(dir, link)
+	t.Logf("err = %v", err)
+	assert.Error(t, err)
+
+	require.NoError(t, r.Remove(link))
+	require.NoError(t, r.Remove(link))
+	_, err = os.Lstat(link)
+	assert.True(t, os.IsNotExist(err))
+
+	require.NoError(t, r.RemoveAll(filepath.Join(tmpDir, "a")))
+	_, err = os.Stat(filepath.Join(tmpDir, "a"))
+	assert.True(t, os.IsNotExist(err))
+
+	return tmpDir
+}
+
+func TestLocalLogRun_FileChanges(t *testing.T) {
+	log, out, _ := newLogger()
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{LogFunc: log.Println})
+	l.SetRecorder(logrun.NewRecorder())
+	tmpDir := testFileChanges(t, l)
+	t.Logf("out = %q", out)
+	assert.Contains(t, out.String(), "mkdir -p -m 750 "+filepath.Join(tmpDir, "a", "b")+"\n")
+	assert.Contains(t, out.String(), "rm -r -f "+filepath.Join(tmpDir, "a")+"\n")
+	assert.Contains(t, out.String(), "ln -s -n ")
+	assert.Equal(t, 8, l.Summary().Changed)
+}
+
+func TestRemoteLogRun_FileChanges(t *testing.T) {
+	s := newTestSSHServer(t, nil)
+	defer s.Close()
+	log, out, _ := newLogger()
+	r, err := logrun.NewRemoteLogRun(logrun.RemoteConfig{
+		LogFunc:     log.Println,
+		Credentials: s.Credentials(),
+	})
+	require.NoError(t, err)
+	tmpDir := testFileChanges(t, r)
+	t.Logf("out = %q", out)
+	assert.Contains(t, out.String(), logrun.MkdirCmd+" -p -m 750 "+filepath.Join(tmpDir, "a", "b"))
+	assert.Contains(t, out.String(), logrun.ChownCmd+" ")
+}
+
+func TestLocalLogRun_FileChangesCheck(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "logrun")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmpDir)
+	dir := filepath.Join(tmpDir, "new")
+
+	log, out, _ := newLogger()
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{LogFunc: log.Println, Dryrun: true})
+	require.NoError(t, l.MkdirAll(dir, 0755))
+	assert.Equal(t, "mkdir -p -m 755 "+dir+"\n", out.String())
+	_, err = os.Stat(dir)
+	assert.True(t, os.IsNotExist(err))
+
+	l.SetDryrun(false)
+	report, err := l.Check(func(r *logrun.LogRun) error {
+		if err := r.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+		return r.RemoveAll(tmpDir)
+	})
+	require.NoError(t, err)
+	t.Logf("report =\n%s", report)
+	require.Len(t, report.Changes(), 2)
+	assert.Equal(t, logrun.ChangeCreate, report.Changes()[0].Action)
+	assert.Equal(t, logrun.ChangeDelete, report.Changes()[1].Action)
+	_, err = os.Stat(tmpDir)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,151 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// HeadCmd is the external command used to limit the number of paths
+// returned by GlobWithOptions(). This command has been tested on
+// RHEL/CentOS 7 and Ubuntu 18.04.
+var HeadCmd = "/usr/bin/head"
+
+// GlobSort selects the order of the paths returned by
+// GlobWithOptions().
+type GlobSort int
+
+const (
+	// GlobSortNone returns paths in the order of Glob(), which is
+	// sorted by name on all hosts except Windows hosts.
+	GlobSortNone GlobSort = iota
+
+	// GlobSortName sorts paths by name in ascending order.
+	GlobSortName
+
+	// GlobSortModTime sorts paths by modification time, newest
+	// first, like ls -t.
+	GlobSortModTime
+
+	// GlobSortSize sorts paths by size, largest first, like ls -S.
+	GlobSortSize
+)
+
+// GlobOptions selects the order and number of the paths returned by
+// GlobWithOptions().
+type GlobOptions struct {
+	// Sort is the order of the paths. Ties are sorted by name.
+	Sort GlobSort
+
+	// Reverse reverses the order selected by Sort, e.g., oldest
+	// first for GlobSortModTime.
+	Reverse bool
+
+	// Limit is the maximum number of paths returned. If zero,
+	// all paths are returned.
+	Limit int
+}
+
+// GlobWithOptions is like Glob() but sorts and limits the paths
+// matching pattern on the host, so selecting a few of many paths does
+// not transfer them all, e.g., the newest backup is returned by
+//
+//	runner.GlobWithOptions("/var/backups/db-*.tar.gz", logrun.GlobOptions{
+//		Sort:  logrun.GlobSortModTime,
+//		Limit: 1,
+//	})
+//
+// Remote runners without SFTP sort with the options of GlobCmd and
+// limit the output with HeadCmd.
+func (r *LogRun) GlobWithOptions(pattern string, opts GlobOptions) (_ []string, err error) {
+	r = r.beginHelper()
+	defer r.addErrorContext(&err, r.commandSeq())
+	if opts.Limit < 0 {
+		return []string{}, fmt.Errorf("invalid glob limit %d", opts.Limit)
+	}
+
+	return r.glob(pattern, opts)
+}
+
+// globMatch is a path matching a glob pattern along with the
+// attributes it may be sorted by.
+type globMatch struct {
+	path    string
+	modTime time.Time
+	size    int64
+}
+
+// sortGlobMatches sorts and limits matches according to opts and
+// returns their paths.
+func sortGlobMatches(matches []globMatch, opts GlobOptions) []string {
+	less := func(a, b globMatch) bool { return a.path < b.path }
+	switch opts.Sort {
+	case GlobSortModTime:
+		less = func(a, b globMatch) bool {
+			if !a.modTime.Equal(b.modTime) {
+				return a.modTime.After(b.modTime)
+			}
+			return a.path < b.path
+		}
+	case GlobSortSize:
+		less = func(a, b globMatch) bool {
+			if a.size != b.size {
+				return a.size > b.size
+			}
+			return a.path < b.path
+		}
+	}
+	if opts.Sort != GlobSortNone {
+		sort.SliceStable(matches, func(i, j int) bool {
+			if opts.Reverse {
+				return less(matches[j], matches[i])
+			}
+			return less(matches[i], matches[j])
+		})
+	} else if opts.Reverse {
+		for i, j := 0, len(matches)-1; i < j; i, j = i+1, j-1 {
+			matches[i], matches[j] = matches[j], matches[i]
+		}
+	}
+	if opts.Limit > 0 && len(matches) > opts.Limit {
+		matches = matches[:opts.Limit]
+	}
+	results := make([]string, 0, len(matches))
+	for _, m := range matches {
+		results = append(results, m.path)
+	}
+
+	return results
+}
+
+// globSortOptions returns the options added to GlobCmd to sort paths
+// according to opts.
+func globSortOptions(opts GlobOptions) []string {
+	var options []string
+	switch opts.Sort {
+	case GlobSortModTime:
+		options = append(options, "-t")
+	case GlobSortSize:
+		options = append(options, "-S")
+	}
+	if opts.Reverse {
+		options = append(options, "-r")
+	}
+
+	return options
+}
+
+// globLimit returns the shell notation limiting the output of GlobCmd
+// to opts.Limit lines, if any.
+func globLimit(opts GlobOptions) string {
+	if opts.Limit == 0 {
+		return ""
+	}
+
+	return " | " + HeadCmd + " -n " + strconv.Itoa(opts.Limit)
+}
@@ -0,0 +1,142 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apatters/go-logrun"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newGlobTestDir creates backup files whose modification times and
+// sizes are ordered differently from their names.
+func newGlobTestDir(t *testing.T) string {
+	tmpDir, err := ioutil.TempDir("", "logrun")
+	require.NoError(t, err)
+	now := time.Now()
+	for i, f := range []struct {
+		name string
+		age  time.Duration
+		size int
+	}{
+		{"db-1.bak", 3 * time.Hour, 20},
+		{"db-2.bak", 1 * time.Hour, 10},
+		{"db-3.bak", 2 * time.Hour, 30},
+	} {
+		p := filepath.Join(tmpDir, f.name)
+		require.NoError(t, ioutil.WriteFile(p, []byte(strings.Repeat("x", f.size)), 0644))
+		mtime := now.Add(-f.age).Add(time.Duration(i) * time.Millisecond)
+		require.NoError(t, os.Chtimes(p, mtime, mtime))
+	}
+
+	return tmpDir
+}
+
+func runGlobWithOptionsTest(t *testing.T, r *logrun.LogRun, tmpDir string) {
+	pattern := filepath.Join(tmpDir, "db-*.bak")
+	paths := func(names ...string) []string {
+		var p []string
+		for _, name := range names {
+			p = append(p, filepath.Join(tmpDir, name))
+		}
+		return p
+	}
+	for _, e := range []struct {
+		opts     logrun.GlobOptions
+		expected []string
+	}{
+		{logrun.GlobOptions{}, paths("db-1.bak", "db-2.bak", "db-3.bak")},
+		{logrun.GlobOptions{Sort: logrun.GlobSortName, Reverse: true}, paths("db-3.bak", "db-2.bak", "db-1.bak")},
+		{logrun.GlobOptions{Sort: logrun.GlobSortModTime}, paths("db-2.bak", "db-3.bak", "db-1.bak")},
+		{logrun.GlobOptions{Sort: logrun.GlobSortModTime, Limit: 1}, paths("db-2.bak")},
+		{logrun.GlobOptions{Sort: logrun.GlobSortModTime, Reverse: true, Limit: 1}, paths("db-1.bak")},
+		{logrun.GlobOptions{Sort: logrun.GlobSortSize, Limit: 2}, paths("db-3.bak", "db-1.bak")},
+		{logrun.GlobOptions{Limit: 5}, paths("db-1.bak", "db-2.bak", "db-3.bak")},
+	} {
+		results, err := r.GlobWithOptions(pattern, e.opts)
+		t.Logf("opts = %+v", e.opts)
+		t.Logf("results = %q", results)
+		require.NoError(t, err)
+		assert.Equal(t, e.expected, results)
+	}
+
+	results, err := r.GlobWithOptions(filepath.Join(tmpDir, "xyzzy*"), logrun.GlobOptions{Limit: 1})
+	t.Logf("err = %v", err)
+	assert.True(t, errors.Is(err, logrun.ErrGlobFailed))
+	assert.Equal(t, []string{}, results)
+
+	_, err = r.GlobWithOptions(pattern, logrun.GlobOptions{Limit: -1})
+	t.Logf("err = %v", err)
+	assert.Error(t, err)
+}
+
+func TestLocalLogRun_GlobWithOptions(t *testing.T) {
+	tmpDir := newGlobTestDir(t)
+	defer os.RemoveAll(tmpDir)
+
+	log, out, errOut := newLogger()
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{
+		LogFunc: log.Println,
+	})
+	runGlobWithOptionsTest(t, l, tmpDir)
+	t.Logf("out = %q", out)
+	assert.Empty(t, errOut.String())
+}
+
+func TestRemoteLogRun_GlobWithOptions(t *testing.T) {
+	s := newTestSSHServer(t, nil)
+	defer s.Close()
+	tmpDir := newGlobTestDir(t)
+	defer os.RemoveAll(tmpDir)
+
+	log, out, _ := newLogger()
+	r, err := logrun.NewRemoteLogRun(logrun.RemoteConfig{
+		LogFunc:     log.Println,
+		Credentials: s.Credentials(),
+	})
+	require.NoError(t, err)
+	defer r.Close() // nolint: errcheck
+	runGlobWithOptionsTest(t, r, tmpDir)
+	t.Logf("out = %q", out)
+	assert.Contains(t, out.String(),
+		logrun.GlobCmd+" -1 --directory -t -r "+filepath.Join(tmpDir, "db-*.bak")+" | "+logrun.HeadCmd+" -n 1\"\n")
+}
+
+func TestRemoteLogRun_SFTPGlobWithOptions(t *testing.T) {
+	s := newTestSSHServer(t, nil)
+	defer s.Close()
+	tmpDir := newGlobTestDir(t)
+	defer os.RemoveAll(tmpDir)
+
+	r, out := newSFTPTestLogRun(t, s)
+	defer r.Close() // nolint: errcheck
+	runGlobWithOptionsTest(t, r, tmpDir)
+	logged := out()
+	t.Logf("out = %q", logged)
+	assert.NotContains(t, logged, logrun.GlobCmd)
+}
+
+func TestRemoteLogRun_WindowsGlobWithOptions(t *testing.T) {
+	var logged []string
+	r := newWindowsTestLogRun(t, "")
+	r.SetLogFunc(func(v ...interface{}) {
+		logged = append(logged, v[0].(string))
+	})
+	r.SetDryrun(true)
+
+	_, err := r.GlobWithOptions(`C:\Backups\*.bak`, logrun.GlobOptions{Sort: logrun.GlobSortModTime, Limit: 1})
+	t.Logf("logged = %q", logged)
+	assert.NoError(t, err)
+	require.Len(t, logged, 1)
+	assert.Contains(t, logged[0], `Get-Item -Path 'C:\Backups\*.bak' -ErrorAction SilentlyContinue | Sort-Object -Property @{Expression='LastWriteTime'; Descending=$true}, FullName | Select-Object -First 1`)
+}
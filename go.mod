@@ -2,6 +2,6 @@ module github.com/apatters/go-logrun
 
 require (
 	github.com/apatters/go-conlog v1.0.1
-	github.com/apatters/go-run v1.0.1
 	github.com/stretchr/testify v1.3.0
+	golang.org/x/crypto v0.0.0-20190131182504-b8fe1690c613
 )
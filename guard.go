@@ -0,0 +1,73 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun
+
+// guard is a shell command that decides whether or not a command is
+// run.
+type guard struct {
+	cmd    string
+	unless bool
+}
+
+// OnlyIf runs the shell command cmd before each command run by Run()
+// or Shell() and skips the command unless cmd exits with a zero exit
+// code, e.g.,
+//
+//	runner.With(logrun.OnlyIf("test -f /etc/nginx/nginx.conf")).Run("nginx", "-s", "reload")
+//
+// Skipped commands are logged as skipped and recorded as skipped in
+// the Summary(). Multiple guards are evaluated in order and all of
+// them must allow the command for it to run. Guards should not change
+// the host: they are logged but not run in dryrun mode, and they are
+// run in check mode.
+func OnlyIf(cmd string) CallOption {
+	return func(o *callOptions) {
+		o.guards = append(append([]guard{}, o.guards...), guard{cmd: cmd})
+	}
+}
+
+// Unless runs the shell command cmd before each command run by Run()
+// or Shell() and skips the command if cmd exits with a zero exit code,
+// e.g., to express idempotence:
+//
+//	runner.With(logrun.Unless("id deploy")).Run("useradd", "deploy")
+//
+// See OnlyIf().
+func Unless(cmd string) CallOption {
+	return func(o *callOptions) {
+		o.guards = append(append([]guard{}, o.guards...), guard{cmd: cmd, unless: true})
+	}
+}
+
+// checkGuards runs the guards of the LogRun. It returns a non-empty
+// reason if the command should be skipped or an error if a guard
+// cannot be run.
+func (r *LogRun) checkGuards() (string, error) {
+	if len(r.call.guards) == 0 {
+		return "", nil
+	}
+
+	// Guards are run without the call options of the command
+	// they guard, e.g., output files and PID tracking.
+	g := *r
+	g.call = callOptions{}
+	for _, gd := range r.call.guards {
+		g.logShell(gd.cmd)
+		if g.Dryrun {
+			continue
+		}
+		_, _, code, err := g.execute(execSpec{cmd: gd.cmd, shell: true, capture: true})
+		switch {
+		case err != nil:
+			return "", err
+		case gd.unless && code == 0:
+			return trf("unless %q succeeded", gd.cmd), nil
+		case !gd.unless && code != 0:
+			return trf("only if %q failed with exit code %d", gd.cmd, code), nil
+		}
+	}
+
+	return "", nil
+}
@@ -0,0 +1,83 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun_test
+
+import (
+	"testing"
+
+	"github.com/apatters/go-logrun"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalLogRun_OnlyIf(t *testing.T) {
+	log, out, _ := newLogger()
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{
+		LogFunc: log.Println,
+	})
+	l.SetRecorder(logrun.NewRecorder())
+
+	stdout, stderr, code := l.With(logrun.OnlyIf("true")).Run("/bin/echo", "ran")
+	t.Logf("out = %q", out)
+	assert.Equal(t, "ran\n", stdout)
+	assert.Empty(t, stderr)
+	assert.Zero(t, code)
+	assert.Equal(t, "/bin/sh -c \"true\"\n/bin/echo ran\n", out.String())
+
+	out.Reset()
+	stdout, _, code = l.With(logrun.OnlyIf("exit 3")).Shell("echo ran")
+	t.Logf("out = %q", out)
+	assert.Empty(t, stdout)
+	assert.Zero(t, code)
+	assert.Equal(t,
+		"/bin/sh -c \"exit 3\"\n"+
+			"skipped: /bin/sh -c \"echo ran\" (only if \"exit 3\" failed with exit code 3)\n",
+		out.String())
+
+	summary := l.Summary()
+	t.Logf("summary = %+v", summary)
+	assert.Equal(t, 1, summary.Skipped)
+	commands := l.Recorder().Commands()
+	require.NotEmpty(t, commands)
+	assert.Equal(t, logrun.SkipGuard, commands[len(commands)-1].SkipReason)
+}
+
+func TestLocalLogRun_Unless(t *testing.T) {
+	log, out, _ := newLogger()
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{
+		LogFunc: log.Println,
+	})
+
+	stdout, _, code := l.With(logrun.Unless("true")).Run("/bin/echo", "ran")
+	t.Logf("out = %q", out)
+	assert.Empty(t, stdout)
+	assert.Zero(t, code)
+	assert.Contains(t, out.String(), "skipped: /bin/echo ran (unless \"true\" succeeded)")
+
+	// All guards must allow the command.
+	out.Reset()
+	stdout, _, _ = l.With(logrun.Unless("false"), logrun.OnlyIf("true")).Run("/bin/echo", "ran")
+	t.Logf("out = %q", out)
+	assert.Equal(t, "ran\n", stdout)
+	stdout, _, _ = l.With(logrun.Unless("false"), logrun.OnlyIf("false")).Run("/bin/echo", "ran")
+	assert.Empty(t, stdout)
+
+	// Guards are not applied to the LogRun they were derived from.
+	stdout, _, _ = l.Run("/bin/echo", "ran")
+	assert.Equal(t, "ran\n", stdout)
+}
+
+func TestLocalLogRun_GuardDryrun(t *testing.T) {
+	log, out, _ := newLogger()
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{
+		LogFunc: log.Println,
+		Dryrun:  true,
+	})
+
+	_, _, code := l.With(logrun.OnlyIf("false")).Run("/bin/echo", "ran")
+	t.Logf("out = %q", out)
+	assert.Zero(t, code)
+	assert.Equal(t, "/bin/sh -c \"false\"\n/bin/echo ran\n", out.String())
+}
@@ -0,0 +1,116 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// handler is an action registered with RegisterHandler().
+type handler struct {
+	name string
+	fn   func(r *LogRun) error
+}
+
+// handlerSet holds the registered handlers and the names of those
+// that have been notified. It is shared by copies of a LogRun.
+type handlerSet struct {
+	mu       sync.Mutex
+	handlers []handler
+	pending  map[string]bool
+}
+
+func (r *LogRun) handlerSet() *handlerSet {
+	if r.handlers == nil {
+		r.handlers = &handlerSet{pending: make(map[string]bool)}
+	}
+
+	return r.handlers
+}
+
+// RegisterHandler registers f as the handler called name, e.g.,
+// "restart nginx". Handlers are only run by FlushHandlers() after
+// being notified, typically because an operation changed the host.
+// Register handlers before deriving LogRuns from r with With() so the
+// handlers are shared. Registering a name again replaces its handler
+// but keeps its original position in the run order.
+func (r *LogRun) RegisterHandler(name string, f func(r *LogRun) error) {
+	hs := r.handlerSet()
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+	for i := range hs.handlers {
+		if hs.handlers[i].name == name {
+			hs.handlers[i].fn = f
+			return
+		}
+	}
+	hs.handlers = append(hs.handlers, handler{name: name, fn: f})
+}
+
+// Notify queues the named handlers to be run by FlushHandlers().
+// Notifying a handler more than once before a flush only runs it once.
+func (r *LogRun) Notify(names ...string) {
+	hs := r.handlerSet()
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+	for _, name := range names {
+		hs.pending[name] = true
+	}
+}
+
+// WithNotify notifies the named handlers whenever an operation run
+// through the LogRun reports that it changed the host, e.g.,
+//
+//	runner.With(logrun.WithNotify("restart nginx")).PutFileString(path, conf, 0644)
+//
+// See RecordOperation().
+func WithNotify(names ...string) CallOption {
+	return func(o *callOptions) {
+		o.notify = append(append([]string{}, o.notify...), names...)
+	}
+}
+
+// FlushHandlers runs the notified handlers once each in the order they
+// were registered and clears the notifications. "handler: name" is
+// logged before each handler is run. Handlers are passed a copy of r
+// without call options; handlers they notify are queued for the next
+// flush. All notified handlers are run even if some fail; the
+// failures, including notifications of unregistered handlers, are
+// returned as a single error.
+func (r *LogRun) FlushHandlers() error {
+	if r.handlers == nil {
+		return nil
+	}
+	hs := r.handlers
+	hs.mu.Lock()
+	pending := hs.pending
+	hs.pending = make(map[string]bool)
+	handlers := append([]handler{}, hs.handlers...)
+	hs.mu.Unlock()
+
+	c := *r
+	c.call = callOptions{}
+	var errs []string
+	for _, h := range handlers {
+		if !pending[h.name] {
+			continue
+		}
+		delete(pending, h.name)
+		c.log("handler: " + h.name)
+		if err := h.fn(&c); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %s", h.name, err))
+		}
+	}
+	for name := range pending {
+		errs = append(errs, fmt.Sprintf("%s: no such handler", name))
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("handlers failed: %s", strings.Join(errs, "; "))
+	}
+
+	return nil
+}
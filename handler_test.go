@@ -0,0 +1,83 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/apatters/go-logrun"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalLogRun_FlushHandlers(t *testing.T) {
+	log, out, _ := newLogger()
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{
+		LogFunc: log.Println,
+	})
+	tmpDir, err := ioutil.TempDir("", "logrun")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmpDir)
+
+	var ran []string
+	l.RegisterHandler("reload nginx", func(r *logrun.LogRun) error {
+		ran = append(ran, "reload nginx")
+		_, _, code := r.Shell("echo reload")
+		assert.Zero(t, code)
+		return nil
+	})
+	l.RegisterHandler("restart app", func(r *logrun.LogRun) error {
+		ran = append(ran, "restart app")
+		return nil
+	})
+
+	// Nothing is run if nothing changed.
+	path := filepath.Join(tmpDir, "nginx.conf")
+	require.NoError(t, ioutil.WriteFile(path, []byte("conf\n"), 0644))
+	n := l.With(logrun.WithNotify("reload nginx"))
+	_, err = n.PutFileString(path, "conf\n", 0644)
+	require.NoError(t, err)
+	require.NoError(t, l.FlushHandlers())
+	assert.Empty(t, ran)
+
+	// Handlers run once each in registration order.
+	l.Notify("restart app")
+	_, err = n.PutFileString(path, "new conf\n", 0644)
+	require.NoError(t, err)
+	_, err = n.PutFileString(filepath.Join(tmpDir, "site.conf"), "site\n", 0644)
+	require.NoError(t, err)
+	out.Reset()
+	require.NoError(t, l.FlushHandlers())
+	t.Logf("out = %q", out)
+	assert.Equal(t, []string{"reload nginx", "restart app"}, ran)
+	assert.Equal(t, "handler: reload nginx\n/bin/sh -c \"echo reload\"\nhandler: restart app\n", out.String())
+
+	// Notifications are cleared by a flush.
+	ran = nil
+	require.NoError(t, l.FlushHandlers())
+	assert.Empty(t, ran)
+}
+
+func TestLocalLogRun_FlushHandlersErrors(t *testing.T) {
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{})
+	l.RegisterHandler("fail", func(r *logrun.LogRun) error {
+		return errors.New("boom")
+	})
+	ran := false
+	l.RegisterHandler("ok", func(r *logrun.LogRun) error {
+		ran = true
+		return nil
+	})
+	l.Notify("ok", "missing", "fail")
+	err := l.FlushHandlers()
+	t.Logf("err = %v", err)
+	require.Error(t, err)
+	assert.True(t, ran)
+	assert.Equal(t, "handlers failed: fail: boom; missing: no such handler", err.Error())
+}
@@ -0,0 +1,60 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun
+
+import (
+	"sync"
+)
+
+// helperCall holds the state of one call of a helper method, e.g.,
+// PutFileString(), that is shared by the commands and helpers it runs
+// in turn.
+type helperCall struct {
+	mu sync.Mutex
+
+	// estimated is true once the estimate declared using
+	// WithEstimate() has been added to a line of the DryrunPlan.
+	estimated bool
+
+	// seq is the number of commands run by the call so far and
+	// command the last of them, if error context is enabled.
+	seq     uint64
+	command string
+}
+
+// beginHelper returns the LogRun a helper method runs its commands
+// with: r if it is already used by a helper, i.e., the helper is
+// called by another one, or a copy of r that tracks a new call.
+// Helpers call it before anything else, e.g.,
+//
+//	r = r.beginHelper()
+//	defer r.addErrorContext(&err, r.commandSeq())
+func (r *LogRun) beginHelper() *LogRun {
+	if r.helper != nil {
+		return r
+	}
+	c := *r
+	c.helper = new(helperCall)
+
+	return &c
+}
+
+// planEstimate returns the estimate added to the next command line of
+// the DryrunPlan. A helper only adds it to its first command line, so
+// it is counted once per call.
+func (r *LogRun) planEstimate() Estimate {
+	h := r.helper
+	if h == nil {
+		return r.call.estimate
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.estimated {
+		return Estimate{}
+	}
+	h.estimated = true
+
+	return r.call.estimate
+}
@@ -0,0 +1,103 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+)
+
+// HostInfo identifies the host commands are run on.
+type HostInfo struct {
+	// Local is true if commands are run on the local host.
+	Local bool
+
+	// Hostname is the name of the host as given in the
+	// Credentials, or the local hostname for local runners.
+	Hostname string
+
+	// Port is the SSH port commands are run through. It is zero
+	// for local runners.
+	Port int
+
+	// Username is the account commands are run as.
+	Username string
+
+	// Address is the resolved network address (IP:port) used
+	// for the last connection to the remote host. It is empty
+	// for local runners and before the first connection.
+	Address string
+
+	// ServerVersion is the version identification string sent by
+	// the SSH server on the last connection, e.g.,
+	// "SSH-2.0-OpenSSH_7.4". It is empty for local runners and
+	// before the first connection.
+	ServerVersion string
+
+	// Banner is the banner sent by the SSH server on the last
+	// connection, if any.
+	Banner string
+}
+
+// String returns a string representation of the host suitable for
+// attributing log messages, e.g., "user@host:22". Hosts without a
+// port, e.g., Docker containers, omit it and, if unknown, the user.
+func (h HostInfo) String() string {
+	switch {
+	case h.Local:
+		return fmt.Sprintf("%s@%s", h.Username, h.Hostname)
+	case h.Port == 0 && h.Username == "":
+		return h.Hostname
+	case h.Port == 0:
+		return fmt.Sprintf("%s@%s", h.Username, h.Hostname)
+	}
+
+	return fmt.Sprintf("%s@%s:%d", h.Username, h.Hostname, h.Port)
+}
+
+// hostIdentifier is implemented by runners that know the identity of
+// the host they run commands on.
+type hostIdentifier interface {
+	hostInfo() HostInfo
+}
+
+// Host returns the identity of the host commands are run on with
+// defaults (port, username) resolved. The zero HostInfo is returned
+// if the Runner was not created by one of the LogRun constructors.
+func (r *LogRun) Host() HostInfo {
+	if h, ok := r.Runner.(hostIdentifier); ok {
+		return h.hostInfo()
+	}
+
+	return HostInfo{}
+}
+
+func (l *localRunner) hostInfo() HostInfo {
+	if l.host != nil {
+		return l.host.hostInfo()
+	}
+	h := HostInfo{Local: true}
+	h.Hostname, _ = os.Hostname()
+	if u, err := user.Current(); err == nil {
+		h.Username = u.Username
+	}
+
+	return h
+}
+
+func (r *remoteRunner) hostInfo() HostInfo {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return HostInfo{
+		Hostname:      r.credentials.Hostname,
+		Port:          r.credentials.Port,
+		Username:      r.credentials.Username,
+		Address:       r.address,
+		ServerVersion: r.serverVersion,
+		Banner:        r.banner,
+	}
+}
@@ -0,0 +1,232 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha1" // nolint: gosec
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// HostKeyPolicy selects how remote LogRuns check the host keys of SSH
+// servers. Policies that use a known_hosts file reject the keys
+// revoked by its @revoked entries.
+type HostKeyPolicy int
+
+const (
+	// HostKeyAcceptAny accepts any host key. This is the default.
+	// It does not protect against man-in-the-middle attacks.
+	HostKeyAcceptAny HostKeyPolicy = iota
+
+	// HostKeyTrustOnFirstUse accepts the key of a host that is not
+	// in the known_hosts file and records it there. Keys that
+	// differ from the recorded ones are rejected, like the
+	// StrictHostKeyChecking=accept-new option of ssh.
+	HostKeyTrustOnFirstUse
+
+	// HostKeyStrict only accepts the keys recorded in the
+	// known_hosts file, which must be provisioned beforehand.
+	HostKeyStrict
+)
+
+// knownHostsMu serializes reading and appending to known_hosts files,
+// which may be shared by the runners of a Pool.
+var knownHostsMu sync.Mutex
+
+// knownHosts checks host keys against a known_hosts file.
+type knownHosts struct {
+	filename string
+	policy   HostKeyPolicy
+}
+
+// newKnownHosts returns the knownHosts for policy and filename, which
+// defaults to ~/.ssh/known_hosts. Nil is returned for
+// HostKeyAcceptAny.
+func newKnownHosts(policy HostKeyPolicy, filename string) (*knownHosts, error) {
+	switch policy {
+	case HostKeyAcceptAny:
+		return nil, nil
+	case HostKeyTrustOnFirstUse, HostKeyStrict:
+	default:
+		return nil, fmt.Errorf("invalid host key policy %d", policy)
+	}
+	if filename == "" {
+		u, err := user.Current()
+		if err != nil {
+			return nil, err
+		}
+		filename = filepath.Join(u.HomeDir, ".ssh", "known_hosts")
+	}
+
+	return &knownHosts{filename: filename, policy: policy}, nil
+}
+
+// configure sets up config to check the host key of the SSH server
+// of hostname listening on port. The accepted key types are limited
+// to those recorded for the host, if any, so the server presents a
+// key that can be checked.
+func (k *knownHosts) configure(config *ssh.ClientConfig, hostname string, port int) error {
+	if k == nil {
+		config.HostKeyCallback = ssh.InsecureIgnoreHostKey() // nolint: gosec
+		return nil
+	}
+	name := knownHostsName(hostname, port)
+	knownHostsMu.Lock()
+	keys, _, _, err := k.lookup(name)
+	knownHostsMu.Unlock()
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		config.HostKeyAlgorithms = append(config.HostKeyAlgorithms, key.Type())
+	}
+	config.HostKeyCallback = func(_ string, _ net.Addr, key ssh.PublicKey) error {
+		return k.check(name, key)
+	}
+
+	return nil
+}
+
+// check returns nil if key is a known key of the host name. Unknown
+// hosts are recorded if the policy is HostKeyTrustOnFirstUse. Revoked
+// keys are always rejected and never recorded.
+func (k *knownHosts) check(name string, key ssh.PublicKey) error {
+	knownHostsMu.Lock()
+	defer knownHostsMu.Unlock()
+	keys, revoked, data, err := k.lookup(name)
+	if err != nil {
+		return err
+	}
+	for _, r := range revoked {
+		if bytes.Equal(r.Marshal(), key.Marshal()) {
+			return fmt.Errorf("%s host key %s of %s is revoked in %s",
+				key.Type(), ssh.FingerprintSHA256(key), name, k.filename)
+		}
+	}
+	for _, known := range keys {
+		if bytes.Equal(known.Marshal(), key.Marshal()) {
+			return nil
+		}
+	}
+	if len(keys) > 0 {
+		return fmt.Errorf("%s host key %s of %s does not match %s: possible man-in-the-middle attack",
+			key.Type(), ssh.FingerprintSHA256(key), name, k.filename)
+	}
+	if k.policy != HostKeyTrustOnFirstUse {
+		return fmt.Errorf("%s host key %s of %s is not in %s",
+			key.Type(), ssh.FingerprintSHA256(key), name, k.filename)
+	}
+
+	return k.add(name, key, data)
+}
+
+// lookup returns the keys recorded for the host name, the keys revoked
+// by @revoked entries, and the contents of the known_hosts file.
+// Revoked keys are returned whatever hosts their entries name, since
+// a revoked key must not be trusted for any host. @cert-authority
+// entries, since host certificates are not supported, and entries
+// that can not be parsed are ignored. A missing file has no entries.
+// knownHostsMu must be held.
+func (k *knownHosts) lookup(name string) (keys []ssh.PublicKey, revoked []ssh.PublicKey, data []byte, err error) {
+	data, err = ioutil.ReadFile(k.filename)
+	if os.IsNotExist(err) {
+		return nil, nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("could not read known hosts: %w", err)
+	}
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		marker, hosts, key, _, _, err := ssh.ParseKnownHosts(line)
+		if err != nil {
+			continue
+		}
+		if marker == "revoked" {
+			revoked = append(revoked, key)
+			continue
+		}
+		if marker != "" {
+			continue
+		}
+		for _, host := range hosts {
+			if knownHostMatches(host, name) {
+				keys = append(keys, key)
+				break
+			}
+		}
+	}
+
+	return keys, revoked, data, nil
+}
+
+// add appends an entry for key of the host name to the known_hosts
+// file, whose contents are data. knownHostsMu must be held.
+func (k *knownHosts) add(name string, key ssh.PublicKey, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(k.filename), 0700); err != nil {
+		return fmt.Errorf("could not record host key of %s: %w", name, err)
+	}
+	f, err := os.OpenFile(k.filename, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return fmt.Errorf("could not record host key of %s: %w", name, err)
+	}
+	entry := name + " " + string(ssh.MarshalAuthorizedKey(key))
+	if len(data) > 0 && data[len(data)-1] != '\n' {
+		entry = "\n" + entry
+	}
+	if _, err := f.WriteString(entry); err != nil {
+		f.Close() // nolint: errcheck
+		return fmt.Errorf("could not record host key of %s: %w", name, err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("could not record host key of %s: %w", name, err)
+	}
+
+	return nil
+}
+
+// knownHostsName returns the name of the host in known_hosts files,
+// i.e., the hostname or, for ports other than 22, "[hostname]:port".
+func knownHostsName(hostname string, port int) string {
+	if port == 0 || port == defaultSSHPort {
+		return hostname
+	}
+
+	return "[" + hostname + "]:" + strconv.Itoa(port)
+}
+
+// knownHostMatches returns true if pattern, a host of a known_hosts
+// entry, matches name. Hashed hosts are supported, wildcards are not.
+func knownHostMatches(pattern string, name string) bool {
+	if !strings.HasPrefix(pattern, "|1|") {
+		return pattern == name
+	}
+	parts := strings.Split(pattern[3:], "|")
+	if len(parts) != 2 {
+		return false
+	}
+	salt, err := base64.StdEncoding.DecodeString(parts[0])
+	if err != nil {
+		return false
+	}
+	hash, err := base64.StdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha1.New, salt)
+	mac.Write([]byte(name)) // nolint: errcheck
+
+	return hmac.Equal(mac.Sum(nil), hash)
+}
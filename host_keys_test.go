@@ -0,0 +1,153 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1" // nolint: gosec
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/apatters/go-logrun"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+)
+
+// knownHostsEntry returns a known_hosts line for key of the test
+// server s.
+func knownHostsEntry(s *testSSHServer, key ssh.PublicKey) string {
+	return fmt.Sprintf("[127.0.0.1]:%d %s", s.Port(), ssh.MarshalAuthorizedKey(key))
+}
+
+func newHostKeyLogRun(t *testing.T, s *testSSHServer, policy logrun.HostKeyPolicy, file string) *logrun.LogRun {
+	r, err := logrun.NewRemoteLogRun(logrun.RemoteConfig{
+		Credentials:    s.Credentials(),
+		HostKeyPolicy:  policy,
+		KnownHostsFile: file,
+	})
+	require.NoError(t, err)
+
+	return r
+}
+
+func TestRemoteLogRun_HostKeyTrustOnFirstUse(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-logrun-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "ssh", "known_hosts")
+	s := newTestSSHServer(t, nil)
+	defer s.Close()
+
+	r := newHostKeyLogRun(t, s, logrun.HostKeyTrustOnFirstUse, file)
+	_, stderr, code := r.Run("true")
+	require.Equal(t, 0, code, stderr)
+	require.NoError(t, r.Close())
+	data, err := ioutil.ReadFile(file)
+	require.NoError(t, err)
+	t.Logf("known_hosts = %q", data)
+	assert.Equal(t, knownHostsEntry(s, s.HostKey()), string(data))
+
+	// The recorded key is accepted without adding it again.
+	r = newHostKeyLogRun(t, s, logrun.HostKeyTrustOnFirstUse, file)
+	_, stderr, code = r.Run("true")
+	require.Equal(t, 0, code, stderr)
+	require.NoError(t, r.Close())
+	after, err := ioutil.ReadFile(file)
+	require.NoError(t, err)
+	assert.Equal(t, data, after)
+}
+
+func TestRemoteLogRun_HostKeyMismatch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-logrun-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "known_hosts")
+	s := newTestSSHServer(t, nil)
+	defer s.Close()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	other, err := ssh.NewPublicKey(&key.PublicKey)
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(file, []byte(knownHostsEntry(s, other)), 0600))
+
+	r := newHostKeyLogRun(t, s, logrun.HostKeyTrustOnFirstUse, file)
+	_, stderr, code := r.Run("true")
+	t.Logf("stderr = %q", stderr)
+	assert.Equal(t, logrun.ExitErrorExecute, code)
+	assert.Contains(t, stderr, "does not match")
+}
+
+func TestRemoteLogRun_HostKeyRevoked(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-logrun-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "known_hosts")
+	s := newTestSSHServer(t, nil)
+	defer s.Close()
+	revoked := "@revoked * " + string(ssh.MarshalAuthorizedKey(s.HostKey()))
+
+	for _, policy := range []logrun.HostKeyPolicy{logrun.HostKeyTrustOnFirstUse, logrun.HostKeyStrict} {
+		t.Logf("policy = %d", policy)
+		require.NoError(t, ioutil.WriteFile(file, []byte(revoked), 0600))
+		r := newHostKeyLogRun(t, s, policy, file)
+		_, stderr, code := r.Run("true")
+		t.Logf("stderr = %q", stderr)
+		assert.Equal(t, logrun.ExitErrorExecute, code)
+		assert.Contains(t, stderr, "is revoked in "+file)
+
+		// The revoked key is not recorded.
+		data, err := ioutil.ReadFile(file)
+		require.NoError(t, err)
+		assert.Equal(t, revoked, string(data))
+
+		// Revocation takes precedence over a recorded key.
+		require.NoError(t, ioutil.WriteFile(file, []byte(knownHostsEntry(s, s.HostKey())+revoked), 0600))
+		r = newHostKeyLogRun(t, s, policy, file)
+		_, stderr, code = r.Run("true")
+		assert.Equal(t, logrun.ExitErrorExecute, code)
+		assert.Contains(t, stderr, "is revoked in "+file)
+	}
+}
+
+func TestRemoteLogRun_HostKeyStrict(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-logrun-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "known_hosts")
+	s := newTestSSHServer(t, nil)
+	defer s.Close()
+
+	r := newHostKeyLogRun(t, s, logrun.HostKeyStrict, file)
+	_, stderr, code := r.Run("true")
+	t.Logf("stderr = %q", stderr)
+	assert.Equal(t, logrun.ExitErrorExecute, code)
+	assert.Contains(t, stderr, "is not in "+file)
+	_, err = os.Stat(file)
+	assert.True(t, os.IsNotExist(err))
+
+	// Hashed entries are matched.
+	salt := make([]byte, sha1.Size)
+	_, err = rand.Read(salt)
+	require.NoError(t, err)
+	mac := hmac.New(sha1.New, salt)
+	fmt.Fprintf(mac, "[127.0.0.1]:%d", s.Port())
+	entry := fmt.Sprintf("|1|%s|%s %s",
+		base64.StdEncoding.EncodeToString(salt),
+		base64.StdEncoding.EncodeToString(mac.Sum(nil)),
+		ssh.MarshalAuthorizedKey(s.HostKey()))
+	require.NoError(t, ioutil.WriteFile(file, []byte("# comment\n"+entry), 0600))
+	r = newHostKeyLogRun(t, s, logrun.HostKeyStrict, file)
+	_, stderr, code = r.Run("true")
+	assert.Equal(t, 0, code, stderr)
+	require.NoError(t, r.Close())
+}
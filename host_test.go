@@ -0,0 +1,97 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun_test
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/apatters/go-logrun"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+)
+
+func TestLocalLogRun_Host(t *testing.T) {
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{})
+	h := l.Host()
+	t.Logf("h = %+v", h)
+	hostname, err := os.Hostname()
+	require.NoError(t, err)
+	assert.True(t, h.Local)
+	assert.Equal(t, hostname, h.Hostname)
+	assert.Equal(t, username(), h.Username)
+	assert.Zero(t, h.Port)
+	assert.Empty(t, h.Address)
+	assert.Equal(t, username()+"@"+hostname, h.String())
+}
+
+func TestRemoteLogRun_Host(t *testing.T) {
+	r, err := logrun.NewRemoteLogRun(logrun.RemoteConfig{
+		Credentials: logrun.Credentials{
+			Hostname: "db1.example.com",
+			Username: "deploy",
+			Password: "secret",
+		},
+	})
+	require.NoError(t, err)
+	h := r.Host()
+	t.Logf("h = %+v", h)
+	assert.False(t, h.Local)
+	assert.Equal(t, "db1.example.com", h.Hostname)
+	assert.Equal(t, 22, h.Port)
+	assert.Equal(t, "deploy", h.Username)
+	assert.Empty(t, h.Address)
+	assert.Equal(t, "deploy@db1.example.com:22", h.String())
+}
+
+func TestRemoteLogRun_HostAddress(t *testing.T) {
+	r, err := logrun.NewRemoteLogRun(logrun.RemoteConfig{})
+	require.NoError(t, err)
+	_, _, code := r.Run("/bin/true")
+	require.Zero(t, code)
+	h := r.Host()
+	t.Logf("h = %+v", h)
+	assert.Regexp(t, `:22$`, h.Address)
+}
+
+func TestLogRun_HostUnknownRunner(t *testing.T) {
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{})
+	l.Runner = nil
+	assert.Equal(t, logrun.HostInfo{}, l.Host())
+}
+
+func TestRemoteLogRun_HostServerVersion(t *testing.T) {
+	s := newTestSSHServer(t, func(config *ssh.ServerConfig) {
+		config.ServerVersion = "SSH-2.0-LogRunTest_1.0"
+		config.BannerCallback = func(ssh.ConnMetadata) string {
+			return "Authorized use only\n"
+		}
+	})
+	defer s.Close()
+
+	log, out, _ := newLogger()
+	r, err := logrun.NewRemoteLogRun(logrun.RemoteConfig{
+		LogFunc:          log.Println,
+		Credentials:      s.Credentials(),
+		LogServerVersion: true,
+	})
+	require.NoError(t, err)
+	assert.Empty(t, r.Host().ServerVersion)
+	for i := 0; i < 2; i++ {
+		stdout, stderr, code := r.Run("echo", "hello")
+		t.Logf("stdout = %q, stderr = %q, code = %d", stdout, stderr, code)
+		require.Zero(t, code)
+		assert.Equal(t, "hello\n", stdout)
+	}
+	h := r.Host()
+	t.Logf("h = %+v", h)
+	t.Logf("out = %q", out)
+	assert.Equal(t, "SSH-2.0-LogRunTest_1.0", h.ServerVersion)
+	assert.Equal(t, "Authorized use only\n", h.Banner)
+	assert.Equal(t, 1, strings.Count(out.String(),
+		`server version SSH-2.0-LogRunTest_1.0, banner "Authorized use only"`))
+}
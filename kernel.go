@@ -0,0 +1,197 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var (
+	// LsmodCmd is the external command used to list the loaded
+	// kernel modules. This command has been tested on
+	// RHEL/CentOS 7 and Ubuntu 18.04.
+	LsmodCmd = "/sbin/lsmod"
+
+	// ModprobeCmd is the external command used to load kernel
+	// modules. This command has been tested on RHEL/CentOS 7 and
+	// Ubuntu 18.04.
+	ModprobeCmd = "/sbin/modprobe"
+
+	// DetectVirtCmd is the external command used to detect the
+	// virtualization platform of a host. This command has been
+	// tested on RHEL/CentOS 7 and Ubuntu 18.04.
+	DetectVirtCmd = "/usr/bin/systemd-detect-virt"
+
+	// ContainerVirtTypes are the types output by DetectVirtCmd
+	// for container platforms. All other types except "none" are
+	// virtual machines.
+	ContainerVirtTypes = []string{
+		"openvz",
+		"lxc",
+		"lxc-libvirt",
+		"systemd-nspawn",
+		"docker",
+		"podman",
+		"rkt",
+		"wsl",
+		"proot",
+		"pouch",
+	}
+)
+
+// KernelModule is a loaded kernel module.
+type KernelModule struct {
+	Name     string
+	Size     uint64
+	UseCount int
+
+	// UsedBy are the names of the modules using the module.
+	UsedBy []string
+}
+
+// Virtualization describes the virtualization platform of a host.
+type Virtualization struct {
+	// Type is the platform reported by DetectVirtCmd, e.g.,
+	// "kvm", "vmware", "docker", or "none" for bare metal.
+	Type string
+
+	// VM is true if the host is a virtual machine.
+	VM bool
+
+	// Container is true if the host is a container.
+	Container bool
+}
+
+// ParseLsmod parses the output of LsmodCmd.
+func ParseLsmod(output string) ([]KernelModule, error) {
+	var mods []KernelModule
+	for i, line := range lines(output) {
+		if i == 0 && strings.HasPrefix(line, "Module") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 3 {
+			return nil, fmt.Errorf("could not parse kernel module %q", line)
+		}
+		size, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse kernel module %q: %w", line, err)
+		}
+		count, err := strconv.Atoi(fields[2])
+		if err != nil {
+			return nil, fmt.Errorf("could not parse kernel module %q: %w", line, err)
+		}
+		mod := KernelModule{Name: fields[0], Size: size, UseCount: count}
+		if len(fields) > 3 {
+			for _, m := range strings.Split(fields[3], ",") {
+				if m != "" {
+					mod.UsedBy = append(mod.UsedBy, m)
+				}
+			}
+		}
+		mods = append(mods, mod)
+	}
+
+	return mods, nil
+}
+
+// KernelModules returns the kernel modules loaded on the host. Only
+// logging is performed if Dryrun is true, in which case nil is
+// returned.
+func (r *LogRun) KernelModules() (_ []KernelModule, err error) {
+	r = r.beginHelper()
+	defer r.addErrorContext(&err, r.commandSeq())
+	out, err := r.query("kernel modules", LsmodCmd)
+	if err != nil || out == "" {
+		return nil, err
+	}
+
+	return ParseLsmod(out)
+}
+
+// KernelModuleLoaded returns true if the kernel module name is loaded
+// on the host. Dashes and underscores in name are equivalent, like
+// they are for modprobe. Only logging is performed if Dryrun is true,
+// in which case false is returned.
+func (r *LogRun) KernelModuleLoaded(name string) (_ bool, err error) {
+	r = r.beginHelper()
+	defer r.addErrorContext(&err, r.commandSeq())
+	mods, err := r.KernelModules()
+	if err != nil {
+		return false, err
+	}
+	name = strings.Replace(name, "-", "_", -1)
+	for _, m := range mods {
+		if m.Name == name {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// LoadKernelModule loads the kernel module name with the optional
+// module parameters, e.g., "max_loop=64", using ModprobeCmd unless it
+// is already loaded. The returned bool is true if the module was
+// loaded and the outcome is recorded as an operation for the
+// Summary(). Only logging is performed if Dryrun is true, in which
+// case the module is reported as loaded.
+func (r *LogRun) LoadKernelModule(name string, params ...string) (_ bool, err error) {
+	r = r.beginHelper()
+	defer r.addErrorContext(&err, r.commandSeq())
+	loaded, err := r.KernelModuleLoaded(name)
+	if err != nil {
+		return false, err
+	}
+	if loaded {
+		r.RecordOperation("LoadKernelModule", name, false)
+		return false, nil
+	}
+	_, stderr, code := r.Run(ModprobeCmd, append([]string{name}, params...)...)
+	if code != 0 {
+		return false, fmt.Errorf("could not load kernel module %s: %s", name, strings.TrimSpace(stderr))
+	}
+	r.RecordOperation("LoadKernelModule", name, true)
+
+	return true, nil
+}
+
+// Virtualization detects the virtualization platform of the host
+// using DetectVirtCmd. Only logging is performed if Dryrun is true,
+// in which case the zero Virtualization is returned.
+func (r *LogRun) Virtualization() (_ Virtualization, err error) {
+	r = r.beginHelper()
+	defer r.addErrorContext(&err, r.commandSeq())
+	r.logRun(DetectVirtCmd)
+	if r.Dryrun {
+		return Virtualization{}, nil
+	}
+
+	// DetectVirtCmd outputs "none" and exits with 1 on bare
+	// metal.
+	stdout, stderr, code := r.run(DetectVirtCmd)
+	virt := Virtualization{Type: strings.TrimSpace(stdout)}
+	if virt.Type == "" || (code != 0 && virt.Type != "none") {
+		return Virtualization{}, fmt.Errorf("could not detect virtualization: %s", strings.TrimSpace(stderr))
+	}
+	if virt.Type != "none" {
+		virt.Container = stringInSlice(virt.Type, ContainerVirtTypes)
+		virt.VM = !virt.Container
+	}
+
+	return virt, nil
+}
+
+func stringInSlice(s string, slice []string) bool {
+	for _, e := range slice {
+		if e == s {
+			return true
+		}
+	}
+
+	return false
+}
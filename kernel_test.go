@@ -0,0 +1,102 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/apatters/go-logrun"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLsmod(t *testing.T) {
+	mods, err := logrun.ParseLsmod(`Module                  Size  Used by
+nf_nat                 40960  2 nf_nat_ipv4,xt_MASQUERADE
+loop                   28672  0
+`)
+	t.Logf("mods = %+v", mods)
+	require.NoError(t, err)
+	assert.Equal(t, []logrun.KernelModule{
+		{Name: "nf_nat", Size: 40960, UseCount: 2, UsedBy: []string{"nf_nat_ipv4", "xt_MASQUERADE"}},
+		{Name: "loop", Size: 28672},
+	}, mods)
+
+	_, err = logrun.ParseLsmod("Module Size Used by\nbad\n")
+	assert.Error(t, err)
+}
+
+// fakeCommand writes an executable shell script named name to dir.
+func fakeCommand(t *testing.T, dir string, name string, script string) string {
+	path := filepath.Join(dir, name)
+	require.NoError(t, ioutil.WriteFile(path, []byte("#!/bin/sh\n"+script), 0755))
+
+	return path
+}
+
+func TestLocalLogRun_LoadKernelModule(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "logrun")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmpDir)
+	origLsmod, origModprobe := logrun.LsmodCmd, logrun.ModprobeCmd
+	defer func() { logrun.LsmodCmd, logrun.ModprobeCmd = origLsmod, origModprobe }()
+	logrun.LsmodCmd = fakeCommand(t, tmpDir, "lsmod", "echo 'Module Size Used by'\necho 'br_netfilter 24576 0'\n")
+	logrun.ModprobeCmd = fakeCommand(t, tmpDir, "modprobe", "echo \"$@\" > "+filepath.Join(tmpDir, "args")+"\n")
+
+	log, out, _ := newLogger()
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{
+		LogFunc: log.Println,
+	})
+	l.SetRecorder(logrun.NewRecorder())
+	loaded, err := l.KernelModuleLoaded("br-netfilter")
+	require.NoError(t, err)
+	assert.True(t, loaded)
+	changed, err := l.LoadKernelModule("br_netfilter")
+	require.NoError(t, err)
+	assert.False(t, changed)
+
+	changed, err = l.LoadKernelModule("loop", "max_loop=64")
+	t.Logf("out = %q", out)
+	require.NoError(t, err)
+	assert.True(t, changed)
+	args, err := ioutil.ReadFile(filepath.Join(tmpDir, "args"))
+	require.NoError(t, err)
+	assert.Equal(t, "loop max_loop=64\n", string(args))
+	s := l.Summary()
+	assert.Equal(t, 1, s.Changed)
+	assert.Equal(t, 1, s.Unchanged)
+}
+
+func TestLocalLogRun_Virtualization(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "logrun")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmpDir)
+	orig := logrun.DetectVirtCmd
+	defer func() { logrun.DetectVirtCmd = orig }()
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{})
+
+	logrun.DetectVirtCmd = fakeCommand(t, tmpDir, "kvm", "echo kvm\n")
+	virt, err := l.Virtualization()
+	require.NoError(t, err)
+	assert.Equal(t, logrun.Virtualization{Type: "kvm", VM: true}, virt)
+
+	logrun.DetectVirtCmd = fakeCommand(t, tmpDir, "docker", "echo docker\n")
+	virt, err = l.Virtualization()
+	require.NoError(t, err)
+	assert.Equal(t, logrun.Virtualization{Type: "docker", Container: true}, virt)
+
+	logrun.DetectVirtCmd = fakeCommand(t, tmpDir, "none", "echo none\nexit 1\n")
+	virt, err = l.Virtualization()
+	require.NoError(t, err)
+	assert.Equal(t, logrun.Virtualization{Type: "none"}, virt)
+
+	logrun.DetectVirtCmd = fakeCommand(t, tmpDir, "broken", "echo failed >&2\nexit 1\n")
+	_, err = l.Virtualization()
+	t.Logf("err = %v", err)
+	assert.Error(t, err)
+}
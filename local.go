@@ -6,8 +6,7 @@ package logrun
 
 import (
 	"io"
-
-	"github.com/apatters/go-run"
+	"time"
 )
 
 // LocalConfig is used to set options in the NewLocalLogRun
@@ -19,10 +18,37 @@ type LocalConfig struct {
 	// LogFunc can also be used.
 	LogFunc LogFunc
 
+	// LogHook, if not nil, receives a structured LogEvent for
+	// every message logged in addition to LogFunc. See
+	// SetLogHook().
+	LogHook LogHook
+
+	// ResultStore, if not nil, persists the Results of the
+	// commands run. See SetResultStore().
+	ResultStore ResultStore
+
+	// ResultLogFunc, if not nil, is used to log the exit code,
+	// duration, and output size of each command once it has
+	// finished. See SetResultLogFunc().
+	ResultLogFunc LogFunc
+
+	// Verbosity selects how much is logged about each command.
+	// See SetVerbosity().
+	Verbosity Verbosity
+
 	// ShellExecutable is the full path to the shell to be run
 	// when executing shell commands.
 	ShellExecutable string
 
+	// RunAs, if not empty, is the user commands are run as. The
+	// commands are started with the user's credentials, which
+	// requires the program to run as root unless RunAs is the
+	// current user. The environment is not changed. File
+	// operations are performed using commands run as RunAs.
+	// Running commands as another user is not supported on
+	// Windows.
+	RunAs string
+
 	// Env specifies the environment of the process.
 	// Each entry is of the form "key=value".
 	// If Env is nil, the new process uses the current process's
@@ -57,26 +83,61 @@ type LocalConfig struct {
 	// Dryrun enables/disables the execution of commands. If
 	// Dryrun is true, the command is only logged.
 	Dryrun bool
+
+	// OutputEncoding specifies how the captured stdout and
+	// stderr are converted to strings. The default, EncodingRaw,
+	// returns the output unmodified.
+	OutputEncoding OutputEncoding
+
+	// NormalizeCRLF converts CRLF line endings in the captured
+	// stdout and stderr to LF line endings if true.
+	NormalizeCRLF bool
+
+	// Timeout is the maximum amount of time a command is allowed
+	// to run before it is killed. Zero means no timeout.
+	Timeout time.Duration
+
+	// Clock is the source of time used for timeouts and
+	// durations. If nil, RealClock is used.
+	Clock Clock
+
+	// StderrClassifier is used to classify the standard error
+	// output of commands. If nil, DefaultStderrClassifier is used.
+	StderrClassifier *StderrClassifier
+
+	// ProbeCapabilities enables the automatic selection of helper
+	// command implementations based on the host's Capabilities.
+	// See SetProbeCapabilities() for why it is disabled by default.
+	ProbeCapabilities bool
+
+	// Vars are the variables of the host referenced by command
+	// templates. See SetVars().
+	Vars map[string]string
+
+	// Tags are the tags of the host used to select it in a Pool.
+	// See SetTags().
+	Tags map[string]string
 }
 
 // NewLocalLogRun is the constructor for LogRun used to log and run a
 // local command.
 func NewLocalLogRun(config LocalConfig) *LogRun {
-	r := new(LogRun)
-	r.Runner = run.NewLocal(run.LocalConfig{
-		ShellExecutable: config.ShellExecutable,
-		Env:             config.Env,
-		Dir:             config.Dir,
-		Stdin:           config.Stdin,
-		Stdout:          config.Stdout,
-		Stderr:          config.Stderr,
+	r := NewLogRun(newLocalRunner(config), LogRunConfig{
+		LogFunc:          config.LogFunc,
+		LogHook:          config.LogHook,
+		ResultStore:      config.ResultStore,
+		ResultLogFunc:    config.ResultLogFunc,
+		Verbosity:        config.Verbosity,
+		Dryrun:           config.Dryrun,
+		OutputEncoding:   config.OutputEncoding,
+		NormalizeCRLF:    config.NormalizeCRLF,
+		Timeout:          config.Timeout,
+		Clock:            config.Clock,
+		StderrClassifier: config.StderrClassifier,
+		Vars:             config.Vars,
+		Tags:             config.Tags,
 	})
-	if config.LogFunc == nil {
-		r.logFunc = DefaultLogFunc
-	} else {
-		r.logFunc = config.LogFunc
-	}
-	r.Dryrun = config.Dryrun
+	r.probeCaps = config.ProbeCapabilities
 
 	return r
 }
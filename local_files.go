@@ -0,0 +1,167 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// The local file operations below use the os and path/filepath
+// packages instead of running FileExistsCmd, DirExistsCmd, GlobCmd,
+// ReadFileCmd, FileModeCmd, and ChmodCmd, so they work on controllers
+// without GNU coreutils and do not depend on the output format of
+// those commands. They log a pseudo-command, e.g., "stat /etc/hosts",
+// describing the operation.
+
+// localRunner returns the Runner of r if it is a local runner that
+// runs commands as the current user, and nil otherwise.
+func (r *LogRun) localRunner() *localRunner {
+	if local, ok := r.Runner.(*localRunner); ok && r.runAs() == "" {
+		return local
+	}
+
+	return nil
+}
+
+// localPath returns p resolved against the working directory of r.
+func (r *LogRun) localPath(local *localRunner, p string) string {
+	dir := local.workDir(&execSpec{dir: r.Dir()})
+	if dir == "" || filepath.IsAbs(p) {
+		return p
+	}
+
+	return filepath.Join(dir, p)
+}
+
+// localStat logs and performs a stat of p following symbolic links.
+// The returned bool is false if p does not exist.
+func (r *LogRun) localStat(local *localRunner, p string) (os.FileInfo, bool, error) {
+	r.log("stat " + p)
+	if r.Dryrun {
+		return nil, true, nil
+	}
+	fi, err := os.Stat(r.localPath(local, p))
+	if os.IsNotExist(err) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, accessError(p, err)
+	}
+
+	return fi, true, nil
+}
+
+// localGlob logs and expands pattern like the shell, i.e., relative
+// patterns are matched in the working directory of r and return
+// relative paths, and hidden files are only matched by patterns
+// starting with a dot.
+func (r *LogRun) localGlob(local *localRunner, pattern string, opts GlobOptions) ([]string, error) {
+	r.log("glob " + pattern)
+	dir := local.workDir(&execSpec{dir: r.Dir()})
+	full := r.localPath(local, pattern)
+	matches, err := filepath.Glob(full)
+	if err != nil {
+		return []string{}, globError(pattern, err)
+	}
+	hidden := strings.HasPrefix(filepath.Base(pattern), ".")
+	results := []globMatch{}
+	for _, m := range matches {
+		if !hidden && strings.HasPrefix(filepath.Base(m), ".") {
+			continue
+		}
+		var match globMatch
+		if opts.Sort == GlobSortModTime || opts.Sort == GlobSortSize {
+			if fi, err := os.Lstat(m); err == nil {
+				match.modTime, match.size = fi.ModTime(), fi.Size()
+			}
+		}
+		if full != pattern {
+			if rel, err := filepath.Rel(dir, m); err == nil {
+				m = rel
+			}
+		}
+		match.path = m
+		results = append(results, match)
+	}
+	if len(results) == 0 {
+		return []string{}, globError(pattern, "no matches")
+	}
+
+	return sortGlobMatches(results, opts), nil
+}
+
+func (r *LogRun) localReadFile(local *localRunner, p string) (string, bool, error) {
+	r.log("get " + p)
+	if r.Dryrun {
+		return "", false, nil
+	}
+	content, err := ioutil.ReadFile(r.localPath(local, p))
+	if os.IsNotExist(err) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("could not read %s: %w", p, err)
+	}
+
+	return string(content), true, nil
+}
+
+func (r *LogRun) localFileMode(local *localRunner, p string) (string, error) {
+	fi, exists, err := r.localStat(local, p)
+	if err != nil {
+		return "", err
+	}
+	if !exists {
+		return "", notFoundError("could not access", p)
+	}
+	if fi == nil {
+		return "", nil
+	}
+
+	return strconv.FormatUint(uint64(fi.Mode().Perm()), 8), nil
+}
+
+func (r *LogRun) localChmod(local *localRunner, p string, mode os.FileMode) error {
+	r.log(fmt.Sprintf("chmod %s %s", strconv.FormatUint(uint64(mode.Perm()), 8), p))
+	if err := os.Chmod(r.localPath(local, p), mode.Perm()); err != nil {
+		return fmt.Errorf("could not change mode of %s: %w", p, err)
+	}
+
+	return nil
+}
+
+// localWriteFile writes content to a temporary file in the directory
+// of p which is then renamed, so readers never see a partially
+// written file. The temporary file has a random name and is created
+// exclusively, so concurrent writers and planted symbolic links are
+// not written through.
+func (r *LogRun) localWriteFile(local *localRunner, p string, content string, mode os.FileMode) error {
+	full := r.localPath(local, p)
+	f, err := ioutil.TempFile(filepath.Dir(full), "."+filepath.Base(full)+".*")
+	if err != nil {
+		return fmt.Errorf("could not write %s: %w", p, err)
+	}
+	_, err = f.WriteString(content)
+	if err == nil {
+		err = f.Chmod(mode.Perm())
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), full)
+	}
+	if err != nil {
+		os.Remove(f.Name()) // nolint: errcheck
+		return fmt.Errorf("could not write %s: %w", p, err)
+	}
+
+	return nil
+}
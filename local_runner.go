@@ -0,0 +1,241 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// localRunner runs commands on the local host using os/exec. It
+// implements Runner so it can be used as a LogRun Runner.
+type localRunner struct {
+	shellExecutable string
+	runAs           string
+	env             []string
+	dir             string
+	stdin           io.Reader
+	stdout          io.Writer
+	stderr          io.Writer
+
+	// host, if not nil, is the host commands are attributed to
+	// instead of the local host, e.g., the remote host rsync
+	// copies to or from.
+	host hostIdentifier
+}
+
+func newLocalRunner(config LocalConfig) *localRunner {
+	l := &localRunner{
+		shellExecutable: config.ShellExecutable,
+		runAs:           config.RunAs,
+		env:             config.Env,
+		dir:             config.Dir,
+		stdin:           config.Stdin,
+		stdout:          config.Stdout,
+		stderr:          config.Stderr,
+	}
+	if l.shellExecutable == "" {
+		l.shellExecutable = localShellExecutable()
+	}
+
+	return l
+}
+
+func (l *localRunner) execute(spec *execSpec) (string, string, int, error) {
+	var cmd *exec.Cmd
+	if spec.shell {
+		cmd = exec.Command(l.shellExecutable, shellOption(l.shellExecutable), spec.cmd)
+	} else {
+		cmd = exec.Command(spec.cmd, spec.args...)
+	}
+	cmd.Env = l.env
+	if len(spec.env) > 0 {
+		if cmd.Env == nil {
+			cmd.Env = os.Environ()
+		}
+		cmd.Env = appendEnv(cmd.Env, spec.env)
+	}
+	cmd.Dir = l.workDir(spec)
+	// Only commands killed when ctx is done, along with any of
+	// their children, get their own process group. Others stay in
+	// the foreground process group of the terminal, if any, so
+	// they can read from it and receive its signals.
+	group := spec.ctx.Done() != nil && !spec.shutdownCtx
+	if group {
+		setProcessGroup(cmd)
+	}
+	if user := runAsUser(spec, l.runAs); user != "" {
+		if err := setCredential(cmd, user); err != nil {
+			return "", "", 0, err
+		}
+	}
+
+	// Hook up standard files.
+	var stdoutBuf, stderrBuf bytes.Buffer
+	cmd.Stdin = l.stdin
+	if spec.stdin != nil {
+		cmd.Stdin = spec.stdin
+	}
+	cmd.Stdout = l.stdout
+	if spec.capture {
+		cmd.Stdout = nil
+	}
+	if spec.stdout != nil {
+		cmd.Stdout = spec.stdout
+	}
+	if cmd.Stdout == nil {
+		cmd.Stdout = &stdoutBuf
+	}
+	cmd.Stderr = l.stderr
+	if spec.capture {
+		cmd.Stderr = nil
+	}
+	if spec.stderr != nil {
+		cmd.Stderr = spec.stderr
+	}
+	if cmd.Stderr == nil {
+		cmd.Stderr = &stderrBuf
+	}
+
+	// Run the command, killing it and any of its children if
+	// the context is done first.
+	if err := cmd.Start(); err != nil {
+		return "", "", 0, err
+	}
+	if spec.pidFile != "" {
+		pid := strconv.Itoa(cmd.Process.Pid) + "\n"
+		if err := ioutil.WriteFile(spec.pidFile, []byte(pid), 0644); err != nil {
+			killCommand(cmd, group)
+			cmd.Wait() // nolint: errcheck
+			return "", "", 0, err
+		}
+	}
+	if spec.onStart != nil {
+		spec.onStart(cmd.Process.Pid)
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+	var err error
+	select {
+	case err = <-done:
+	case <-spec.ctx.Done():
+		killCommand(cmd, group)
+		<-done
+		return "", "", 0, spec.ctx.Err()
+	}
+
+	code := 0
+	if err != nil {
+		exitErr, ok := err.(*exec.ExitError)
+		if !ok {
+			return "", "", 0, err
+		}
+		code = exitErr.ExitCode()
+		if code < 0 {
+			// Killed by a signal.
+			return "", "", 0, err
+		}
+	}
+
+	return stdoutBuf.String(), stderrBuf.String(), code, nil
+}
+
+// killCommand kills the process started by cmd and, if group is true,
+// the other processes of its process group.
+func killCommand(cmd *exec.Cmd, group bool) {
+	if group {
+		killProcessGroup(cmd)
+		return
+	}
+	if cmd.Process != nil {
+		_ = cmd.Process.Kill()
+	}
+}
+
+// workDir returns the working directory of the command described by
+// spec.
+func (l *localRunner) workDir(spec *execSpec) string {
+	switch {
+	case spec.dir == "":
+		return l.dir
+	case filepath.IsAbs(spec.dir) || l.dir == "":
+		return spec.dir
+	}
+
+	return filepath.Join(l.dir, spec.dir)
+}
+
+// format returns a string representation of the command described by
+// spec. Commands with a working directory are prefixed with a cd
+// command and commands run as another user with "(as USER)".
+func (l *localRunner) format(spec *execSpec) string {
+	var s string
+	switch {
+	case isCmdShell(l.shellExecutable) && spec.shell:
+		s = fmt.Sprintf(`%s %s "%s"`, l.shellExecutable, shellOption(l.shellExecutable), spec.cmd)
+	case isCmdShell(l.shellExecutable):
+		words := []string{cmdQuote(spec.cmd)}
+		for _, arg := range spec.args {
+			words = append(words, cmdQuote(arg))
+		}
+		s = strings.Join(words, " ")
+	case spec.shell:
+		s = fmt.Sprintf(`%s -c %s`, l.shellExecutable, shellCommandArg(spec.cmd))
+	default:
+		s = shellCommandLine(spec.cmd, spec.args)
+	}
+	if len(spec.env) > 0 {
+		var vars []string
+		for _, v := range maskEnv(spec.env) {
+			vars = append(vars, shellQuote(v))
+		}
+		s = strings.Join(vars, " ") + " " + s
+	}
+	if spec.dir != "" {
+		s = fmt.Sprintf("cd %s && %s", shellQuote(spec.dir), s)
+	}
+	if user := runAsUser(spec, l.runAs); user != "" {
+		s = fmt.Sprintf("(as %s) %s", user, s)
+	}
+
+	return s
+}
+
+// Run runs a command like glibc's exec() call. It returns the
+// standard out, standard error, and exit code of the command when it
+// completes.
+func (l *localRunner) Run(cmd string, args ...string) (string, string, int, error) {
+	return l.execute(&execSpec{ctx: context.Background(), cmd: cmd, args: args})
+}
+
+// FormatRun returns a string representation of the what command would
+// be run using Run(). Useful for logging commands.
+func (l *localRunner) FormatRun(cmd string, args ...string) string {
+	return l.format(&execSpec{cmd: cmd, args: args})
+}
+
+// Shell runs a command in a shell. The command is passed to the shell
+// as the -c option, so just about any shell code that can be used on
+// the command-line will be passed to it. It returns the standard out,
+// standard error, and exit code of the command when it completes.
+func (l *localRunner) Shell(cmd string) (string, string, int, error) {
+	return l.execute(&execSpec{ctx: context.Background(), cmd: cmd, shell: true})
+}
+
+// FormatShell returns a string representation of the what command
+// would be run using Shell(). Useful for logging commands.
+func (l *localRunner) FormatShell(cmd string) string {
+	return l.format(&execSpec{cmd: cmd, shell: true})
+}
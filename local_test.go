@@ -7,6 +7,7 @@ package logrun_test
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -15,6 +16,7 @@ import (
 	"regexp"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/apatters/go-logrun"
 	"github.com/stretchr/testify/assert"
@@ -92,7 +94,7 @@ func runLocalFileExistsTest(t *testing.T, e existsTestEntry) {
 	t.Logf("out = %q", out)
 	t.Logf("errOut = %q", errOut)
 	assert.EqualValues(t, e.ExpectedResult, exists)
-	assert.EqualValues(t, "/usr/bin/stat --dereference --format %n:%F "+e.Path+"\n", out.String())
+	assert.EqualValues(t, "stat "+e.Path+"\n", out.String())
 	assert.Empty(t, errOut.String())
 }
 
@@ -110,7 +112,7 @@ func runLocalDirExistsTest(t *testing.T, e existsTestEntry) {
 	t.Logf("out = %q", out)
 	t.Logf("errOut = %q", errOut)
 	assert.EqualValues(t, e.ExpectedResult, exists)
-	assert.EqualValues(t, "/usr/bin/stat --dereference --format %n:%F "+e.Path+"\n", out.String())
+	assert.EqualValues(t, "stat "+e.Path+"\n", out.String())
 	assert.Empty(t, errOut.String())
 }
 
@@ -136,7 +138,7 @@ func runLocalGlobTest(t *testing.T, e globTestEntry) {
 		assert.NoError(t, err)
 	}
 	assert.EqualValues(t, results, e.ExpectedPaths)
-	assert.EqualValues(t, "/bin/sh -c \"/bin/ls -1 --directory "+e.Glob+"\"\n", out.String())
+	assert.EqualValues(t, "glob "+e.Glob+"\n", out.String())
 	assert.Empty(t, errOut.String())
 }
 
@@ -163,7 +165,7 @@ func runLocalRsyncTest(t *testing.T, e rsyncTestEntry) {
 	t.Logf("err = %v", err)
 	t.Logf("out = %q", out)
 	t.Logf("errOut = %q", errOut)
-	assert.EqualValues(t, "/usr/bin/rsync --rsh ssh -q -o StrictHostKeyChecking=no -o UserKnownHostsFile=/dev/null -o GlobalKnownHostsFile=/dev/null --recursive --links --times "+e.SrcPath+" "+destDir+"/\n", out.String())
+	assert.EqualValues(t, "/usr/bin/rsync --rsh 'ssh -q -o StrictHostKeyChecking=no -o UserKnownHostsFile=/dev/null -o GlobalKnownHostsFile=/dev/null' --recursive --links --times "+e.SrcPath+" "+destDir+"/\n", out.String())
 	assert.Empty(t, errOut.String())
 	if e.ExpectError {
 		require.Error(t, err)
@@ -363,7 +365,7 @@ func TestLocalLogRun_RunExit(t *testing.T) {
 	assert.Empty(t, stdout)
 	assert.Empty(t, stderr)
 	assert.Equal(t, code, 6)
-	assert.EqualValues(t, "/bin/sh -c exit 6\n", out.String())
+	assert.EqualValues(t, "/bin/sh -c 'exit 6'\n", out.String())
 	assert.Empty(t, errOut.String())
 }
 
@@ -410,7 +412,7 @@ func TestLocalLogRun_RunStdin(t *testing.T) {
 	assert.Equal(t, strings.ToLower(stdinStr), stdout)
 	assert.Empty(t, stderr)
 	assert.Zero(t, code)
-	assert.EqualValues(t, "/usr/bin/tr [:upper:] [:lower:]\n", out.String())
+	assert.EqualValues(t, "/usr/bin/tr '[:upper:]' '[:lower:]'\n", out.String())
 	assert.Empty(t, errOut.String())
 }
 
@@ -725,6 +727,41 @@ func TestLocalLogRum_Glob(t *testing.T) {
 	}
 }
 
+func TestLocalLogRun_GlobDir(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "logrun")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmpDir) // nolint: errcheck
+	for _, name := range []string{"a.conf", "b.conf", ".hidden.conf"} {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(tmpDir, name), nil, 0644))
+	}
+
+	log, out, errOut := newLogger()
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{
+		LogFunc: log.Println,
+	})
+	l.PushDir(tmpDir)
+	results, err := l.Glob("*.conf")
+	t.Logf("err = %v", err)
+	t.Logf("results = %q", results)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a.conf", "b.conf"}, results)
+
+	results, err = l.Glob(".*.conf")
+	t.Logf("err = %v", err)
+	t.Logf("results = %q", results)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{".hidden.conf"}, results)
+
+	exists, err := l.FileExists("a.conf")
+	t.Logf("exists = %t", exists)
+	assert.NoError(t, err)
+	assert.True(t, exists)
+	t.Logf("out = %q", out)
+	t.Logf("errOut = %q", errOut)
+	assert.Equal(t, "glob *.conf\nglob .*.conf\nstat a.conf\n", out.String())
+	assert.Empty(t, errOut.String())
+}
+
 func TestLocalLogRun_Rsync(t *testing.T) {
 	for _, entry := range localRsyncTestTable {
 		runLocalRsyncTest(t, entry)
@@ -772,3 +809,130 @@ func TestLocalLogRun_ShellDryrun(t *testing.T) {
 	assert.EqualValues(t, "/bin/sh -c \"/bin/false\"\n", out.String())
 	assert.Empty(t, errOut.String())
 }
+
+func TestLocalLogRun_RunTimeout(t *testing.T) {
+	log, out, errOut := newLogger()
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{
+		LogFunc: log.Println,
+		Timeout: 100 * time.Millisecond,
+	})
+	start := time.Now()
+	stdout, stderr, code := l.Run("/bin/sleep", "5")
+	elapsed := time.Since(start)
+
+	t.Logf("stdout %q", stdout)
+	t.Logf("stderr %q", stderr)
+	t.Logf("code %d", code)
+	t.Logf("elapsed %s", elapsed)
+	t.Logf("out = %q", out)
+	t.Logf("errOut = %q", errOut)
+
+	assert.Empty(t, stdout)
+	assert.EqualValues(t, "command timed out after 100ms", stderr)
+	assert.Equal(t, logrun.ExitErrorExecute, code)
+	assert.True(t, elapsed < 5*time.Second)
+	assert.EqualValues(t, "/bin/sleep 5\n", out.String())
+	assert.Empty(t, errOut.String())
+}
+
+func TestLocalLogRun_ShellTimeout(t *testing.T) {
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{})
+	l.SetTimeout(100 * time.Millisecond)
+
+	// The backgrounded sleep holds stdout open, so the shell
+	// returns only if its whole process group is killed.
+	start := time.Now()
+	stdout, stderr, code := l.Shell("/bin/sleep 5 & wait")
+	elapsed := time.Since(start)
+
+	t.Logf("stdout %q", stdout)
+	t.Logf("stderr %q", stderr)
+	t.Logf("code %d", code)
+	t.Logf("elapsed %s", elapsed)
+
+	assert.Empty(t, stdout)
+	assert.EqualValues(t, "command timed out after 100ms", stderr)
+	assert.Equal(t, logrun.ExitErrorExecute, code)
+	assert.True(t, elapsed < 5*time.Second)
+
+	l.SetTimeout(5 * time.Second)
+	stdout, stderr, code = l.Shell("echo hello")
+	assert.Equal(t, "hello\n", stdout)
+	assert.Empty(t, stderr)
+	assert.Zero(t, code)
+}
+
+func TestLocalLogRun_RunContext(t *testing.T) {
+	log, out, _ := newLogger()
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{
+		LogFunc: log.Println,
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		cancel()
+	}()
+	start := time.Now()
+	stdout, stderr, code := l.RunContext(ctx, "/bin/sleep", "5")
+	elapsed := time.Since(start)
+
+	t.Logf("stdout %q", stdout)
+	t.Logf("stderr %q", stderr)
+	t.Logf("code %d", code)
+	t.Logf("elapsed %s", elapsed)
+	t.Logf("out = %q", out)
+
+	assert.Empty(t, stdout)
+	assert.EqualValues(t, context.Canceled.Error(), stderr)
+	assert.Equal(t, logrun.ExitErrorExecute, code)
+	assert.True(t, elapsed < 5*time.Second)
+	assert.EqualValues(t, "/bin/sleep 5\n", out.String())
+
+	stdout, _, code = l.RunContext(context.Background(), "/bin/echo", "hello")
+	assert.Equal(t, "hello\n", stdout)
+	assert.Zero(t, code)
+}
+
+func TestLocalLogRun_ProcessGroup(t *testing.T) {
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{})
+	const pgid = "ps -o pgid= -p $$"
+	parent, _, code := l.Shell(fmt.Sprintf("ps -o pgid= -p %d", os.Getpid()))
+	require.Zero(t, code)
+
+	// Plain commands stay in the process group of the caller so
+	// they can read from its terminal.
+	stdout, _, code := l.Shell(pgid)
+	require.Zero(t, code)
+	assert.Equal(t, parent, stdout)
+
+	// Commands that are killed when ctx is done get their own
+	// process group so their children are killed too.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stdout, _, code = l.ShellContext(ctx, pgid)
+	require.Zero(t, code)
+	assert.NotEqual(t, parent, stdout)
+	stdout, _, code = l.With(logrun.WithTimeout(time.Minute)).Shell(pgid)
+	require.Zero(t, code)
+	assert.NotEqual(t, parent, stdout)
+}
+
+func TestLocalLogRun_ShellContext(t *testing.T) {
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{})
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	stdout, stderr, code := l.ShellContext(ctx, "/bin/sleep 5 & wait")
+	elapsed := time.Since(start)
+
+	t.Logf("stdout %q", stdout)
+	t.Logf("stderr %q", stderr)
+	t.Logf("code %d", code)
+	t.Logf("elapsed %s", elapsed)
+
+	assert.Empty(t, stdout)
+	assert.EqualValues(t, context.DeadlineExceeded.Error(), stderr)
+	assert.Equal(t, logrun.ExitErrorExecute, code)
+	assert.True(t, elapsed < 5*time.Second)
+}
@@ -0,0 +1,204 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun
+
+import (
+	"io"
+	"strings"
+	"time"
+)
+
+// LogEvent describes a message logged by a LogRun, e.g., a command
+// about to be run, with its parts available as separate fields so it
+// can be passed on to structured loggers such as logrus, zap, or
+// slog.
+type LogEvent struct {
+	// Message is the preformatted message passed to the LogFunc,
+	// without the indentation added for sections.
+	Message string
+
+	// Host identifies the host the command is run on.
+	Host string
+
+	// Section is the current section as returned by Section().
+	Section string
+
+	// Command and Args are the command and its arguments. For shell
+	// commands, Shell is true and Command is the shell command
+	// line. Command is empty for messages that do not describe a
+	// command, e.g., section headings.
+	Command string
+	Args    []string
+	Shell   bool
+
+	// Dryrun is true if the command is only logged.
+	Dryrun bool
+
+	// Skipped is true if the command is skipped because of a guard
+	// set by OnlyIf() or Unless().
+	Skipped bool
+
+	// SkipReason is why the command is not run, if it is not,
+	// e.g., SkipDryrun.
+	SkipReason SkipReason
+
+	// Silent is true if the message was not passed to the LogFunc
+	// because of WithSilent().
+	Silent bool
+
+	// StartTime is the time the message was logged, i.e., just
+	// before the command is run.
+	StartTime time.Time
+}
+
+// LogHook receives the structured form of every message logged by a
+// LogRun. It is called in addition to the LogFunc.
+type LogHook interface {
+	LogEvent(e LogEvent)
+}
+
+// LogHookFunc adapts an ordinary function to a LogHook.
+type LogHookFunc func(e LogEvent)
+
+// LogEvent calls f(e).
+func (f LogHookFunc) LogEvent(e LogEvent) {
+	f(e)
+}
+
+// SetLogHook sets the hook that receives a LogEvent for every message
+// logged. Set it to nil, the default, to only use the LogFunc, e.g.,
+//
+//	runner.SetLogFunc(logrun.DiscardLogFunc)
+//	runner.SetLogHook(logrun.LogHookFunc(func(e logrun.LogEvent) {
+//		logrus.WithFields(logrus.Fields{
+//			"host":    e.Host,
+//			"command": e.Command,
+//			"args":    e.Args,
+//		}).Info(e.Message)
+//	}))
+func (r *LogRun) SetLogHook(h LogHook) {
+	r.logHook = h
+}
+
+// log logs msg indented by the current section nesting level.
+func (r *LogRun) log(msg string) {
+	r.logEvent(LogEvent{Message: msg})
+}
+
+// logRun logs running cmd with args.
+func (r *LogRun) logRun(cmd string, args ...string) {
+	r.logEvent(LogEvent{Message: r.formatRun(cmd, args...), Command: cmd, Args: args})
+}
+
+// logShell logs running cmd in a shell.
+func (r *LogRun) logShell(cmd string) {
+	r.logEvent(LogEvent{Message: r.formatShell(cmd), Command: cmd, Shell: true})
+}
+
+// logChangeRun logs running cmd with args, which changes the host.
+func (r *LogRun) logChangeRun(cmd string, args ...string) {
+	spec := execSpec{cmd: cmd, args: args}
+	r.logChangeEvent(spec, LogEvent{Message: r.format(spec), Command: cmd, Args: args})
+}
+
+// logChangeShell logs running cmd in a shell, which changes the host.
+func (r *LogRun) logChangeShell(cmd string) {
+	spec := execSpec{cmd: cmd, shell: true}
+	r.logChangeEvent(spec, LogEvent{Message: r.format(spec), Command: cmd, Shell: true})
+}
+
+// logChange logs msg, which describes a change of the host that is
+// made without running the command described by spec, e.g., using
+// SFTP.
+func (r *LogRun) logChange(spec execSpec, msg string) {
+	r.logChangeEvent(spec, LogEvent{Message: msg})
+}
+
+// logChangeEvent logs e, which describes a change of the host made by
+// a helper. In dryrun mode, the command described by spec, which makes
+// the change, is added to the DryrunPlan.
+func (r *LogRun) logChangeEvent(spec execSpec, e LogEvent) {
+	if r.planning() {
+		r.planCommand(spec, e.Message)
+	}
+	r.emitEvent(e)
+}
+
+// logSpec logs msg, the formatted form of spec, which is not run if
+// reason is not SkipNone.
+func (r *LogRun) logSpec(spec execSpec, msg string, reason SkipReason) {
+	if r.planning() && reason == SkipDryrun {
+		r.planCommand(spec, msg)
+	} else if r.planning() {
+		r.planComment(msg)
+	}
+	r.emitEvent(LogEvent{
+		Message:    msg,
+		Command:    spec.cmd,
+		Args:       spec.args,
+		Shell:      spec.shell,
+		Skipped:    reason == SkipGuard,
+		SkipReason: reason,
+	})
+}
+
+// logEvent passes e.Message to the LogFunc and, if there is a
+// LogHook, fills in the fields of e common to all messages and passes
+// it to the hook.
+func (r *LogRun) logEvent(e LogEvent) {
+	if r.planning() {
+		r.planComment(e.Message)
+	}
+	r.emitEvent(e)
+}
+
+// emitEvent passes e to the LogFunc and LogHook without adding it to
+// the DryrunPlan.
+func (r *LogRun) emitEvent(e LogEvent) {
+	if !r.call.silent {
+		r.logFunc(strings.Repeat(SectionIndent, len(r.sections)) + e.Message)
+	}
+	if r.logHook == nil {
+		return
+	}
+	e.Silent = r.call.silent
+	e.Host = r.Host().String()
+	e.Section = r.Section()
+	e.Dryrun = r.Dryrun
+	e.StartTime = r.getClock().Now()
+	r.logHook.LogEvent(e)
+}
+
+// logResult logs the outcome of the command msg using the
+// ResultLogFunc.
+func (r *LogRun) logResult(msg string, code int, d time.Duration, stdoutBytes, stderrBytes int64, err error) {
+	if r.call.silent {
+		return
+	}
+	if err != nil {
+		msg = trf("%s: failed in %s: %s", msg, d, err)
+	} else {
+		msg = trf("%s: exit code %d in %s (stdout %d bytes, stderr %d bytes)",
+			msg, code, d, stdoutBytes, stderrBytes)
+	}
+	r.resultLogFunc(strings.Repeat(SectionIndent, len(r.sections)) + msg)
+}
+
+// byteCounter counts the bytes written through a countingWriter.
+type byteCounter int64
+
+// countingWriter passes writes on to w and adds the number of bytes
+// written to n.
+type countingWriter struct {
+	w io.Writer
+	n *byteCounter
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	*c.n += byteCounter(n)
+
+	return n, err
+}
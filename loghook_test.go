@@ -0,0 +1,121 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apatters/go-logrun"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalLogRun_SetLogHook(t *testing.T) {
+	log, out, _ := newLogger()
+	var events []logrun.LogEvent
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{
+		LogFunc: log.Println,
+		LogHook: logrun.LogHookFunc(func(e logrun.LogEvent) {
+			events = append(events, e)
+		}),
+	})
+	start := time.Date(2019, 1, 2, 3, 4, 5, 0, time.UTC)
+	l.SetClock(logrun.NewFakeClock(start))
+
+	l.BeginSection("deploy")
+	_, _, code := l.Run("/bin/echo", "hello", "world")
+	require.Zero(t, code)
+	_, _, code = l.Shell("true")
+	require.Zero(t, code)
+	require.NoError(t, l.EndSection())
+	for i, e := range events {
+		t.Logf("events[%d] = %+v", i, e)
+	}
+	t.Logf("out = %q", out)
+	require.Len(t, events, 3)
+
+	assert.Equal(t, "=== deploy", events[0].Message)
+	assert.Empty(t, events[0].Command)
+
+	assert.Equal(t, "/bin/echo hello world", events[1].Message)
+	assert.Equal(t, "/bin/echo", events[1].Command)
+	assert.Equal(t, []string{"hello", "world"}, events[1].Args)
+	assert.False(t, events[1].Shell)
+	assert.Equal(t, "deploy", events[1].Section)
+	assert.Equal(t, l.Host().String(), events[1].Host)
+	assert.Equal(t, start, events[1].StartTime)
+	assert.False(t, events[1].Dryrun)
+
+	assert.Equal(t, "true", events[2].Command)
+	assert.True(t, events[2].Shell)
+
+	// The LogFunc receives the same messages.
+	assert.Equal(t, "=== deploy\n  /bin/echo hello world\n  /bin/sh -c \"true\"\n", out.String())
+}
+
+func TestLocalLogRun_SetLogHookDryrun(t *testing.T) {
+	var events []logrun.LogEvent
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{})
+	l.SetLogHook(logrun.LogHookFunc(func(e logrun.LogEvent) {
+		events = append(events, e)
+	}))
+	l.SetDryrun(true)
+	l.Run("/bin/rm", "-rf", "/tmp/x")
+	l.With(logrun.OnlyIf("false")).Run("/bin/echo")
+	for i, e := range events {
+		t.Logf("events[%d] = %+v", i, e)
+	}
+	require.NotEmpty(t, events)
+	assert.True(t, events[0].Dryrun)
+	assert.Equal(t, logrun.SkipDryrun, events[0].SkipReason)
+	assert.Equal(t, []string{"-rf", "/tmp/x"}, events[0].Args)
+
+	events = nil
+	l.SetDryrun(false)
+	l.With(logrun.OnlyIf("false")).Run("/bin/echo")
+	for i, e := range events {
+		t.Logf("events[%d] = %+v", i, e)
+	}
+	require.Len(t, events, 2)
+	assert.Equal(t, "false", events[0].Command)
+	assert.True(t, events[0].Shell)
+	assert.Equal(t, logrun.SkipNone, events[0].SkipReason)
+	assert.True(t, events[1].Skipped)
+	assert.Equal(t, logrun.SkipGuard, events[1].SkipReason)
+	assert.Equal(t, "/bin/echo", events[1].Command)
+}
+
+func TestLocalLogRun_SetResultLogFunc(t *testing.T) {
+	log, out, _ := newLogger()
+	resultLog, results, _ := newLogger()
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{
+		LogFunc:       log.Println,
+		ResultLogFunc: resultLog.Println,
+	})
+	l.SetClock(logrun.NewFakeClock(time.Date(2019, 1, 2, 3, 4, 5, 0, time.UTC)))
+	_, _, code := l.Shell("echo hello; echo oops >&2; exit 2")
+	require.Equal(t, 2, code)
+	var buf bytes.Buffer
+	_, _, code = l.With(logrun.WithStdout(&buf)).Run("/bin/echo", "abc")
+	require.Zero(t, code)
+	_, _, code = l.Run("/does/not/exist")
+	require.NotZero(t, code)
+	t.Logf("out = %q", out)
+	t.Logf("results = %q", results)
+	resultLines := strings.Split(strings.TrimSpace(results.String()), "\n")
+	require.Len(t, resultLines, 3)
+	assert.Equal(t, `/bin/sh -c "echo hello; echo oops >&2; exit 2": exit code 2 in 0s (stdout 6 bytes, stderr 5 bytes)`, resultLines[0])
+	assert.Equal(t, `/bin/echo abc: exit code 0 in 0s (stdout 4 bytes, stderr 0 bytes)`, resultLines[1])
+	assert.True(t, strings.HasPrefix(resultLines[2], "/does/not/exist: failed in 0s: "))
+
+	// Dryrun commands are not run so no result is logged.
+	results.Reset()
+	l.SetDryrun(true)
+	l.Run("/bin/echo")
+	assert.Empty(t, results.String())
+}
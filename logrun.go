@@ -11,10 +11,12 @@ codes. Commands are logged using a specified logging function.
 package logrun
 
 import (
+	"bytes"
+	"context"
 	"fmt"
+	"io"
 	"strings"
-
-	"github.com/apatters/go-run"
+	"time"
 )
 
 var (
@@ -75,7 +77,9 @@ var (
 	// RsyncCmdOptions are the command-line options added to
 	// RsyncCmd used to opy a directory or file to or from a local
 	// or remote destination. This command and options has been
-	// tested on RHEL/CentOS 7 and Ubuntu 18.04.
+	// tested on RHEL/CentOS 7 and Ubuntu 18.04. The --rsh option
+	// is replaced when copying to or from remote LogRuns, see
+	// Rsync().
 	RsyncCmdOptions = []string{
 		"--rsh",
 		"ssh -q -o StrictHostKeyChecking=no -o UserKnownHostsFile=/dev/null -o GlobalKnownHostsFile=/dev/null",
@@ -83,6 +87,51 @@ var (
 		"--links",
 		"--times",
 	}
+
+	// RemoteKillCmd is the shell command run on a remote host to
+	// kill a command that has timed out. It is also used by
+	// DetachedJob.Stop(). The %[1]d verbs are replaced with the
+	// process ID of the command, which is also its process group
+	// ID. This command has been tested on RHEL/CentOS 7 and Ubuntu
+	// 18.04.
+	RemoteKillCmd = "kill -TERM -- -%[1]d 2>/dev/null || kill -TERM %[1]d"
+
+	// ReadFileCmd is the external command used to read the
+	// contents of a file. This command has been tested on
+	// RHEL/CentOS 7 and Ubuntu 18.04.
+	ReadFileCmd = "/bin/cat"
+
+	// FileModeCmd is the external command used to determine the
+	// permission bits of a file. This command has been tested on
+	// RHEL/CentOS 7 and Ubuntu 18.04.
+	FileModeCmd = "/usr/bin/stat"
+
+	// FileModeCmdOptions are the command-line options added to
+	// FileModeCmd used to output the permission bits of a file in
+	// octal. This command and options has been tested on
+	// RHEL/CentOS 7 and Ubuntu 18.04.
+	FileModeCmdOptions = []string{
+		"--dereference",
+		"--format",
+		"%a",
+	}
+
+	// ChmodCmd is the external command used to change the
+	// permission bits of a file. This command has been tested on
+	// RHEL/CentOS 7 and Ubuntu 18.04.
+	ChmodCmd = "/bin/chmod"
+
+	// RemoveFileCmd is the external command used to remove a
+	// file. This command has been tested on RHEL/CentOS 7 and
+	// Ubuntu 18.04.
+	RemoveFileCmd = "/bin/rm"
+
+	// RemoveFileCmdOptions are the command-line options added to
+	// RemoveFileCmd. This command and options has been tested on
+	// RHEL/CentOS 7 and Ubuntu 18.04.
+	RemoveFileCmdOptions = []string{
+		"-f",
+	}
 )
 
 // LogFunc is the type for the function that will be called to log the
@@ -97,9 +146,40 @@ func DiscardLogFunc(v ...interface{}) {
 // LogRun encapsulates a logger used to log and run and either a local
 // or remote command.
 type LogRun struct {
-	Runner  run.Runner
+	Runner  Runner
 	logFunc LogFunc
+	logHook LogHook
 	Dryrun  bool
+
+	resultLogFunc LogFunc
+	verbosity     Verbosity
+
+	outputEncoding OutputEncoding
+	normalizeCRLF  bool
+	timeout        time.Duration
+	clock          Clock
+
+	stderrClassifier *StderrClassifier
+	caps             *capsCache
+	probeCaps        bool
+	call             callOptions
+	dirs             []string
+	check            *ChangeReport
+	plan             *DryrunPlan
+	pushCache        *PushCache
+	undo             *undoStack
+	sections         []string
+	recorder         *Recorder
+	transferHook     TransferHook
+	vars             map[string]string
+	groupVars        map[string]string
+	tags             map[string]string
+	handlers         *handlerSet
+	shutdown         *shutdownState
+	errCtx           *errorContextState
+	helper           *helperCall
+	approval         *approvalGate
+	resultStore      ResultStore
 }
 
 // SetLogFunc is used to set the logging function used to log a
@@ -109,52 +189,133 @@ func (r *LogRun) SetLogFunc(f LogFunc) {
 	r.logFunc = f
 }
 
+// SetResultLogFunc sets the logging function used to log the outcome
+// of a command once it has finished: its exit code, how long it took,
+// and the number of bytes it wrote to stdout and stderr, e.g.,
+//
+//	/bin/ls /tmp: exit code 0 in 2.1ms (stdout 120 bytes, stderr 0 bytes)
+//
+// Commands that could not be run are logged with the error instead of
+// an exit code. Output sent to files by WithStdoutFile() or to the
+// Stdout and Stderr writers the LogRun was constructed with is not
+// counted. Set it to nil, the default, to disable these messages.
+func (r *LogRun) SetResultLogFunc(f LogFunc) {
+	r.resultLogFunc = f
+}
+
 // SetDryrun enables/disables the execution of commands. If Dryrun is
 // true, the command is only logged.
 func (r *LogRun) SetDryrun(dryrun bool) {
 	r.Dryrun = dryrun
 }
 
+// SetOutputEncoding sets the encoding used to convert the captured
+// stdout and stderr of commands into strings. The default is
+// EncodingRaw which returns the output unmodified.
+func (r *LogRun) SetOutputEncoding(encoding OutputEncoding) {
+	r.outputEncoding = encoding
+}
+
+// SetNormalizeCRLF enables/disables the conversion of CRLF line
+// endings in the captured stdout and stderr of commands to LF line
+// endings. Useful for output that originates from Windows hosts.
+func (r *LogRun) SetNormalizeCRLF(normalize bool) {
+	r.normalizeCRLF = normalize
+}
+
+// SetTimeout sets the maximum amount of time a command is allowed to
+// run. Commands that time out are killed along with any processes
+// they started, including on remote hosts. A timeout of zero, the
+// default, disables timeouts.
+func (r *LogRun) SetTimeout(timeout time.Duration) {
+	r.timeout = timeout
+}
+
+// SetClock sets the Clock used for timeouts and durations. The
+// default is RealClock. Tests typically use a FakeClock.
+func (r *LogRun) SetClock(clock Clock) {
+	r.clock = clock
+}
+
+// Close releases the resources held by the Runner, e.g., the shared
+// SSH connection of a remote LogRun. Commands still running are
+// allowed to complete. A later command reconnects. Remote LogRuns
+// should be closed when they are no longer needed.
+func (r *LogRun) Close() error {
+	if c, ok := r.Runner.(io.Closer); ok {
+		return c.Close()
+	}
+
+	return nil
+}
+
 // Run first logs the command and then runs the command. Only logging
 // is performed if DryRun is true.
 func (r *LogRun) Run(cmd string, args ...string) (string, string, int) {
-	msg := r.Runner.FormatRun(cmd, args...)
-	r.logFunc(msg)
-	if r.Dryrun {
-		return "", "", ExitOK
-	}
+	return r.logAndRun(execSpec{cmd: cmd, args: args})
+}
 
-	return r.run(cmd, args...)
+// RunContext is like Run() but kills the command if ctx is done
+// before it completes. On remote hosts the process group of the
+// command is killed. If ctx is done first, its error is returned as
+// the standard error along with ExitErrorExecute.
+func (r *LogRun) RunContext(ctx context.Context, cmd string, args ...string) (string, string, int) {
+	return r.logAndRun(execSpec{ctx: ctx, cmd: cmd, args: args})
 }
 
 // FormatRun returns a string representation of the command that would
 // be executed using Run().
 func (r *LogRun) FormatRun(cmd string, args ...string) string {
-	return r.Runner.FormatRun(cmd, args...)
+	return r.formatRun(cmd, args...)
 }
 
 // Shell first logs the command and then runs the command in a
 // shell. Only logging is performed if DryRun is true.
 func (r *LogRun) Shell(cmd string) (string, string, int) {
-	msg := r.Runner.FormatShell(cmd)
-	r.logFunc(msg)
-	if r.Dryrun {
-		return "", "", ExitOK
-	}
-	return r.shell(cmd)
+	return r.logAndRun(execSpec{cmd: r.shellCmd(cmd), shell: true})
+}
+
+// ShellContext is like Shell() but kills the command if ctx is done
+// before it completes. See RunContext().
+func (r *LogRun) ShellContext(ctx context.Context, cmd string) (string, string, int) {
+	return r.logAndRun(execSpec{ctx: ctx, cmd: r.shellCmd(cmd), shell: true})
 }
 
 // FormatShell returns a string representation of the command that
 // would be executed using Shell().
 func (r *LogRun) FormatShell(cmd string) string {
-	return r.Runner.FormatShell(cmd)
+	return r.formatShell(r.shellCmd(cmd))
 }
 
 // FileExists returns true if filename exists and is a regular
-// file. This function is more suited to run remotely.
-func (r *LogRun) FileExists(filename string) (bool, error) {
-	cmdArgs := append(FileExistsCmdOptions, filename)
-	r.logFunc(r.Runner.FormatRun(FileExistsCmd, cmdArgs...))
+// file. Local runners use os.Stat() rather than FileExistsCmd. This
+// function is more suited to run remotely. False and a nil error are
+// returned if filename does not exist. Otherwise, the returned error
+// matches ErrNotRegularFile, ErrPermissionDenied, or ErrConnection
+// with errors.Is() to tell why it is not a regular file or could not
+// be checked. Use Stat() to get the other attributes of a file.
+func (r *LogRun) FileExists(filename string) (_ bool, err error) {
+	r = r.beginHelper()
+	defer r.addErrorContext(&err, r.commandSeq())
+	if r.sftpRunner() != nil || r.localRunner() != nil {
+		fi, exists, err := r.statInfo(filename, true)
+		switch {
+		case err != nil || !exists || r.Dryrun:
+			return exists, err
+		case !fi.IsRegular():
+			return false, fmt.Errorf("%s is %w", filename, ErrNotRegularFile)
+		}
+		return true, nil
+	}
+	if r.windowsRunner() != nil {
+		return r.windowsFileExists(filename)
+	}
+	cmdOptions, err := r.statOptions(FileExistsCmdOptions)
+	if err != nil {
+		return false, err
+	}
+	cmdArgs := append(append([]string{}, cmdOptions...), filename)
+	r.logRun(FileExistsCmd, cmdArgs...)
 	if r.Dryrun {
 		return true, nil
 	}
@@ -163,21 +324,42 @@ func (r *LogRun) FileExists(filename string) (bool, error) {
 		if strings.Contains(stderr, "No such file or directory") {
 			return false, nil
 		}
-		return false, fmt.Errorf("could not access %s: %s", filename, stdout)
+		return false, commandAccessError(filename, stderr, code)
 	}
-	fileType := strings.TrimSpace(strings.Split(stdout, ":")[1])
+	fileType := strings.ToLower(strings.TrimSpace(strings.Split(stdout, ":")[1]))
 	if fileType != "regular file" && fileType != "regular empty file" {
-		return false, fmt.Errorf("%s is not a regular file", filename)
+		return false, fmt.Errorf("%s is %w", filename, ErrNotRegularFile)
 	}
 
 	return true, nil
 }
 
-// DirExists returns true if dirname exists and is a directory. This
-// method is more suited to run remotely.
-func (r *LogRun) DirExists(dirname string) (bool, error) {
-	cmdArgs := append(DirExistsCmdOptions, dirname)
-	r.logFunc(r.Runner.FormatRun(DirExistsCmd, cmdArgs...))
+// DirExists returns true if dirname exists and is a directory. Local
+// runners use os.Stat() rather than DirExistsCmd. This method is more
+// suited to run remotely. Errors are reported like FileExists(), with
+// ErrNotDirectory instead of ErrNotRegularFile.
+func (r *LogRun) DirExists(dirname string) (_ bool, err error) {
+	r = r.beginHelper()
+	defer r.addErrorContext(&err, r.commandSeq())
+	if r.sftpRunner() != nil || r.localRunner() != nil {
+		fi, exists, err := r.statInfo(dirname, true)
+		switch {
+		case err != nil || !exists || r.Dryrun:
+			return exists, err
+		case !fi.IsDir():
+			return false, fmt.Errorf("%s is %w", dirname, ErrNotDirectory)
+		}
+		return true, nil
+	}
+	if r.windowsRunner() != nil {
+		return r.windowsDirExists(dirname)
+	}
+	cmdOptions, err := r.statOptions(DirExistsCmdOptions)
+	if err != nil {
+		return false, err
+	}
+	cmdArgs := append(append([]string{}, cmdOptions...), dirname)
+	r.logRun(DirExistsCmd, cmdArgs...)
 	if r.Dryrun {
 		return true, nil
 	}
@@ -186,26 +368,49 @@ func (r *LogRun) DirExists(dirname string) (bool, error) {
 		if strings.Contains(stderr, "No such file or directory") {
 			return false, nil
 		}
-		return false, fmt.Errorf("could not access %s: %s", dirname, stdout)
+		return false, commandAccessError(dirname, stderr, code)
 	}
-	if strings.TrimSpace(strings.Split(stdout, ":")[1]) != "directory" {
-		return false, fmt.Errorf("%s is not a directory", dirname)
+	if strings.ToLower(strings.TrimSpace(strings.Split(stdout, ":")[1])) != "directory" {
+		return false, fmt.Errorf("%s is %w", dirname, ErrNotDirectory)
 	}
 
 	return true, nil
 }
 
-// Glob returns a list of files matching a shell glob pattern. This
-// method is more suited to run remotely.
-func (r *LogRun) Glob(pattern string) ([]string, error) {
+// Glob returns a list of files matching a shell glob pattern. Local
+// runners use filepath.Glob() rather than GlobCmd. This method is more
+// suited to run remotely. Errors match ErrGlobFailed and, when the
+// reason is known, ErrNotFound if nothing matched, ErrPermissionDenied,
+// or ErrConnection.
+func (r *LogRun) Glob(pattern string) (_ []string, err error) {
+	r = r.beginHelper()
+	defer r.addErrorContext(&err, r.commandSeq())
+	return r.glob(pattern, GlobOptions{})
+}
+
+func (r *LogRun) glob(pattern string, opts GlobOptions) ([]string, error) {
+	if remote := r.sftpRunner(); remote != nil {
+		return r.sftpGlob(remote, pattern, opts)
+	}
+	if local := r.localRunner(); local != nil {
+		return r.localGlob(local, pattern, opts)
+	}
+	if r.windowsRunner() != nil {
+		return r.windowsGlob(pattern, opts)
+	}
+	cmdOptions, err := r.globOptions()
+	if err != nil {
+		return []string{}, err
+	}
 	args := []string{GlobCmd}
-	args = append(args, GlobCmdOptions...)
+	args = append(args, cmdOptions...)
+	args = append(args, globSortOptions(opts)...)
 	args = append(args, pattern)
-	cmd := strings.Join(args, " ")
-	r.logFunc(r.Runner.FormatShell(cmd))
+	cmd := strings.Join(args, " ") + globLimit(opts)
+	r.logShell(cmd)
 	stdout, stderr, code := r.shell(cmd)
 	if code != 0 {
-		return []string{}, fmt.Errorf("glob '%s' failed: %s", pattern, stderr)
+		return []string{}, commandGlobError(pattern, stderr, code)
 	}
 	var results []string
 	for _, line := range strings.Split(stdout, "\n") {
@@ -214,38 +419,220 @@ func (r *LogRun) Glob(pattern string) ([]string, error) {
 			results = append(results, line)
 		}
 	}
+	if len(results) == 0 && opts.Limit > 0 && !r.Dryrun {
+		// The exit status of GlobCmd is hidden by HeadCmd.
+		if stderr = strings.TrimSpace(stderr); stderr == "" {
+			stderr = "no matches"
+		}
+		return []string{}, globError(pattern, stderr)
+	}
 
 	return results, nil
 }
 
 // Rsync copies files/directories to or from local and remote
 // locations using the rsync command. This method is more suited to
-// run locally.
-func (r *LogRun) Rsync(src string, dest string) error {
-	cmdArgs := RsyncCmdOptions
+// run locally. If rsync exits with a non-zero exit code, the returned
+// error is of type *RsyncError. If a Recorder or a TransferHook was
+// set with SetRecorder() or SetTransferHook(), RsyncStatsCmdOptions
+// are added so the bytes transferred are recorded, and the statistics
+// printed by rsync are written to Stdout. For LogRuns created by
+// NewDockerLogRun(), src on the controller is instead copied into the
+// existing directory dest in the container like docker cp, without
+// rsync's change detection.
+//
+// For LogRuns created by NewRemoteLogRun(), a src or dest prefixed
+// with a colon, e.g., ":/opt/app/", is a path on the remote host.
+// rsync is then run on the controller and connects to the remote
+// host using ssh with the port, username, private key file, jump
+// hosts, and host key policy of the RemoteConfig. The command and the
+// transfer are still attributed to the remote host. Since ssh can not
+// be given a password, single files are instead copied using Upload()
+// or Download() if the remote host is authenticated with a password;
+// directories and destinations ending in a slash are then rejected.
+// Otherwise rsync is run on the remote host.
+func (r *LogRun) Rsync(src string, dest string) (err error) {
+	r = r.beginHelper()
+	defer r.addErrorContext(&err, r.commandSeq())
+	return r.rsyncWithOptions(src, dest, RsyncOptions{})
+}
+
+func (r *LogRun) rsyncWithOptions(src string, dest string, opts RsyncOptions) error {
+	if d := r.dockerRunner(); d != nil {
+		if !opts.isZero() {
+			return fmt.Errorf("rsync options are not supported when copying into containers")
+		}
+		return r.dockerCopy(d, src, dest)
+	}
+	if remote, ok := r.Runner.(*remoteRunner); ok && (isRemoteRsyncPath(src) || isRemoteRsyncPath(dest)) {
+		return r.remoteRsync(remote, src, dest, opts)
+	}
+
+	return r.rsync(RsyncCmdOptions, src, dest, opts)
+}
+
+// rsync runs RsyncCmd with options followed by those selected by opts
+// to copy src to dest.
+func (r *LogRun) rsync(options []string, src string, dest string, opts RsyncOptions) error {
+	caps, err := r.helperCapabilities()
+	if err != nil {
+		return err
+	}
+	if !caps.Rsync {
+		return fmt.Errorf("rsync command failed: rsync is not available")
+	}
+	cmdArgs := append([]string{}, options...)
+	cmdArgs = append(cmdArgs, opts.args()...)
+	progress := !r.checking() && opts.Progress != nil
+	stats := !r.checking() && (progress || r.accounting() && !opts.DryRun)
+	if r.checking() {
+		cmdArgs = append(cmdArgs, RsyncCheckCmdOptions...)
+	} else if stats {
+		cmdArgs = append(cmdArgs, RsyncStatsCmdOptions...)
+	}
+	if progress {
+		cmdArgs = append(cmdArgs, RsyncProgressCmdOptions...)
+	}
 	cmdArgs = append(cmdArgs, src, dest)
-	_, stderr, code := r.Run(RsyncCmd, cmdArgs...)
+	r.logChangeRun(RsyncCmd, cmdArgs...)
+	if r.Dryrun {
+		return nil
+	}
+	spec := execSpec{cmd: RsyncCmd, args: cmdArgs, capture: r.checking()}
+	var statsOut bytes.Buffer
+	if stats || progress {
+		var writers []io.Writer
+		if runnerStdout, _ := runnerWriters(r.Runner); runnerStdout != nil {
+			writers = append(writers, runnerStdout)
+		}
+		if stats {
+			writers = append(writers, &statsOut)
+		}
+		if progress {
+			writers = append(writers, &rsyncProgressWriter{fn: opts.Progress})
+		}
+		spec.stdout = io.MultiWriter(writers...)
+	}
+	stdout, stderr, code, err := r.execute(spec)
+	if err != nil {
+		return fmt.Errorf("rsync command failed: %w", err)
+	}
+	if stats && !opts.DryRun {
+		sent, received := parseRsyncStats(r.decodeOutput(statsOut.String()))
+		r.recordTransfer("Rsync", dest, sent, received)
+	}
+	if progress && stats && code == 0 {
+		opts.Progress(rsyncDone(r.decodeOutput(statsOut.String())))
+	}
 	if code != 0 {
-		return fmt.Errorf("rsync command failed: %s", stderr)
+		return &RsyncError{
+			Code:   code,
+			Stderr: stderr,
+			Lines:  r.ClassifyStderr(RsyncCmd, stderr),
+		}
+	}
+	if r.checking() {
+		for _, change := range parseRsyncItemized(dest, r.decodeOutput(stdout)) {
+			r.check.add(change)
+		}
 	}
 
 	return nil
 }
 
-func (r *LogRun) run(cmd string, args ...string) (string, string, int) {
-	stdout, stderr, code, err := r.Runner.Run(cmd, args...)
+// logAndRun logs the command described by spec and then runs it. Only
+// logging is performed if DryRun is true. In check mode the command is
+// recorded instead of being run. Commands are skipped if a guard set
+// by OnlyIf() or Unless() does not allow them.
+func (r *LogRun) logAndRun(spec execSpec) (string, string, int) {
+	res := r.logAndExecute(spec)
+	if res.Err != nil {
+		return "", res.Err.Error(), ExitErrorExecute
+	}
+
+	return res.Stdout, res.Stderr, res.ExitCode
+}
+
+// logAndExecute is like logAndRun() but returns a Result, which is
+// saved to the ResultStore, if any.
+func (r *LogRun) logAndExecute(spec execSpec) Result {
+	start := r.getClock().Now()
+	res := r.logAndExecuteSpec(spec)
+	r.storeResult(res, start)
+
+	return res
+}
+
+func (r *LogRun) logAndExecuteSpec(spec execSpec) Result {
+	msg := r.format(spec)
+	res := Result{Host: r.Host().String(), Command: msg}
+	skip, err := r.checkGuards()
 	if err != nil {
-		return "", err.Error(), ExitErrorExecute
+		res.Err = err
+		return res
+	}
+	if skip != "" {
+		r.logSpec(spec, trf("skipped: %s (%s)", msg, skip), SkipGuard)
+		r.recordSkipped(msg, SkipGuard)
+		res.Skipped = true
+		res.SkipReason = SkipGuard
+		return res
+	}
+	reason := r.skipReason()
+	r.logSpec(spec, msg, reason)
+	r.logContext(spec)
+	if reason != SkipNone {
+		r.recordSkipped(msg, reason)
+		res.Skipped = true
+		res.SkipReason = reason
 	}
+	if r.Dryrun {
+		return res
+	}
+	if r.checking() {
+		r.check.add(Change{Action: ChangeRun, Target: msg})
+		return res
+	}
+	clock := r.getClock()
+	if r.call.lineTimestamps {
+		spec.lines = &outputLines{clock: clock}
+	}
+	start := clock.Now()
+	stdout, stderr, code, err := r.execute(spec)
+	res.Duration = clock.Since(start)
+	if spec.lines != nil {
+		res.Lines = spec.lines.get(r)
+	}
+	if err != nil {
+		res.Err = err
+		return res
+	}
+	res.Stdout = r.decodeOutput(stdout)
+	res.Stderr = r.decodeOutput(stderr)
+	res.StderrLines = r.ClassifyStderr(spec.cmd, res.Stderr)
+	res.ExitCode = code
 
-	return stdout, stderr, code
+	return res
 }
 
+// run runs a command without logging it and captures its output. It
+// is used by helper methods that parse the output of a command.
+func (r *LogRun) run(cmd string, args ...string) (string, string, int) {
+	return r.runSpec(execSpec{cmd: cmd, args: args, capture: true})
+}
+
+// shell runs a command in a shell without logging it and captures
+// its output. It is used by helper methods that parse the output of a
+// command.
 func (r *LogRun) shell(cmd string) (string, string, int) {
-	stdout, stderr, code, err := r.Runner.Shell(cmd)
+	return r.runSpec(execSpec{cmd: cmd, shell: true, capture: true})
+}
+
+func (r *LogRun) runSpec(spec execSpec) (string, string, int) {
+	stdout, stderr, code, err := r.execute(spec)
 	if err != nil {
 		return "", err.Error(), ExitErrorExecute
 	}
 
-	return stdout, stderr, code
+	return r.decodeOutput(stdout), r.decodeOutput(stderr), code
 }
@@ -4,16 +4,94 @@
 
 package logrun
 
-// LogRunner is the interface for both LocalLogRun and RemoteLogRun.
-type LogRunner interface {
-	SetLogFunc(f LogFunc)
-	SetDryrun(dryrun bool)
+import (
+	"context"
+	"os"
+)
+
+// CommandRunner is the interface for logging and running commands
+// with arguments.
+type CommandRunner interface {
 	Run(cmd string, args ...string) (string, string, int)
+	RunContext(ctx context.Context, cmd string, args ...string) (string, string, int)
+	RunLine(line string) (string, string, int)
+	RunResult(cmd string, args ...string) (Result, error)
+	RunCombined(cmd string, args ...string) (string, int)
+	RunLines(cmd string, args ...string) ([]string, string, int)
+	RunTrimmed(cmd string, args ...string) (string, string, int)
+	Start(cmd string, args ...string) (*ProcessHandle, error)
+	RunStream(h StreamHandlers, cmd string, args ...string) (int, error)
 	FormatRun(cmd string, args ...string) string
+	FormatRunCommand(cmd string, args ...string) Command
+	RunTemplate(t *CommandTemplate, data interface{}) (string, string, int)
+}
+
+// ShellRunner is the interface for logging and running shell
+// commands.
+type ShellRunner interface {
 	Shell(cmd string) (string, string, int)
+	ShellContext(ctx context.Context, cmd string) (string, string, int)
+	ShellStream(h StreamHandlers, cmd string) (int, error)
+	ShellResult(cmd string) (Result, error)
+	ShellCombined(cmd string) (string, int)
+	ShellLines(cmd string) ([]string, string, int)
+	ShellTrimmed(cmd string) (string, string, int)
+	StartShell(cmd string) (*ProcessHandle, error)
 	FormatShell(cmd string) string
+	FormatShellCommand(cmd string) Command
+	ShellTemplate(t *CommandTemplate, data interface{}) (string, string, int)
+}
+
+// FileOps is the interface for inspecting and modifying files.
+type FileOps interface {
 	FileExists(filename string) (bool, error)
 	DirExists(dirname string) (bool, error)
+	Stat(path string) (FileInfo, error)
+	Checksum(path string, algo ChecksumAlgorithm) (string, error)
 	Glob(pattern string) ([]string, error)
+	GetFileString(path string) (string, error)
+	PutFileString(path string, content string, mode os.FileMode) (bool, error)
+	ReadFile(path string) ([]byte, error)
+	WriteFile(path string, data []byte, mode os.FileMode) error
+	MkdirAll(path string, mode os.FileMode) error
+	Remove(path string) error
+	RemoveAll(path string) error
+	Chmod(path string, mode os.FileMode) error
+	Chown(path string, owner string) error
+	Symlink(oldname string, newname string) error
+}
+
+// Transfer is the interface for copying files between hosts.
+type Transfer interface {
 	Rsync(src string, dest string) error
+	Upload(localPath string, remotePath string) error
+	Download(remotePath string, localPath string) error
+}
+
+// LogRunner is the interface for both LocalLogRun and RemoteLogRun. It
+// is the union of CommandRunner, ShellRunner, FileOps, and Transfer
+// plus configuration, working directory, and section methods. Code
+// that only needs part of it, e.g., mocks and alternative backends,
+// should use the smaller interfaces.
+type LogRunner interface {
+	CommandRunner
+	ShellRunner
+	FileOps
+	Transfer
+	SetLogFunc(f LogFunc)
+	SetRunner(runner Runner)
+	SetLogHook(h LogHook)
+	SetResultLogFunc(f LogFunc)
+	Config() EffectiveConfig
+	SetDryrun(dryrun bool)
+	PushDir(dir string)
+	PopDir() (string, error)
+	Dir() string
+	BeginSection(name string)
+	EndSection() error
+	WithSection(name string, f func() error) error
+	Section() string
+	Close() error
 }
+
+var _ LogRunner = (*LogRun)(nil)
@@ -5,6 +5,7 @@
 package logrun_test
 
 import (
+	"os"
 	"regexp"
 	"testing"
 
@@ -58,3 +59,96 @@ func TestLogRunnerSuccess(t *testing.T) {
 	out.Reset()
 	errOut.Reset()
 }
+
+type fakeFileOps struct {
+	files map[string]string
+}
+
+func (f fakeFileOps) FileExists(filename string) (bool, error) {
+	_, ok := f.files[filename]
+	return ok, nil
+}
+
+func (f fakeFileOps) DirExists(dirname string) (bool, error) {
+	return false, nil
+}
+
+func (f fakeFileOps) Stat(path string) (logrun.FileInfo, error) {
+	content, ok := f.files[path]
+	if !ok {
+		return logrun.FileInfo{}, logrun.ErrNotFound
+	}
+	return logrun.FileInfo{Path: path, Size: int64(len(content)), Mode: 0644}, nil
+}
+
+func (f fakeFileOps) Checksum(path string, algo logrun.ChecksumAlgorithm) (string, error) {
+	return "", nil
+}
+
+func (f fakeFileOps) Glob(pattern string) ([]string, error) {
+	return []string{}, nil
+}
+
+func (f fakeFileOps) GetFileString(path string) (string, error) {
+	return f.files[path], nil
+}
+
+func (f fakeFileOps) PutFileString(path string, content string, mode os.FileMode) (bool, error) {
+	changed := f.files[path] != content
+	f.files[path] = content
+	return changed, nil
+}
+
+func (f fakeFileOps) ReadFile(path string) ([]byte, error) {
+	return []byte(f.files[path]), nil
+}
+
+func (f fakeFileOps) WriteFile(path string, data []byte, mode os.FileMode) error {
+	f.files[path] = string(data)
+	return nil
+}
+
+func (f fakeFileOps) MkdirAll(path string, mode os.FileMode) error {
+	return nil
+}
+
+func (f fakeFileOps) Remove(path string) error {
+	delete(f.files, path)
+	return nil
+}
+
+func (f fakeFileOps) RemoveAll(path string) error {
+	delete(f.files, path)
+	return nil
+}
+
+func (f fakeFileOps) Chmod(path string, mode os.FileMode) error {
+	return nil
+}
+
+func (f fakeFileOps) Chown(path string, owner string) error {
+	return nil
+}
+
+func (f fakeFileOps) Symlink(oldname string, newname string) error {
+	f.files[newname] = f.files[oldname]
+	return nil
+}
+
+func TestFileOps(t *testing.T) {
+	var ops logrun.FileOps = logrun.NewLocalLogRun(logrun.LocalConfig{})
+	exists, err := ops.FileExists("/bin/true")
+	t.Logf("exists = %t", exists)
+	assert.NoError(t, err)
+	assert.True(t, exists)
+
+	ops = fakeFileOps{files: map[string]string{}}
+	changed, err := ops.PutFileString("/etc/motd", "hello\n", 0644)
+	t.Logf("changed = %t", changed)
+	assert.NoError(t, err)
+	assert.True(t, changed)
+	exists, err = ops.FileExists("/etc/motd")
+	t.Logf("exists = %t", exists)
+	assert.NoError(t, err)
+	assert.True(t, exists)
+}
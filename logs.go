@@ -0,0 +1,108 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// JournalCmd is the external command used to fetch the logs of
+	// systemd units. This command has been tested on RHEL/CentOS 7
+	// and Ubuntu 18.04.
+	JournalCmd = "/bin/journalctl"
+
+	// JournalCmdOptions are the command-line options added to
+	// JournalCmd to output plain text with ISO 8601 timestamps.
+	// This command (and options) has been tested on RHEL/CentOS 7
+	// and Ubuntu 18.04.
+	JournalCmdOptions = []string{
+		"--no-pager",
+		"--output=short-iso",
+	}
+
+	// TailCmd is the external command used to fetch the end of
+	// log files. This command has been tested on RHEL/CentOS 7
+	// and Ubuntu 18.04.
+	TailCmd = "/usr/bin/tail"
+
+	// DefaultFetchLogsLines is the number of lines fetched by
+	// FetchLogs() if lines is zero.
+	DefaultFetchLogsLines = 1000
+
+	// FetchLogsMaxBytes limits the size of the logs returned by
+	// FetchLogs(). Only the last FetchLogsMaxBytes bytes are
+	// kept.
+	FetchLogsMaxBytes = 1024 * 1024
+)
+
+// FetchLogs returns the last lines of the logs of source on the host,
+// e.g., right after a deployment step failed. If source is an
+// absolute path, the end of that file is fetched using TailCmd.
+// Otherwise source is the name of a systemd unit, e.g., "nginx", and
+// its journal entries are fetched using JournalCmd; if since is not
+// zero, only entries logged in the last since are included. since is
+// ignored for files. If lines is zero, DefaultFetchLogsLines lines are
+// fetched. At most the last FetchLogsMaxBytes bytes of the logs are
+// kept in memory and returned. Only logging is performed if Dryrun is
+// true, in which case the empty string is returned.
+func (r *LogRun) FetchLogs(source string, since time.Duration, lines int) (_ string, err error) {
+	r = r.beginHelper()
+	defer r.addErrorContext(&err, r.commandSeq())
+	if lines <= 0 {
+		lines = DefaultFetchLogsLines
+	}
+	var cmd string
+	var args []string
+	if strings.HasPrefix(source, "/") {
+		cmd = TailCmd
+		args = []string{"-n", strconv.Itoa(lines), source}
+	} else {
+		cmd = JournalCmd
+		args = append(append([]string{}, JournalCmdOptions...), "-u", source, "-n", strconv.Itoa(lines))
+		if since > 0 {
+			args = append(args, fmt.Sprintf("--since=-%ds", int64(since/time.Second)))
+		}
+	}
+	r.logRun(cmd, args...)
+	if r.Dryrun {
+		return "", nil
+	}
+	buf := &tailBuffer{max: FetchLogsMaxBytes}
+	_, stderr, code := r.runSpec(execSpec{cmd: cmd, args: args, stdout: buf, capture: true})
+	if code != 0 {
+		return "", fmt.Errorf("could not fetch logs of %s: %s", source, strings.TrimSpace(stderr))
+	}
+
+	return r.decodeOutput(buf.String()), nil
+}
+
+// tailBuffer is an io.Writer that keeps the last max bytes written to
+// it.
+type tailBuffer struct {
+	max int
+	buf []byte
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	n := len(p)
+	if n >= b.max {
+		b.buf = append(b.buf[:0], p[n-b.max:]...)
+		return n, nil
+	}
+	if over := len(b.buf) + n - b.max; over > 0 {
+		b.buf = append(b.buf[:0], b.buf[over:]...)
+	}
+	b.buf = append(b.buf, p...)
+
+	return n, nil
+}
+
+func (b *tailBuffer) String() string {
+	return string(b.buf)
+}
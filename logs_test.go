@@ -0,0 +1,70 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apatters/go-logrun"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalLogRun_FetchLogs(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "logrun")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmpDir)
+	path := filepath.Join(tmpDir, "app.log")
+	var content strings.Builder
+	for i := 1; i <= 20; i++ {
+		fmt.Fprintf(&content, "line %02d\n", i)
+	}
+	require.NoError(t, ioutil.WriteFile(path, []byte(content.String()), 0644))
+
+	log, out, _ := newLogger()
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{
+		LogFunc: log.Println,
+	})
+	logs, err := l.FetchLogs(path, time.Hour, 3)
+	t.Logf("logs = %q", logs)
+	t.Logf("out = %q", out)
+	require.NoError(t, err)
+	assert.Equal(t, "line 18\nline 19\nline 20\n", logs)
+	assert.Equal(t, fmt.Sprintf("/usr/bin/tail -n 3 %s\n", path), out.String())
+
+	// Only the last FetchLogsMaxBytes bytes are kept.
+	orig := logrun.FetchLogsMaxBytes
+	logrun.FetchLogsMaxBytes = 16
+	defer func() { logrun.FetchLogsMaxBytes = orig }()
+	logs, err = l.FetchLogs(path, 0, 0)
+	t.Logf("logs = %q", logs)
+	require.NoError(t, err)
+	assert.Equal(t, "line 19\nline 20\n", logs)
+
+	_, err = l.FetchLogs(filepath.Join(tmpDir, "missing.log"), 0, 0)
+	t.Logf("err = %v", err)
+	assert.Error(t, err)
+}
+
+func TestLocalLogRun_FetchLogsJournal(t *testing.T) {
+	log, out, _ := newLogger()
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{
+		LogFunc: log.Println,
+		Dryrun:  true,
+	})
+	logs, err := l.FetchLogs("nginx", 90*time.Minute, 0)
+	t.Logf("out = %q", out)
+	require.NoError(t, err)
+	assert.Empty(t, logs)
+	assert.Equal(t,
+		"/bin/journalctl --no-pager --output=short-iso -u nginx -n 1000 --since=-5400s\n",
+		out.String())
+}
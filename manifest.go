@@ -0,0 +1,314 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var (
+	// FileOwnerCmd is the external command used by Verify() to
+	// determine the owner of a file. This command has been tested
+	// on RHEL/CentOS 7 and Ubuntu 18.04.
+	FileOwnerCmd = "/usr/bin/stat"
+
+	// FileOwnerCmdOptions are the command-line options added to
+	// FileOwnerCmd used to output the user and group owning a
+	// file. This command and options has been tested on
+	// RHEL/CentOS 7 and Ubuntu 18.04.
+	FileOwnerCmdOptions = []string{
+		"--dereference",
+		"--format",
+		"%U:%G",
+	}
+)
+
+// Manifest describes the desired state of a host, i.e., the files,
+// packages, and services it is expected to have. It is usually
+// stored as JSON, e.g.,
+//
+//	{
+//	  "files": [
+//	    {"path": "/etc/nginx/nginx.conf", "sha256": "9f86d0...", "mode": "644", "owner": "root:root"},
+//	    {"path": "/etc/nginx/conf.d/default.conf", "absent": true}
+//	  ],
+//	  "packages": [{"name": "nginx", "version": "1.20.1-1.el7"}],
+//	  "services": [{"name": "nginx.service", "active_state": "active", "unit_file_state": "enabled"}]
+//	}
+//
+// and checked against a host using Verify().
+type Manifest struct {
+	Files    []ManifestFile    `json:"files,omitempty"`
+	Packages []ManifestPackage `json:"packages,omitempty"`
+	Services []ManifestService `json:"services,omitempty"`
+}
+
+// ManifestFile is the desired state of a file. Empty fields are not
+// checked.
+type ManifestFile struct {
+	// Path is the path of the file.
+	Path string `json:"path"`
+
+	// Absent is true if the file must not exist.
+	Absent bool `json:"absent,omitempty"`
+
+	// SHA256 is the hex-encoded SHA-256 digest of the contents of
+	// the file.
+	SHA256 string `json:"sha256,omitempty"`
+
+	// Mode is the permission bits of the file in octal, e.g.,
+	// "644".
+	Mode string `json:"mode,omitempty"`
+
+	// Owner is the user, or the user and group separated by a
+	// colon, owning the file, e.g., "root:root".
+	Owner string `json:"owner,omitempty"`
+}
+
+// ManifestPackage is the desired state of a package.
+type ManifestPackage struct {
+	// Name is the name of the package.
+	Name string `json:"name"`
+
+	// Version is the installed version of the package as output
+	// by PackagesCmd, e.g., "1.20.1-1.el7". Any version is
+	// accepted if it is empty.
+	Version string `json:"version,omitempty"`
+
+	// Absent is true if the package must not be installed.
+	Absent bool `json:"absent,omitempty"`
+}
+
+// ManifestService is the desired state of a systemd unit. The unit
+// must exist. Empty fields are not checked.
+type ManifestService struct {
+	// Name is the name of the unit, e.g., "nginx.service".
+	Name string `json:"name"`
+
+	// ActiveState is the ActiveState of the unit, e.g., "active".
+	ActiveState string `json:"active_state,omitempty"`
+
+	// UnitFileState is the UnitFileState of the unit, e.g.,
+	// "enabled".
+	UnitFileState string `json:"unit_file_state,omitempty"`
+}
+
+// ParseManifest parses the JSON encoding of a Manifest and checks that
+// its entries are valid.
+func ParseManifest(data []byte) (*Manifest, error) {
+	m := new(Manifest)
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, fmt.Errorf("could not parse manifest: %w", err)
+	}
+	if err := m.Validate(); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// Validate returns an error if an entry of the Manifest is missing its
+// name or has an invalid value.
+func (m *Manifest) Validate() error {
+	for _, f := range m.Files {
+		if f.Path == "" {
+			return errors.New("invalid manifest: file without a path")
+		}
+		if f.Absent && (f.SHA256 != "" || f.Mode != "" || f.Owner != "") {
+			return fmt.Errorf("invalid manifest: absent file %s has attributes", f.Path)
+		}
+		if f.Mode != "" {
+			if _, err := strconv.ParseUint(f.Mode, 8, 32); err != nil {
+				return fmt.Errorf("invalid manifest: file %s has invalid mode %q", f.Path, f.Mode)
+			}
+		}
+	}
+	for _, p := range m.Packages {
+		if p.Name == "" {
+			return errors.New("invalid manifest: package without a name")
+		}
+	}
+	for _, s := range m.Services {
+		if s.Name == "" {
+			return errors.New("invalid manifest: service without a name")
+		}
+	}
+
+	return nil
+}
+
+// ManifestDrift is a difference between the desired state described by
+// a Manifest and the state of the host.
+type ManifestDrift struct {
+	// Kind is "file", "package", or "service".
+	Kind string
+
+	// Name is the path of the file or the name of the package or
+	// service.
+	Name string
+
+	// Property is what differs, e.g., "exists", "sha256", "mode",
+	// "owner", "version", "active_state", or "unit_file_state".
+	Property string
+
+	// Expected is the value in the Manifest and Actual is the value
+	// found on the host.
+	Expected string
+	Actual   string
+}
+
+// String returns a description of the drift, e.g., "file /etc/motd:
+// mode is 600, expected 644".
+func (d ManifestDrift) String() string {
+	return trf("%s %s: %s is %s, expected %s", d.Kind, d.Name, d.Property, d.Actual, d.Expected)
+}
+
+// VerifyReport is the outcome of Verify().
+type VerifyReport struct {
+	// Host identifies the host that was verified.
+	Host string
+
+	// Drifts are the differences found in the order of the entries
+	// of the Manifest.
+	Drifts []ManifestDrift
+}
+
+// InSync returns true if no drift was found.
+func (v *VerifyReport) InSync() bool {
+	return len(v.Drifts) == 0
+}
+
+// String returns the drifts found, one per line.
+func (v *VerifyReport) String() string {
+	if v.InSync() {
+		return trf("%s: no drift\n", v.Host)
+	}
+	var b strings.Builder
+	b.WriteString(trf("%s: %d drifted\n", v.Host, len(v.Drifts)))
+	for _, d := range v.Drifts {
+		b.WriteString("  " + d.String() + "\n")
+	}
+
+	return b.String()
+}
+
+// Verify checks the host against m and reports where it differs. Only
+// read-only commands are run, e.g., stat, sha256sum, rpm, and
+// systemctl, so Verify runs them even if Dryrun is true and can be
+// used for drift detection on production hosts. An error is returned
+// if m is invalid or the state of the host could not be determined.
+func (r *LogRun) Verify(m *Manifest) (_ *VerifyReport, err error) {
+	r = r.beginHelper()
+	defer r.addErrorContext(&err, r.commandSeq())
+	if err := m.Validate(); err != nil {
+		return nil, err
+	}
+	v := r.With()
+	v.Dryrun = false
+	report := &VerifyReport{Host: r.Host().String()}
+	drift := func(kind string, name string, property string, expected string, actual string) {
+		report.Drifts = append(report.Drifts, ManifestDrift{
+			Kind:     kind,
+			Name:     name,
+			Property: property,
+			Expected: expected,
+			Actual:   actual,
+		})
+	}
+
+	for _, f := range m.Files {
+		mode, err := v.fileMode(f.Path)
+		exists := !errors.Is(err, ErrNotFound)
+		if err != nil && exists {
+			return nil, err
+		}
+		if exists == f.Absent {
+			drift("file", f.Path, "exists", strconv.FormatBool(!f.Absent), strconv.FormatBool(exists))
+			continue
+		}
+		if !exists {
+			continue
+		}
+		if f.Mode != "" {
+			want, _ := strconv.ParseUint(f.Mode, 8, 32)
+			if got, err := strconv.ParseUint(mode, 8, 32); err != nil || got != want {
+				drift("file", f.Path, "mode", strconv.FormatUint(want, 8), mode)
+			}
+		}
+		if f.Owner != "" {
+			owner, err := v.fileOwner(f.Path)
+			if err != nil {
+				return nil, err
+			}
+			if !strings.Contains(f.Owner, ":") {
+				owner = strings.SplitN(owner, ":", 2)[0]
+			}
+			if owner != f.Owner {
+				drift("file", f.Path, "owner", f.Owner, owner)
+			}
+		}
+		if f.SHA256 != "" {
+			digest, _, err := v.fileDigest(f.Path)
+			if err != nil {
+				return nil, err
+			}
+			if !strings.EqualFold(digest, f.SHA256) {
+				drift("file", f.Path, "sha256", strings.ToLower(f.SHA256), digest)
+			}
+		}
+	}
+
+	if len(m.Packages) > 0 {
+		installed, err := v.installedPackages()
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range m.Packages {
+			version, ok := installed[p.Name]
+			switch {
+			case ok == p.Absent:
+				drift("package", p.Name, "installed", strconv.FormatBool(!p.Absent), strconv.FormatBool(ok))
+			case ok && p.Version != "" && version != p.Version:
+				drift("package", p.Name, "version", p.Version, version)
+			}
+		}
+	}
+
+	for _, s := range m.Services {
+		status, err := v.UnitStatus(s.Name)
+		if err != nil {
+			return nil, err
+		}
+		if status.LoadState == "not-found" {
+			drift("service", s.Name, "load_state", "loaded", status.LoadState)
+			continue
+		}
+		if s.ActiveState != "" && status.ActiveState != s.ActiveState {
+			drift("service", s.Name, "active_state", s.ActiveState, status.ActiveState)
+		}
+		if s.UnitFileState != "" && status.UnitFileState != s.UnitFileState {
+			drift("service", s.Name, "unit_file_state", s.UnitFileState, status.UnitFileState)
+		}
+	}
+
+	return report, nil
+}
+
+// fileOwner returns the user and group owning path separated by a
+// colon.
+func (r *LogRun) fileOwner(path string) (string, error) {
+	cmdArgs := append(append([]string{}, FileOwnerCmdOptions...), path)
+	r.logRun(FileOwnerCmd, cmdArgs...)
+	stdout, stderr, code := r.run(FileOwnerCmd, cmdArgs...)
+	if code != 0 {
+		return "", commandAccessError(path, stderr, code)
+	}
+
+	return strings.TrimSpace(stdout), nil
+}
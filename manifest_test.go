@@ -0,0 +1,127 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/user"
+	"path/filepath"
+	"testing"
+
+	"github.com/apatters/go-logrun"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseManifest(t *testing.T) {
+	m, err := logrun.ParseManifest([]byte(`{
+		"files": [{"path": "/etc/motd", "mode": "644", "owner": "root"}],
+		"packages": [{"name": "nginx", "version": "1.20.1-1.el7"}],
+		"services": [{"name": "nginx.service", "active_state": "active"}]
+	}`))
+	require.NoError(t, err)
+	assert.Equal(t, "644", m.Files[0].Mode)
+	assert.Equal(t, "1.20.1-1.el7", m.Packages[0].Version)
+	assert.Equal(t, "active", m.Services[0].ActiveState)
+
+	for _, data := range []string{
+		`{"files": [{"mode": "644"}]}`,
+		`{"files": [{"path": "/etc/motd", "mode": "rw-r--r--"}]}`,
+		`{"files": [{"path": "/etc/motd", "absent": true, "mode": "644"}]}`,
+		`{"packages": [{"version": "1.0"}]}`,
+		`{"services": [{}]}`,
+		`{"files": {}}`,
+	} {
+		_, err := logrun.ParseManifest([]byte(data))
+		t.Logf("err = %v", err)
+		assert.Error(t, err, data)
+	}
+}
+
+func testVerify(t *testing.T, r *logrun.LogRun) {
+	tmpDir, err := ioutil.TempDir("", "logrun")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmpDir)
+	conf := filepath.Join(tmpDir, "nginx.conf")
+	require.NoError(t, ioutil.WriteFile(conf, []byte("worker_processes 4;\n"), 0644))
+	require.NoError(t, os.Chmod(conf, 0600))
+	sum := sha256.Sum256([]byte("worker_processes 2;\n"))
+	u, err := user.Current()
+	require.NoError(t, err)
+
+	m := &logrun.Manifest{
+		Files: []logrun.ManifestFile{
+			{Path: conf, SHA256: hex.EncodeToString(sum[:]), Mode: "0644", Owner: u.Username},
+			{Path: filepath.Join(tmpDir, "missing.conf")},
+			{Path: tmpDir, Mode: "700"},
+			{Path: filepath.Join(tmpDir, "default.conf"), Absent: true},
+		},
+	}
+	report, err := r.Verify(m)
+	require.NoError(t, err)
+	t.Logf("report =\n%s", report)
+	assert.False(t, report.InSync())
+	require.Len(t, report.Drifts, 3)
+	assert.Equal(t, logrun.ManifestDrift{Kind: "file", Name: conf, Property: "mode", Expected: "644", Actual: "600"}, report.Drifts[0])
+	assert.Equal(t, "sha256", report.Drifts[1].Property)
+	assert.Equal(t, "exists", report.Drifts[2].Property)
+	assert.Equal(t, fmt.Sprintf("file %s: mode is 600, expected 644", conf), report.Drifts[0].String())
+
+	require.NoError(t, ioutil.WriteFile(conf, []byte("worker_processes 2;\n"), 0644))
+	require.NoError(t, os.Chmod(conf, 0644))
+	m.Files = m.Files[:1]
+	report, err = r.Verify(m)
+	require.NoError(t, err)
+	t.Logf("report =\n%s", report)
+	assert.True(t, report.InSync())
+}
+
+func TestLocalLogRun_Verify(t *testing.T) {
+	log, out, _ := newLogger()
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{LogFunc: log.Println, Dryrun: true})
+	l.SetRecorder(logrun.NewRecorder())
+	testVerify(t, l)
+	t.Logf("out = %q", out)
+	assert.Contains(t, out.String(), "stat ")
+	assert.Zero(t, l.Summary().Changed)
+}
+
+func TestRemoteLogRun_Verify(t *testing.T) {
+	s := newTestSSHServer(t, nil)
+	defer s.Close()
+	r, err := logrun.NewRemoteLogRun(logrun.RemoteConfig{
+		Credentials: s.Credentials(),
+	})
+	require.NoError(t, err)
+	testVerify(t, r)
+}
+
+func TestLocalLogRun_VerifyPackages(t *testing.T) {
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{})
+	stdout, _, code := l.Shell(logrun.PackagesCmd)
+	if code != 0 || stdout == "" {
+		t.Skip("packages cannot be listed on this host")
+	}
+	report, err := l.Verify(&logrun.Manifest{
+		Packages: []logrun.ManifestPackage{
+			{Name: "logrun-no-such-package"},
+			{Name: "logrun-no-such-package", Absent: true},
+		},
+	})
+	require.NoError(t, err)
+	t.Logf("report =\n%s", report)
+	require.Len(t, report.Drifts, 1)
+	assert.Equal(t, logrun.ManifestDrift{
+		Kind:     "package",
+		Name:     "logrun-no-such-package",
+		Property: "installed",
+		Expected: "true",
+		Actual:   "false",
+	}, report.Drifts[0])
+}
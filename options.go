@@ -0,0 +1,371 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"time"
+)
+
+// CallOption configures the commands run through the LogRun returned
+// by With(). Call options override the corresponding settings of the
+// LogRun they were derived from.
+type CallOption func(*callOptions)
+
+// callOptions holds the settings applied by CallOptions.
+type callOptions struct {
+	stdin   io.Reader
+	stdout  io.Writer
+	stderr  io.Writer
+	capture bool
+
+	env     []string
+	dir     string
+	timeout time.Duration
+
+	// ctx, if not nil, cancels the commands that are run, e.g., when
+	// a task of a TaskList times out.
+	ctx context.Context
+
+	stdinFile  string
+	stdoutFile string
+	stdoutMode OutputFileMode
+
+	pidFunc func(pid int)
+	pidFile string
+
+	guards   []guard
+	notify   []string
+	runAs    string
+	fileLock bool
+	pipefail bool
+
+	lineTimestamps bool
+	silent         bool
+
+	// estimate is added to the lines of the commands in a
+	// DryrunPlan.
+	estimate Estimate
+}
+
+// OutputFileMode selects how WithStdoutFile() opens an existing file.
+type OutputFileMode int
+
+const (
+	// OutputTruncate truncates an existing file like the shell's
+	// > redirection.
+	OutputTruncate OutputFileMode = iota
+
+	// OutputAppend appends to an existing file like the shell's
+	// >> redirection.
+	OutputAppend
+)
+
+// WithStdout sends the standard output of commands to w instead of
+// the Stdout writer the LogRun was constructed with. The output is
+// not returned by Run() and Shell().
+func WithStdout(w io.Writer) CallOption {
+	return func(o *callOptions) {
+		o.stdout = w
+		o.stdoutFile = ""
+		o.capture = false
+	}
+}
+
+// WithStderr sends the standard error of commands to w instead of
+// the Stderr writer the LogRun was constructed with. The output is
+// not returned by Run() and Shell().
+func WithStderr(w io.Writer) CallOption {
+	return func(o *callOptions) {
+		o.stderr = w
+		o.capture = false
+	}
+}
+
+// WithStdin reads the standard input of commands from rd instead of
+// the Stdin reader the LogRun was constructed with. Since a reader can
+// only be consumed once, rd is normally used for a single command,
+// e.g.,
+//
+//	runner.With(logrun.WithStdin(strings.NewReader(sql))).Run("psql")
+func WithStdin(rd io.Reader) CallOption {
+	return func(o *callOptions) {
+		o.stdin = rd
+		o.stdinFile = ""
+	}
+}
+
+// WithStdinFile reads the standard input of commands from the file at
+// path on the controller, i.e., the host running the program. The file
+// is opened each time a command is run and the redirection is included
+// in logged commands.
+func WithStdinFile(path string) CallOption {
+	return func(o *callOptions) {
+		o.stdin = nil
+		o.stdinFile = path
+	}
+}
+
+// WithEnv sets environment variables, given as "KEY=VALUE" strings,
+// for commands in addition to the Env the LogRun was constructed with.
+// Variables set by later options and calls take precedence. Values
+// are masked like Env in logged commands.
+func WithEnv(vars ...string) CallOption {
+	return func(o *callOptions) {
+		o.env = append(append([]string{}, o.env...), vars...)
+	}
+}
+
+// WithDir runs commands in dir. A relative dir is relative to the
+// working directory set by PushDir(), if any, or else the directory
+// the LogRun was constructed with. Unlike PushDir(), the directory
+// only applies to the LogRun returned by With().
+func WithDir(dir string) CallOption {
+	return func(o *callOptions) {
+		o.dir = dir
+	}
+}
+
+// WithTimeout overrides the maximum amount of time commands are
+// allowed to run set by SetTimeout(). A timeout of zero uses the
+// timeout of the LogRun.
+func WithTimeout(timeout time.Duration) CallOption {
+	return func(o *callOptions) {
+		o.timeout = timeout
+	}
+}
+
+// WithPipefail runs commands passed to Shell() and the other shell
+// methods with the pipefail shell option set, so a pipeline fails if
+// any of its commands fails rather than only the last one, e.g.,
+//
+//	runner.With(logrun.WithPipefail()).Shell("curl -fsS $URL | sh")
+//
+// The shell must support pipefail, e.g., bash. Shells that do not,
+// such as older versions of dash, the /bin/sh of Ubuntu 18.04, fail
+// with exit code 2 without running the command. The option is ignored
+// on Windows hosts.
+func WithPipefail() CallOption {
+	return func(o *callOptions) {
+		o.pipefail = true
+	}
+}
+
+// WithStdoutFile writes the standard output of commands to the file at
+// path on the controller, i.e., the host running the program. The
+// file is created if it does not exist and mode selects whether an
+// existing file is truncated or appended to. The output is not
+// returned by Run() and Shell() and the redirection is included in
+// logged commands.
+func WithStdoutFile(path string, mode OutputFileMode) CallOption {
+	return func(o *callOptions) {
+		o.stdout = nil
+		o.stdoutFile = path
+		o.stdoutMode = mode
+		o.capture = false
+	}
+}
+
+// WithPIDFunc calls f with the process ID of each command once it has
+// started, e.g., so a supervisor can check on or stop a long-running
+// command while it runs. For remote commands the PID is that of the
+// process on the remote host, which is also its process group ID.
+func WithPIDFunc(f func(pid int)) CallOption {
+	return func(o *callOptions) {
+		o.pidFunc = f
+	}
+}
+
+// WithPIDFile writes the process ID of each command to the file at
+// path on the host running the command once it has started, so later
+// invocations can check on or stop the command after the original
+// connection is gone. Use an absolute path; relative paths are
+// relative to the home directory on remote hosts.
+func WithPIDFile(path string) CallOption {
+	return func(o *callOptions) {
+		o.pidFile = path
+	}
+}
+
+// WithCapturedOutput captures the standard output and error of
+// commands so they are returned by Run() and Shell() even if the
+// LogRun was constructed with Stdout or Stderr writers.
+func WithCapturedOutput() CallOption {
+	return func(o *callOptions) {
+		o.stdout = nil
+		o.stderr = nil
+		o.stdoutFile = ""
+		o.capture = true
+	}
+}
+
+// WithSilent runs commands without passing their messages to the
+// LogFunc or the ResultLogFunc, e.g., for polling commands run every
+// second that would otherwise drown the console:
+//
+//	poller := runner.With(logrun.WithSilent())
+//	for {
+//		_, _, code := poller.Run("systemctl", "is-active", "app")
+//		...
+//	}
+//
+// Commands are still recorded by the Recorder, the ResultStore, and the
+// DryrunPlan, and passed to the LogHook with LogEvent.Silent set, so
+// they remain in the audit trail.
+func WithSilent() CallOption {
+	return func(o *callOptions) {
+		o.silent = true
+	}
+}
+
+// With returns a copy of the LogRun that applies opts to every
+// command it runs. The copy shares the Runner, logging function, and
+// other settings of r, so it is cheap to create one per call, e.g.,
+//
+//	runner.With(logrun.WithStdout(logFile)).Run("make", "all")
+func (r *LogRun) With(opts ...CallOption) *LogRun {
+	c := *r
+	for _, opt := range opts {
+		opt(&c.call)
+	}
+
+	return &c
+}
+
+// applyCallOptions copies the call options of the LogRun into spec.
+// Settings already present in spec take precedence.
+func (r *LogRun) applyCallOptions(spec *execSpec) {
+	if spec.ctx == nil {
+		spec.ctx = r.call.ctx
+	}
+	if spec.stdin == nil {
+		spec.stdin = r.call.stdin
+	}
+	if spec.env == nil {
+		spec.env = r.call.env
+	}
+	if spec.stdout == nil {
+		spec.stdout = r.call.stdout
+	}
+	if spec.stderr == nil {
+		spec.stderr = r.call.stderr
+	}
+	if r.call.capture {
+		spec.capture = true
+	}
+	if spec.onStart == nil {
+		spec.onStart = r.call.pidFunc
+	}
+	if spec.pidFile == "" {
+		spec.pidFile = r.call.pidFile
+	}
+	if spec.runAs == "" {
+		spec.runAs = r.call.runAs
+	}
+}
+
+// shellCmd returns cmd as run by the shell methods, taking
+// WithPipefail() into account.
+func (r *LogRun) shellCmd(cmd string) string {
+	if !r.call.pipefail || r.windowsRunner() != nil {
+		return cmd
+	}
+	if r.localRunner() != nil && runtime.GOOS == "windows" {
+		return cmd
+	}
+
+	return "set -o pipefail; " + cmd
+}
+
+// execDir returns the working directory of the commands run by the
+// LogRun, taking WithDir() into account.
+func (r *LogRun) execDir() string {
+	dir := r.call.dir
+	if dir == "" {
+		return r.Dir()
+	}
+	if cur := r.Dir(); cur != "" && !r.isAbsPath(dir) {
+		dir = r.joinPath(cur, dir)
+	}
+
+	return dir
+}
+
+// callContext returns the context that cancels the commands run by the
+// LogRun.
+func (r *LogRun) callContext() context.Context {
+	if r.call.ctx == nil {
+		return context.Background()
+	}
+
+	return r.call.ctx
+}
+
+// commandTimeout returns the maximum amount of time commands are
+// allowed to run, taking WithTimeout() into account.
+func (r *LogRun) commandTimeout() time.Duration {
+	if r.call.timeout > 0 {
+		return r.call.timeout
+	}
+
+	return r.timeout
+}
+
+// openCallFiles opens the files selected by WithStdinFile() and
+// WithStdoutFile() and connects them to spec unless spec already has
+// its own stdin or stdout. The returned function closes the files.
+func (r *LogRun) openCallFiles(spec *execSpec) (func(), error) {
+	var files []*os.File
+	closeFiles := func() {
+		for _, f := range files {
+			f.Close() // nolint: errcheck
+		}
+	}
+	if r.call.stdinFile != "" && spec.stdin == nil {
+		f, err := os.Open(r.call.stdinFile)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, f)
+		spec.stdin = f
+	}
+	if r.call.stdoutFile != "" && spec.stdout == nil {
+		flag := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+		if r.call.stdoutMode == OutputAppend {
+			flag = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+		}
+		f, err := os.OpenFile(r.call.stdoutFile, flag, 0644)
+		if err != nil {
+			closeFiles()
+			return nil, err
+		}
+		files = append(files, f)
+		spec.stdout = f
+	}
+
+	return closeFiles, nil
+}
+
+// redirections returns the shell notation of the files selected by
+// WithStdinFile() and WithStdoutFile() for logging.
+func (r *LogRun) redirections() string {
+	var s string
+	if r.call.stdinFile != "" {
+		s += fmt.Sprintf(" < %s", shellQuote(r.call.stdinFile))
+	}
+	if r.call.stdoutFile != "" {
+		op := ">"
+		if r.call.stdoutMode == OutputAppend {
+			op = ">>"
+		}
+		s += fmt.Sprintf(" %s %s", op, shellQuote(r.call.stdoutFile))
+	}
+
+	return s
+}
@@ -0,0 +1,286 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apatters/go-logrun"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalLogRun_WithStdout(t *testing.T) {
+	log, out, errOut := newLogger()
+	var b bytes.Buffer
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{
+		LogFunc: log.Println,
+	})
+
+	stdout, stderr, code := l.With(logrun.WithStdout(&b)).Shell("echo build output")
+	t.Logf("stdout = %q", stdout)
+	t.Logf("stderr = %q", stderr)
+	t.Logf("code = %d", code)
+	t.Logf("b = %q", b.String())
+	t.Logf("out = %q", out)
+	assert.Empty(t, stdout)
+	assert.Empty(t, stderr)
+	assert.Zero(t, code)
+	assert.Equal(t, "build output\n", b.String())
+	assert.EqualValues(t, "/bin/sh -c \"echo build output\"\n", out.String())
+	assert.Empty(t, errOut.String())
+
+	// The original LogRun still captures output.
+	stdout, _, _ = l.Shell("echo short")
+	assert.Equal(t, "short\n", stdout)
+	assert.Equal(t, "build output\n", b.String())
+}
+
+func TestLocalLogRun_WithStderr(t *testing.T) {
+	var b bytes.Buffer
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{})
+	stdout, stderr, code := l.With(logrun.WithStderr(&b)).Shell("echo out; echo err >&2")
+	t.Logf("stdout = %q", stdout)
+	t.Logf("stderr = %q", stderr)
+	t.Logf("code = %d", code)
+	assert.Equal(t, "out\n", stdout)
+	assert.Empty(t, stderr)
+	assert.Zero(t, code)
+	assert.Equal(t, "err\n", b.String())
+}
+
+func TestLocalLogRun_WithCapturedOutput(t *testing.T) {
+	var outBuf, errBuf bytes.Buffer
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{
+		Stdout: &outBuf,
+		Stderr: &errBuf,
+	})
+	stdout, stderr, _ := l.Shell("echo streamed")
+	assert.Empty(t, stdout)
+	assert.Empty(t, stderr)
+	assert.Equal(t, "streamed\n", outBuf.String())
+
+	stdout, stderr, code := l.With(logrun.WithCapturedOutput()).Shell("echo out; echo err >&2")
+	t.Logf("stdout = %q", stdout)
+	t.Logf("stderr = %q", stderr)
+	t.Logf("code = %d", code)
+	assert.Equal(t, "out\n", stdout)
+	assert.Equal(t, "err\n", stderr)
+	assert.Zero(t, code)
+	assert.Equal(t, "streamed\n", outBuf.String())
+	assert.Empty(t, errBuf.String())
+
+	// Helper methods always capture the output they parse.
+	exists, err := l.FileExists("/bin/true")
+	assert.NoError(t, err)
+	assert.True(t, exists)
+	assert.Equal(t, "streamed\n", outBuf.String())
+}
+
+func TestLogRun_WithStdinStdoutFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-logrun-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	in := filepath.Join(dir, "in.txt")
+	out := filepath.Join(dir, "out.txt")
+	require.NoError(t, ioutil.WriteFile(in, []byte("hello\n"), 0644))
+
+	log, logOut, _ := newLogger()
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{
+		LogFunc: log.Println,
+	})
+	stdout, _, code := l.With(
+		logrun.WithStdinFile(in),
+		logrun.WithStdoutFile(out, logrun.OutputTruncate),
+	).Run("/bin/cat")
+	assert.Zero(t, code)
+	assert.Empty(t, stdout)
+	stdout, _, code = l.With(
+		logrun.WithStdinFile(in),
+		logrun.WithStdoutFile(out, logrun.OutputAppend),
+	).Shell("tr a-z A-Z")
+	assert.Zero(t, code)
+	assert.Empty(t, stdout)
+	t.Logf("logOut = %q", logOut)
+	content, err := ioutil.ReadFile(out)
+	require.NoError(t, err)
+	assert.Equal(t, "hello\nHELLO\n", string(content))
+	assert.Contains(t, logOut.String(), "/bin/cat < '"+in+"' > '"+out+"'\n")
+	assert.Contains(t, logOut.String(), "/bin/sh -c \"tr a-z A-Z\" < '"+in+"' >> '"+out+"'\n")
+
+	// The output file is truncated.
+	_, _, code = l.With(logrun.WithStdoutFile(out, logrun.OutputTruncate)).Run("/bin/echo", "bye")
+	assert.Zero(t, code)
+	content, err = ioutil.ReadFile(out)
+	require.NoError(t, err)
+	assert.Equal(t, "bye\n", string(content))
+
+	// A missing input file is an execution error.
+	_, stderr, code := l.With(logrun.WithStdinFile(filepath.Join(dir, "missing"))).Run("/bin/cat")
+	t.Logf("stderr = %q", stderr)
+	assert.Equal(t, logrun.ExitErrorExecute, code)
+	assert.Contains(t, stderr, "no such file or directory")
+}
+
+func TestLocalLogRun_WithEnv(t *testing.T) {
+	log, out, errOut := newLogger()
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{
+		LogFunc: log.Println,
+		Env:     []string{"LOGRUN_A=1", "LOGRUN_B=2"},
+	})
+
+	stdout, stderr, code := l.With(logrun.WithEnv("LOGRUN_B=3", "LOGRUN_C=4")).Shell("echo $LOGRUN_A $LOGRUN_B $LOGRUN_C")
+	t.Logf("stdout = %q", stdout)
+	t.Logf("stderr = %q", stderr)
+	t.Logf("code = %d", code)
+	t.Logf("out = %q", out)
+	assert.Equal(t, "1 3 4\n", stdout)
+	assert.Zero(t, code)
+	assert.EqualValues(t,
+		"'LOGRUN_B=3' 'LOGRUN_C=4' /bin/sh -c 'echo $LOGRUN_A $LOGRUN_B $LOGRUN_C'\n",
+		out.String())
+	assert.Empty(t, errOut.String())
+
+	// The environment of the program is inherited if the LogRun
+	// has no Env.
+	require.NoError(t, os.Setenv("LOGRUN_INHERITED", "yes"))
+	defer os.Unsetenv("LOGRUN_INHERITED") // nolint: errcheck
+	l = logrun.NewLocalLogRun(logrun.LocalConfig{})
+	stdout, _, _ = l.With(logrun.WithEnv("LOGRUN_C=4")).Shell("echo $LOGRUN_INHERITED $LOGRUN_C")
+	t.Logf("stdout = %q", stdout)
+	assert.Equal(t, "yes 4\n", stdout)
+	stdout, _, _ = l.Shell("echo $LOGRUN_C")
+	assert.Equal(t, "\n", stdout)
+}
+
+func TestLocalLogRun_WithDir(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "sub"), 0755))
+
+	log, out, _ := newLogger()
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{
+		LogFunc: log.Println,
+	})
+	stdout, _, code := l.With(logrun.WithDir(dir)).Run("pwd")
+	t.Logf("stdout = %q", stdout)
+	t.Logf("out = %q", out)
+	assert.Zero(t, code)
+	assert.Equal(t, dir, strings.TrimSpace(stdout))
+	assert.EqualValues(t, "cd '"+dir+"' && pwd\n", out.String())
+	assert.Empty(t, l.Dir())
+
+	// Relative directories are relative to PushDir().
+	l.PushDir(dir)
+	stdout, _, _ = l.With(logrun.WithDir("sub")).Run("pwd")
+	t.Logf("stdout = %q", stdout)
+	assert.Equal(t, filepath.Join(dir, "sub"), strings.TrimSpace(stdout))
+	assert.Equal(t, dir, l.Dir())
+}
+
+func TestLocalLogRun_WithStdin(t *testing.T) {
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{})
+	stdout, _, code := l.With(logrun.WithStdin(strings.NewReader("b\na\n"))).Run("sort")
+	t.Logf("stdout = %q", stdout)
+	assert.Zero(t, code)
+	assert.Equal(t, "a\nb\n", stdout)
+
+	// WithStdinFile() replaces WithStdin().
+	path := filepath.Join(t.TempDir(), "input")
+	require.NoError(t, ioutil.WriteFile(path, []byte("from file\n"), 0644))
+	stdout, _, _ = l.With(
+		logrun.WithStdin(strings.NewReader("from reader\n")),
+		logrun.WithStdinFile(path)).Run("cat")
+	t.Logf("stdout = %q", stdout)
+	assert.Equal(t, "from file\n", stdout)
+}
+
+func TestLocalLogRun_WithTimeout(t *testing.T) {
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{})
+	l.SetTimeout(5 * time.Second)
+	c := l.With(logrun.WithTimeout(100 * time.Millisecond))
+	assert.Equal(t, 100*time.Millisecond, c.Config().Timeout)
+	assert.Equal(t, 5*time.Second, l.Config().Timeout)
+
+	start := time.Now()
+	_, err := c.ShellResult("sleep 5")
+	t.Logf("err = %v", err)
+	t.Logf("elapsed = %s", time.Since(start))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "timed out after 100ms")
+	assert.True(t, time.Since(start) < 4*time.Second)
+}
+
+func TestLocalLogRun_WithPipefail(t *testing.T) {
+	log, out, _ := newLogger()
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{
+		LogFunc:         log.Println,
+		ShellExecutable: "/bin/bash",
+	})
+	_, _, code := l.Shell("false | cat")
+	t.Logf("code = %d", code)
+	assert.Zero(t, code)
+
+	c := l.With(logrun.WithPipefail())
+	out.Reset()
+	_, _, code = c.Shell("false | cat")
+	t.Logf("code = %d", code)
+	t.Logf("out = %q", out)
+	assert.Equal(t, 1, code)
+	assert.EqualValues(t, "/bin/bash -c \"set -o pipefail; false | cat\"\n", out.String())
+	assert.Equal(t, `/bin/bash -c "set -o pipefail; true | cat"`, c.FormatShell("true | cat"))
+
+	res, err := c.ShellResult("exit 3 | cat")
+	t.Logf("err = %v", err)
+	assert.Equal(t, 3, res.ExitCode)
+
+	// Other methods are not affected.
+	stdout, _, code := c.Run("echo", "a")
+	assert.Zero(t, code)
+	assert.Equal(t, "a\n", stdout)
+}
+
+func TestLocalLogRun_WithSilent(t *testing.T) {
+	log, out, _ := newLogger()
+	resultLog, resultOut, _ := newLogger()
+	var events []logrun.LogEvent
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{
+		LogFunc: log.Println,
+		LogHook: logrun.LogHookFunc(func(e logrun.LogEvent) {
+			events = append(events, e)
+		}),
+	})
+	l.SetRecorder(logrun.NewRecorder())
+	l.SetResultLogFunc(resultLog.Println)
+
+	c := l.With(logrun.WithSilent())
+	stdout, _, code := c.Run("/bin/echo", "poll")
+	t.Logf("out = %q", out)
+	t.Logf("resultOut = %q", resultOut)
+	assert.Zero(t, code)
+	assert.Equal(t, "poll\n", stdout)
+	assert.Empty(t, out.String())
+	assert.Empty(t, resultOut.String())
+
+	// The command is still recorded and passed to the LogHook.
+	require.Len(t, events, 1)
+	assert.Equal(t, "/bin/echo poll", events[0].Message)
+	assert.True(t, events[0].Silent)
+	commands := l.Recorder().Commands()
+	require.Len(t, commands, 1)
+	assert.Equal(t, "/bin/echo poll", commands[0].Command)
+
+	// Other commands are logged as usual.
+	_, _, code = l.Run("/bin/true")
+	assert.Zero(t, code)
+	assert.Equal(t, "/bin/true\n", out.String())
+	require.Len(t, events, 2)
+	assert.False(t, events[1].Silent)
+}
@@ -0,0 +1,110 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+)
+
+// RunCombined first logs the command and then runs it like Run(), but
+// returns its standard output and standard error interleaved in the
+// order they were received, like exec.Cmd.CombinedOutput(), along with
+// its exit code. If the command could not be run, the error is
+// returned as the output along with ExitErrorExecute.
+func (r *LogRun) RunCombined(cmd string, args ...string) (string, int) {
+	return r.combined(execSpec{cmd: cmd, args: args})
+}
+
+// ShellCombined first logs the command and then runs it in a shell
+// like Shell(), but returns its standard output and standard error
+// interleaved. See RunCombined().
+func (r *LogRun) ShellCombined(cmd string) (string, int) {
+	return r.combined(execSpec{cmd: r.shellCmd(cmd), shell: true})
+}
+
+// RunLines first logs the command and then runs it like Run(), but
+// returns its standard output as a slice of lines with leading and
+// trailing white space removed. Blank lines are dropped.
+func (r *LogRun) RunLines(cmd string, args ...string) ([]string, string, int) {
+	stdout, stderr, code := r.Run(cmd, args...)
+
+	return trimmedLines(stdout), stderr, code
+}
+
+// ShellLines first logs the command and then runs it in a shell like
+// Shell(), but returns its standard output as a slice of lines. See
+// RunLines().
+func (r *LogRun) ShellLines(cmd string) ([]string, string, int) {
+	stdout, stderr, code := r.Shell(cmd)
+
+	return trimmedLines(stdout), stderr, code
+}
+
+// RunTrimmed first logs the command and then runs it like Run(), but
+// returns its standard output and standard error with leading and
+// trailing white space, e.g., the final newline, removed.
+func (r *LogRun) RunTrimmed(cmd string, args ...string) (string, string, int) {
+	stdout, stderr, code := r.Run(cmd, args...)
+
+	return strings.TrimSpace(stdout), strings.TrimSpace(stderr), code
+}
+
+// ShellTrimmed first logs the command and then runs it in a shell like
+// Shell(), but returns its output with leading and trailing white space
+// removed. See RunTrimmed().
+func (r *LogRun) ShellTrimmed(cmd string) (string, string, int) {
+	stdout, stderr, code := r.Shell(cmd)
+
+	return strings.TrimSpace(stdout), strings.TrimSpace(stderr), code
+}
+
+func (r *LogRun) combined(spec execSpec) (string, int) {
+	out := new(combinedBuffer)
+	spec.stdout = out
+	spec.stderr = out
+	// The output is returned without timestamps.
+	c := *r
+	c.call.lineTimestamps = false
+	res := c.logAndExecute(spec)
+	if res.Err != nil {
+		return res.Err.Error(), ExitErrorExecute
+	}
+
+	return r.decodeOutput(out.String()), res.ExitCode
+}
+
+// trimmedLines returns the non-blank lines of output with leading and
+// trailing white space removed.
+func trimmedLines(output string) []string {
+	ls := lines(output)
+	for i, line := range ls {
+		ls[i] = strings.TrimSpace(line)
+	}
+
+	return ls
+}
+
+// combinedBuffer is a bytes.Buffer that standard output and standard
+// error can be written to concurrently.
+type combinedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *combinedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.buf.Write(p)
+}
+
+func (b *combinedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.buf.String()
+}
@@ -0,0 +1,90 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun_test
+
+import (
+	"testing"
+
+	"github.com/apatters/go-logrun"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalLogRun_RunCombined(t *testing.T) {
+	log, out, _ := newLogger()
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{
+		LogFunc: log.Println,
+	})
+	output, code := l.ShellCombined("echo one; echo two >&2; echo three; exit 2")
+	t.Logf("output = %q", output)
+	t.Logf("out = %q", out)
+	assert.Equal(t, 2, code)
+	assert.Equal(t, "one\ntwo\nthree\n", output)
+	assert.Equal(t, "/bin/sh -c \"echo one; echo two >&2; echo three; exit 2\"\n", out.String())
+
+	output, code = l.RunCombined("/does/not/exist")
+	t.Logf("output = %q", output)
+	assert.Equal(t, logrun.ExitErrorExecute, code)
+	assert.NotEmpty(t, output)
+}
+
+func TestLocalLogRun_RunLines(t *testing.T) {
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{})
+	lines, stderr, code := l.RunLines("/usr/bin/printf", `  one\n\ntwo \r\n\tthree`)
+	t.Logf("lines = %q", lines)
+	assert.Zero(t, code)
+	assert.Empty(t, stderr)
+	assert.Equal(t, []string{"one", "two", "three"}, lines)
+
+	lines, stderr, code = l.ShellLines("echo oops >&2; exit 1")
+	assert.Equal(t, 1, code)
+	assert.Equal(t, "oops\n", stderr)
+	assert.Empty(t, lines)
+}
+
+func TestLocalLogRun_RunTrimmed(t *testing.T) {
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{})
+	stdout, stderr, code := l.RunTrimmed("/usr/bin/printf", `  3.10.0\n\n`)
+	assert.Zero(t, code)
+	assert.Empty(t, stderr)
+	assert.Equal(t, "3.10.0", stdout)
+
+	stdout, stderr, code = l.ShellTrimmed("echo ok; echo warning >&2")
+	assert.Zero(t, code)
+	assert.Equal(t, "ok", stdout)
+	assert.Equal(t, "warning", stderr)
+}
+
+func TestRemoteLogRun_RunCombined(t *testing.T) {
+	s := newTestSSHServer(t, nil)
+	defer s.Close()
+
+	r, err := logrun.NewRemoteLogRun(logrun.RemoteConfig{
+		Credentials: s.Credentials(),
+	})
+	require.NoError(t, err)
+	output, code := r.ShellCombined("echo a; sleep 0.1; echo b >&2; sleep 0.1; echo c")
+	t.Logf("output = %q", output)
+	assert.Zero(t, code)
+	assert.Equal(t, "a\nb\nc\n", output)
+}
+
+func TestStdRunLogger_RunLines(t *testing.T) {
+	log, out, _ := newLogger()
+	logrun.SetLogFunc(log.Println)
+	defer logrun.SetLogFunc(logrun.DiscardLogFunc)
+
+	lines, stderr, code := logrun.RunLines("/usr/bin/seq", "3")
+	t.Logf("out = %q", out)
+	assert.Zero(t, code)
+	assert.Empty(t, stderr)
+	assert.Equal(t, []string{"1", "2", "3"}, lines)
+	assert.Equal(t, "/usr/bin/seq 3\n", out.String())
+
+	stdout, _, _ := logrun.ShellTrimmed("echo hello")
+	assert.Equal(t, "hello", stdout)
+	output, _ := logrun.RunCombined("/usr/bin/seq", "1")
+	assert.Equal(t, "1\n", output)
+}
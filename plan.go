@@ -0,0 +1,222 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PlanScriptHeader is written at the beginning of the scripts exported
+// by DryrunPlan.ExportScript().
+var PlanScriptHeader = `#!/bin/bash
+# Commands collected by a dry run. Review them before running this
+# script. Masked values of secret environment variables must be filled
+# in by hand.
+set -euo pipefail
+`
+
+// DryrunPlan collects the commands a LogRun would run while Dryrun is
+// true, so an operator can review them and run them by hand. See
+// SetDryrunPlan().
+type DryrunPlan struct {
+	mu       sync.Mutex
+	lines    []string
+	duration time.Duration
+}
+
+// Lines returns the lines of the script in the order they were
+// collected. The changes helpers such as PutFileString(), MkdirAll(),
+// and Rsync() would make are included as the commands that make them,
+// even if the helper uses SFTP or, on local hosts, the os package.
+// Other messages, e.g., section headings and the commands helpers run
+// to inspect the host, are included as comments.
+func (p *DryrunPlan) Lines() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return append([]string{}, p.lines...)
+}
+
+// Duration returns the sum of the estimated durations of the
+// commands and helpers in the plan declared using WithEstimate().
+func (p *DryrunPlan) Duration() time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.duration
+}
+
+// add appends line to the plan followed by estimate as a comment, if
+// any.
+func (p *DryrunPlan) add(line string, estimate Estimate) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if s := estimate.String(); s != "" {
+		line += " # " + trf("estimate: %s", s)
+	}
+	p.lines = append(p.lines, line)
+	p.duration += estimate.Duration
+}
+
+// ExportScript writes the plan to w as a bash script, starting with
+// PlanScriptHeader and, if estimates were declared, the estimated
+// total duration. Commands for remote hosts are run using ssh and
+// commands for containers using docker exec. Commands for Windows
+// hosts are included as comments.
+func (p *DryrunPlan) ExportScript(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString(tr(PlanScriptHeader)) // nolint: errcheck
+	if d := p.Duration(); d > 0 {
+		bw.WriteString("# " + trf("Estimated duration: %s", d) + "\n") // nolint: errcheck
+	}
+	for _, line := range p.Lines() {
+		bw.WriteString(line) // nolint: errcheck
+		bw.WriteString("\n") // nolint: errcheck
+	}
+
+	return bw.Flush()
+}
+
+// SetDryrunPlan collects the commands that are only logged because
+// Dryrun is true in plan, if plan is not nil. See Plan().
+func (r *LogRun) SetDryrunPlan(plan *DryrunPlan) {
+	r.plan = plan
+}
+
+// Plan runs task with Dryrun enabled using a copy of the LogRun and
+// returns the plan of the commands it would run, e.g.,
+//
+//	plan, err := runner.Plan(deploy)
+//	...
+//	err = plan.ExportScript(os.Stdout)
+//
+// The error returned by task is passed on.
+func (r *LogRun) Plan(task func(r *LogRun) error) (*DryrunPlan, error) {
+	plan := new(DryrunPlan)
+	c := r.With()
+	c.SetDryrun(true)
+	c.SetDryrunPlan(plan)
+	err := task(c)
+
+	return plan, err
+}
+
+// planning returns true if messages are collected in a DryrunPlan.
+func (r *LogRun) planning() bool {
+	return r.plan != nil && r.Dryrun
+}
+
+// planComment adds msg to the plan as a comment.
+func (r *LogRun) planComment(msg string) {
+	r.plan.add(strings.Repeat(SectionIndent, len(r.sections))+"# "+msg, Estimate{})
+}
+
+// planCommand adds the command described by spec, which is logged as
+// msg, to the plan.
+func (r *LogRun) planCommand(spec execSpec, msg string) {
+	line, ok := r.scriptLine(spec)
+	if !ok {
+		r.planComment(msg)
+		return
+	}
+	r.plan.add(strings.Repeat(SectionIndent, len(r.sections))+line, r.planEstimate())
+}
+
+// contentInput returns a command that outputs content, e.g., to be
+// piped to a command that writes it to a file.
+func contentInput(content string) string {
+	return "printf '%s' " + shellQuote(content)
+}
+
+// scriptLine returns the command described by spec as a line of a
+// bash script run on the controller. False is returned if the command
+// cannot be expressed that way.
+func (r *LogRun) scriptLine(spec execSpec) (string, bool) {
+	r.applyCallOptions(&spec)
+	if spec.dir == "" {
+		spec.dir = r.execDir()
+	}
+	var line string
+	switch runner := r.Runner.(type) {
+	case *localRunner:
+		_, env := r.execContext(&spec)
+		line = scriptCommandLine(&spec, runner.shellExecutable, runner.workDir(&spec), env, runAsUser(&spec, runner.runAs), SudoOptions{})
+	case *remoteRunner:
+		if runner.remoteOS == RemoteWindows {
+			return "", false
+		}
+		env := appendEnv(runner.env, spec.env)
+		cmdLine := scriptCommandLine(&spec, runner.shellExecutable, runner.workDir(&spec), env, runAsUser(&spec, runner.runAs), runner.sudo)
+		words := []string{"ssh"}
+		if port := runner.credentials.Port; port != 0 && port != defaultSSHPort {
+			words = append(words, "-p", strconv.Itoa(port))
+		}
+		words = append(words, shellQuote(runner.credentials.Username+"@"+runner.credentials.Hostname), shellQuote(cmdLine))
+		line = strings.Join(words, " ")
+	case *dockerRunner:
+		words := []string{"docker", "exec"}
+		if user := runAsUser(&spec, runner.runAs); user != "" {
+			words = append(words, "-u", shellQuote(user))
+		}
+		if dir := runner.workDir(&spec); dir != "" {
+			words = append(words, "-w", shellQuote(dir))
+		}
+		for _, kv := range maskEnv(appendEnv(runner.env, spec.env)) {
+			words = append(words, "-e", shellQuote(kv))
+		}
+		words = append(words, shellQuote(runner.containerID))
+		words = append(words, scriptCommandLine(&spec, runner.shellExecutable, "", nil, "", SudoOptions{}))
+		line = strings.Join(words, " ")
+	default:
+		return "", false
+	}
+	line += r.redirections()
+	if spec.planOutput != "" {
+		line += " > " + shellQuote(spec.planOutput)
+	}
+	if spec.planInput != "" {
+		line = spec.planInput + " | " + line
+	}
+
+	return line, true
+}
+
+// scriptCommandLine returns the command described by spec with its
+// words quoted for a POSIX shell, run in dir with the variables env
+// set as user, if not empty, using sudo with the options selected by
+// sudo. Values of secret variables are masked.
+func scriptCommandLine(spec *execSpec, shell string, dir string, env []string, user string, sudo SudoOptions) string {
+	var words []string
+	if spec.shell {
+		words = []string{shellQuote(shell), "-c", shellQuote(spec.cmd)}
+	} else {
+		words = []string{shellQuote(spec.cmd)}
+		for _, arg := range spec.args {
+			words = append(words, shellQuote(arg))
+		}
+	}
+	cmdLine := strings.Join(words, " ")
+	if len(env) > 0 {
+		vars := []string{shellQuote(EnvCmd)}
+		for _, kv := range maskEnv(env) {
+			vars = append(vars, shellQuote(kv))
+		}
+		cmdLine = strings.Join(vars, " ") + " " + cmdLine
+	}
+	if user != "" {
+		cmdLine = runAsCommandLine(user, cmdLine, sudo)
+	}
+	if dir != "" {
+		cmdLine = fmt.Sprintf("cd %s && %s", shellQuote(dir), cmdLine)
+	}
+
+	return cmdLine
+}
@@ -0,0 +1,229 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apatters/go-logrun"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalLogRun_Plan(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "it's done")
+
+	log, out, _ := newLogger()
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{LogFunc: log.Println})
+	failure := errors.New("task failed")
+	plan, err := l.Plan(func(r *logrun.LogRun) error {
+		return r.WithSection("deploy", func() error {
+			r.Run("/usr/bin/touch", target)
+			r.With(logrun.WithDir(dir), logrun.WithEnv("NAME=a b", "API_TOKEN=secret")).Shell(`echo "$NAME" > name`)
+			_, err := r.PutFileString(filepath.Join(dir, "app.conf"), "a = 1\n", 0644)
+			require.NoError(t, err)
+			return failure
+		})
+	})
+	t.Logf("out = %q", out)
+	assert.Equal(t, failure, err)
+	assert.False(t, l.Dryrun)
+	lines := plan.Lines()
+	for _, line := range lines {
+		t.Logf("%s", line)
+	}
+	require.Len(t, lines, 5)
+	assert.Equal(t, "# === deploy", lines[0])
+	assert.Equal(t, "  '/usr/bin/touch' '"+strings.Replace(target, "'", `'"'"'`, -1)+"'", lines[1])
+	assert.Equal(t,
+		"  cd '"+dir+"' && '/usr/bin/env' 'NAME=a b' 'API_TOKEN=********' '/bin/sh' -c 'echo \"$NAME\" > name'",
+		lines[2])
+	assert.True(t, strings.HasPrefix(lines[3], "  # get "))
+	assert.True(t, strings.HasPrefix(lines[4], `  printf '%s' 'a = 1
+' | '/bin/sh' -c 'tmp=$(mktemp `), lines[4])
+
+	// The exported script can be run.
+	var script strings.Builder
+	require.NoError(t, plan.ExportScript(&script))
+	t.Logf("script = %s", script.String())
+	assert.True(t, strings.HasPrefix(script.String(), logrun.PlanScriptHeader))
+	cmd := exec.Command("/bin/bash", "-c", script.String())
+	output, err := cmd.CombinedOutput()
+	t.Logf("output = %q", output)
+	require.NoError(t, err)
+	assert.FileExists(t, target)
+	content, err := ioutil.ReadFile(filepath.Join(dir, "name"))
+	require.NoError(t, err)
+	assert.Equal(t, "a b\n", string(content))
+	content, err = ioutil.ReadFile(filepath.Join(dir, "app.conf"))
+	require.NoError(t, err)
+	assert.Equal(t, "a = 1\n", string(content))
+	fi, err := os.Stat(filepath.Join(dir, "app.conf"))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0644), fi.Mode().Perm())
+
+	// Commands are only collected in Dryrun.
+	plan = new(logrun.DryrunPlan)
+	l.SetDryrunPlan(plan)
+	l.Run("/bin/true")
+	assert.Empty(t, plan.Lines())
+}
+
+func TestRemoteLogRun_Plan(t *testing.T) {
+	r, err := logrun.NewRemoteLogRun(logrun.RemoteConfig{
+		Credentials: logrun.Credentials{
+			Hostname: "db1",
+			Port:     2222,
+			Username: "admin",
+			Password: "secret",
+		},
+		RunAs: "postgres",
+	})
+	require.NoError(t, err)
+	plan, err := r.Plan(func(r *logrun.LogRun) error {
+		r.Run("psql", "-c", "select 'x'")
+		return nil
+	})
+	require.NoError(t, err)
+	lines := plan.Lines()
+	t.Logf("lines = %q", lines)
+	require.Len(t, lines, 1)
+	assert.Equal(t,
+		`ssh -p 2222 'admin@db1' 'sudo -n -u '"'"'postgres'"'"' -- '"'"'psql'"'"' '"'"'-c'"'"' '"'"'select '"'"'"'"'"'"'"'"'x'"'"'"'"'"'"'"'"''"'"''`,
+		lines[0])
+}
+
+func TestLocalLogRun_PlanHelpers(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "app.bin")
+	require.NoError(t, ioutil.WriteFile(src, []byte("binary"), 0600))
+	conf := filepath.Join(dir, "a", "b", "app.conf")
+
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{})
+	plan, err := l.Plan(func(r *logrun.LogRun) error {
+		require.NoError(t, r.MkdirAll(filepath.Join(dir, "a", "b"), 0750))
+		_, err := r.PutFileString(conf, "listen 80\n", 0600)
+		require.NoError(t, err)
+		require.NoError(t, r.Chmod(conf, 0640))
+		require.NoError(t, r.Upload(src, filepath.Join(dir, "a", "b", "app.bin")))
+		require.NoError(t, r.Download(conf, filepath.Join(dir, "downloaded.conf")))
+		require.NoError(t, r.Symlink(filepath.Join(dir, "a", "b"), filepath.Join(dir, "current")))
+		return nil
+	})
+	require.NoError(t, err)
+	var script strings.Builder
+	require.NoError(t, plan.ExportScript(&script))
+	t.Logf("script = %s", script.String())
+	_, err = os.Stat(filepath.Join(dir, "a"))
+	require.True(t, os.IsNotExist(err))
+
+	// The script makes the changes of the helpers.
+	output, err := exec.Command("/bin/bash", "-c", script.String()).CombinedOutput()
+	t.Logf("output = %q", output)
+	require.NoError(t, err)
+	content, err := ioutil.ReadFile(conf)
+	require.NoError(t, err)
+	assert.Equal(t, "listen 80\n", string(content))
+	fi, err := os.Stat(conf)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0640), fi.Mode().Perm())
+	content, err = ioutil.ReadFile(filepath.Join(dir, "current", "app.bin"))
+	require.NoError(t, err)
+	assert.Equal(t, "binary", string(content))
+	content, err = ioutil.ReadFile(filepath.Join(dir, "downloaded.conf"))
+	require.NoError(t, err)
+	assert.Equal(t, "listen 80\n", string(content))
+}
+
+func TestRemoteLogRun_PlanHelpers(t *testing.T) {
+	r, err := logrun.NewRemoteLogRun(logrun.RemoteConfig{
+		Credentials: logrun.Credentials{Hostname: "db1", Username: "admin", Password: "secret"},
+	})
+	require.NoError(t, err)
+	plan, err := r.Plan(func(r *logrun.LogRun) error {
+		require.NoError(t, r.MkdirAll("/srv/app", 0750))
+		_, err := r.PutFileString("/srv/app/app.conf", "listen 80\n", 0600)
+		require.NoError(t, err)
+		return r.Rsync("/srv/app/", "/srv/backup/")
+	})
+	require.NoError(t, err)
+	lines := plan.Lines()
+	for _, line := range lines {
+		t.Logf("%s", line)
+	}
+	require.Len(t, lines, 4)
+	assert.Equal(t, `ssh 'admin@db1' ''"'"'/bin/mkdir'"'"' '"'"'-p'"'"' '"'"'-m'"'"' '"'"'750'"'"' '"'"'/srv/app'"'"''`, lines[0])
+	assert.Equal(t, "# ssh admin@db1 /bin/cat /srv/app/app.conf", lines[1])
+	assert.True(t, strings.HasPrefix(lines[2], `printf '%s' 'listen 80
+' | ssh 'admin@db1' ''"'"'/bin/sh'"'"' -c '"'"'tmp=$(mktemp `), lines[2])
+	assert.True(t, strings.HasPrefix(lines[3], `ssh 'admin@db1' ''"'"'/usr/bin/rsync'"'"' `), lines[3])
+}
+
+func TestLogRun_PlanEstimate(t *testing.T) {
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{})
+	plan, err := l.Plan(func(r *logrun.LogRun) error {
+		r.With(logrun.WithEstimate(logrun.Estimate{
+			Duration: 5 * time.Minute,
+			Impact:   "rsync ~2.3 GB",
+		})).Run("/bin/true")
+		r.With(logrun.WithEstimate(logrun.Estimate{Impact: "service restart: brief outage"})).Run("/bin/false")
+		r.With(logrun.WithEstimate(logrun.Estimate{Duration: 30 * time.Second})).Shell("sleep 30")
+		r.Run("/bin/echo")
+		return nil
+	})
+	require.NoError(t, err)
+	lines := plan.Lines()
+	for _, line := range lines {
+		t.Logf("%s", line)
+	}
+	assert.Equal(t, []string{
+		"'/bin/true' # estimate: ~5m0s, rsync ~2.3 GB",
+		"'/bin/false' # estimate: service restart: brief outage",
+		"'/bin/sh' -c 'sleep 30' # estimate: ~30s",
+		"'/bin/echo'",
+	}, lines)
+	assert.Equal(t, 5*time.Minute+30*time.Second, plan.Duration())
+
+	var script strings.Builder
+	require.NoError(t, plan.ExportScript(&script))
+	assert.True(t, strings.HasPrefix(script.String(), logrun.PlanScriptHeader+"# Estimated duration: 5m30s\n"))
+}
+
+func TestLogRun_PlanEstimateHelpers(t *testing.T) {
+	dir := t.TempDir()
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{})
+	plan, err := l.Plan(func(r *logrun.LogRun) error {
+		c := r.With(logrun.WithEstimate(logrun.Estimate{Duration: time.Minute}))
+		// PutFileString reads the file before writing it.
+		if _, err := c.PutFileString(filepath.Join(dir, "app.conf"), "a = 1\n", 0644); err != nil {
+			return err
+		}
+		if err := c.MkdirAll(filepath.Join(dir, "data"), 0750); err != nil {
+			return err
+		}
+		c.With(logrun.OnlyIf("test -d /srv")).Run("/bin/echo")
+		return nil
+	})
+	require.NoError(t, err)
+	lines := plan.Lines()
+	for _, line := range lines {
+		t.Logf("%s", line)
+	}
+	require.Len(t, lines, 5)
+	assert.Equal(t, "# get "+filepath.Join(dir, "app.conf"), lines[0])
+	assert.True(t, strings.HasSuffix(lines[1], " # estimate: ~1m0s"), lines[1])
+	assert.Equal(t, "'/bin/mkdir' '-p' '-m' '750' '"+filepath.Join(dir, "data")+"' # estimate: ~1m0s", lines[2])
+	assert.Equal(t, `# /bin/sh -c "test -d /srv"`, lines[3])
+	assert.Equal(t, "'/bin/echo' # estimate: ~1m0s", lines[4])
+	assert.Equal(t, 3*time.Minute, plan.Duration())
+}
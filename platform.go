@@ -0,0 +1,37 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun
+
+// The local runner adapts to the operating system of the controller
+// it is built for. The platform_*.go files define the default shell
+// and the Capabilities assumed for local helper methods on each
+// platform:
+//
+//	Platform   Shell()       File helpers   Rsync()
+//	linux      /bin/sh -c    os package     RsyncCmd
+//	darwin     /bin/sh -c    os package     RsyncCmd
+//	windows    cmd.exe /c    os package     not available
+//	other      /bin/sh -c    os package     RsyncCmd
+//
+// The file helpers are FileExists(), DirExists(), Glob(),
+// GetFileString(), and PutFileString(). The other helpers, e.g.,
+// DiskUsage(), still run the external commands defined by the *Cmd
+// variables, which assume a RHEL/CentOS 7 or Ubuntu 18.04 host.
+// Remote hosts are not affected by the platform of the controller.
+
+// LocalCapabilities returns the Capabilities assumed for commands run
+// locally when capability probing is disabled.
+func LocalCapabilities() Capabilities {
+	return localCapabilities
+}
+
+// shellOption returns the option used to pass a command to shell.
+func shellOption(shell string) string {
+	if isCmdShell(shell) {
+		return "/c"
+	}
+
+	return "-c"
+}
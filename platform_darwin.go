@@ -0,0 +1,20 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+//go:build darwin
+// +build darwin
+
+package logrun
+
+// localShellExecutable returns the default shell of local runners.
+func localShellExecutable() string {
+	return DefaultShellExecutable
+}
+
+// localCapabilities are the capabilities of the BSD userland of
+// macOS, which includes rsync.
+var localCapabilities = Capabilities{
+	BSDStat: true,
+	Rsync:   true,
+}
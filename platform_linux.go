@@ -0,0 +1,21 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+//go:build linux
+// +build linux
+
+package logrun
+
+// localShellExecutable returns the default shell of local runners.
+func localShellExecutable() string {
+	return DefaultShellExecutable
+}
+
+// localCapabilities are the capabilities of the GNU userland of
+// Linux distributions.
+var localCapabilities = Capabilities{
+	GNUStat:     true,
+	LsDirectory: true,
+	Rsync:       true,
+}
@@ -0,0 +1,20 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+//go:build !linux && !darwin && !windows
+// +build !linux,!darwin,!windows
+
+package logrun
+
+// localShellExecutable returns the default shell of local runners.
+func localShellExecutable() string {
+	return DefaultShellExecutable
+}
+
+// localCapabilities are the capabilities of the BSD userland of
+// other Unix systems. rsync is assumed to be installed.
+var localCapabilities = Capabilities{
+	BSDStat: true,
+	Rsync:   true,
+}
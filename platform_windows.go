@@ -0,0 +1,18 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+//go:build windows
+// +build windows
+
+package logrun
+
+// localShellExecutable returns the default shell of local runners.
+// DefaultShellExecutable is not used since it is a POSIX shell.
+func localShellExecutable() string {
+	return "cmd.exe"
+}
+
+// localCapabilities are empty since Windows has none of the external
+// commands used by the helpers.
+var localCapabilities = Capabilities{}
@@ -0,0 +1,247 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// PoolConfig is used to set options in the NewPool and NewRemotePool
+// constructors.
+type PoolConfig struct {
+	// Concurrency is the maximum number of hosts commands are run
+	// on at the same time. If zero, commands are run on all hosts
+	// at the same time.
+	Concurrency int
+
+	// Vars are the group variables inherited by the runners of
+	// the Pool. See SetVars().
+	Vars map[string]string
+}
+
+// Pool runs the same commands on a set of hosts concurrently.
+type Pool struct {
+	runners     []*LogRun
+	hosts       []string
+	concurrency int
+}
+
+// PoolResults are the results of a command run on the hosts of a
+// Pool keyed by hostname.
+type PoolResults map[string]Result
+
+// NewPool returns a Pool that runs commands using runners. Each runner
+// must be for a different host. Results are keyed by the hostname of
+// each runner as returned by Host() with the port appended if it is
+// not the default SSH port, e.g., "web1" or "web1:2222".
+func NewPool(runners []*LogRun, config PoolConfig) (*Pool, error) {
+	p := &Pool{
+		runners:     runners,
+		concurrency: config.Concurrency,
+	}
+	seen := make(map[string]bool)
+	for _, r := range runners {
+		host := poolHost(r.Host())
+		if seen[host] {
+			return nil, fmt.Errorf("duplicate host %s in pool", host)
+		}
+		seen[host] = true
+		p.hosts = append(p.hosts, host)
+	}
+	if config.Vars != nil {
+		p.SetVars(config.Vars)
+	}
+
+	return p, nil
+}
+
+// NewRemotePool returns a Pool that runs commands on the hosts
+// described by configs using NewRemoteLogRun().
+func NewRemotePool(configs []RemoteConfig, config PoolConfig) (*Pool, error) {
+	var runners []*LogRun
+	for _, c := range configs {
+		r, err := NewRemoteLogRun(c)
+		if err != nil {
+			return nil, err
+		}
+		runners = append(runners, r)
+	}
+
+	return NewPool(runners, config)
+}
+
+// SetConcurrency sets the maximum number of hosts commands are run on
+// at the same time. If zero, commands are run on all hosts at the same
+// time.
+func (p *Pool) SetConcurrency(n int) {
+	p.concurrency = n
+}
+
+// SetVars sets the group variables of the Pool, which its runners
+// inherit for use in command templates, e.g., the port shared by a
+// group of web servers. Variables set for a host using
+// LogRun.SetVars() override them. SetVars replaces the group
+// variables set earlier and must not be called while commands are
+// running.
+func (p *Pool) SetVars(vars map[string]string) {
+	groupVars := make(map[string]string, len(vars))
+	for k, v := range vars {
+		groupVars[k] = v
+	}
+	for _, r := range p.runners {
+		r.groupVars = groupVars
+	}
+}
+
+// Runners returns the runners of the Pool, e.g., to change their
+// settings.
+func (p *Pool) Runners() []*LogRun {
+	return append([]*LogRun{}, p.runners...)
+}
+
+// Hosts returns the hostnames of the Pool in the order the runners
+// were given to NewPool().
+func (p *Pool) Hosts() []string {
+	return append([]string{}, p.hosts...)
+}
+
+// Select returns a Pool of the hosts whose tags, see SetTags(), match
+// selector, e.g., "role=web && dc=us-east", so operations can be run
+// against a subset of the hosts. See ParseSelector() for the syntax.
+// The returned Pool uses the runners and the concurrency limit of p,
+// so closing either closes the selected runners.
+func (p *Pool) Select(selector string) (*Pool, error) {
+	sel, err := ParseSelector(selector)
+	if err != nil {
+		return nil, err
+	}
+	sub := &Pool{concurrency: p.concurrency}
+	for i, r := range p.runners {
+		if sel.Matches(r.tags) {
+			sub.runners = append(sub.runners, r)
+			sub.hosts = append(sub.hosts, p.hosts[i])
+		}
+	}
+
+	return sub, nil
+}
+
+// Run runs cmd with args on every host of the Pool like RunResult().
+func (p *Pool) Run(cmd string, args ...string) PoolResults {
+	return p.results(func(r *LogRun) Result {
+		res, _ := r.RunResult(cmd, args...)
+		return res
+	})
+}
+
+// Shell runs cmd in a shell on every host of the Pool like
+// ShellResult().
+func (p *Pool) Shell(cmd string) PoolResults {
+	return p.results(func(r *LogRun) Result {
+		res, _ := r.ShellResult(cmd)
+		return res
+	})
+}
+
+// Each calls f with the runner of every host of the Pool, honoring the
+// concurrency limit, and returns the errors returned by f keyed by
+// hostname. Hosts for which f returned nil are not included.
+func (p *Pool) Each(f func(r *LogRun) error) map[string]error {
+	var mu sync.Mutex
+	errs := make(map[string]error)
+	p.each(func(i int, r *LogRun) {
+		if err := f(r); err != nil {
+			mu.Lock()
+			errs[p.hosts[i]] = err
+			mu.Unlock()
+		}
+	})
+
+	return errs
+}
+
+// Close closes the runners of the Pool, e.g., their shared SSH
+// connections, and returns the first error.
+func (p *Pool) Close() error {
+	var first error
+	for _, r := range p.runners {
+		if err := r.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+
+	return first
+}
+
+func (p *Pool) results(f func(r *LogRun) Result) PoolResults {
+	var mu sync.Mutex
+	results := make(PoolResults, len(p.runners))
+	p.each(func(i int, r *LogRun) {
+		res := f(r)
+		mu.Lock()
+		results[p.hosts[i]] = res
+		mu.Unlock()
+	})
+
+	return results
+}
+
+// each calls f concurrently with the index and runner of every host,
+// at most p.concurrency at a time, and waits for the calls to return.
+func (p *Pool) each(f func(i int, r *LogRun)) {
+	n := p.concurrency
+	if n <= 0 || n > len(p.runners) {
+		n = len(p.runners)
+	}
+	sem := make(chan struct{}, n)
+	var wg sync.WaitGroup
+	for i, r := range p.runners {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, r *LogRun) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			f(i, r)
+		}(i, r)
+	}
+	wg.Wait()
+}
+
+// poolHost returns the key of the results of h in PoolResults.
+func poolHost(h HostInfo) string {
+	if h.Local || h.Port == 0 || h.Port == defaultSSHPort {
+		return h.Hostname
+	}
+
+	return net.JoinHostPort(h.Hostname, strconv.Itoa(h.Port))
+}
+
+// Hosts returns the hostnames of the results in sorted order.
+func (pr PoolResults) Hosts() []string {
+	var hosts []string
+	for host := range pr {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+
+	return hosts
+}
+
+// Failed returns the hostnames, in sorted order, of the hosts where
+// the command could not be run or exited with a non-zero exit code.
+func (pr PoolResults) Failed() []string {
+	var hosts []string
+	for _, host := range pr.Hosts() {
+		if !pr[host].Success() {
+			hosts = append(hosts, host)
+		}
+	}
+
+	return hosts
+}
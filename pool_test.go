@@ -0,0 +1,108 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun_test
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/apatters/go-logrun"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewPool(t *testing.T) {
+	l1 := logrun.NewLocalLogRun(logrun.LocalConfig{})
+	l2 := logrun.NewLocalLogRun(logrun.LocalConfig{})
+	_, err := logrun.NewPool([]*logrun.LogRun{l1, l2}, logrun.PoolConfig{})
+	t.Logf("err = %v", err)
+	assert.Error(t, err)
+
+	p, err := logrun.NewPool([]*logrun.LogRun{l1}, logrun.PoolConfig{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{l1.Host().Hostname}, p.Hosts())
+	assert.Len(t, p.Runners(), 1)
+}
+
+func TestPool_Shell(t *testing.T) {
+	var configs []logrun.RemoteConfig
+	var hosts []string
+	for i := 0; i < 3; i++ {
+		s := newTestSSHServer(t, nil)
+		defer s.Close()
+		creds := s.Credentials()
+		configs = append(configs, logrun.RemoteConfig{Credentials: creds})
+		hosts = append(hosts, net.JoinHostPort(creds.Hostname, strconv.Itoa(creds.Port)))
+	}
+	p, err := logrun.NewRemotePool(configs, logrun.PoolConfig{})
+	require.NoError(t, err)
+	assert.Equal(t, hosts, p.Hosts())
+
+	results := p.Shell("echo hello")
+	for host, res := range results {
+		t.Logf("results[%s] = %+v", host, res)
+	}
+	require.Len(t, results, 3)
+	for _, host := range hosts {
+		assert.Equal(t, "hello\n", results[host].Stdout)
+	}
+	assert.Empty(t, results.Failed())
+
+	results = p.Run("/bin/false")
+	assert.Len(t, results.Failed(), 3)
+
+	errs := p.Each(func(r *logrun.LogRun) error {
+		if r.Host().String() == p.Runners()[1].Host().String() {
+			return fmt.Errorf("failed")
+		}
+		return nil
+	})
+	t.Logf("errs = %v", errs)
+	assert.Equal(t, map[string]error{hosts[1]: fmt.Errorf("failed")}, errs)
+}
+
+func TestPool_SetConcurrency(t *testing.T) {
+	var configs []logrun.RemoteConfig
+	for i := 0; i < 6; i++ {
+		configs = append(configs, logrun.RemoteConfig{
+			Credentials: logrun.Credentials{
+				Hostname: fmt.Sprintf("host%d", i),
+				Username: "deploy",
+				Password: "secret",
+			},
+		})
+	}
+	p, err := logrun.NewRemotePool(configs, logrun.PoolConfig{Concurrency: 2})
+	require.NoError(t, err)
+	var running, maxRunning, count int32
+	work := func(r *logrun.LogRun) error {
+		n := atomic.AddInt32(&running, 1)
+		for {
+			m := atomic.LoadInt32(&maxRunning)
+			if n <= m || atomic.CompareAndSwapInt32(&maxRunning, m, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		atomic.AddInt32(&running, -1)
+		atomic.AddInt32(&count, 1)
+		return nil
+	}
+	errs := p.Each(work)
+	t.Logf("maxRunning = %d", maxRunning)
+	assert.Empty(t, errs)
+	assert.Equal(t, int32(6), count)
+	assert.Equal(t, int32(2), maxRunning)
+
+	atomic.StoreInt32(&maxRunning, 0)
+	p.SetConcurrency(0)
+	p.Each(work)
+	t.Logf("maxRunning = %d", maxRunning)
+	assert.True(t, maxRunning > 2)
+}
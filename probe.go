@@ -0,0 +1,151 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// HTTPProbeCmd is the shell command used by HTTPProbe() to
+	// output the HTTP status code of a URL. It uses curl if it is
+	// installed and wget otherwise. %[1]s is replaced by the
+	// quoted URL and %[2]d by ProbeTimeout in seconds. This
+	// command has been tested on RHEL/CentOS 7 and Ubuntu 18.04.
+	HTTPProbeCmd = strings.Join([]string{
+		"if command -v curl >/dev/null 2>&1; then curl -s -o /dev/null -w '%%{http_code}' --max-time %[2]d %[1]s",
+		"elif command -v wget >/dev/null 2>&1; then wget -q -S -O /dev/null -T %[2]d -t 1 %[1]s 2>&1 | awk '/^ *HTTP\\//{c=$2} END{print c}'",
+		"else echo 'neither curl nor wget is installed' >&2; exit 127",
+		"fi",
+	}, "; ")
+
+	// TCPProbeCmd is the shell command used by TCPProbe() to
+	// connect to a TCP port. It uses bash's /dev/tcp if bash is
+	// installed and nc otherwise. %[1]s is replaced by the host,
+	// %[2]s by the port, and %[3]d by ProbeTimeout in seconds.
+	// This command has been tested on RHEL/CentOS 7 and Ubuntu
+	// 18.04.
+	TCPProbeCmd = strings.Join([]string{
+		"if command -v bash >/dev/null 2>&1; then timeout %[3]d bash -c 'exec 3<>/dev/tcp/%[1]s/%[2]s'",
+		"elif command -v nc >/dev/null 2>&1; then nc -z -w %[3]d %[1]s %[2]s",
+		"else echo 'neither bash nor nc is installed' >&2; exit 127",
+		"fi",
+	}, "; ")
+
+	// ProbeTimeout is the time allowed for a probe to connect and,
+	// for HTTP probes, receive a response.
+	ProbeTimeout = 5 * time.Second
+)
+
+// probeHostRegexp matches the hosts TCPProbe() accepts, so they can
+// be inserted into TCPProbeCmd without quoting.
+var probeHostRegexp = regexp.MustCompile(`^[A-Za-z0-9._:%-]+$`)
+
+// ProbeResult is the outcome of a health probe run on a host.
+type ProbeResult struct {
+	// Target is the probed URL or address.
+	Target string
+
+	// OK is true if the probe succeeded, i.e., the port accepted
+	// a connection or the URL returned a 2xx or 3xx status.
+	OK bool
+
+	// StatusCode is the HTTP status code returned by the URL of
+	// an HTTP probe, or zero if no response was received.
+	StatusCode int
+
+	// Detail describes why the probe failed.
+	Detail string
+}
+
+// HTTPProbe requests url from the host using curl or wget, e.g., to
+// verify a deployment from the deployed host's own network vantage
+// point. An error is only returned if the probe could not be run;
+// failed requests are reported in the ProbeResult. Only logging is
+// performed if Dryrun is true, in which case the probe is reported as
+// successful.
+func (r *LogRun) HTTPProbe(url string) (_ ProbeResult, err error) {
+	r = r.beginHelper()
+	defer r.addErrorContext(&err, r.commandSeq())
+	res := ProbeResult{Target: url}
+	cmd := fmt.Sprintf(HTTPProbeCmd, shellQuote(url), probeSeconds())
+	r.logShell(cmd)
+	if r.Dryrun {
+		res.OK = true
+		return res, nil
+	}
+	stdout, stderr, code := r.shell(cmd)
+	if code == 127 {
+		return res, fmt.Errorf("could not probe %s: %s", url, strings.TrimSpace(stderr))
+	}
+	res.StatusCode, _ = strconv.Atoi(strings.TrimSpace(stdout))
+	switch {
+	case res.StatusCode == 0:
+		res.Detail = fmt.Sprintf("no response (exit code %d)", code)
+	case res.StatusCode < 200 || res.StatusCode >= 400:
+		res.Detail = fmt.Sprintf("HTTP status %d", res.StatusCode)
+	default:
+		res.OK = true
+	}
+
+	return res, nil
+}
+
+// TCPProbe connects to addr, a "host:port" address, from the host
+// using bash or nc. An error is only returned if the probe could not
+// be run; failed connections are reported in the ProbeResult. Only
+// logging is performed if Dryrun is true, in which case the probe is
+// reported as successful.
+func (r *LogRun) TCPProbe(addr string) (_ ProbeResult, err error) {
+	r = r.beginHelper()
+	defer r.addErrorContext(&err, r.commandSeq())
+	res := ProbeResult{Target: addr}
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return res, err
+	}
+	if _, err := strconv.ParseUint(port, 10, 16); err != nil {
+		return res, fmt.Errorf("invalid port in %s", addr)
+	}
+	if !probeHostRegexp.MatchString(host) {
+		return res, fmt.Errorf("invalid host in %s", addr)
+	}
+	cmd := fmt.Sprintf(TCPProbeCmd, host, port, probeSeconds())
+	r.logShell(cmd)
+	if r.Dryrun {
+		res.OK = true
+		return res, nil
+	}
+	_, stderr, code := r.shell(cmd)
+	switch code {
+	case 0:
+		res.OK = true
+	case 127:
+		return res, fmt.Errorf("could not probe %s: %s", addr, strings.TrimSpace(stderr))
+	case 124:
+		res.Detail = "connection timed out"
+	default:
+		res.Detail = "connection failed"
+		if msg := strings.TrimSpace(stderr); msg != "" {
+			res.Detail += ": " + msg
+		}
+	}
+
+	return res, nil
+}
+
+// probeSeconds returns ProbeTimeout in whole seconds, at least one.
+func probeSeconds() int {
+	if s := int(ProbeTimeout / time.Second); s > 0 {
+		return s
+	}
+
+	return 1
+}
@@ -0,0 +1,89 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun_test
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/apatters/go-logrun"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalLogRun_HTTPProbe(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/health" {
+			http.NotFound(w, req)
+		}
+	}))
+	defer srv.Close()
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{})
+
+	res, err := l.HTTPProbe(srv.URL + "/health")
+	t.Logf("res = %+v", res)
+	require.NoError(t, err)
+	assert.True(t, res.OK)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+
+	res, err = l.HTTPProbe(srv.URL + "/missing")
+	t.Logf("res = %+v", res)
+	require.NoError(t, err)
+	assert.False(t, res.OK)
+	assert.Equal(t, http.StatusNotFound, res.StatusCode)
+	assert.Equal(t, "HTTP status 404", res.Detail)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := ln.Addr().String()
+	ln.Close()
+	res, err = l.HTTPProbe("http://" + addr + "/")
+	t.Logf("res = %+v", res)
+	require.NoError(t, err)
+	assert.False(t, res.OK)
+	assert.Zero(t, res.StatusCode)
+}
+
+func TestLocalLogRun_TCPProbe(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{})
+
+	res, err := l.TCPProbe(ln.Addr().String())
+	t.Logf("res = %+v", res)
+	require.NoError(t, err)
+	assert.True(t, res.OK)
+
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := closed.Addr().String()
+	closed.Close()
+	res, err = l.TCPProbe(addr)
+	t.Logf("res = %+v", res)
+	require.NoError(t, err)
+	assert.False(t, res.OK)
+	assert.NotEmpty(t, res.Detail)
+
+	_, err = l.TCPProbe("localhost")
+	assert.Error(t, err)
+	_, err = l.TCPProbe("$(reboot):22")
+	assert.Error(t, err)
+}
+
+func TestLocalLogRun_ProbeDryrun(t *testing.T) {
+	log, out, _ := newLogger()
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{
+		LogFunc: log.Println,
+		Dryrun:  true,
+	})
+	res, err := l.TCPProbe("db1:5432")
+	t.Logf("out = %q", out)
+	require.NoError(t, err)
+	assert.True(t, res.OK)
+	assert.Contains(t, out.String(), "/dev/tcp/db1/5432")
+}
@@ -0,0 +1,39 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+//go:build windows
+// +build windows
+
+package logrun
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+)
+
+// setProcessGroup is a no-op on Windows.
+func setProcessGroup(cmd *exec.Cmd) {
+}
+
+// killProcessGroup kills the process started by cmd. Children of the
+// process are not killed on Windows.
+func killProcessGroup(cmd *exec.Cmd) {
+	if cmd.Process == nil {
+		return
+	}
+	_ = cmd.Process.Kill()
+}
+
+// setCredential returns an error since running commands as another
+// user is not supported on Windows.
+func setCredential(cmd *exec.Cmd, username string) error {
+	return fmt.Errorf("running commands as another user is not supported on Windows")
+}
+
+// fileOwnerName returns the empty string since files on Windows are
+// not owned by a user and group.
+func fileOwnerName(fi os.FileInfo) string {
+	return ""
+}
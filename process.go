@@ -0,0 +1,82 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// KillCmd is the external command used to check on and send signals
+// to processes. This command has been tested on RHEL/CentOS 7 and
+// Ubuntu 18.04.
+var KillCmd = "/bin/kill"
+
+// ProcessRunning returns true if a process with the given PID is
+// running on the host, e.g., one started using WithPIDFunc() or
+// WithPIDFile(). Only logging is performed if Dryrun is true, in which
+// case true is returned.
+func (r *LogRun) ProcessRunning(pid int) (_ bool, err error) {
+	r = r.beginHelper()
+	defer r.addErrorContext(&err, r.commandSeq())
+	args := []string{"-0", strconv.Itoa(pid)}
+	r.logRun(KillCmd, args...)
+	if r.Dryrun {
+		return true, nil
+	}
+	_, stderr, code := r.run(KillCmd, args...)
+	switch {
+	case code == 0:
+		return true, nil
+	case strings.Contains(stderr, "No such process"):
+		return false, nil
+	case strings.Contains(stderr, "not permitted"):
+		// The process exists but belongs to another user.
+		return true, nil
+	}
+
+	return false, fmt.Errorf("could not check process %d: %s", pid, strings.TrimSpace(stderr))
+}
+
+// SignalProcess sends signal, e.g., "TERM" or "KILL", to the process
+// with the given PID on the host. A negative PID signals the process
+// group -pid. Only logging is performed if Dryrun is true.
+func (r *LogRun) SignalProcess(pid int, signal string) (err error) {
+	r = r.beginHelper()
+	defer r.addErrorContext(&err, r.commandSeq())
+	args := []string{"-s", signal, "--", strconv.Itoa(pid)}
+	r.logChangeRun(KillCmd, args...)
+	if r.Dryrun {
+		return nil
+	}
+	_, stderr, code := r.run(KillCmd, args...)
+	if code != 0 {
+		return fmt.Errorf("could not signal process %d: %s", pid, strings.TrimSpace(stderr))
+	}
+
+	return nil
+}
+
+// ReadPIDFile returns the PID stored in the file at path on the host,
+// e.g., one written using WithPIDFile(). Only logging is performed if
+// Dryrun is true, in which case 0 is returned.
+func (r *LogRun) ReadPIDFile(path string) (_ int, err error) {
+	r = r.beginHelper()
+	defer r.addErrorContext(&err, r.commandSeq())
+	content, exists, err := r.readFile(path)
+	if err != nil || r.Dryrun {
+		return 0, err
+	}
+	if !exists {
+		return 0, notFoundError("could not read", path)
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(content))
+	if err != nil {
+		return 0, fmt.Errorf("%s does not contain a PID: %q", path, content)
+	}
+
+	return pid, nil
+}
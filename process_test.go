@@ -0,0 +1,97 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/apatters/go-logrun"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testProcessTracking starts a long-running command using l, stops it
+// using the PID from the PID file, and checks that the PID passed to
+// the PID function matches.
+func testProcessTracking(t *testing.T, l *logrun.LogRun, pidFile string) {
+	pids := make(chan int, 1)
+	done := make(chan int, 1)
+	go func() {
+		_, _, code := l.With(
+			logrun.WithPIDFunc(func(pid int) { pids <- pid }),
+			logrun.WithPIDFile(pidFile),
+		).Run("sleep", "30")
+		done <- code
+	}()
+
+	var pid int
+	select {
+	case pid = <-pids:
+	case <-time.After(5 * time.Second):
+		t.Fatal("PID was not reported")
+	}
+	t.Logf("pid = %d", pid)
+	filePID, err := l.ReadPIDFile(pidFile)
+	require.NoError(t, err)
+	assert.Equal(t, pid, filePID)
+	running, err := l.ProcessRunning(pid)
+	require.NoError(t, err)
+	assert.True(t, running)
+
+	require.NoError(t, l.SignalProcess(pid, "TERM"))
+	select {
+	case code := <-done:
+		t.Logf("code = %d", code)
+		assert.NotZero(t, code)
+	case <-time.After(5 * time.Second):
+		t.Fatal("process was not stopped")
+	}
+	running, err = l.ProcessRunning(pid)
+	require.NoError(t, err)
+	assert.False(t, running)
+}
+
+func TestLocalLogRun_ProcessTracking(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-logrun-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{})
+	testProcessTracking(t, l, filepath.Join(dir, "sleep.pid"))
+}
+
+func TestRemoteLogRun_ProcessTracking(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-logrun-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	s := newTestSSHServer(t, nil)
+	defer s.Close()
+
+	r, err := logrun.NewRemoteLogRun(logrun.RemoteConfig{
+		Credentials: s.Credentials(),
+	})
+	require.NoError(t, err)
+	testProcessTracking(t, r, filepath.Join(dir, "sleep.pid"))
+}
+
+func TestLocalLogRun_ReadPIDFileInvalid(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-logrun-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "bad.pid")
+	require.NoError(t, ioutil.WriteFile(path, []byte("abc\n"), 0644))
+
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{})
+	_, err = l.ReadPIDFile(path)
+	t.Logf("err = %v", err)
+	assert.Error(t, err)
+	_, err = l.ReadPIDFile(filepath.Join(dir, "missing.pid"))
+	t.Logf("err = %v", err)
+	assert.Error(t, err)
+}
@@ -0,0 +1,352 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// Sha256Cmd is the external command used by PushFile() to
+	// hash the files on hosts. This command has been tested on
+	// RHEL/CentOS 7 and Ubuntu 18.04.
+	Sha256Cmd = "/usr/bin/sha256sum"
+
+	// PeerCopyCmd is the external command run on a host by
+	// PushFile() to copy a file from a peer host that already has
+	// it. This command has been tested on RHEL/CentOS 7 and Ubuntu
+	// 18.04.
+	PeerCopyCmd = "/usr/bin/scp"
+
+	// PeerCopyCmdOptions are the command-line options added to
+	// PeerCopyCmd so it fails rather than prompting for a
+	// password. This command (and options) has been tested on
+	// RHEL/CentOS 7 and Ubuntu 18.04.
+	PeerCopyCmdOptions = []string{"-q", "-o", "BatchMode=yes"}
+)
+
+// PushCacheConfig is used to set options in the NewPushCache
+// constructor.
+type PushCacheConfig struct {
+	// SeedFromPeers enables copying a file pushed by PushFile()
+	// from a remote host that already received it, rather than
+	// from the controller. See PushCache.
+	SeedFromPeers bool
+}
+
+// PushCache is a content-addressed cache of the local files pushed to
+// hosts by PushFile(). Each file is read and hashed once, no matter
+// how many hosts it is pushed to, as long as its size and modification
+// time do not change. Share it between the LogRuns of several hosts
+// with SetPushCache(), e.g.,
+//
+//	cache := logrun.NewPushCache(logrun.PushCacheConfig{SeedFromPeers: true})
+//	for _, r := range runners {
+//		r.SetPushCache(cache)
+//		changed, err := r.PushFile("build/app.tar.gz", "/opt/app/app.tar.gz", 0644)
+//		...
+//	}
+//
+// The cache also remembers which remote hosts received which contents.
+// If SeedFromPeers is true, a remote host fetches a file from such a
+// peer using PeerCopyCmd, which requires the host to be able to log in
+// to the peer without a password, e.g., using agent forwarding or host
+// based authentication. The copy is verified against the hash of the
+// file and the file is pushed from the controller if it fails. Cached
+// contents are held in memory until Reset() is called. It is safe for
+// concurrent use.
+type PushCache struct {
+	mu            sync.Mutex
+	seedFromPeers bool
+	files         map[pushKey]string
+	contents      map[string]string
+	peers         map[string][]pushPeer
+}
+
+// pushKey identifies a version of a local file.
+type pushKey struct {
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+// pushPeer is a remote host holding a copy of a pushed file. id is
+// the host as returned by HostInfo.String().
+type pushPeer struct {
+	id       string
+	username string
+	hostname string
+	port     int
+	path     string
+}
+
+// NewPushCache is the constructor for PushCache.
+func NewPushCache(config PushCacheConfig) *PushCache {
+	c := &PushCache{seedFromPeers: config.SeedFromPeers}
+	c.Reset()
+
+	return c
+}
+
+// SetSeedFromPeers enables/disables copying pushed files from remote
+// hosts that already received them.
+func (c *PushCache) SetSeedFromPeers(seed bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.seedFromPeers = seed
+}
+
+// Reset discards the cached contents and the peers that received them.
+func (c *PushCache) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.files = make(map[pushKey]string)
+	c.contents = make(map[string]string)
+	c.peers = make(map[string][]pushPeer)
+}
+
+// load returns the SHA-256 digest and contents of the local file at
+// path, reading it only if it is not cached.
+func (c *PushCache) load(path string) (string, string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", "", err
+	}
+	fi, err := os.Stat(abs)
+	if err != nil {
+		return "", "", err
+	}
+	key := pushKey{path: abs, size: fi.Size(), modTime: fi.ModTime()}
+	c.mu.Lock()
+	digest, ok := c.files[key]
+	content := c.contents[digest]
+	c.mu.Unlock()
+	if ok {
+		return digest, content, nil
+	}
+
+	digest, content, err = readPushFile(abs)
+	if err != nil {
+		return "", "", err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.files[key] = digest
+	c.contents[digest] = content
+
+	return digest, content, nil
+}
+
+// peer returns a remote host other than the one identified by id
+// holding the contents with digest, if seeding from peers is enabled.
+func (c *PushCache) peer(digest string, id string) (pushPeer, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.seedFromPeers {
+		return pushPeer{}, false
+	}
+	for _, p := range c.peers[digest] {
+		if p.id != id {
+			return p, true
+		}
+	}
+
+	return pushPeer{}, false
+}
+
+// addPeer records that p holds the contents with digest.
+func (c *PushCache) addPeer(digest string, p pushPeer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, q := range c.peers[digest] {
+		if q == p {
+			return
+		}
+	}
+	c.peers[digest] = append(c.peers[digest], p)
+}
+
+// readPushFile returns the SHA-256 digest and contents of the local
+// file at path.
+func readPushFile(path string) (string, string, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", "", err
+	}
+	sum := sha256.Sum256(b)
+
+	return hex.EncodeToString(sum[:]), string(b), nil
+}
+
+// SetPushCache sets the PushCache used by PushFile(). Use the same
+// PushCache for several LogRuns to read each file once, or nil, the
+// default, to read files every time they are pushed.
+func (r *LogRun) SetPushCache(c *PushCache) {
+	r.pushCache = c
+}
+
+// PushFile copies the local file at localPath, i.e., on the host
+// running the program, to dest on the host and sets its permission
+// bits to mode. The file is only copied if the SHA-256 hash of dest,
+// computed on the host with Sha256Cmd, differs from the hash of the
+// local file, and only its mode is changed if that differs. The
+// returned bool is true if the host was changed. See SetPushCache() to
+// avoid reading the local file again for each host. Only logging is
+// performed if Dryrun is true, in which case the file is reported as
+// changed. In check mode, the change is only recorded. The outcome is
+// recorded as an operation for the Summary(). Unlike PutFileString(),
+// PushFile() does not register undo actions. Pushing files is not
+// supported on Windows hosts.
+func (r *LogRun) PushFile(localPath string, dest string, mode os.FileMode) (_ bool, err error) {
+	r = r.beginHelper()
+	defer r.addErrorContext(&err, r.commandSeq())
+	if r.windowsRunner() != nil {
+		return false, fmt.Errorf("pushing files is not supported on Windows hosts")
+	}
+	var digest, content string
+	if r.pushCache != nil {
+		digest, content, err = r.pushCache.load(localPath)
+	} else {
+		digest, content, err = readPushFile(localPath)
+	}
+	if err != nil {
+		return false, fmt.Errorf("could not read %s: %w", localPath, err)
+	}
+
+	changed, err := r.pushFile(localPath, digest, content, dest, mode)
+	if err != nil {
+		return false, err
+	}
+	r.RecordOperation("PushFile", dest, changed)
+	if self, ok := r.pushPeer(dest); ok && !r.Dryrun && !r.checking() {
+		r.pushCache.addPeer(digest, self)
+	}
+
+	return changed, nil
+}
+
+func (r *LogRun) pushFile(localPath string, digest string, content string, dest string, mode os.FileMode) (bool, error) {
+	input := "cat " + shellQuote(localPath)
+	if r.Dryrun {
+		r.logWriteFile(dest, mode, input)
+		return true, nil
+	}
+	current, exists, err := r.fileDigest(dest)
+	if err != nil {
+		return false, err
+	}
+	perm := strconv.FormatUint(uint64(mode.Perm()), 8)
+	if exists && current == digest {
+		currentPerm, err := r.fileMode(dest)
+		if err != nil {
+			return false, err
+		}
+		if currentPerm == perm {
+			return false, nil
+		}
+		if r.checking() {
+			r.check.add(Change{
+				Action: ChangeModify,
+				Target: dest,
+				Detail: fmt.Sprintf("mode %s -> %s", currentPerm, perm),
+			})
+			return true, nil
+		}
+		return true, r.chmod(dest, mode)
+	}
+
+	if r.checking() {
+		r.logWriteFile(dest, mode, input)
+		if exists {
+			r.check.add(Change{Action: ChangeModify, Target: dest, Detail: "content"})
+		} else {
+			r.check.add(Change{Action: ChangeCreate, Target: dest, Detail: "mode " + perm})
+		}
+		return true, nil
+	}
+	if self, ok := r.pushPeer(dest); ok {
+		if peer, ok := r.pushCache.peer(digest, self.id); ok {
+			err := r.seedFromPeer(peer, digest, dest, mode)
+			if err == nil {
+				return true, nil
+			}
+			r.log(trf("could not copy %s from %s, pushing it: %s", dest, peer.id, err))
+		}
+	}
+	r.logWriteFile(dest, mode, input)
+
+	return true, r.writeFile(dest, content, mode)
+}
+
+// fileDigest returns the SHA-256 digest of the file at path and
+// whether or not it exists.
+func (r *LogRun) fileDigest(path string) (string, bool, error) {
+	return r.fileChecksum(path, ChecksumSHA256)
+}
+
+// pushPeer returns the LogRun's host as a peer holding dest. False is
+// returned if there is no PushCache or the host cannot be a peer,
+// i.e., it is not a remote host.
+func (r *LogRun) pushPeer(dest string) (pushPeer, bool) {
+	remote, ok := r.Runner.(*remoteRunner)
+	if !ok || r.pushCache == nil {
+		return pushPeer{}, false
+	}
+	if !r.isAbsPath(dest) {
+		dir := r.execDir()
+		if dir == "" {
+			return pushPeer{}, false
+		}
+		dest = r.joinPath(dir, dest)
+	}
+
+	return pushPeer{
+		id:       r.Host().String(),
+		username: remote.credentials.Username,
+		hostname: remote.credentials.Hostname,
+		port:     remote.credentials.Port,
+		path:     dest,
+	}, true
+}
+
+// seedFromPeer copies the file at dest from peer using PeerCopyCmd on
+// the host and verifies its digest before moving it into place. The
+// file is copied to a temporary file with a random name created by
+// mktemp, so concurrent pushes of the file do not collide.
+func (r *LogRun) seedFromPeer(peer pushPeer, digest string, dest string, mode os.FileMode) error {
+	words := []string{PeerCopyCmd}
+	words = append(words, PeerCopyCmdOptions...)
+	if peer.port != 0 && peer.port != defaultSSHPort {
+		words = append(words, "-P", strconv.Itoa(peer.port))
+	}
+	words = append(words, shellQuote(peer.username+"@"+peer.hostname+":"+peer.path), `"$tmp"`)
+	cmd := fmt.Sprintf(
+		`tmp=$(mktemp %[1]s) && { %[2]s && echo %[3]s"$tmp" | %[4]s -c --status && chmod %[5]s "$tmp" && mv -f "$tmp" %[6]s || { rm -f "$tmp"; exit 1; }; }`,
+		shellQuote(tempFilePattern(dest)+".XXXXXX"),
+		strings.Join(words, " "),
+		shellQuote(digest+"  "),
+		Sha256Cmd,
+		strconv.FormatUint(uint64(mode.Perm()), 8),
+		shellQuote(dest))
+	r.logShell(cmd)
+	_, stderr, code := r.shell(cmd)
+	if code != 0 {
+		if stderr = strings.TrimSpace(stderr); stderr == "" {
+			stderr = fmt.Sprintf("exit code %d", code)
+		}
+		return fmt.Errorf("%s", stderr)
+	}
+
+	return nil
+}
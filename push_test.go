@@ -0,0 +1,169 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/apatters/go-logrun"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalLogRun_PushFile(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "logrun")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmpDir)
+	src := filepath.Join(tmpDir, "app.bin")
+	require.NoError(t, ioutil.WriteFile(src, []byte("version 1\n"), 0644))
+	dest := filepath.Join(tmpDir, "installed.bin")
+
+	log, out, _ := newLogger()
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{LogFunc: log.Println})
+	l.SetRecorder(logrun.NewRecorder())
+	changed, err := l.PushFile(src, dest, 0600)
+	t.Logf("out = %q", out)
+	require.NoError(t, err)
+	assert.True(t, changed)
+	content, err := ioutil.ReadFile(dest)
+	require.NoError(t, err)
+	assert.Equal(t, "version 1\n", string(content))
+	fi, err := os.Stat(dest)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), fi.Mode().Perm())
+
+	// Pushing the same contents changes nothing.
+	changed, err = l.PushFile(src, dest, 0600)
+	require.NoError(t, err)
+	assert.False(t, changed)
+
+	// Only the mode is changed if the contents match.
+	changed, err = l.PushFile(src, dest, 0640)
+	require.NoError(t, err)
+	assert.True(t, changed)
+	fi, err = os.Stat(dest)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0640), fi.Mode().Perm())
+
+	s := l.Summary()
+	assert.Equal(t, 2, s.Changed)
+	assert.Equal(t, 1, s.Unchanged)
+
+	_, err = l.PushFile(filepath.Join(tmpDir, "missing"), dest, 0644)
+	t.Logf("err = %v", err)
+	assert.Error(t, err)
+}
+
+func TestLocalLogRun_PushFileCheckMode(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "logrun")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmpDir)
+	src := filepath.Join(tmpDir, "app.bin")
+	require.NoError(t, ioutil.WriteFile(src, []byte("version 1\n"), 0644))
+	dest := filepath.Join(tmpDir, "installed.bin")
+
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{})
+	report, err := l.Check(func(r *logrun.LogRun) error {
+		_, err := r.PushFile(src, dest, 0644)
+		return err
+	})
+	require.NoError(t, err)
+	t.Logf("report = %+v", report)
+	require.Len(t, report.Changes(), 1)
+	assert.Equal(t, dest, report.Changes()[0].Target)
+	_, err = os.Stat(dest)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestPushCache_Load(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "logrun")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmpDir)
+	src := filepath.Join(tmpDir, "app.bin")
+	require.NoError(t, ioutil.WriteFile(src, []byte("version 1\n"), 0644))
+	fi, err := os.Stat(src)
+	require.NoError(t, err)
+
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{})
+	cache := logrun.NewPushCache(logrun.PushCacheConfig{})
+	l.SetPushCache(cache)
+	_, err = l.PushFile(src, filepath.Join(tmpDir, "a.bin"), 0644)
+	require.NoError(t, err)
+
+	// The local file is not read again while its size and
+	// modification time are unchanged.
+	require.NoError(t, ioutil.WriteFile(src, []byte("version 2\n"), 0644))
+	require.NoError(t, os.Chtimes(src, fi.ModTime(), fi.ModTime()))
+	_, err = l.PushFile(src, filepath.Join(tmpDir, "b.bin"), 0644)
+	require.NoError(t, err)
+	content, err := ioutil.ReadFile(filepath.Join(tmpDir, "b.bin"))
+	require.NoError(t, err)
+	assert.Equal(t, "version 1\n", string(content))
+
+	cache.Reset()
+	_, err = l.PushFile(src, filepath.Join(tmpDir, "c.bin"), 0644)
+	require.NoError(t, err)
+	content, err = ioutil.ReadFile(filepath.Join(tmpDir, "c.bin"))
+	require.NoError(t, err)
+	assert.Equal(t, "version 2\n", string(content))
+}
+
+func TestPushCache_SeedFromPeers(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "logrun")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmpDir)
+	src := filepath.Join(tmpDir, "app.bin")
+	require.NoError(t, ioutil.WriteFile(src, []byte("version 1\n"), 0644))
+
+	// Peers are simulated by two SSH servers on the local host, so
+	// the peer copy command copies the local file.
+	copyCmd := filepath.Join(tmpDir, "peercopy")
+	require.NoError(t, ioutil.WriteFile(copyCmd, []byte(
+		"#!/bin/sh\nfor arg; do src=\"$dest\"; dest=\"$arg\"; done\ncp \"${src#*:}\" \"$dest\"\n"), 0755))
+	origCmd, origOptions := logrun.PeerCopyCmd, logrun.PeerCopyCmdOptions
+	logrun.PeerCopyCmd, logrun.PeerCopyCmdOptions = copyCmd, nil
+	defer func() { logrun.PeerCopyCmd, logrun.PeerCopyCmdOptions = origCmd, origOptions }()
+
+	cache := logrun.NewPushCache(logrun.PushCacheConfig{SeedFromPeers: true})
+	var outs []string
+	for i := 0; i < 2; i++ {
+		s := newTestSSHServer(t, nil)
+		defer s.Close()
+		log, out, _ := newLogger()
+		r, err := logrun.NewRemoteLogRun(logrun.RemoteConfig{
+			Credentials: s.Credentials(),
+			LogFunc:     log.Println,
+		})
+		require.NoError(t, err)
+		r.SetPushCache(cache)
+		dest := filepath.Join(tmpDir, "host"+string(rune('1'+i))+".bin")
+		changed, err := r.PushFile(src, dest, 0644)
+		t.Logf("out = %q", out)
+		require.NoError(t, err)
+		assert.True(t, changed)
+		content, err := ioutil.ReadFile(dest)
+		require.NoError(t, err)
+		assert.Equal(t, "version 1\n", string(content))
+		outs = append(outs, out.String())
+	}
+	assert.NotContains(t, outs[0], copyCmd)
+	assert.Contains(t, outs[1], copyCmd+" ")
+	assert.Contains(t, outs[1], filepath.Join(tmpDir, "host1.bin"))
+	assert.False(t, strings.Contains(outs[1], "cat > "), "file was pushed from the controller")
+	assert.NotContains(t, outs[1], "could not copy")
+
+	// No temporary files are left behind.
+	entries, err := ioutil.ReadDir(tmpDir)
+	require.NoError(t, err)
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	assert.Equal(t, []string{"app.bin", "host1.bin", "host2.bin", "peercopy"}, names)
+}
@@ -0,0 +1,81 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun
+
+import (
+	"strings"
+)
+
+// shellSafeChars are the characters that never need to be quoted in a
+// word passed to a POSIX shell.
+const shellSafeChars = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789@%+=:,./-_"
+
+// ShellQuote returns s quoted so that a POSIX shell passes it to a
+// command as a single word, e.g., when copying a logged command to a
+// terminal. Words made only of letters, digits, and the characters
+// @%+=:,./-_ are returned unchanged, so common commands stay readable.
+// Other words, including the empty string and words with spaces,
+// quotes, or glob characters, are enclosed in single quotes, e.g.,
+//
+//	logrun.ShellQuote("it's *.log") == `'it'"'"'s *.log'`
+//
+// FormatRun() and FormatShell() quote commands this way, and so do
+// remote runners when sending commands to remote hosts.
+func ShellQuote(s string) string {
+	if s != "" && strings.Trim(s, shellSafeChars) == "" {
+		return s
+	}
+
+	return shellQuote(s)
+}
+
+// shellQuote quotes s so that it is passed to a POSIX shell as a
+// single word. Unlike ShellQuote(), s is always quoted.
+func shellQuote(s string) string {
+	if s == "" {
+		return "''"
+	}
+
+	return "'" + strings.Replace(s, "'", `'"'"'`, -1) + "'"
+}
+
+// shellCommandLine returns cmd and args quoted by ShellQuote() and
+// joined by spaces.
+func shellCommandLine(cmd string, args []string) string {
+	words := make([]string, 0, len(args)+1)
+	words = append(words, ShellQuote(cmd))
+	for _, arg := range args {
+		words = append(words, ShellQuote(arg))
+	}
+
+	return strings.Join(words, " ")
+}
+
+// shellCommandArg returns cmd quoted as the argument of the -c option
+// of a POSIX shell, so the shell running it receives it unchanged. It
+// is enclosed in double quotes unless it contains characters that are
+// special within double quotes, in which case it is enclosed in single
+// quotes or, if it also contains single quotes, those characters are
+// escaped.
+func shellCommandArg(cmd string) string {
+	const special = "\"$`\\"
+	switch {
+	case !strings.ContainsAny(cmd, special):
+		return `"` + cmd + `"`
+	case !strings.Contains(cmd, "'"):
+		return "'" + cmd + "'"
+	}
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, c := range cmd {
+		if strings.ContainsRune(special, c) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(c)
+	}
+	b.WriteByte('"')
+
+	return b.String()
+}
@@ -0,0 +1,60 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun_test
+
+import (
+	"testing"
+
+	"github.com/apatters/go-logrun"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShellQuote(t *testing.T) {
+	for _, e := range []struct {
+		s        string
+		expected string
+	}{
+		{"/usr/bin/seq", "/usr/bin/seq"},
+		{"--mode=0644", "--mode=0644"},
+		{"user@host:/tmp/a_b,c%d+e", "user@host:/tmp/a_b,c%d+e"},
+		{"", "''"},
+		{"hello world", "'hello world'"},
+		{"*.log", "'*.log'"},
+		{"$HOME", "'$HOME'"},
+		{"it's", `'it'"'"'s'`},
+		{`say "hi"`, `'say "hi"'`},
+	} {
+		actual := logrun.ShellQuote(e.s)
+		t.Logf("ShellQuote(%q) = %s", e.s, actual)
+		assert.Equal(t, e.expected, actual)
+	}
+}
+
+func TestRemoteLogRun_RunQuoted(t *testing.T) {
+	s := newTestSSHServer(t, nil)
+	defer s.Close()
+	log, out, _ := newLogger()
+	r, err := logrun.NewRemoteLogRun(logrun.RemoteConfig{
+		Credentials: s.Credentials(),
+		LogFunc:     log.Println,
+	})
+	require.NoError(t, err)
+
+	stdout, stderr, code := r.Run("/usr/bin/printf", `[%s]\n`, "a b", "it's", "*", "$HOME")
+	t.Logf("stdout = %q", stdout)
+	t.Logf("stderr = %q", stderr)
+	t.Logf("out = %q", out)
+	require.Zero(t, code)
+	assert.Equal(t, "[a b]\n[it's]\n[*]\n[$HOME]\n", stdout)
+	assert.Contains(t, out.String(), ` /usr/bin/printf '[%s]\n' 'a b' 'it'"'"'s' '*' '$HOME'`+"\n")
+
+	out.Reset()
+	stdout, _, code = r.Shell(`x="a b"; echo "$x" 'it'"'"'s' $((1+2))`)
+	t.Logf("stdout = %q", stdout)
+	t.Logf("out = %q", out)
+	require.Zero(t, code)
+	assert.Equal(t, "a b it's 3\n", stdout)
+}
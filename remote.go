@@ -5,9 +5,11 @@
 package logrun
 
 import (
+	"fmt"
 	"io"
-
-	"github.com/apatters/go-run"
+	"strings"
+	"sync"
+	"time"
 )
 
 // Credentials contains needed credentials to SSH to a host. It can
@@ -45,21 +47,72 @@ type RemoteConfig struct {
 	// LogFunc can also be used.
 	LogFunc LogFunc
 
+	// LogHook, if not nil, receives a structured LogEvent for
+	// every message logged in addition to LogFunc. See
+	// SetLogHook().
+	LogHook LogHook
+
+	// ResultStore, if not nil, persists the Results of the
+	// commands run. See SetResultStore().
+	ResultStore ResultStore
+
+	// ResultLogFunc, if not nil, is used to log the exit code,
+	// duration, and output size of each command once it has
+	// finished. See SetResultLogFunc().
+	ResultLogFunc LogFunc
+
+	// Verbosity selects how much is logged about each command.
+	// See SetVerbosity().
+	Verbosity Verbosity
+
 	// ShellExecutable is the full path to the shell on the remote
 	// host to be run when executing shell commands.
 	ShellExecutable string
 
-	// Env specifies the environment of the process.
-	// Each entry is of the form "key=value".
-	// If Env is nil, the new process uses the current process's
-	// environment.
-	// If Env contains duplicate environment keys, only the last
-	// value in the slice for each duplicate key is used.
+	// RunAs, if not empty, is the user commands are run as on
+	// the remote host using RunAsCmd, i.e., sudo, which must not
+	// prompt for a password. File operations are performed
+	// using commands run as RunAs rather than SFTP. Running
+	// commands as another user is not supported on Windows
+	// hosts.
+	RunAs string
+
+	// HostKeyPolicy selects how the host keys of the remote host
+	// and its jump hosts are checked against KnownHostsFile. The
+	// default, HostKeyAcceptAny, accepts any key.
+	HostKeyPolicy HostKeyPolicy
+
+	// KnownHostsFile is the known_hosts file used by
+	// HostKeyPolicy. If empty, ~/.ssh/known_hosts of the user
+	// running the program is used. Hosts are recorded by their
+	// real hostname, as "[hostname]:port" for ports other than 22.
+	// Hashed entries are supported.
+	KnownHostsFile string
+
+	// Sudo selects how RunAsCmd passes the environment to
+	// commands run as RunAs or WithRunAs(), e.g., to keep proxy or
+	// locale settings that sudo removes by default.
+	Sudo SudoOptions
+
+	// RemoteOS is the operating system of the remote host. If
+	// RemoteWindows, commands, paths, and file operations are
+	// formatted for Windows hosts. See RemoteWindows.
+	RemoteOS RemoteOS
+
+	// Env are variables added to the environment of commands on
+	// the remote host. Each entry is of the form "key=value".
+	// The variables are exported by the login shell before the
+	// command is run, or set using cmd's set command on Windows
+	// hosts, and passed on to commands run as another user using
+	// EnvCmd. Values of variables whose names contain PASSWORD,
+	// SECRET, TOKEN, or KEY are masked when commands are
+	// logged.
 	Env []string
 
-	// Dir specifies the working directory of the command.
-	// If Dir is the empty string, Run runs the command in the
-	// calling process's current directory.
+	// Dir specifies the working directory of commands on the
+	// remote host. If Dir is the empty string, commands run in
+	// the login directory. Working directories set using
+	// PushDir() are relative to Dir.
 	Dir string
 
 	// Stdin specifies the process's standard input.
@@ -97,40 +150,163 @@ type RemoteConfig struct {
 	// Credentials are used to authenticate with the remote host.
 	Credentials Credentials
 
+	// UseSSHConfig resolves Credentials.Hostname as a host alias
+	// in the OpenSSH client configuration file, e.g., "prod-db",
+	// using LookupSSHConfig(). The configured HostName is
+	// connected to, and the configured Port, User, and
+	// IdentityFile are used unless set in Credentials. The alias
+	// is still used when logging commands. Jump hosts in
+	// ProxyJump authenticate using ssh-agent and their own
+	// IdentityFile or the default private key, never
+	// Credentials.Password; set JumpHosts to use other
+	// credentials.
+	UseSSHConfig bool
+
+	// SSHConfigFile is the OpenSSH client configuration file used
+	// if UseSSHConfig is true. If empty, ~/.ssh/config is used if
+	// it exists. Setting SSHConfigFile implies UseSSHConfig.
+	SSHConfigFile string
+
+	// JumpHosts are the bastion hosts used to reach a remote host
+	// that is not directly reachable, in the order they are
+	// connected to. The first jump host is connected to directly
+	// and each following host, as well as the remote host,
+	// through an SSH connection to the previous one, like the
+	// ProxyJump option of ssh. Unset ports, usernames, and
+	// private key files default as for Credentials. JumpHosts
+	// overrides any ProxyJump found using UseSSHConfig.
+	JumpHosts []Credentials
+
+	// ConnectTimeout is the time allowed for connecting to each
+	// address of the remote host. If zero, DefaultConnectTimeout
+	// is used.
+	ConnectTimeout time.Duration
+
+	// ConnectDelay is the time to wait for a connection attempt
+	// to an address of the remote host before also trying the
+	// next address when the hostname resolves to more than one
+	// address. If zero, DefaultConnectDelay is used.
+	ConnectDelay time.Duration
+
+	// DisableConnectionReuse connects to the remote host for
+	// every command instead of running commands over one shared
+	// SSH connection. By default, the connection is opened by the
+	// first command and reused until Close() is called on the
+	// LogRun.
+	DisableConnectionReuse bool
+
+	// ConnectionLimits limits the reuse of connections unless
+	// DisableConnectionReuse is true, e.g., to stay within the
+	// MaxSessions setting of the SSH server or to periodically
+	// re-dial servers that leak memory on long-lived
+	// connections.
+	ConnectionLimits ConnectionLimits
+
+	// Resolver, if not nil, is used to resolve Credentials.Hostname
+	// to addresses (or other hostnames) before connecting, e.g.,
+	// using service discovery. The logical Hostname is still used
+	// when logging commands. If nil, the system resolver is used.
+	Resolver Resolver
+
 	// Dryrun enables/disables the execution of commands. If
 	// Dryrun is true, the command is only logged.
 	Dryrun bool
+
+	// OutputEncoding specifies how the captured stdout and
+	// stderr are converted to strings. The default, EncodingRaw,
+	// returns the output unmodified.
+	OutputEncoding OutputEncoding
+
+	// NormalizeCRLF converts CRLF line endings in the captured
+	// stdout and stderr to LF line endings if true.
+	NormalizeCRLF bool
+
+	// Timeout is the maximum amount of time a command is allowed
+	// to run before it is killed. Zero means no timeout.
+	Timeout time.Duration
+
+	// Clock is the source of time used for timeouts and
+	// durations. If nil, RealClock is used.
+	Clock Clock
+
+	// StderrClassifier is used to classify the standard error
+	// output of commands. If nil, DefaultStderrClassifier is used.
+	StderrClassifier *StderrClassifier
+
+	// OnConnectionEvent, if not nil, is called when a connection
+	// to the remote host is established, fails to authenticate, or
+	// is closed, e.g., to log the availability of the host or show
+	// it in a UI. It is called synchronously and must not run
+	// commands using the LogRun. Without connection reuse, each
+	// command connects and disconnects.
+	OnConnectionEvent func(event ConnectionEvent)
+
+	// LogServerVersion enables logging the SSH server version
+	// (and banner, if any) whenever it is first seen or changes.
+	LogServerVersion bool
+
+	// ProbeCapabilities enables the automatic selection of helper
+	// command implementations based on the host's Capabilities.
+	// See SetProbeCapabilities() for why it is disabled by default.
+	ProbeCapabilities bool
+
+	// UseSFTP, if true, performs FileExists(), DirExists(),
+	// Glob(), GetFileString(), and PutFileString() using the SFTP
+	// subsystem of the SSH server instead of running FileExistsCmd,
+	// GlobCmd, and other external commands, e.g., for minimal
+	// hosts without GNU coreutils.
+	UseSFTP bool
+
+	// Vars are the variables of the host referenced by command
+	// templates. See SetVars().
+	Vars map[string]string
+
+	// Tags are the tags of the host used to select it in a Pool.
+	// See SetTags().
+	Tags map[string]string
 }
 
 // NewRemoteLogRun is the constructor for RemoteLogRun used to log and
 // run a remote command.
 func NewRemoteLogRun(config RemoteConfig) (*LogRun, error) {
-	r := new(LogRun)
-	creds := run.Credentials{
-		Hostname:           config.Credentials.Hostname,
-		Port:               config.Credentials.Port,
-		Username:           config.Credentials.Username,
-		Password:           config.Credentials.Password,
-		PrivateKeyFilename: config.Credentials.PrivateKeyFilename,
-	}
-	remote, err := run.NewRemote(run.RemoteConfig{
-		ShellExecutable: config.ShellExecutable,
-		Stdin:           config.Stdin,
-		Stdout:          config.Stdout,
-		Stderr:          config.Stderr,
-		Credentials:     creds,
-	})
+	remote, err := newRemoteRunner(config)
 	if err != nil {
 		return nil, err
 	}
 
-	r.Runner = remote
-	if config.LogFunc == nil {
-		r.logFunc = DefaultLogFunc
-	} else {
-		r.logFunc = config.LogFunc
+	r := NewLogRun(remote, LogRunConfig{
+		LogFunc:          config.LogFunc,
+		LogHook:          config.LogHook,
+		ResultStore:      config.ResultStore,
+		ResultLogFunc:    config.ResultLogFunc,
+		Verbosity:        config.Verbosity,
+		Dryrun:           config.Dryrun,
+		OutputEncoding:   config.OutputEncoding,
+		NormalizeCRLF:    config.NormalizeCRLF,
+		Timeout:          config.Timeout,
+		Clock:            config.Clock,
+		StderrClassifier: config.StderrClassifier,
+		Vars:             config.Vars,
+		Tags:             config.Tags,
+	})
+	if config.LogServerVersion {
+		var last string
+		var mu sync.Mutex
+		remote.onConnect = func(h HostInfo) {
+			mu.Lock()
+			defer mu.Unlock()
+			if h.ServerVersion == last {
+				return
+			}
+			last = h.ServerVersion
+			msg := fmt.Sprintf("%s@%s: server version %s", h.Username, h.Hostname, h.ServerVersion)
+			if h.Banner != "" {
+				msg += fmt.Sprintf(", banner %q", strings.TrimSpace(h.Banner))
+			}
+			r.log(msg)
+		}
 	}
-	r.Dryrun = config.Dryrun
+	r.probeCaps = config.ProbeCapabilities
 
 	return r, nil
 }
@@ -0,0 +1,757 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/user"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+const (
+	defaultSSHPort        = 22
+	defaultSSHHostname    = "localhost"
+	defaultSSHKeyfileName = "id_rsa"
+)
+
+// remoteRunner runs commands on a remote host over SSH. It implements
+// Runner so it can be used as a LogRun Runner.
+type remoteRunner struct {
+	shellExecutable string
+	runAs           string
+	sudo            SudoOptions
+	hostKeys        *knownHosts
+	env             []string
+	dir             string
+	stdin           io.Reader
+	stdout          io.Writer
+	stderr          io.Writer
+	credentials     Credentials
+	connectTimeout  time.Duration
+	connectDelay    time.Duration
+	resolver        Resolver
+	useSFTP         bool
+	remoteOS        RemoteOS
+
+	// hostname is the real hostname connected to, which differs
+	// from credentials.Hostname if the latter is an alias in the
+	// OpenSSH client configuration.
+	hostname string
+
+	// jumps are the jump hosts used to reach the remote host.
+	jumps []sshJump
+
+	conns *connManager
+
+	// onConnect, if not nil, is called after each successful
+	// connection.
+	onConnect func(HostInfo)
+
+	// onEvent, if not nil, is called with the connection events
+	// and connected is true once a connection has succeeded.
+	onEvent   func(ConnectionEvent)
+	clock     Clock
+	connected int32
+
+	// mu protects the information about the last connection:
+	// the resolved address, the server version, and the banner.
+	mu            sync.Mutex
+	address       string
+	serverVersion string
+	banner        string
+}
+
+func newRemoteRunner(config RemoteConfig) (*remoteRunner, error) {
+	r := &remoteRunner{
+		shellExecutable: config.ShellExecutable,
+		runAs:           config.RunAs,
+		sudo:            config.Sudo,
+		env:             config.Env,
+		dir:             config.Dir,
+		stdin:           config.Stdin,
+		stdout:          config.Stdout,
+		stderr:          config.Stderr,
+		credentials:     config.Credentials,
+		connectTimeout:  config.ConnectTimeout,
+		connectDelay:    config.ConnectDelay,
+		resolver:        config.Resolver,
+		useSFTP:         config.UseSFTP,
+		remoteOS:        config.RemoteOS,
+		onEvent:         config.OnConnectionEvent,
+		clock:           config.Clock,
+	}
+	r.conns = &connManager{
+		reuse:  !config.DisableConnectionReuse,
+		limits: config.ConnectionLimits,
+		dial:   r.dial,
+	}
+	if r.onEvent != nil {
+		r.conns.onDisconnect = func(err error) {
+			r.connectionEvent(ConnectionDisconnected, err)
+		}
+	}
+	if r.clock == nil {
+		r.clock = RealClock{}
+	}
+	r.conns.clock = r.clock
+	if r.connectTimeout == 0 {
+		r.connectTimeout = DefaultConnectTimeout
+	}
+	if r.connectDelay == 0 {
+		r.connectDelay = DefaultConnectDelay
+	}
+	if r.shellExecutable == "" {
+		r.shellExecutable = DefaultShellExecutable
+		if r.remoteOS == RemoteWindows {
+			r.shellExecutable = PowerShellCmd
+		}
+	}
+	if r.credentials.Hostname == "" {
+		r.credentials.Hostname = defaultSSHHostname
+	}
+	r.hostname = r.credentials.Hostname
+	if config.UseSSHConfig || config.SSHConfigFile != "" {
+		if err := r.applySSHConfig(config.SSHConfigFile); err != nil {
+			return nil, err
+		}
+	}
+	if len(config.JumpHosts) > 0 {
+		jumps, err := jumpHosts(config.JumpHosts)
+		if err != nil {
+			return nil, err
+		}
+		r.jumps = jumps
+	}
+	if err := fillCredentialDefaults(&r.credentials); err != nil {
+		return nil, err
+	}
+	hostKeys, err := newKnownHosts(config.HostKeyPolicy, config.KnownHostsFile)
+	if err != nil {
+		return nil, err
+	}
+	r.hostKeys = hostKeys
+
+	return r, nil
+}
+
+// jumpHosts converts the JumpHosts of a RemoteConfig to jump hosts
+// with default credentials filled in.
+func jumpHosts(hosts []Credentials) ([]sshJump, error) {
+	jumps := make([]sshJump, 0, len(hosts))
+	for i, creds := range hosts {
+		if creds.Hostname == "" {
+			return nil, fmt.Errorf("jump host %d has no hostname", i+1)
+		}
+		if err := fillCredentialDefaults(&creds); err != nil {
+			return nil, err
+		}
+		jumps = append(jumps, sshJump{credentials: creds, hostname: creds.Hostname})
+	}
+
+	return jumps, nil
+}
+
+// applySSHConfig resolves the hostname of r as a host alias in the
+// OpenSSH client configuration file filename, see LookupSSHConfig().
+// Settings in the credentials of r take precedence.
+func (r *remoteRunner) applySSHConfig(filename string) error {
+	lookup := func(host string) (SSHHostConfig, error) {
+		return LookupSSHConfig(filename, host)
+	}
+	hc, err := lookup(r.credentials.Hostname)
+	if err != nil {
+		return err
+	}
+	if hc.Hostname != "" {
+		r.hostname = hc.Hostname
+	}
+	if r.credentials.Port == 0 {
+		r.credentials.Port = hc.Port
+	}
+	if r.credentials.Username == "" {
+		r.credentials.Username = hc.User
+	}
+	if r.credentials.Password == "" && r.credentials.PrivateKeyFilename == "" {
+		r.credentials.PrivateKeyFilename = hc.IdentityFile
+	}
+	if hc.ProxyJump != "" {
+		r.jumps, err = parseProxyJump(hc.ProxyJump, lookup)
+	}
+
+	return err
+}
+
+// fillCredentialDefaults sets the unset port, username, and, if there
+// is no password, private key file of creds to their defaults.
+func fillCredentialDefaults(creds *Credentials) error {
+	if creds.Port == 0 {
+		creds.Port = defaultSSHPort
+	}
+	if creds.Username == "" {
+		u, err := user.Current()
+		if err != nil {
+			return err
+		}
+		creds.Username = u.Username
+	}
+	if creds.Password == "" && creds.PrivateKeyFilename == "" {
+		u, err := user.Lookup(creds.Username)
+		if err != nil {
+			return err
+		}
+		creds.PrivateKeyFilename = filepath.Join(u.HomeDir, ".ssh", defaultSSHKeyfileName)
+	}
+
+	return nil
+}
+
+// sshAuths returns the authentication methods used to connect to a
+// host using creds. The returned closer must be closed once the
+// connection has been established.
+func sshAuths(creds Credentials) ([]ssh.AuthMethod, io.Closer, error) {
+	if creds.Password != "" {
+		return []ssh.AuthMethod{ssh.Password(creds.Password)}, ioutil.NopCloser(nil), nil
+	}
+	if sockName := os.Getenv("SSH_AUTH_SOCK"); sockName != "" {
+		sock, err := net.Dial("unix", sockName)
+		if err != nil {
+			return nil, nil, err
+		}
+		signers, err := agent.NewClient(sock).Signers()
+		if err != nil {
+			sock.Close() // nolint: errcheck
+			return nil, nil, err
+		}
+		return []ssh.AuthMethod{ssh.PublicKeys(signers...)}, sock, nil
+	}
+	keyBuf, err := ioutil.ReadFile(creds.PrivateKeyFilename)
+	if err != nil {
+		return nil, nil, fmt.Errorf(
+			"run: could not read private key file '%s': %w",
+			creds.PrivateKeyFilename,
+			err)
+	}
+	key, err := ssh.ParsePrivateKey(keyBuf)
+	if err != nil {
+		return nil, nil, fmt.Errorf(
+			"run: could not use private key file '%s': %w",
+			creds.PrivateKeyFilename,
+			err)
+	}
+
+	return []ssh.AuthMethod{ssh.PublicKeys(key)}, ioutil.NopCloser(nil), nil
+}
+
+// dial opens a connection to the remote host. If the hostname
+// resolves to multiple addresses, they are tried using dialAddrs()
+// and the address used is recorded.
+func (r *remoteRunner) dial(ctx context.Context) (*ssh.Client, error) {
+	auths, closer, err := sshAuths(r.credentials)
+	if err != nil {
+		return nil, err
+	}
+	defer closer.Close() // nolint: errcheck
+	var banner string
+	config := &ssh.ClientConfig{
+		User: r.credentials.Username,
+		Auth: auths,
+		BannerCallback: func(message string) error {
+			banner = message
+			return nil
+		},
+	}
+	if err := r.hostKeys.configure(config, r.hostname, r.credentials.Port); err != nil {
+		return nil, err
+	}
+	conn, addr, jumpClients, err := r.dialConn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("run: connection to %s@%s failed: %w",
+			r.credentials.Username,
+			r.credentials.Hostname,
+			err)
+	}
+	r.mu.Lock()
+	r.address = addr
+	r.mu.Unlock()
+	c, chans, reqs, err := ssh.NewClientConn(conn, addr, config)
+	if err != nil {
+		conn.Close() // nolint: errcheck
+		closeClients(jumpClients)
+		if isAuthFailure(err) {
+			r.connectionEvent(ConnectionAuthFailed, err)
+		}
+		return nil, fmt.Errorf("run: connection to %s@%s (%s) failed: %w",
+			r.credentials.Username,
+			r.credentials.Hostname,
+			addr,
+			err)
+	}
+
+	r.mu.Lock()
+	r.serverVersion = string(c.ServerVersion())
+	r.banner = banner
+	r.mu.Unlock()
+	if r.onConnect != nil {
+		r.onConnect(r.hostInfo())
+	}
+	if atomic.SwapInt32(&r.connected, 1) == 0 {
+		r.connectionEvent(ConnectionConnected, nil)
+	} else {
+		r.connectionEvent(ConnectionReconnected, nil)
+	}
+	client := ssh.NewClient(c, chans, reqs)
+	if len(jumpClients) > 0 {
+		go func() {
+			client.Wait() // nolint: errcheck
+			closeClients(jumpClients)
+		}()
+	}
+
+	return client, nil
+}
+
+// dialConn opens a network connection to the SSH server of the
+// remote host, through its jump hosts, if any. The returned clients
+// are the connections to the jump hosts, which must be closed once
+// the connection is no longer used.
+func (r *remoteRunner) dialConn(ctx context.Context) (net.Conn, string, []*ssh.Client, error) {
+	hostname := r.hostname
+	if hostname == "" {
+		hostname = r.credentials.Hostname
+	}
+	if len(r.jumps) == 0 {
+		addrs, err := resolveAddrs(ctx, r.resolver, hostname)
+		if err != nil {
+			return nil, "", nil, err
+		}
+		conn, addr, err := dialAddrs(ctx, addrs, r.credentials.Port, r.connectTimeout, r.connectDelay)
+		return conn, addr, nil, err
+	}
+
+	var clients []*ssh.Client
+	var conn net.Conn
+	var addr string
+	for i, jump := range r.jumps {
+		var err error
+		if i == 0 {
+			var addrs []string
+			addrs, err = resolveAddrs(ctx, r.resolver, jump.hostname)
+			if err == nil {
+				conn, addr, err = dialAddrs(ctx, addrs, jump.credentials.Port, r.connectTimeout, r.connectDelay)
+			}
+		} else {
+			addr = net.JoinHostPort(jump.hostname, strconv.Itoa(jump.credentials.Port))
+			conn, err = clients[i-1].Dial("tcp", addr)
+		}
+		if err == nil {
+			var client *ssh.Client
+			client, err = r.newJumpClient(conn, addr, jump)
+			clients = append(clients, client)
+		}
+		if err != nil {
+			closeClients(clients)
+			return nil, "", nil, fmt.Errorf("jump host %s: %w", jump.credentials.Hostname, err)
+		}
+	}
+	addr = net.JoinHostPort(hostname, strconv.Itoa(r.credentials.Port))
+	conn, err := clients[len(clients)-1].Dial("tcp", addr)
+	if err != nil {
+		closeClients(clients)
+		return nil, "", nil, err
+	}
+
+	return conn, addr, clients, nil
+}
+
+// newJumpClient establishes an SSH connection to the jump host jump
+// over conn. Its host key is checked like the one of the remote host.
+func (r *remoteRunner) newJumpClient(conn net.Conn, addr string, jump sshJump) (*ssh.Client, error) {
+	auths, closer, err := sshAuths(jump.credentials)
+	if err != nil {
+		conn.Close() // nolint: errcheck
+		return nil, err
+	}
+	defer closer.Close() // nolint: errcheck
+	config := &ssh.ClientConfig{
+		User: jump.credentials.Username,
+		Auth: auths,
+	}
+	if err := r.hostKeys.configure(config, jump.hostname, jump.credentials.Port); err != nil {
+		conn.Close() // nolint: errcheck
+		return nil, err
+	}
+	c, chans, reqs, err := ssh.NewClientConn(conn, addr, config)
+	if err != nil {
+		conn.Close() // nolint: errcheck
+		return nil, err
+	}
+
+	return ssh.NewClient(c, chans, reqs), nil
+}
+
+// closeClients closes clients in reverse order.
+func closeClients(clients []*ssh.Client) {
+	for i := len(clients) - 1; i >= 0; i-- {
+		if clients[i] != nil {
+			clients[i].Close() // nolint: errcheck
+		}
+	}
+}
+
+// commandLine returns the command line sent to the remote host. The
+// variables env are passed on to commands run as another user using
+// EnvCmd since sudo resets the environment.
+func (r *remoteRunner) commandLine(spec *execSpec, env []string) string {
+	if r.remoteOS == RemoteWindows {
+		return r.windowsCommandLine(spec)
+	}
+	cmdLine := shellCommandLine(spec.cmd, spec.args)
+	if spec.shell {
+		cmdLine = fmt.Sprintf(`%s -c %s`, r.shellExecutable, shellCommandArg(spec.cmd))
+	}
+	user := runAsUser(spec, r.runAs)
+	if user == "" {
+		return cmdLine
+	}
+	if len(env) > 0 {
+		args := []string{EnvCmd}
+		for _, kv := range env {
+			args = append(args, shellQuote(kv))
+		}
+		cmdLine = strings.Join(append(args, cmdLine), " ")
+	}
+
+	return runAsCommandLine(user, cmdLine, r.sudo)
+}
+
+// withEnv prefixes cmdLine with the commands that add the variables
+// env to the environment of the login shell, so they are also
+// available to the arguments of cmdLine.
+func (r *remoteRunner) withEnv(env []string, cmdLine string) string {
+	if len(env) == 0 {
+		return cmdLine
+	}
+	if r.remoteOS == RemoteWindows {
+		var sets []string
+		for _, kv := range env {
+			sets = append(sets, fmt.Sprintf(`set "%s" && `, kv))
+		}
+		return strings.Join(sets, "") + cmdLine
+	}
+	args := []string{"export"}
+	for _, kv := range env {
+		args = append(args, shellQuote(kv))
+	}
+
+	return strings.Join(args, " ") + " && " + cmdLine
+}
+
+// inDir prefixes cmdLine with a cd to the working directory of spec,
+// if any.
+func (r *remoteRunner) inDir(spec *execSpec, cmdLine string) string {
+	dir := r.workDir(spec)
+	if dir == "" {
+		return cmdLine
+	}
+	if r.remoteOS == RemoteWindows {
+		return fmt.Sprintf("cd /d %s && %s", cmdQuote(dir), cmdLine)
+	}
+
+	return fmt.Sprintf("cd %s && %s", shellQuote(dir), cmdLine)
+}
+
+// workDir returns the working directory of the command described by
+// spec, which is relative to the Dir the runner was configured with.
+func (r *remoteRunner) workDir(spec *execSpec) string {
+	if r.remoteOS == RemoteWindows {
+		switch {
+		case spec.dir == "":
+			return r.dir
+		case r.dir == "" || isWindowsAbs(spec.dir):
+			return spec.dir
+		}
+		return strings.TrimRight(r.dir, `\/`) + `\` + strings.Replace(spec.dir, "/", `\`, -1)
+	}
+	switch {
+	case spec.dir == "":
+		return r.dir
+	case r.dir == "" || path.IsAbs(spec.dir):
+		return spec.dir
+	}
+
+	return path.Join(r.dir, spec.dir)
+}
+
+// format returns a string representation of the command described by
+// spec.
+func (r *remoteRunner) format(spec *execSpec) string {
+	env := maskEnv(appendEnv(r.env, spec.env))
+	return fmt.Sprintf(`ssh %s@%s %s`,
+		r.credentials.Username,
+		r.credentials.Hostname,
+		r.inDir(spec, r.withEnv(env, r.commandLine(spec, env))))
+}
+
+// session opens a new session for a command. If a reused connection
+// cannot open a session, e.g., because it was closed by the server,
+// it is discarded and a new connection is tried.
+func (r *remoteRunner) session(ctx context.Context) (*sshConn, *ssh.Session, error) {
+	for {
+		c, fresh, err := r.conns.acquire(ctx)
+		if err != nil {
+			return nil, nil, err
+		}
+		session, err := c.client.NewSession()
+		if err == nil {
+			return c, session, nil
+		}
+		r.conns.release(c, true)
+		if fresh {
+			return nil, nil, err
+		}
+	}
+}
+
+// Close closes the reused connection, if any, once its running
+// commands complete.
+func (r *remoteRunner) Close() error {
+	r.conns.close()
+
+	return nil
+}
+
+func (r *remoteRunner) execute(spec *execSpec) (string, string, int, error) {
+	c, session, err := r.session(spec.ctx)
+	if err != nil {
+		return "", "", 0, err
+	}
+	defer r.conns.release(c, false)
+	defer session.Close() // nolint: errcheck
+	client := c.client
+
+	// Hook up standard files.
+	var stdoutBuf, stderrBuf bytes.Buffer
+	session.Stdin = r.stdin
+	if spec.stdin != nil {
+		session.Stdin = spec.stdin
+	}
+	session.Stdout = r.stdout
+	if spec.capture {
+		session.Stdout = nil
+	}
+	if spec.stdout != nil {
+		session.Stdout = spec.stdout
+	}
+	if session.Stdout == nil {
+		session.Stdout = &stdoutBuf
+	}
+	session.Stderr = r.stderr
+	if spec.capture {
+		session.Stderr = nil
+	}
+	if spec.stderr != nil {
+		session.Stderr = spec.stderr
+	}
+	if session.Stderr == nil {
+		session.Stderr = &stderrBuf
+	}
+
+	if r.remoteOS == RemoteWindows && runAsUser(spec, r.runAs) != "" {
+		return "", "", 0, fmt.Errorf("running commands as another user is not supported on Windows hosts")
+	}
+	env := appendEnv(r.env, spec.env)
+	cmdLine := r.commandLine(spec, env)
+	if spec.onStart == nil && spec.pidFile == "" && spec.ctx.Done() == nil {
+		err = session.Run(r.inDir(spec, r.withEnv(env, cmdLine)))
+	} else if spec.onStart == nil && spec.pidFile == "" && spec.shutdownCtx {
+		err = runAbandonable(spec.ctx, session, r.inDir(spec, r.withEnv(env, cmdLine)))
+		if spec.ctx.Err() != nil {
+			return "", "", 0, spec.ctx.Err()
+		}
+	} else if r.remoteOS == RemoteWindows {
+		return "", "", 0, fmt.Errorf("cancelable and detached commands are not supported on Windows hosts")
+	} else {
+		err = r.runCancelable(spec, client, session, r.inDir(spec, r.withEnv(env, "exec "+cmdLine)))
+		if spec.ctx.Err() != nil {
+			return "", "", 0, spec.ctx.Err()
+		}
+	}
+
+	code := 0
+	if err != nil {
+		exitErr, ok := err.(*ssh.ExitError)
+		if !ok {
+			return "", "", 0, err
+		}
+		code = exitErr.ExitStatus()
+		if exitErr.Signal() != "" {
+			// Killed by a signal.
+			return "", "", 0, err
+		}
+	}
+
+	return stdoutBuf.String(), stderrBuf.String(), code, nil
+}
+
+// runAbandonable runs cmdLine in session like session.Run(). If ctx is
+// done before the command completes, the session is closed without
+// killing the command.
+func runAbandonable(ctx context.Context, session *ssh.Session, cmdLine string) error {
+	if err := session.Start(cmdLine); err != nil {
+		return err
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- session.Wait()
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		session.Close() // nolint: errcheck
+		return ctx.Err()
+	}
+}
+
+// runCancelable runs cmdLine in session. The remote shell first
+// reports its PID (which is also the process group ID of the
+// command, since sshd starts each session in a new session) on
+// stdout, writes it to spec.pidFile if set, and then runs cmdLine,
+// which is expected to exec the command. The PID is passed to
+// spec.onStart, if set. If spec.ctx is done before the command
+// completes, the remote process group is killed using RemoteKillCmd.
+func (r *remoteRunner) runCancelable(
+	spec *execSpec,
+	client *ssh.Client,
+	session *ssh.Session,
+	cmdLine string) error {
+
+	ctx := spec.ctx
+	pw := &pidWriter{w: session.Stdout, onPID: spec.onStart}
+	session.Stdout = pw
+	prefix := "echo $$; "
+	if spec.pidFile != "" {
+		prefix += fmt.Sprintf("echo $$ > %s || exit 1; ", shellQuote(spec.pidFile))
+	}
+	if err := session.Start(prefix + cmdLine); err != nil {
+		return err
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- session.Wait()
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		if pid := pw.PID(); pid > 0 {
+			r.kill(client, pid)
+		}
+		return ctx.Err()
+	}
+}
+
+// kill kills the remote process group pid using a new session on
+// client.
+func (r *remoteRunner) kill(client *ssh.Client, pid int) {
+	session, err := client.NewSession()
+	if err != nil {
+		return
+	}
+	defer session.Close() // nolint: errcheck
+	_ = session.Run(fmt.Sprintf(RemoteKillCmd, pid))
+}
+
+// Run runs a command like glibc's exec() call. It returns the
+// standard out, standard error, and exit code of the command when it
+// completes.
+func (r *remoteRunner) Run(cmd string, args ...string) (string, string, int, error) {
+	return r.execute(&execSpec{ctx: context.Background(), cmd: cmd, args: args})
+}
+
+// FormatRun returns a string representation of the what command would
+// be run using Run(). Useful for logging commands.
+func (r *remoteRunner) FormatRun(cmd string, args ...string) string {
+	return r.format(&execSpec{cmd: cmd, args: args})
+}
+
+// Shell runs a command in a shell. The command is passed to the shell
+// as the -c option, so just about any shell code that can be used on
+// the command-line will be passed to it. It returns the standard out,
+// standard error, and exit code of the command when it completes.
+func (r *remoteRunner) Shell(cmd string) (string, string, int, error) {
+	return r.execute(&execSpec{ctx: context.Background(), cmd: cmd, shell: true})
+}
+
+// FormatShell returns a string representation of the what command
+// would be run using Shell().  Useful for logging commands.
+func (r *remoteRunner) FormatShell(cmd string) string {
+	return r.format(&execSpec{cmd: cmd, shell: true})
+}
+
+// pidWriter strips the first line written to it, which is expected to
+// be the PID of the remote process, and passes everything after it on
+// to w. onPID, if not nil, is called with the PID once it is known.
+type pidWriter struct {
+	w     io.Writer
+	onPID func(pid int)
+	mu    sync.Mutex
+	line  []byte
+	pid   int
+	done  bool
+}
+
+func (p *pidWriter) Write(b []byte) (int, error) {
+	p.mu.Lock()
+	if p.done {
+		p.mu.Unlock()
+		return p.w.Write(b)
+	}
+	n := len(b)
+	i := bytes.IndexByte(b, '\n')
+	if i < 0 {
+		p.line = append(p.line, b...)
+		p.mu.Unlock()
+		return n, nil
+	}
+	p.line = append(p.line, b[:i]...)
+	p.pid, _ = strconv.Atoi(strings.TrimSpace(string(p.line)))
+	p.done = true
+	p.mu.Unlock()
+	if p.onPID != nil && p.pid > 0 {
+		p.onPID(p.pid)
+	}
+	if rest := b[i+1:]; len(rest) > 0 {
+		if _, err := p.w.Write(rest); err != nil {
+			return 0, err
+		}
+	}
+
+	return n, nil
+}
+
+// PID returns the PID of the remote process or 0 if it has not been
+// reported yet.
+func (p *pidWriter) PID() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.pid
+}
@@ -7,10 +7,14 @@ package logrun_test
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"fmt"
+	"os"
+	"path/filepath"
 	"regexp"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/apatters/go-logrun"
 	"github.com/stretchr/testify/assert"
@@ -684,3 +688,111 @@ func TestRemoteLogRun_ShellDryrun(t *testing.T) {
 	out.Reset()
 	errOut.Reset()
 }
+
+func TestRemoteLogRun_ShellTimeout(t *testing.T) {
+	r, err := logrun.NewRemoteLogRun(logrun.RemoteConfig{
+		Timeout: 2 * time.Second,
+	})
+	t.Logf("err = %v", err)
+	require.NoError(t, err)
+	marker := fmt.Sprintf("logrun-timeout-%d", time.Now().UnixNano())
+
+	// The remote sleep must be killed when the command times out.
+	start := time.Now()
+	stdout, stderr, code := r.Shell(fmt.Sprintf("/bin/sleep 30 # %s", marker))
+	elapsed := time.Since(start)
+	t.Logf("stdout = %q", stdout)
+	t.Logf("stderr = %q", stderr)
+	t.Logf("code = %d", code)
+	t.Logf("elapsed = %s", elapsed)
+	assert.Empty(t, stdout)
+	assert.EqualValues(t, "command timed out after 2s", stderr)
+	assert.Equal(t, logrun.ExitErrorExecute, code)
+	assert.True(t, elapsed < 30*time.Second)
+
+	r.SetTimeout(0)
+	stdout, stderr, code = r.Shell(fmt.Sprintf("pgrep -f '[%s]%s'", marker[:1], marker[1:]))
+	t.Logf("stdout = %q", stdout)
+	t.Logf("stderr = %q", stderr)
+	t.Logf("code = %d", code)
+	assert.Empty(t, stdout)
+	assert.NotZero(t, code)
+}
+
+func TestRemoteLogRun_RunContext(t *testing.T) {
+	s := newTestSSHServer(t, nil)
+	defer s.Close()
+
+	r, err := logrun.NewRemoteLogRun(logrun.RemoteConfig{
+		Credentials: s.Credentials(),
+	})
+	require.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	stdout, stderr, code := r.RunContext(ctx, "/bin/sleep", "5")
+	elapsed := time.Since(start)
+	t.Logf("stdout = %q", stdout)
+	t.Logf("stderr = %q", stderr)
+	t.Logf("code = %d", code)
+	t.Logf("elapsed = %s", elapsed)
+	assert.Empty(t, stdout)
+	assert.EqualValues(t, context.DeadlineExceeded.Error(), stderr)
+	assert.Equal(t, logrun.ExitErrorExecute, code)
+	assert.True(t, elapsed < 5*time.Second)
+
+	stdout, _, code = r.ShellContext(context.Background(), "echo hello")
+	assert.Equal(t, "hello\n", stdout)
+	assert.Zero(t, code)
+}
+
+func TestRemoteLogRun_EnvAndDir(t *testing.T) {
+	s := newTestSSHServer(t, nil)
+	defer s.Close()
+	dir := t.TempDir()
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "sub"), 0755))
+	log, out, _ := newLogger()
+	r, err := logrun.NewRemoteLogRun(logrun.RemoteConfig{
+		LogFunc:     log.Println,
+		Credentials: s.Credentials(),
+		Env:         []string{"GREETING=hello world", "API_TOKEN=s3cret"},
+		Dir:         dir,
+	})
+	require.NoError(t, err)
+	defer r.Close() // nolint: errcheck
+
+	stdout, stderr, code := r.Shell("echo $GREETING $API_TOKEN; pwd")
+	t.Logf("stdout = %q", stdout)
+	t.Logf("stderr = %q", stderr)
+	t.Logf("out = %q", out)
+	require.Zero(t, code)
+	assert.Equal(t, "hello world s3cret\n"+dir+"\n", stdout)
+	assert.Contains(t, out.String(),
+		"cd '"+dir+"' && export 'GREETING=hello world' 'API_TOKEN=********' && /bin/sh -c")
+	assert.NotContains(t, out.String(), "s3cret")
+	assert.Equal(t, dir, r.Config().Dir)
+
+	// Working directories are relative to Dir, including for
+	// cancelable commands.
+	r.PushDir("sub")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stdout, _, code = r.RunContext(ctx, "/bin/pwd")
+	t.Logf("stdout = %q", stdout)
+	require.Zero(t, code)
+	assert.Equal(t, filepath.Join(dir, "sub")+"\n", stdout)
+	stdout, _, code = r.RunContext(ctx, "/usr/bin/printenv", "GREETING")
+	t.Logf("stdout = %q", stdout)
+	require.Zero(t, code)
+	assert.Equal(t, "hello world\n", stdout)
+
+	// Per-call variables override Env.
+	out.Reset()
+	stdout, _, code = r.With(logrun.WithEnv("GREETING=hi", "DB_PASSWORD=pw")).Shell("echo $GREETING $DB_PASSWORD")
+	t.Logf("stdout = %q", stdout)
+	t.Logf("out = %q", out)
+	require.Zero(t, code)
+	assert.Equal(t, "hi pw\n", stdout)
+	assert.Contains(t, out.String(), "'GREETING=hi' 'DB_PASSWORD=********' &&")
+}
@@ -0,0 +1,146 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Skipped  int              `xml:"skipped,attr"`
+	Time     string           `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Skipped  int             `xml:"skipped,attr"`
+	Time     string          `xml:"time,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	ClassName string        `xml:"classname,attr"`
+	Name      string        `xml:"name,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Skipped   *junitSkipped `xml:"skipped,omitempty"`
+}
+
+type junitSkipped struct {
+	Message string `xml:"message,attr,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// failureMessage returns a short description of why c failed.
+func (c CommandStat) failureMessage() string {
+	if c.Error != "" {
+		return c.Error
+	}
+
+	return fmt.Sprintf("exit code %d", c.ExitCode)
+}
+
+// WriteJUnit writes the recorded commands to w as JUnit XML so runs
+// can be displayed as test results by CI servers such as Jenkins and
+// GitLab. Each host is a test suite and each command a test case
+// whose class name is the log section it was run in, or the host
+// outside of any section. name is the name of the run.
+func (rec *Recorder) WriteJUnit(w io.Writer, name string) error {
+	s := rec.Summary()
+	suites := junitTestSuites{
+		Name:     name,
+		Tests:    s.Run + s.Skipped,
+		Failures: s.Failed,
+		Skipped:  s.Skipped,
+		Time:     fmt.Sprintf("%.3f", s.Duration.Seconds()),
+	}
+	index := make(map[string]int)
+	for _, h := range s.Hosts {
+		index[h.Host] = len(suites.Suites)
+		suites.Suites = append(suites.Suites, junitTestSuite{
+			Name:     h.Host,
+			Tests:    h.Run + h.Skipped,
+			Failures: h.Failed,
+			Skipped:  h.Skipped,
+			Time:     fmt.Sprintf("%.3f", h.Duration.Seconds()),
+		})
+	}
+	for _, c := range rec.Commands() {
+		tc := junitTestCase{
+			ClassName: c.Section,
+			Name:      c.Command,
+			Time:      fmt.Sprintf("%.3f", c.Duration.Seconds()),
+		}
+		if tc.ClassName == "" {
+			tc.ClassName = c.Host
+		}
+		switch {
+		case c.Skipped:
+			tc.Skipped = &junitSkipped{Message: string(c.SkipReason)}
+		case c.Failed:
+			tc.Failure = &junitFailure{Message: c.failureMessage(), Text: c.Error}
+		}
+		suite := &suites.Suites[index[c.Host]]
+		suite.Cases = append(suite.Cases, tc)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(suites); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+
+	return err
+}
+
+// WriteTAP writes the recorded commands to w in the Test Anything
+// Protocol (version 13) format. Each command is a test point; failed
+// commands include their exit code and error as YAML diagnostics and
+// skipped commands are marked with the SKIP directive.
+func (rec *Recorder) WriteTAP(w io.Writer) error {
+	commands := rec.Commands()
+	var b strings.Builder
+	fmt.Fprintf(&b, "TAP version 13\n1..%d\n", len(commands))
+	for i, c := range commands {
+		// '#' starts a directive in TAP.
+		desc := strings.Replace(c.Host+": "+c.Command, "#", `\#`, -1)
+		switch {
+		case c.Skipped:
+			fmt.Fprintf(&b, "ok %d - %s # SKIP not run (%s)\n", i+1, desc, c.SkipReason)
+		case c.Failed:
+			fmt.Fprintf(&b, "not ok %d - %s\n", i+1, desc)
+			b.WriteString("  ---\n")
+			fmt.Fprintf(&b, "  exit_code: %d\n", c.ExitCode)
+			if c.Error != "" {
+				fmt.Fprintf(&b, "  error: %q\n", c.Error)
+			}
+			fmt.Fprintf(&b, "  duration_ms: %d\n", c.Duration.Nanoseconds()/1e6)
+			b.WriteString("  ...\n")
+		default:
+			fmt.Fprintf(&b, "ok %d - %s\n", i+1, desc)
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+
+	return err
+}
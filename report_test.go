@@ -0,0 +1,86 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun_test
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	"github.com/apatters/go-logrun"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newReportRecorder() (*logrun.Recorder, string) {
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{})
+	l.SetRecorder(logrun.NewRecorder())
+	l.Run("/bin/true")
+	l.BeginSection("checks")
+	l.Shell("echo '#1' && exit 3")
+	l.EndSection() // nolint: errcheck
+	l.SetDryrun(true)
+	l.Run("/bin/true")
+
+	return l.Recorder(), l.Host().String()
+}
+
+func TestRecorder_WriteJUnit(t *testing.T) {
+	rec, host := newReportRecorder()
+	var buf bytes.Buffer
+	err := rec.WriteJUnit(&buf, "provision")
+	t.Logf("junit =\n%s", buf.String())
+	require.NoError(t, err)
+
+	var doc struct {
+		Name     string `xml:"name,attr"`
+		Tests    int    `xml:"tests,attr"`
+		Failures int    `xml:"failures,attr"`
+		Suites   []struct {
+			Name  string `xml:"name,attr"`
+			Cases []struct {
+				ClassName string `xml:"classname,attr"`
+				Name      string `xml:"name,attr"`
+				Failure   *struct {
+					Message string `xml:"message,attr"`
+				} `xml:"failure"`
+				Skipped *struct {
+					Message string `xml:"message,attr"`
+				} `xml:"skipped"`
+			} `xml:"testcase"`
+		} `xml:"testsuite"`
+	}
+	require.NoError(t, xml.Unmarshal(buf.Bytes(), &doc))
+	assert.Equal(t, "provision", doc.Name)
+	assert.Equal(t, 3, doc.Tests)
+	assert.Equal(t, 1, doc.Failures)
+	require.Len(t, doc.Suites, 1)
+	assert.Equal(t, host, doc.Suites[0].Name)
+	cases := doc.Suites[0].Cases
+	require.Len(t, cases, 3)
+	assert.Equal(t, host, cases[0].ClassName)
+	assert.Nil(t, cases[0].Failure)
+	assert.Equal(t, "checks", cases[1].ClassName)
+	require.NotNil(t, cases[1].Failure)
+	assert.Equal(t, "exit code 3", cases[1].Failure.Message)
+	require.NotNil(t, cases[2].Skipped)
+	assert.Equal(t, "dryrun", cases[2].Skipped.Message)
+}
+
+func TestRecorder_WriteTAP(t *testing.T) {
+	rec, host := newReportRecorder()
+	var buf bytes.Buffer
+	err := rec.WriteTAP(&buf)
+	t.Logf("tap =\n%s", buf.String())
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(buf.String(), "TAP version 13\n"+
+		"1..3\n"+
+		"ok 1 - "+host+": /bin/true\n"+
+		"not ok 2 - "+host+": /bin/sh -c \"echo '\\#1' && exit 3\"\n"+
+		"  ---\n"+
+		"  exit_code: 3\n"))
+	assert.Contains(t, buf.String(), "  ...\nok 3 - "+host+": /bin/true # SKIP not run (dryrun)\n")
+}
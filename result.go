@@ -0,0 +1,103 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun
+
+import (
+	"fmt"
+	"time"
+)
+
+// Result is the outcome of a command run by RunResult() or
+// ShellResult().
+type Result struct {
+	// Host identifies the host the command was run on.
+	Host string
+
+	// Command is the command as it was logged.
+	Command string
+
+	// Stdout and Stderr are the captured output of the command.
+	// They are empty if the output was sent elsewhere, e.g., by
+	// WithStdout().
+	Stdout string
+	Stderr string
+
+	// StderrLines is Stderr classified using ClassifyStderr(),
+	// e.g., to tell benign warnings from real errors.
+	StderrLines StderrLines
+
+	// Lines are the lines of standard output and standard error
+	// in the order they were received, along with the time they
+	// were received. They are only recorded if
+	// WithLineTimestamps() is in effect.
+	Lines []OutputLine
+
+	// ExitCode is the exit code of the command. It is only
+	// meaningful if Err is nil and Skipped is false.
+	ExitCode int
+
+	// Duration is how long the command took to run.
+	Duration time.Duration
+
+	// Skipped is true if the command was only logged because of
+	// Dryrun or check mode, or skipped because of a guard.
+	Skipped bool
+
+	// SkipReason is why the command was skipped or SkipNone if
+	// it was run.
+	SkipReason SkipReason
+
+	// Err is the error that prevented the command from being run
+	// or completing, e.g., an SSH authentication failure, a
+	// missing executable, or a timeout. It is nil if the command
+	// ran and exited, whatever its exit code.
+	Err error
+}
+
+// Success returns true if the command ran and exited with a zero
+// exit code, or was skipped.
+func (res Result) Success() bool {
+	return res.Err == nil && res.ExitCode == 0
+}
+
+// String returns a short description of the outcome of the command.
+func (res Result) String() string {
+	switch {
+	case res.Err != nil:
+		return fmt.Sprintf("%s: %s", res.Command, res.Err)
+	case res.Skipped:
+		return trf("%s: skipped (%s)", res.Command, tr(string(res.SkipReason)))
+	}
+
+	return trf("%s: exit code %d in %s", res.Command, res.ExitCode, res.Duration)
+}
+
+// RunResult first logs the command and then runs it like Run(). The
+// returned error is the same as Result.Err: it is only non-nil if the
+// command could not be run, so callers can distinguish a command that
+// failed from one that was never run, e.g.,
+//
+//	res, err := runner.RunResult("systemctl", "is-active", "nginx")
+//	if err != nil {
+//		return err // e.g., the host is unreachable
+//	}
+//	active := res.ExitCode == 0
+func (r *LogRun) RunResult(cmd string, args ...string) (_ Result, err error) {
+	r = r.beginHelper()
+	defer r.addErrorContext(&err, r.commandSeq())
+	res := r.logAndExecute(execSpec{cmd: cmd, args: args})
+
+	return res, res.Err
+}
+
+// ShellResult first logs the command and then runs it in a shell like
+// Shell(). See RunResult().
+func (r *LogRun) ShellResult(cmd string) (_ Result, err error) {
+	r = r.beginHelper()
+	defer r.addErrorContext(&err, r.commandSeq())
+	res := r.logAndExecute(execSpec{cmd: r.shellCmd(cmd), shell: true})
+
+	return res, res.Err
+}
@@ -0,0 +1,148 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apatters/go-logrun"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalLogRun_RunResult(t *testing.T) {
+	log, out, _ := newLogger()
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{
+		LogFunc: log.Println,
+	})
+	res, err := l.RunResult("/bin/echo", "hello")
+	t.Logf("res = %+v", res)
+	t.Logf("out = %q", out)
+	require.NoError(t, err)
+	assert.Equal(t, "/bin/echo hello", res.Command)
+	assert.Equal(t, "hello\n", res.Stdout)
+	assert.Zero(t, res.ExitCode)
+	assert.True(t, res.Success())
+	assert.False(t, res.Skipped)
+	assert.Equal(t, logrun.SkipNone, res.SkipReason)
+	assert.Equal(t, "/bin/echo hello\n", out.String())
+
+	// A command exiting with 1 is not an error.
+	res, err = l.RunResult("/bin/false")
+	t.Logf("res = %+v", res)
+	require.NoError(t, err)
+	assert.Equal(t, 1, res.ExitCode)
+	assert.False(t, res.Success())
+
+	res, err = l.RunResult("/does/not/exist")
+	t.Logf("res = %+v", res)
+	t.Logf("err = %v", err)
+	assert.Error(t, err)
+	assert.Equal(t, err, res.Err)
+	assert.False(t, res.Success())
+	assert.Contains(t, res.String(), "/does/not/exist: ")
+}
+
+func TestLocalLogRun_ShellResult(t *testing.T) {
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{})
+	res, err := l.ShellResult("echo out; echo err >&2; exit 3")
+	t.Logf("res = %+v", res)
+	t.Logf("res.String() = %s", res)
+	require.NoError(t, err)
+	assert.Equal(t, "out\n", res.Stdout)
+	assert.Equal(t, "err\n", res.Stderr)
+	assert.Equal(t, 3, res.ExitCode)
+	assert.Contains(t, res.String(), "exit code 3")
+
+	// Stderr is classified.
+	res, err = l.ShellResult("echo 'warning: deprecated' >&2; echo 'failed' >&2")
+	require.NoError(t, err)
+	assert.Equal(t, logrun.StderrLines{
+		{Text: "warning: deprecated", Level: logrun.StderrWarning},
+		{Text: "failed", Level: logrun.StderrError},
+	}, res.StderrLines)
+
+	l.Dryrun = true
+	res, err = l.ShellResult("exit 3")
+	t.Logf("res = %+v", res)
+	require.NoError(t, err)
+	assert.True(t, res.Skipped)
+	assert.Equal(t, logrun.SkipDryrun, res.SkipReason)
+	assert.True(t, res.Success())
+	assert.Equal(t, `/bin/sh -c "exit 3": skipped (dryrun)`, res.String())
+}
+
+func TestRemoteLogRun_ShellResult(t *testing.T) {
+	s := newTestSSHServer(t, nil)
+	defer s.Close()
+
+	r, err := logrun.NewRemoteLogRun(logrun.RemoteConfig{
+		Credentials: s.Credentials(),
+	})
+	require.NoError(t, err)
+	res, err := r.ShellResult("exit 1")
+	t.Logf("res = %+v", res)
+	require.NoError(t, err)
+	assert.Equal(t, 1, res.ExitCode)
+	assert.Equal(t, r.Host().String(), res.Host)
+
+	creds := s.Credentials()
+	creds.Password = "wrong"
+	bad, err := logrun.NewRemoteLogRun(logrun.RemoteConfig{
+		Credentials: creds,
+	})
+	require.NoError(t, err)
+	res, err = bad.RunResult("/bin/true")
+	t.Logf("res = %+v", res)
+	t.Logf("err = %v", err)
+	assert.Error(t, err)
+	assert.Zero(t, res.ExitCode)
+}
+
+func TestLocalLogRun_WithLineTimestamps(t *testing.T) {
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{}).With(logrun.WithLineTimestamps())
+	before := time.Now()
+	res, err := l.ShellResult("echo a; sleep 0.3; echo b >&2; printf c")
+	t.Logf("res = %+v", res)
+	require.NoError(t, err)
+	assert.Equal(t, "a\nc", res.Stdout)
+	assert.Equal(t, "b\n", res.Stderr)
+	require.Len(t, res.Lines, 3)
+	for _, line := range res.Lines {
+		t.Logf("line = %s", line)
+	}
+	assert.Equal(t, "a", res.Lines[0].Text)
+	assert.False(t, res.Lines[0].Stderr)
+	assert.Equal(t, "b", res.Lines[1].Text)
+	assert.True(t, res.Lines[1].Stderr)
+	assert.Equal(t, "c", res.Lines[2].Text)
+	assert.False(t, res.Lines[0].Time.Before(before))
+	assert.True(t, res.Lines[1].Time.Sub(res.Lines[0].Time) >= 250*time.Millisecond)
+	assert.True(t, strings.HasSuffix(res.Lines[1].String(), " stderr: b"))
+
+	// Lines sent to writers are prefixed with their timestamp.
+	var buf strings.Builder
+	stdout, _, code := l.With(logrun.WithStdout(&buf)).Shell("echo x; echo y")
+	t.Logf("buf = %q", buf.String())
+	assert.Zero(t, code)
+	assert.Empty(t, stdout)
+	assert.Regexp(t, `^\d{4}-\d\d-\d\dT\d\d:\d\d:\d\d\.\d{3}\S* x\n\S+ y\n$`, buf.String())
+
+	// Captured output and streamed lines are not changed.
+	stdout, _, _ = l.Shell("echo x")
+	assert.Equal(t, "x\n", stdout)
+	var streamed []string
+	_, err = l.ShellStream(logrun.StreamHandlers{
+		OnStdoutLine: func(line string) { streamed = append(streamed, line) },
+	}, "echo x")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"x"}, streamed)
+
+	res, err = logrun.NewLocalLogRun(logrun.LocalConfig{}).ShellResult("echo a")
+	require.NoError(t, err)
+	assert.Nil(t, res.Lines)
+}
@@ -0,0 +1,241 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Exit codes returned by the rsync command. See the EXIT VALUES
+// section of rsync(1) for details.
+const (
+	// RsyncExitSyntax indicates a syntax or usage error.
+	RsyncExitSyntax = 1
+
+	// RsyncExitProtocol indicates a protocol incompatibility.
+	RsyncExitProtocol = 2
+
+	// RsyncExitFileSelect indicates errors selecting input/output
+	// files or directories.
+	RsyncExitFileSelect = 3
+
+	// RsyncExitUnsupported indicates that the requested action is
+	// not supported.
+	RsyncExitUnsupported = 4
+
+	// RsyncExitStartClient indicates an error starting the
+	// client-server protocol.
+	RsyncExitStartClient = 5
+
+	// RsyncExitSocketIO indicates an error in socket I/O.
+	RsyncExitSocketIO = 10
+
+	// RsyncExitFileIO indicates an error in file I/O.
+	RsyncExitFileIO = 11
+
+	// RsyncExitStreamIO indicates an error in the rsync protocol
+	// data stream.
+	RsyncExitStreamIO = 12
+
+	// RsyncExitIPC indicates an error in IPC code.
+	RsyncExitIPC = 14
+
+	// RsyncExitSignal indicates that rsync received SIGUSR1 or
+	// SIGINT.
+	RsyncExitSignal = 20
+
+	// RsyncExitWaitChild indicates an error returned by
+	// waitpid().
+	RsyncExitWaitChild = 21
+
+	// RsyncExitMalloc indicates an error allocating core memory
+	// buffers.
+	RsyncExitMalloc = 22
+
+	// RsyncExitPartialTransfer indicates a partial transfer due
+	// to an error.
+	RsyncExitPartialTransfer = 23
+
+	// RsyncExitVanished indicates a partial transfer due to
+	// vanished source files.
+	RsyncExitVanished = 24
+
+	// RsyncExitDeleteLimit indicates that the --max-delete limit
+	// stopped deletions.
+	RsyncExitDeleteLimit = 25
+
+	// RsyncExitTimeout indicates a timeout in data send/receive.
+	RsyncExitTimeout = 30
+
+	// RsyncExitConnectTimeout indicates a timeout waiting for a
+	// daemon connection.
+	RsyncExitConnectTimeout = 35
+)
+
+var rsyncExitDescriptions = map[int]string{
+	RsyncExitSyntax:          "syntax or usage error",
+	RsyncExitProtocol:        "protocol incompatibility",
+	RsyncExitFileSelect:      "errors selecting input/output files, dirs",
+	RsyncExitUnsupported:     "requested action not supported",
+	RsyncExitStartClient:     "error starting client-server protocol",
+	RsyncExitSocketIO:        "error in socket I/O",
+	RsyncExitFileIO:          "error in file I/O",
+	RsyncExitStreamIO:        "error in rsync protocol data stream",
+	RsyncExitIPC:             "error in IPC code",
+	RsyncExitSignal:          "received SIGUSR1 or SIGINT",
+	RsyncExitWaitChild:       "some error returned by waitpid()",
+	RsyncExitMalloc:          "error allocating core memory buffers",
+	RsyncExitPartialTransfer: "partial transfer due to error",
+	RsyncExitVanished:        "partial transfer due to vanished source files",
+	RsyncExitDeleteLimit:     "the --max-delete limit stopped deletions",
+	RsyncExitTimeout:         "timeout in data send/receive",
+	RsyncExitConnectTimeout:  "timeout waiting for daemon connection",
+}
+
+// RsyncError is the error returned by Rsync() when the rsync command
+// exits with a non-zero exit code. Callers can use the Code field or
+// the helper methods to treat partial transfers differently from
+// hard failures.
+type RsyncError struct {
+	// Code is the exit code returned by the rsync command.
+	Code int
+
+	// Stderr is the standard error output of the rsync command.
+	Stderr string
+
+	// Lines is Stderr classified using the LogRun's
+	// StderrClassifier.
+	Lines StderrLines
+}
+
+// Error returns a string representation of the error.
+func (e *RsyncError) Error() string {
+	desc, ok := rsyncExitDescriptions[e.Code]
+	if !ok {
+		desc = fmt.Sprintf("exit code %d", e.Code)
+	}
+
+	return fmt.Sprintf("rsync command failed (%s): %s", desc, strings.TrimSpace(e.Stderr))
+}
+
+// Description returns the rsync(1) description of the exit code or
+// the empty string if the code is not a documented rsync exit code.
+func (e *RsyncError) Description() string {
+	return rsyncExitDescriptions[e.Code]
+}
+
+// Partial returns true if rsync transferred some, but not all, of
+// the files, i.e., the exit code is either RsyncExitPartialTransfer
+// or RsyncExitVanished.
+func (e *RsyncError) Partial() bool {
+	return e.Code == RsyncExitPartialTransfer || e.Code == RsyncExitVanished
+}
+
+// Vanished returns true if the only problem encountered was that
+// source files vanished before they could be transferred. This is
+// typically treated as a warning when copying directories that are
+// actively changing.
+func (e *RsyncError) Vanished() bool {
+	return e.Code == RsyncExitVanished
+}
+
+// Timeout returns true if rsync failed because of a timeout.
+func (e *RsyncError) Timeout() bool {
+	return e.Code == RsyncExitTimeout || e.Code == RsyncExitConnectTimeout
+}
+
+// RsyncOptions selects options of a single Rsync() call, see
+// RsyncWithOptions(). They are added after RsyncCmdOptions.
+type RsyncOptions struct {
+	// Delete deletes files in dest that are not in src
+	// (--delete).
+	Delete bool
+
+	// Includes are patterns of files that are transferred even if
+	// they match Excludes (--include).
+	Includes []string
+
+	// Excludes are patterns of files that are not transferred
+	// (--exclude).
+	Excludes []string
+
+	// Checksum compares files by checksum instead of by size and
+	// modification time (--checksum).
+	Checksum bool
+
+	// BandwidthLimit is the maximum transfer rate in KiB per
+	// second. If zero, the rate is not limited (--bwlimit).
+	BandwidthLimit int
+
+	// DryRun lets rsync report what it would transfer without
+	// changing dest (--dry-run). Unlike Dryrun of the LogRun,
+	// rsync is run.
+	DryRun bool
+
+	// ExtraArgs are added to the command line as is, after the
+	// other options.
+	ExtraArgs []string
+
+	// Progress, if not nil, is called with the progress of the
+	// transfer reported by rsync using RsyncProgressCmdOptions,
+	// e.g., to drive a progress bar, and with the totals once
+	// rsync has exited successfully. With DryRun, the totals are
+	// those rsync would transfer. It is not called in check mode.
+	Progress func(RsyncProgress)
+}
+
+// isZero returns true if no options are selected.
+func (o RsyncOptions) isZero() bool {
+	return !o.Delete && len(o.Includes) == 0 && len(o.Excludes) == 0 &&
+		!o.Checksum && o.BandwidthLimit == 0 && !o.DryRun && len(o.ExtraArgs) == 0 &&
+		o.Progress == nil
+}
+
+// args returns the rsync options selected by o. Includes precede
+// Excludes since rsync uses the first matching pattern.
+func (o RsyncOptions) args() []string {
+	var args []string
+	if o.Delete {
+		args = append(args, "--delete")
+	}
+	for _, pattern := range o.Includes {
+		args = append(args, "--include="+pattern)
+	}
+	for _, pattern := range o.Excludes {
+		args = append(args, "--exclude="+pattern)
+	}
+	if o.Checksum {
+		args = append(args, "--checksum")
+	}
+	if o.BandwidthLimit > 0 {
+		args = append(args, "--bwlimit="+strconv.Itoa(o.BandwidthLimit))
+	}
+	if o.DryRun {
+		args = append(args, "--dry-run")
+	}
+
+	return append(args, o.ExtraArgs...)
+}
+
+// RsyncWithOptions is like Rsync() but adds the rsync options selected
+// by opts, so options can differ between calls without changing
+// RsyncCmdOptions, e.g.,
+//
+//	runner.RsyncWithOptions("build/", "web1:/srv/app/", logrun.RsyncOptions{
+//		Delete:   true,
+//		Excludes: []string{"*.log"},
+//	})
+//
+// No transfer is recorded for DryRun. Options are not supported by
+// LogRuns created by NewDockerLogRun() or when falling back to
+// Upload() and Download() for remote hosts authenticated with a
+// password.
+func (r *LogRun) RsyncWithOptions(src string, dest string, opts RsyncOptions) (err error) {
+	r = r.beginHelper()
+	defer r.addErrorContext(&err, r.commandSeq())
+	return r.rsyncWithOptions(src, dest, opts)
+}
@@ -0,0 +1,135 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun
+
+import (
+	"bytes"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// RsyncProgressCmdOptions are the command-line options added to
+// RsyncCmd so it reports the progress of the whole transfer when
+// RsyncOptions.Progress is set. This command (and options) has been
+// tested on RHEL/CentOS 7 and Ubuntu 18.04.
+var RsyncProgressCmdOptions = []string{"--info=progress2"}
+
+// RsyncProgress is the progress of a transfer by RsyncWithOptions()
+// passed to RsyncOptions.Progress.
+type RsyncProgress struct {
+	// Bytes is the number of bytes of the files transferred so
+	// far.
+	Bytes int64
+
+	// Percent is the part of the transfer completed so far as
+	// estimated by rsync.
+	Percent int
+
+	// BytesPerSecond is the current transfer rate.
+	BytesPerSecond float64
+
+	// FilesDone is the number of files transferred so far.
+	FilesDone int
+
+	// FilesToCheck is the number of files rsync has yet to check
+	// and FilesTotal is the number of files it has found so far.
+	FilesToCheck int
+	FilesTotal   int
+
+	// Done is true for the last report, which is made once rsync
+	// has exited successfully. Its Bytes and FilesDone are the
+	// totals reported by rsync --stats and BytesSent and
+	// BytesReceived are set.
+	Done          bool
+	BytesSent     int64
+	BytesReceived int64
+}
+
+// rsyncProgressRegexp matches a progress line printed by rsync
+// --info=progress2, e.g.,
+// "  1,238,099  44%  118.07MB/s    0:00:00 (xfr#1, to-chk=5/7)".
+var rsyncProgressRegexp = regexp.MustCompile(
+	`^\s*([0-9,.]+)\s+(\d+)%\s+([0-9.]+)([kMGT]?B)/s\s+\S+(?:\s+\(xfr#(\d+), (?:ir|to)-chk=(\d+)/(\d+)\))?`)
+
+// rsyncTotalsRegexp matches the totals of files printed by rsync
+// --stats.
+var rsyncTotalsRegexp = regexp.MustCompile(
+	`(?m)^(Total transferred file size|Number of (?:regular )?files transferred): ([0-9,.]+)`)
+
+// rsyncRateUnits are the multipliers of the units of transfer rates.
+var rsyncRateUnits = map[string]float64{
+	"B":  1,
+	"kB": 1 << 10,
+	"MB": 1 << 20,
+	"GB": 1 << 30,
+	"TB": 1 << 40,
+}
+
+// parseRsyncNumber parses a number printed by rsync, which may contain
+// thousands separators.
+func parseRsyncNumber(s string) int64 {
+	n, _ := strconv.ParseInt(strings.NewReplacer(",", "", ".", "").Replace(s), 10, 64)
+	return n
+}
+
+// parseRsyncProgress parses a progress line printed by rsync
+// --info=progress2. False is returned for other lines.
+func parseRsyncProgress(line string) (RsyncProgress, bool) {
+	m := rsyncProgressRegexp.FindStringSubmatch(line)
+	if m == nil {
+		return RsyncProgress{}, false
+	}
+	p := RsyncProgress{Bytes: parseRsyncNumber(m[1])}
+	p.Percent, _ = strconv.Atoi(m[2])
+	rate, _ := strconv.ParseFloat(m[3], 64)
+	p.BytesPerSecond = rate * rsyncRateUnits[m[4]]
+	if m[5] != "" {
+		p.FilesDone, _ = strconv.Atoi(m[5])
+		p.FilesToCheck, _ = strconv.Atoi(m[6])
+		p.FilesTotal, _ = strconv.Atoi(m[7])
+	}
+
+	return p, true
+}
+
+// rsyncDone returns the last report of a transfer according to output,
+// the output of rsync --stats.
+func rsyncDone(output string) RsyncProgress {
+	p := RsyncProgress{Percent: 100, Done: true}
+	for _, m := range rsyncTotalsRegexp.FindAllStringSubmatch(output, -1) {
+		if m[1] == "Total transferred file size" {
+			p.Bytes = parseRsyncNumber(m[2])
+		} else {
+			p.FilesDone = int(parseRsyncNumber(m[2]))
+		}
+	}
+	p.BytesSent, p.BytesReceived = parseRsyncStats(output)
+
+	return p
+}
+
+// rsyncProgressWriter passes the progress lines written to it, which
+// rsync terminates with carriage returns, to fn.
+type rsyncProgressWriter struct {
+	fn  func(RsyncProgress)
+	buf []byte
+}
+
+func (w *rsyncProgressWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexAny(w.buf, "\r\n")
+		if i < 0 {
+			break
+		}
+		if progress, ok := parseRsyncProgress(string(w.buf[:i])); ok {
+			w.fn(progress)
+		}
+		w.buf = w.buf[i+1:]
+	}
+
+	return len(p), nil
+}
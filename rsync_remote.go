@@ -0,0 +1,169 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// isRemoteRsyncPath returns true if p, a src or dest of Rsync(), is a
+// path on the remote host, i.e., it is prefixed with a colon.
+func isRemoteRsyncPath(p string) bool {
+	return strings.HasPrefix(p, ":")
+}
+
+// remoteRsync copies src to dest, one of which is a path on the remote
+// host prefixed with a colon, by running rsync on the controller.
+// The command is attributed to the remote host. Hosts authenticated
+// with a password fall back to Upload() and Download(), which do not
+// support opts.
+func (r *LogRun) remoteRsync(remote *remoteRunner, src string, dest string, opts RsyncOptions) error {
+	if isRemoteRsyncPath(src) && isRemoteRsyncPath(dest) {
+		return fmt.Errorf("rsync command failed: %s and %s are both on the remote host", src, dest)
+	}
+	if remote.credentials.Password != "" {
+		if !opts.isZero() {
+			return fmt.Errorf("rsync options are not supported when copying with a password")
+		}
+		return r.passwordCopy(src, dest)
+	}
+	if !r.Dryrun {
+		if err := remote.verifyHostKey(); err != nil {
+			return fmt.Errorf("rsync command failed: %w", err)
+		}
+	}
+	runner := newLocalRunner(LocalConfig{})
+	runner.host = remote
+	local := *r
+	local.SetRunner(runner)
+
+	return local.rsync(
+		rsyncRshOptions(RsyncCmdOptions, remote.rsh()),
+		remote.rsyncPath(src),
+		remote.rsyncPath(dest),
+		opts)
+}
+
+// passwordCopy copies the file src to dest, one of which is a path on
+// the remote host prefixed with a colon, using Upload() or Download().
+// Unlike rsync, they neither copy directories nor copy into the
+// directory dest, so both are rejected.
+func (r *LogRun) passwordCopy(src string, dest string) error {
+	const unsupported = "rsync command failed: only single files can be copied with a password"
+	if strings.HasSuffix(dest, "/") {
+		return fmt.Errorf("%s: can not copy into directory %s", unsupported, dest)
+	}
+	if isRemoteRsyncPath(dest) {
+		if fi, err := os.Stat(src); err == nil && fi.IsDir() {
+			return fmt.Errorf("%s: %s is a directory", unsupported, src)
+		}
+		return r.Upload(src, dest[1:])
+	}
+	if _, err := r.FileExists(src[1:]); err != nil {
+		return fmt.Errorf("%s: %w", unsupported, err)
+	}
+
+	return r.Download(src[1:], dest)
+}
+
+// verifyHostKey connects to the remote host if its host key policy is
+// HostKeyTrustOnFirstUse so the key is checked, and recorded if the
+// host is new, before ssh is run with StrictHostKeyChecking=yes. ssh
+// is not given StrictHostKeyChecking=accept-new, which requires
+// OpenSSH 7.6 or later, since RHEL/CentOS 7 ships OpenSSH 7.4.
+func (r *remoteRunner) verifyHostKey() error {
+	if r.hostKeys == nil || r.hostKeys.policy != HostKeyTrustOnFirstUse {
+		return nil
+	}
+	c, _, err := r.conns.acquire(context.Background())
+	if err != nil {
+		return err
+	}
+	r.conns.release(c, false)
+
+	return nil
+}
+
+// rsyncPath returns p, a src or dest of Rsync(), with a colon prefix
+// replaced by the hostname of the remote host.
+func (r *remoteRunner) rsyncPath(p string) string {
+	if !isRemoteRsyncPath(p) {
+		return p
+	}
+	host := r.hostname
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+
+	return host + p
+}
+
+// rsh returns the ssh command line used by rsync to connect to the
+// remote host using the credentials, jump hosts, and host key policy
+// of r. Jump hosts authenticate as configured for ssh, e.g., using
+// ssh-agent. Host keys are checked against the known_hosts file of r,
+// to which verifyHostKey() adds new hosts for HostKeyTrustOnFirstUse.
+func (r *remoteRunner) rsh() string {
+	args := []string{
+		"ssh", "-q",
+		"-p", strconv.Itoa(r.credentials.Port),
+		"-l", r.credentials.Username,
+	}
+	if r.credentials.PrivateKeyFilename != "" {
+		args = append(args, "-i", r.credentials.PrivateKeyFilename)
+	}
+	if len(r.jumps) > 0 {
+		hops := make([]string, 0, len(r.jumps))
+		for _, jump := range r.jumps {
+			hops = append(hops, jump.credentials.Username+"@"+
+				net.JoinHostPort(jump.hostname, strconv.Itoa(jump.credentials.Port)))
+		}
+		args = append(args, "-J", strings.Join(hops, ","))
+	}
+	switch {
+	case r.hostKeys == nil:
+		args = append(args,
+			"-o", "StrictHostKeyChecking=no",
+			"-o", "UserKnownHostsFile=/dev/null",
+			"-o", "GlobalKnownHostsFile=/dev/null")
+	default:
+		args = append(args,
+			"-o", "StrictHostKeyChecking=yes",
+			"-o", "UserKnownHostsFile="+r.hostKeys.filename)
+	}
+
+	return shellCommandLine(args[0], args[1:])
+}
+
+// rsyncRshOptions returns options with the remote shell set to rsh,
+// replacing the --rsh or -e option, if any.
+func rsyncRshOptions(options []string, rsh string) []string {
+	opts := make([]string, 0, len(options)+2)
+	found := false
+	for i := 0; i < len(options); i++ {
+		opt := options[i]
+		switch {
+		case (opt == "--rsh" || opt == "-e") && i+1 < len(options):
+			opts = append(opts, opt, rsh)
+			i++
+			found = true
+		case strings.HasPrefix(opt, "--rsh="):
+			opts = append(opts, "--rsh="+rsh)
+			found = true
+		default:
+			opts = append(opts, opt)
+		}
+	}
+	if !found {
+		opts = append([]string{"--rsh", rsh}, opts...)
+	}
+
+	return opts
+}
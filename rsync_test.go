@@ -0,0 +1,361 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/apatters/go-logrun"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+)
+
+type rsyncErrorTestEntry struct {
+	Description     string
+	Code            int
+	ExpectPartial   bool
+	ExpectVanished  bool
+	ExpectTimeout   bool
+	ExpectedMessage string
+}
+
+var rsyncErrorTestTable = []rsyncErrorTestEntry{
+	{"Syntax error", logrun.RsyncExitSyntax, false, false, false, "rsync command failed (syntax or usage error): oops"},
+	{"Partial transfer", logrun.RsyncExitPartialTransfer, true, false, false, "rsync command failed (partial transfer due to error): oops"},
+	{"Vanished files", logrun.RsyncExitVanished, true, true, false, "rsync command failed (partial transfer due to vanished source files): oops"},
+	{"Timeout", logrun.RsyncExitTimeout, false, false, true, "rsync command failed (timeout in data send/receive): oops"},
+	{"Unknown code", 99, false, false, false, "rsync command failed (exit code 99): oops"},
+}
+
+// fakeRsync replaces logrun.RsyncCmd with a script that writes to
+// stderr and exits with code. The returned function restores the
+// original command.
+func fakeRsync(t *testing.T, code int) func() {
+	dir, err := ioutil.TempDir("", "go-logrun-")
+	require.NoError(t, err)
+	script := filepath.Join(dir, "rsync")
+	err = ioutil.WriteFile(
+		script,
+		[]byte(fmt.Sprintf("#!/bin/sh\necho oops >&2\nexit %d\n", code)),
+		0755)
+	require.NoError(t, err)
+	orig := logrun.RsyncCmd
+	logrun.RsyncCmd = script
+
+	return func() {
+		logrun.RsyncCmd = orig
+		os.RemoveAll(dir)
+	}
+}
+
+func TestRsyncError(t *testing.T) {
+	for _, e := range rsyncErrorTestTable {
+		t.Log(e.Description)
+		restore := fakeRsync(t, e.Code)
+		l := logrun.NewLocalLogRun(logrun.LocalConfig{})
+		err := l.Rsync("/src/", "/dest/")
+		restore()
+		t.Logf("err = %v", err)
+		require.Error(t, err)
+
+		rsyncErr, ok := err.(*logrun.RsyncError)
+		require.True(t, ok)
+		assert.Equal(t, e.Code, rsyncErr.Code)
+		assert.Equal(t, "oops\n", rsyncErr.Stderr)
+		assert.Equal(t, []string{"oops"}, rsyncErr.Lines.Errors())
+		assert.Equal(t, e.ExpectPartial, rsyncErr.Partial())
+		assert.Equal(t, e.ExpectVanished, rsyncErr.Vanished())
+		assert.Equal(t, e.ExpectTimeout, rsyncErr.Timeout())
+		assert.Equal(t, e.ExpectedMessage, err.Error())
+	}
+}
+
+func TestRsyncError_Execute(t *testing.T) {
+	orig := logrun.RsyncCmd
+	logrun.RsyncCmd = "/bin/xyzzy"
+	defer func() { logrun.RsyncCmd = orig }()
+
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{})
+	err := l.Rsync("/src/", "/dest/")
+	t.Logf("err = %v", err)
+	require.Error(t, err)
+	_, ok := err.(*logrun.RsyncError)
+	assert.False(t, ok)
+}
+
+func TestRsyncError_Dryrun(t *testing.T) {
+	log, out, errOut := newLogger()
+	restore := fakeRsync(t, logrun.RsyncExitPartialTransfer)
+	defer restore()
+
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{
+		LogFunc: log.Println,
+		Dryrun:  true,
+	})
+	err := l.Rsync("/src/", "/dest/")
+	t.Logf("err = %v", err)
+	t.Logf("out = %q", out)
+	t.Logf("errOut = %q", errOut)
+	assert.NoError(t, err)
+	assert.Contains(t, out.String(), "/src/ /dest/\n")
+	assert.Empty(t, errOut.String())
+}
+
+// fakeRsyncArgs replaces logrun.RsyncCmd with a script that writes its
+// arguments, one per line, to a file. The returned function returns
+// the arguments of the last run.
+func fakeRsyncArgs(t *testing.T) (func() []string, func()) {
+	dir, err := ioutil.TempDir("", "go-logrun-")
+	require.NoError(t, err)
+	script := filepath.Join(dir, "rsync")
+	argsFile := filepath.Join(dir, "args")
+	err = ioutil.WriteFile(
+		script,
+		[]byte(fmt.Sprintf("#!/bin/sh\nprintf '%%s\\n' \"$@\" > %s\n", argsFile)),
+		0755)
+	require.NoError(t, err)
+	orig := logrun.RsyncCmd
+	logrun.RsyncCmd = script
+
+	args := func() []string {
+		data, err := ioutil.ReadFile(argsFile)
+		require.NoError(t, err)
+		return strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	}
+	return args, func() {
+		logrun.RsyncCmd = orig
+		os.RemoveAll(dir)
+	}
+}
+
+func TestRemoteLogRun_RsyncCredentials(t *testing.T) {
+	args, restore := fakeRsyncArgs(t)
+	defer restore()
+	log, out, _ := newLogger()
+	r, err := logrun.NewRemoteLogRun(logrun.RemoteConfig{
+		LogFunc: log.Println,
+		Credentials: logrun.Credentials{
+			Hostname:           "web1",
+			Port:               2222,
+			Username:           "deploy",
+			PrivateKeyFilename: "/keys/id rsa",
+		},
+		JumpHosts: []logrun.Credentials{
+			{Hostname: "bastion", Username: "jump", Password: "secret"},
+		},
+		HostKeyPolicy:  logrun.HostKeyStrict,
+		KnownHostsFile: "/keys/known_hosts",
+	})
+	require.NoError(t, err)
+
+	// rsync runs on the controller, so no connection is needed.
+	require.NoError(t, r.Rsync("/src/", ":/dest/"))
+	t.Logf("out = %q", out)
+	rsh := "ssh -q -p 2222 -l deploy -i '/keys/id rsa' -J jump@bastion:22 " +
+		"-o StrictHostKeyChecking=yes -o UserKnownHostsFile=/keys/known_hosts"
+	assert.Equal(t, []string{"--rsh", rsh, "--recursive", "--links", "--times", "/src/", "web1:/dest/"}, args())
+
+	require.NoError(t, r.Rsync(":/src/", "/dest/"))
+	assert.Equal(t, []string{"--rsh", rsh, "--recursive", "--links", "--times", "web1:/src/", "/dest/"}, args())
+
+	err = r.Rsync(":/src/", ":/dest/")
+	t.Logf("err = %v", err)
+	assert.Error(t, err)
+
+	// The command and transfer are attributed to the remote host.
+	f, err := os.OpenFile(logrun.RsyncCmd, os.O_WRONLY|os.O_APPEND, 0)
+	require.NoError(t, err)
+	_, err = f.WriteString("echo 'Total bytes sent: 10'\n")
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	r.SetRecorder(logrun.NewRecorder())
+	require.NoError(t, r.Rsync("/src/", ":/dest/"))
+	commands := r.Recorder().Commands()
+	require.Len(t, commands, 1)
+	assert.Equal(t, "deploy@web1:2222", commands[0].Host)
+	transfers := r.Recorder().Transfers()
+	require.Len(t, transfers, 1)
+	assert.Equal(t, "deploy@web1:2222", transfers[0].Host)
+}
+
+func TestRemoteLogRun_RsyncTrustOnFirstUse(t *testing.T) {
+	args, restore := fakeRsyncArgs(t)
+	defer restore()
+	dir, err := ioutil.TempDir("", "go-logrun-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	keyFile := writeTestSSHKey(t, dir)
+	s := newTestSSHServer(t, func(config *ssh.ServerConfig) {
+		config.PublicKeyCallback = func(ssh.ConnMetadata, ssh.PublicKey) (*ssh.Permissions, error) {
+			return nil, nil
+		}
+	})
+	defer s.Close()
+	knownHosts := filepath.Join(dir, "known_hosts")
+	r, err := logrun.NewRemoteLogRun(logrun.RemoteConfig{
+		Credentials: logrun.Credentials{
+			Hostname:           "127.0.0.1",
+			Port:               s.Port(),
+			Username:           "deploy",
+			PrivateKeyFilename: keyFile,
+		},
+		HostKeyPolicy:  logrun.HostKeyTrustOnFirstUse,
+		KnownHostsFile: knownHosts,
+	})
+	require.NoError(t, err)
+
+	// The host key is recorded before ssh is run, so ssh can check
+	// it strictly without support for accept-new.
+	require.NoError(t, r.Rsync("/src/", ":/dest/"))
+	data, err := ioutil.ReadFile(knownHosts)
+	require.NoError(t, err)
+	assert.Equal(t, knownHostsEntry(s, s.HostKey()), string(data))
+	rsh := fmt.Sprintf("ssh -q -p %d -l deploy -i %s -o StrictHostKeyChecking=yes -o UserKnownHostsFile=%s",
+		s.Port(), keyFile, knownHosts)
+	assert.Equal(t, []string{"--rsh", rsh, "--recursive", "--links", "--times", "/src/", "127.0.0.1:/dest/"}, args())
+}
+
+func TestRemoteLogRun_RsyncPassword(t *testing.T) {
+	s := newTestSSHServer(t, nil)
+	defer s.Close()
+	dir, err := ioutil.TempDir("", "go-logrun-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	src := filepath.Join(dir, "app.conf")
+	require.NoError(t, ioutil.WriteFile(src, []byte("port=80\n"), 0644))
+	r, err := logrun.NewRemoteLogRun(logrun.RemoteConfig{Credentials: s.Credentials()})
+	require.NoError(t, err)
+
+	// ssh can not be given the password, so the file is uploaded
+	// and downloaded on the existing connection.
+	remotePath := filepath.Join(dir, "uploaded.conf")
+	require.NoError(t, r.Rsync(src, ":"+remotePath))
+	data, err := ioutil.ReadFile(remotePath)
+	require.NoError(t, err)
+	assert.Equal(t, "port=80\n", string(data))
+
+	dest := filepath.Join(dir, "downloaded.conf")
+	require.NoError(t, r.Rsync(":"+remotePath, dest))
+	data, err = ioutil.ReadFile(dest)
+	require.NoError(t, err)
+	assert.Equal(t, "port=80\n", string(data))
+
+	// Directories are not copied recursively and files are not
+	// copied into directories.
+	for _, paths := range [][2]string{
+		{dir, ":" + filepath.Join(dir, "copy")},
+		{dir + "/", ":" + filepath.Join(dir, "copy")},
+		{src, ":" + dir + "/"},
+		{":" + dir, filepath.Join(dir, "copy")},
+		{":" + dir + "/", filepath.Join(dir, "copy")},
+		{":" + remotePath, dir + "/"},
+	} {
+		err = r.Rsync(paths[0], paths[1])
+		t.Logf("err = %v", err)
+		assert.Error(t, err, "%s to %s", paths[0], paths[1])
+		assert.Contains(t, err.Error(), "only single files can be copied with a password")
+	}
+	_, err = os.Stat(filepath.Join(dir, "copy"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestLocalLogRun_RsyncWithOptions(t *testing.T) {
+	args, restore := fakeRsyncArgs(t)
+	defer restore()
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{})
+
+	err := l.RsyncWithOptions("/src/", "/dest/", logrun.RsyncOptions{
+		Delete:         true,
+		Includes:       []string{"keep.log"},
+		Excludes:       []string{"*.log", "tmp/"},
+		Checksum:       true,
+		BandwidthLimit: 1024,
+		DryRun:         true,
+		ExtraArgs:      []string{"--compress"},
+	})
+	require.NoError(t, err)
+	got := args()
+	t.Logf("args = %q", got)
+	assert.Equal(t, []string{
+		"--delete",
+		"--include=keep.log",
+		"--exclude=*.log",
+		"--exclude=tmp/",
+		"--checksum",
+		"--bwlimit=1024",
+		"--dry-run",
+		"--compress",
+		"/src/",
+		"/dest/",
+	}, got[len(logrun.RsyncCmdOptions):])
+
+	// The options only apply to the call, which records the
+	// transfer.
+	require.NoError(t, l.Rsync("/src/", "/dest/"))
+	assert.Equal(t, []string{"/src/", "/dest/"}, args()[len(logrun.RsyncCmdOptions):])
+}
+
+func TestLocalLogRun_RsyncProgress(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-logrun-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	script := filepath.Join(dir, "rsync")
+	err = ioutil.WriteFile(script, []byte(`#!/bin/sh
+printf '          32,768   1%%    1.50MB/s    0:00:20\r'
+printf '       1,238,099  44%%  118.07MB/s    0:00:00 (xfr#1, to-chk=5/7)\r'
+printf '       2,813,952 100%%   12.00kB/s    0:00:01 (xfr#3, ir-chk=0/7)\n'
+printf '\nNumber of files: 7 (reg: 3, dir: 4)\n'
+printf 'Number of regular files transferred: 3\n'
+printf 'Total transferred file size: 2,813,952 bytes\n'
+printf 'Total bytes sent: 2,815,000\n'
+printf 'Total bytes received: 92\n'
+`), 0755)
+	require.NoError(t, err)
+	orig := logrun.RsyncCmd
+	logrun.RsyncCmd = script
+	defer func() { logrun.RsyncCmd = orig }()
+
+	log, out, _ := newLogger()
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{LogFunc: log.Println})
+	var reports []logrun.RsyncProgress
+	err = l.RsyncWithOptions("/src/", "/dest/", logrun.RsyncOptions{
+		Progress: func(p logrun.RsyncProgress) {
+			reports = append(reports, p)
+		},
+	})
+	require.NoError(t, err)
+	t.Logf("out = %q", out)
+	for _, p := range reports {
+		t.Logf("report = %+v", p)
+	}
+	assert.Contains(t, out.String(), " --stats --info=progress2 /src/ /dest/\n")
+	assert.Equal(t, []logrun.RsyncProgress{
+		{Bytes: 32768, Percent: 1, BytesPerSecond: 1.5 * (1 << 20)},
+		{Bytes: 1238099, Percent: 44, BytesPerSecond: 118.07 * (1 << 20), FilesDone: 1, FilesToCheck: 5, FilesTotal: 7},
+		{Bytes: 2813952, Percent: 100, BytesPerSecond: 12 * (1 << 10), FilesDone: 3, FilesTotal: 7},
+		{Bytes: 2813952, Percent: 100, FilesDone: 3, Done: true, BytesSent: 2815000, BytesReceived: 92},
+	}, reports)
+
+	// DryRun reports the totals rsync would transfer without
+	// recording a transfer.
+	l.SetRecorder(logrun.NewRecorder())
+	reports = nil
+	err = l.RsyncWithOptions("/src/", "/dest/", logrun.RsyncOptions{
+		DryRun: true,
+		Progress: func(p logrun.RsyncProgress) {
+			reports = append(reports, p)
+		},
+	})
+	require.NoError(t, err)
+	require.NotEmpty(t, reports)
+	assert.True(t, reports[len(reports)-1].Done)
+	assert.Empty(t, l.Recorder().Transfers())
+}
@@ -0,0 +1,106 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun
+
+import (
+	"strings"
+)
+
+var (
+	// RunAsCmd is the external command used to run commands on
+	// remote hosts as the user selected by RunAs or WithRunAs().
+	// The user and the command line follow RunAsCmdOptions. This
+	// command (and options) has been tested on RHEL/CentOS 7 and
+	// Ubuntu 18.04.
+	RunAsCmd = "sudo"
+
+	// RunAsCmdOptions are the options passed to RunAsCmd. The
+	// -n option makes sudo fail instead of prompting for a
+	// password.
+	RunAsCmdOptions = []string{"-n", "-u"}
+)
+
+// SudoOptions control how RunAsCmd, i.e., sudo, sets up the
+// environment of commands run as another user on remote hosts. By
+// default, sudo resets the environment according to its security
+// policy, which drops, e.g., proxy and locale settings.
+type SudoOptions struct {
+	// PreserveEnv keeps the environment of the SSH session, like
+	// sudo -E. The security policy may still remove variables.
+	PreserveEnv bool
+
+	// Login runs commands using the login shell of the user, like
+	// sudo -i, so the profile of the user is read.
+	Login bool
+
+	// PassEnv are the names of the variables kept from the
+	// environment of the SSH session, e.g., "http_proxy" or
+	// "LANG", like sudo --preserve-env=http_proxy,LANG. This
+	// requires sudo 1.8.21 or later.
+	PassEnv []string
+}
+
+// args returns the options added to RunAsCmd for o.
+func (o SudoOptions) args() []string {
+	var args []string
+	if o.PreserveEnv {
+		args = append(args, "-E")
+	}
+	if o.Login {
+		args = append(args, "-i")
+	}
+	if len(o.PassEnv) > 0 {
+		args = append(args, shellQuote("--preserve-env="+strings.Join(o.PassEnv, ",")))
+	}
+
+	return args
+}
+
+// WithRunAs runs commands as user instead of the user the LogRun
+// runs commands as, overriding the RunAs setting of the LogRun. See
+// LocalConfig.RunAs and RemoteConfig.RunAs.
+func WithRunAs(user string) CallOption {
+	return func(o *callOptions) {
+		o.runAs = user
+	}
+}
+
+// runAs returns the user commands run by r run as, or the empty
+// string if they run as the user the LogRun runs commands as.
+func (r *LogRun) runAs() string {
+	if r.call.runAs != "" {
+		return r.call.runAs
+	}
+	switch runner := r.Runner.(type) {
+	case *localRunner:
+		return runner.runAs
+	case *remoteRunner:
+		return runner.runAs
+	case *dockerRunner:
+		return runner.runAs
+	}
+
+	return ""
+}
+
+// runAsUser returns the user the command described by spec runs as,
+// falling back to the RunAs user of the runner.
+func runAsUser(spec *execSpec, runAs string) string {
+	if spec.runAs != "" {
+		return spec.runAs
+	}
+
+	return runAs
+}
+
+// runAsCommandLine returns cmdLine run as user using RunAsCmd with the
+// options selected by sudo.
+func runAsCommandLine(user string, cmdLine string, sudo SudoOptions) string {
+	args := append([]string{RunAsCmd}, sudo.args()...)
+	args = append(args, RunAsCmdOptions...)
+	args = append(args, shellQuote(user), "--", cmdLine)
+
+	return strings.Join(args, " ")
+}
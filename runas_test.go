@@ -0,0 +1,140 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun_test
+
+import (
+	"io/ioutil"
+	"os"
+	"os/user"
+	"testing"
+
+	"github.com/apatters/go-logrun"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalLogRun_RunAs(t *testing.T) {
+	u, err := user.Current()
+	require.NoError(t, err)
+	log, out, _ := newLogger()
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{
+		LogFunc: log.Println,
+		RunAs:   u.Username,
+	})
+	stdout, stderr, code := l.Run("/bin/echo", "hello")
+	t.Logf("stdout = %q", stdout)
+	t.Logf("stderr = %q", stderr)
+	t.Logf("out = %q", out)
+	require.Zero(t, code)
+	assert.Equal(t, "hello\n", stdout)
+	assert.Equal(t, "(as "+u.Username+") /bin/echo hello\n", out.String())
+	assert.Equal(t, "(as "+u.Username+") /bin/echo hello", l.Runner.FormatRun("/bin/echo", "hello"))
+	assert.Equal(t, u.Username, l.Config().RunAs)
+
+	// File operations are run as commands.
+	out.Reset()
+	exists, err := l.FileExists("/etc/hosts")
+	t.Logf("out = %q", out)
+	require.NoError(t, err)
+	assert.True(t, exists)
+	assert.Contains(t, out.String(), "(as "+u.Username+") "+logrun.FileExistsCmd)
+
+	_, stderr, code = l.With(logrun.WithRunAs("no-such-user")).Run("/bin/true")
+	t.Logf("stderr = %q", stderr)
+	assert.Equal(t, logrun.ExitErrorExecute, code)
+	assert.Contains(t, stderr, "no-such-user")
+}
+
+func TestLocalLogRun_WithRunAs(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("running commands as another user requires root")
+	}
+	nobody, err := user.Lookup("nobody")
+	if err != nil {
+		t.Skip("there is no nobody user")
+	}
+	log, out, _ := newLogger()
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{LogFunc: log.Println})
+	stdout, stderr, code := l.With(logrun.WithRunAs("nobody")).Shell("id -u")
+	t.Logf("stdout = %q", stdout)
+	t.Logf("stderr = %q", stderr)
+	t.Logf("out = %q", out)
+	require.Zero(t, code)
+	assert.Equal(t, nobody.Uid+"\n", stdout)
+	assert.Equal(t, "(as nobody) /bin/sh -c \"id -u\"\n", out.String())
+}
+
+func TestRemoteLogRun_RunAs(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "logrun")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmpDir)
+	origCmd := logrun.RunAsCmd
+	defer func() { logrun.RunAsCmd = origCmd }()
+	logrun.RunAsCmd = fakeCommand(t, tmpDir, "sudo", "echo \"sudo $*\" >&2\nshift 4\nexec \"$@\"\n")
+
+	s := newTestSSHServer(t, nil)
+	defer s.Close()
+	log, out, _ := newLogger()
+	l, err := logrun.NewRemoteLogRun(logrun.RemoteConfig{
+		LogFunc:     log.Println,
+		Credentials: s.Credentials(),
+		RunAs:       "postgres",
+		UseSFTP:     true,
+	})
+	require.NoError(t, err)
+	defer l.Close() // nolint: errcheck
+
+	stdout, stderr, code := l.Shell("echo hello")
+	t.Logf("stdout = %q", stdout)
+	t.Logf("stderr = %q", stderr)
+	t.Logf("out = %q", out)
+	require.Zero(t, code)
+	assert.Equal(t, "hello\n", stdout)
+	assert.Equal(t, "sudo -n -u postgres -- /bin/sh -c echo hello\n", stderr)
+	assert.Contains(t, out.String(), logrun.RunAsCmd+" -n -u 'postgres' -- /bin/sh -c \"echo hello\"\n")
+
+	// SFTP is not used since it runs as the login user.
+	out.Reset()
+	exists, err := l.With(logrun.WithRunAs("root")).DirExists("/")
+	t.Logf("out = %q", out)
+	require.NoError(t, err)
+	assert.True(t, exists)
+	assert.Contains(t, out.String(), " -n -u 'root' -- "+logrun.DirExistsCmd)
+}
+
+func TestRemoteLogRun_RunAsSudoOptions(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "logrun")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmpDir)
+	origCmd := logrun.RunAsCmd
+	defer func() { logrun.RunAsCmd = origCmd }()
+	logrun.RunAsCmd = fakeCommand(t, tmpDir, "sudo",
+		"echo \"sudo $*\" >&2\nwhile [ \"$1\" != -- ]; do shift; done\nshift\nexec \"$@\"\n")
+
+	s := newTestSSHServer(t, nil)
+	defer s.Close()
+	log, out, _ := newLogger()
+	l, err := logrun.NewRemoteLogRun(logrun.RemoteConfig{
+		LogFunc:     log.Println,
+		Credentials: s.Credentials(),
+		RunAs:       "postgres",
+		Sudo: logrun.SudoOptions{
+			PreserveEnv: true,
+			Login:       true,
+			PassEnv:     []string{"http_proxy", "LANG"},
+		},
+	})
+	require.NoError(t, err)
+	defer l.Close() // nolint: errcheck
+
+	stdout, stderr, code := l.Shell("echo hello")
+	t.Logf("stdout = %q", stdout)
+	t.Logf("stderr = %q", stderr)
+	t.Logf("out = %q", out)
+	require.Zero(t, code)
+	assert.Equal(t, "hello\n", stdout)
+	assert.Equal(t, "sudo -E -i --preserve-env=http_proxy,LANG -n -u postgres -- /bin/sh -c echo hello\n", stderr)
+	assert.Contains(t, out.String(), logrun.RunAsCmd+" -E -i ")
+}
@@ -0,0 +1,110 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun
+
+import (
+	"time"
+)
+
+// DefaultShellExecutable is the shell used to run shell commands
+// when one is not specified in the LocalConfig or RemoteConfig
+// objects. Local runners on Windows use cmd.exe instead.
+var DefaultShellExecutable = "/bin/sh"
+
+// Runner is the interface of the backends that run the commands of a
+// LogRun. It has the same methods as the Runner interface of the
+// github.com/apatters/go-run package, so its runners can be used as
+// well. The runners created by NewLocalLogRun() and NewRemoteLogRun()
+// also implement an internal interface that adds cancellation,
+// per-command I/O, working directories, and process tracking.
+type Runner interface {
+	// Run runs a command like glibc's exec() call. It returns the
+	// standard out, standard error, and exit code of the command
+	// when it completes.
+	Run(cmd string, args ...string) (string, string, int, error)
+
+	// FormatRun returns a string representation of the command
+	// that would be run using Run(). Useful for logging commands.
+	FormatRun(cmd string, args ...string) string
+
+	// Shell runs a command in a shell. The command is passed to
+	// the shell as the -c option. It returns the standard out,
+	// standard error, and exit code of the command when it
+	// completes.
+	Shell(cmd string) (string, string, int, error)
+
+	// FormatShell returns a string representation of the command
+	// that would be run using Shell(). Useful for logging
+	// commands.
+	FormatShell(cmd string) string
+}
+
+// LogRunConfig is used to set options in the NewLogRun constructor.
+// The fields have the same meaning as in LocalConfig and
+// RemoteConfig.
+type LogRunConfig struct {
+	LogFunc          LogFunc
+	LogHook          LogHook
+	ResultStore      ResultStore
+	ResultLogFunc    LogFunc
+	Verbosity        Verbosity
+	Dryrun           bool
+	OutputEncoding   OutputEncoding
+	NormalizeCRLF    bool
+	Timeout          time.Duration
+	Clock            Clock
+	StderrClassifier *StderrClassifier
+	Vars             map[string]string
+	Tags             map[string]string
+}
+
+// NewLogRun returns a LogRun that logs commands and runs them using
+// runner, so applications can provide their own Runner, e.g., one
+// that records metrics, injects failures, or caches results, while
+// keeping the logging, dryrun, and check mode of the LogRun. The
+// Runner can be replaced later using SetRunner().
+//
+// Only the runners used by NewLocalLogRun() and NewRemoteLogRun()
+// support per-command I/O, working directories, process tracking,
+// cancellation, and timeouts. Other runners return an error for
+// commands that need them.
+func NewLogRun(runner Runner, config LogRunConfig) *LogRun {
+	r := new(LogRun)
+	r.Runner = runner
+	if config.LogFunc == nil {
+		r.logFunc = DefaultLogFunc
+	} else {
+		r.logFunc = config.LogFunc
+	}
+	r.logHook = config.LogHook
+	r.resultStore = config.ResultStore
+	r.resultLogFunc = config.ResultLogFunc
+	r.verbosity = config.Verbosity
+	r.Dryrun = config.Dryrun
+	r.outputEncoding = config.OutputEncoding
+	r.normalizeCRLF = config.NormalizeCRLF
+	r.timeout = config.Timeout
+	if config.Clock == nil {
+		r.clock = RealClock{}
+	} else {
+		r.clock = config.Clock
+	}
+	r.stderrClassifier = config.StderrClassifier
+	r.caps = new(capsCache)
+	r.shutdown = newShutdownState()
+	r.errCtx = new(errorContextState)
+	r.SetVars(config.Vars)
+	r.SetTags(config.Tags)
+
+	return r
+}
+
+// SetRunner replaces the Runner used to run commands, e.g., to wrap
+// the current Runner, which is returned by the Runner field, in an
+// instrumented one. Cached capabilities of the host are discarded.
+func (r *LogRun) SetRunner(runner Runner) {
+	r.Runner = runner
+	r.caps = new(capsCache)
+}
@@ -0,0 +1,63 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun_test
+
+import (
+	"testing"
+
+	"github.com/apatters/go-logrun"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingRunner wraps a Runner and counts the commands it runs.
+type countingRunner struct {
+	logrun.Runner
+	count int
+}
+
+func (c *countingRunner) Run(cmd string, args ...string) (string, string, int, error) {
+	c.count++
+	return c.Runner.Run(cmd, args...)
+}
+
+func (c *countingRunner) Shell(cmd string) (string, string, int, error) {
+	c.count++
+	return c.Runner.Shell(cmd)
+}
+
+func TestNewLogRun(t *testing.T) {
+	log, out, _ := newLogger()
+	runner := &countingRunner{Runner: logrun.NewLocalLogRun(logrun.LocalConfig{}).Runner}
+	l := logrun.NewLogRun(runner, logrun.LogRunConfig{
+		LogFunc: log.Println,
+	})
+	stdout, _, code := l.Run("/bin/echo", "hello")
+	t.Logf("out = %q", out)
+	require.Zero(t, code)
+	assert.Equal(t, "hello\n", stdout)
+	assert.Equal(t, "/bin/echo hello\n", out.String())
+	assert.Equal(t, 1, runner.count)
+
+	l.SetDryrun(true)
+	l.Shell("exit 1")
+	assert.Equal(t, 1, runner.count)
+
+	// Features that need the built-in runners are reported.
+	l.SetDryrun(false)
+	_, stderr, code := l.With(logrun.WithPIDFunc(func(int) {})).Run("/bin/true")
+	t.Logf("stderr = %q", stderr)
+	assert.Equal(t, logrun.ExitErrorExecute, code)
+	assert.Contains(t, stderr, "does not support process tracking")
+}
+
+func TestLogRun_SetRunner(t *testing.T) {
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{})
+	runner := &countingRunner{Runner: l.Runner}
+	l.SetRunner(runner)
+	_, _, code := l.Shell("true")
+	require.Zero(t, code)
+	assert.Equal(t, 1, runner.count)
+}
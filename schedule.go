@@ -0,0 +1,377 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Schedule decides when a Scheduler runs its job.
+type Schedule interface {
+	// Next returns the first time after t the job is due.
+	Next(t time.Time) time.Time
+}
+
+type everySchedule time.Duration
+
+// Every returns a Schedule that runs a job every d, starting d after
+// the Scheduler is started.
+func Every(d time.Duration) Schedule {
+	return everySchedule(d)
+}
+
+func (e everySchedule) Next(t time.Time) time.Time {
+	return t.Add(time.Duration(e))
+}
+
+// cronSchedule is a parsed cron expression. Each field is a bit set
+// of the allowed values.
+type cronSchedule struct {
+	minute, hour, dom, month, dow uint64
+	domStar, dowStar              bool
+}
+
+// cronFields are the ranges of the fields of a cron expression.
+var cronFields = []struct {
+	name     string
+	min, max int
+}{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 7},
+}
+
+// cronShorthands are the predefined schedules accepted by ParseCron.
+var cronShorthands = map[string]string{
+	"@hourly":  "0 * * * *",
+	"@daily":   "0 0 * * *",
+	"@weekly":  "0 0 * * 0",
+	"@monthly": "0 0 1 * *",
+	"@yearly":  "0 0 1 1 *",
+}
+
+// ParseCron parses a standard five field cron expression, i.e.,
+// minute, hour, day of month, month, and day of week, e.g.,
+// "*/15 2-4 * * 1-5". Fields are lists of values, ranges, and steps.
+// Sunday is 0 or 7. Like cron, a job is due if either the day of the
+// month or the day of the week matches when both are restricted. The
+// shorthands @hourly, @daily, @weekly, @monthly, and @yearly are
+// accepted as well. Times are matched in the location of the time
+// passed to Next().
+func ParseCron(expr string) (Schedule, error) {
+	if s, ok := cronShorthands[strings.TrimSpace(expr)]; ok {
+		expr = s
+	}
+	fields := strings.Fields(expr)
+	if len(fields) != len(cronFields) {
+		return nil, fmt.Errorf("invalid cron expression %q: expected %d fields", expr, len(cronFields))
+	}
+	var sets [5]uint64
+	for i, field := range fields {
+		set, err := parseCronField(field, cronFields[i].min, cronFields[i].max)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cron expression %q: %s: %w", expr, cronFields[i].name, err)
+		}
+		sets[i] = set
+	}
+	s := &cronSchedule{
+		minute:  sets[0],
+		hour:    sets[1],
+		dom:     sets[2],
+		month:   sets[3],
+		dow:     sets[4],
+		domStar: fields[2] == "*",
+		dowStar: fields[4] == "*",
+	}
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+
+	return s, nil
+}
+
+// parseCronField returns the bit set of the values between min and max
+// matched by field.
+func parseCronField(field string, min int, max int) (uint64, error) {
+	var set uint64
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			var err error
+			if step, err = strconv.Atoi(part[i+1:]); err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid step %q", part[i+1:])
+			}
+			part = part[:i]
+		}
+		lo, hi := min, max
+		switch {
+		case part == "*":
+		case strings.Contains(part, "-"):
+			bounds := strings.SplitN(part, "-", 2)
+			var err error
+			if lo, err = strconv.Atoi(bounds[0]); err != nil {
+				return 0, fmt.Errorf("invalid value %q", bounds[0])
+			}
+			if hi, err = strconv.Atoi(bounds[1]); err != nil {
+				return 0, fmt.Errorf("invalid value %q", bounds[1])
+			}
+		default:
+			var err error
+			if lo, err = strconv.Atoi(part); err != nil {
+				return 0, fmt.Errorf("invalid value %q", part)
+			}
+			hi = lo
+			if step > 1 {
+				hi = max
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return 0, fmt.Errorf("%q is out of range %d-%d", part, min, max)
+		}
+		for v := lo; v <= hi; v += step {
+			set |= 1 << uint(v)
+		}
+	}
+
+	return set, nil
+}
+
+// Next returns the first minute after t that matches the schedule.
+// The zero time is returned if there is none within five years, e.g.,
+// for "0 0 30 2 *".
+func (s *cronSchedule) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		switch {
+		case s.month&(1<<uint(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !s.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case s.hour&(1<<uint(t.Hour())) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+		case s.minute&(1<<uint(t.Minute())) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+
+	return time.Time{}
+}
+
+func (s *cronSchedule) dayMatches(t time.Time) bool {
+	dom := s.dom&(1<<uint(t.Day())) != 0
+	dow := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domStar || s.dowStar {
+		return dom && dow
+	}
+
+	return dom || dow
+}
+
+// ScheduledRun is the outcome of a run of the job of a Scheduler.
+type ScheduledRun struct {
+	// Start is when the run was due.
+	Start time.Time
+
+	// Duration is how long the run took.
+	Duration time.Duration
+
+	// Skipped is true if the run was skipped because the previous
+	// run was still in progress.
+	Skipped bool
+
+	// Errs are the errors returned by the job keyed by the host
+	// of the runner, as returned by Host(). Hosts for which the
+	// job succeeded are not included.
+	Errs map[string]error
+
+	// Summary summarizes the commands, operations, and tasks of
+	// the run on all hosts.
+	Summary Summary
+}
+
+// Failed returns true if the job failed on any host.
+func (run ScheduledRun) Failed() bool {
+	return len(run.Errs) > 0
+}
+
+// SchedulerConfig is used to set options in the NewScheduler
+// constructor.
+type SchedulerConfig struct {
+	// Schedule decides when the job runs, e.g., Every(time.Hour)
+	// or the result of ParseCron().
+	Schedule Schedule
+
+	// OnRun, if not nil, is called with the outcome of every run,
+	// including skipped ones, e.g., to log or report Summary.
+	OnRun func(run ScheduledRun)
+
+	// Clock is the source of time. The default is RealClock.
+	Clock Clock
+}
+
+// Scheduler runs a job against a set of runners on a schedule, so
+// small recurring maintenance jobs do not need a separate
+// orchestrator. The job is run on all runners concurrently. A run is
+// skipped if the previous one is still in progress.
+type Scheduler struct {
+	runners  []*LogRun
+	job      func(r *LogRun) error
+	schedule Schedule
+	onRun    func(run ScheduledRun)
+	clock    Clock
+
+	mu      sync.Mutex
+	running bool
+	wg      sync.WaitGroup
+}
+
+// NewScheduler returns a Scheduler that calls job with each of runners
+// when config.Schedule is due, e.g., with the runners of a Pool:
+//
+//	s, err := logrun.NewScheduler(pool.Runners(), func(r *logrun.LogRun) error {
+//		_, stderr, code := r.Run("/usr/bin/find", "/var/tmp", "-mtime", "+7", "-delete")
+//		if code != 0 {
+//			return fmt.Errorf("cleanup failed: %s", stderr)
+//		}
+//		return nil
+//	}, logrun.SchedulerConfig{Schedule: logrun.Every(time.Hour)})
+//
+// Each run records to its own Recorder, so the Summary of every run
+// only includes that run.
+func NewScheduler(runners []*LogRun, job func(r *LogRun) error, config SchedulerConfig) (*Scheduler, error) {
+	if len(runners) == 0 {
+		return nil, errors.New("scheduler has no runners")
+	}
+	if job == nil {
+		return nil, errors.New("scheduler has no job")
+	}
+	if config.Schedule == nil {
+		return nil, errors.New("scheduler has no schedule")
+	}
+	s := &Scheduler{
+		runners:  append([]*LogRun{}, runners...),
+		job:      job,
+		schedule: config.Schedule,
+		onRun:    config.OnRun,
+		clock:    config.Clock,
+	}
+	if s.clock == nil {
+		s.clock = RealClock{}
+	}
+
+	return s, nil
+}
+
+// NewTaskScheduler returns a Scheduler that runs tasks against each of
+// runners when config.Schedule is due.
+func NewTaskScheduler(runners []*LogRun, tasks *TaskList, config SchedulerConfig) (*Scheduler, error) {
+	return NewScheduler(runners, func(r *LogRun) error {
+		return tasks.Run(r).Err()
+	}, config)
+}
+
+// Run runs the job whenever it is due until ctx is done. It then
+// waits for the run in progress, if any, and returns ctx.Err().
+func (s *Scheduler) Run(ctx context.Context) error {
+	defer s.wg.Wait()
+	for {
+		now := s.clock.Now()
+		next := s.schedule.Next(now)
+		if next.IsZero() {
+			return errors.New("schedule has no next run")
+		}
+		timer := s.clock.NewTimer(next.Sub(now))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C():
+			s.trigger(next)
+		}
+	}
+}
+
+// RunNow runs the job immediately and waits for it to complete. The
+// run is skipped if a scheduled run is in progress.
+func (s *Scheduler) RunNow() ScheduledRun {
+	s.mu.Lock()
+	if s.running {
+		s.mu.Unlock()
+		return s.report(ScheduledRun{Start: s.clock.Now(), Skipped: true})
+	}
+	s.running = true
+	s.mu.Unlock()
+
+	return s.runJob(s.clock.Now())
+}
+
+// trigger starts a run due at start unless one is in progress.
+func (s *Scheduler) trigger(start time.Time) {
+	s.mu.Lock()
+	if s.running {
+		s.mu.Unlock()
+		s.report(ScheduledRun{Start: start, Skipped: true})
+		return
+	}
+	s.running = true
+	s.mu.Unlock()
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.runJob(start)
+	}()
+}
+
+// runJob runs the job on every runner and reports the outcome. The
+// caller must have set s.running.
+func (s *Scheduler) runJob(start time.Time) ScheduledRun {
+	rec := NewRecorder()
+	run := ScheduledRun{Start: start}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, r := range s.runners {
+		c := r.With()
+		c.SetRecorder(rec)
+		wg.Add(1)
+		go func(c *LogRun) {
+			defer wg.Done()
+			if err := s.job(c); err != nil {
+				mu.Lock()
+				if run.Errs == nil {
+					run.Errs = make(map[string]error)
+				}
+				run.Errs[c.Host().String()] = err
+				mu.Unlock()
+			}
+		}(c)
+	}
+	wg.Wait()
+	run.Duration = s.clock.Since(start)
+	run.Summary = rec.Summary()
+	s.mu.Lock()
+	s.running = false
+	s.mu.Unlock()
+
+	return s.report(run)
+}
+
+func (s *Scheduler) report(run ScheduledRun) ScheduledRun {
+	if s.onRun != nil {
+		s.onRun(run)
+	}
+
+	return run
+}
@@ -0,0 +1,121 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/apatters/go-logrun"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCron(t *testing.T) {
+	base := time.Date(2019, time.March, 15, 10, 7, 30, 0, time.UTC) // a Friday
+	tests := []struct {
+		expr string
+		next time.Time
+	}{
+		{"* * * * *", time.Date(2019, time.March, 15, 10, 8, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2019, time.March, 15, 10, 15, 0, 0, time.UTC)},
+		{"0 2-4 * * *", time.Date(2019, time.March, 16, 2, 0, 0, 0, time.UTC)},
+		{"30 9 * * 1-5", time.Date(2019, time.March, 18, 9, 30, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2019, time.March, 17, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1,20 * 1", time.Date(2019, time.March, 18, 0, 0, 0, 0, time.UTC)},
+		{"0 0 31 * *", time.Date(2019, time.March, 31, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2020, time.February, 29, 0, 0, 0, 0, time.UTC)},
+		{"@monthly", time.Date(2019, time.April, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 30 2 *", time.Time{}},
+	}
+	for _, test := range tests {
+		s, err := logrun.ParseCron(test.expr)
+		require.NoError(t, err, test.expr)
+		assert.Equal(t, test.next, s.Next(base), test.expr)
+	}
+
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* * 0 * *", "*/0 * * * *", "5-1 * * * *", "a * * * *"} {
+		_, err := logrun.ParseCron(expr)
+		t.Logf("err = %v", err)
+		assert.Error(t, err, expr)
+	}
+}
+
+func TestScheduler_Run(t *testing.T) {
+	clock := logrun.NewFakeClock(time.Date(2019, time.March, 15, 10, 0, 0, 0, time.UTC))
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{})
+	l.SetRecorder(logrun.NewRecorder())
+	release := make(chan struct{})
+	runs := make(chan logrun.ScheduledRun, 10)
+	calls := 0
+	s, err := logrun.NewScheduler([]*logrun.LogRun{l}, func(r *logrun.LogRun) error {
+		calls++
+		r.Run("/bin/true")
+		if calls == 1 {
+			<-release
+			return errors.New("boom")
+		}
+		return nil
+	}, logrun.SchedulerConfig{
+		Schedule: logrun.Every(time.Minute),
+		Clock:    clock,
+		OnRun:    func(run logrun.ScheduledRun) { runs <- run },
+	})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- s.Run(ctx) }()
+
+	clock.BlockUntil(1)
+	clock.Advance(time.Minute)
+	clock.BlockUntil(1)
+	clock.Advance(time.Minute)
+	run := <-runs
+	t.Logf("run = %+v", run)
+	assert.True(t, run.Skipped, "the second run overlapped the first")
+
+	close(release)
+	run = <-runs
+	t.Logf("run = %+v", run)
+	assert.False(t, run.Skipped)
+	assert.True(t, run.Failed())
+	assert.Equal(t, 1, run.Summary.Run)
+
+	clock.BlockUntil(1)
+	clock.Advance(time.Minute)
+	run = <-runs
+	assert.False(t, run.Failed())
+	assert.Equal(t, 1, run.Summary.Run, "each run has its own summary")
+	assert.Zero(t, l.Summary().Run)
+
+	cancel()
+	assert.Equal(t, context.Canceled, <-done)
+	assert.Equal(t, 2, calls)
+}
+
+func TestNewTaskScheduler(t *testing.T) {
+	_, err := logrun.NewScheduler(nil, func(r *logrun.LogRun) error { return nil }, logrun.SchedulerConfig{Schedule: logrun.Every(time.Second)})
+	assert.Error(t, err)
+
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{})
+	tasks, err := logrun.NewTaskList([]logrun.Task{{
+		Name: "rotate",
+		Run: func(r *logrun.LogRun) error {
+			r.Run("/bin/true")
+			return nil
+		},
+	}}, logrun.TaskListConfig{})
+	require.NoError(t, err)
+	s, err := logrun.NewTaskScheduler([]*logrun.LogRun{l}, tasks, logrun.SchedulerConfig{Schedule: logrun.Every(time.Hour)})
+	require.NoError(t, err)
+	run := s.RunNow()
+	t.Logf("summary = %s", run.Summary)
+	assert.False(t, run.Failed())
+	assert.Equal(t, 1, run.Summary.Run)
+	assert.Len(t, run.Summary.Tasks, 1)
+}
@@ -0,0 +1,59 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun
+
+import (
+	"fmt"
+	"strings"
+)
+
+var (
+	// SectionHeadingPrefix is logged before the name of a section
+	// when it begins.
+	SectionHeadingPrefix = "=== "
+
+	// SectionIndent is logged before messages once per level of
+	// section nesting.
+	SectionIndent = "  "
+)
+
+// BeginSection logs a heading for name and groups the commands logged
+// until the matching EndSection() call under it by indenting them.
+// Sections can be nested.
+func (r *LogRun) BeginSection(name string) {
+	r.log(SectionHeadingPrefix + name)
+	// Always copy the stack so copies made by With() do not share
+	// it.
+	sections := make([]string, len(r.sections), len(r.sections)+1)
+	copy(sections, r.sections)
+	r.sections = append(sections, name)
+}
+
+// EndSection ends the section started by the last BeginSection()
+// call. An error is returned if no section has been started.
+func (r *LogRun) EndSection() error {
+	if len(r.sections) == 0 {
+		return fmt.Errorf("no section to end")
+	}
+	r.sections = r.sections[:len(r.sections)-1]
+
+	return nil
+}
+
+// WithSection runs f in a section named name, ending the section when
+// f returns. The error returned by f is passed on.
+func (r *LogRun) WithSection(name string, f func() error) error {
+	r.BeginSection(name)
+	defer r.EndSection() // nolint: errcheck
+
+	return f()
+}
+
+// Section returns the names of the current section and the sections
+// it is nested in separated by "/", e.g., "deploy/database". The empty
+// string is returned outside of any section.
+func (r *LogRun) Section() string {
+	return strings.Join(r.sections, "/")
+}
@@ -0,0 +1,51 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/apatters/go-logrun"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLocalLogRun_Section(t *testing.T) {
+	var logged []string
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{
+		LogFunc: func(v ...interface{}) {
+			logged = append(logged, v[0].(string))
+		},
+		Dryrun: true,
+	})
+	l.Run("/bin/true")
+	l.BeginSection("deploy")
+	l.Run("/bin/true")
+	err := l.WithSection("database", func() error {
+		assert.Equal(t, "deploy/database", l.Section())
+		l.Shell("echo hello")
+		return errors.New("oops")
+	})
+	assert.EqualError(t, err, "oops")
+	assert.Equal(t, "deploy", l.Section())
+	l.Run("/bin/false")
+	assert.NoError(t, l.EndSection())
+	assert.Error(t, l.EndSection())
+	assert.Equal(t, "", l.Section())
+	l.Run("/bin/true")
+
+	for _, msg := range logged {
+		t.Logf("%s", msg)
+	}
+	assert.Equal(t, []string{
+		"/bin/true",
+		"=== deploy",
+		"  /bin/true",
+		"  === database",
+		"    /bin/sh -c \"echo hello\"",
+		"  /bin/false",
+		"/bin/true",
+	}, logged)
+}
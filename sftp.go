@@ -0,0 +1,847 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// SFTP protocol version 3 packet types, status codes, and flags. See
+// draft-ietf-secsh-filexfer-02.
+const (
+	sftpInit     = 1
+	sftpVersion  = 2
+	sftpOpen     = 3
+	sftpClose    = 4
+	sftpRead     = 5
+	sftpWrite    = 6
+	sftpLstat    = 7
+	sftpSetstat  = 9
+	sftpOpendir  = 11
+	sftpReaddir  = 12
+	sftpRemove   = 13
+	sftpStat     = 17
+	sftpRename   = 18
+	sftpReadlink = 19
+	sftpExtended = 200
+
+	sftpStatus = 101
+	sftpHandle = 102
+	sftpData   = 103
+	sftpName   = 104
+	sftpAttrs  = 105
+
+	sftpStatusOK         = 0
+	sftpStatusEOF        = 1
+	sftpStatusNoSuchFile = 2
+	sftpStatusPermission = 3
+
+	sftpAttrSize        = 0x00000001
+	sftpAttrUIDGID      = 0x00000002
+	sftpAttrPermissions = 0x00000004
+	sftpAttrACModTime   = 0x00000008
+	sftpAttrExtended    = 0x80000000
+
+	sftpOpenRead  = 0x00000001
+	sftpOpenWrite = 0x00000002
+	sftpOpenCreat = 0x00000008
+	sftpOpenTrunc = 0x00000010
+	sftpOpenExcl  = 0x00000020
+
+	// sftpPosixRename is the OpenSSH extension that renames over
+	// an existing file.
+	sftpPosixRename = "posix-rename@openssh.com"
+
+	// sftpChunkSize is the number of bytes read or written per
+	// request.
+	sftpChunkSize = 32768
+)
+
+// sftpStatusError is an error status returned by an SFTP server.
+type sftpStatusError struct {
+	code uint32
+	msg  string
+}
+
+func (e *sftpStatusError) Error() string {
+	if e.msg != "" {
+		return e.msg
+	}
+
+	return fmt.Sprintf("sftp status %d", e.code)
+}
+
+// Is reports whether a missing file status matches ErrNotFound and a
+// permission denied status matches ErrPermissionDenied.
+func (e *sftpStatusError) Is(target error) bool {
+	switch target {
+	case ErrNotFound:
+		return e.code == sftpStatusNoSuchFile
+	case ErrPermissionDenied:
+		return e.code == sftpStatusPermission
+	}
+
+	return false
+}
+
+// sftpNotExist returns true if err reports a missing file.
+func sftpNotExist(err error) bool {
+	var statusErr *sftpStatusError
+
+	return errors.As(err, &statusErr) && statusErr.code == sftpStatusNoSuchFile
+}
+
+// sftpFileAttrs are the attributes of a file returned by an SFTP
+// server.
+type sftpFileAttrs struct {
+	size     uint64
+	mode     os.FileMode
+	modTime  time.Time
+	uid      uint32
+	gid      uint32
+	hasOwner bool
+}
+
+// sftpClient is a minimal SFTP version 3 client supporting the
+// operations used by the file helpers of LogRun. Requests are sent one
+// at a time.
+type sftpClient struct {
+	mu         sync.Mutex
+	w          io.WriteCloser
+	r          io.Reader
+	id         uint32
+	extensions map[string]string
+}
+
+// newSFTPClient starts the SFTP subsystem in session and performs the
+// version handshake.
+func newSFTPClient(session *ssh.Session) (*sftpClient, error) {
+	w, err := session.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	r, err := session.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := session.RequestSubsystem("sftp"); err != nil {
+		return nil, fmt.Errorf("sftp subsystem is not available: %w", err)
+	}
+	c := &sftpClient{w: w, r: r, extensions: make(map[string]string)}
+	if err := c.writePacket(sftpInit, sftpUint32(nil, 3)); err != nil {
+		return nil, err
+	}
+	typ, data, err := c.readPacket()
+	if err != nil {
+		return nil, err
+	}
+	if typ != sftpVersion {
+		return nil, fmt.Errorf("sftp: unexpected packet type %d in handshake", typ)
+	}
+	p := sftpParser{data}
+	if _, err := p.uint32(); err != nil {
+		return nil, err
+	}
+	for len(p.b) > 0 {
+		name, err := p.string()
+		if err != nil {
+			return nil, err
+		}
+		value, err := p.string()
+		if err != nil {
+			return nil, err
+		}
+		c.extensions[name] = value
+	}
+
+	return c, nil
+}
+
+func (c *sftpClient) close() error {
+	return c.w.Close()
+}
+
+func (c *sftpClient) writePacket(typ byte, payload []byte) error {
+	packet := make([]byte, 0, 5+len(payload))
+	packet = sftpUint32(packet, uint32(1+len(payload)))
+	packet = append(packet, typ)
+	packet = append(packet, payload...)
+	_, err := c.w.Write(packet)
+
+	return err
+}
+
+func (c *sftpClient) readPacket() (byte, []byte, error) {
+	var header [5]byte
+	if _, err := io.ReadFull(c.r, header[:]); err != nil {
+		return 0, nil, err
+	}
+	n := binary.BigEndian.Uint32(header[:4])
+	if n < 1 || n > 4*sftpChunkSize+1024 {
+		return 0, nil, fmt.Errorf("sftp: invalid packet length %d", n)
+	}
+	data := make([]byte, n-1)
+	if _, err := io.ReadFull(c.r, data); err != nil {
+		return 0, nil, err
+	}
+
+	return header[4], data, nil
+}
+
+// request sends a request of type typ with payload and returns the
+// type and payload, without the request ID, of the response.
+func (c *sftpClient) request(typ byte, payload []byte) (byte, []byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.id++
+	id := c.id
+	if err := c.writePacket(typ, append(sftpUint32(nil, id), payload...)); err != nil {
+		return 0, nil, err
+	}
+	respType, data, err := c.readPacket()
+	if err != nil {
+		return 0, nil, err
+	}
+	p := sftpParser{data}
+	respID, err := p.uint32()
+	if err != nil {
+		return 0, nil, err
+	}
+	if respID != id {
+		return 0, nil, fmt.Errorf("sftp: unexpected response ID %d for request %d", respID, id)
+	}
+
+	return respType, p.b, nil
+}
+
+// expect sends a request and checks that the response is of type
+// want. Error statuses are returned as *sftpStatusError.
+func (c *sftpClient) expect(typ byte, payload []byte, want byte) ([]byte, error) {
+	respType, data, err := c.request(typ, payload)
+	if err != nil {
+		return nil, err
+	}
+	if respType == sftpStatus {
+		err := sftpParseStatus(data)
+		if err == nil && want != sftpStatus {
+			return nil, fmt.Errorf("sftp: unexpected OK status")
+		}
+		return nil, err
+	}
+	if respType != want {
+		return nil, fmt.Errorf("sftp: unexpected packet type %d", respType)
+	}
+
+	return data, nil
+}
+
+// status sends a request that is answered with a status.
+func (c *sftpClient) status(typ byte, payload []byte) error {
+	_, err := c.expect(typ, payload, sftpStatus)
+
+	return err
+}
+
+func (c *sftpClient) stat(p string) (sftpFileAttrs, error) {
+	return c.statType(sftpStat, p)
+}
+
+func (c *sftpClient) lstat(p string) (sftpFileAttrs, error) {
+	return c.statType(sftpLstat, p)
+}
+
+func (c *sftpClient) statType(typ byte, p string) (sftpFileAttrs, error) {
+	data, err := c.expect(typ, sftpString(nil, p), sftpAttrs)
+	if err != nil {
+		return sftpFileAttrs{}, err
+	}
+	parser := sftpParser{data}
+
+	return parser.attrs()
+}
+
+func (c *sftpClient) open(p string, flags uint32, perm os.FileMode) (string, error) {
+	payload := sftpString(nil, p)
+	payload = sftpUint32(payload, flags)
+	if flags&sftpOpenCreat != 0 {
+		payload = sftpUint32(payload, sftpAttrPermissions)
+		payload = sftpUint32(payload, uint32(perm.Perm()))
+	} else {
+		payload = sftpUint32(payload, 0)
+	}
+	data, err := c.expect(sftpOpen, payload, sftpHandle)
+	if err != nil {
+		return "", err
+	}
+	parser := sftpParser{data}
+
+	return parser.string()
+}
+
+func (c *sftpClient) closeHandle(handle string) error {
+	return c.status(sftpClose, sftpString(nil, handle))
+}
+
+func (c *sftpClient) readFile(p string) ([]byte, error) {
+	var content bytes.Buffer
+	_, err := c.readTo(p, &content)
+
+	return content.Bytes(), err
+}
+
+// readTo writes the contents of p to w and returns the number of bytes
+// written.
+func (c *sftpClient) readTo(p string, w io.Writer) (int64, error) {
+	handle, err := c.open(p, sftpOpenRead, 0)
+	if err != nil {
+		return 0, err
+	}
+	var offset int64
+	for {
+		payload := sftpString(nil, handle)
+		payload = sftpUint64(payload, uint64(offset))
+		payload = sftpUint32(payload, sftpChunkSize)
+		data, err := c.expect(sftpRead, payload, sftpData)
+		if err != nil {
+			var statusErr *sftpStatusError
+			if errors.As(err, &statusErr) && statusErr.code == sftpStatusEOF {
+				break
+			}
+			c.closeHandle(handle) // nolint: errcheck
+			return offset, err
+		}
+		parser := sftpParser{data}
+		chunk, err := parser.string()
+		if err == nil {
+			_, err = io.WriteString(w, chunk)
+		}
+		if err != nil {
+			c.closeHandle(handle) // nolint: errcheck
+			return offset, err
+		}
+		offset += int64(len(chunk))
+	}
+
+	return offset, c.closeHandle(handle)
+}
+
+// writeFile writes content to a temporary file next to p, sets its
+// permission bits to perm, and renames it to p.
+func (c *sftpClient) writeFile(p string, content []byte, perm os.FileMode) error {
+	_, err := c.writeFrom(p, bytes.NewReader(content), perm)
+
+	return err
+}
+
+// writeFrom is like writeFile() but writes the contents read from rd
+// and returns the number of bytes written. The temporary file has a
+// random name and is created exclusively, so concurrent writers and
+// planted symbolic links are not written through.
+func (c *sftpClient) writeFrom(p string, rd io.Reader, perm os.FileMode) (int64, error) {
+	tmp, err := tempFileName(p)
+	if err != nil {
+		return 0, err
+	}
+	handle, err := c.open(tmp, sftpOpenWrite|sftpOpenCreat|sftpOpenExcl, 0600)
+	if err != nil {
+		return 0, err
+	}
+	var offset int64
+	buf := make([]byte, sftpChunkSize)
+	for {
+		n, err := io.ReadFull(rd, buf)
+		if n > 0 {
+			payload := sftpString(nil, handle)
+			payload = sftpUint64(payload, uint64(offset))
+			payload = sftpString(payload, string(buf[:n]))
+			if err := c.status(sftpWrite, payload); err != nil {
+				c.closeHandle(handle) // nolint: errcheck
+				c.remove(tmp)         // nolint: errcheck
+				return offset, err
+			}
+			offset += int64(n)
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			c.closeHandle(handle) // nolint: errcheck
+			c.remove(tmp)         // nolint: errcheck
+			return offset, err
+		}
+	}
+	if err := c.closeHandle(handle); err != nil {
+		c.remove(tmp) // nolint: errcheck
+		return offset, err
+	}
+	// The permissions given to open() are subject to the umask.
+	if err := c.chmod(tmp, perm); err != nil {
+		c.remove(tmp) // nolint: errcheck
+		return offset, err
+	}
+	if err := c.rename(tmp, p); err != nil {
+		c.remove(tmp) // nolint: errcheck
+		return offset, err
+	}
+
+	return offset, nil
+}
+
+func (c *sftpClient) chmod(p string, perm os.FileMode) error {
+	payload := sftpString(nil, p)
+	payload = sftpUint32(payload, sftpAttrPermissions)
+	payload = sftpUint32(payload, uint32(perm.Perm()))
+
+	return c.status(sftpSetstat, payload)
+}
+
+func (c *sftpClient) remove(p string) error {
+	return c.status(sftpRemove, sftpString(nil, p))
+}
+
+// rename renames from to to, replacing to if it exists. The OpenSSH
+// posix-rename extension is used if the server supports it, since
+// plain SFTP renames fail if to exists.
+func (c *sftpClient) rename(from string, to string) error {
+	if _, ok := c.extensions[sftpPosixRename]; ok {
+		payload := sftpString(nil, sftpPosixRename)
+		payload = sftpString(payload, from)
+		payload = sftpString(payload, to)
+		return c.status(sftpExtended, payload)
+	}
+	if err := c.remove(to); err != nil && !sftpNotExist(err) {
+		return err
+	}
+
+	return c.status(sftpRename, sftpString(sftpString(nil, from), to))
+}
+
+// readlink returns the target of the symbolic link p.
+func (c *sftpClient) readlink(p string) (string, error) {
+	data, err := c.expect(sftpReadlink, sftpString(nil, p), sftpName)
+	if err != nil {
+		return "", err
+	}
+	parser := sftpParser{data}
+	count, err := parser.uint32()
+	if err != nil {
+		return "", err
+	}
+	if count != 1 {
+		return "", fmt.Errorf("sftp: readlink returned %d names", count)
+	}
+
+	return parser.string()
+}
+
+// readDir returns the names of the entries of the directory p,
+// excluding "." and "..".
+func (c *sftpClient) readDir(p string) ([]string, error) {
+	data, err := c.expect(sftpOpendir, sftpString(nil, p), sftpHandle)
+	if err != nil {
+		return nil, err
+	}
+	parser := sftpParser{data}
+	handle, err := parser.string()
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for {
+		data, err := c.expect(sftpReaddir, sftpString(nil, handle), sftpName)
+		if err != nil {
+			var statusErr *sftpStatusError
+			if errors.As(err, &statusErr) && statusErr.code == sftpStatusEOF {
+				break
+			}
+			c.closeHandle(handle) // nolint: errcheck
+			return nil, err
+		}
+		parser := sftpParser{data}
+		count, err := parser.uint32()
+		if err != nil {
+			c.closeHandle(handle) // nolint: errcheck
+			return nil, err
+		}
+		for i := uint32(0); i < count; i++ {
+			name, err := parser.string()
+			if err == nil {
+				_, err = parser.string() // long name
+			}
+			if err == nil {
+				_, err = parser.attrs()
+			}
+			if err != nil {
+				c.closeHandle(handle) // nolint: errcheck
+				return nil, err
+			}
+			if name != "." && name != ".." {
+				names = append(names, name)
+			}
+		}
+	}
+
+	return names, c.closeHandle(handle)
+}
+
+// glob returns the paths matching the shell glob pattern in sorted
+// order. Like the shell, wildcards do not match a leading "." in a
+// file name.
+func (c *sftpClient) glob(pattern string) ([]string, error) {
+	if !strings.ContainsAny(pattern, `*?[\`) {
+		if _, err := c.lstat(pattern); err != nil {
+			if sftpNotExist(err) {
+				return nil, nil
+			}
+			return nil, err
+		}
+		return []string{pattern}, nil
+	}
+	dir, file := path.Split(pattern)
+	if dir != "/" {
+		dir = strings.TrimSuffix(dir, "/")
+	}
+	dirs := []string{dir}
+	if strings.ContainsAny(dir, `*?[\`) {
+		var err error
+		if dirs, err = c.glob(dir); err != nil {
+			return nil, err
+		}
+	}
+	var matches []string
+	for _, d := range dirs {
+		readPath := d
+		if readPath == "" {
+			readPath = "."
+		}
+		names, err := c.readDir(readPath)
+		if err != nil {
+			// Not a directory or not readable, like the
+			// shell.
+			continue
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if strings.HasPrefix(name, ".") && !strings.HasPrefix(file, ".") {
+				continue
+			}
+			ok, err := path.Match(file, name)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				matches = append(matches, path.Join(d, name))
+			}
+		}
+	}
+
+	return matches, nil
+}
+
+func sftpParseStatus(data []byte) error {
+	p := sftpParser{data}
+	code, err := p.uint32()
+	if err != nil {
+		return err
+	}
+	if code == sftpStatusOK {
+		return nil
+	}
+	// Version 3 servers send a message and language tag, but
+	// older servers may not.
+	msg, _ := p.string()
+
+	return &sftpStatusError{code: code, msg: msg}
+}
+
+func sftpUint32(b []byte, v uint32) []byte {
+	return append(b, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+func sftpUint64(b []byte, v uint64) []byte {
+	return sftpUint32(sftpUint32(b, uint32(v>>32)), uint32(v))
+}
+
+func sftpString(b []byte, s string) []byte {
+	return append(sftpUint32(b, uint32(len(s))), s...)
+}
+
+// sftpParser decodes the fields of an SFTP packet.
+type sftpParser struct {
+	b []byte
+}
+
+var errSFTPShortPacket = errors.New("sftp: short packet")
+
+func (p *sftpParser) uint32() (uint32, error) {
+	if len(p.b) < 4 {
+		return 0, errSFTPShortPacket
+	}
+	v := binary.BigEndian.Uint32(p.b)
+	p.b = p.b[4:]
+
+	return v, nil
+}
+
+func (p *sftpParser) uint64() (uint64, error) {
+	if len(p.b) < 8 {
+		return 0, errSFTPShortPacket
+	}
+	v := binary.BigEndian.Uint64(p.b)
+	p.b = p.b[8:]
+
+	return v, nil
+}
+
+func (p *sftpParser) string() (string, error) {
+	n, err := p.uint32()
+	if err != nil {
+		return "", err
+	}
+	if uint32(len(p.b)) < n {
+		return "", errSFTPShortPacket
+	}
+	s := string(p.b[:n])
+	p.b = p.b[n:]
+
+	return s, nil
+}
+
+func (p *sftpParser) attrs() (sftpFileAttrs, error) {
+	var a sftpFileAttrs
+	flags, err := p.uint32()
+	if err != nil {
+		return a, err
+	}
+	if flags&sftpAttrSize != 0 {
+		if a.size, err = p.uint64(); err != nil {
+			return a, err
+		}
+	}
+	if flags&sftpAttrUIDGID != 0 {
+		if a.uid, err = p.uint32(); err != nil {
+			return a, err
+		}
+		if a.gid, err = p.uint32(); err != nil {
+			return a, err
+		}
+		a.hasOwner = true
+	}
+	if flags&sftpAttrPermissions != 0 {
+		perm, err := p.uint32()
+		if err != nil {
+			return a, err
+		}
+		a.mode = sftpFileMode(perm)
+	}
+	if flags&sftpAttrACModTime != 0 {
+		times, err := p.uint64()
+		if err != nil {
+			return a, err
+		}
+		a.modTime = time.Unix(int64(uint32(times)), 0)
+	}
+	if flags&sftpAttrExtended != 0 {
+		count, err := p.uint32()
+		if err != nil {
+			return a, err
+		}
+		for i := uint32(0); i < 2*count; i++ {
+			if _, err := p.string(); err != nil {
+				return a, err
+			}
+		}
+	}
+
+	return a, nil
+}
+
+// sftpFileMode converts the POSIX mode bits sent by SFTP servers to
+// an os.FileMode.
+func sftpFileMode(perm uint32) os.FileMode {
+	mode := os.FileMode(perm & 0777)
+	switch perm & 0170000 {
+	case 0040000:
+		mode |= os.ModeDir
+	case 0120000:
+		mode |= os.ModeSymlink
+	case 0010000:
+		mode |= os.ModeNamedPipe
+	case 0140000:
+		mode |= os.ModeSocket
+	case 0020000:
+		mode |= os.ModeDevice | os.ModeCharDevice
+	case 0060000:
+		mode |= os.ModeDevice
+	}
+	if perm&04000 != 0 {
+		mode |= os.ModeSetuid
+	}
+	if perm&02000 != 0 {
+		mode |= os.ModeSetgid
+	}
+	if perm&01000 != 0 {
+		mode |= os.ModeSticky
+	}
+
+	return mode
+}
+
+// withSFTP calls f with an SFTP client using a session on the
+// connection commands are run on.
+func (r *remoteRunner) withSFTP(f func(c *sftpClient) error) error {
+	conn, session, err := r.session(context.Background())
+	if err != nil {
+		return connectionError(err)
+	}
+	defer r.conns.release(conn, false)
+	defer session.Close() // nolint: errcheck
+	c, err := newSFTPClient(session)
+	if err != nil {
+		return connectionError(err)
+	}
+	defer c.close() // nolint: errcheck
+
+	return f(c)
+}
+
+// formatSFTP returns a string representation of an SFTP operation
+// suitable for logging, e.g., "sftp user@host stat /etc/hosts".
+func (r *remoteRunner) formatSFTP(op string, args ...string) string {
+	return fmt.Sprintf("sftp %s@%s %s",
+		r.credentials.Username,
+		r.credentials.Hostname,
+		strings.Join(append([]string{op}, args...), " "))
+}
+
+// sftpRunner returns the Runner of r if it is a remote runner
+// configured to use SFTP for file operations that runs commands as
+// the login user, and nil otherwise.
+func (r *LogRun) sftpRunner() *remoteRunner {
+	if remote, ok := r.Runner.(*remoteRunner); ok && remote.useSFTP && r.runAs() == "" {
+		return remote
+	}
+
+	return nil
+}
+
+// sftpStat logs and performs an SFTP stat of p. The returned bool is
+// false if p does not exist.
+func (r *LogRun) sftpStat(remote *remoteRunner, p string) (sftpFileAttrs, bool, error) {
+	r.log(remote.formatSFTP("stat", p))
+	var attrs sftpFileAttrs
+	err := remote.withSFTP(func(c *sftpClient) error {
+		var err error
+		attrs, err = c.stat(p)
+		return err
+	})
+	if sftpNotExist(err) {
+		return attrs, false, nil
+	}
+	if err != nil {
+		return attrs, false, accessError(p, err)
+	}
+
+	return attrs, true, nil
+}
+
+func (r *LogRun) sftpGlob(remote *remoteRunner, pattern string, opts GlobOptions) ([]string, error) {
+	r.log(remote.formatSFTP("glob", pattern))
+	var matches []globMatch
+	err := remote.withSFTP(func(c *sftpClient) error {
+		paths, err := c.glob(pattern)
+		if err != nil {
+			return err
+		}
+		for _, p := range paths {
+			match := globMatch{path: p}
+			if opts.Sort == GlobSortModTime || opts.Sort == GlobSortSize {
+				if attrs, err := c.lstat(p); err == nil {
+					match.modTime, match.size = attrs.modTime, int64(attrs.size)
+				}
+			}
+			matches = append(matches, match)
+		}
+		return nil
+	})
+	if err != nil {
+		return []string{}, globError(pattern, err)
+	}
+	if len(matches) == 0 {
+		return []string{}, globError(pattern, "no matches")
+	}
+
+	return sortGlobMatches(matches, opts), nil
+}
+
+func (r *LogRun) sftpReadFile(remote *remoteRunner, p string) (string, bool, error) {
+	r.log(remote.formatSFTP("get", p))
+	if r.Dryrun {
+		return "", false, nil
+	}
+	var content []byte
+	err := remote.withSFTP(func(c *sftpClient) error {
+		var err error
+		content, err = c.readFile(p)
+		return err
+	})
+	if sftpNotExist(err) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("could not read %s: %w", p, err)
+	}
+
+	return string(content), true, nil
+}
+
+func (r *LogRun) sftpFileMode(remote *remoteRunner, p string) (string, error) {
+	attrs, exists, err := r.sftpStat(remote, p)
+	if err != nil {
+		return "", err
+	}
+	if !exists {
+		return "", notFoundError("could not access", p)
+	}
+
+	return strconv.FormatUint(uint64(attrs.mode.Perm()), 8), nil
+}
+
+func (r *LogRun) sftpChmod(remote *remoteRunner, p string, mode os.FileMode) error {
+	r.log(remote.formatSFTP("chmod", strconv.FormatUint(uint64(mode.Perm()), 8), p))
+	err := remote.withSFTP(func(c *sftpClient) error {
+		return c.chmod(p, mode)
+	})
+	if err != nil {
+		return fmt.Errorf("could not change mode of %s: %w", p, err)
+	}
+
+	return nil
+}
+
+func (r *LogRun) sftpWriteFile(remote *remoteRunner, p string, content string, mode os.FileMode) error {
+	err := remote.withSFTP(func(c *sftpClient) error {
+		return c.writeFile(p, []byte(content), mode)
+	})
+	if err != nil {
+		return fmt.Errorf("could not write %s: %w", p, err)
+	}
+
+	return nil
+}
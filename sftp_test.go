@@ -0,0 +1,164 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/apatters/go-logrun"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newSFTPTestLogRun(t *testing.T, s *testSSHServer) (*logrun.LogRun, func() string) {
+	log, out, _ := newLogger()
+	r, err := logrun.NewRemoteLogRun(logrun.RemoteConfig{
+		LogFunc:     log.Println,
+		Credentials: s.Credentials(),
+		UseSFTP:     true,
+	})
+	require.NoError(t, err)
+
+	return r, func() string {
+		defer out.Reset()
+		return out.String()
+	}
+}
+
+func TestRemoteLogRun_SFTPExists(t *testing.T) {
+	s := newTestSSHServer(t, nil)
+	defer s.Close()
+	tmpDir, err := ioutil.TempDir("", "logrun")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmpDir)
+	file := filepath.Join(tmpDir, "file")
+	require.NoError(t, ioutil.WriteFile(file, []byte("x"), 0644))
+	require.NoError(t, os.Symlink(tmpDir, filepath.Join(tmpDir, "link")))
+
+	r, out := newSFTPTestLogRun(t, s)
+	exists, err := r.FileExists(file)
+	require.NoError(t, err)
+	assert.True(t, exists)
+	logged := out()
+	t.Logf("out = %q", logged)
+	assert.Regexp(t, `^sftp .*@127\.0\.0\.1 stat .*/file\n$`, logged)
+
+	exists, err = r.FileExists(filepath.Join(tmpDir, "xyzzy"))
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	exists, err = r.FileExists(tmpDir)
+	t.Logf("err = %v", err)
+	assert.Error(t, err)
+	assert.False(t, exists)
+
+	exists, err = r.DirExists(filepath.Join(tmpDir, "link"))
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	exists, err = r.DirExists(file)
+	t.Logf("err = %v", err)
+	assert.Error(t, err)
+	assert.False(t, exists)
+	t.Logf("out = %q", out())
+}
+
+func TestRemoteLogRun_SFTPGlob(t *testing.T) {
+	s := newTestSSHServer(t, nil)
+	defer s.Close()
+	tmpDir, err := ioutil.TempDir("", "logrun")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmpDir)
+	for _, name := range []string{"a/x.conf", "a/y.conf", "b/z.conf", "b/.hidden.conf", "c"} {
+		p := filepath.Join(tmpDir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(p), 0755))
+		require.NoError(t, ioutil.WriteFile(p, nil, 0644))
+	}
+
+	r, out := newSFTPTestLogRun(t, s)
+	results, err := r.Glob(filepath.Join(tmpDir, "*", "*.conf"))
+	t.Logf("results = %q", results)
+	t.Logf("out = %q", out())
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		filepath.Join(tmpDir, "a/x.conf"),
+		filepath.Join(tmpDir, "a/y.conf"),
+		filepath.Join(tmpDir, "b/z.conf"),
+	}, results)
+
+	results, err = r.Glob(filepath.Join(tmpDir, "c"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(tmpDir, "c")}, results)
+
+	results, err = r.Glob(filepath.Join(tmpDir, "xy*zzy"))
+	t.Logf("err = %v", err)
+	assert.Error(t, err)
+	assert.Equal(t, []string{}, results)
+}
+
+func TestRemoteLogRun_SFTPFileString(t *testing.T) {
+	s := newTestSSHServer(t, nil)
+	defer s.Close()
+	tmpDir, err := ioutil.TempDir("", "logrun")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmpDir)
+	path := filepath.Join(tmpDir, "config")
+	content := strings.Repeat("0123456789abcdef", 10000)
+
+	r, out := newSFTPTestLogRun(t, s)
+	changed, err := r.PutFileString(path, content, 0600)
+	logged := out()
+	t.Logf("out = %q", logged)
+	require.NoError(t, err)
+	assert.True(t, changed)
+	assert.Contains(t, logged, " put "+path+"\n")
+	assert.NotContains(t, logged, "/bin/")
+	data, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, content, string(data))
+	fi, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), fi.Mode().Perm())
+
+	got, err := r.GetFileString(path)
+	require.NoError(t, err)
+	assert.Equal(t, content, got)
+
+	changed, err = r.PutFileString(path, content, 0600)
+	require.NoError(t, err)
+	assert.False(t, changed)
+
+	changed, err = r.PutFileString(path, content, 0640)
+	logged = out()
+	t.Logf("out = %q", logged)
+	require.NoError(t, err)
+	assert.True(t, changed)
+	assert.Contains(t, logged, " chmod 640 "+path+"\n")
+	fi, err = os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0640), fi.Mode().Perm())
+
+	changed, err = r.PutFileString(path, "new\n", 0640)
+	require.NoError(t, err)
+	assert.True(t, changed)
+	data, err = ioutil.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "new\n", string(data))
+
+	_, err = r.GetFileString(filepath.Join(tmpDir, "xyzzy"))
+	t.Logf("err = %v", err)
+	assert.Error(t, err)
+}
+
+func TestRemoteLogRun_SFTPConcurrentWrites(t *testing.T) {
+	s := newTestSSHServer(t, nil)
+	defer s.Close()
+	r, _ := newSFTPTestLogRun(t, s)
+	testConcurrentWrites(t, r)
+}
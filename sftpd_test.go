@@ -0,0 +1,271 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun_test
+
+import (
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"syscall"
+)
+
+// serveTestSFTP serves the local filesystem using the subset of SFTP
+// version 3 used by logrun on rw until it is closed.
+func serveTestSFTP(rw io.ReadWriter) {
+	s := &testSFTPServer{
+		rw:    rw,
+		files: make(map[string]*os.File),
+		dirs:  make(map[string][]os.FileInfo),
+	}
+	for {
+		typ, data, err := s.readPacket()
+		if err != nil {
+			return
+		}
+		if typ == 1 { // INIT
+			reply := sftpTestUint32(nil, 3)
+			reply = sftpTestString(reply, "posix-rename@openssh.com")
+			reply = sftpTestString(reply, "1")
+			s.writePacket(2, reply)
+			continue
+		}
+		if len(data) < 4 {
+			return
+		}
+		id := binary.BigEndian.Uint32(data)
+		s.handle(typ, id, &sftpTestParser{data[4:]})
+	}
+}
+
+type testSFTPServer struct {
+	rw    io.ReadWriter
+	files map[string]*os.File
+	dirs  map[string][]os.FileInfo
+	next  int
+}
+
+func (s *testSFTPServer) readPacket() (byte, []byte, error) {
+	var header [5]byte
+	if _, err := io.ReadFull(s.rw, header[:]); err != nil {
+		return 0, nil, err
+	}
+	data := make([]byte, binary.BigEndian.Uint32(header[:4])-1)
+	_, err := io.ReadFull(s.rw, data)
+
+	return header[4], data, err
+}
+
+func (s *testSFTPServer) writePacket(typ byte, payload []byte) {
+	packet := sftpTestUint32(nil, uint32(1+len(payload)))
+	packet = append(packet, typ)
+	s.rw.Write(append(packet, payload...)) // nolint: errcheck
+}
+
+func (s *testSFTPServer) status(id uint32, err error) {
+	code := uint32(0)
+	msg := "OK"
+	switch {
+	case err == io.EOF:
+		code, msg = 1, "EOF"
+	case os.IsNotExist(err):
+		code, msg = 2, "No such file"
+	case os.IsPermission(err):
+		code, msg = 3, "Permission denied"
+	case err != nil:
+		code, msg = 4, err.Error()
+	}
+	reply := sftpTestUint32(sftpTestUint32(nil, id), code)
+	reply = sftpTestString(reply, msg)
+	s.writePacket(101, sftpTestString(reply, ""))
+}
+
+func (s *testSFTPServer) newHandle() string {
+	s.next++
+	return strconv.Itoa(s.next)
+}
+
+func (s *testSFTPServer) handle(typ byte, id uint32, p *sftpTestParser) {
+	reply := sftpTestUint32(nil, id)
+	switch typ {
+	case 3: // OPEN
+		name := p.string()
+		flags := p.uint32()
+		osFlags := os.O_RDONLY
+		if flags&2 != 0 {
+			osFlags = os.O_WRONLY
+		}
+		if flags&8 != 0 {
+			osFlags |= os.O_CREATE
+		}
+		if flags&0x10 != 0 {
+			osFlags |= os.O_TRUNC
+		}
+		if flags&0x20 != 0 {
+			osFlags |= os.O_EXCL
+		}
+		perm := os.FileMode(0644)
+		if attrFlags := p.uint32(); attrFlags&4 != 0 {
+			perm = os.FileMode(p.uint32() & 0777)
+		}
+		f, err := os.OpenFile(name, osFlags, perm)
+		if err != nil {
+			s.status(id, err)
+			return
+		}
+		h := s.newHandle()
+		s.files[h] = f
+		s.writePacket(102, sftpTestString(reply, h))
+	case 4: // CLOSE
+		h := p.string()
+		if f, ok := s.files[h]; ok {
+			delete(s.files, h)
+			s.status(id, f.Close())
+			return
+		}
+		delete(s.dirs, h)
+		s.status(id, nil)
+	case 5: // READ
+		f := s.files[p.string()]
+		offset := p.uint64()
+		buf := make([]byte, p.uint32())
+		n, err := f.ReadAt(buf, int64(offset))
+		if n == 0 && err != nil {
+			s.status(id, err)
+			return
+		}
+		s.writePacket(103, sftpTestString(reply, string(buf[:n])))
+	case 6: // WRITE
+		f := s.files[p.string()]
+		offset := p.uint64()
+		_, err := f.WriteAt([]byte(p.string()), int64(offset))
+		s.status(id, err)
+	case 7, 17: // LSTAT, STAT
+		name := p.string()
+		stat := os.Stat
+		if typ == 7 {
+			stat = os.Lstat
+		}
+		fi, err := stat(name)
+		if err != nil {
+			s.status(id, err)
+			return
+		}
+		s.writePacket(105, append(reply, sftpTestAttrs(fi)...))
+	case 9: // SETSTAT
+		name := p.string()
+		if p.uint32()&4 != 0 {
+			s.status(id, os.Chmod(name, os.FileMode(p.uint32()&07777)))
+			return
+		}
+		s.status(id, nil)
+	case 11: // OPENDIR
+		entries, err := ioutil.ReadDir(p.string())
+		if err != nil {
+			s.status(id, err)
+			return
+		}
+		h := s.newHandle()
+		s.dirs[h] = entries
+		s.writePacket(102, sftpTestString(reply, h))
+	case 12: // READDIR
+		h := p.string()
+		entries := s.dirs[h]
+		if len(entries) == 0 {
+			s.status(id, io.EOF)
+			return
+		}
+		s.dirs[h] = nil
+		reply = sftpTestUint32(reply, uint32(len(entries)))
+		for _, fi := range entries {
+			reply = sftpTestString(reply, fi.Name())
+			reply = sftpTestString(reply, fi.Name())
+			reply = append(reply, sftpTestAttrs(fi)...)
+		}
+		s.writePacket(104, reply)
+	case 19: // READLINK
+		target, err := os.Readlink(p.string())
+		if err != nil {
+			s.status(id, err)
+			return
+		}
+		reply = sftpTestUint32(reply, 1)
+		reply = sftpTestString(reply, target)
+		reply = sftpTestString(reply, target)
+		s.writePacket(104, append(reply, sftpTestUint32(nil, 0)...))
+	case 13: // REMOVE
+		s.status(id, os.Remove(p.string()))
+	case 200: // EXTENDED
+		if p.string() != "posix-rename@openssh.com" {
+			s.status(id, syscall.ENOSYS)
+			return
+		}
+		from := p.string()
+		s.status(id, os.Rename(from, p.string()))
+	default:
+		s.status(id, syscall.ENOSYS)
+	}
+}
+
+func sftpTestAttrs(fi os.FileInfo) []byte {
+	mode := uint32(fi.Mode().Perm())
+	switch {
+	case fi.IsDir():
+		mode |= 0040000
+	case fi.Mode()&os.ModeSymlink != 0:
+		mode |= 0120000
+	case fi.Mode().IsRegular():
+		mode |= 0100000
+	}
+	// The files served in tests are owned by the user running them.
+	uid, gid := uint32(os.Getuid()), uint32(os.Getgid())
+	b := sftpTestUint32(nil, 1|2|4|8)
+	b = sftpTestUint32(b, uint32(uint64(fi.Size())>>32))
+	b = sftpTestUint32(b, uint32(fi.Size()))
+	b = sftpTestUint32(b, uid)
+	b = sftpTestUint32(b, gid)
+	b = sftpTestUint32(b, mode)
+	b = sftpTestUint32(b, uint32(fi.ModTime().Unix()))
+
+	return sftpTestUint32(b, uint32(fi.ModTime().Unix()))
+}
+
+func sftpTestUint32(b []byte, v uint32) []byte {
+	return append(b, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+func sftpTestString(b []byte, s string) []byte {
+	return append(sftpTestUint32(b, uint32(len(s))), s...)
+}
+
+type sftpTestParser struct {
+	b []byte
+}
+
+func (p *sftpTestParser) uint32() uint32 {
+	if len(p.b) < 4 {
+		return 0
+	}
+	v := binary.BigEndian.Uint32(p.b)
+	p.b = p.b[4:]
+
+	return v
+}
+
+func (p *sftpTestParser) uint64() uint64 {
+	return uint64(p.uint32())<<32 | uint64(p.uint32())
+}
+
+func (p *sftpTestParser) string() string {
+	n := p.uint32()
+	if uint32(len(p.b)) < n {
+		return ""
+	}
+	s := string(p.b[:n])
+	p.b = p.b[n:]
+
+	return s
+}
@@ -0,0 +1,170 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrShutdown is returned for commands started after Shutdown() has
+// been called.
+var ErrShutdown = errors.New("logrun is shut down")
+
+// inFlightCmd is a command that is running.
+type inFlightCmd struct {
+	// cancel kills the command. It is nil if the Runner can not
+	// cancel commands.
+	cancel context.CancelFunc
+
+	// done is closed when the command has returned.
+	done chan struct{}
+}
+
+// shutdownState tracks the commands in flight so Shutdown() can wait
+// for them. It is shared by copies of a LogRun.
+type shutdownState struct {
+	mu       sync.Mutex
+	closing  bool
+	next     int
+	inFlight map[int]*inFlightCmd
+	wg       sync.WaitGroup
+}
+
+func newShutdownState() *shutdownState {
+	return &shutdownState{inFlight: make(map[int]*inFlightCmd)}
+}
+
+// beginCommand registers the command described by spec as in flight
+// and, if the Runner supports it, makes it cancelable by Shutdown().
+// The returned function must be called when the command has returned.
+// ErrShutdown is returned once Shutdown() has been called.
+func (r *LogRun) beginCommand(spec *execSpec) (func(), error) {
+	s := r.shutdown
+	if s == nil {
+		return func() {}, nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closing {
+		return nil, ErrShutdown
+	}
+	cmd := &inFlightCmd{done: make(chan struct{})}
+	// Making every remote command cancelable would fail on Windows
+	// hosts, which do not support cancelable commands.
+	if _, ok := r.Runner.(executor); ok && r.windowsRunner() == nil {
+		if spec.ctx == nil {
+			spec.ctx = r.call.ctx
+		}
+		if spec.ctx == nil {
+			spec.ctx = context.Background()
+		}
+		spec.shutdownCtx = spec.ctx.Done() == nil
+		spec.ctx, cmd.cancel = context.WithCancel(spec.ctx)
+	}
+	id := s.next
+	s.next++
+	s.inFlight[id] = cmd
+	s.wg.Add(1)
+
+	return func() {
+		if cmd.cancel != nil {
+			cmd.cancel()
+		}
+		s.mu.Lock()
+		delete(s.inFlight, id)
+		s.mu.Unlock()
+		close(cmd.done)
+		s.wg.Done()
+	}, nil
+}
+
+// Shutdown stops the LogRun, and all LogRuns derived from it using
+// With(), from accepting new commands, which fail with ErrShutdown. It
+// then waits for the commands in flight to complete until ctx is done,
+// kills those still running, and closes the Runner like Close(), so a
+// controller can exit on SIGTERM without orphaning remote work, e.g.,
+//
+//	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+//	defer cancel()
+//	err := r.Shutdown(ctx)
+//
+// The runner used by NewLocalLogRun() kills the commands still
+// running, along with their children for those run with a context or
+// a timeout, which run in their own process group. The runner used by NewRemoteLogRun() only kills those run
+// with a context, a timeout, or process tracking, and not on Windows
+// hosts, since only those are run through a shell that reports their
+// PID. Like the runner used by NewDockerLogRun(), it stops waiting for
+// the others and leaves them running. Commands of other runners are
+// left to the Runner when it is closed.
+// The error of ctx is returned if commands had to be killed, else the
+// error of Close(), if any.
+func (r *LogRun) Shutdown(ctx context.Context) error {
+	s := r.shutdown
+	if s == nil {
+		return r.Close()
+	}
+	s.mu.Lock()
+	s.closing = true
+	s.mu.Unlock()
+
+	idle := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(idle)
+	}()
+	var err error
+	select {
+	case <-idle:
+	case <-ctx.Done():
+		err = ctx.Err()
+		s.cancelInFlight()
+	}
+	if cerr := r.Close(); err == nil {
+		err = cerr
+	}
+
+	return err
+}
+
+// cancelInFlight kills the commands in flight that can be canceled and
+// waits for them to return.
+func (s *shutdownState) cancelInFlight() {
+	s.mu.Lock()
+	var canceled []*inFlightCmd
+	for _, cmd := range s.inFlight {
+		if cmd.cancel != nil {
+			cmd.cancel()
+			canceled = append(canceled, cmd)
+		}
+	}
+	s.mu.Unlock()
+	for _, cmd := range canceled {
+		<-cmd.done
+	}
+}
+
+// Shutdown shuts down the runners of the Pool concurrently like
+// LogRun.Shutdown() and returns the first error.
+func (p *Pool) Shutdown(ctx context.Context) error {
+	errs := make([]error, len(p.runners))
+	var wg sync.WaitGroup
+	for i, r := range p.runners {
+		wg.Add(1)
+		go func(i int, r *LogRun) {
+			defer wg.Done()
+			errs[i] = r.Shutdown(ctx)
+		}(i, r)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,168 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/apatters/go-logrun"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// startSleep runs "sleep seconds" using l in the background and waits
+// for it to start. The exit code is sent to the returned channel.
+func startSleep(t *testing.T, l *logrun.LogRun, seconds string) <-chan int {
+	started := make(chan int, 1)
+	done := make(chan int, 1)
+	go func() {
+		_, _, code := l.With(
+			logrun.WithPIDFunc(func(pid int) { started <- pid }),
+		).Run("sleep", seconds)
+		done <- code
+	}()
+	select {
+	case pid := <-started:
+		t.Logf("pid = %d", pid)
+	case <-time.After(5 * time.Second):
+		t.Fatal("command was not started")
+	}
+
+	return done
+}
+
+// testShutdown checks that Shutdown() waits for a command in flight
+// and rejects new commands.
+func testShutdown(t *testing.T, l *logrun.LogRun) {
+	done := startSleep(t, l, "0.2")
+	require.NoError(t, l.Shutdown(context.Background()))
+	assert.Equal(t, 0, <-done)
+
+	_, stderr, code := l.Run("true")
+	t.Logf("stderr = %q, code = %d", stderr, code)
+	assert.Equal(t, logrun.ExitErrorExecute, code)
+	assert.Contains(t, stderr, logrun.ErrShutdown.Error())
+}
+
+// testShutdownTimeout checks that Shutdown() kills the commands still
+// running when ctx is done.
+func testShutdownTimeout(t *testing.T, l *logrun.LogRun) {
+	done := startSleep(t, l, "30")
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := l.Shutdown(ctx)
+	t.Logf("err = %v", err)
+	assert.Equal(t, context.DeadlineExceeded, err)
+	assert.True(t, time.Since(start) < 5*time.Second)
+	select {
+	case code := <-done:
+		t.Logf("code = %d", code)
+		assert.Equal(t, logrun.ExitErrorExecute, code)
+	case <-time.After(5 * time.Second):
+		t.Fatal("command was not killed")
+	}
+}
+
+func TestLocalLogRun_Shutdown(t *testing.T) {
+	log, _, _ := newLogger()
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{LogFunc: log.Println})
+	testShutdown(t, l)
+}
+
+func TestLocalLogRun_ShutdownTimeout(t *testing.T) {
+	log, _, _ := newLogger()
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{LogFunc: log.Println})
+	testShutdownTimeout(t, l)
+}
+
+func TestLocalLogRun_ShutdownWith(t *testing.T) {
+	log, _, _ := newLogger()
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{LogFunc: log.Println})
+	c := l.With(logrun.WithEnv("FOO=bar"))
+	require.NoError(t, c.Shutdown(context.Background()))
+
+	_, _, code := l.Run("true")
+	assert.Equal(t, logrun.ExitErrorExecute, code)
+}
+
+func TestRemoteLogRun_Shutdown(t *testing.T) {
+	s := newTestSSHServer(t, nil)
+	defer s.Close()
+	log, _, _ := newLogger()
+	r, err := logrun.NewRemoteLogRun(logrun.RemoteConfig{
+		Credentials: s.Credentials(),
+		LogFunc:     log.Println,
+	})
+	require.NoError(t, err)
+	testShutdown(t, r)
+}
+
+func TestRemoteLogRun_ShutdownTimeout(t *testing.T) {
+	s := newTestSSHServer(t, nil)
+	defer s.Close()
+	log, _, _ := newLogger()
+	r, err := logrun.NewRemoteLogRun(logrun.RemoteConfig{
+		Credentials: s.Credentials(),
+		LogFunc:     log.Println,
+	})
+	require.NoError(t, err)
+	testShutdownTimeout(t, r)
+}
+
+func TestRemoteLogRun_ShutdownPlainCommand(t *testing.T) {
+	s := newTestSSHServer(t, nil)
+	defer s.Close()
+	r, err := logrun.NewRemoteLogRun(logrun.RemoteConfig{Credentials: s.Credentials()})
+	require.NoError(t, err)
+
+	// Commands without a context or timeout are sent as is, so
+	// shell builtins can be run.
+	_, stderr, code := r.Run("cd", "/")
+	assert.Equal(t, 0, code, stderr)
+
+	// Shutdown() stops waiting for them when ctx is done.
+	done := make(chan int, 1)
+	go func() {
+		_, _, code := r.Run("sleep", "30")
+		done <- code
+	}()
+	time.Sleep(200 * time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, r.Shutdown(ctx))
+	select {
+	case code := <-done:
+		assert.Equal(t, logrun.ExitErrorExecute, code)
+	case <-time.After(5 * time.Second):
+		t.Fatal("command was not abandoned")
+	}
+}
+
+func TestPool_Shutdown(t *testing.T) {
+	var configs []logrun.RemoteConfig
+	var hosts []string
+	for i := 0; i < 2; i++ {
+		s := newTestSSHServer(t, nil)
+		defer s.Close()
+		creds := s.Credentials()
+		configs = append(configs, logrun.RemoteConfig{Credentials: creds})
+		hosts = append(hosts, net.JoinHostPort(creds.Hostname, strconv.Itoa(creds.Port)))
+	}
+	p, err := logrun.NewRemotePool(configs, logrun.PoolConfig{})
+	require.NoError(t, err)
+	require.NoError(t, p.Shutdown(context.Background()))
+
+	results := p.Run("true")
+	for _, host := range hosts {
+		t.Logf("results[%s] = %+v", host, results[host])
+		assert.True(t, errors.Is(results[host].Err, logrun.ErrShutdown))
+	}
+}
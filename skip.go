@@ -0,0 +1,40 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun
+
+// SkipReason describes why a command was not run. It is recorded in
+// Results, LogEvents, and CommandStats, so reports can distinguish
+// commands that ran successfully from commands that never ran.
+type SkipReason string
+
+const (
+	// SkipNone is the SkipReason of commands that were run.
+	SkipNone SkipReason = ""
+
+	// SkipDryrun is the SkipReason of commands that were only
+	// logged because Dryrun is true.
+	SkipDryrun SkipReason = "dryrun"
+
+	// SkipCheckMode is the SkipReason of commands that were only
+	// recorded as changes because of check mode.
+	SkipCheckMode SkipReason = "check mode"
+
+	// SkipGuard is the SkipReason of commands skipped because of
+	// a guard set by OnlyIf() or Unless().
+	SkipGuard SkipReason = "guard"
+)
+
+// skipReason returns the reason commands that pass their guards are
+// not run, if any.
+func (r *LogRun) skipReason() SkipReason {
+	switch {
+	case r.Dryrun:
+		return SkipDryrun
+	case r.checking():
+		return SkipCheckMode
+	}
+
+	return SkipNone
+}
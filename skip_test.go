@@ -0,0 +1,63 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/apatters/go-logrun"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogRun_SkipReason(t *testing.T) {
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{})
+	l.SetRecorder(logrun.NewRecorder())
+
+	res, err := l.RunResult("/bin/true")
+	require.NoError(t, err)
+	assert.False(t, res.Skipped)
+	assert.Equal(t, logrun.SkipNone, res.SkipReason)
+
+	l.SetCheckMode(new(logrun.ChangeReport))
+	res, err = l.RunResult("/bin/true")
+	t.Logf("res = %s", res)
+	require.NoError(t, err)
+	assert.True(t, res.Skipped)
+	assert.Equal(t, logrun.SkipCheckMode, res.SkipReason)
+
+	// Dryrun takes precedence over check mode.
+	l.SetDryrun(true)
+	res, err = l.RunResult("/bin/true")
+	t.Logf("res = %s", res)
+	require.NoError(t, err)
+	assert.Equal(t, logrun.SkipDryrun, res.SkipReason)
+
+	l.SetCheckMode(nil)
+	l.SetDryrun(false)
+	res, err = l.With(logrun.Unless("true")).RunResult("/bin/true")
+	t.Logf("res = %s", res)
+	require.NoError(t, err)
+	assert.Equal(t, logrun.SkipGuard, res.SkipReason)
+
+	// Reports distinguish commands that ran from those that did
+	// not.
+	var reasons []logrun.SkipReason
+	for _, c := range l.Recorder().Commands() {
+		reasons = append(reasons, c.SkipReason)
+	}
+	assert.Equal(t, []logrun.SkipReason{
+		logrun.SkipNone,
+		logrun.SkipCheckMode,
+		logrun.SkipDryrun,
+		logrun.SkipNone, // The guard.
+		logrun.SkipGuard,
+	}, reasons)
+	data, err := json.Marshal(l.Recorder().Commands()[1])
+	require.NoError(t, err)
+	t.Logf("data = %s", data)
+	assert.Contains(t, string(data), `"skip_reason":"check mode"`)
+}
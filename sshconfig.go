@@ -0,0 +1,229 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/user"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// SSHHostConfig is the part of the OpenSSH client configuration of a
+// host used by remote LogRuns. Empty fields were not configured.
+type SSHHostConfig struct {
+	// Hostname is the real hostname or address to connect to
+	// (HostName).
+	Hostname string
+
+	// Port is the port to connect to (Port).
+	Port int
+
+	// User is the user to log in as (User).
+	User string
+
+	// IdentityFile is the first private key file (IdentityFile)
+	// with "~" expanded.
+	IdentityFile string
+
+	// ProxyJump is the comma separated list of jump hosts
+	// (ProxyJump), each of the form [user@]host[:port].
+	ProxyJump string
+}
+
+// ParseSSHConfig returns the configuration of host in the OpenSSH
+// client configuration read from r, see ssh_config(5). Like ssh, the
+// first value found for each keyword wins, so host specific
+// sections should come before general ones. Host patterns may use
+// "*", "?", and "!" negation. Match and Include directives are not
+// supported; the keywords in Match sections are ignored. The %h, %d,
+// %u, and %% tokens are expanded in HostName and IdentityFile.
+func ParseSSHConfig(r io.Reader, host string) (SSHHostConfig, error) {
+	var hc SSHHostConfig
+	seen := make(map[string]bool)
+	active := true
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value := splitSSHConfigLine(line)
+		key = strings.ToLower(key)
+		switch key {
+		case "host":
+			active = sshHostMatches(host, strings.Fields(value))
+			continue
+		case "match":
+			active = false
+			continue
+		}
+		if !active || seen[key] {
+			continue
+		}
+		switch key {
+		case "hostname":
+			hc.Hostname = expandSSHTokens(value, host)
+		case "port":
+			port, err := strconv.Atoi(value)
+			if err != nil {
+				return hc, fmt.Errorf("line %d: invalid port %q", n, value)
+			}
+			hc.Port = port
+		case "user":
+			hc.User = value
+		case "identityfile":
+			hc.IdentityFile = expandSSHTokens(value, host)
+		case "proxyjump":
+			if !strings.EqualFold(value, "none") {
+				hc.ProxyJump = value
+			}
+		default:
+			continue
+		}
+		seen[key] = true
+	}
+
+	return hc, scanner.Err()
+}
+
+// LookupSSHConfig returns the configuration of host in the OpenSSH
+// client configuration file at filename using ParseSSHConfig(). If
+// filename is empty, ~/.ssh/config is used and the zero SSHHostConfig
+// is returned if it does not exist.
+func LookupSSHConfig(filename string, host string) (SSHHostConfig, error) {
+	optional := filename == ""
+	if optional {
+		filename = expandSSHTokens("~/.ssh/config", host)
+	}
+	f, err := os.Open(filename)
+	if optional && os.IsNotExist(err) {
+		return SSHHostConfig{}, nil
+	}
+	if err != nil {
+		return SSHHostConfig{}, err
+	}
+	defer f.Close() // nolint: errcheck
+	hc, err := ParseSSHConfig(f, host)
+	if err != nil {
+		return hc, fmt.Errorf("%s: %w", filename, err)
+	}
+
+	return hc, nil
+}
+
+// splitSSHConfigLine splits a configuration line into its keyword and
+// value. The keyword is separated by whitespace and/or an equals
+// sign. Surrounding double quotes are removed from the value.
+func splitSSHConfigLine(line string) (string, string) {
+	i := strings.IndexAny(line, " \t=")
+	if i < 0 {
+		return line, ""
+	}
+	key := line[:i]
+	value := strings.TrimSpace(line[i:])
+	value = strings.TrimSpace(strings.TrimPrefix(value, "="))
+	if len(value) >= 2 && strings.HasPrefix(value, `"`) && strings.HasSuffix(value, `"`) {
+		value = value[1 : len(value)-1]
+	}
+
+	return key, value
+}
+
+// sshHostMatches returns true if host matches one of patterns and
+// none of the negated ones.
+func sshHostMatches(host string, patterns []string) bool {
+	matched := false
+	for _, p := range patterns {
+		negated := strings.HasPrefix(p, "!")
+		p = strings.TrimPrefix(p, "!")
+		ok, err := path.Match(strings.ToLower(p), strings.ToLower(host))
+		if err != nil || !ok {
+			continue
+		}
+		if negated {
+			return false
+		}
+		matched = true
+	}
+
+	return matched
+}
+
+// expandSSHTokens expands "~" and the %h, %d, %u, and %% tokens in s.
+func expandSSHTokens(s string, host string) string {
+	var home, username string
+	if u, err := user.Current(); err == nil {
+		home, username = u.HomeDir, u.Username
+	}
+	if s == "~" || strings.HasPrefix(s, "~/") {
+		s = filepath.Join(home, s[1:])
+	}
+	r := strings.NewReplacer("%%", "%", "%h", host, "%d", home, "%u", username)
+
+	return r.Replace(s)
+}
+
+// sshJump is a jump host used to reach a remote host.
+type sshJump struct {
+	credentials Credentials
+
+	// hostname is the real hostname of the jump host.
+	hostname string
+}
+
+// parseProxyJump parses a ProxyJump value into jump hosts. Each jump
+// host is resolved using lookup, e.g., to find its real hostname,
+// and authenticates like ssh, i.e., using ssh-agent and its
+// configured identity file or the default private key. The password
+// of the remote host is never sent to jump hosts.
+func parseProxyJump(value string, lookup func(string) (SSHHostConfig, error)) ([]sshJump, error) {
+	var jumps []sshJump
+	for _, hop := range strings.Split(value, ",") {
+		hop = strings.TrimPrefix(strings.TrimSpace(hop), "ssh://")
+		if hop == "" {
+			continue
+		}
+		var jump sshJump
+		if i := strings.LastIndex(hop, "@"); i >= 0 {
+			jump.credentials.Username, hop = hop[:i], hop[i+1:]
+		}
+		if host, port, err := net.SplitHostPort(hop); err == nil {
+			p, err := strconv.Atoi(port)
+			if err != nil {
+				return nil, fmt.Errorf("invalid jump host port in %q", value)
+			}
+			hop, jump.credentials.Port = host, p
+		}
+		jump.credentials.Hostname = hop
+		jump.hostname = hop
+		hc, err := lookup(hop)
+		if err != nil {
+			return nil, err
+		}
+		if hc.Hostname != "" {
+			jump.hostname = hc.Hostname
+		}
+		if jump.credentials.Port == 0 {
+			jump.credentials.Port = hc.Port
+		}
+		if jump.credentials.Username == "" {
+			jump.credentials.Username = hc.User
+		}
+		jump.credentials.PrivateKeyFilename = hc.IdentityFile
+		if err := fillCredentialDefaults(&jump.credentials); err != nil {
+			return nil, err
+		}
+		jumps = append(jumps, jump)
+	}
+
+	return jumps, nil
+}
@@ -0,0 +1,217 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/apatters/go-logrun"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+)
+
+const testSSHConfig = `# Production database.
+Host prod-db
+    HostName db1.example.com
+    Port 2222
+    User deploy
+    IdentityFile ~/.ssh/prod_key
+
+Host *.internal !bastion.internal
+    ProxyJump ops@bastion.internal:2200
+    User ops
+
+Host web?
+    HostName %h.example.com
+
+Match user root
+    User nobody
+
+Host *
+    User default
+    Port=22
+`
+
+func TestParseSSHConfig(t *testing.T) {
+	u, err := user.Current()
+	require.NoError(t, err)
+
+	hc, err := logrun.ParseSSHConfig(strings.NewReader(testSSHConfig), "prod-db")
+	t.Logf("prod-db = %+v", hc)
+	require.NoError(t, err)
+	assert.Equal(t, logrun.SSHHostConfig{
+		Hostname:     "db1.example.com",
+		Port:         2222,
+		User:         "deploy",
+		IdentityFile: filepath.Join(u.HomeDir, ".ssh", "prod_key"),
+	}, hc)
+
+	hc, err = logrun.ParseSSHConfig(strings.NewReader(testSSHConfig), "app.internal")
+	t.Logf("app.internal = %+v", hc)
+	require.NoError(t, err)
+	assert.Equal(t, logrun.SSHHostConfig{
+		Port:      22,
+		User:      "ops",
+		ProxyJump: "ops@bastion.internal:2200",
+	}, hc)
+
+	hc, err = logrun.ParseSSHConfig(strings.NewReader(testSSHConfig), "bastion.internal")
+	t.Logf("bastion.internal = %+v", hc)
+	require.NoError(t, err)
+	assert.Equal(t, logrun.SSHHostConfig{Port: 22, User: "default"}, hc)
+
+	hc, err = logrun.ParseSSHConfig(strings.NewReader(testSSHConfig), "web1")
+	t.Logf("web1 = %+v", hc)
+	require.NoError(t, err)
+	assert.Equal(t, "web1.example.com", hc.Hostname)
+
+	_, err = logrun.ParseSSHConfig(strings.NewReader("Port ssh\n"), "any")
+	t.Logf("err = %v", err)
+	assert.Error(t, err)
+}
+
+func writeTestSSHConfig(t *testing.T, dir string, format string, args ...interface{}) string {
+	filename := filepath.Join(dir, "config")
+	require.NoError(t, ioutil.WriteFile(filename, []byte(fmt.Sprintf(format, args...)), 0600))
+
+	return filename
+}
+
+func TestRemoteLogRun_SSHConfig(t *testing.T) {
+	s := newTestSSHServer(t, nil)
+	defer s.Close()
+	dir, err := ioutil.TempDir("", "logrun")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	filename := writeTestSSHConfig(t, dir, "Host testhost\n  HostName 127.0.0.1\n  Port %d\n  User tester\n", s.Port())
+
+	log, out, _ := newLogger()
+	r, err := logrun.NewRemoteLogRun(logrun.RemoteConfig{
+		LogFunc: log.Println,
+		Credentials: logrun.Credentials{
+			Hostname: "testhost",
+			Password: testSSHPassword,
+		},
+		SSHConfigFile: filename,
+	})
+	require.NoError(t, err)
+	defer r.Close() // nolint: errcheck
+	c := r.Config()
+	t.Logf("credentials = %+v", c.Credentials)
+	assert.Equal(t, "testhost", c.Credentials.Hostname)
+	assert.Equal(t, s.Port(), c.Credentials.Port)
+	assert.Equal(t, "tester", c.Credentials.Username)
+
+	stdout, stderr, code := r.Run("echo", "hello")
+	t.Logf("stdout = %q", stdout)
+	t.Logf("stderr = %q", stderr)
+	t.Logf("out = %q", out)
+	assert.Zero(t, code)
+	assert.Equal(t, "hello\n", stdout)
+	assert.Equal(t, "ssh tester@testhost echo hello\n", out.String())
+	assert.Equal(t, 1, s.Connections())
+}
+
+func TestRemoteLogRun_SSHConfigProxyJump(t *testing.T) {
+	// The jump host user only authenticates with a key and must
+	// not be sent the password of the remote host.
+	var jumpPassword int32
+	s := newTestSSHServer(t, func(config *ssh.ServerConfig) {
+		password := config.PasswordCallback
+		config.PasswordCallback = func(c ssh.ConnMetadata, pass []byte) (*ssh.Permissions, error) {
+			if c.User() == "jumper" {
+				atomic.StoreInt32(&jumpPassword, 1)
+				return nil, errPermissionDenied
+			}
+			return password(c, pass)
+		}
+		config.PublicKeyCallback = func(c ssh.ConnMetadata, _ ssh.PublicKey) (*ssh.Permissions, error) {
+			if c.User() != "jumper" {
+				return nil, errPermissionDenied
+			}
+			return nil, nil
+		}
+	})
+	defer s.Close()
+	dir, err := ioutil.TempDir("", "logrun")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	keyFile := writeTestSSHKey(t, dir)
+	filename := writeTestSSHConfig(t, dir,
+		"Host target\n  HostName 127.0.0.1\n  Port %[1]d\n  ProxyJump jump\n"+
+			"Host jump\n  HostName 127.0.0.1\n  Port %[1]d\n  User jumper\n  IdentityFile %[2]s\n",
+		s.Port(), keyFile)
+
+	r, err := logrun.NewRemoteLogRun(logrun.RemoteConfig{
+		Credentials: logrun.Credentials{
+			Hostname: "target",
+			Password: testSSHPassword,
+		},
+		SSHConfigFile: filename,
+	})
+	require.NoError(t, err)
+	defer r.Close() // nolint: errcheck
+
+	stdout, stderr, code := r.Shell("echo hello")
+	t.Logf("stdout = %q", stdout)
+	t.Logf("stderr = %q", stderr)
+	assert.Zero(t, code)
+	assert.Equal(t, "hello\n", stdout)
+	// One connection to the jump host and one forwarded through it.
+	assert.Equal(t, 2, s.Connections())
+	assert.Zero(t, atomic.LoadInt32(&jumpPassword))
+}
+
+func TestRemoteLogRun_JumpHosts(t *testing.T) {
+	s := newTestSSHServer(t, nil)
+	defer s.Close()
+	creds := s.Credentials()
+
+	// Two jump hosts: the first is dialed directly and the second
+	// and the remote host through the previous SSH connection.
+	r, err := logrun.NewRemoteLogRun(logrun.RemoteConfig{
+		Credentials: creds,
+		JumpHosts:   []logrun.Credentials{creds, creds},
+	})
+	require.NoError(t, err)
+	defer r.Close() // nolint: errcheck
+
+	stdout, stderr, code := r.Shell("echo hello")
+	t.Logf("stdout = %q", stdout)
+	t.Logf("stderr = %q", stderr)
+	assert.Zero(t, code)
+	assert.Equal(t, "hello\n", stdout)
+	assert.Equal(t, 3, s.Connections())
+
+	// Jump hosts require a hostname.
+	_, err = logrun.NewRemoteLogRun(logrun.RemoteConfig{
+		Credentials: creds,
+		JumpHosts:   []logrun.Credentials{{Port: creds.Port}},
+	})
+	t.Logf("err = %v", err)
+	assert.Error(t, err)
+
+	// Failures to reach a jump host name the jump host.
+	bad := creds
+	bad.Password = "wrong"
+	r, err = logrun.NewRemoteLogRun(logrun.RemoteConfig{
+		Credentials: creds,
+		JumpHosts:   []logrun.Credentials{bad},
+	})
+	require.NoError(t, err)
+	defer r.Close() // nolint: errcheck
+	_, stderr, code = r.Shell("echo hello")
+	t.Logf("stderr = %q", stderr)
+	assert.Equal(t, logrun.ExitErrorExecute, code)
+	assert.Contains(t, stderr, "jump host 127.0.0.1")
+}
@@ -0,0 +1,226 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/pem"
+	"io"
+	"io/ioutil"
+	"net"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/apatters/go-logrun"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+)
+
+const testSSHPassword = "secret"
+
+// testSSHServer is a minimal SSH server that runs exec requests
+// locally using /bin/sh, serves the local filesystem over SFTP, and
+// forwards TCP connections for jump host tests. It accepts any user
+// with testSSHPassword.
+type testSSHServer struct {
+	listener    net.Listener
+	config      *ssh.ServerConfig
+	hostKey     ssh.PublicKey
+	connections int32
+
+	mu    sync.Mutex
+	conns []net.Conn
+}
+
+// newTestSSHServer starts a testSSHServer on an IPv4 loopback port.
+// configure, if not nil, can modify the server configuration.
+func newTestSSHServer(t *testing.T, configure func(*ssh.ServerConfig)) *testSSHServer {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	signer, err := ssh.NewSignerFromKey(key)
+	require.NoError(t, err)
+	config := &ssh.ServerConfig{
+		PasswordCallback: func(c ssh.ConnMetadata, pass []byte) (*ssh.Permissions, error) {
+			if string(pass) != testSSHPassword {
+				return nil, errPermissionDenied
+			}
+			return nil, nil
+		},
+	}
+	config.AddHostKey(signer)
+	if configure != nil {
+		configure(config)
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	s := &testSSHServer{listener: l, config: config, hostKey: signer.PublicKey()}
+	go s.serve()
+
+	return s
+}
+
+// writeTestSSHKey writes a new private key to the file id_ecdsa in dir
+// and returns its path.
+func writeTestSSHKey(t *testing.T, dir string) string {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	filename := filepath.Join(dir, "id_ecdsa")
+	require.NoError(t, ioutil.WriteFile(filename, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), 0600))
+
+	return filename
+}
+
+type permissionDenied struct{}
+
+func (permissionDenied) Error() string { return "permission denied" }
+
+var errPermissionDenied = permissionDenied{}
+
+// Close stops the server.
+func (s *testSSHServer) Close() {
+	s.listener.Close() // nolint: errcheck
+}
+
+// HostKey returns the public host key of the server.
+func (s *testSSHServer) HostKey() ssh.PublicKey {
+	return s.hostKey
+}
+
+// Port returns the port the server listens on.
+func (s *testSSHServer) Port() int {
+	return s.listener.Addr().(*net.TCPAddr).Port
+}
+
+// Credentials returns credentials for connecting to the server.
+func (s *testSSHServer) Credentials() logrun.Credentials {
+	return logrun.Credentials{
+		Hostname: "127.0.0.1",
+		Port:     s.Port(),
+		Password: testSSHPassword,
+	}
+}
+
+// Connections returns the number of connections accepted so far.
+func (s *testSSHServer) Connections() int {
+	return int(atomic.LoadInt32(&s.connections))
+}
+
+// DropConnections closes the accepted connections as if the network
+// failed.
+func (s *testSSHServer) DropConnections() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, conn := range s.conns {
+		conn.Close() // nolint: errcheck
+	}
+	s.conns = nil
+}
+
+func (s *testSSHServer) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		atomic.AddInt32(&s.connections, 1)
+		s.mu.Lock()
+		s.conns = append(s.conns, conn)
+		s.mu.Unlock()
+		go s.handle(conn)
+	}
+}
+
+func (s *testSSHServer) handle(conn net.Conn) {
+	_, chans, reqs, err := ssh.NewServerConn(conn, s.config)
+	if err != nil {
+		conn.Close() // nolint: errcheck
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+	for newChan := range chans {
+		if newChan.ChannelType() == "direct-tcpip" {
+			go forwardTestTCPIP(newChan)
+			continue
+		}
+		if newChan.ChannelType() != "session" {
+			newChan.Reject(ssh.UnknownChannelType, "unknown channel type") // nolint: errcheck
+			continue
+		}
+		ch, chReqs, err := newChan.Accept()
+		if err != nil {
+			continue
+		}
+		go s.session(ch, chReqs)
+	}
+}
+
+func (s *testSSHServer) session(ch ssh.Channel, reqs <-chan *ssh.Request) {
+	defer ch.Close() // nolint: errcheck
+	for req := range reqs {
+		if req.Type == "subsystem" && string(req.Payload[4:]) == "sftp" {
+			req.Reply(true, nil) // nolint: errcheck
+			go ssh.DiscardRequests(reqs)
+			serveTestSFTP(ch)
+			return
+		}
+		if req.Type != "exec" || len(req.Payload) < 4 {
+			req.Reply(false, nil) // nolint: errcheck
+			continue
+		}
+		req.Reply(true, nil) // nolint: errcheck
+		n := binary.BigEndian.Uint32(req.Payload)
+		cmd := exec.Command("/bin/sh", "-c", string(req.Payload[4:4+n]))
+		cmd.Stdin = ch
+		cmd.Stdout = ch
+		cmd.Stderr = ch.Stderr()
+		status := uint32(0)
+		if err := cmd.Run(); err != nil {
+			status = 255
+			if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() >= 0 {
+				status = uint32(exitErr.ExitCode())
+			}
+		}
+		payload := make([]byte, 4)
+		binary.BigEndian.PutUint32(payload, status)
+		ch.SendRequest("exit-status", false, payload) // nolint: errcheck
+		return
+	}
+}
+
+// forwardTestTCPIP connects a direct-tcpip channel, as opened by
+// ssh.Client.Dial(), to the requested address.
+func forwardTestTCPIP(newChan ssh.NewChannel) {
+	p := &sftpTestParser{newChan.ExtraData()}
+	host := p.string()
+	port := p.uint32()
+	conn, err := net.Dial("tcp", net.JoinHostPort(host, strconv.Itoa(int(port))))
+	if err != nil {
+		newChan.Reject(ssh.ConnectionFailed, err.Error()) // nolint: errcheck
+		return
+	}
+	ch, reqs, err := newChan.Accept()
+	if err != nil {
+		conn.Close() // nolint: errcheck
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+	go func() {
+		io.Copy(ch, conn) // nolint: errcheck
+		ch.CloseWrite()   // nolint: errcheck
+	}()
+	io.Copy(conn, ch) // nolint: errcheck
+	conn.Close()      // nolint: errcheck
+	ch.Close()        // nolint: errcheck
+}
@@ -0,0 +1,139 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun
+
+import (
+	"context"
+	"sync"
+)
+
+// ProcessHandle is a command started by Start() or StartShell() that
+// is still under the control of the program, unlike a command started
+// by RunDetached().
+type ProcessHandle struct {
+	r       *LogRun
+	cancel  context.CancelFunc
+	started chan struct{}
+	done    chan struct{}
+
+	mu     sync.Mutex
+	pid    int
+	result Result
+}
+
+// Start first logs the command and then starts it like Run() without
+// waiting for it to complete. It returns once the command has started
+// or failed to start. Use the returned ProcessHandle to wait for,
+// signal, or kill the command. Only logging is performed if Dryrun is
+// true, in which case the returned ProcessHandle has already
+// completed, e.g.,
+//
+//	p, err := runner.Start("/usr/bin/socat", "TCP-LISTEN:8080,fork", "TCP:db:5432")
+//	if err != nil {
+//		return err
+//	}
+//	defer p.Kill()
+func (r *LogRun) Start(cmd string, args ...string) (_ *ProcessHandle, err error) {
+	r = r.beginHelper()
+	defer r.addErrorContext(&err, r.commandSeq())
+	return r.start(execSpec{cmd: cmd, args: args})
+}
+
+// StartShell first logs the command and then starts it in a shell
+// like Shell() without waiting for it to complete. See Start().
+func (r *LogRun) StartShell(cmd string) (_ *ProcessHandle, err error) {
+	r = r.beginHelper()
+	defer r.addErrorContext(&err, r.commandSeq())
+	return r.start(execSpec{cmd: r.shellCmd(cmd), shell: true})
+}
+
+func (r *LogRun) start(spec execSpec) (*ProcessHandle, error) {
+	p := &ProcessHandle{
+		r:       r,
+		started: make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	spec.ctx, p.cancel = context.WithCancel(context.Background())
+	pidFunc := r.call.pidFunc
+	var once sync.Once
+	spec.onStart = func(pid int) {
+		p.mu.Lock()
+		p.pid = pid
+		p.mu.Unlock()
+		if pidFunc != nil {
+			pidFunc(pid)
+		}
+		once.Do(func() { close(p.started) })
+	}
+	go func() {
+		defer close(p.done)
+		defer p.cancel()
+		res := r.logAndExecute(spec)
+		p.mu.Lock()
+		p.result = res
+		p.mu.Unlock()
+	}()
+	select {
+	case <-p.started:
+		return p, nil
+	case <-p.done:
+		if p.result.Err != nil {
+			return nil, p.result.Err
+		}
+		return p, nil
+	}
+}
+
+// PID returns the process ID of the command on the host. On remote
+// hosts it is also the process group ID of the command. Zero is
+// returned if the command was not run, e.g., because Dryrun is true.
+func (p *ProcessHandle) PID() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.pid
+}
+
+// Done returns a channel that is closed when the command completes.
+func (p *ProcessHandle) Done() <-chan struct{} {
+	return p.done
+}
+
+// Wait waits for the command to complete and returns its Result and
+// the error that prevented it from completing, if any, like
+// RunResult(). A command killed by Kill() returns
+// context.Canceled.
+func (p *ProcessHandle) Wait() (Result, error) {
+	<-p.done
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.result, p.result.Err
+}
+
+// Kill kills the command and any processes it started, including on
+// remote hosts, and waits for it to complete. Killing a command that
+// has already completed has no effect.
+func (p *ProcessHandle) Kill() {
+	p.cancel()
+	<-p.done
+}
+
+// Signal sends signal, e.g., "TERM" or "HUP", to the command using
+// SignalProcess(). Signals sent to a command that has already
+// completed are ignored.
+func (p *ProcessHandle) Signal(signal string) error {
+	pid := p.PID()
+	if pid == 0 {
+		return nil
+	}
+	select {
+	case <-p.done:
+		return nil
+	default:
+	}
+
+	return p.r.SignalProcess(pid, signal)
+}
@@ -0,0 +1,104 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/apatters/go-logrun"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalLogRun_Start(t *testing.T) {
+	log, out, _ := newLogger()
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{
+		LogFunc: log.Println,
+	})
+	p, err := l.Start("/bin/sh", "-c", "echo started; exit 4")
+	require.NoError(t, err)
+	t.Logf("pid = %d", p.PID())
+	assert.NotZero(t, p.PID())
+	res, err := p.Wait()
+	t.Logf("res = %+v", res)
+	t.Logf("out = %q", out)
+	require.NoError(t, err)
+	assert.Equal(t, 4, res.ExitCode)
+	assert.Equal(t, "started\n", res.Stdout)
+	assert.Equal(t, "/bin/sh -c 'echo started; exit 4'\n", out.String())
+
+	_, err = l.Start("/does/not/exist")
+	t.Logf("err = %v", err)
+	assert.Error(t, err)
+}
+
+func TestLocalLogRun_StartShellKill(t *testing.T) {
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{})
+	var pids []int
+	p, err := l.With(logrun.WithPIDFunc(func(pid int) { pids = append(pids, pid) })).StartShell("sleep 60")
+	require.NoError(t, err)
+	running, err := l.ProcessRunning(p.PID())
+	require.NoError(t, err)
+	assert.True(t, running)
+	assert.Equal(t, []int{p.PID()}, pids)
+
+	begin := time.Now()
+	p.Kill()
+	t.Logf("killed in %s", time.Since(begin))
+	assert.True(t, time.Since(begin) < 10*time.Second)
+	res, err := p.Wait()
+	t.Logf("res = %+v", res)
+	assert.Equal(t, context.Canceled, err)
+	select {
+	case <-p.Done():
+	default:
+		t.Error("Done() is not closed after Kill()")
+	}
+	p.Kill()
+	assert.NoError(t, p.Signal("TERM"))
+}
+
+func TestLocalLogRun_StartSignal(t *testing.T) {
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{})
+	p, err := l.StartShell("trap 'exit 7' TERM; while :; do sleep 0.1; done")
+	require.NoError(t, err)
+	time.Sleep(200 * time.Millisecond)
+	require.NoError(t, p.Signal("TERM"))
+	res, err := p.Wait()
+	t.Logf("res = %+v", res)
+	require.NoError(t, err)
+	assert.Equal(t, 7, res.ExitCode)
+}
+
+func TestLocalLogRun_StartDryrun(t *testing.T) {
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{})
+	l.SetDryrun(true)
+	p, err := l.Start("/bin/sleep", "60")
+	require.NoError(t, err)
+	assert.Zero(t, p.PID())
+	res, err := p.Wait()
+	require.NoError(t, err)
+	assert.True(t, res.Skipped)
+}
+
+func TestRemoteLogRun_StartShell(t *testing.T) {
+	s := newTestSSHServer(t, nil)
+	defer s.Close()
+
+	r, err := logrun.NewRemoteLogRun(logrun.RemoteConfig{
+		Credentials: s.Credentials(),
+	})
+	require.NoError(t, err)
+	p, err := r.StartShell("echo remote")
+	require.NoError(t, err)
+	t.Logf("pid = %d", p.PID())
+	assert.NotZero(t, p.PID())
+	res, err := p.Wait()
+	t.Logf("res = %+v", res)
+	require.NoError(t, err)
+	assert.Equal(t, "remote\n", res.Stdout)
+}
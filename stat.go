@@ -0,0 +1,264 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// StatCmd is the external command used by Stat() to get the
+	// attributes of a file. This command has been tested on
+	// RHEL/CentOS 7 and Ubuntu 18.04.
+	StatCmd = "/usr/bin/stat"
+
+	// StatCmdOptions are the command-line options added to StatCmd
+	// used to output the raw mode in hex, size, owner,
+	// modification time, and quoted name and link target of a
+	// file. This command and options has been tested on
+	// RHEL/CentOS 7 and Ubuntu 18.04.
+	StatCmdOptions = []string{
+		"--format",
+		"%f|%s|%U:%G|%Y|%N",
+	}
+
+	// BSDStatInfoCmdOptions are the command-line options added to
+	// StatCmd instead of StatCmdOptions on hosts with a BSD stat
+	// command, e.g., macOS. Used only when capability probing is
+	// enabled.
+	BSDStatInfoCmdOptions = []string{
+		"-f",
+		"%Xp|%z|%Su:%Sg|%m|%Y",
+	}
+)
+
+// FileInfo describes a file as returned by Stat().
+type FileInfo struct {
+	// Path is the path passed to Stat().
+	Path string
+
+	// Size is the size of the file in bytes. For symbolic links
+	// it is the length of the link target.
+	Size int64
+
+	// Mode is the type and permission bits of the file.
+	Mode os.FileMode
+
+	// Owner is the user and group owning the file separated by a
+	// colon, e.g., "root:root". Hosts using SFTP report numeric
+	// IDs, e.g., "0:0".
+	Owner string
+
+	// ModTime is the modification time of the file with a
+	// resolution of one second.
+	ModTime time.Time
+
+	// LinkTarget is the target of the file if it is a symbolic
+	// link.
+	LinkTarget string
+}
+
+// IsDir returns true if the file is a directory.
+func (fi FileInfo) IsDir() bool {
+	return fi.Mode.IsDir()
+}
+
+// IsRegular returns true if the file is a regular file.
+func (fi FileInfo) IsRegular() bool {
+	return fi.Mode.IsRegular()
+}
+
+// IsSymlink returns true if the file is a symbolic link.
+func (fi FileInfo) IsSymlink() bool {
+	return fi.Mode&os.ModeSymlink != 0
+}
+
+// Stat returns the type, size, mode, owner, modification time, and
+// link target of the file at path using a single stat command, SFTP
+// session, or, for local runners, the os package. Like os.Lstat(),
+// symbolic links are not followed. If path does not exist, the
+// returned error matches ErrNotFound. Other errors are reported like
+// FileExists(). Only logging is performed if Dryrun is true, in which
+// case the zero FileInfo is returned.
+func (r *LogRun) Stat(path string) (_ FileInfo, err error) {
+	r = r.beginHelper()
+	defer r.addErrorContext(&err, r.commandSeq())
+	fi, exists, err := r.statInfo(path, false)
+	if err != nil || r.Dryrun {
+		return FileInfo{}, err
+	}
+	if !exists {
+		return FileInfo{}, notFoundError("could not stat", path)
+	}
+
+	return fi, nil
+}
+
+// statInfo logs and performs a stat of p, following symbolic links if
+// follow is true. The returned bool is false if p does not exist.
+func (r *LogRun) statInfo(p string, follow bool) (FileInfo, bool, error) {
+	if remote := r.sftpRunner(); remote != nil {
+		return r.sftpStatInfo(remote, p, follow)
+	}
+	if local := r.localRunner(); local != nil {
+		return r.localStatInfo(local, p, follow)
+	}
+	fi := FileInfo{Path: p}
+	if r.windowsRunner() != nil {
+		return fi, false, fmt.Errorf("could not stat %s: not supported on Windows hosts", p)
+	}
+	caps, err := r.helperCapabilities()
+	if err != nil {
+		return fi, false, err
+	}
+	var cmdArgs []string
+	bsd := !caps.GNUStat && caps.BSDStat
+	switch {
+	case bsd && follow:
+		cmdArgs = append([]string{"-L"}, BSDStatInfoCmdOptions...)
+	case bsd:
+		cmdArgs = append([]string{}, BSDStatInfoCmdOptions...)
+	case follow:
+		cmdArgs = append([]string{"--dereference"}, StatCmdOptions...)
+	default:
+		cmdArgs = append([]string{}, StatCmdOptions...)
+	}
+	cmdArgs = append(cmdArgs, p)
+	r.logRun(StatCmd, cmdArgs...)
+	if r.Dryrun {
+		return fi, true, nil
+	}
+	stdout, stderr, code := r.run(StatCmd, cmdArgs...)
+	if code != 0 {
+		if strings.Contains(stderr, "No such file or directory") {
+			return fi, false, nil
+		}
+		return fi, false, commandAccessError(p, stderr, code)
+	}
+	if err := parseStatInfo(&fi, strings.TrimRight(stdout, "\r\n"), bsd); err != nil {
+		return fi, false, err
+	}
+
+	return fi, true, nil
+}
+
+// parseStatInfo parses the output of StatCmd run with StatCmdOptions
+// or, if bsd is true, BSDStatInfoCmdOptions into fi.
+func parseStatInfo(fi *FileInfo, output string, bsd bool) error {
+	fields := strings.SplitN(output, "|", 5)
+	if len(fields) != 5 {
+		return fmt.Errorf("could not stat %s: unexpected output %q", fi.Path, output)
+	}
+	mode, err := strconv.ParseUint(fields[0], 16, 32)
+	if err != nil {
+		return fmt.Errorf("could not stat %s: invalid mode %q", fi.Path, fields[0])
+	}
+	size, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil {
+		return fmt.Errorf("could not stat %s: invalid size %q", fi.Path, fields[1])
+	}
+	mtime, err := strconv.ParseInt(fields[3], 10, 64)
+	if err != nil {
+		return fmt.Errorf("could not stat %s: invalid modification time %q", fi.Path, fields[3])
+	}
+	fi.Mode = sftpFileMode(uint32(mode))
+	fi.Size = size
+	fi.Owner = fields[2]
+	fi.ModTime = time.Unix(mtime, 0)
+	if !fi.IsSymlink() {
+		return nil
+	}
+	if bsd {
+		fi.LinkTarget = fields[4]
+		return nil
+	}
+	// GNU stat quotes the name and the target, e.g.,
+	// 'current' -> '/srv/app/releases/42'.
+	if i := strings.LastIndex(fields[4], " -> "); i >= 0 {
+		fi.LinkTarget = unquoteStatName(fields[4][i+4:])
+	}
+
+	return nil
+}
+
+// unquoteStatName removes the quotes GNU stat puts around the names
+// output by %N.
+func unquoteStatName(s string) string {
+	if len(s) >= 2 && (s[0] == '\'' || s[0] == '"') && s[len(s)-1] == s[0] {
+		s = s[1 : len(s)-1]
+	}
+
+	return strings.Replace(s, `'\''`, "'", -1)
+}
+
+func (r *LogRun) localStatInfo(local *localRunner, p string, follow bool) (FileInfo, bool, error) {
+	fi := FileInfo{Path: p}
+	r.log("stat " + p)
+	if r.Dryrun {
+		return fi, true, nil
+	}
+	full := r.localPath(local, p)
+	stat := os.Lstat
+	if follow {
+		stat = os.Stat
+	}
+	info, err := stat(full)
+	if os.IsNotExist(err) {
+		return fi, false, nil
+	}
+	if err != nil {
+		return fi, false, accessError(p, err)
+	}
+	fi.Size = info.Size()
+	fi.Mode = info.Mode()
+	fi.ModTime = info.ModTime().Truncate(time.Second)
+	fi.Owner = fileOwnerName(info)
+	if fi.IsSymlink() {
+		if fi.LinkTarget, err = os.Readlink(full); err != nil {
+			return fi, false, accessError(p, err)
+		}
+	}
+
+	return fi, true, nil
+}
+
+func (r *LogRun) sftpStatInfo(remote *remoteRunner, p string, follow bool) (FileInfo, bool, error) {
+	fi := FileInfo{Path: p}
+	r.log(remote.formatSFTP("stat", p))
+	if r.Dryrun {
+		return fi, true, nil
+	}
+	var attrs sftpFileAttrs
+	err := remote.withSFTP(func(c *sftpClient) error {
+		var err error
+		if follow {
+			attrs, err = c.stat(p)
+		} else {
+			attrs, err = c.lstat(p)
+		}
+		if err == nil && attrs.mode&os.ModeSymlink != 0 {
+			fi.LinkTarget, err = c.readlink(p)
+		}
+		return err
+	})
+	if sftpNotExist(err) {
+		return fi, false, nil
+	}
+	if err != nil {
+		return fi, false, accessError(p, err)
+	}
+	fi.Size = int64(attrs.size)
+	fi.Mode = attrs.mode
+	fi.ModTime = attrs.modTime
+	if attrs.hasOwner {
+		fi.Owner = fmt.Sprintf("%d:%d", attrs.uid, attrs.gid)
+	}
+
+	return fi, true, nil
+}
@@ -0,0 +1,106 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/apatters/go-logrun"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testStat stats a file, a directory, and a symbolic link using r and
+// returns the owner reported for the file.
+func testStat(t *testing.T, r *logrun.LogRun) string {
+	tmpDir, err := ioutil.TempDir("", "logrun")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmpDir)
+	file := filepath.Join(tmpDir, "it's a file")
+	require.NoError(t, ioutil.WriteFile(file, []byte("hello\n"), 0640))
+	require.NoError(t, os.Chmod(file, 0640))
+	mtime := time.Unix(1500000000, 0)
+	require.NoError(t, os.Chtimes(file, mtime, mtime))
+	link := filepath.Join(tmpDir, "current")
+	require.NoError(t, os.Symlink(file, link))
+
+	fi, err := r.Stat(file)
+	require.NoError(t, err)
+	t.Logf("fi = %+v", fi)
+	assert.Equal(t, file, fi.Path)
+	assert.True(t, fi.IsRegular())
+	assert.Equal(t, int64(6), fi.Size)
+	assert.Equal(t, os.FileMode(0640), fi.Mode)
+	assert.True(t, mtime.Equal(fi.ModTime))
+	assert.NotEmpty(t, fi.Owner)
+	assert.Empty(t, fi.LinkTarget)
+
+	dir, err := r.Stat(tmpDir)
+	require.NoError(t, err)
+	assert.True(t, dir.IsDir())
+
+	fi, err = r.Stat(link)
+	require.NoError(t, err)
+	t.Logf("fi = %+v", fi)
+	assert.True(t, fi.IsSymlink())
+	assert.Equal(t, file, fi.LinkTarget)
+	exists, err := r.FileExists(link)
+	assert.NoError(t, err)
+	assert.True(t, exists)
+
+	_, err = r.Stat(filepath.Join(tmpDir, "missing"))
+	t.Logf("err = %v", err)
+	assert.True(t, errors.Is(err, logrun.ErrNotFound))
+
+	return fi.Owner
+}
+
+func TestLocalLogRun_Stat(t *testing.T) {
+	log, out, _ := newLogger()
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{LogFunc: log.Println})
+	owner := testStat(t, l)
+	assert.Contains(t, out.String(), "stat ")
+	assert.Contains(t, owner, ":")
+}
+
+func TestRemoteLogRun_Stat(t *testing.T) {
+	s := newTestSSHServer(t, nil)
+	defer s.Close()
+	log, out, _ := newLogger()
+	r, err := logrun.NewRemoteLogRun(logrun.RemoteConfig{
+		LogFunc:     log.Println,
+		Credentials: s.Credentials(),
+	})
+	require.NoError(t, err)
+	owner := testStat(t, r)
+	t.Logf("out = %q", out)
+	assert.Contains(t, out.String(), logrun.StatCmd+" --format ")
+	local, err := logrun.NewLocalLogRun(logrun.LocalConfig{}).Stat("/")
+	require.NoError(t, err)
+	assert.Equal(t, local.Owner, owner)
+}
+
+func TestRemoteLogRun_SFTPStat(t *testing.T) {
+	s := newTestSSHServer(t, nil)
+	defer s.Close()
+	r, output := newSFTPTestLogRun(t, s)
+	owner := testStat(t, r)
+	assert.Regexp(t, `^\d+:\d+$`, owner)
+	assert.Contains(t, output(), "sftp ")
+}
+
+func TestLocalLogRun_StatDryrun(t *testing.T) {
+	log, out, _ := newLogger()
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{LogFunc: log.Println, Dryrun: true})
+	fi, err := l.Stat("/does/not/exist")
+	assert.NoError(t, err)
+	assert.Equal(t, logrun.FileInfo{}, fi)
+	assert.Equal(t, "stat /does/not/exist\n", out.String())
+}
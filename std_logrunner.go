@@ -4,6 +4,11 @@
 
 package logrun
 
+import (
+	"context"
+	"os"
+)
+
 var (
 	// The standard runner is used to run local commands without
 	// the need to explicitly use a constructor.
@@ -16,6 +21,25 @@ func SetLogFunc(f LogFunc) {
 	std.SetLogFunc(f)
 }
 
+// SetLogHook sets the hook that receives structured log events by
+// calling the standard run logger's SetLogHook() method.
+func SetLogHook(h LogHook) {
+	std.SetLogHook(h)
+}
+
+// SetResultLogFunc sets the logging function used to log the outcome
+// of commands by calling the standard run logger's SetResultLogFunc()
+// method.
+func SetResultLogFunc(f LogFunc) {
+	std.SetResultLogFunc(f)
+}
+
+// Config returns a snapshot of the effective configuration of the
+// standard runner.
+func Config() EffectiveConfig {
+	return std.Config()
+}
+
 // SetDryrun is used to enable/disable whether commands are just
 // logged and not executed by calling the run logger's SetDryrun()
 // method.
@@ -30,6 +54,55 @@ func Run(cmd string, args ...string) (string, string, int) {
 	return std.Run(cmd, args...)
 }
 
+// RunContext runs a command like Run() using the standard runner but
+// kills it if ctx is done before it completes.
+func RunContext(ctx context.Context, cmd string, args ...string) (string, string, int) {
+	return std.RunContext(ctx, cmd, args...)
+}
+
+// RunStream runs a command like Run() using the standard runner but
+// passes its output to h line by line as it is produced.
+func RunStream(h StreamHandlers, cmd string, args ...string) (int, error) {
+	return std.RunStream(h, cmd, args...)
+}
+
+// RunResult runs a command like Run() using the standard runner and
+// returns a Result and the error that prevented it from being run, if
+// any.
+func RunResult(cmd string, args ...string) (Result, error) {
+	return std.RunResult(cmd, args...)
+}
+
+// RunCombined runs a command like Run() using the standard runner and
+// returns its standard output and standard error interleaved.
+func RunCombined(cmd string, args ...string) (string, int) {
+	return std.RunCombined(cmd, args...)
+}
+
+// RunLines runs a command like Run() using the standard runner and
+// returns its standard output split into trimmed, non-blank lines.
+func RunLines(cmd string, args ...string) ([]string, string, int) {
+	return std.RunLines(cmd, args...)
+}
+
+// RunTrimmed runs a command like Run() using the standard runner and
+// returns its output with leading and trailing white space removed.
+func RunTrimmed(cmd string, args ...string) (string, string, int) {
+	return std.RunTrimmed(cmd, args...)
+}
+
+// Start starts a command without waiting for it to complete using
+// the standard runner.
+func Start(cmd string, args ...string) (*ProcessHandle, error) {
+	return std.Start(cmd, args...)
+}
+
+// RunLine splits a command line into words without using a shell and
+// runs it using the standard runner's RunLine() method.
+func RunLine(line string) (string, string, int) {
+	return std.RunLine(line)
+}
+
 // FormatRun returns a string representation of the what command would
 // be run using the standard runner's Run() method. Useful for logging
 // commands.
@@ -37,6 +110,12 @@ func FormatRun(cmd string, args ...string) string {
 	return std.FormatRun(cmd, args...)
 }
 
+// FormatRunCommand is like FormatRun() but returns the command as a
+// Command using the standard runner's FormatRunCommand() method.
+func FormatRunCommand(cmd string, args ...string) Command {
+	return std.FormatRunCommand(cmd, args...)
+}
+
 // Shell runs a command in a shell using the standard runner. The
 // command is passed to the shell as the -c option, so just about any
 // shell code that can be used on the command-line will be passed to
@@ -46,6 +125,53 @@ func Shell(cmd string) (string, string, int) {
 	return std.Shell(cmd)
 }
 
+// ShellContext runs a command in a shell like Shell() using the
+// standard runner but kills it if ctx is done before it completes.
+func ShellContext(ctx context.Context, cmd string) (string, string, int) {
+	return std.ShellContext(ctx, cmd)
+}
+
+// ShellStream runs a command in a shell like Shell() using the
+// standard runner but passes its output to h line by line as it is
+// produced.
+func ShellStream(h StreamHandlers, cmd string) (int, error) {
+	return std.ShellStream(h, cmd)
+}
+
+// ShellResult runs a command in a shell like Shell() using the
+// standard runner and returns a Result and the error that prevented
+// it from being run, if any.
+func ShellResult(cmd string) (Result, error) {
+	return std.ShellResult(cmd)
+}
+
+// ShellCombined runs a command in a shell like Shell() using the
+// standard runner and returns its standard output and standard error
+// interleaved.
+func ShellCombined(cmd string) (string, int) {
+	return std.ShellCombined(cmd)
+}
+
+// ShellLines runs a command in a shell like Shell() using the standard
+// runner and returns its standard output split into trimmed, non-blank
+// lines.
+func ShellLines(cmd string) ([]string, string, int) {
+	return std.ShellLines(cmd)
+}
+
+// ShellTrimmed runs a command in a shell like Shell() using the
+// standard runner and returns its output with leading and trailing
+// white space removed.
+func ShellTrimmed(cmd string) (string, string, int) {
+	return std.ShellTrimmed(cmd)
+}
+
+// StartShell starts a command in a shell without waiting for it to
+// complete using the standard runner.
+func StartShell(cmd string) (*ProcessHandle, error) {
+	return std.StartShell(cmd)
+}
+
 // FormatShell returns a string representation of the what command
 // would be run using the standard runner's Shell() method. Useful
 // for logging commands.
@@ -53,6 +179,24 @@ func FormatShell(cmd string) string {
 	return std.FormatShell(cmd)
 }
 
+// FormatShellCommand is like FormatShell() but returns the command as
+// a Command using the standard runner's FormatShellCommand() method.
+func FormatShellCommand(cmd string) Command {
+	return std.FormatShellCommand(cmd)
+}
+
+// RunTemplate renders a command template and runs it without a
+// shell using the standard runner's RunTemplate() method.
+func RunTemplate(t *CommandTemplate, data interface{}) (string, string, int) {
+	return std.RunTemplate(t, data)
+}
+
+// ShellTemplate renders a command template and runs it in a shell
+// using the standard runner's ShellTemplate() method.
+func ShellTemplate(t *CommandTemplate, data interface{}) (string, string, int) {
+	return std.ShellTemplate(t, data)
+}
+
 // FileExists returns true if filename exists and is a regular file
 // using the standard log runner's FileExist() method.
 func FileExists(filename string) (bool, error) {
@@ -65,14 +209,184 @@ func DirExists(dirname string) (bool, error) {
 	return std.DirExists(dirname)
 }
 
+// Stat returns the attributes of a file using the standard log
+// runner's Stat() method.
+func Stat(path string) (FileInfo, error) {
+	return std.Stat(path)
+}
+
+// Checksum returns the digest of a file computed on the host using
+// the standard log runner's Checksum() method.
+func Checksum(path string, algo ChecksumAlgorithm) (string, error) {
+	return std.Checksum(path, algo)
+}
+
 // Glob returns a list of files matching a glob pattern using the
 // standard log runner's Glob() method.
 func Glob(pattern string) ([]string, error) {
 	return std.Glob(pattern)
 }
 
+// GlobWithOptions returns a sorted and limited list of files matching
+// a shell glob pattern using the standard log runner's
+// GlobWithOptions() method.
+func GlobWithOptions(pattern string, opts GlobOptions) ([]string, error) {
+	return std.GlobWithOptions(pattern, opts)
+}
+
 // Rsync copies files/directories using the rsync command by calling
 // the standard log runner's Rsync() method().
 func Rsync(src string, dest string) error {
 	return std.Rsync(src, dest)
 }
+
+// RsyncWithOptions copies files/directories using the rsync command
+// with per-call options by calling the standard log runner's
+// RsyncWithOptions() method.
+func RsyncWithOptions(src string, dest string, opts RsyncOptions) error {
+	return std.RsyncWithOptions(src, dest, opts)
+}
+
+// Upload copies a file from the controller to the host using the
+// standard runner's Upload() method.
+func Upload(localPath string, remotePath string) error {
+	return std.Upload(localPath, remotePath)
+}
+
+// Download copies a file from the host to the controller using the
+// standard runner's Download() method.
+func Download(remotePath string, localPath string) error {
+	return std.Download(remotePath, localPath)
+}
+
+// CollectArtifacts copies the files matching glob patterns to a local
+// directory using the standard log runner's CollectArtifacts() method.
+func CollectArtifacts(patterns []string, destDir string) ([]string, error) {
+	return std.CollectArtifacts(patterns, destDir)
+}
+
+// PushFile copies a local file to the host using the standard log
+// runner's PushFile() method.
+func PushFile(localPath string, dest string, mode os.FileMode) (bool, error) {
+	return std.PushFile(localPath, dest, mode)
+}
+
+// GetFileString returns the contents of a file using the standard
+// log runner's GetFileString() method.
+func GetFileString(path string) (string, error) {
+	return std.GetFileString(path)
+}
+
+// ReadFile returns the contents of a file as bytes using the standard
+// log runner's ReadFile() method.
+func ReadFile(path string) ([]byte, error) {
+	return std.ReadFile(path)
+}
+
+// WriteFile writes data to a file using the standard log runner's
+// WriteFile() method.
+func WriteFile(path string, data []byte, mode os.FileMode) error {
+	return std.WriteFile(path, data, mode)
+}
+
+// MkdirAll creates a directory and any missing parents using the
+// standard log runner's MkdirAll() method.
+func MkdirAll(path string, mode os.FileMode) error {
+	return std.MkdirAll(path, mode)
+}
+
+// Remove removes a file or empty directory using the standard log
+// runner's Remove() method.
+func Remove(path string) error {
+	return std.Remove(path)
+}
+
+// RemoveAll removes a path and everything it contains using the
+// standard log runner's RemoveAll() method.
+func RemoveAll(path string) error {
+	return std.RemoveAll(path)
+}
+
+// Chmod changes the permission bits of a file using the standard log
+// runner's Chmod() method.
+func Chmod(path string, mode os.FileMode) error {
+	return std.Chmod(path, mode)
+}
+
+// Chown changes the owner of a file using the standard log runner's
+// Chown() method.
+func Chown(path string, owner string) error {
+	return std.Chown(path, owner)
+}
+
+// Symlink creates a symbolic link using the standard log runner's
+// Symlink() method.
+func Symlink(oldname string, newname string) error {
+	return std.Symlink(oldname, newname)
+}
+
+// PutFileString writes content to a file if it has changed using the
+// standard log runner's PutFileString() method.
+func PutFileString(path string, content string, mode os.FileMode) (bool, error) {
+	return std.PutFileString(path, content, mode)
+}
+
+// PutFileDiff writes content to a file if it has changed and returns
+// the diff using the standard log runner's PutFileDiff() method.
+func PutFileDiff(path string, content string, mode os.FileMode) (bool, string, error) {
+	return std.PutFileDiff(path, content, mode)
+}
+
+// EditFile rewrites a file with the contents returned by edit using
+// the standard log runner's EditFile() method.
+func EditFile(path string, mode os.FileMode, edit func(content string) (string, error)) (bool, error) {
+	return std.EditFile(path, mode, edit)
+}
+
+// LockFile takes the lock for editing a file using the standard log
+// runner's LockFile() method.
+func LockFile(path string) (func() error, error) {
+	return std.LockFile(path)
+}
+
+// PushDir changes the working directory of the commands run by the
+// standard log runner using its PushDir() method.
+func PushDir(dir string) {
+	std.PushDir(dir)
+}
+
+// PopDir restores the previous working directory of the standard log
+// runner using its PopDir() method.
+func PopDir() (string, error) {
+	return std.PopDir()
+}
+
+// Dir returns the working directory of the standard log runner using
+// its Dir() method.
+func Dir() string {
+	return std.Dir()
+}
+
+// BeginSection starts a named section in the log of the standard log
+// runner using its BeginSection() method.
+func BeginSection(name string) {
+	std.BeginSection(name)
+}
+
+// EndSection ends the current section of the standard log runner
+// using its EndSection() method.
+func EndSection() error {
+	return std.EndSection()
+}
+
+// WithSection runs f in a named section of the standard log runner
+// using its WithSection() method.
+func WithSection(name string, f func() error) error {
+	return std.WithSection(name, f)
+}
+
+// Section returns the current section of the standard log runner
+// using its Section() method.
+func Section() string {
+	return std.Section()
+}
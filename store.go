@@ -0,0 +1,249 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// resultStoreExt is the extension of the files written by
+// JSONResultStore.
+const resultStoreExt = ".jsonl"
+
+// StoredResult is a Result persisted by a ResultStore along with the
+// run it belongs to. Durations are in nanoseconds when encoded as
+// JSON.
+type StoredResult struct {
+	RunID       string        `json:"run_id"`
+	Host        string        `json:"host"`
+	Section     string        `json:"section,omitempty"`
+	Command     string        `json:"command"`
+	StartTime   time.Time     `json:"start_time"`
+	Duration    time.Duration `json:"duration"`
+	ExitCode    int           `json:"exit_code"`
+	Stdout      string        `json:"stdout,omitempty"`
+	Stderr      string        `json:"stderr,omitempty"`
+	StderrLines StderrLines   `json:"stderr_lines,omitempty"`
+	Skipped     bool          `json:"skipped,omitempty"`
+	SkipReason  SkipReason    `json:"skip_reason,omitempty"`
+	Error       string        `json:"error,omitempty"`
+}
+
+// Failed returns true if the command could not be run or exited with
+// a non-zero exit code.
+func (res StoredResult) Failed() bool {
+	return res.Error != "" || (!res.Skipped && res.ExitCode != 0)
+}
+
+// ResultStore persists the Results of the commands run by a LogRun,
+// so they can be inspected after the run has finished. See
+// SetResultStore().
+type ResultStore interface {
+	SaveResult(res StoredResult) error
+}
+
+// ResultQuery selects stored results. Empty fields match every
+// result.
+type ResultQuery struct {
+	// Host matches results of the host.
+	Host string
+
+	// Section matches results of commands run in the section.
+	Section string
+
+	// Command matches results of commands containing the string.
+	Command string
+
+	// Failed matches only the results of failed commands.
+	Failed bool
+}
+
+// Matches returns true if res is selected by q.
+func (q ResultQuery) Matches(res StoredResult) bool {
+	switch {
+	case q.Host != "" && res.Host != q.Host:
+		return false
+	case q.Section != "" && res.Section != q.Section:
+		return false
+	case q.Command != "" && !strings.Contains(res.Command, q.Command):
+		return false
+	case q.Failed && !res.Failed():
+		return false
+	}
+
+	return true
+}
+
+// JSONResultStore is a ResultStore that appends results as JSON lines
+// to a file per run in a directory, e.g., "results/20190314-151502.jsonl".
+// It is safe for concurrent use, so one store can be shared by the
+// runners of a Pool.
+type JSONResultStore struct {
+	dir   string
+	runID string
+
+	mu sync.Mutex
+	f  *os.File
+}
+
+// NewJSONResultStore is the constructor for JSONResultStore. Results
+// are saved in dir, which is created if needed, under runID. If runID
+// is empty, one is generated from the current time.
+func NewJSONResultStore(dir string, runID string) (*JSONResultStore, error) {
+	if runID == "" {
+		runID = time.Now().UTC().Format("20060102-150405.000000")
+	}
+	if err := checkRunID(runID); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("could not create result store: %w", err)
+	}
+
+	return &JSONResultStore{dir: dir, runID: runID}, nil
+}
+
+// RunID returns the ID of the run results are saved under.
+func (s *JSONResultStore) RunID() string {
+	return s.runID
+}
+
+// SaveResult appends res to the file of the run. The RunID of res is
+// set to the run ID of the store.
+func (s *JSONResultStore) SaveResult(res StoredResult) error {
+	res.RunID = s.runID
+	data, err := json.Marshal(res)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.f == nil {
+		f, err := os.OpenFile(s.runFile(s.runID), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+		if err != nil {
+			return err
+		}
+		s.f = f
+	}
+	_, err = s.f.Write(append(data, '\n'))
+
+	return err
+}
+
+// Close closes the file of the run. Later results reopen it.
+func (s *JSONResultStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.f == nil {
+		return nil
+	}
+	err := s.f.Close()
+	s.f = nil
+
+	return err
+}
+
+// Runs returns the IDs of the runs in the directory of the store in
+// sorted order.
+func (s *JSONResultStore) Runs() ([]string, error) {
+	matches, err := filepath.Glob(filepath.Join(s.dir, "*"+resultStoreExt))
+	if err != nil {
+		return nil, err
+	}
+	var runs []string
+	for _, m := range matches {
+		runs = append(runs, strings.TrimSuffix(filepath.Base(m), resultStoreExt))
+	}
+	sort.Strings(runs)
+
+	return runs, nil
+}
+
+// Query returns the results of the run runID selected by q in the
+// order they were saved.
+func (s *JSONResultStore) Query(runID string, q ResultQuery) ([]StoredResult, error) {
+	if err := checkRunID(runID); err != nil {
+		return nil, err
+	}
+	f, err := os.Open(s.runFile(runID))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, notFoundError("run", runID)
+		}
+		return nil, err
+	}
+	defer f.Close() // nolint: errcheck
+
+	var results []StoredResult
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 64*1024*1024)
+	for n := 1; scanner.Scan(); n++ {
+		var res StoredResult
+		if err := json.Unmarshal(scanner.Bytes(), &res); err != nil {
+			return nil, fmt.Errorf("run %s line %d: %w", runID, n, err)
+		}
+		if q.Matches(res) {
+			results = append(results, res)
+		}
+	}
+
+	return results, scanner.Err()
+}
+
+func (s *JSONResultStore) runFile(runID string) string {
+	return filepath.Join(s.dir, runID+resultStoreExt)
+}
+
+// checkRunID returns an error if runID cannot be used as a file name.
+func checkRunID(runID string) error {
+	if runID == "" || runID == "." || runID == ".." || strings.ContainsAny(runID, `/\`) {
+		return fmt.Errorf("invalid run ID %q", runID)
+	}
+
+	return nil
+}
+
+// SetResultStore sets the ResultStore the Results of the commands run
+// using Run(), Shell(), and their variants are saved to, including
+// their output. Set it to nil, the default, to not persist results.
+// Results that cannot be saved are logged.
+func (r *LogRun) SetResultStore(store ResultStore) {
+	r.resultStore = store
+}
+
+// storeResult saves res, which started at start, to the ResultStore,
+// if any.
+func (r *LogRun) storeResult(res Result, start time.Time) {
+	if r.resultStore == nil {
+		return
+	}
+	stored := StoredResult{
+		Host:        res.Host,
+		Section:     r.Section(),
+		Command:     res.Command,
+		StartTime:   start,
+		Duration:    res.Duration,
+		ExitCode:    res.ExitCode,
+		Stdout:      res.Stdout,
+		Stderr:      res.Stderr,
+		StderrLines: res.StderrLines,
+		Skipped:     res.Skipped,
+		SkipReason:  res.SkipReason,
+	}
+	if res.Err != nil {
+		stored.Error = res.Err.Error()
+	}
+	if err := r.resultStore.SaveResult(stored); err != nil {
+		r.log(trf("could not save result of %s: %s", res.Command, err))
+	}
+}
@@ -0,0 +1,74 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/apatters/go-logrun"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJSONResultStore(t *testing.T) {
+	dir := t.TempDir()
+	store, err := logrun.NewJSONResultStore(dir, "deploy-42")
+	require.NoError(t, err)
+	assert.Equal(t, "deploy-42", store.RunID())
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{ResultStore: store})
+
+	l.Shell("echo hello")
+	l.BeginSection("checks")
+	l.Shell("echo oops >&2; exit 3")
+	l.Run("/does/not/exist")
+	require.NoError(t, l.EndSection())
+	l.SetDryrun(true)
+	l.Run("/bin/rm", "-rf", "/tmp/x")
+	require.NoError(t, store.Close())
+
+	// The results can be inspected later using another store.
+	later, err := logrun.NewJSONResultStore(dir, "")
+	require.NoError(t, err)
+	runs, err := later.Runs()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"deploy-42"}, runs)
+
+	results, err := later.Query("deploy-42", logrun.ResultQuery{})
+	for i, res := range results {
+		t.Logf("results[%d] = %+v", i, res)
+	}
+	require.NoError(t, err)
+	require.Len(t, results, 4)
+	assert.Equal(t, "deploy-42", results[0].RunID)
+	assert.Equal(t, l.Host().String(), results[0].Host)
+	assert.Equal(t, "hello\n", results[0].Stdout)
+	assert.False(t, results[0].StartTime.IsZero())
+	assert.Equal(t, "oops\n", results[1].Stderr)
+	assert.Equal(t, logrun.StderrLines{{Text: "oops", Level: logrun.StderrError}}, results[1].StderrLines)
+	assert.Equal(t, 3, results[1].ExitCode)
+	assert.Equal(t, "checks", results[1].Section)
+	assert.NotEmpty(t, results[2].Error)
+	assert.Equal(t, logrun.SkipDryrun, results[3].SkipReason)
+
+	failed, err := later.Query("deploy-42", logrun.ResultQuery{Failed: true})
+	require.NoError(t, err)
+	assert.Len(t, failed, 2)
+	checks, err := later.Query("deploy-42", logrun.ResultQuery{Section: "checks", Command: "exist"})
+	require.NoError(t, err)
+	require.Len(t, checks, 1)
+	assert.Equal(t, "/does/not/exist", checks[0].Command)
+	none, err := later.Query("deploy-42", logrun.ResultQuery{Host: "elsewhere"})
+	require.NoError(t, err)
+	assert.Empty(t, none)
+
+	_, err = later.Query("deploy-43", logrun.ResultQuery{})
+	t.Logf("err = %v", err)
+	assert.True(t, errors.Is(err, logrun.ErrNotFound))
+	_, err = later.Query("../deploy-42", logrun.ResultQuery{})
+	assert.Error(t, err)
+	_, err = logrun.NewJSONResultStore(dir, "a/b")
+	assert.Error(t, err)
+}
@@ -0,0 +1,101 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun
+
+import (
+	"bytes"
+	"strings"
+)
+
+// StreamHandlers are the callbacks used by RunStream() and
+// ShellStream() to process the output of a command as it is produced.
+// Lines are passed without their line endings. The callbacks for
+// standard output and standard error may be called concurrently.
+type StreamHandlers struct {
+	// OnStdoutLine, if not nil, is called with each line written
+	// to standard output. Otherwise standard output is discarded.
+	OnStdoutLine func(line string)
+
+	// OnStderrLine, if not nil, is called with each line written
+	// to standard error. Otherwise standard error is discarded.
+	OnStderrLine func(line string)
+}
+
+// RunStream first logs the command and then runs it like Run(), but
+// passes its output to h line by line as it is produced instead of
+// buffering it until the command exits, so commands producing large
+// amounts of output can be processed in constant memory. It returns
+// the exit code of the command, or an error if the command could not
+// be run. Only logging is performed if DryRun is true.
+func (r *LogRun) RunStream(h StreamHandlers, cmd string, args ...string) (_ int, err error) {
+	r = r.beginHelper()
+	defer r.addErrorContext(&err, r.commandSeq())
+	return r.stream(h, execSpec{cmd: cmd, args: args})
+}
+
+// ShellStream first logs the command and then runs it in a shell like
+// Shell(), but passes its output to h line by line as it is produced.
+// See RunStream().
+func (r *LogRun) ShellStream(h StreamHandlers, cmd string) (_ int, err error) {
+	r = r.beginHelper()
+	defer r.addErrorContext(&err, r.commandSeq())
+	return r.stream(h, execSpec{cmd: r.shellCmd(cmd), shell: true})
+}
+
+func (r *LogRun) stream(h StreamHandlers, spec execSpec) (int, error) {
+	stdout := &lineWriter{r: r, f: h.OnStdoutLine}
+	stderr := &lineWriter{r: r, f: h.OnStderrLine}
+	spec.stdout = stdout
+	spec.stderr = stderr
+	// Lines are passed to h without timestamps.
+	c := *r
+	c.call.lineTimestamps = false
+	res := c.logAndExecute(spec)
+	stdout.flush()
+	stderr.flush()
+
+	return res.ExitCode, res.Err
+}
+
+// lineWriter is an io.Writer that calls f with each complete line
+// written to it.
+type lineWriter struct {
+	r       *LogRun
+	f       func(line string)
+	partial []byte
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	if w.f == nil {
+		return n, nil
+	}
+	for {
+		i := bytes.IndexByte(p, '\n')
+		if i < 0 {
+			break
+		}
+		w.partial = append(w.partial, p[:i]...)
+		w.emit()
+		p = p[i+1:]
+	}
+	w.partial = append(w.partial, p...)
+
+	return n, nil
+}
+
+// flush passes the final line to f if it was not terminated by a
+// newline.
+func (w *lineWriter) flush() {
+	if w.f != nil && len(w.partial) > 0 {
+		w.emit()
+	}
+}
+
+func (w *lineWriter) emit() {
+	line := w.r.decodeOutput(string(w.partial))
+	w.partial = w.partial[:0]
+	w.f(strings.TrimSuffix(line, "\r"))
+}
@@ -0,0 +1,67 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun_test
+
+import (
+	"testing"
+
+	"github.com/apatters/go-logrun"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalLogRun_RunStream(t *testing.T) {
+	log, out, _ := newLogger()
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{
+		LogFunc: log.Println,
+	})
+	var lines []string
+	code, err := l.RunStream(logrun.StreamHandlers{
+		OnStdoutLine: func(line string) { lines = append(lines, line) },
+	}, "/usr/bin/printf", `one\ntwo\r\nthree`)
+	t.Logf("lines = %q", lines)
+	t.Logf("out = %q", out)
+	require.NoError(t, err)
+	assert.Zero(t, code)
+	assert.Equal(t, []string{"one", "two", "three"}, lines)
+	assert.Equal(t, "/usr/bin/printf 'one\\ntwo\\r\\nthree'\n", out.String())
+
+	_, err = l.RunStream(logrun.StreamHandlers{}, "/does/not/exist")
+	t.Logf("err = %v", err)
+	assert.Error(t, err)
+}
+
+func TestLocalLogRun_ShellStream(t *testing.T) {
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{})
+	count := 0
+	var errLines []string
+	code, err := l.ShellStream(logrun.StreamHandlers{
+		OnStdoutLine: func(line string) { count++ },
+		OnStderrLine: func(line string) { errLines = append(errLines, line) },
+	}, "seq 100000; echo oops >&2; exit 3")
+	t.Logf("count = %d", count)
+	t.Logf("errLines = %q", errLines)
+	require.NoError(t, err)
+	assert.Equal(t, 3, code)
+	assert.Equal(t, 100000, count)
+	assert.Equal(t, []string{"oops"}, errLines)
+}
+
+func TestRemoteLogRun_RunStream(t *testing.T) {
+	s := newTestSSHServer(t, nil)
+	defer s.Close()
+
+	r, err := logrun.NewRemoteLogRun(logrun.RemoteConfig{
+		Credentials: s.Credentials(),
+	})
+	require.NoError(t, err)
+	var lines []string
+	code, err := r.ShellStream(logrun.StreamHandlers{
+		OnStdoutLine: func(line string) { lines = append(lines, line) },
+	}, "echo a; echo b")
+	require.NoError(t, err)
+	assert.Zero(t, code)
+	assert.Equal(t, []string{"a", "b"}, lines)
+}
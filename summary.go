@@ -0,0 +1,455 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SummarySlowestCount is the number of slowest commands included in
+// a Summary.
+var SummarySlowestCount = 5
+
+// CommandStat describes a single command that was run.
+type CommandStat struct {
+	// Host identifies the host the command was run on.
+	Host string `json:"host"`
+
+	// Command is the command as it was logged.
+	Command string `json:"command"`
+
+	// Duration is how long the command took to run.
+	Duration time.Duration `json:"duration"`
+
+	// ExitCode is the exit code of the command.
+	ExitCode int `json:"exit_code"`
+
+	// Failed is true if the command could not be run or exited
+	// with a non-zero exit code.
+	Failed bool `json:"failed"`
+
+	// Error is the error that prevented the command from being
+	// run, if any.
+	Error string `json:"error,omitempty"`
+
+	// Skipped is true if the command was only logged because of
+	// Dryrun or check mode, or skipped because of a guard.
+	Skipped bool `json:"skipped,omitempty"`
+
+	// SkipReason is why the command was skipped, if it was.
+	SkipReason SkipReason `json:"skip_reason,omitempty"`
+
+	// Section is the log section the command was run in.
+	Section string `json:"section,omitempty"`
+}
+
+// OperationStat describes a single operation, e.g., a file written by
+// PutFileString(), and whether or not it changed the host.
+type OperationStat struct {
+	// Host identifies the host the operation was performed on.
+	Host string `json:"host"`
+
+	// Operation is the name of the operation, e.g.,
+	// "PutFileString".
+	Operation string `json:"operation"`
+
+	// Target is what the operation was performed on, e.g., a
+	// path.
+	Target string `json:"target"`
+
+	// Changed is true if the operation changed the host.
+	Changed bool `json:"changed"`
+
+	// Section is the log section the operation was performed in.
+	Section string `json:"section,omitempty"`
+}
+
+// TaskStat describes the outcome of a task run by a TaskList.
+type TaskStat struct {
+	// Host identifies the host the task was run on.
+	Host string `json:"host"`
+
+	// Task is the name of the task.
+	Task string `json:"task"`
+
+	// Status is one of "ok", "failed", "timed out", "ignored",
+	// "recovered", or "skipped".
+	Status string `json:"status"`
+
+	// Attempts is the number of times the task was run.
+	Attempts int `json:"attempts"`
+
+	// Duration is how long the task took to run, including all of
+	// its attempts.
+	Duration time.Duration `json:"duration"`
+
+	// Error is the error the task failed with, if any.
+	Error string `json:"error,omitempty"`
+
+	// Section is the log section the TaskList was run in.
+	Section string `json:"section,omitempty"`
+}
+
+// TransferStat describes data transferred to or from a host, e.g., by
+// Rsync() or PutFileString(). BytesSent and BytesReceived are counted
+// from the point of view of the controller, i.e., the host running the
+// program, except for Rsync(), which counts them from the point of view
+// of the host rsync is run on.
+type TransferStat struct {
+	// Host identifies the host the data was transferred to or
+	// from.
+	Host string `json:"host"`
+
+	// Operation is how the data was transferred: "Rsync",
+	// "Upload", "Download", "CollectArtifacts", or "ReadFile" and "WriteFile" for the
+	// helpers reading and writing files, e.g., GetFileString() and
+	// PutFileString().
+	Operation string `json:"operation"`
+
+	// Target is what was transferred, e.g., a path.
+	Target string `json:"target"`
+
+	// BytesSent and BytesReceived are the number of bytes
+	// transferred in each direction.
+	BytesSent     int64 `json:"bytes_sent"`
+	BytesReceived int64 `json:"bytes_received"`
+
+	// Section is the log section the data was transferred in.
+	Section string `json:"section,omitempty"`
+}
+
+// HostSummary is the breakdown of a Summary for a single host.
+type HostSummary struct {
+	Host      string        `json:"host"`
+	Run       int           `json:"run"`
+	Failed    int           `json:"failed"`
+	Skipped   int           `json:"skipped"`
+	Duration  time.Duration `json:"duration"`
+	Changed   int           `json:"changed"`
+	Unchanged int           `json:"unchanged"`
+
+	BytesSent     int64 `json:"bytes_sent,omitempty"`
+	BytesReceived int64 `json:"bytes_received,omitempty"`
+}
+
+// Summary reports the commands run by one or more LogRuns sharing a
+// Recorder. Durations are in nanoseconds when encoded as JSON.
+type Summary struct {
+	// Run is the number of commands that were run, including
+	// those run by helper methods.
+	Run int `json:"run"`
+
+	// Failed is the number of commands that could not be run or
+	// exited with a non-zero exit code.
+	Failed int `json:"failed"`
+
+	// Skipped is the number of commands passed to Run() and
+	// Shell() that were only logged because of Dryrun or check
+	// mode.
+	Skipped int `json:"skipped"`
+
+	// Duration is the total time spent running commands.
+	Duration time.Duration `json:"duration"`
+
+	// Changed is the number of operations that changed a host.
+	Changed int `json:"changed"`
+
+	// Unchanged is the number of operations that found a host
+	// already in the desired state.
+	Unchanged int `json:"unchanged"`
+
+	// BytesSent and BytesReceived are the total number of bytes
+	// transferred, as recorded in TransferStats.
+	BytesSent     int64 `json:"bytes_sent,omitempty"`
+	BytesReceived int64 `json:"bytes_received,omitempty"`
+
+	// Slowest are the SummarySlowestCount slowest commands,
+	// slowest first.
+	Slowest []CommandStat `json:"slowest"`
+
+	// Hosts is the breakdown by host, sorted by host.
+	Hosts []HostSummary `json:"hosts"`
+
+	// Tasks are the outcomes of the tasks run by TaskLists in the
+	// order they completed.
+	Tasks []TaskStat `json:"tasks,omitempty"`
+}
+
+// String returns the summary as human readable text.
+func (s Summary) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, tr("%d commands run, %d failed, %d skipped in %s\n"),
+		s.Run, s.Failed, s.Skipped, s.Duration)
+	if s.Changed+s.Unchanged > 0 {
+		fmt.Fprintf(&b, tr("%d changed, %d unchanged\n"), s.Changed, s.Unchanged)
+	}
+	if s.BytesSent+s.BytesReceived > 0 {
+		fmt.Fprintf(&b, tr("%d bytes sent, %d bytes received\n"), s.BytesSent, s.BytesReceived)
+	}
+	if len(s.Slowest) > 0 {
+		b.WriteString(tr("Slowest commands:\n"))
+		for _, c := range s.Slowest {
+			fmt.Fprintf(&b, "  %s %s: %s\n", c.Duration, c.Host, c.Command)
+		}
+	}
+	if len(s.Hosts) > 1 {
+		b.WriteString(tr("Hosts:\n"))
+		for _, h := range s.Hosts {
+			fmt.Fprintf(&b, tr("  %s: %d run, %d failed, %d skipped in %s"),
+				h.Host, h.Run, h.Failed, h.Skipped, h.Duration)
+			if s.Changed+s.Unchanged > 0 {
+				fmt.Fprintf(&b, tr(", %d changed, %d unchanged"), h.Changed, h.Unchanged)
+			}
+			if s.BytesSent+s.BytesReceived > 0 {
+				fmt.Fprintf(&b, tr(", %d bytes sent, %d bytes received"), h.BytesSent, h.BytesReceived)
+			}
+			b.WriteString("\n")
+		}
+	}
+	if len(s.Tasks) > 0 {
+		b.WriteString(tr("Tasks:\n"))
+		for _, t := range s.Tasks {
+			fmt.Fprintf(&b, "  %s %s: %s", t.Host, t.Task, tr(t.Status))
+			if t.Attempts > 1 {
+				fmt.Fprintf(&b, tr(" after %d attempts"), t.Attempts)
+			}
+			if t.Status != "skipped" {
+				fmt.Fprintf(&b, tr(" in %s"), t.Duration)
+			}
+			if t.Error != "" {
+				fmt.Fprintf(&b, " (%s)", t.Error)
+			}
+			b.WriteString("\n")
+		}
+	}
+
+	return b.String()
+}
+
+// JSON returns the summary encoded as indented JSON, e.g., for CI job
+// summaries.
+func (s Summary) JSON() ([]byte, error) {
+	return json.MarshalIndent(s, "", "  ")
+}
+
+// Recorder records the commands run by LogRuns for a Summary. A
+// Recorder can be shared by several LogRuns, e.g., one per host, to
+// produce a combined summary. It is safe for concurrent use.
+type Recorder struct {
+	mu         sync.Mutex
+	commands   []CommandStat
+	operations []OperationStat
+	tasks      []TaskStat
+	transfers  []TransferStat
+}
+
+// NewRecorder is the constructor for Recorder.
+func NewRecorder() *Recorder {
+	return new(Recorder)
+}
+
+func (rec *Recorder) record(stat CommandStat) {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	rec.commands = append(rec.commands, stat)
+}
+
+func (rec *Recorder) recordOperation(stat OperationStat) {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	rec.operations = append(rec.operations, stat)
+}
+
+func (rec *Recorder) recordTask(stat TaskStat) {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	rec.tasks = append(rec.tasks, stat)
+}
+
+func (rec *Recorder) recordTransfer(stat TransferStat) {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	rec.transfers = append(rec.transfers, stat)
+}
+
+// Commands returns the commands recorded so far in the order they
+// were run.
+func (rec *Recorder) Commands() []CommandStat {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	return append([]CommandStat{}, rec.commands...)
+}
+
+// Operations returns the operations recorded so far in the order
+// they were performed.
+func (rec *Recorder) Operations() []OperationStat {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	return append([]OperationStat{}, rec.operations...)
+}
+
+// Tasks returns the outcomes of the tasks recorded so far in the order
+// they completed.
+func (rec *Recorder) Tasks() []TaskStat {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	return append([]TaskStat{}, rec.tasks...)
+}
+
+// Transfers returns the transfers recorded so far in the order they
+// completed.
+func (rec *Recorder) Transfers() []TransferStat {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	return append([]TransferStat{}, rec.transfers...)
+}
+
+// Reset discards everything recorded so far.
+func (rec *Recorder) Reset() {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	rec.commands = nil
+	rec.operations = nil
+	rec.tasks = nil
+	rec.transfers = nil
+}
+
+// Summary returns a summary of the commands recorded so far.
+func (rec *Recorder) Summary() Summary {
+	var s Summary
+	hosts := make(map[string]*HostSummary)
+	host := func(name string) *HostSummary {
+		if hosts[name] == nil {
+			hosts[name] = &HostSummary{Host: name}
+		}
+		return hosts[name]
+	}
+	commands := rec.Commands()
+	for _, c := range commands {
+		h := host(c.Host)
+		if c.Skipped {
+			s.Skipped++
+			h.Skipped++
+			continue
+		}
+		s.Run++
+		h.Run++
+		if c.Failed {
+			s.Failed++
+			h.Failed++
+		}
+		s.Duration += c.Duration
+		h.Duration += c.Duration
+	}
+	for _, op := range rec.Operations() {
+		h := host(op.Host)
+		if op.Changed {
+			s.Changed++
+			h.Changed++
+		} else {
+			s.Unchanged++
+			h.Unchanged++
+		}
+	}
+	for _, t := range rec.Transfers() {
+		h := host(t.Host)
+		s.BytesSent += t.BytesSent
+		h.BytesSent += t.BytesSent
+		s.BytesReceived += t.BytesReceived
+		h.BytesReceived += t.BytesReceived
+	}
+	for _, h := range hosts {
+		s.Hosts = append(s.Hosts, *h)
+	}
+	sort.Slice(s.Hosts, func(i, j int) bool {
+		return s.Hosts[i].Host < s.Hosts[j].Host
+	})
+	for _, c := range commands {
+		if !c.Skipped {
+			s.Slowest = append(s.Slowest, c)
+		}
+	}
+	sort.SliceStable(s.Slowest, func(i, j int) bool {
+		return s.Slowest[i].Duration > s.Slowest[j].Duration
+	})
+	if len(s.Slowest) > SummarySlowestCount {
+		s.Slowest = s.Slowest[:SummarySlowestCount]
+	}
+	if tasks := rec.Tasks(); len(tasks) > 0 {
+		s.Tasks = tasks
+	}
+
+	return s
+}
+
+// SetRecorder sets the Recorder used to record the commands run. Use
+// the same Recorder for several LogRuns to summarize them together,
+// or nil to disable recording. Recording is disabled by default.
+func (r *LogRun) SetRecorder(rec *Recorder) {
+	r.recorder = rec
+}
+
+// Recorder returns the Recorder used to record the commands run, or
+// nil if recording is disabled.
+func (r *LogRun) Recorder() *Recorder {
+	return r.recorder
+}
+
+// Summary returns a summary of the commands recorded by the LogRun's
+// Recorder. The zero Summary is returned if recording is disabled.
+func (r *LogRun) Summary() Summary {
+	if r.recorder == nil {
+		return Summary{}
+	}
+
+	return r.recorder.Summary()
+}
+
+// RecordOperation records the outcome of an operation performed on
+// the host so it is included in the Changed and Unchanged counts of
+// the Summary(). The helper methods that change hosts, e.g.,
+// PutFileString(), record their operations; use RecordOperation to do
+// the same for operations built on Run() and Shell(), e.g.,
+//
+//	runner.RecordOperation("EnsureUser", "deploy", created)
+//
+// Changed operations notify the handlers selected by WithNotify().
+func (r *LogRun) RecordOperation(operation string, target string, changed bool) {
+	if changed && len(r.call.notify) > 0 {
+		r.Notify(r.call.notify...)
+	}
+	if r.recorder != nil {
+		r.recorder.recordOperation(OperationStat{
+			Host:      r.Host().String(),
+			Operation: operation,
+			Target:    target,
+			Changed:   changed,
+			Section:   r.Section(),
+		})
+	}
+}
+
+// recordSkipped records a command that was not run because of
+// reason.
+func (r *LogRun) recordSkipped(msg string, reason SkipReason) {
+	if r.recorder != nil {
+		r.recorder.record(CommandStat{
+			Host:       r.Host().String(),
+			Command:    msg,
+			Skipped:    true,
+			SkipReason: reason,
+			Section:    r.Section(),
+		})
+	}
+}
@@ -0,0 +1,121 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/apatters/go-logrun"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalLogRun_Summary(t *testing.T) {
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{})
+	l.SetRecorder(logrun.NewRecorder())
+	l.Run("/bin/true")
+	l.Shell("sleep 0.2")
+	l.Run("/bin/false")
+	l.SetDryrun(true)
+	l.Run("/bin/true")
+
+	s := l.Summary()
+	t.Logf("summary =\n%s", s)
+	assert.Equal(t, 3, s.Run)
+	assert.Equal(t, 1, s.Failed)
+	assert.Equal(t, 1, s.Skipped)
+	assert.True(t, s.Duration >= 200*time.Millisecond)
+	require.Len(t, s.Slowest, 3)
+	assert.Equal(t, `/bin/sh -c "sleep 0.2"`, s.Slowest[0].Command)
+	assert.Equal(t, l.Host().String(), s.Slowest[0].Host)
+	require.Len(t, s.Hosts, 1)
+	assert.Equal(t, l.Host().String(), s.Hosts[0].Host)
+	assert.Equal(t, 3, s.Hosts[0].Run)
+	assert.Contains(t, s.String(), "3 commands run, 1 failed, 1 skipped in ")
+
+	buf, err := s.JSON()
+	require.NoError(t, err)
+	t.Logf("json = %s", buf)
+	var decoded logrun.Summary
+	require.NoError(t, json.Unmarshal(buf, &decoded))
+	assert.Equal(t, s, decoded)
+
+	l.Recorder().Reset()
+	assert.Zero(t, l.Summary().Run)
+}
+
+func TestLocalLogRun_SummarySharedRecorder(t *testing.T) {
+	orig := logrun.SummarySlowestCount
+	logrun.SummarySlowestCount = 1
+	defer func() { logrun.SummarySlowestCount = orig }()
+
+	rec := logrun.NewRecorder()
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{})
+	l.SetRecorder(rec)
+	r, err := logrun.NewRemoteLogRun(logrun.RemoteConfig{
+		Credentials: logrun.Credentials{
+			Hostname: "db1.example.com",
+			Password: "secret",
+		},
+		Dryrun: true,
+	})
+	require.NoError(t, err)
+	r.SetRecorder(rec)
+	l.Run("/bin/true")
+	l.Run("/bin/true")
+	r.Run("/bin/true")
+
+	s := rec.Summary()
+	t.Logf("summary =\n%s", s)
+	assert.Equal(t, 2, s.Run)
+	assert.Equal(t, 1, s.Skipped)
+	assert.Len(t, s.Slowest, 1)
+	require.Len(t, s.Hosts, 2)
+	assert.Contains(t, s.String(), "Hosts:\n")
+
+	l.SetRecorder(nil)
+	assert.Equal(t, logrun.Summary{}, l.Summary())
+}
+
+func TestLocalLogRun_SummaryChanged(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "logrun")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmpDir)
+	path := filepath.Join(tmpDir, "config")
+
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{})
+	l.SetRecorder(logrun.NewRecorder())
+	_, err = l.PutFileString(path, "a=1\n", 0644)
+	require.NoError(t, err)
+	_, err = l.PutFileString(path, "a=1\n", 0644)
+	require.NoError(t, err)
+	l.RecordOperation("EnsureUser", "deploy", false)
+
+	ops := l.Recorder().Operations()
+	t.Logf("operations = %+v", ops)
+	require.Len(t, ops, 3)
+	assert.Equal(t, "PutFileString", ops[0].Operation)
+	assert.Equal(t, path, ops[0].Target)
+	assert.True(t, ops[0].Changed)
+	assert.False(t, ops[1].Changed)
+	assert.Equal(t, "deploy", ops[2].Target)
+
+	s := l.Summary()
+	t.Logf("summary =\n%s", s)
+	assert.Equal(t, 1, s.Changed)
+	assert.Equal(t, 2, s.Unchanged)
+	require.Len(t, s.Hosts, 1)
+	assert.Equal(t, 1, s.Hosts[0].Changed)
+	assert.Equal(t, 2, s.Hosts[0].Unchanged)
+	assert.Contains(t, s.String(), "\n1 changed, 2 unchanged\n")
+
+	l.Recorder().Reset()
+	assert.Empty(t, l.Recorder().Operations())
+}
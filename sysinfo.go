@@ -0,0 +1,583 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	// DiskUsageCmd is the external command used to get the disk
+	// usage of mounted filesystems. This command has been tested
+	// on RHEL/CentOS 7 and Ubuntu 18.04.
+	DiskUsageCmd = "/bin/df"
+
+	// DiskUsageCmdOptions are the command-line options added to
+	// DiskUsageCmd to output POSIX format with sizes in
+	// kilobytes. This command (and options) has been tested on
+	// RHEL/CentOS 7 and Ubuntu 18.04.
+	DiskUsageCmdOptions = []string{
+		"-P",
+		"-k",
+	}
+
+	// MemoryCmd is the external command used to get the memory
+	// usage of a host. This command has been tested on
+	// RHEL/CentOS 7 and Ubuntu 18.04.
+	MemoryCmd = "/usr/bin/free"
+
+	// MemoryCmdOptions are the command-line options added to
+	// MemoryCmd to output sizes in bytes. This command (and
+	// options) has been tested on RHEL/CentOS 7 and Ubuntu 18.04.
+	MemoryCmdOptions = []string{
+		"-b",
+	}
+
+	// ProcessesCmd is the external command used to list the
+	// processes of a host. This command has been tested on
+	// RHEL/CentOS 7 and Ubuntu 18.04.
+	ProcessesCmd = "/bin/ps"
+
+	// ProcessesCmdOptions are the command-line options added to
+	// ProcessesCmd to list all processes without a header. This
+	// command (and options) has been tested on RHEL/CentOS 7 and
+	// Ubuntu 18.04.
+	ProcessesCmdOptions = []string{
+		"-e",
+		"-o",
+		"pid=,ppid=,user=,pcpu=,rss=,args=",
+	}
+
+	// ListeningPortsCmd is the external command used to list the
+	// listening TCP and UDP ports of a host. It is found using
+	// the PATH since it is installed in /usr/sbin on RHEL/CentOS
+	// 7 and /bin on Ubuntu 18.04. This command has been tested on
+	// RHEL/CentOS 7 and Ubuntu 18.04.
+	ListeningPortsCmd = "ss"
+
+	// ListeningPortsCmdOptions are the command-line options
+	// added to ListeningPortsCmd to list listening TCP and UDP
+	// sockets numerically. This command (and options) has been
+	// tested on RHEL/CentOS 7 and Ubuntu 18.04.
+	ListeningPortsCmdOptions = []string{
+		"-t",
+		"-u",
+		"-l",
+		"-n",
+	}
+
+	// IPAddressesCmd is the external command used to list the IP
+	// addresses of a host. This command has been tested on
+	// RHEL/CentOS 7 and Ubuntu 18.04.
+	IPAddressesCmd = "/sbin/ip"
+
+	// IPAddressesCmdOptions are the command-line options added to
+	// IPAddressesCmd to list one address per line. This command
+	// (and options) has been tested on RHEL/CentOS 7 and Ubuntu
+	// 18.04.
+	IPAddressesCmdOptions = []string{
+		"-o",
+		"addr",
+		"show",
+	}
+
+	// BlockDevicesCmd is the external command used to list the
+	// block devices of a host. This command has been tested on
+	// RHEL/CentOS 7 and Ubuntu 18.04.
+	BlockDevicesCmd = "/bin/lsblk"
+
+	// BlockDevicesCmdOptions are the command-line options added
+	// to BlockDevicesCmd to output KEY="value" pairs with sizes
+	// in bytes. This command (and options) has been tested on
+	// RHEL/CentOS 7 and Ubuntu 18.04.
+	BlockDevicesCmdOptions = []string{
+		"-P",
+		"-b",
+		"-o",
+		"NAME,TYPE,SIZE,MOUNTPOINT,FSTYPE",
+	}
+
+	// UnitStatusCmd is the external command used to get the
+	// status of a systemd unit. This command has been tested on
+	// RHEL/CentOS 7 and Ubuntu 18.04.
+	UnitStatusCmd = "/bin/systemctl"
+
+	// UnitStatusCmdOptions are the command-line options added to
+	// UnitStatusCmd before the name of the unit. This command
+	// (and options) has been tested on RHEL/CentOS 7 and Ubuntu
+	// 18.04.
+	UnitStatusCmdOptions = []string{
+		"show",
+		"--property=Id,Description,LoadState,ActiveState,SubState,MainPID,UnitFileState",
+	}
+)
+
+// DiskUsage is the usage of a mounted filesystem. Sizes are in bytes.
+type DiskUsage struct {
+	Filesystem string
+	Size       uint64
+	Used       uint64
+	Available  uint64
+	UsePercent int
+	MountPoint string
+}
+
+// Memory is the memory usage of a host. Sizes are in bytes.
+type Memory struct {
+	Total     uint64
+	Used      uint64
+	Free      uint64
+	Shared    uint64
+	BuffCache uint64
+	Available uint64
+	SwapTotal uint64
+	SwapUsed  uint64
+	SwapFree  uint64
+}
+
+// Process is a process running on a host.
+type Process struct {
+	PID     int
+	PPID    int
+	User    string
+	CPU     float64
+	RSS     uint64
+	Command string
+}
+
+// ListeningPort is a TCP or UDP socket listening for connections.
+type ListeningPort struct {
+	// Protocol is "tcp" or "udp".
+	Protocol string
+
+	// Address is the local address, e.g., "0.0.0.0", "::", or
+	// "*" for all addresses.
+	Address string
+
+	Port int
+}
+
+// IPAddress is an address assigned to a network interface.
+type IPAddress struct {
+	Interface string
+	IP        net.IP
+	PrefixLen int
+}
+
+// BlockDevice is a block device, e.g., a disk or partition.
+type BlockDevice struct {
+	Name       string
+	Type       string
+	Size       uint64
+	MountPoint string
+	FSType     string
+}
+
+// UnitStatus is the status of a systemd unit.
+type UnitStatus struct {
+	Name          string
+	Description   string
+	LoadState     string
+	ActiveState   string
+	SubState      string
+	UnitFileState string
+	MainPID       int
+}
+
+// Active returns true if the unit is active.
+func (u UnitStatus) Active() bool {
+	return u.ActiveState == "active"
+}
+
+// ParseDiskUsage parses the output of DiskUsageCmd.
+func ParseDiskUsage(output string) ([]DiskUsage, error) {
+	var usage []DiskUsage
+	for i, line := range lines(output) {
+		if i == 0 {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 6 {
+			return nil, fmt.Errorf("could not parse disk usage %q", line)
+		}
+		sizes, err := parseUints(fields[1:4])
+		if err != nil {
+			return nil, fmt.Errorf("could not parse disk usage %q: %w", line, err)
+		}
+		percent, err := strconv.Atoi(strings.TrimSuffix(fields[4], "%"))
+		if err != nil {
+			percent = 0
+		}
+		usage = append(usage, DiskUsage{
+			Filesystem: fields[0],
+			Size:       sizes[0] * 1024,
+			Used:       sizes[1] * 1024,
+			Available:  sizes[2] * 1024,
+			UsePercent: percent,
+			MountPoint: strings.Join(fields[5:], " "),
+		})
+	}
+
+	return usage, nil
+}
+
+// ParseMemory parses the output of MemoryCmd. Both the current
+// "buff/cache" and older "buffers cached" formats are supported.
+func ParseMemory(output string) (Memory, error) {
+	var m Memory
+	var header []string
+	for _, line := range lines(output) {
+		fields := strings.Fields(line)
+		if header == nil {
+			header = fields
+			continue
+		}
+		if len(fields) == 0 || len(fields)-1 > len(header) ||
+			(fields[0] != "Mem:" && fields[0] != "Swap:") {
+			continue
+		}
+		values, err := parseUints(fields[1:])
+		if err != nil {
+			return Memory{}, fmt.Errorf("could not parse memory %q: %w", line, err)
+		}
+		switch fields[0] {
+		case "Mem:":
+			for i, v := range values {
+				switch header[i] {
+				case "total":
+					m.Total = v
+				case "used":
+					m.Used = v
+				case "free":
+					m.Free = v
+				case "shared":
+					m.Shared = v
+				case "buff/cache", "buffers", "cached":
+					m.BuffCache += v
+				case "available":
+					m.Available = v
+				}
+			}
+		case "Swap:":
+			if len(values) < 3 {
+				return Memory{}, fmt.Errorf("could not parse memory %q", line)
+			}
+			m.SwapTotal, m.SwapUsed, m.SwapFree = values[0], values[1], values[2]
+		}
+	}
+	if header == nil {
+		return Memory{}, fmt.Errorf("could not parse memory %q", output)
+	}
+
+	return m, nil
+}
+
+// ParseProcesses parses the output of ProcessesCmd.
+func ParseProcesses(output string) ([]Process, error) {
+	var procs []Process
+	for _, line := range lines(output) {
+		fields := strings.Fields(line)
+		if len(fields) < 6 {
+			return nil, fmt.Errorf("could not parse process %q", line)
+		}
+		pid, err := strconv.Atoi(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("could not parse process %q: %w", line, err)
+		}
+		ppid, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("could not parse process %q: %w", line, err)
+		}
+		cpu, err := strconv.ParseFloat(fields[3], 64)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse process %q: %w", line, err)
+		}
+		rss, err := strconv.ParseUint(fields[4], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse process %q: %w", line, err)
+		}
+		procs = append(procs, Process{
+			PID:     pid,
+			PPID:    ppid,
+			User:    fields[2],
+			CPU:     cpu,
+			RSS:     rss * 1024,
+			Command: strings.Join(fields[5:], " "),
+		})
+	}
+
+	return procs, nil
+}
+
+// ParseListeningPorts parses the output of ListeningPortsCmd.
+func ParseListeningPorts(output string) ([]ListeningPort, error) {
+	var ports []ListeningPort
+	for _, line := range lines(output) {
+		fields := strings.Fields(line)
+		if len(fields) > 0 && fields[0] == "Netid" {
+			continue
+		}
+		if len(fields) < 5 {
+			return nil, fmt.Errorf("could not parse listening port %q", line)
+		}
+		local := fields[4]
+		i := strings.LastIndex(local, ":")
+		if i < 0 {
+			return nil, fmt.Errorf("could not parse listening port %q", line)
+		}
+		port, err := strconv.Atoi(local[i+1:])
+		if err != nil {
+			return nil, fmt.Errorf("could not parse listening port %q: %w", line, err)
+		}
+		ports = append(ports, ListeningPort{
+			Protocol: fields[0],
+			Address:  strings.TrimSuffix(strings.TrimPrefix(local[:i], "["), "]"),
+			Port:     port,
+		})
+	}
+
+	return ports, nil
+}
+
+// ParseIPAddresses parses the output of IPAddressesCmd.
+func ParseIPAddresses(output string) ([]IPAddress, error) {
+	var addrs []IPAddress
+	for _, line := range lines(output) {
+		fields := strings.Fields(line)
+		if len(fields) < 4 {
+			return nil, fmt.Errorf("could not parse IP address %q", line)
+		}
+		if fields[2] != "inet" && fields[2] != "inet6" {
+			continue
+		}
+		ip, ipNet, err := net.ParseCIDR(fields[3])
+		if err != nil {
+			return nil, fmt.Errorf("could not parse IP address %q: %w", line, err)
+		}
+		prefixLen, _ := ipNet.Mask.Size()
+		addrs = append(addrs, IPAddress{
+			Interface: fields[1],
+			IP:        ip,
+			PrefixLen: prefixLen,
+		})
+	}
+
+	return addrs, nil
+}
+
+// keyValuePairRegexp matches the KEY="value" pairs output by
+// BlockDevicesCmd.
+var keyValuePairRegexp = regexp.MustCompile(`([A-Z:-]+)="([^"]*)"`)
+
+// ParseBlockDevices parses the output of BlockDevicesCmd.
+func ParseBlockDevices(output string) ([]BlockDevice, error) {
+	var devs []BlockDevice
+	for _, line := range lines(output) {
+		pairs := make(map[string]string)
+		for _, m := range keyValuePairRegexp.FindAllStringSubmatch(line, -1) {
+			pairs[m[1]] = m[2]
+		}
+		if pairs["NAME"] == "" {
+			return nil, fmt.Errorf("could not parse block device %q", line)
+		}
+		var size uint64
+		if pairs["SIZE"] != "" {
+			var err error
+			size, err = strconv.ParseUint(pairs["SIZE"], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("could not parse block device %q: %w", line, err)
+			}
+		}
+		devs = append(devs, BlockDevice{
+			Name:       pairs["NAME"],
+			Type:       pairs["TYPE"],
+			Size:       size,
+			MountPoint: pairs["MOUNTPOINT"],
+			FSType:     pairs["FSTYPE"],
+		})
+	}
+
+	return devs, nil
+}
+
+// ParseSystemctlShow parses the Property=value lines output by
+// "systemctl show" into a map.
+func ParseSystemctlShow(output string) map[string]string {
+	props := make(map[string]string)
+	for _, line := range lines(output) {
+		i := strings.Index(line, "=")
+		if i < 0 {
+			continue
+		}
+		props[line[:i]] = line[i+1:]
+	}
+
+	return props
+}
+
+// ParseUnitStatus parses the output of UnitStatusCmd.
+func ParseUnitStatus(output string) (UnitStatus, error) {
+	props := ParseSystemctlShow(output)
+	if props["Id"] == "" {
+		return UnitStatus{}, fmt.Errorf("could not parse unit status %q", output)
+	}
+	pid, _ := strconv.Atoi(props["MainPID"])
+
+	return UnitStatus{
+		Name:          props["Id"],
+		Description:   props["Description"],
+		LoadState:     props["LoadState"],
+		ActiveState:   props["ActiveState"],
+		SubState:      props["SubState"],
+		UnitFileState: props["UnitFileState"],
+		MainPID:       pid,
+	}, nil
+}
+
+// lines returns the non-blank lines of output.
+func lines(output string) []string {
+	var ls []string
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	scanner.Buffer(make([]byte, 64*1024), len(output)+1)
+	for scanner.Scan() {
+		if strings.TrimSpace(scanner.Text()) != "" {
+			ls = append(ls, scanner.Text())
+		}
+	}
+
+	return ls
+}
+
+func parseUints(fields []string) ([]uint64, error) {
+	values := make([]uint64, len(fields))
+	for i, f := range fields {
+		v, err := strconv.ParseUint(f, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = v
+	}
+
+	return values, nil
+}
+
+// query logs and runs cmd with args and returns its output. Only
+// logging is performed if Dryrun is true, in which case the empty
+// string is returned. what describes the output in errors.
+func (r *LogRun) query(what string, cmd string, args ...string) (string, error) {
+	r.logRun(cmd, args...)
+	if r.Dryrun {
+		return "", nil
+	}
+	stdout, stderr, code := r.run(cmd, args...)
+	if code != 0 {
+		return "", fmt.Errorf("could not get %s: %s", what, strings.TrimSpace(stderr))
+	}
+
+	return stdout, nil
+}
+
+// DiskUsage returns the usage of the filesystems mounted on the host.
+// Only logging is performed if Dryrun is true, in which case nil is
+// returned.
+func (r *LogRun) DiskUsage() (_ []DiskUsage, err error) {
+	r = r.beginHelper()
+	defer r.addErrorContext(&err, r.commandSeq())
+	out, err := r.query("disk usage", DiskUsageCmd, DiskUsageCmdOptions...)
+	if err != nil || out == "" {
+		return nil, err
+	}
+
+	return ParseDiskUsage(out)
+}
+
+// Memory returns the memory usage of the host. Only logging is
+// performed if Dryrun is true, in which case the zero Memory is
+// returned.
+func (r *LogRun) Memory() (_ Memory, err error) {
+	r = r.beginHelper()
+	defer r.addErrorContext(&err, r.commandSeq())
+	out, err := r.query("memory", MemoryCmd, MemoryCmdOptions...)
+	if err != nil || out == "" {
+		return Memory{}, err
+	}
+
+	return ParseMemory(out)
+}
+
+// Processes returns the processes running on the host. Only logging
+// is performed if Dryrun is true, in which case nil is returned.
+func (r *LogRun) Processes() (_ []Process, err error) {
+	r = r.beginHelper()
+	defer r.addErrorContext(&err, r.commandSeq())
+	out, err := r.query("processes", ProcessesCmd, ProcessesCmdOptions...)
+	if err != nil || out == "" {
+		return nil, err
+	}
+
+	return ParseProcesses(out)
+}
+
+// ListeningPorts returns the TCP and UDP ports the host is listening
+// on. Only logging is performed if Dryrun is true, in which case nil
+// is returned.
+func (r *LogRun) ListeningPorts() (_ []ListeningPort, err error) {
+	r = r.beginHelper()
+	defer r.addErrorContext(&err, r.commandSeq())
+	out, err := r.query("listening ports", ListeningPortsCmd, ListeningPortsCmdOptions...)
+	if err != nil || out == "" {
+		return nil, err
+	}
+
+	return ParseListeningPorts(out)
+}
+
+// IPAddresses returns the IP addresses assigned to the network
+// interfaces of the host. Only logging is performed if Dryrun is
+// true, in which case nil is returned.
+func (r *LogRun) IPAddresses() (_ []IPAddress, err error) {
+	r = r.beginHelper()
+	defer r.addErrorContext(&err, r.commandSeq())
+	out, err := r.query("IP addresses", IPAddressesCmd, IPAddressesCmdOptions...)
+	if err != nil || out == "" {
+		return nil, err
+	}
+
+	return ParseIPAddresses(out)
+}
+
+// BlockDevices returns the block devices of the host. Only logging is
+// performed if Dryrun is true, in which case nil is returned.
+func (r *LogRun) BlockDevices() (_ []BlockDevice, err error) {
+	r = r.beginHelper()
+	defer r.addErrorContext(&err, r.commandSeq())
+	out, err := r.query("block devices", BlockDevicesCmd, BlockDevicesCmdOptions...)
+	if err != nil || out == "" {
+		return nil, err
+	}
+
+	return ParseBlockDevices(out)
+}
+
+// UnitStatus returns the status of the systemd unit, e.g.,
+// "nginx.service". Units that do not exist are reported with a
+// LoadState of "not-found". Only logging is performed if Dryrun is
+// true, in which case the zero UnitStatus is returned.
+func (r *LogRun) UnitStatus(unit string) (_ UnitStatus, err error) {
+	r = r.beginHelper()
+	defer r.addErrorContext(&err, r.commandSeq())
+	args := append(append([]string{}, UnitStatusCmdOptions...), unit)
+	out, err := r.query("status of "+unit, UnitStatusCmd, args...)
+	if err != nil || out == "" {
+		return UnitStatus{}, err
+	}
+
+	return ParseUnitStatus(out)
+}
@@ -0,0 +1,180 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun_test
+
+import (
+	"net"
+	"os"
+	"testing"
+
+	"github.com/apatters/go-logrun"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDiskUsage(t *testing.T) {
+	usage, err := logrun.ParseDiskUsage(`Filesystem     1024-blocks     Used Available Capacity Mounted on
+/dev/sda1         20961280  5242880  15718400      26% /
+tmpfs              1024000        0   1024000       0% /mnt/my data
+`)
+	t.Logf("usage = %+v", usage)
+	require.NoError(t, err)
+	require.Len(t, usage, 2)
+	assert.Equal(t, logrun.DiskUsage{
+		Filesystem: "/dev/sda1",
+		Size:       20961280 * 1024,
+		Used:       5242880 * 1024,
+		Available:  15718400 * 1024,
+		UsePercent: 26,
+		MountPoint: "/",
+	}, usage[0])
+	assert.Equal(t, "/mnt/my data", usage[1].MountPoint)
+
+	_, err = logrun.ParseDiskUsage("header\nbad line\n")
+	assert.Error(t, err)
+}
+
+func TestParseMemory(t *testing.T) {
+	m, err := logrun.ParseMemory(`              total        used        free      shared  buff/cache   available
+Mem:     6294937600   545984512  4608167936     9265152  1394679808  5748953088
+Swap:        1048576        1024     1047552
+`)
+	t.Logf("memory = %+v", m)
+	require.NoError(t, err)
+	assert.Equal(t, logrun.Memory{
+		Total:     6294937600,
+		Used:      545984512,
+		Free:      4608167936,
+		Shared:    9265152,
+		BuffCache: 1394679808,
+		Available: 5748953088,
+		SwapTotal: 1048576,
+		SwapUsed:  1024,
+		SwapFree:  1047552,
+	}, m)
+
+	// Older procps versions report buffers and cache separately.
+	m, err = logrun.ParseMemory(`             total       used       free     shared    buffers     cached
+Mem:          1000        600        400         10         50        100
+-/+ buffers/cache:        450        550
+Swap:            0          0          0
+`)
+	t.Logf("memory = %+v", m)
+	require.NoError(t, err)
+	assert.EqualValues(t, 1000, m.Total)
+	assert.EqualValues(t, 150, m.BuffCache)
+}
+
+func TestParseProcesses(t *testing.T) {
+	procs, err := logrun.ParseProcesses(`    1     0 root      0.2  9252 /sbin/init splash
+  812     1 www-data  1.5 20480 nginx: worker process
+`)
+	t.Logf("procs = %+v", procs)
+	require.NoError(t, err)
+	require.Len(t, procs, 2)
+	assert.Equal(t, logrun.Process{
+		PID:     812,
+		PPID:    1,
+		User:    "www-data",
+		CPU:     1.5,
+		RSS:     20480 * 1024,
+		Command: "nginx: worker process",
+	}, procs[1])
+}
+
+func TestParseListeningPorts(t *testing.T) {
+	ports, err := logrun.ParseListeningPorts(`Netid State  Recv-Q Send-Q Local Address:Port  Peer Address:PortProcess
+udp   UNCONN 0      0      127.0.0.53%lo:53         0.0.0.0:*
+tcp   LISTEN 0      128          0.0.0.0:22         0.0.0.0:*
+tcp   LISTEN 0      128             [::]:443           [::]:*
+tcp   LISTEN 0      128                *:8080             *:*
+`)
+	t.Logf("ports = %+v", ports)
+	require.NoError(t, err)
+	assert.Equal(t, []logrun.ListeningPort{
+		{Protocol: "udp", Address: "127.0.0.53%lo", Port: 53},
+		{Protocol: "tcp", Address: "0.0.0.0", Port: 22},
+		{Protocol: "tcp", Address: "::", Port: 443},
+		{Protocol: "tcp", Address: "*", Port: 8080},
+	}, ports)
+}
+
+func TestParseIPAddresses(t *testing.T) {
+	addrs, err := logrun.ParseIPAddresses(`1: lo    inet 127.0.0.1/8 scope host lo\       valid_lft forever preferred_lft forever
+1: lo    inet6 ::1/128 scope host \       valid_lft forever preferred_lft forever
+2: eth0    inet 192.0.2.2/24 brd 192.0.2.255 scope global eth0\       valid_lft forever preferred_lft forever
+`)
+	t.Logf("addrs = %+v", addrs)
+	require.NoError(t, err)
+	require.Len(t, addrs, 3)
+	assert.Equal(t, "lo", addrs[1].Interface)
+	assert.True(t, net.ParseIP("::1").Equal(addrs[1].IP))
+	assert.Equal(t, 128, addrs[1].PrefixLen)
+	assert.Equal(t, "eth0", addrs[2].Interface)
+	assert.True(t, net.ParseIP("192.0.2.2").Equal(addrs[2].IP))
+	assert.Equal(t, 24, addrs[2].PrefixLen)
+}
+
+func TestParseBlockDevices(t *testing.T) {
+	devs, err := logrun.ParseBlockDevices(`NAME="sda" TYPE="disk" SIZE="274877906944" MOUNTPOINT="" FSTYPE=""
+NAME="sda1" TYPE="part" SIZE="1073741824" MOUNTPOINT="/boot" FSTYPE="xfs"
+`)
+	t.Logf("devs = %+v", devs)
+	require.NoError(t, err)
+	assert.Equal(t, []logrun.BlockDevice{
+		{Name: "sda", Type: "disk", Size: 274877906944},
+		{Name: "sda1", Type: "part", Size: 1073741824, MountPoint: "/boot", FSType: "xfs"},
+	}, devs)
+}
+
+func TestParseUnitStatus(t *testing.T) {
+	u, err := logrun.ParseUnitStatus(`Id=nginx.service
+Description=A high performance web server
+LoadState=loaded
+ActiveState=active
+SubState=running
+MainPID=1234
+UnitFileState=enabled
+`)
+	t.Logf("unit = %+v", u)
+	require.NoError(t, err)
+	assert.Equal(t, logrun.UnitStatus{
+		Name:          "nginx.service",
+		Description:   "A high performance web server",
+		LoadState:     "loaded",
+		ActiveState:   "active",
+		SubState:      "running",
+		UnitFileState: "enabled",
+		MainPID:       1234,
+	}, u)
+	assert.True(t, u.Active())
+}
+
+func TestLocalLogRun_Processes(t *testing.T) {
+	log, out, _ := newLogger()
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{
+		LogFunc: log.Println,
+	})
+	procs, err := l.Processes()
+	t.Logf("out = %q", out)
+	require.NoError(t, err)
+	found := false
+	for _, p := range procs {
+		if p.PID == os.Getpid() {
+			found = true
+		}
+	}
+	assert.True(t, found)
+	assert.Equal(t, "/bin/ps -e -o pid=,ppid=,user=,pcpu=,rss=,args=\n", out.String())
+
+	usage, err := l.DiskUsage()
+	require.NoError(t, err)
+	assert.NotEmpty(t, usage)
+
+	l.SetDryrun(true)
+	procs, err = l.Processes()
+	assert.NoError(t, err)
+	assert.Nil(t, procs)
+}
@@ -0,0 +1,391 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun
+
+import (
+	"encoding/csv"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// TableFormat selects how ParseTable() splits lines into columns.
+type TableFormat int
+
+const (
+	// TableWhitespace splits lines at runs of whitespace. Extra
+	// fields are joined to the last column, so the last column
+	// may contain spaces, e.g., the arguments of a command.
+	TableWhitespace TableFormat = iota
+
+	// TableFixedWidth locates columns using the positions of the
+	// column names in the header line, like the output of ps,
+	// df, or docker ps. Each whitespace separated word is
+	// assigned to the column whose name it overlaps the most or,
+	// if none, to the column to its left, so both left and right
+	// aligned columns and values containing spaces are handled. A
+	// header line is required.
+	TableFixedWidth
+
+	// TableDelimited splits lines at Delimiter using CSV quoting
+	// rules, e.g., for CSV or TSV output.
+	TableDelimited
+)
+
+// TableOptions describes the tabular output parsed by ParseTable().
+type TableOptions struct {
+	// Format selects how lines are split into columns.
+	Format TableFormat
+
+	// Delimiter separates columns when Format is
+	// TableDelimited. If zero, a comma is used.
+	Delimiter rune
+
+	// Columns are the names of the columns. If there is a header
+	// line, they override the names found in it; for
+	// TableFixedWidth they are located in the header line, which
+	// allows names containing spaces such as "Mounted on". If
+	// NoHeader is true, Columns is required.
+	Columns []string
+
+	// NoHeader is true if the output has no header line.
+	NoHeader bool
+
+	// SkipLines is the number of lines to skip before the header
+	// line or first row, e.g., for titles.
+	SkipLines int
+}
+
+// ParseTable parses tabular command output into one map per row
+// keyed by column name. Blank lines are ignored. Rows with fewer
+// values than columns have empty values for the missing columns.
+func ParseTable(output string, opts TableOptions) ([]map[string]string, error) {
+	if opts.Format == TableDelimited {
+		return parseDelimitedTable(output, opts)
+	}
+	ls := lines(output)
+	if opts.SkipLines > len(ls) {
+		return nil, nil
+	}
+	ls = ls[opts.SkipLines:]
+	if opts.NoHeader {
+		if opts.Format == TableFixedWidth {
+			return nil, fmt.Errorf("fixed width tables require a header line")
+		}
+		if len(opts.Columns) == 0 {
+			return nil, fmt.Errorf("columns are required for tables without a header line")
+		}
+		return splitTableRows(ls, opts.Columns), nil
+	}
+	if len(ls) == 0 {
+		return nil, nil
+	}
+	header, rows := ls[0], ls[1:]
+	if opts.Format == TableWhitespace {
+		columns := opts.Columns
+		if len(columns) == 0 {
+			columns = strings.Fields(header)
+		}
+		return splitTableRows(rows, columns), nil
+	}
+
+	spans, err := tableSpans(header, opts.Columns)
+	if err != nil {
+		return nil, err
+	}
+	var table []map[string]string
+	for _, line := range rows {
+		values := make([][]string, len(spans))
+		for _, w := range tableWords(line) {
+			i := nearestSpan(spans, w)
+			values[i] = append(values[i], w.text)
+		}
+		row := make(map[string]string, len(spans))
+		for i, s := range spans {
+			row[s.name] = strings.Join(values[i], " ")
+		}
+		table = append(table, row)
+	}
+
+	return table, nil
+}
+
+// UnmarshalTable parses tabular command output using ParseTable() and
+// stores the rows in the slice of structs pointed to by v. Columns
+// are matched to exported fields using a `table:"name"` tag or, if
+// there is none, by comparing the field name and column name ignoring
+// case and non-alphanumeric characters, e.g., the "Use%" column is
+// stored in a field named Use. String, integer, floating point, and
+// bool fields are supported; empty values leave fields at their zero
+// value.
+func UnmarshalTable(output string, opts TableOptions, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Slice ||
+		rv.Elem().Type().Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("UnmarshalTable requires a pointer to a slice of structs, not %T", v)
+	}
+	table, err := ParseTable(output, opts)
+	if err != nil {
+		return err
+	}
+	slice := rv.Elem()
+	elemType := slice.Type().Elem()
+	fields := tableFields(elemType)
+	rows := reflect.MakeSlice(slice.Type(), 0, len(table))
+	for n, row := range table {
+		elem := reflect.New(elemType).Elem()
+		for column, value := range row {
+			i, ok := fields[column]
+			if !ok {
+				i, ok = fields[normalizeColumnName(column)]
+			}
+			if !ok || value == "" {
+				continue
+			}
+			if err := setTableField(elem.Field(i), value); err != nil {
+				return fmt.Errorf("row %d column %q: %w", n+1, column, err)
+			}
+		}
+		rows = reflect.Append(rows, elem)
+	}
+	slice.Set(rows)
+
+	return nil
+}
+
+// tableSpan is the position of a column name in a header line.
+type tableSpan struct {
+	name       string
+	start, end int
+}
+
+// tableWord is a whitespace separated word and its position.
+type tableWord struct {
+	text       string
+	start, end int
+}
+
+// tableSpans locates the columns in header. If columns is empty, each
+// whitespace separated word of header is a column.
+func tableSpans(header string, columns []string) ([]tableSpan, error) {
+	var spans []tableSpan
+	if len(columns) == 0 {
+		for _, w := range tableWords(header) {
+			spans = append(spans, tableSpan{name: w.text, start: w.start, end: w.end})
+		}
+		return spans, nil
+	}
+	offset := 0
+	for _, c := range columns {
+		i := strings.Index(header[offset:], c)
+		if i < 0 {
+			return nil, fmt.Errorf("column %q not found in header %q", c, header)
+		}
+		start := utf8.RuneCountInString(header[:offset+i])
+		spans = append(spans, tableSpan{name: c, start: start, end: start + utf8.RuneCountInString(c)})
+		offset += i + len(c)
+	}
+
+	return spans, nil
+}
+
+// tableWords splits line at whitespace and records the rune position
+// of each word.
+func tableWords(line string) []tableWord {
+	var words []tableWord
+	runes := []rune(line)
+	for i := 0; i < len(runes); {
+		if unicode.IsSpace(runes[i]) {
+			i++
+			continue
+		}
+		j := i
+		for j < len(runes) && !unicode.IsSpace(runes[j]) {
+			j++
+		}
+		words = append(words, tableWord{text: string(runes[i:j]), start: i, end: j})
+		i = j
+	}
+
+	return words
+}
+
+// nearestSpan returns the index of the span w overlaps the most or,
+// if it overlaps none, of the last span starting before w since
+// values wider than their column name are left aligned. Right aligned
+// values always overlap their column name.
+func nearestSpan(spans []tableSpan, w tableWord) int {
+	best, bestOverlap := 0, 0
+	for i, s := range spans {
+		overlap := minInt(s.end, w.end) - maxInt(s.start, w.start)
+		if overlap > bestOverlap {
+			best, bestOverlap = i, overlap
+		}
+	}
+	if bestOverlap > 0 {
+		return best
+	}
+	for i, s := range spans {
+		if s.start <= w.start {
+			best = i
+		}
+	}
+
+	return best
+}
+
+// splitTableRows splits rows at whitespace into columns, joining
+// extra values to the last column.
+func splitTableRows(rows []string, columns []string) []map[string]string {
+	var table []map[string]string
+	for _, line := range rows {
+		fields := strings.Fields(line)
+		row := make(map[string]string, len(columns))
+		for i, c := range columns {
+			switch {
+			case i >= len(fields):
+				row[c] = ""
+			case i == len(columns)-1:
+				row[c] = strings.Join(fields[i:], " ")
+			default:
+				row[c] = fields[i]
+			}
+		}
+		table = append(table, row)
+	}
+
+	return table
+}
+
+func parseDelimitedTable(output string, opts TableOptions) ([]map[string]string, error) {
+	reader := csv.NewReader(strings.NewReader(output))
+	reader.Comma = ','
+	if opts.Delimiter != 0 {
+		reader.Comma = opts.Delimiter
+	}
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if opts.SkipLines > len(records) {
+		return nil, nil
+	}
+	records = records[opts.SkipLines:]
+	columns := opts.Columns
+	if !opts.NoHeader {
+		if len(records) == 0 {
+			return nil, nil
+		}
+		if len(columns) == 0 {
+			columns = records[0]
+		}
+		records = records[1:]
+	}
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("columns are required for tables without a header line")
+	}
+	var table []map[string]string
+	for _, record := range records {
+		row := make(map[string]string, len(columns))
+		for i, c := range columns {
+			if i < len(record) {
+				row[c] = record[i]
+			} else {
+				row[c] = ""
+			}
+		}
+		table = append(table, row)
+	}
+
+	return table, nil
+}
+
+// tableFields maps the tag names and normalized names of the exported
+// fields of t to their indexes.
+func tableFields(t reflect.Type) map[string]int {
+	fields := make(map[string]int)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		if tag := f.Tag.Get("table"); tag != "" {
+			fields[tag] = i
+			continue
+		}
+		fields[normalizeColumnName(f.Name)] = i
+	}
+
+	return fields
+}
+
+// normalizeColumnName lower cases name and removes non-alphanumeric
+// characters.
+func normalizeColumnName(name string) string {
+	var b strings.Builder
+	for _, c := range name {
+		if unicode.IsLetter(c) || unicode.IsDigit(c) {
+			b.WriteRune(unicode.ToLower(c))
+		}
+	}
+
+	return b.String()
+}
+
+func setTableField(f reflect.Value, value string) error {
+	switch f.Kind() {
+	case reflect.String:
+		f.SetString(value)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, f.Type().Bits())
+		if err != nil {
+			return err
+		}
+		f.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(value, 10, f.Type().Bits())
+		if err != nil {
+			return err
+		}
+		f.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(strings.TrimSuffix(value, "%"), f.Type().Bits())
+		if err != nil {
+			return err
+		}
+		f.SetFloat(n)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		f.SetBool(b)
+	default:
+		return fmt.Errorf("unsupported field type %s", f.Type())
+	}
+
+	return nil
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+
+	return b
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+
+	return b
+}
@@ -0,0 +1,127 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun_test
+
+import (
+	"testing"
+
+	"github.com/apatters/go-logrun"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const dfOutput = `Filesystem     1024-blocks     Used Available Capacity Mounted on
+/dev/sda1         20961280  5242880  15718400      26% /
+tmpfs              1024000        0   1024000       0% /mnt/my data
+`
+
+func TestParseTable_FixedWidth(t *testing.T) {
+	table, err := logrun.ParseTable(dfOutput, logrun.TableOptions{
+		Format:  logrun.TableFixedWidth,
+		Columns: []string{"Filesystem", "1024-blocks", "Used", "Available", "Capacity", "Mounted on"},
+	})
+	t.Logf("table = %v", table)
+	require.NoError(t, err)
+	require.Len(t, table, 2)
+	assert.Equal(t, map[string]string{
+		"Filesystem":  "tmpfs",
+		"1024-blocks": "1024000",
+		"Used":        "0",
+		"Available":   "1024000",
+		"Capacity":    "0%",
+		"Mounted on":  "/mnt/my data",
+	}, table[1])
+
+	table, err = logrun.ParseTable(`CONTAINER ID   IMAGE          COMMAND                  STATUS
+4c01db0b339c   nginx:1.17     "nginx -g 'daemon of…"   Up 2 hours
+d7886598dbe2   redis          "redis-server"           Exited (0) 3 days ago
+`, logrun.TableOptions{
+		Format:  logrun.TableFixedWidth,
+		Columns: []string{"CONTAINER ID", "IMAGE", "COMMAND", "STATUS"},
+	})
+	t.Logf("table = %v", table)
+	require.NoError(t, err)
+	require.Len(t, table, 2)
+	assert.Equal(t, `"nginx -g 'daemon of…"`, table[0]["COMMAND"])
+	assert.Equal(t, "Up 2 hours", table[0]["STATUS"])
+	assert.Equal(t, "redis", table[1]["IMAGE"])
+	assert.Equal(t, "Exited (0) 3 days ago", table[1]["STATUS"])
+
+	_, err = logrun.ParseTable(dfOutput, logrun.TableOptions{
+		Format:  logrun.TableFixedWidth,
+		Columns: []string{"Missing"},
+	})
+	assert.Error(t, err)
+}
+
+func TestParseTable_Whitespace(t *testing.T) {
+	table, err := logrun.ParseTable("  PID USER     COMMAND\n    1 root     /sbin/init splash\n   12 www\n",
+		logrun.TableOptions{})
+	t.Logf("table = %v", table)
+	require.NoError(t, err)
+	assert.Equal(t, []map[string]string{
+		{"PID": "1", "USER": "root", "COMMAND": "/sbin/init splash"},
+		{"PID": "12", "USER": "www", "COMMAND": ""},
+	}, table)
+
+	table, err = logrun.ParseTable("title\n1 a\n2 b\n", logrun.TableOptions{
+		SkipLines: 1,
+		NoHeader:  true,
+		Columns:   []string{"n", "s"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []map[string]string{{"n": "1", "s": "a"}, {"n": "2", "s": "b"}}, table)
+
+	_, err = logrun.ParseTable("1 a\n", logrun.TableOptions{NoHeader: true})
+	assert.Error(t, err)
+}
+
+func TestParseTable_Delimited(t *testing.T) {
+	table, err := logrun.ParseTable("name,comment\nweb1,\"says \"\"hi\"\", bye\"\nweb2\n",
+		logrun.TableOptions{Format: logrun.TableDelimited})
+	t.Logf("table = %v", table)
+	require.NoError(t, err)
+	assert.Equal(t, []map[string]string{
+		{"name": "web1", "comment": `says "hi", bye`},
+		{"name": "web2", "comment": ""},
+	}, table)
+
+	table, err = logrun.ParseTable("a\tb c\n", logrun.TableOptions{
+		Format:    logrun.TableDelimited,
+		Delimiter: '\t',
+		NoHeader:  true,
+		Columns:   []string{"x", "y"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []map[string]string{{"x": "a", "y": "b c"}}, table)
+}
+
+type dfRow struct {
+	Filesystem string
+	Blocks     uint64 `table:"1024-blocks"`
+	Used       uint64
+	Available  uint64
+	Capacity   float64
+	MountedOn  string
+}
+
+func TestUnmarshalTable(t *testing.T) {
+	var rows []dfRow
+	err := logrun.UnmarshalTable(dfOutput, logrun.TableOptions{
+		Format:  logrun.TableFixedWidth,
+		Columns: []string{"Filesystem", "1024-blocks", "Used", "Available", "Capacity", "Mounted on"},
+	}, &rows)
+	t.Logf("rows = %+v", rows)
+	require.NoError(t, err)
+	assert.Equal(t, []dfRow{
+		{"/dev/sda1", 20961280, 5242880, 15718400, 26, "/"},
+		{"tmpfs", 1024000, 0, 1024000, 0, "/mnt/my data"},
+	}, rows)
+
+	err = logrun.UnmarshalTable("Used\nlots\n", logrun.TableOptions{}, &rows)
+	t.Logf("err = %v", err)
+	assert.Error(t, err)
+	assert.Error(t, logrun.UnmarshalTable(dfOutput, logrun.TableOptions{}, rows))
+}
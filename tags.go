@@ -0,0 +1,247 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// SetTags sets the tags of the host the LogRun runs commands on, e.g.,
+// {"role": "web", "dc": "us-east"}. Tags are used to select hosts of
+// a Pool using Pool.Select(). SetTags replaces any tags set earlier.
+func (r *LogRun) SetTags(tags map[string]string) {
+	r.tags = make(map[string]string, len(tags))
+	for k, v := range tags {
+		r.tags[k] = v
+	}
+}
+
+// Tags returns a copy of the tags of the host.
+func (r *LogRun) Tags() map[string]string {
+	tags := make(map[string]string, len(r.tags))
+	for k, v := range r.tags {
+		tags[k] = v
+	}
+
+	return tags
+}
+
+// Selector matches the tags of hosts. See ParseSelector().
+type Selector struct {
+	text  string
+	match func(tags map[string]string) bool
+}
+
+// ParseSelector parses a tag selector such as "role=web && dc=us-east".
+// A selector is made up of the following terms, which can be combined
+// using "&&", "||", "!", and parentheses, with "&&" binding tighter
+// than "||":
+//
+//	key=value   the tag key is set to value ("==" is also accepted)
+//	key!=value  the tag key is not set to value
+//	key         the tag key is set
+//
+// Keys and values are made up of letters, digits, and the characters
+// "._-/:". The empty selector matches every host.
+func ParseSelector(s string) (Selector, error) {
+	tokens, err := selectorTokens(s)
+	if err != nil {
+		return Selector{}, err
+	}
+	sel := Selector{text: s}
+	if len(tokens) == 0 {
+		sel.match = func(map[string]string) bool { return true }
+		return sel, nil
+	}
+	p := &selectorParser{tokens: tokens}
+	sel.match, err = p.or()
+	if err == nil && p.pos < len(p.tokens) {
+		err = fmt.Errorf("unexpected %q", p.tokens[p.pos])
+	}
+	if err != nil {
+		return Selector{}, fmt.Errorf("invalid selector %q: %w", s, err)
+	}
+
+	return sel, nil
+}
+
+// MustParseSelector is like ParseSelector() but panics if the selector
+// is invalid.
+func MustParseSelector(s string) Selector {
+	sel, err := ParseSelector(s)
+	if err != nil {
+		panic(err)
+	}
+
+	return sel
+}
+
+// Matches returns true if tags match the selector.
+func (sel Selector) Matches(tags map[string]string) bool {
+	if sel.match == nil {
+		return true
+	}
+
+	return sel.match(tags)
+}
+
+// String returns the selector as it was parsed.
+func (sel Selector) String() string {
+	return sel.text
+}
+
+// selectorOperators are the operators of selectors, longest first.
+var selectorOperators = []string{"&&", "||", "==", "!=", "=", "!", "(", ")"}
+
+// selectorTokens splits s into words and operators.
+func selectorTokens(s string) ([]string, error) {
+	var tokens []string
+	for i := 0; i < len(s); {
+		c := rune(s[i])
+		if unicode.IsSpace(c) {
+			i++
+			continue
+		}
+		if isSelectorWordChar(c) {
+			j := i
+			for j < len(s) && isSelectorWordChar(rune(s[j])) {
+				j++
+			}
+			tokens = append(tokens, s[i:j])
+			i = j
+			continue
+		}
+		op := ""
+		for _, o := range selectorOperators {
+			if strings.HasPrefix(s[i:], o) {
+				op = o
+				break
+			}
+		}
+		if op == "" {
+			return nil, fmt.Errorf("invalid selector %q: unexpected %q", s, s[i:i+1])
+		}
+		tokens = append(tokens, op)
+		i += len(op)
+	}
+
+	return tokens, nil
+}
+
+func isSelectorWordChar(c rune) bool {
+	return c < unicode.MaxASCII && (unicode.IsLetter(c) || unicode.IsDigit(c) || strings.ContainsRune("._-/:", c))
+}
+
+// selectorParser is a recursive descent parser for selectors.
+type selectorParser struct {
+	tokens []string
+	pos    int
+}
+
+type tagMatcher func(tags map[string]string) bool
+
+func (p *selectorParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+
+	return ""
+}
+
+func (p *selectorParser) or() (tagMatcher, error) {
+	left, err := p.and()
+	for err == nil && p.peek() == "||" {
+		p.pos++
+		var right tagMatcher
+		if right, err = p.and(); err == nil {
+			l := left
+			left = func(tags map[string]string) bool { return l(tags) || right(tags) }
+		}
+	}
+
+	return left, err
+}
+
+func (p *selectorParser) and() (tagMatcher, error) {
+	left, err := p.unary()
+	for err == nil && p.peek() == "&&" {
+		p.pos++
+		var right tagMatcher
+		if right, err = p.unary(); err == nil {
+			l := left
+			left = func(tags map[string]string) bool { return l(tags) && right(tags) }
+		}
+	}
+
+	return left, err
+}
+
+func (p *selectorParser) unary() (tagMatcher, error) {
+	switch p.peek() {
+	case "!":
+		p.pos++
+		m, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return func(tags map[string]string) bool { return !m(tags) }, nil
+	case "(":
+		p.pos++
+		m, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ")" {
+			return nil, fmt.Errorf("missing )")
+		}
+		p.pos++
+		return m, nil
+	}
+
+	return p.term()
+}
+
+func (p *selectorParser) term() (tagMatcher, error) {
+	key := p.word()
+	if key == "" {
+		if p.pos < len(p.tokens) {
+			return nil, fmt.Errorf("unexpected %q", p.peek())
+		}
+		return nil, fmt.Errorf("unexpected end")
+	}
+	op := p.peek()
+	if op != "=" && op != "==" && op != "!=" {
+		return func(tags map[string]string) bool {
+			_, ok := tags[key]
+			return ok
+		}, nil
+	}
+	p.pos++
+	value := p.word()
+	if value == "" {
+		return nil, fmt.Errorf("missing value for %s", key)
+	}
+	if op == "!=" {
+		return func(tags map[string]string) bool { return tags[key] != value }, nil
+	}
+
+	return func(tags map[string]string) bool {
+		v, ok := tags[key]
+		return ok && v == value
+	}, nil
+}
+
+// word consumes and returns the next token if it is a word.
+func (p *selectorParser) word() string {
+	t := p.peek()
+	if t == "" || !isSelectorWordChar(rune(t[0])) {
+		return ""
+	}
+	p.pos++
+
+	return t
+}
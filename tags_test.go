@@ -0,0 +1,103 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun_test
+
+import (
+	"testing"
+
+	"github.com/apatters/go-logrun"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSelector(t *testing.T) {
+	tags := map[string]string{"role": "web", "dc": "us-east", "canary": ""}
+	tests := []struct {
+		selector string
+		match    bool
+	}{
+		{"", true},
+		{"role=web", true},
+		{"role==web", true},
+		{"role=db", false},
+		{"role!=db", true},
+		{"missing!=db", true},
+		{"canary", true},
+		{"!canary", false},
+		{"missing", false},
+		{"role=web && dc=us-east", true},
+		{"role=web && dc=eu-west", false},
+		{"role=db || dc=us-east", true},
+		{"role=db || dc=eu-west && canary", false},
+		{"role=web || role=db && dc=eu-west", true},
+		{"(role=web || role=db) && dc=eu-west", false},
+		{"!(role=db) && dc=us-east", true},
+	}
+	for _, test := range tests {
+		sel, err := logrun.ParseSelector(test.selector)
+		require.NoError(t, err, test.selector)
+		t.Logf("%q matches = %t", sel, sel.Matches(tags))
+		assert.Equal(t, test.match, sel.Matches(tags), test.selector)
+	}
+
+	for _, s := range []string{"role=", "=web", "role=web &&", "(role=web", "role=web)", "role=web & dc=x", "role web"} {
+		_, err := logrun.ParseSelector(s)
+		t.Logf("%q: err = %v", s, err)
+		assert.Error(t, err, s)
+	}
+	assert.Panics(t, func() { logrun.MustParseSelector("&&") })
+}
+
+func TestPool_Select(t *testing.T) {
+	var configs []logrun.RemoteConfig
+	for _, host := range []struct {
+		name string
+		tags map[string]string
+	}{
+		{"web1", map[string]string{"role": "web", "dc": "us-east"}},
+		{"web2", map[string]string{"role": "web", "dc": "eu-west"}},
+		{"db1", map[string]string{"role": "db", "dc": "us-east"}},
+	} {
+		configs = append(configs, logrun.RemoteConfig{
+			Credentials: logrun.Credentials{
+				Hostname: host.name,
+				Username: "tester",
+				Password: "secret",
+			},
+			Dryrun: true,
+			Tags:   host.tags,
+		})
+	}
+	p, err := logrun.NewRemotePool(configs, logrun.PoolConfig{})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"role": "db", "dc": "us-east"}, p.Runners()[2].Tags())
+	assert.Equal(t, "db", p.Runners()[2].Config().Tags["role"])
+
+	web, err := p.Select("role=web")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"web1", "web2"}, web.Hosts())
+
+	east, err := p.Select("role=web && dc=us-east")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"web1"}, east.Hosts())
+	results := east.Shell("systemctl reload nginx")
+	t.Logf("results = %+v", results)
+	assert.Equal(t, []string{"web1"}, results.Hosts())
+
+	none, err := p.Select("role=cache")
+	require.NoError(t, err)
+	assert.Empty(t, none.Hosts())
+	assert.Empty(t, none.Run("/bin/true"))
+
+	_, err = p.Select("role=")
+	t.Logf("err = %v", err)
+	assert.Error(t, err)
+
+	// Tags can be changed after the Pool is created.
+	p.Runners()[2].SetTags(map[string]string{"role": "web"})
+	web, err = p.Select("role=web")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"web1", "web2", "db1"}, web.Hosts())
+}
@@ -0,0 +1,406 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Task is a step of a TaskList.
+type Task struct {
+	// Name identifies the task in DependsOn and in the results of
+	// the TaskList. It is required and must be unique.
+	Name string
+
+	// DependsOn are the names of the tasks that must succeed
+	// before the task is run. Tasks that do not depend on each
+	// other, directly or indirectly, may run at the same time.
+	DependsOn []string
+
+	// Run performs the task using a copy of the LogRun the
+	// TaskList is run with, made by With(), in a section named
+	// after the task. A non-nil error fails the task.
+	Run func(r *LogRun) error
+
+	// Timeout is the maximum amount of time each attempt of the
+	// task is allowed to take. When it expires, the commands the
+	// task is running are killed, the commands it runs afterwards
+	// fail, and the attempt fails once Run returns. If zero, the
+	// task is not timed out. Only the runners created by the LogRun
+	// constructors support timeouts.
+	Timeout time.Duration
+
+	// Retries is the number of times the task is run again after
+	// it fails, waiting RetryDelay between attempts.
+	Retries    int
+	RetryDelay time.Duration
+
+	// IgnoreFailure, if true, lets the tasks that depend on the
+	// task run even if it fails. The failure is reported in its
+	// TaskResult but is not returned by TaskResults.Err().
+	IgnoreFailure bool
+
+	// OnFailure, if not nil, is called with the LogRun of the task
+	// once its last attempt has failed, e.g., to roll back or to
+	// collect diagnostics. The error it returns replaces the error
+	// of the task, so returning nil recovers from the failure and
+	// returning err leaves the task failed.
+	OnFailure func(r *LogRun, err error) error
+}
+
+// TaskListConfig is used to set options in the NewTaskList
+// constructor.
+type TaskListConfig struct {
+	// Concurrency is the maximum number of tasks run at the same
+	// time. If zero, all tasks whose dependencies have succeeded
+	// are run at the same time.
+	Concurrency int
+
+	// Vars are the group variables inherited by the LogRuns the
+	// tasks are run with, overriding those inherited from a Pool.
+	// Variables set for the host using LogRun.SetVars() override
+	// them.
+	Vars map[string]string
+}
+
+// TaskList runs a set of tasks on a host, running tasks as soon as
+// the tasks they depend on have succeeded, so independent steps run in
+// parallel while ordered ones wait, e.g.,
+//
+//	tasks, err := logrun.NewTaskList([]logrun.Task{
+//		{Name: "packages", Run: installPackages},
+//		{Name: "users", Run: createUsers},
+//		{Name: "app", DependsOn: []string{"packages", "users"}, Run: deployApp},
+//	}, logrun.TaskListConfig{})
+//	...
+//	if err := tasks.Run(runner).Err(); err != nil {
+//		...
+//	}
+type TaskList struct {
+	tasks       []Task
+	index       map[string]int
+	concurrency int
+	vars        map[string]string
+}
+
+// TaskResult is the outcome of a task run by a TaskList.
+type TaskResult struct {
+	// Name is the name of the task.
+	Name string
+
+	// Err is the error returned by the task, if any.
+	Err error
+
+	// Skipped is true if the task was not run because a task it
+	// depends on failed or was skipped.
+	Skipped bool
+
+	// Duration is how long the task took to run, including all of
+	// its attempts.
+	Duration time.Duration
+
+	// Attempts is the number of times the task was run.
+	Attempts int
+
+	// TimedOut is true if the last attempt of the task exceeded
+	// its Timeout.
+	TimedOut bool
+
+	// Ignored is true if the task failed but its failure was
+	// ignored because of IgnoreFailure.
+	Ignored bool
+
+	// Recovered is true if the task failed but its OnFailure
+	// handler returned nil.
+	Recovered bool
+}
+
+// Failed returns true if the task was run and returned an error whose
+// failure was not ignored.
+func (res TaskResult) Failed() bool {
+	return res.Err != nil && !res.Ignored
+}
+
+// status returns the outcome of the task as reported in a Summary.
+func (res TaskResult) status() string {
+	switch {
+	case res.Skipped:
+		return "skipped"
+	case res.Ignored:
+		return "ignored"
+	case res.TimedOut:
+		return "timed out"
+	case res.Err != nil:
+		return "failed"
+	case res.Recovered:
+		return "recovered"
+	}
+
+	return "ok"
+}
+
+// TaskResults are the results of the tasks of a TaskList in the order
+// the tasks were given to NewTaskList().
+type TaskResults []TaskResult
+
+// Err returns an error listing the failed and skipped tasks, or nil if
+// every task succeeded. Tasks whose failure was ignored are not
+// listed.
+func (results TaskResults) Err() error {
+	var failed, skipped []string
+	for _, res := range results {
+		switch {
+		case res.Failed():
+			failed = append(failed, fmt.Sprintf("%s: %s", res.Name, res.Err))
+		case res.Skipped:
+			skipped = append(skipped, res.Name)
+		}
+	}
+	if len(failed) == 0 && len(skipped) == 0 {
+		return nil
+	}
+	msg := trf("%d task(s) failed", len(failed))
+	if len(failed) > 0 {
+		msg += " (" + strings.Join(failed, "; ") + ")"
+	}
+	if len(skipped) > 0 {
+		msg += trf(", %d skipped (%s)", len(skipped), strings.Join(skipped, ", "))
+	}
+
+	return fmt.Errorf("%s", msg)
+}
+
+// NewTaskList returns a TaskList that runs tasks. An error is returned
+// if a task has no name or a duplicate name, depends on an unknown
+// task, or if the dependencies form a cycle.
+func NewTaskList(tasks []Task, config TaskListConfig) (*TaskList, error) {
+	l := &TaskList{
+		tasks:       tasks,
+		index:       make(map[string]int, len(tasks)),
+		concurrency: config.Concurrency,
+		vars:        config.Vars,
+	}
+	for i, t := range tasks {
+		switch {
+		case t.Name == "":
+			return nil, fmt.Errorf("task %d has no name", i+1)
+		case t.Run == nil:
+			return nil, fmt.Errorf("task %s has no Run function", t.Name)
+		}
+		if _, ok := l.index[t.Name]; ok {
+			return nil, fmt.Errorf("duplicate task %s", t.Name)
+		}
+		l.index[t.Name] = i
+	}
+	for _, t := range tasks {
+		for _, dep := range t.DependsOn {
+			if _, ok := l.index[dep]; !ok {
+				return nil, fmt.Errorf("task %s depends on unknown task %s", t.Name, dep)
+			}
+		}
+	}
+	if cycle := l.cycle(); cycle != nil {
+		return nil, fmt.Errorf("task dependency cycle: %s", strings.Join(cycle, " -> "))
+	}
+
+	return l, nil
+}
+
+// SetConcurrency sets the maximum number of tasks run at the same
+// time. If zero, all tasks whose dependencies have succeeded are run
+// at the same time.
+func (l *TaskList) SetConcurrency(n int) {
+	l.concurrency = n
+}
+
+// Tasks returns the tasks of the TaskList.
+func (l *TaskList) Tasks() []Task {
+	return l.tasks
+}
+
+// Run runs the tasks using r and waits for them to complete. A task
+// is run once all the tasks it depends on have succeeded and is
+// skipped if any of them failed or was skipped. Tasks that do not
+// depend on a failed task are still run. The outcome of each task is
+// recorded by the Recorder of r, if any, for its Summary().
+func (l *TaskList) Run(r *LogRun) TaskResults {
+	if len(l.vars) > 0 {
+		r = r.withGroupVars(l.vars)
+	}
+	results := make(TaskResults, len(l.tasks))
+	done := make([]chan struct{}, len(l.tasks))
+	for i := range done {
+		done[i] = make(chan struct{})
+	}
+	n := l.concurrency
+	if n <= 0 || n > len(l.tasks) {
+		n = len(l.tasks)
+	}
+	sem := make(chan struct{}, n)
+	clock := r.getClock()
+	var wg sync.WaitGroup
+	for i, t := range l.tasks {
+		wg.Add(1)
+		go func(i int, t Task) {
+			defer wg.Done()
+			defer close(done[i])
+			res := TaskResult{Name: t.Name}
+			for _, dep := range t.DependsOn {
+				j := l.index[dep]
+				<-done[j]
+				if results[j].Failed() || results[j].Skipped {
+					res.Skipped = true
+				}
+			}
+			if res.Skipped {
+				r.log(trf("skipped: task %s (dependency failed)", t.Name))
+				r.recordTask(res)
+				results[i] = res
+				return
+			}
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			c := r.With()
+			start := clock.Now()
+			c.WithSection(t.Name, func() error { // nolint: errcheck
+				res = runTask(c, t)
+				return res.Err
+			})
+			res.Duration = clock.Since(start)
+			r.recordTask(res)
+			results[i] = res
+		}(i, t)
+	}
+	wg.Wait()
+
+	return results
+}
+
+// runTask runs t using r, which is a copy of the LogRun the TaskList
+// is run with, applying the timeout, retries, and failure policy of
+// t.
+func runTask(r *LogRun, t Task) TaskResult {
+	res := TaskResult{Name: t.Name}
+	for {
+		res.Attempts++
+		res.TimedOut, res.Err = runTaskAttempt(r, t)
+		if res.Err == nil || res.Attempts > t.Retries {
+			break
+		}
+		r.log(trf("retrying: task %s (attempt %d of %d): %s",
+			t.Name, res.Attempts+1, t.Retries+1, res.Err))
+		if t.RetryDelay > 0 {
+			r.getClock().Sleep(t.RetryDelay)
+		}
+	}
+	if res.Err != nil && t.OnFailure != nil {
+		res.Err = t.OnFailure(r, res.Err)
+		res.Recovered = res.Err == nil
+	}
+	if res.Err != nil && t.IgnoreFailure {
+		r.log(trf("ignored: task %s failed: %s", t.Name, res.Err))
+		res.Ignored = true
+	}
+
+	return res
+}
+
+// runTaskAttempt runs t once using r. If t has a timeout, the commands
+// run by t are killed when it expires and true is returned.
+func runTaskAttempt(r *LogRun, t Task) (bool, error) {
+	if t.Timeout <= 0 {
+		return false, t.Run(r)
+	}
+	ctx, cancel := context.WithCancel(r.callContext())
+	defer cancel()
+	timer := r.getClock().NewTimer(t.Timeout)
+	defer timer.Stop()
+	var timedOut int32
+	go func() {
+		select {
+		case <-timer.C():
+			atomic.StoreInt32(&timedOut, 1)
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	c := r.With()
+	c.call.ctx = ctx
+	err := t.Run(c)
+	if atomic.LoadInt32(&timedOut) == 1 {
+		return true, fmt.Errorf("task %s timed out after %s", t.Name, t.Timeout)
+	}
+
+	return false, err
+}
+
+// recordTask records the outcome of a task in the Recorder of the
+// LogRun, if any.
+func (r *LogRun) recordTask(res TaskResult) {
+	if r.recorder == nil {
+		return
+	}
+	stat := TaskStat{
+		Host:     r.Host().String(),
+		Task:     res.Name,
+		Status:   res.status(),
+		Attempts: res.Attempts,
+		Duration: res.Duration,
+		Section:  r.Section(),
+	}
+	if res.Err != nil {
+		stat.Error = res.Err.Error()
+	}
+	r.recorder.recordTask(stat)
+}
+
+// cycle returns the names of the tasks forming a dependency cycle,
+// starting and ending with the same task, or nil if there is none.
+func (l *TaskList) cycle() []string {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make([]int, len(l.tasks))
+	var path []string
+	var visit func(i int) []string
+	visit = func(i int) []string {
+		state[i] = visiting
+		path = append(path, l.tasks[i].Name)
+		for _, dep := range l.tasks[i].DependsOn {
+			j := l.index[dep]
+			switch state[j] {
+			case visiting:
+				for k, name := range path {
+					if name == dep {
+						return append(append([]string{}, path[k:]...), dep)
+					}
+				}
+			case unvisited:
+				if cycle := visit(j); cycle != nil {
+					return cycle
+				}
+			}
+		}
+		path = path[:len(path)-1]
+		state[i] = visited
+		return nil
+	}
+	for i := range l.tasks {
+		if state[i] == unvisited {
+			if cycle := visit(i); cycle != nil {
+				return cycle
+			}
+		}
+	}
+
+	return nil
+}
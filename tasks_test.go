@@ -0,0 +1,210 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun_test
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/apatters/go-logrun"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTaskList_Run(t *testing.T) {
+	var mu sync.Mutex
+	var order []string
+	running, maxRunning := 0, 0
+	task := func(name string, deps ...string) logrun.Task {
+		return logrun.Task{
+			Name:      name,
+			DependsOn: deps,
+			Run: func(r *logrun.LogRun) error {
+				mu.Lock()
+				running++
+				if running > maxRunning {
+					maxRunning = running
+				}
+				mu.Unlock()
+				time.Sleep(50 * time.Millisecond)
+				r.Run("/bin/true")
+				mu.Lock()
+				running--
+				order = append(order, name)
+				mu.Unlock()
+				return nil
+			},
+		}
+	}
+	tasks, err := logrun.NewTaskList([]logrun.Task{
+		task("app", "packages", "users"),
+		task("packages"),
+		task("users"),
+		task("start", "app"),
+	}, logrun.TaskListConfig{})
+	require.NoError(t, err)
+
+	log, out, _ := newLogger()
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{LogFunc: log.Println})
+	results := tasks.Run(l)
+	t.Logf("results = %+v", results)
+	t.Logf("order = %q", order)
+	t.Logf("out = %q", out)
+	require.NoError(t, results.Err())
+	require.Len(t, results, 4)
+	assert.Equal(t, "app", results[0].Name)
+	assert.True(t, results[0].Duration >= 50*time.Millisecond)
+	assert.Equal(t, 2, maxRunning)
+	assert.ElementsMatch(t, []string{"packages", "users"}, order[:2])
+	assert.Equal(t, []string{"app", "start"}, order[2:])
+	assert.Contains(t, out.String(), "=== start\n  /bin/true\n")
+	assert.Empty(t, l.Section())
+
+	// Concurrency limits the tasks run at the same time.
+	order, maxRunning = nil, 0
+	tasks.SetConcurrency(1)
+	require.NoError(t, tasks.Run(l).Err())
+	assert.Equal(t, 1, maxRunning)
+}
+
+func TestTaskList_Failure(t *testing.T) {
+	var ran []string
+	var mu sync.Mutex
+	task := func(name string, err error, deps ...string) logrun.Task {
+		return logrun.Task{Name: name, DependsOn: deps, Run: func(r *logrun.LogRun) error {
+			mu.Lock()
+			ran = append(ran, name)
+			mu.Unlock()
+			return err
+		}}
+	}
+	failure := errors.New("boom")
+	tasks, err := logrun.NewTaskList([]logrun.Task{
+		task("a", failure),
+		task("b", nil, "a"),
+		task("c", nil, "b"),
+		task("d", nil),
+	}, logrun.TaskListConfig{})
+	require.NoError(t, err)
+
+	log, out, _ := newLogger()
+	results := tasks.Run(logrun.NewLocalLogRun(logrun.LocalConfig{LogFunc: log.Println}))
+	t.Logf("results = %+v", results)
+	t.Logf("out = %q", out)
+	assert.ElementsMatch(t, []string{"a", "d"}, ran)
+	assert.Equal(t, failure, results[0].Err)
+	assert.True(t, results[1].Skipped)
+	assert.True(t, results[2].Skipped)
+	assert.False(t, results[3].Failed())
+	err = results.Err()
+	t.Logf("err = %v", err)
+	assert.EqualError(t, err, "1 task(s) failed (a: boom), 2 skipped (b, c)")
+	assert.Contains(t, out.String(), "skipped: task b (dependency failed)\n")
+}
+
+func TestNewTaskList_Invalid(t *testing.T) {
+	run := func(r *logrun.LogRun) error { return nil }
+	for _, e := range []struct {
+		tasks    []logrun.Task
+		expected string
+	}{
+		{[]logrun.Task{{Run: run}}, "task 1 has no name"},
+		{[]logrun.Task{{Name: "a"}}, "task a has no Run function"},
+		{[]logrun.Task{{Name: "a", Run: run}, {Name: "a", Run: run}}, "duplicate task a"},
+		{[]logrun.Task{{Name: "a", DependsOn: []string{"x"}, Run: run}}, "task a depends on unknown task x"},
+		{[]logrun.Task{
+			{Name: "a", DependsOn: []string{"c"}, Run: run},
+			{Name: "b", DependsOn: []string{"a"}, Run: run},
+			{Name: "c", DependsOn: []string{"b"}, Run: run},
+		}, "task dependency cycle: a -> c -> b -> a"},
+	} {
+		_, err := logrun.NewTaskList(e.tasks, logrun.TaskListConfig{})
+		t.Logf("err = %v", err)
+		assert.EqualError(t, err, e.expected)
+	}
+}
+
+func TestTaskList_Policy(t *testing.T) {
+	attempts := 0
+	var handled error
+	tasks, err := logrun.NewTaskList([]logrun.Task{
+		{Name: "flaky", Retries: 2, Run: func(r *logrun.LogRun) error {
+			attempts++
+			if attempts < 3 {
+				return errors.New("not yet")
+			}
+			return nil
+		}},
+		{Name: "slow", Timeout: 100 * time.Millisecond, Run: func(r *logrun.LogRun) error {
+			_, _, code := r.Run("/bin/sleep", "5")
+			if code != 0 {
+				return errors.New("sleep failed")
+			}
+			return nil
+		}},
+		{Name: "optional", IgnoreFailure: true, Run: func(r *logrun.LogRun) error {
+			return errors.New("boom")
+		}},
+		{Name: "after-optional", DependsOn: []string{"optional"}, Run: func(r *logrun.LogRun) error {
+			return nil
+		}},
+		{Name: "rescued", Run: func(r *logrun.LogRun) error {
+			return errors.New("broken")
+		}, OnFailure: func(r *logrun.LogRun, err error) error {
+			handled = err
+			r.Run("/bin/true")
+			return nil
+		}},
+	}, logrun.TaskListConfig{})
+	require.NoError(t, err)
+
+	log, out, _ := newLogger()
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{LogFunc: log.Println})
+	l.SetRecorder(logrun.NewRecorder())
+	start := time.Now()
+	results := tasks.Run(l)
+	elapsed := time.Since(start)
+	t.Logf("results = %+v", results)
+	t.Logf("out = %q", out)
+	assert.True(t, elapsed < 4*time.Second, "elapsed = %s", elapsed)
+
+	assert.NoError(t, results[0].Err)
+	assert.Equal(t, 3, results[0].Attempts)
+	assert.Contains(t, out.String(), "retrying: task flaky (attempt 2 of 3): not yet\n")
+
+	assert.True(t, results[1].Failed())
+	assert.True(t, results[1].TimedOut)
+	assert.EqualError(t, results[1].Err, "task slow timed out after 100ms")
+
+	assert.Error(t, results[2].Err)
+	assert.True(t, results[2].Ignored)
+	assert.False(t, results[2].Failed())
+	assert.False(t, results[3].Skipped)
+	assert.Equal(t, 1, results[3].Attempts)
+
+	assert.NoError(t, results[4].Err)
+	assert.True(t, results[4].Recovered)
+	assert.EqualError(t, handled, "broken")
+
+	assert.EqualError(t, results.Err(), "1 task(s) failed (slow: task slow timed out after 100ms)")
+
+	s := l.Summary()
+	t.Logf("summary = %s", s)
+	status := make(map[string]string)
+	for _, task := range s.Tasks {
+		status[task.Task] = task.Status
+	}
+	assert.Equal(t, map[string]string{
+		"flaky":          "ok",
+		"slow":           "timed out",
+		"optional":       "ignored",
+		"after-optional": "ok",
+		"rescued":        "recovered",
+	}, status)
+	assert.Contains(t, s.String(), ": ok after 3 attempts in ")
+	assert.Contains(t, s.String(), ": timed out in ")
+}
@@ -0,0 +1,187 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"text/template"
+	"text/template/parse"
+)
+
+// rawString is a template value that is inserted into a command
+// without quoting.
+type rawString string
+
+// CommandTemplate is a command line written as a Go text/template
+// whose parameters are automatically shell-quoted, e.g.,
+//
+//	t := logrun.MustCommandTemplate("backup", "tar -czf {{.Archive}} -C {{.Dir}} .")
+//	runner.ShellTemplate(t, map[string]string{"Archive": "/tmp/my backup.tgz", "Dir": "/srv"})
+//
+// runs tar -czf '/tmp/my backup.tgz' -C '/srv' . The value of every
+// action is quoted as a single word; slices and arrays are quoted
+// element by element and joined with spaces. Use the raw function,
+// e.g., {{raw .Flags}}, to insert a value unquoted. When run with
+// RunTemplate() or ShellTemplate(), the var function returns the
+// value of a host variable of the LogRun, e.g., {{var "port"}}. See
+// SetVars().
+type CommandTemplate struct {
+	tmpl *template.Template
+}
+
+// NewCommandTemplate parses text as a command template named name.
+func NewCommandTemplate(name string, text string) (*CommandTemplate, error) {
+	tmpl, err := template.New(name).
+		Option("missingkey=error").
+		Funcs(template.FuncMap{
+			"raw":     func(v interface{}) rawString { return rawString(fmt.Sprint(v)) },
+			"shquote": templateQuote,
+			"var":     noVars,
+		}).
+		Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range tmpl.Templates() {
+		if t.Tree != nil {
+			quoteActions(t.Tree, t.Tree.Root)
+		}
+	}
+
+	return &CommandTemplate{tmpl: tmpl}, nil
+}
+
+// MustCommandTemplate is like NewCommandTemplate but panics if text
+// cannot be parsed. It is intended for package level template
+// variables.
+func MustCommandTemplate(name string, text string) *CommandTemplate {
+	t, err := NewCommandTemplate(name, text)
+	if err != nil {
+		panic(err)
+	}
+
+	return t
+}
+
+// Name returns the name of the template.
+func (t *CommandTemplate) Name() string {
+	return t.tmpl.Name()
+}
+
+// Render returns the command line produced by applying the template
+// to data.
+// Host variables are not available, so the var function fails.
+func (t *CommandTemplate) Render(data interface{}) (string, error) {
+	return t.render(t.tmpl, data)
+}
+
+// render applies tmpl, a clone of the template's parsed template with
+// possibly different functions, to data.
+func (t *CommandTemplate) render(tmpl *template.Template, data interface{}) (string, error) {
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(b.String()), nil
+}
+
+// noVars is the var function used when rendering a template without
+// a LogRun.
+func noVars(name string) (string, error) {
+	return "", fmt.Errorf("host variable %q is not available", name)
+}
+
+// quoteActions appends the shquote function to the pipeline of every
+// action in node that outputs a value.
+func quoteActions(tree *parse.Tree, node parse.Node) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			quoteActions(tree, child)
+		}
+	case *parse.ActionNode:
+		if len(n.Pipe.Decl) > 0 {
+			return
+		}
+		quote := parse.NewIdentifier("shquote").SetTree(tree).SetPos(n.Position())
+		n.Pipe.Cmds = append(n.Pipe.Cmds, &parse.CommandNode{
+			NodeType: parse.NodeCommand,
+			Pos:      n.Position(),
+			Args:     []parse.Node{quote},
+		})
+	case *parse.IfNode:
+		quoteActions(tree, n.List)
+		quoteActions(tree, n.ElseList)
+	case *parse.RangeNode:
+		quoteActions(tree, n.List)
+		quoteActions(tree, n.ElseList)
+	case *parse.WithNode:
+		quoteActions(tree, n.List)
+		quoteActions(tree, n.ElseList)
+	}
+}
+
+// templateQuote quotes the value of a template action.
+func templateQuote(v interface{}) string {
+	switch s := v.(type) {
+	case rawString:
+		return string(s)
+	case string:
+		return shellQuote(s)
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array {
+		words := make([]string, rv.Len())
+		for i := range words {
+			words[i] = templateQuote(rv.Index(i).Interface())
+		}
+		return strings.Join(words, " ")
+	}
+
+	return shellQuote(fmt.Sprint(v))
+}
+
+// RenderTemplate returns the command line produced by applying t to
+// data with the var function returning the host variables of the
+// LogRun.
+func (r *LogRun) RenderTemplate(t *CommandTemplate, data interface{}) (string, error) {
+	tmpl, err := t.tmpl.Clone()
+	if err != nil {
+		return "", err
+	}
+	tmpl.Funcs(template.FuncMap{"var": r.lookupVar})
+
+	return t.render(tmpl, data)
+}
+
+// ShellTemplate renders t with data and runs the resulting command
+// line using Shell(). If t cannot be rendered, nothing is logged or
+// run, and the error is returned as the standard error along with
+// ExitErrorExecute.
+func (r *LogRun) ShellTemplate(t *CommandTemplate, data interface{}) (string, string, int) {
+	cmd, err := r.RenderTemplate(t, data)
+	if err != nil {
+		return "", err.Error(), ExitErrorExecute
+	}
+
+	return r.Shell(cmd)
+}
+
+// RunTemplate renders t with data and runs the resulting command line
+// without a shell using RunLine().
+func (r *LogRun) RunTemplate(t *CommandTemplate, data interface{}) (string, string, int) {
+	cmd, err := r.RenderTemplate(t, data)
+	if err != nil {
+		return "", err.Error(), ExitErrorExecute
+	}
+
+	return r.RunLine(cmd)
+}
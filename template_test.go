@@ -0,0 +1,109 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/apatters/go-logrun"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type commandTemplateTestEntry struct {
+	Description string
+	Text        string
+	Data        interface{}
+	Expected    string
+}
+
+var commandTemplateTestTable = []commandTemplateTestEntry{
+	{
+		"Simple parameters",
+		"tar -czf {{.Archive}} -C {{.Dir}} .",
+		map[string]string{"Archive": "/tmp/my backup.tgz", "Dir": "/srv"},
+		"tar -czf '/tmp/my backup.tgz' -C '/srv' .",
+	},
+	{
+		"Embedded quotes and shell syntax",
+		"echo {{.}}",
+		"it's $(rm -rf /); `true`",
+		`echo 'it'"'"'s $(rm -rf /); ` + "`true`'",
+	},
+	{
+		"Slice parameters",
+		"rm -f {{.}}",
+		[]string{"a b", "c"},
+		"rm -f 'a b' 'c'",
+	},
+	{
+		"Raw parameters",
+		"ls {{raw .Flags}} {{.Dir}}",
+		map[string]string{"Flags": "-l -a", "Dir": "/tmp"},
+		"ls -l -a '/tmp'",
+	},
+	{
+		"Control structures",
+		"{{if .Force}}rm -rf{{else}}rm{{end}} {{range .Paths}}{{.}} {{end}}",
+		struct {
+			Force bool
+			Paths []string
+		}{true, []string{"/tmp/x", "/tmp/y z"}},
+		"rm -rf '/tmp/x' '/tmp/y z'",
+	},
+	{
+		"Numbers",
+		"sleep {{.}}",
+		5,
+		"sleep '5'",
+	},
+}
+
+func TestCommandTemplate_Render(t *testing.T) {
+	for _, e := range commandTemplateTestTable {
+		t.Log(e.Description)
+		tmpl, err := logrun.NewCommandTemplate(e.Description, e.Text)
+		require.NoError(t, err)
+		cmd, err := tmpl.Render(e.Data)
+		t.Logf("cmd = %q", cmd)
+		require.NoError(t, err)
+		assert.Equal(t, e.Expected, cmd)
+	}
+}
+
+func TestCommandTemplate_Errors(t *testing.T) {
+	_, err := logrun.NewCommandTemplate("bad", "echo {{.Foo")
+	assert.Error(t, err)
+	assert.Panics(t, func() { logrun.MustCommandTemplate("bad", "echo {{.Foo") })
+
+	tmpl := logrun.MustCommandTemplate("missing", "echo {{.Foo}}")
+	assert.Equal(t, "missing", tmpl.Name())
+	_, err = tmpl.Render(map[string]string{})
+	t.Logf("err = %v", err)
+	assert.Error(t, err)
+
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{})
+	_, stderr, code := l.ShellTemplate(tmpl, map[string]string{})
+	assert.Equal(t, logrun.ExitErrorExecute, code)
+	assert.Contains(t, stderr, "Foo")
+}
+
+func TestLocalLogRun_ShellTemplate(t *testing.T) {
+	log, out, _ := newLogger()
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{
+		LogFunc: log.Println,
+	})
+	tmpl := logrun.MustCommandTemplate("greet", "printf '%s\\n' {{.}} | wc -l")
+	stdout, _, code := l.ShellTemplate(tmpl, []string{"a b", "it's; true"})
+	t.Logf("out = %q", out)
+	assert.Zero(t, code)
+	assert.Equal(t, "2", strings.TrimSpace(stdout))
+
+	tmpl = logrun.MustCommandTemplate("echo", "/bin/echo {{.}}")
+	stdout, _, code = l.RunTemplate(tmpl, "$HOME; ls")
+	assert.Zero(t, code)
+	assert.Equal(t, "$HOME; ls\n", stdout)
+}
@@ -0,0 +1,197 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LineTimestampFormat is the time layout of the timestamps prefixed to
+// the lines of output sent to writers when WithLineTimestamps() is in
+// effect.
+var LineTimestampFormat = "2006-01-02T15:04:05.000Z07:00"
+
+// OutputLine is a line of output of a command along with the time it
+// was received.
+type OutputLine struct {
+	// Time is when the first byte of the line was received by the
+	// controller, i.e., the host running the program.
+	Time time.Time
+
+	// Stderr is true if the line was written to standard error
+	// rather than standard output.
+	Stderr bool
+
+	// Text is the line without its line ending.
+	Text string
+}
+
+// String returns the line prefixed with its timestamp in
+// LineTimestampFormat and, for standard error, "stderr: ".
+func (l OutputLine) String() string {
+	s := l.Time.Format(LineTimestampFormat) + " "
+	if l.Stderr {
+		s += "stderr: "
+	}
+
+	return s + l.Text
+}
+
+// WithLineTimestamps records the time each line of output of commands
+// is received, so it can be seen where a long-running command stalled.
+// The lines of both standard output and standard error are returned in
+// the order they were received in Result.Lines by RunResult() and
+// ShellResult(). Lines sent to writers, e.g., by WithStdout() or the
+// Stdout writer the LogRun was constructed with, are prefixed with
+// their timestamp in LineTimestampFormat and are only written once they
+// are complete. Captured output returned by Run() and Shell() is not
+// changed. Only the runners created by the LogRun constructors support
+// timestamps.
+func WithLineTimestamps() CallOption {
+	return func(o *callOptions) {
+		o.lineTimestamps = true
+	}
+}
+
+// outputLines collects the lines of output of a command.
+type outputLines struct {
+	clock Clock
+
+	mu    sync.Mutex
+	lines []OutputLine
+}
+
+// add appends line.
+func (o *outputLines) add(line OutputLine) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.lines = append(o.lines, line)
+}
+
+// get returns the lines with their text decoded by r.
+func (o *outputLines) get(r *LogRun) []OutputLine {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	lines := make([]OutputLine, 0, len(o.lines))
+	for _, line := range o.lines {
+		line.Text = strings.TrimSuffix(strings.TrimSuffix(r.decodeOutput(line.Text), "\n"), "\r")
+		lines = append(lines, line)
+	}
+
+	return lines
+}
+
+// stampWriter is an io.Writer that records each line written to it in
+// lines and either writes it prefixed with its timestamp to dest or,
+// if dest is nil, captures it.
+type stampWriter struct {
+	lines  *outputLines
+	stderr bool
+	dest   io.Writer
+
+	captured bytes.Buffer
+	partial  []byte
+	start    time.Time
+}
+
+func (w *stampWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	now := w.lines.clock.Now()
+	for len(p) > 0 {
+		if len(w.partial) == 0 {
+			w.start = now
+		}
+		i := bytes.IndexByte(p, '\n')
+		if i < 0 {
+			w.partial = append(w.partial, p...)
+			break
+		}
+		w.partial = append(w.partial, p[:i+1]...)
+		p = p[i+1:]
+		if err := w.emit(); err != nil {
+			return 0, err
+		}
+	}
+
+	return n, nil
+}
+
+// flush records the final line if it was not terminated by a newline.
+func (w *stampWriter) flush() error {
+	if len(w.partial) == 0 {
+		return nil
+	}
+
+	return w.emit()
+}
+
+func (w *stampWriter) emit() error {
+	line := OutputLine{Time: w.start, Stderr: w.stderr, Text: string(w.partial)}
+	w.lines.add(line)
+	var err error
+	if w.dest == nil {
+		w.captured.Write(w.partial)
+	} else {
+		_, err = io.WriteString(w.dest, w.start.Format(LineTimestampFormat)+" "+string(w.partial))
+	}
+	w.partial = w.partial[:0]
+
+	return err
+}
+
+// stampOutput replaces the output writers of spec with stampWriters
+// recording lines in spec.lines. Output that would have been captured
+// by the runner is captured by the stampWriters instead. The returned
+// function flushes the stampWriters and returns the captured output
+// along with stdout and stderr returned by the runner.
+func (r *LogRun) stampOutput(spec *execSpec) func(stdout string, stderr string) (string, string, error) {
+	runnerStdout, runnerStderr := runnerWriters(r.Runner)
+	if spec.capture {
+		runnerStdout, runnerStderr = nil, nil
+	}
+	stdout := &stampWriter{lines: spec.lines, dest: spec.stdout}
+	if stdout.dest == nil {
+		stdout.dest = runnerStdout
+	}
+	stderr := &stampWriter{lines: spec.lines, stderr: true, dest: spec.stderr}
+	if stderr.dest == nil {
+		stderr.dest = runnerStderr
+	}
+	spec.stdout, spec.stderr = stdout, stderr
+
+	return func(outStr string, errStr string) (string, string, error) {
+		err := stdout.flush()
+		if flushErr := stderr.flush(); err == nil {
+			err = flushErr
+		}
+
+		return stdout.captured.String() + outStr, stderr.captured.String() + errStr, err
+	}
+}
+
+// runnerWriters returns the Stdout and Stderr writers runner was
+// constructed with, if any.
+func runnerWriters(runner Runner) (io.Writer, io.Writer) {
+	switch runner := runner.(type) {
+	case *localRunner:
+		return runner.stdout, runner.stderr
+	case *remoteRunner:
+		return runner.stdout, runner.stderr
+	case *winrmRunner:
+		return runner.stdout, runner.stderr
+	case *dockerRunner:
+		return runner.stdout, runner.stderr
+	case *ChaosRunner:
+		return runnerWriters(runner.runner)
+	case *RecordingRunner:
+		return runnerWriters(runner.runner)
+	}
+
+	return nil, nil
+}
@@ -0,0 +1,183 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// DateCmd (and options) is the external command used to get
+	// the time of a host as seconds since the epoch. This command
+	// (and options) has been tested on RHEL/CentOS 7 and Ubuntu
+	// 18.04.
+	DateCmd        = "/bin/date"
+	DateCmdOptions = []string{"-u", "+%s.%N"}
+
+	// TimedatectlCmd is the external command used to query and
+	// enable time synchronization. This command has been tested
+	// on RHEL/CentOS 7 and Ubuntu 18.04.
+	TimedatectlCmd = "/usr/bin/timedatectl"
+
+	// NTPEnabledKeys are the keys of the line of "timedatectl
+	// status" output that reports whether time synchronization is
+	// enabled. They differ between systemd versions.
+	NTPEnabledKeys = []string{
+		"NTP enabled",
+		"Network time on",
+		"systemd-timesyncd.service active",
+		"NTP service",
+	}
+)
+
+// ClockSkewError is returned by CheckClockSkew() if the clock of a
+// host differs from the controller's by more than the allowed skew.
+type ClockSkewError struct {
+	Skew    time.Duration
+	MaxSkew time.Duration
+}
+
+// Error implements the error interface.
+func (e *ClockSkewError) Error() string {
+	return fmt.Sprintf("clock skew %s exceeds %s", e.Skew, e.MaxSkew)
+}
+
+// GetTime returns the current time of the host. Only logging is
+// performed if Dryrun is true, in which case the zero time is
+// returned.
+func (r *LogRun) GetTime() (_ time.Time, err error) {
+	r = r.beginHelper()
+	defer r.addErrorContext(&err, r.commandSeq())
+	out, err := r.query("time", DateCmd, DateCmdOptions...)
+	if err != nil || out == "" {
+		return time.Time{}, err
+	}
+
+	return parseEpoch(strings.TrimSpace(out))
+}
+
+// ClockSkew returns the difference between the clock of the host and
+// the Clock of the LogRun, positive if the host is ahead. The
+// controller's time is taken halfway through the round trip to the
+// host. Only logging is performed if Dryrun is true, in which case
+// zero is returned.
+func (r *LogRun) ClockSkew() (_ time.Duration, err error) {
+	r = r.beginHelper()
+	defer r.addErrorContext(&err, r.commandSeq())
+	clock := r.getClock()
+	before := clock.Now()
+	t, err := r.GetTime()
+	if err != nil || t.IsZero() {
+		return 0, err
+	}
+	local := before.Add(clock.Since(before) / 2)
+
+	return t.Sub(local), nil
+}
+
+// CheckClockSkew returns a *ClockSkewError if the clock of the host
+// differs from the controller's by more than maxSkew, e.g., to catch
+// skew that would break TLS or Kerberos before it does. See
+// ClockSkew().
+func (r *LogRun) CheckClockSkew(maxSkew time.Duration) (err error) {
+	r = r.beginHelper()
+	defer r.addErrorContext(&err, r.commandSeq())
+	skew, err := r.ClockSkew()
+	if err != nil {
+		return err
+	}
+	if skew > maxSkew || skew < -maxSkew {
+		return &ClockSkewError{Skew: skew, MaxSkew: maxSkew}
+	}
+
+	return nil
+}
+
+// NTPEnabled returns true if time synchronization is enabled on the
+// host. Only logging is performed if Dryrun is true, in which case
+// false is returned.
+func (r *LogRun) NTPEnabled() (_ bool, err error) {
+	r = r.beginHelper()
+	defer r.addErrorContext(&err, r.commandSeq())
+	out, err := r.query("time synchronization status", TimedatectlCmd, "status")
+	if err != nil || out == "" {
+		return false, err
+	}
+
+	return ParseNTPEnabled(out)
+}
+
+// EnsureNTP enables time synchronization on the host unless it is
+// already enabled. The returned bool is true if it was enabled. Only
+// logging is performed if Dryrun is true, in which case the host is
+// reported as changed. In check mode, the change is only recorded.
+// The outcome is recorded as an operation for the Summary().
+func (r *LogRun) EnsureNTP() (_ bool, err error) {
+	r = r.beginHelper()
+	defer r.addErrorContext(&err, r.commandSeq())
+	enabled, err := r.NTPEnabled()
+	if err != nil {
+		return false, err
+	}
+	if enabled {
+		r.RecordOperation("EnsureNTP", "ntp", false)
+		return false, nil
+	}
+	_, stderr, code := r.Run(TimedatectlCmd, "set-ntp", "true")
+	if code != 0 {
+		return false, fmt.Errorf("could not enable time synchronization: %s", strings.TrimSpace(stderr))
+	}
+	r.RecordOperation("EnsureNTP", "ntp", true)
+
+	return true, nil
+}
+
+// ParseNTPEnabled parses the output of "timedatectl status" and
+// returns true if time synchronization is enabled. See
+// NTPEnabledKeys.
+func ParseNTPEnabled(output string) (bool, error) {
+	for _, line := range lines(output) {
+		fields := strings.SplitN(line, ":", 2)
+		if len(fields) != 2 || !stringInSlice(strings.TrimSpace(fields[0]), NTPEnabledKeys) {
+			continue
+		}
+		switch strings.TrimSpace(fields[1]) {
+		case "yes", "active":
+			return true, nil
+		default:
+			return false, nil
+		}
+	}
+
+	return false, fmt.Errorf("could not find time synchronization status in %q", output)
+}
+
+// parseEpoch parses seconds since the epoch with an optional
+// fraction.
+func parseEpoch(s string) (time.Time, error) {
+	secs, frac := s, ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		secs, frac = s[:i], s[i+1:]
+	}
+	sec, err := strconv.ParseInt(secs, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("could not parse time %q", s)
+	}
+	var nsec int64
+	if frac != "" {
+		if len(frac) > 9 {
+			frac = frac[:9]
+		}
+		frac += strings.Repeat("0", 9-len(frac))
+		if nsec, err = strconv.ParseInt(frac, 10, 64); err != nil {
+			return time.Time{}, fmt.Errorf("could not parse time %q", s)
+		}
+	}
+
+	return time.Unix(sec, nsec).UTC(), nil
+}
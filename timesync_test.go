@@ -0,0 +1,117 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/apatters/go-logrun"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseNTPEnabled(t *testing.T) {
+	centos := `      Local time: Tue 2019-04-02 10:11:12 MDT
+  Universal time: Tue 2019-04-02 16:11:12 UTC
+        RTC time: Tue 2019-04-02 16:11:12
+       Time zone: America/Denver (MDT, -0600)
+     NTP enabled: yes
+NTP synchronized: yes
+ RTC in local TZ: no
+      DST active: yes
+`
+	enabled, err := logrun.ParseNTPEnabled(centos)
+	require.NoError(t, err)
+	assert.True(t, enabled)
+
+	ubuntu := `                      Local time: Tue 2019-04-02 16:11:12 UTC
+                  Universal time: Tue 2019-04-02 16:11:12 UTC
+                        RTC time: Tue 2019-04-02 16:11:12
+                       Time zone: Etc/UTC (UTC, +0000)
+       System clock synchronized: no
+systemd-timesyncd.service active: no
+                 RTC in local TZ: no
+`
+	enabled, err = logrun.ParseNTPEnabled(ubuntu)
+	require.NoError(t, err)
+	assert.False(t, enabled)
+
+	enabled, err = logrun.ParseNTPEnabled("NTP service: active\n")
+	require.NoError(t, err)
+	assert.True(t, enabled)
+
+	_, err = logrun.ParseNTPEnabled("Local time: Tue 2019-04-02 16:11:12 UTC\n")
+	t.Logf("err = %v", err)
+	assert.Error(t, err)
+}
+
+func TestLocalLogRun_GetTime(t *testing.T) {
+	log, out, _ := newLogger()
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{
+		LogFunc: log.Println,
+	})
+	now, err := l.GetTime()
+	t.Logf("now = %s", now)
+	t.Logf("out = %q", out)
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now(), now, 5*time.Second)
+	assert.Equal(t, "/bin/date -u +%s.%N\n", out.String())
+
+	skew, err := l.ClockSkew()
+	t.Logf("skew = %s", skew)
+	require.NoError(t, err)
+	assert.NoError(t, l.CheckClockSkew(5*time.Second))
+
+	l.SetDryrun(true)
+	now, err = l.GetTime()
+	require.NoError(t, err)
+	assert.True(t, now.IsZero())
+	assert.NoError(t, l.CheckClockSkew(0))
+}
+
+func TestLocalLogRun_CheckClockSkew(t *testing.T) {
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{})
+	l.SetClock(logrun.NewFakeClock(time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)))
+	err := l.CheckClockSkew(time.Minute)
+	t.Logf("err = %v", err)
+	require.Error(t, err)
+	skewErr, ok := err.(*logrun.ClockSkewError)
+	require.True(t, ok)
+	assert.True(t, skewErr.Skew > 365*24*time.Hour)
+	assert.Equal(t, time.Minute, skewErr.MaxSkew)
+}
+
+func TestLocalLogRun_EnsureNTP(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "logrun")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmpDir)
+	orig := logrun.TimedatectlCmd
+	defer func() { logrun.TimedatectlCmd = orig }()
+	state := filepath.Join(tmpDir, "enabled")
+	logrun.TimedatectlCmd = fakeCommand(t, tmpDir, "timedatectl", `
+case "$1" in
+status) if [ -f `+state+` ]; then echo 'NTP enabled: yes'; else echo 'NTP enabled: no'; fi ;;
+set-ntp) touch `+state+` ;;
+esac
+`)
+
+	log, out, _ := newLogger()
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{
+		LogFunc: log.Println,
+	})
+	changed, err := l.EnsureNTP()
+	t.Logf("out = %q", out)
+	require.NoError(t, err)
+	assert.True(t, changed)
+	assert.FileExists(t, state)
+
+	changed, err = l.EnsureNTP()
+	require.NoError(t, err)
+	assert.False(t, changed)
+}
@@ -0,0 +1,319 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"sync"
+)
+
+// ErrNotRecorded is returned by a ReplayRunner for commands that are
+// not in its transcript or whose recorded runs have all been replayed.
+var ErrNotRecorded = errors.New("replay: command not recorded")
+
+// TranscriptEntry is a command run by a RecordingRunner along with
+// its outcome. Transcripts are encoded as JSON lines, one entry per
+// line.
+type TranscriptEntry struct {
+	// Display is the command as formatted by the recorded Runner,
+	// e.g., for logging.
+	Display string `json:"display"`
+
+	// Cmd, Args, Shell, and Dir identify the command when it is
+	// replayed.
+	Cmd   string   `json:"cmd"`
+	Args  []string `json:"args,omitempty"`
+	Shell bool     `json:"shell,omitempty"`
+	Dir   string   `json:"dir,omitempty"`
+
+	Stdout   string `json:"stdout,omitempty"`
+	Stderr   string `json:"stderr,omitempty"`
+	ExitCode int    `json:"exit_code"`
+
+	// Error is the error returned by the recorded Runner, if
+	// any.
+	Error string `json:"error,omitempty"`
+}
+
+// key returns the key replayed entries are looked up by.
+func (e TranscriptEntry) key() string {
+	args := e.Args
+	if len(args) == 0 {
+		args = nil
+	}
+	b, _ := json.Marshal([]interface{}{e.Cmd, args, e.Shell, e.Dir}) // nolint: errcheck
+
+	return string(b)
+}
+
+// transcriptEntry returns the entry of the command described by spec
+// without its outcome.
+func transcriptEntry(spec *execSpec) TranscriptEntry {
+	return TranscriptEntry{
+		Cmd:   spec.cmd,
+		Args:  spec.args,
+		Shell: spec.shell,
+		Dir:   spec.dir,
+	}
+}
+
+// ReadTranscript returns the entries of the transcript read from rd.
+func ReadTranscript(rd io.Reader) ([]TranscriptEntry, error) {
+	var entries []TranscriptEntry
+	scanner := bufio.NewScanner(rd)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var e TranscriptEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("could not read transcript line %d: %w", line, err)
+		}
+		entries = append(entries, e)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("could not read transcript: %w", err)
+	}
+
+	return entries, nil
+}
+
+// RecordingRunner is a Runner that wraps another Runner and writes
+// every command it runs along with its output and exit code to a
+// transcript, so the run can later be served by a ReplayRunner, e.g.,
+// for deterministic integration tests or to debug a provisioning run
+// offline. Install it with SetRunner():
+//
+//	f, err := os.Create("run.transcript")
+//	...
+//	r.SetRunner(logrun.NewRecordingRunner(r.Runner, f))
+//
+// Output written to per-command writers, e.g., WithStdout(), is
+// recorded as well. Note that a LogRun with a RecordingRunner runs its
+// file helpers using external commands rather than in-process, even
+// for local hosts, so they are recorded too.
+type RecordingRunner struct {
+	runner Runner
+
+	mu  sync.Mutex
+	w   io.Writer
+	err error
+}
+
+// NewRecordingRunner returns a RecordingRunner that runs commands
+// using runner and writes the transcript to w.
+func NewRecordingRunner(runner Runner, w io.Writer) *RecordingRunner {
+	return &RecordingRunner{runner: runner, w: w}
+}
+
+// Err returns the first error writing the transcript, if any.
+func (rec *RecordingRunner) Err() error {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	return rec.err
+}
+
+// Run runs a command using the wrapped Runner and records it.
+func (rec *RecordingRunner) Run(cmd string, args ...string) (string, string, int, error) {
+	return rec.execute(&execSpec{cmd: cmd, args: args})
+}
+
+// FormatRun returns the FormatRun() of the wrapped Runner.
+func (rec *RecordingRunner) FormatRun(cmd string, args ...string) string {
+	return rec.runner.FormatRun(cmd, args...)
+}
+
+// Shell runs a shell command using the wrapped Runner and records
+// it.
+func (rec *RecordingRunner) Shell(cmd string) (string, string, int, error) {
+	return rec.execute(&execSpec{cmd: cmd, shell: true})
+}
+
+// FormatShell returns the FormatShell() of the wrapped Runner.
+func (rec *RecordingRunner) FormatShell(cmd string) string {
+	return rec.runner.FormatShell(cmd)
+}
+
+func (rec *RecordingRunner) execute(spec *execSpec) (string, string, int, error) {
+	entry := transcriptEntry(spec)
+	entry.Display = rec.format(spec)
+	var outCopy, errCopy bytes.Buffer
+	if spec.stdout != nil {
+		spec.stdout = io.MultiWriter(spec.stdout, &outCopy)
+	}
+	if spec.stderr != nil {
+		spec.stderr = io.MultiWriter(spec.stderr, &errCopy)
+	}
+	var stdout, stderr string
+	var code int
+	var err error
+	if e, ok := rec.runner.(executor); ok {
+		stdout, stderr, code, err = e.execute(spec)
+	} else {
+		stdout, stderr, code, err = executePlain(rec.runner, spec)
+	}
+	entry.Stdout = outCopy.String() + stdout
+	entry.Stderr = errCopy.String() + stderr
+	entry.ExitCode = code
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	rec.write(entry)
+
+	return stdout, stderr, code, err
+}
+
+// write appends entry to the transcript.
+func (rec *RecordingRunner) write(entry TranscriptEntry) {
+	line, err := json.Marshal(entry)
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if err == nil {
+		_, err = rec.w.Write(append(line, '\n'))
+	}
+	if err != nil && rec.err == nil {
+		rec.err = fmt.Errorf("could not write transcript: %w", err)
+	}
+}
+
+func (rec *RecordingRunner) format(spec *execSpec) string {
+	if e, ok := rec.runner.(executor); ok {
+		return e.format(spec)
+	}
+	if spec.shell {
+		return rec.runner.FormatShell(spec.cmd)
+	}
+
+	return rec.runner.FormatRun(spec.cmd, spec.args...)
+}
+
+// ReplayRunner is a Runner that serves the results recorded by a
+// RecordingRunner without running anything. Commands are matched by
+// their command, arguments, whether they run in a shell, and working
+// directory, and each recorded run is replayed once in the order it
+// was recorded. Commands without a remaining recorded run fail with
+// ErrNotRecorded. Use it with NewLogRun(), e.g.,
+//
+//	f, err := os.Open("run.transcript")
+//	...
+//	replay, err := logrun.NewReplayRunner(f)
+//	...
+//	r := logrun.NewLogRun(replay, logrun.LogRunConfig{})
+//
+// Commands are formatted as they were recorded. Standard input is
+// read and discarded.
+type ReplayRunner struct {
+	mu      sync.Mutex
+	entries map[string][]TranscriptEntry
+}
+
+// NewReplayRunner returns a ReplayRunner serving the transcript read
+// from rd.
+func NewReplayRunner(rd io.Reader) (*ReplayRunner, error) {
+	entries, err := ReadTranscript(rd)
+	if err != nil {
+		return nil, err
+	}
+	p := &ReplayRunner{entries: make(map[string][]TranscriptEntry)}
+	for _, e := range entries {
+		p.entries[e.key()] = append(p.entries[e.key()], e)
+	}
+
+	return p, nil
+}
+
+// Remaining returns the number of recorded runs that have not been
+// replayed, e.g., to verify that a test ran every recorded command.
+func (p *ReplayRunner) Remaining() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	n := 0
+	for _, entries := range p.entries {
+		n += len(entries)
+	}
+
+	return n
+}
+
+// Run returns the recorded result of a command.
+func (p *ReplayRunner) Run(cmd string, args ...string) (string, string, int, error) {
+	return p.execute(&execSpec{cmd: cmd, args: args})
+}
+
+// FormatRun returns the command as it was recorded.
+func (p *ReplayRunner) FormatRun(cmd string, args ...string) string {
+	return p.format(&execSpec{cmd: cmd, args: args})
+}
+
+// Shell returns the recorded result of a shell command.
+func (p *ReplayRunner) Shell(cmd string) (string, string, int, error) {
+	return p.execute(&execSpec{cmd: cmd, shell: true})
+}
+
+// FormatShell returns the command as it was recorded.
+func (p *ReplayRunner) FormatShell(cmd string) string {
+	return p.format(&execSpec{cmd: cmd, shell: true})
+}
+
+func (p *ReplayRunner) execute(spec *execSpec) (string, string, int, error) {
+	key := transcriptEntry(spec).key()
+	p.mu.Lock()
+	entries := p.entries[key]
+	if len(entries) == 0 {
+		p.mu.Unlock()
+		return "", "", 0, fmt.Errorf("%w: %s", ErrNotRecorded, p.format(spec))
+	}
+	entry := entries[0]
+	p.entries[key] = entries[1:]
+	p.mu.Unlock()
+
+	if spec.stdin != nil {
+		io.Copy(ioutil.Discard, spec.stdin) // nolint: errcheck
+	}
+	stdout, stderr := entry.Stdout, entry.Stderr
+	if spec.stdout != nil {
+		if _, err := io.WriteString(spec.stdout, stdout); err != nil {
+			return "", "", 0, err
+		}
+		stdout = ""
+	}
+	if spec.stderr != nil {
+		if _, err := io.WriteString(spec.stderr, stderr); err != nil {
+			return "", "", 0, err
+		}
+		stderr = ""
+	}
+	if entry.Error != "" {
+		return stdout, stderr, entry.ExitCode, errors.New(entry.Error)
+	}
+
+	return stdout, stderr, entry.ExitCode, nil
+}
+
+// format returns the Display of the next recorded run of the command
+// described by spec, or the command and its arguments if there is
+// none.
+func (p *ReplayRunner) format(spec *execSpec) string {
+	p.mu.Lock()
+	entries := p.entries[transcriptEntry(spec).key()]
+	p.mu.Unlock()
+	if len(entries) > 0 {
+		return entries[0].Display
+	}
+	if spec.shell {
+		return spec.cmd
+	}
+
+	return strings.TrimSpace(spec.cmd + " " + strings.Join(spec.args, " "))
+}
@@ -0,0 +1,92 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun_test
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/apatters/go-logrun"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordingRunner_Replay(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.conf")
+
+	var transcript bytes.Buffer
+	log, out, _ := newLogger()
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{LogFunc: log.Println})
+	rec := logrun.NewRecordingRunner(l.Runner, &transcript)
+	l.SetRunner(rec)
+	run := func(l *logrun.LogRun) []string {
+		var results []string
+		stdout, stderr, code := l.Run("/bin/echo", "hello")
+		results = append(results, stdout, stderr, strings.Repeat("x", code))
+		var buf strings.Builder
+		_, _, code = l.With(logrun.WithStdout(&buf)).Shell("echo world; echo oops >&2; exit 3")
+		results = append(results, buf.String(), strings.Repeat("x", code))
+		l.PushDir(dir)
+		stdout, _, _ = l.Run("pwd")
+		results = append(results, stdout)
+		_, err := l.PopDir()
+		require.NoError(t, err)
+		changed, err := l.PutFileString(path, "a = 1\n", 0644)
+		require.NoError(t, err)
+		assert.True(t, changed)
+		content, err := l.GetFileString(path)
+		require.NoError(t, err)
+		return append(results, content)
+	}
+	recorded := run(l)
+	recordedLog := out.String()
+	t.Logf("recorded = %q", recorded)
+	t.Logf("transcript = %s", transcript.String())
+	require.NoError(t, rec.Err())
+	assert.Equal(t, []string{"hello\n", "", "", "world\n", "xxx", dir + "\n", "a = 1\n"}, recorded)
+
+	entries, err := logrun.ReadTranscript(bytes.NewReader(transcript.Bytes()))
+	require.NoError(t, err)
+	require.True(t, len(entries) > 3)
+	assert.Equal(t, logrun.TranscriptEntry{
+		Display:  "/bin/echo hello",
+		Cmd:      "/bin/echo",
+		Args:     []string{"hello"},
+		Stdout:   "hello\n",
+		ExitCode: 0,
+	}, entries[0])
+	assert.Equal(t, "oops\n", entries[1].Stderr)
+	assert.Equal(t, 3, entries[1].ExitCode)
+	assert.Equal(t, dir, entries[2].Dir)
+
+	replay, err := logrun.NewReplayRunner(bytes.NewReader(transcript.Bytes()))
+	require.NoError(t, err)
+	log, out, _ = newLogger()
+	r := logrun.NewLogRun(replay, logrun.LogRunConfig{LogFunc: log.Println})
+	replayed := run(r)
+	t.Logf("replayed = %q", replayed)
+	assert.Equal(t, recorded, replayed)
+	assert.Equal(t, recordedLog, out.String())
+	assert.Zero(t, replay.Remaining())
+
+	// Every recorded run is only replayed once.
+	_, stderr, code := r.Run("/bin/echo", "hello")
+	t.Logf("stderr = %q", stderr)
+	assert.Equal(t, logrun.ExitErrorExecute, code)
+	assert.Contains(t, stderr, logrun.ErrNotRecorded.Error())
+	_, err = r.RunResult("/bin/echo", "goodbye")
+	assert.True(t, errors.Is(err, logrun.ErrNotRecorded))
+}
+
+func TestReadTranscript_Invalid(t *testing.T) {
+	_, err := logrun.ReadTranscript(strings.NewReader("{\"cmd\": \"true\"}\n\nnot json\n"))
+	t.Logf("err = %v", err)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "line 3")
+}
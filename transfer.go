@@ -0,0 +1,118 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun
+
+import (
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// RsyncStatsCmdOptions are the command-line options added to RsyncCmd
+// so the number of bytes it transferred is printed and can be
+// accounted for. They are only added if RsyncOptions.Progress is set
+// or if the LogRun has a Recorder or a TransferHook and
+// RsyncOptions.DryRun is not set. This command (and options) has been
+// tested on RHEL/CentOS 7 and Ubuntu 18.04.
+var RsyncStatsCmdOptions = []string{"--stats"}
+
+// TransferHook receives a TransferStat for every transfer of data to
+// or from a host, e.g., to export bandwidth metrics.
+type TransferHook interface {
+	Transfer(stat TransferStat)
+}
+
+// TransferHookFunc adapts an ordinary function to a TransferHook.
+type TransferHookFunc func(stat TransferStat)
+
+// Transfer calls f(stat).
+func (f TransferHookFunc) Transfer(stat TransferStat) {
+	f(stat)
+}
+
+// SetTransferHook sets the hook that receives a TransferStat for every
+// transfer of data to or from the host, in addition to the Recorder,
+// e.g.,
+//
+//	runner.SetTransferHook(logrun.TransferHookFunc(func(s logrun.TransferStat) {
+//		bytesSent.WithLabelValues(s.Host).Add(float64(s.BytesSent))
+//		bytesReceived.WithLabelValues(s.Host).Add(float64(s.BytesReceived))
+//	}))
+//
+// Set it to nil, the default, to only record transfers in the
+// Recorder, if any.
+func (r *LogRun) SetTransferHook(h TransferHook) {
+	r.transferHook = h
+}
+
+// accounting returns true if transfers are recorded.
+func (r *LogRun) accounting() bool {
+	return r.recorder != nil || r.transferHook != nil
+}
+
+// recordTransfer records that operation sent and received the given
+// number of bytes while acting on target.
+func (r *LogRun) recordTransfer(operation string, target string, sent int64, received int64) {
+	if !r.accounting() || sent == 0 && received == 0 {
+		return
+	}
+	stat := TransferStat{
+		Host:          r.Host().String(),
+		Operation:     operation,
+		Target:        target,
+		BytesSent:     sent,
+		BytesReceived: received,
+		Section:       r.Section(),
+	}
+	if r.recorder != nil {
+		r.recorder.recordTransfer(stat)
+	}
+	if r.transferHook != nil {
+		r.transferHook.Transfer(stat)
+	}
+}
+
+// countTransfers returns true if the file helpers count the bytes they
+// transfer. Nothing is transferred for local hosts.
+func (r *LogRun) countTransfers() bool {
+	return r.accounting() && r.localRunner() == nil
+}
+
+// rsyncStatsRegexp matches the totals printed by rsync --stats.
+var rsyncStatsRegexp = regexp.MustCompile(`(?m)^Total bytes (sent|received): ([0-9,.]+)`)
+
+// parseRsyncStats returns the number of bytes sent and received
+// according to output, the output of rsync --stats.
+func parseRsyncStats(output string) (int64, int64) {
+	var sent, received int64
+	for _, m := range rsyncStatsRegexp.FindAllStringSubmatch(output, -1) {
+		n, err := strconv.ParseInt(strings.NewReplacer(",", "", ".", "").Replace(m[2]), 10, 64)
+		if err != nil {
+			continue
+		}
+		if m[1] == "sent" {
+			sent = n
+		} else {
+			received = n
+		}
+	}
+
+	return sent, received
+}
+
+// countingReader passes reads on to rd and adds the number of bytes
+// read to n.
+type countingReader struct {
+	rd io.Reader
+	n  int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.rd.Read(p)
+	c.n += int64(n)
+
+	return n, err
+}
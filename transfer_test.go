@@ -0,0 +1,111 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/apatters/go-logrun"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalLogRun_RsyncTransfers(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-logrun-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	script := filepath.Join(dir, "rsync")
+	err = ioutil.WriteFile(script, []byte(`#!/bin/sh
+echo "$@" > `+filepath.Join(dir, "args")+`
+echo 'Number of files: 3'
+echo 'Total bytes sent: 1,234,567'
+echo 'Total bytes received: 89'
+`), 0755)
+	require.NoError(t, err)
+	orig := logrun.RsyncCmd
+	logrun.RsyncCmd = script
+	defer func() { logrun.RsyncCmd = orig }()
+
+	var stdout bytes.Buffer
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{Stdout: &stdout})
+	l.SetRecorder(logrun.NewRecorder())
+	var mu sync.Mutex
+	var hooked []logrun.TransferStat
+	l.SetTransferHook(logrun.TransferHookFunc(func(s logrun.TransferStat) {
+		mu.Lock()
+		defer mu.Unlock()
+		hooked = append(hooked, s)
+	}))
+	require.NoError(t, l.Rsync("/src/", "web1:/dest/"))
+	args, err := ioutil.ReadFile(filepath.Join(dir, "args"))
+	require.NoError(t, err)
+	assert.Contains(t, string(args), "--stats /src/ web1:/dest/")
+	assert.Contains(t, stdout.String(), "Total bytes sent: 1,234,567")
+
+	transfers := l.Recorder().Transfers()
+	require.Len(t, transfers, 1)
+	assert.Equal(t, "Rsync", transfers[0].Operation)
+	assert.Equal(t, "web1:/dest/", transfers[0].Target)
+	assert.Equal(t, int64(1234567), transfers[0].BytesSent)
+	assert.Equal(t, int64(89), transfers[0].BytesReceived)
+	assert.Equal(t, transfers, hooked)
+
+	s := l.Summary()
+	t.Logf("summary =\n%s", s)
+	assert.Equal(t, int64(1234567), s.BytesSent)
+	assert.Equal(t, int64(89), s.BytesReceived)
+	assert.Contains(t, s.String(), "1234567 bytes sent, 89 bytes received\n")
+
+	// By default, nothing is accounted for.
+	l = logrun.NewLocalLogRun(logrun.LocalConfig{})
+	require.NoError(t, l.Rsync("/src/", "web1:/dest/"))
+	args, err = ioutil.ReadFile(filepath.Join(dir, "args"))
+	require.NoError(t, err)
+	assert.NotContains(t, string(args), "--stats")
+}
+
+func TestRemoteLogRun_FileTransfers(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "logrun")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmpDir)
+	path := filepath.Join(tmpDir, "app.conf")
+
+	s := newTestSSHServer(t, nil)
+	defer s.Close()
+	r, err := logrun.NewRemoteLogRun(logrun.RemoteConfig{Credentials: s.Credentials()})
+	require.NoError(t, err)
+	r.SetRecorder(logrun.NewRecorder())
+	_, err = r.PutFileString(path, "listen 80\n", 0644)
+	require.NoError(t, err)
+	content, err := r.GetFileString(path)
+	require.NoError(t, err)
+	assert.Equal(t, "listen 80\n", content)
+
+	var sent, received int64
+	for _, stat := range r.Recorder().Transfers() {
+		t.Logf("transfer = %+v", stat)
+		assert.Equal(t, path, stat.Target)
+		sent += stat.BytesSent
+		received += stat.BytesReceived
+	}
+	assert.Equal(t, int64(10), sent)
+	assert.Equal(t, int64(10), received)
+	summary := r.Summary()
+	require.Len(t, summary.Hosts, 1)
+	assert.Equal(t, int64(10), summary.Hosts[0].BytesSent)
+	assert.Equal(t, int64(10), summary.Hosts[0].BytesReceived)
+
+	// Nothing is transferred for local hosts.
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{})
+	l.SetRecorder(logrun.NewRecorder())
+	_, err = l.GetFileString(path)
+	require.NoError(t, err)
+	assert.Empty(t, l.Recorder().Transfers())
+}
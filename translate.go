@@ -0,0 +1,65 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun
+
+import (
+	"fmt"
+	"sync"
+)
+
+// MessageTranslator translates the messages logrun generates itself,
+// e.g., skip messages, task retries, summaries, and plan headings, so
+// programs with non-English user interfaces can present consistent
+// output. It is passed the English format of a message, e.g.,
+// "skipped: %s (%s)", and returns the format to use instead. The
+// translated format must use the same verbs; explicit argument
+// indexes, e.g., "%[2]s", can be used to reorder them. Commands,
+// paths, and other values substituted into the format are not
+// translated. Formats without a translation should be returned
+// unchanged.
+type MessageTranslator func(format string) string
+
+var (
+	translatorMu sync.RWMutex
+	translator   MessageTranslator
+)
+
+// SetMessageTranslator sets the MessageTranslator used by every
+// LogRun, e.g.,
+//
+//	catalog := map[string]string{
+//		"skipped: %s (%s)": "übersprungen: %s (%s)",
+//	}
+//	logrun.SetMessageTranslator(func(format string) string {
+//		if s, ok := catalog[format]; ok {
+//			return s
+//		}
+//		return format
+//	})
+//
+// Set it to nil, the default, to use English messages.
+func SetMessageTranslator(t MessageTranslator) {
+	translatorMu.Lock()
+	defer translatorMu.Unlock()
+	translator = t
+}
+
+// tr returns format translated by the MessageTranslator, if any.
+func tr(format string) string {
+	translatorMu.RLock()
+	t := translator
+	translatorMu.RUnlock()
+	if t == nil {
+		return format
+	}
+
+	return t(format)
+}
+
+// trf formats a according to format translated by the
+// MessageTranslator, if any.
+func trf(format string, a ...interface{}) string {
+	return fmt.Sprintf(tr(format), a...)
+}
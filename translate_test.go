@@ -0,0 +1,78 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun_test
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/apatters/go-logrun"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetMessageTranslator(t *testing.T) {
+	catalog := map[string]string{
+		"skipped: %s (%s)":                               "übersprungen: %s (%s)",
+		"unless %q succeeded":                            "außer %q war erfolgreich",
+		"retrying: task %s (attempt %d of %d): %s":       "Wiederholung %[2]d von %[3]d: Aufgabe %[1]s: %[4]s",
+		"%d commands run, %d failed, %d skipped in %s\n": "%d Befehle ausgeführt, %d fehlgeschlagen, %d übersprungen in %s\n",
+		"create": "erstellen",
+		"Plan: %d to create, %d to modify, %d to delete, %d commands to run.": "Plan: %d erstellen, %d ändern, %d löschen, %d Befehle.",
+	}
+	var formats []string
+	logrun.SetMessageTranslator(func(format string) string {
+		formats = append(formats, format)
+		if s, ok := catalog[format]; ok {
+			return s
+		}
+		return strings.Replace(format, "#!/bin/bash\n", "#!/bin/bash\n# Übersetzt\n", 1)
+	})
+	defer logrun.SetMessageTranslator(nil)
+
+	log, out, _ := newLogger()
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{LogFunc: log.Println})
+	l.SetRecorder(logrun.NewRecorder())
+	l.With(logrun.Unless("true")).Run("/bin/echo", "hello")
+	t.Logf("out = %q", out)
+	assert.Contains(t, out.String(), "übersprungen: /bin/echo hello (außer \"true\" war erfolgreich)\n")
+
+	out.Reset()
+	tasks, err := logrun.NewTaskList([]logrun.Task{{
+		Name:    "flaky",
+		Retries: 1,
+		Run:     func(r *logrun.LogRun) error { return errors.New("boom") },
+	}}, logrun.TaskListConfig{})
+	require.NoError(t, err)
+	tasks.Run(l)
+	t.Logf("out = %q", out)
+	assert.Contains(t, out.String(), "Wiederholung 2 von 2: Aufgabe flaky: boom\n")
+	assert.True(t, strings.HasPrefix(l.Summary().String(), "1 Befehle ausgeführt, 0 fehlgeschlagen, 1 übersprungen in "))
+
+	report, err := l.Check(func(r *logrun.LogRun) error {
+		_, err := r.PutFileString("/tmp/logrun-translate-test", "x", 0644)
+		return err
+	})
+	require.NoError(t, err)
+	t.Logf("report =\n%s", report)
+	assert.Contains(t, report.String(), "+ erstellen /tmp/logrun-translate-test")
+	assert.Contains(t, report.String(), "Plan: 1 erstellen, 0 ändern, 0 löschen, 0 Befehle.")
+
+	plan, err := l.Plan(func(r *logrun.LogRun) error {
+		r.Run("/bin/true")
+		return nil
+	})
+	require.NoError(t, err)
+	var script bytes.Buffer
+	require.NoError(t, plan.ExportScript(&script))
+	assert.True(t, strings.HasPrefix(script.String(), "#!/bin/bash\n# Übersetzt\n"))
+
+	// Values substituted into the formats are not translated.
+	for _, format := range formats {
+		assert.NotContains(t, format, "/bin/echo")
+	}
+}
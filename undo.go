@@ -0,0 +1,173 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// undoEntry is a compensating action registered with RegisterUndo(),
+// RegisterUndoShell(), or RegisterUndoFunc().
+type undoEntry struct {
+	// spec is the command to run if fn is nil.
+	spec execSpec
+
+	// fn, if not nil, is called with the undoing LogRun instead
+	// of running spec. desc is logged before calling it.
+	fn   func(r *LogRun) error
+	desc string
+}
+
+// undoStack holds the registered undo entries. It is shared by
+// copies of a LogRun.
+type undoStack struct {
+	mu      sync.Mutex
+	entries []undoEntry
+}
+
+func (u *undoStack) push(e undoEntry) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.entries = append(u.entries, e)
+}
+
+// take removes and returns all entries.
+func (u *undoStack) take() []undoEntry {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	entries := u.entries
+	u.entries = nil
+
+	return entries
+}
+
+func (r *LogRun) undoStack() *undoStack {
+	if r.undo == nil {
+		r.undo = new(undoStack)
+	}
+
+	return r.undo
+}
+
+// RegisterUndo registers cmd with args as the compensating command of
+// an operation. It is run by Undo() in the working directory that is
+// in effect when it is registered.
+func (r *LogRun) RegisterUndo(cmd string, args ...string) {
+	r.undoStack().push(undoEntry{
+		spec: execSpec{cmd: cmd, args: append([]string{}, args...), dir: r.Dir()},
+	})
+}
+
+// RegisterUndoShell registers cmd as the compensating shell command
+// of an operation.
+func (r *LogRun) RegisterUndoShell(cmd string) {
+	r.undoStack().push(undoEntry{
+		spec: execSpec{cmd: cmd, shell: true, dir: r.Dir()},
+	})
+}
+
+// RegisterUndoFunc registers f as the compensating action of an
+// operation. desc is logged when f is called by Undo().
+func (r *LogRun) RegisterUndoFunc(desc string, f func() error) {
+	r.undoStack().push(undoEntry{
+		fn:   func(*LogRun) error { return f() },
+		desc: desc,
+	})
+}
+
+// Undo runs the registered compensating actions in the reverse order
+// they were registered and clears them. Commands are logged and honor
+// Dryrun like Run() and Shell(). All actions are attempted even if
+// some fail; the failures are returned as a single error.
+func (r *LogRun) Undo() error {
+	if r.undo == nil {
+		return nil
+	}
+	entries := r.undo.take()
+
+	// Actions run while undoing must not register more actions.
+	c := *r
+	c.undo = nil
+	var errs []string
+	for i := len(entries) - 1; i >= 0; i-- {
+		e := entries[i]
+		if e.fn != nil {
+			c.log("undo: " + e.desc)
+			if c.Dryrun {
+				continue
+			}
+			if err := e.fn(&c); err != nil {
+				errs = append(errs, fmt.Sprintf("%s: %s", e.desc, err))
+			}
+			continue
+		}
+		_, stderr, code := c.logAndRun(e.spec)
+		if code != 0 {
+			errs = append(errs, fmt.Sprintf("%s: %s", c.format(e.spec), strings.TrimSpace(stderr)))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("undo failed: %s", strings.Join(errs, "; "))
+	}
+
+	return nil
+}
+
+// ClearUndo discards the registered compensating actions, e.g., once
+// all operations have succeeded.
+func (r *LogRun) ClearUndo() {
+	if r.undo != nil {
+		r.undo.take()
+	}
+}
+
+// Transaction runs task using a copy of the LogRun with its own undo
+// stack. Files written by PutFileString() in task automatically
+// register the restoration of their previous contents. If task
+// returns an error, the registered actions are run using Undo() and
+// the error of task is returned, wrapped along with any undo failure.
+func (r *LogRun) Transaction(task func(r *LogRun) error) error {
+	c := r.With()
+	c.undo = new(undoStack)
+	err := task(c)
+	if err == nil {
+		c.ClearUndo()
+		return nil
+	}
+	if undoErr := c.Undo(); undoErr != nil {
+		return fmt.Errorf("%w (%s)", err, undoErr)
+	}
+
+	return err
+}
+
+// registerFileUndo registers the restoration of path to its previous
+// state. It is called by PutFileString() after path has been changed
+// while an undo stack is active.
+func (r *LogRun) registerFileUndo(path string, exists bool, content string, perm string) {
+	if r.undo == nil {
+		return
+	}
+	if !exists {
+		args := append(append([]string{}, RemoveFileCmdOptions...), path)
+		r.RegisterUndo(RemoveFileCmd, args...)
+		return
+	}
+	r.undoStack().push(undoEntry{
+		fn: func(u *LogRun) error {
+			mode, err := strconv.ParseUint(perm, 8, 32)
+			if err != nil {
+				return err
+			}
+			_, err = u.PutFileString(path, content, os.FileMode(mode))
+			return err
+		},
+		desc: fmt.Sprintf("restore %s", path),
+	})
+}
@@ -0,0 +1,113 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/apatters/go-logrun"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalLogRun_Undo(t *testing.T) {
+	log, out, _ := newLogger()
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{
+		LogFunc: log.Println,
+	})
+	var order []string
+	l.RegisterUndoFunc("first", func() error {
+		order = append(order, "first")
+		return nil
+	})
+	l.RegisterUndoShell("echo second")
+	l.RegisterUndo("/bin/false")
+	l.RegisterUndoFunc("fourth", func() error {
+		order = append(order, "fourth")
+		return errors.New("oops")
+	})
+	err := l.Undo()
+	t.Logf("err = %v", err)
+	t.Logf("out = %q", out)
+	require.Error(t, err)
+	assert.Equal(t, "undo failed: fourth: oops; /bin/false: ", err.Error())
+	assert.Equal(t, []string{"fourth", "first"}, order)
+	assert.Regexp(t, `(?s)undo: fourth.*/bin/false.*echo second.*undo: first`, out.String())
+
+	// The stack is cleared.
+	order = nil
+	assert.NoError(t, l.Undo())
+	assert.Empty(t, order)
+}
+
+func TestLocalLogRun_ClearUndo(t *testing.T) {
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{})
+	l.RegisterUndo("/bin/false")
+	l.ClearUndo()
+	assert.NoError(t, l.Undo())
+}
+
+func TestLocalLogRun_Transaction(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-logrun-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	existing := filepath.Join(dir, "existing.conf")
+	created := filepath.Join(dir, "created.conf")
+	require.NoError(t, ioutil.WriteFile(existing, []byte("a = 1\n"), 0644))
+
+	log, out, _ := newLogger()
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{
+		LogFunc: log.Println,
+	})
+	err = l.Transaction(func(r *logrun.LogRun) error {
+		_, err := r.PutFileString(existing, "a = 2\n", 0600)
+		require.NoError(t, err)
+		_, err = r.PutFileString(created, "b = 1\n", 0644)
+		require.NoError(t, err)
+		_, stderr, code := r.Run("/bin/false")
+		if code != 0 {
+			return errors.New("could not run false" + stderr)
+		}
+		return nil
+	})
+	t.Logf("err = %v", err)
+	t.Logf("out = %q", out)
+	assert.EqualError(t, err, "could not run false")
+
+	content, err := ioutil.ReadFile(existing)
+	require.NoError(t, err)
+	assert.Equal(t, "a = 1\n", string(content))
+	info, err := os.Stat(existing)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0644), info.Mode().Perm())
+	_, err = os.Stat(created)
+	assert.True(t, os.IsNotExist(err))
+	assert.Contains(t, out.String(), "undo: restore "+existing)
+
+	// A successful transaction keeps its changes.
+	err = l.Transaction(func(r *logrun.LogRun) error {
+		_, err := r.PutFileString(created, "b = 1\n", 0644)
+		return err
+	})
+	require.NoError(t, err)
+	assert.FileExists(t, created)
+	assert.NoError(t, l.Undo())
+	assert.FileExists(t, created)
+
+	// Undo failures are added to the error of task, which stays
+	// available to errors.Is() and errors.As().
+	errTask := errors.New("task failed")
+	err = l.Transaction(func(r *logrun.LogRun) error {
+		r.RegisterUndoFunc("fail", func() error { return errors.New("undo failed") })
+		return errTask
+	})
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, errTask))
+	assert.Contains(t, err.Error(), "undo failed")
+}
@@ -0,0 +1,204 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// Upload copies the file at localPath on the controller, i.e., the
+// host running the program, to remotePath on the host, keeping its
+// permission bits. Remote hosts use SFTP on the existing connection
+// and other hosts stream the file to the standard input of a shell
+// command, so neither rsync nor another set of ssh options is needed.
+// The file is written to a temporary file which is then renamed, so
+// readers never see a partially written file. Only logging is
+// performed if Dryrun is true. In check mode, the change is only
+// recorded. The upload is recorded as an operation for the Summary().
+func (r *LogRun) Upload(localPath string, remotePath string) (err error) {
+	r = r.beginHelper()
+	defer r.addErrorContext(&err, r.commandSeq())
+	f, err := os.Open(localPath)
+	if err != nil {
+		return fmt.Errorf("could not upload %s: %w", localPath, err)
+	}
+	defer f.Close() // nolint: errcheck
+	fi, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("could not upload %s: %w", localPath, err)
+	}
+	if !fi.Mode().IsRegular() {
+		return fmt.Errorf("could not upload %s: %w", localPath, ErrNotRegularFile)
+	}
+	mode := fi.Mode().Perm()
+
+	remote := r.sftpRunner()
+	local := r.localRunner()
+	spec := execSpec{cmd: writeFileCmd(remotePath, mode), shell: true, planInput: "cat " + shellQuote(localPath)}
+	switch {
+	case remote != nil:
+		r.logChange(spec, remote.formatSFTP("put", localPath, remotePath))
+	case local != nil:
+		r.logChange(spec, "put "+localPath+" "+remotePath)
+	case r.windowsRunner() != nil:
+		return fmt.Errorf("uploading files without SFTP is not supported on Windows hosts")
+	default:
+		r.logChangeEvent(spec, LogEvent{Message: r.format(spec), Command: spec.cmd, Shell: true})
+	}
+	if r.Dryrun {
+		return nil
+	}
+	if r.checking() {
+		r.check.add(Change{Action: ChangeModify, Target: remotePath, Detail: "upload " + localPath})
+		return nil
+	}
+
+	var sent int64
+	switch {
+	case remote != nil:
+		err = remote.withSFTP(func(c *sftpClient) error {
+			n, err := c.writeFrom(remotePath, f, mode)
+			sent = n
+			return err
+		})
+	case local != nil:
+		_, err = copyToFile(r.localPath(local, remotePath), f, mode)
+	default:
+		stdin := &countingReader{rd: f}
+		_, stderr, code := r.runSpec(execSpec{
+			cmd:   writeFileCmd(remotePath, mode),
+			shell: true,
+			stdin: stdin,
+		})
+		sent = stdin.n
+		if code != 0 {
+			err = commandError(stderr, code)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("could not upload %s to %s: %w", localPath, remotePath, err)
+	}
+	if r.countTransfers() {
+		r.recordTransfer("Upload", remotePath, sent, 0)
+	}
+	r.RecordOperation("Upload", remotePath, true)
+
+	return nil
+}
+
+// Download copies the file at remotePath on the host to localPath on
+// the controller, i.e., the host running the program, like Upload() in
+// the other direction. The local file is written to a temporary file
+// which is then renamed and is created with permission bits 0644. If
+// remotePath does not exist, the returned error matches ErrNotFound.
+// Only logging is performed if Dryrun is true. Downloads are performed
+// in check mode since they do not change the host.
+func (r *LogRun) Download(remotePath string, localPath string) (err error) {
+	r = r.beginHelper()
+	defer r.addErrorContext(&err, r.commandSeq())
+	remote := r.sftpRunner()
+	local := r.localRunner()
+	spec := execSpec{cmd: ReadFileCmd, args: []string{remotePath}, planOutput: localPath}
+	switch {
+	case remote != nil:
+		r.logChange(spec, remote.formatSFTP("get", remotePath, localPath))
+	case local != nil:
+		r.logChange(spec, "get "+remotePath+" "+localPath)
+	case r.windowsRunner() != nil:
+		return fmt.Errorf("downloading files without SFTP is not supported on Windows hosts")
+	default:
+		r.logChangeEvent(spec, LogEvent{Message: r.format(spec), Command: ReadFileCmd, Args: spec.args})
+	}
+	if r.Dryrun {
+		return nil
+	}
+
+	pr, pw := io.Pipe()
+	type copied struct {
+		n   int64
+		err error
+	}
+	done := make(chan copied, 1)
+	go func() {
+		n, err := copyToFile(localPath, pr, 0644)
+		if err != nil {
+			pr.CloseWithError(err) // nolint: errcheck
+		}
+		done <- copied{n, err}
+	}()
+	switch {
+	case remote != nil:
+		err = remote.withSFTP(func(c *sftpClient) error {
+			_, err := c.readTo(remotePath, pw)
+			return err
+		})
+	case local != nil:
+		var f *os.File
+		if f, err = os.Open(r.localPath(local, remotePath)); err == nil {
+			_, err = io.Copy(pw, f)
+			f.Close() // nolint: errcheck
+		}
+	default:
+		_, stderr, code := r.runSpec(execSpec{
+			cmd:    ReadFileCmd,
+			args:   []string{remotePath},
+			stdout: pw,
+		})
+		if code != 0 {
+			err = commandError(stderr, code)
+		}
+	}
+	// The temporary file is removed if the copy failed.
+	pw.CloseWithError(err) // nolint: errcheck
+	res := <-done
+	if res.err != nil && res.err != err {
+		// The local file could not be written.
+		return fmt.Errorf("could not download %s to %s: %w", remotePath, localPath, res.err)
+	}
+	if errors.Is(err, ErrNotFound) || errors.Is(err, os.ErrNotExist) {
+		return notFoundError("could not download", remotePath)
+	}
+	if err != nil {
+		return fmt.Errorf("could not download %s to %s: %w", remotePath, localPath, err)
+	}
+	if r.countTransfers() {
+		r.recordTransfer("Download", remotePath, 0, res.n)
+	}
+
+	return nil
+}
+
+// copyToFile writes the contents read from rd to a temporary file next
+// to dest with permission bits mode and renames it to dest. It returns
+// the number of bytes written. The temporary file has a random name
+// and is created exclusively, so concurrent writers and planted
+// symbolic links are not written through.
+func copyToFile(dest string, rd io.Reader, mode os.FileMode) (int64, error) {
+	f, err := ioutil.TempFile(filepath.Dir(dest), "."+filepath.Base(dest)+".*")
+	if err != nil {
+		return 0, err
+	}
+	n, err := io.Copy(f, rd)
+	if err == nil {
+		err = f.Chmod(mode)
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), dest)
+	}
+	if err != nil {
+		os.Remove(f.Name()) // nolint: errcheck
+		return n, err
+	}
+
+	return n, nil
+}
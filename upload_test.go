@@ -0,0 +1,164 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun_test
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/apatters/go-logrun"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testUploadDownload uploads and downloads a file larger than an SFTP
+// chunk using r.
+func testUploadDownload(t *testing.T, r *logrun.LogRun) {
+	tmpDir, err := ioutil.TempDir("", "logrun")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmpDir)
+	content := bytes.Repeat([]byte("0123456789abcdef\n"), 5000)
+	src := filepath.Join(tmpDir, "app.tar")
+	require.NoError(t, ioutil.WriteFile(src, content, 0640))
+	require.NoError(t, os.Chmod(src, 0640))
+
+	remotePath := filepath.Join(tmpDir, "uploaded.tar")
+	require.NoError(t, r.Upload(src, remotePath))
+	uploaded, err := ioutil.ReadFile(remotePath)
+	require.NoError(t, err)
+	assert.Equal(t, content, uploaded)
+	fi, err := os.Stat(remotePath)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0640), fi.Mode().Perm())
+
+	dest := filepath.Join(tmpDir, "downloaded.tar")
+	require.NoError(t, r.Download(remotePath, dest))
+	downloaded, err := ioutil.ReadFile(dest)
+	require.NoError(t, err)
+	assert.Equal(t, content, downloaded)
+	tmpFiles, err := filepath.Glob(filepath.Join(tmpDir, ".*"))
+	require.NoError(t, err)
+	assert.Empty(t, tmpFiles)
+
+	err = r.Download(filepath.Join(tmpDir, "missing"), filepath.Join(tmpDir, "missing.out"))
+	t.Logf("err = %v", err)
+	assert.True(t, errors.Is(err, logrun.ErrNotFound))
+	_, err = os.Stat(filepath.Join(tmpDir, "missing.out"))
+	assert.True(t, os.IsNotExist(err))
+
+	err = r.Upload(filepath.Join(tmpDir, "missing"), remotePath)
+	t.Logf("err = %v", err)
+	assert.Error(t, err)
+}
+
+func TestLocalLogRun_UploadDownload(t *testing.T) {
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{})
+	l.SetRecorder(logrun.NewRecorder())
+	testUploadDownload(t, l)
+	assert.Empty(t, l.Recorder().Transfers())
+}
+
+func TestRemoteLogRun_UploadDownload(t *testing.T) {
+	s := newTestSSHServer(t, nil)
+	defer s.Close()
+	log, out, _ := newLogger()
+	r, err := logrun.NewRemoteLogRun(logrun.RemoteConfig{
+		LogFunc:     log.Println,
+		Credentials: s.Credentials(),
+	})
+	require.NoError(t, err)
+	r.SetRecorder(logrun.NewRecorder())
+	testUploadDownload(t, r)
+	t.Logf("out = %q", out)
+	assert.Contains(t, out.String(), "cat > ")
+	assert.Contains(t, out.String(), logrun.ReadFileCmd+" ")
+
+	var sent, received int64
+	for _, stat := range r.Recorder().Transfers() {
+		sent += stat.BytesSent
+		received += stat.BytesReceived
+	}
+	assert.Equal(t, int64(85000), sent)
+	assert.Equal(t, int64(85000), received)
+	assert.Equal(t, 1, r.Summary().Changed)
+}
+
+func TestRemoteLogRun_SFTPUploadDownload(t *testing.T) {
+	s := newTestSSHServer(t, nil)
+	defer s.Close()
+	r, output := newSFTPTestLogRun(t, s)
+	testUploadDownload(t, r)
+	out := output()
+	t.Logf("out = %q", out)
+	assert.Contains(t, out, "sftp ")
+	assert.Contains(t, out, " put ")
+	assert.Contains(t, out, " get ")
+	assert.False(t, strings.Contains(out, "cat > "), "file was not uploaded with SFTP")
+}
+
+func TestLocalLogRun_UploadDryrun(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "logrun")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmpDir)
+	src := filepath.Join(tmpDir, "app.conf")
+	require.NoError(t, ioutil.WriteFile(src, []byte("x"), 0644))
+
+	log, out, _ := newLogger()
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{LogFunc: log.Println, Dryrun: true})
+	dest := filepath.Join(tmpDir, "dest.conf")
+	require.NoError(t, l.Upload(src, dest))
+	require.NoError(t, l.Download(src, dest))
+	t.Logf("out = %q", out)
+	assert.Equal(t, "put "+src+" "+dest+"\nget "+src+" "+dest+"\n", out.String())
+	_, err = os.Stat(dest)
+	assert.True(t, os.IsNotExist(err))
+
+	l.SetDryrun(false)
+	report, err := l.Check(func(r *logrun.LogRun) error {
+		return r.Upload(src, dest)
+	})
+	require.NoError(t, err)
+	require.Len(t, report.Changes(), 1)
+	assert.Equal(t, dest, report.Changes()[0].Target)
+	_, err = os.Stat(dest)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestLocalLogRun_ConcurrentUploads(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "logrun")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmpDir)
+	srcDir := filepath.Join(tmpDir, "src")
+	require.NoError(t, os.Mkdir(srcDir, 0755))
+	dest := filepath.Join(tmpDir, "app.tar")
+
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{})
+	contents := make([]string, 8)
+	var wg sync.WaitGroup
+	for i := range contents {
+		contents[i] = strings.Repeat(strconv.Itoa(i), 100000)
+		src := filepath.Join(srcDir, strconv.Itoa(i))
+		require.NoError(t, ioutil.WriteFile(src, []byte(contents[i]), 0644))
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, l.Upload(src, dest))
+		}()
+	}
+	wg.Wait()
+	uploaded, err := ioutil.ReadFile(dest)
+	require.NoError(t, err)
+	assert.Contains(t, contents, string(uploaded))
+	tmpFiles, err := filepath.Glob(filepath.Join(tmpDir, ".*"))
+	require.NoError(t, err)
+	assert.Empty(t, tmpFiles)
+}
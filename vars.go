@@ -0,0 +1,93 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun
+
+import (
+	"fmt"
+)
+
+// SetVars sets the variables of the host the LogRun runs commands
+// on. Variables are referenced in command templates using the var
+// function, e.g., {{var "port"}}, so the same templates can be run
+// against hosts that use different ports, paths, etc. Only templated
+// commands, e.g., those run by ShellTemplate() and RunTemplate(), are
+// expanded; the command lines passed to Run() and Shell() are run as
+// is. Later maps override the values of earlier ones:
+//
+//	runner.SetVars(webVars, hostVars)
+//
+// Host variables override the group variables the LogRun inherits
+// from a Pool, see Pool.SetVars(), or a TaskList, see
+// TaskListConfig.Vars. SetVars replaces any host variables set
+// earlier.
+func (r *LogRun) SetVars(vars ...map[string]string) {
+	r.vars = make(map[string]string)
+	for _, m := range vars {
+		for k, v := range m {
+			r.vars[k] = v
+		}
+	}
+}
+
+// SetVar sets the value of a single host variable.
+func (r *LogRun) SetVar(name string, value string) {
+	vars := make(map[string]string, len(r.vars)+1)
+	for k, v := range r.vars {
+		vars[k] = v
+	}
+	vars[name] = value
+	r.vars = vars
+}
+
+// Var returns the value of the variable name, set for the host or
+// inherited from its group, and whether or not it is set.
+func (r *LogRun) Var(name string) (string, bool) {
+	if v, ok := r.vars[name]; ok {
+		return v, true
+	}
+	v, ok := r.groupVars[name]
+
+	return v, ok
+}
+
+// Vars returns a copy of the variables of the host, including those
+// inherited from its group.
+func (r *LogRun) Vars() map[string]string {
+	vars := make(map[string]string, len(r.groupVars)+len(r.vars))
+	for k, v := range r.groupVars {
+		vars[k] = v
+	}
+	for k, v := range r.vars {
+		vars[k] = v
+	}
+
+	return vars
+}
+
+// withGroupVars returns a copy of r that inherits vars as group
+// variables, overriding the group variables r already inherits.
+func (r *LogRun) withGroupVars(vars map[string]string) *LogRun {
+	c := r.With()
+	c.groupVars = make(map[string]string, len(r.groupVars)+len(vars))
+	for k, v := range r.groupVars {
+		c.groupVars[k] = v
+	}
+	for k, v := range vars {
+		c.groupVars[k] = v
+	}
+
+	return c
+}
+
+// lookupVar returns the value of the variable name for use in command
+// templates. It is an error to reference an unset variable.
+func (r *LogRun) lookupVar(name string) (string, error) {
+	v, ok := r.Var(name)
+	if !ok {
+		return "", fmt.Errorf("host variable %q is not set", name)
+	}
+
+	return v, nil
+}
@@ -0,0 +1,132 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun_test
+
+import (
+	"testing"
+
+	"github.com/apatters/go-logrun"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogRun_SetVars(t *testing.T) {
+	group := map[string]string{"port": "8080", "root": "/srv/www"}
+	host := map[string]string{"port": "9090"}
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{
+		Vars: map[string]string{"unused": "x"},
+	})
+	v, ok := l.Var("unused")
+	assert.True(t, ok)
+	assert.Equal(t, "x", v)
+
+	l.SetVars(group, host)
+	t.Logf("vars = %v", l.Vars())
+	assert.Equal(t, map[string]string{"port": "9090", "root": "/srv/www"}, l.Vars())
+	_, ok = l.Var("unused")
+	assert.False(t, ok)
+
+	c := l.With()
+	c.SetVar("root", "/var/www")
+	v, _ = c.Var("root")
+	assert.Equal(t, "/var/www", v)
+	v, _ = l.Var("root")
+	assert.Equal(t, "/srv/www", v)
+}
+
+func TestLogRun_RenderTemplate(t *testing.T) {
+	tmpl := logrun.MustCommandTemplate("deploy", `deploy --port {{var "port"}} --root {{var "root"}} {{.}}`)
+	web1 := logrun.NewLocalLogRun(logrun.LocalConfig{
+		Vars: map[string]string{"port": "8080", "root": "/srv/www"},
+	})
+	web2 := logrun.NewLocalLogRun(logrun.LocalConfig{
+		Vars: map[string]string{"port": "9090", "root": "/srv/my www"},
+	})
+
+	cmd, err := web1.RenderTemplate(tmpl, "app.tgz")
+	t.Logf("web1 = %q", cmd)
+	require.NoError(t, err)
+	assert.Equal(t, "deploy --port '8080' --root '/srv/www' 'app.tgz'", cmd)
+	cmd, err = web2.RenderTemplate(tmpl, "app.tgz")
+	t.Logf("web2 = %q", cmd)
+	require.NoError(t, err)
+	assert.Equal(t, "deploy --port '9090' --root '/srv/my www' 'app.tgz'", cmd)
+
+	_, err = tmpl.Render("app.tgz")
+	t.Logf("err = %v", err)
+	assert.Error(t, err)
+
+	_, stderr, code := logrun.NewLocalLogRun(logrun.LocalConfig{}).ShellTemplate(tmpl, "app.tgz")
+	t.Logf("stderr = %q", stderr)
+	assert.Equal(t, logrun.ExitErrorExecute, code)
+	assert.Contains(t, stderr, `"port" is not set`)
+
+	stdout, _, code := web2.ShellTemplate(logrun.MustCommandTemplate("echo", `echo {{var "root"}}`), nil)
+	assert.Zero(t, code)
+	assert.Equal(t, "/srv/my www\n", stdout)
+}
+
+func TestPool_SetVars(t *testing.T) {
+	var runners []*logrun.LogRun
+	for _, host := range []string{"web1", "web2"} {
+		r, err := logrun.NewRemoteLogRun(logrun.RemoteConfig{
+			Credentials: logrun.Credentials{Hostname: host, Username: "deploy", Password: "secret"},
+		})
+		require.NoError(t, err)
+		runners = append(runners, r)
+	}
+	runners[1].SetVar("port", "9090")
+	p, err := logrun.NewPool(runners, logrun.PoolConfig{
+		Vars: map[string]string{"port": "8080", "root": "/srv/www"},
+	})
+	require.NoError(t, err)
+
+	// Host variables override the group variables of the Pool.
+	tmpl := logrun.MustCommandTemplate("deploy", `deploy --port {{var "port"}} --root {{var "root"}}`)
+	cmd, err := runners[0].RenderTemplate(tmpl, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "deploy --port '8080' --root '/srv/www'", cmd)
+	cmd, err = runners[1].RenderTemplate(tmpl, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "deploy --port '9090' --root '/srv/www'", cmd)
+	assert.Equal(t, map[string]string{"port": "9090", "root": "/srv/www"}, runners[1].Vars())
+
+	p.SetVars(map[string]string{"port": "80"})
+	_, ok := runners[0].Var("root")
+	assert.False(t, ok)
+	v, _ := runners[0].Var("port")
+	assert.Equal(t, "80", v)
+}
+
+func TestTaskList_Vars(t *testing.T) {
+	var got []string
+	tasks, err := logrun.NewTaskList([]logrun.Task{{
+		Name: "deploy",
+		Run: func(r *logrun.LogRun) error {
+			for _, name := range []string{"port", "root", "user"} {
+				v, _ := r.Var(name)
+				got = append(got, v)
+			}
+			return nil
+		},
+	}}, logrun.TaskListConfig{
+		Vars: map[string]string{"port": "8080", "root": "/srv/www"},
+	})
+	require.NoError(t, err)
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{
+		Vars: map[string]string{"root": "/var/www"},
+	})
+	p, err := logrun.NewPool([]*logrun.LogRun{l}, logrun.PoolConfig{
+		Vars: map[string]string{"port": "80", "user": "www"},
+	})
+	require.NoError(t, err)
+
+	// The variables of the TaskList override those of the Pool and
+	// are overridden by those of the host.
+	require.NoError(t, tasks.Run(p.Runners()[0]).Err())
+	assert.Equal(t, []string{"8080", "/var/www", "www"}, got)
+	v, _ := l.Var("port")
+	assert.Equal(t, "80", v)
+}
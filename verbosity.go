@@ -0,0 +1,117 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Verbosity selects how much is logged about each command.
+type Verbosity int
+
+const (
+	// VerbosityNormal logs the command line of each command.
+	VerbosityNormal Verbosity = iota
+
+	// VerbosityFull also logs the effective working directory
+	// of each command and the environment variables set for it
+	// rather than inherited, e.g.,
+	//
+	//	/usr/bin/make all
+	//	  dir: /srv/app
+	//	  env: GOFLAGS=-mod=vendor API_TOKEN=********
+	//
+	// Values of variables whose names contain PASSWORD, SECRET,
+	// TOKEN, or KEY are replaced by MaskedSecret.
+	VerbosityFull
+)
+
+// String returns the name of the verbosity.
+func (v Verbosity) String() string {
+	switch v {
+	case VerbosityNormal:
+		return "normal"
+	case VerbosityFull:
+		return "full"
+	}
+
+	return fmt.Sprintf("Verbosity(%d)", int(v))
+}
+
+// SetVerbosity sets how much is logged about each command. The
+// default is VerbosityNormal.
+func (r *LogRun) SetVerbosity(v Verbosity) {
+	r.verbosity = v
+}
+
+// logContext logs the working directory and environment of spec if
+// the verbosity is VerbosityFull.
+func (r *LogRun) logContext(spec execSpec) {
+	if r.verbosity < VerbosityFull {
+		return
+	}
+	if spec.dir == "" {
+		spec.dir = r.execDir()
+	}
+	if spec.env == nil {
+		spec.env = r.call.env
+	}
+	dir, env := r.execContext(&spec)
+	if dir != "" {
+		r.log(SectionIndent + "dir: " + dir)
+	}
+	if len(env) > 0 {
+		r.log(SectionIndent + "env: " + strings.Join(maskEnv(env), " "))
+	}
+}
+
+// execContext returns the effective working directory of spec and the
+// environment variables set for it that are not inherited from the
+// program. The directory is empty if it is unknown.
+func (r *LogRun) execContext(spec *execSpec) (string, []string) {
+	switch runner := r.Runner.(type) {
+	case *localRunner:
+		dir := runner.workDir(spec)
+		if dir == "" {
+			dir, _ = os.Getwd()
+		}
+		return dir, appendEnv(nonInheritedEnv(runner.env, os.Environ()), spec.env)
+	case *remoteRunner:
+		dir := runner.workDir(spec)
+		switch {
+		case dir != "":
+		case runner.remoteOS == RemoteWindows:
+			dir = "%USERPROFILE%"
+		default:
+			dir = "~"
+		}
+		return dir, appendEnv(runner.env, spec.env)
+	}
+
+	return spec.dir, nil
+}
+
+// nonInheritedEnv returns the variables of env that are not set to the
+// same value in environ. Nil is returned if env is nil, i.e., if
+// environ is inherited.
+func nonInheritedEnv(env []string, environ []string) []string {
+	if env == nil {
+		return nil
+	}
+	inherited := make(map[string]bool, len(environ))
+	for _, kv := range environ {
+		inherited[kv] = true
+	}
+	var vars []string
+	for _, kv := range env {
+		if !inherited[kv] {
+			vars = append(vars, kv)
+		}
+	}
+
+	return vars
+}
@@ -0,0 +1,88 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/apatters/go-logrun"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogRun_SetVerbosity(t *testing.T) {
+	log, out, _ := newLogger()
+	dir := t.TempDir()
+	env := append(os.Environ(), "GREETING=hello", "API_TOKEN=s3cret")
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{
+		LogFunc: log.Println,
+		Env:     env,
+	})
+
+	// Normal verbosity only logs the command.
+	_, _, code := l.Shell("true")
+	t.Logf("out = %q", out)
+	require.Zero(t, code)
+	assert.Equal(t, "/bin/sh -c \"true\"\n", out.String())
+
+	out.Reset()
+	l.SetVerbosity(logrun.VerbosityFull)
+	l.PushDir(dir)
+	stdout, _, code := l.Shell("echo $GREETING")
+	t.Logf("stdout = %q", stdout)
+	t.Logf("out = %q", out)
+	require.Zero(t, code)
+	assert.Equal(t, "hello\n", stdout)
+	assert.Equal(t,
+		"cd '"+dir+"' && /bin/sh -c 'echo $GREETING'\n"+
+			"  dir: "+dir+"\n"+
+			"  env: GREETING=hello API_TOKEN="+logrun.MaskedSecret+"\n",
+		out.String())
+	assert.NotContains(t, out.String(), "s3cret")
+	assert.Equal(t, logrun.VerbosityFull, l.Config().Verbosity)
+
+	// Inherited environments are not logged.
+	out.Reset()
+	l = logrun.NewLocalLogRun(logrun.LocalConfig{
+		LogFunc:   log.Println,
+		Verbosity: logrun.VerbosityFull,
+		Dryrun:    true,
+	})
+	l.Run("/bin/true")
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	t.Logf("out = %q", out)
+	assert.Equal(t, "/bin/true\n  dir: "+wd+"\n", out.String())
+}
+
+func TestRemoteLogRun_SetVerbosity(t *testing.T) {
+	log, out, _ := newLogger()
+	l, err := logrun.NewRemoteLogRun(logrun.RemoteConfig{
+		LogFunc: log.Println,
+		Credentials: logrun.Credentials{
+			Hostname: "testhost",
+			Username: "tester",
+			Password: "secret",
+		},
+		Verbosity: logrun.VerbosityFull,
+		Dryrun:    true,
+	})
+	require.NoError(t, err)
+	l.Run("/bin/true")
+	l.PushDir("/srv")
+	l.Run("/bin/true")
+	t.Logf("out = %q", out)
+	assert.Equal(t,
+		"ssh tester@testhost /bin/true\n  dir: ~\n"+
+			"ssh tester@testhost cd '/srv' && /bin/true\n  dir: /srv\n",
+		out.String())
+}
+
+func TestVerbosity_String(t *testing.T) {
+	assert.Equal(t, "normal", logrun.VerbosityNormal.String())
+	assert.Equal(t, "full", logrun.VerbosityFull.String())
+	assert.Equal(t, "Verbosity(7)", logrun.Verbosity(7).String())
+}
@@ -0,0 +1,261 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// RemoteOS is the operating system of a remote host. It selects how
+// command lines, shell commands, working directories, and file
+// operations are formatted.
+type RemoteOS int
+
+const (
+	// RemoteUnix is a host with a POSIX shell and the GNU
+	// coreutils, e.g., RHEL/CentOS or Ubuntu. It is the default.
+	RemoteUnix RemoteOS = iota
+
+	// RemoteWindows is a host running the Win32 port of OpenSSH
+	// with cmd.exe as its default shell. Shell commands are run
+	// using PowerShellCmd unless ShellExecutable is cmd.exe, and
+	// FileExists(), DirExists(), and Glob() use PowerShell
+	// cmdlets such as Test-Path. Paths may use backslashes and
+	// drive letters. Commands with a timeout or context, detached
+	// commands, and the other file helpers are not supported
+	// unless UseSFTP is true for the file helpers.
+	RemoteWindows
+)
+
+// String returns the name of the operating system.
+func (o RemoteOS) String() string {
+	switch o {
+	case RemoteUnix:
+		return "unix"
+	case RemoteWindows:
+		return "windows"
+	}
+
+	return fmt.Sprintf("RemoteOS(%d)", int(o))
+}
+
+var (
+	// PowerShellCmd is the external command used to run shell
+	// commands and file operations on Windows hosts. It is found
+	// using the PATH.
+	PowerShellCmd = "powershell"
+
+	// PowerShellCmdOptions are the command-line options added to
+	// PowerShellCmd before the command to run.
+	PowerShellCmdOptions = []string{
+		"-NoProfile",
+		"-NonInteractive",
+		"-Command",
+	}
+)
+
+// windowsRunner returns the Runner of r if it is a remote runner for a
+// Windows host or a WinRM runner, and nil otherwise.
+func (r *LogRun) windowsRunner() Runner {
+	switch runner := r.Runner.(type) {
+	case *remoteRunner:
+		if runner.remoteOS == RemoteWindows {
+			return runner
+		}
+	case *winrmRunner:
+		return runner
+	}
+
+	return nil
+}
+
+// isWindowsAbs returns true if p is an absolute Windows path, i.e.,
+// it starts with a drive letter and a separator or is a UNC path.
+func isWindowsAbs(p string) bool {
+	if strings.HasPrefix(p, `\\`) {
+		return true
+	}
+
+	return len(p) >= 3 && p[1] == ':' && (p[2] == '\\' || p[2] == '/') &&
+		((p[0] >= 'a' && p[0] <= 'z') || (p[0] >= 'A' && p[0] <= 'Z'))
+}
+
+// joinPath joins elem to the directory dir of the host of r.
+func (r *LogRun) joinPath(dir string, elem string) string {
+	if r.windowsRunner() == nil {
+		return path.Join(dir, elem)
+	}
+	if isWindowsAbs(elem) {
+		return elem
+	}
+
+	return strings.TrimRight(dir, `\/`) + `\` + strings.Replace(elem, "/", `\`, -1)
+}
+
+// isAbsPath returns true if p is an absolute path on the host of r.
+func (r *LogRun) isAbsPath(p string) bool {
+	if r.windowsRunner() != nil {
+		return isWindowsAbs(p)
+	}
+
+	return path.IsAbs(p)
+}
+
+// cmdQuote quotes s so that it is passed to a Windows program as a
+// single argument by cmd.exe and the Microsoft C runtime. Strings
+// without spaces or quotes are returned unchanged.
+func cmdQuote(s string) string {
+	if s != "" && !strings.ContainsAny(s, " \t\"&|<>^") {
+		return s
+	}
+
+	return `"` + strings.Replace(s, `"`, `\"`, -1) + `"`
+}
+
+// psQuote quotes s as a PowerShell literal string.
+func psQuote(s string) string {
+	return "'" + strings.Replace(s, "'", "''", -1) + "'"
+}
+
+// isCmdShell returns true if shell is cmd.exe.
+func isCmdShell(shell string) bool {
+	name := strings.ToLower(filepath.Base(strings.Replace(shell, `\`, "/", -1)))
+
+	return name == "cmd" || name == "cmd.exe"
+}
+
+// windowsCommandLine returns the command line sent to a Windows host
+// for the command described by spec.
+func (r *remoteRunner) windowsCommandLine(spec *execSpec) string {
+	return windowsCommandLine(r.shellExecutable, spec)
+}
+
+// windowsCommandLine returns the cmd.exe command line running the
+// command described by spec, using shell for shell commands.
+func windowsCommandLine(shell string, spec *execSpec) string {
+	if spec.shell {
+		if isCmdShell(shell) {
+			return fmt.Sprintf("%s /c %s", shell, spec.cmd)
+		}
+		args := append([]string{shell}, PowerShellCmdOptions...)
+		return strings.Join(append(args, cmdQuote(spec.cmd)), " ")
+	}
+	words := []string{spec.cmd}
+	for _, arg := range spec.args {
+		words = append(words, cmdQuote(arg))
+	}
+
+	return strings.Join(words, " ")
+}
+
+// powerShell logs and runs script using PowerShellCmd and returns its
+// trimmed standard output. Only logging is performed if Dryrun is
+// true, in which case the empty string is returned.
+func (r *LogRun) powerShell(script string) (string, string, int) {
+	args := append(append([]string{}, PowerShellCmdOptions...), script)
+	r.logRun(PowerShellCmd, args...)
+	if r.Dryrun {
+		return "", "", 0
+	}
+	stdout, stderr, code := r.run(PowerShellCmd, args...)
+
+	return strings.TrimSpace(stdout), strings.TrimSpace(stderr), code
+}
+
+// windowsPathType returns "leaf", "container", or "none" for p using
+// Test-Path.
+func (r *LogRun) windowsPathType(p string) (string, error) {
+	script := fmt.Sprintf(
+		"if (Test-Path -LiteralPath %[1]s -PathType Leaf) { 'leaf' } "+
+			"elseif (Test-Path -LiteralPath %[1]s -PathType Container) { 'container' } "+
+			"else { 'none' }",
+		psQuote(p))
+	stdout, stderr, code := r.powerShell(script)
+	if code != 0 {
+		return "", commandAccessError(p, stderr, code)
+	}
+
+	return strings.ToLower(stdout), nil
+}
+
+func (r *LogRun) windowsFileExists(filename string) (bool, error) {
+	typ, err := r.windowsPathType(filename)
+	switch {
+	case err != nil:
+		return false, err
+	case r.Dryrun:
+		return true, nil
+	case typ == "none":
+		return false, nil
+	case typ != "leaf":
+		return false, fmt.Errorf("%s is %w", filename, ErrNotRegularFile)
+	}
+
+	return true, nil
+}
+
+func (r *LogRun) windowsDirExists(dirname string) (bool, error) {
+	typ, err := r.windowsPathType(dirname)
+	switch {
+	case err != nil:
+		return false, err
+	case r.Dryrun:
+		return true, nil
+	case typ == "none":
+		return false, nil
+	case typ != "container":
+		return false, fmt.Errorf("%s is %w", dirname, ErrNotDirectory)
+	}
+
+	return true, nil
+}
+
+func (r *LogRun) windowsGlob(pattern string, opts GlobOptions) ([]string, error) {
+	script := fmt.Sprintf(
+		"Resolve-Path -Path %s -ErrorAction SilentlyContinue | ForEach-Object { $_.ProviderPath }",
+		psQuote(pattern))
+	if opts.Sort != GlobSortNone || opts.Reverse || opts.Limit > 0 {
+		script = windowsGlobScript(pattern, opts)
+	}
+	stdout, stderr, code := r.powerShell(script)
+	if code != 0 {
+		return []string{}, commandGlobError(pattern, stderr, code)
+	}
+	results := []string{}
+	for _, line := range lines(stdout) {
+		results = append(results, strings.TrimSpace(line))
+	}
+	if len(results) == 0 && !r.Dryrun {
+		return []string{}, globError(pattern, "no matches")
+	}
+
+	return results, nil
+}
+
+// windowsGlobScript returns the PowerShell script expanding pattern
+// that sorts and limits the paths according to opts. Paths are sorted
+// by name for GlobSortNone, which is the order of Resolve-Path.
+func windowsGlobScript(pattern string, opts GlobOptions) string {
+	property, descending := "FullName", opts.Reverse
+	switch opts.Sort {
+	case GlobSortModTime:
+		property, descending = "LastWriteTime", !opts.Reverse
+	case GlobSortSize:
+		property, descending = "Length", !opts.Reverse
+	}
+	script := fmt.Sprintf(
+		"Get-Item -Path %s -ErrorAction SilentlyContinue | Sort-Object -Property @{Expression='%s'; Descending=$%t}, FullName",
+		psQuote(pattern),
+		property,
+		descending)
+	if opts.Limit > 0 {
+		script += fmt.Sprintf(" | Select-Object -First %d", opts.Limit)
+	}
+
+	return script + " | ForEach-Object { $_.FullName }"
+}
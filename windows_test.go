@@ -0,0 +1,131 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/apatters/go-logrun"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newWindowsTestLogRun(t *testing.T, shell string) *logrun.LogRun {
+	r, err := logrun.NewRemoteLogRun(logrun.RemoteConfig{
+		Credentials: logrun.Credentials{
+			Hostname: "winhost",
+			Username: "admin",
+			Password: "secret",
+		},
+		ShellExecutable: shell,
+		RemoteOS:        logrun.RemoteWindows,
+	})
+	require.NoError(t, err)
+
+	return r
+}
+
+func TestRemoteLogRun_WindowsFormat(t *testing.T) {
+	r := newWindowsTestLogRun(t, "")
+	s := r.FormatRun(`C:\Tools\app.exe`, "--config", `C:\Program Files\App\app.conf`)
+	t.Logf("FormatRun = %q", s)
+	assert.Equal(t, `ssh admin@winhost C:\Tools\app.exe --config "C:\Program Files\App\app.conf"`, s)
+
+	s = r.FormatShell(`Get-Service "sshd"`)
+	t.Logf("FormatShell = %q", s)
+	assert.Equal(t, `ssh admin@winhost powershell -NoProfile -NonInteractive -Command "Get-Service \"sshd\""`, s)
+
+	r = newWindowsTestLogRun(t, "cmd.exe")
+	s = r.FormatShell("dir /b")
+	t.Logf("FormatShell = %q", s)
+	assert.Equal(t, `ssh admin@winhost cmd.exe /c dir /b`, s)
+	assert.Equal(t, logrun.RemoteWindows, r.Config().RemoteOS)
+	assert.Equal(t, "windows", r.Config().RemoteOS.String())
+}
+
+func TestRemoteLogRun_WindowsDir(t *testing.T) {
+	var logged []string
+	r := newWindowsTestLogRun(t, "")
+	r.SetLogFunc(func(v ...interface{}) {
+		logged = append(logged, v[0].(string))
+	})
+	r.SetDryrun(true)
+	r.PushDir(`C:\App`)
+	r.PushDir("bin/x64")
+	t.Logf("Dir = %q", r.Dir())
+	assert.Equal(t, `C:\App\bin\x64`, r.Dir())
+	r.PushDir(`D:\Data`)
+	assert.Equal(t, `D:\Data`, r.Dir())
+	_, err := r.PopDir()
+	assert.NoError(t, err)
+
+	r.Run("app.exe", "--version")
+	t.Logf("logged = %q", logged)
+	require.Len(t, logged, 1)
+	assert.Equal(t, `ssh admin@winhost cd /d C:\App\bin\x64 && app.exe --version`, logged[0])
+}
+
+func TestRemoteLogRun_WindowsFileOps(t *testing.T) {
+	s := newTestSSHServer(t, nil)
+	defer s.Close()
+	tmpDir, err := ioutil.TempDir("", "logrun")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmpDir)
+	origPath := os.Getenv("PATH")
+	defer os.Setenv("PATH", origPath) // nolint: errcheck
+	require.NoError(t, os.Setenv("PATH", tmpDir+":"+origPath))
+	fake := func(output string) {
+		fakeCommand(t, tmpDir, "powershell", "printf '"+output+"'\n")
+	}
+
+	log, out, _ := newLogger()
+	r, err := logrun.NewRemoteLogRun(logrun.RemoteConfig{
+		LogFunc:     log.Println,
+		Credentials: s.Credentials(),
+		RemoteOS:    logrun.RemoteWindows,
+	})
+	require.NoError(t, err)
+	defer r.Close() // nolint: errcheck
+
+	fake(`leaf\r\n`)
+	exists, err := r.FileExists(`C:\Windows\notepad.exe`)
+	t.Logf("out = %q", out)
+	assert.NoError(t, err)
+	assert.True(t, exists)
+	assert.Contains(t, out.String(), `powershell -NoProfile -NonInteractive -Command "if (Test-Path -LiteralPath 'C:\Windows\notepad.exe' -PathType Leaf)`)
+	exists, err = r.DirExists(`C:\Windows\notepad.exe`)
+	t.Logf("err = %v", err)
+	assert.False(t, exists)
+	assert.True(t, errors.Is(err, logrun.ErrNotDirectory))
+
+	fake(`container\r\n`)
+	exists, err = r.DirExists(`C:\Windows`)
+	assert.NoError(t, err)
+	assert.True(t, exists)
+	exists, err = r.FileExists(`C:\Windows`)
+	t.Logf("err = %v", err)
+	assert.False(t, exists)
+	assert.True(t, errors.Is(err, logrun.ErrNotRegularFile))
+
+	fake(`none\r\n`)
+	exists, err = r.FileExists(`C:\xyzzy`)
+	assert.NoError(t, err)
+	assert.False(t, exists)
+
+	fake(`C:\\Windows\\win.ini\r\nC:\\Windows\\system.ini\r\n`)
+	paths, err := r.Glob(`C:\Windows\*.ini`)
+	t.Logf("paths = %q", paths)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{`C:\Windows\win.ini`, `C:\Windows\system.ini`}, paths)
+
+	fake(``)
+	paths, err = r.Glob(`C:\xyzzy*`)
+	t.Logf("err = %v", err)
+	assert.Equal(t, []string{}, paths)
+	assert.True(t, errors.Is(err, logrun.ErrGlobFailed))
+}
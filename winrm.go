@@ -0,0 +1,614 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultWinRMPort and DefaultWinRMHTTPSPort are the ports
+	// WinRM listens on for HTTP and HTTPS.
+	DefaultWinRMPort      = 5985
+	DefaultWinRMHTTPSPort = 5986
+)
+
+// WinRMOperationTimeout is the time a WinRM server waits for output
+// before answering a receive request with a timeout fault, after which
+// the request is repeated. It bounds how long cancellation of a
+// command may take to be noticed by the server.
+var WinRMOperationTimeout = 20 * time.Second
+
+// WinRM (WS-Management) actions, resource URIs, and options. See
+// [MS-WSMV] and [MS-WSMAN].
+const (
+	winrmActionCreate  = "http://schemas.xmlsoap.org/ws/2004/09/transfer/Create"
+	winrmActionDelete  = "http://schemas.xmlsoap.org/ws/2004/09/transfer/Delete"
+	winrmActionCommand = "http://schemas.microsoft.com/wbem/wsman/1/windows/shell/Command"
+	winrmActionSend    = "http://schemas.microsoft.com/wbem/wsman/1/windows/shell/Send"
+	winrmActionReceive = "http://schemas.microsoft.com/wbem/wsman/1/windows/shell/Receive"
+	winrmActionSignal  = "http://schemas.microsoft.com/wbem/wsman/1/windows/shell/Signal"
+
+	winrmResourceCmd   = "http://schemas.microsoft.com/wbem/wsman/1/windows/shell/cmd"
+	winrmStateDone     = "http://schemas.microsoft.com/wbem/wsman/1/windows/shell/CommandState/Done"
+	winrmSignalKill    = "http://schemas.microsoft.com/wbem/wsman/1/windows/shell/signal/terminate"
+	winrmTimedOutCode  = "2150858793"
+	winrmMaxEnvelope   = 153600
+	winrmStdinChunk    = 32768
+	winrmUTF8CodePage  = "65001"
+	winrmCleanupPeriod = 10 * time.Second
+)
+
+// WinRMConfig is used to set options in the NewWinRMLogRun
+// constructor. The fields not described here have the same meaning as
+// in RemoteConfig.
+type WinRMConfig struct {
+	LogFunc       LogFunc
+	LogHook       LogHook
+	ResultStore   ResultStore
+	ResultLogFunc LogFunc
+	Verbosity     Verbosity
+
+	// ShellExecutable is the shell used to run shell commands. If
+	// empty, PowerShellCmd is used. Use cmd.exe to run shell
+	// commands using cmd.exe.
+	ShellExecutable string
+
+	// Env holds "KEY=VALUE" environment variables set for every
+	// command in addition to the environment of the remote user.
+	Env []string
+
+	// Dir, if not empty, is the working directory of commands.
+	Dir string
+
+	Stdout io.Writer
+	Stderr io.Writer
+
+	// Credentials are the host, port, and account used to connect
+	// to the WinRM service. The password is sent using HTTP basic
+	// authentication, which must be enabled on the host. If Port
+	// is zero, DefaultWinRMPort or DefaultWinRMHTTPSPort is used.
+	// PrivateKeyFilename is not used.
+	Credentials Credentials
+
+	// UseHTTPS connects using HTTPS. It should be used unless the
+	// network is trusted since basic authentication sends the
+	// password in the clear over HTTP.
+	UseHTTPS bool
+
+	// InsecureSkipVerify disables verification of the certificate
+	// of the host when UseHTTPS is true, e.g., for self-signed
+	// certificates.
+	InsecureSkipVerify bool
+
+	// ConnectTimeout is the time allowed for connecting to the
+	// host. If zero, DefaultConnectTimeout is used.
+	ConnectTimeout time.Duration
+
+	Dryrun           bool
+	OutputEncoding   OutputEncoding
+	NormalizeCRLF    bool
+	Timeout          time.Duration
+	Clock            Clock
+	StderrClassifier *StderrClassifier
+	Vars             map[string]string
+	Tags             map[string]string
+}
+
+// NewWinRMLogRun is the constructor for a LogRun that runs commands
+// on a Windows host using Windows Remote Management (WinRM), i.e.,
+// PowerShell remoting's transport, instead of SSH. Commands are run
+// by cmd.exe like winrs, and FileExists(), DirExists(), and Glob() use
+// PowerShell cmdlets like for RemoteWindows hosts. No connection is
+// made until the first command is run.
+//
+// Commands can be canceled and given per-command I/O, environments,
+// and working directories, but detached commands, process tracking,
+// RunAs, and the other file helpers are not supported.
+func NewWinRMLogRun(config WinRMConfig) (*LogRun, error) {
+	w, err := newWinRMRunner(config)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewLogRun(w, LogRunConfig{
+		LogFunc:          config.LogFunc,
+		LogHook:          config.LogHook,
+		ResultStore:      config.ResultStore,
+		ResultLogFunc:    config.ResultLogFunc,
+		Verbosity:        config.Verbosity,
+		Dryrun:           config.Dryrun,
+		OutputEncoding:   config.OutputEncoding,
+		NormalizeCRLF:    config.NormalizeCRLF,
+		Timeout:          config.Timeout,
+		Clock:            config.Clock,
+		StderrClassifier: config.StderrClassifier,
+		Vars:             config.Vars,
+		Tags:             config.Tags,
+	}), nil
+}
+
+// winrmRunner runs commands using WinRM. A new remote shell is
+// created for every command.
+type winrmRunner struct {
+	credentials     Credentials
+	endpoint        string
+	client          *http.Client
+	connectTimeout  time.Duration
+	shellExecutable string
+	env             []string
+	dir             string
+	stdout          io.Writer
+	stderr          io.Writer
+}
+
+func newWinRMRunner(config WinRMConfig) (*winrmRunner, error) {
+	creds := config.Credentials
+	if creds.Hostname == "" {
+		return nil, fmt.Errorf("no hostname specified")
+	}
+	scheme := "http"
+	if config.UseHTTPS {
+		scheme = "https"
+	}
+	if creds.Port == 0 {
+		creds.Port = DefaultWinRMPort
+		if config.UseHTTPS {
+			creds.Port = DefaultWinRMHTTPSPort
+		}
+	}
+	w := &winrmRunner{
+		credentials:     creds,
+		endpoint:        fmt.Sprintf("%s://%s/wsman", scheme, net.JoinHostPort(creds.Hostname, strconv.Itoa(creds.Port))),
+		connectTimeout:  config.ConnectTimeout,
+		shellExecutable: config.ShellExecutable,
+		env:             config.Env,
+		dir:             config.Dir,
+		stdout:          config.Stdout,
+		stderr:          config.Stderr,
+	}
+	if w.connectTimeout == 0 {
+		w.connectTimeout = DefaultConnectTimeout
+	}
+	if w.shellExecutable == "" {
+		w.shellExecutable = PowerShellCmd
+	}
+	dialer := &net.Dialer{Timeout: w.connectTimeout}
+	w.client = &http.Client{
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSClientConfig:     &tls.Config{InsecureSkipVerify: config.InsecureSkipVerify}, // nolint: gosec
+			TLSHandshakeTimeout: w.connectTimeout,
+			MaxIdleConnsPerHost: 2,
+		},
+	}
+
+	return w, nil
+}
+
+func (w *winrmRunner) hostInfo() HostInfo {
+	return HostInfo{
+		Hostname: w.credentials.Hostname,
+		Port:     w.credentials.Port,
+		Username: w.credentials.Username,
+	}
+}
+
+// Run runs a command like glibc's exec() call. It returns the
+// standard out, standard error, and exit code of the command when it
+// completes.
+func (w *winrmRunner) Run(cmd string, args ...string) (string, string, int, error) {
+	return w.execute(&execSpec{ctx: context.Background(), cmd: cmd, args: args})
+}
+
+// FormatRun returns a string representation of the what command would
+// be run using Run(). Useful for logging commands.
+func (w *winrmRunner) FormatRun(cmd string, args ...string) string {
+	return w.format(&execSpec{cmd: cmd, args: args})
+}
+
+// Shell runs a command using the ShellExecutable. It returns the
+// standard out, standard error, and exit code of the command when it
+// completes.
+func (w *winrmRunner) Shell(cmd string) (string, string, int, error) {
+	return w.execute(&execSpec{ctx: context.Background(), cmd: cmd, shell: true})
+}
+
+// FormatShell returns a string representation of the what command
+// would be run using Shell(). Useful for logging commands.
+func (w *winrmRunner) FormatShell(cmd string) string {
+	return w.format(&execSpec{cmd: cmd, shell: true})
+}
+
+// format returns a string representation of the command described by
+// spec. The working directory and environment, which are set by the
+// remote shell, are shown using cmd.exe notation.
+func (w *winrmRunner) format(spec *execSpec) string {
+	var s string
+	for _, kv := range maskEnv(appendEnv(w.env, spec.env)) {
+		s += fmt.Sprintf(`set "%s" && `, kv)
+	}
+	s += windowsCommandLine(w.shellExecutable, spec)
+	if dir := w.workDir(spec); dir != "" {
+		s = fmt.Sprintf("cd /d %s && %s", cmdQuote(dir), s)
+	}
+
+	return fmt.Sprintf("winrm %s@%s %s", w.credentials.Username, w.credentials.Hostname, s)
+}
+
+// workDir returns the working directory of the command described by
+// spec, which is relative to the Dir the runner was configured with.
+func (w *winrmRunner) workDir(spec *execSpec) string {
+	switch {
+	case spec.dir == "":
+		return w.dir
+	case w.dir == "" || isWindowsAbs(spec.dir):
+		return spec.dir
+	}
+
+	return strings.TrimRight(w.dir, `\/`) + `\` + strings.Replace(spec.dir, "/", `\`, -1)
+}
+
+func (w *winrmRunner) execute(spec *execSpec) (string, string, int, error) {
+	if spec.onStart != nil || spec.pidFile != "" {
+		return "", "", 0, fmt.Errorf("process tracking is not supported over WinRM")
+	}
+	if spec.runAs != "" {
+		return "", "", 0, fmt.Errorf("running commands as another user is not supported over WinRM")
+	}
+	ctx := spec.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	// Hook up standard files.
+	var stdoutBuf, stderrBuf bytes.Buffer
+	stdout, stderr := w.stdout, w.stderr
+	if spec.capture {
+		stdout, stderr = nil, nil
+	}
+	if spec.stdout != nil {
+		stdout = spec.stdout
+	}
+	if stdout == nil {
+		stdout = &stdoutBuf
+	}
+	if spec.stderr != nil {
+		stderr = spec.stderr
+	}
+	if stderr == nil {
+		stderr = &stderrBuf
+	}
+
+	shellID, err := w.createShell(ctx, w.workDir(spec), appendEnv(w.env, spec.env))
+	if err != nil {
+		return "", "", 0, w.connError(err)
+	}
+	defer w.cleanup(func(ctx context.Context) error {
+		_, err := w.request(ctx, winrmActionDelete, shellID, nil, "")
+		return err
+	})
+	body := fmt.Sprintf("<rsp:CommandLine><rsp:Command>%s</rsp:Command></rsp:CommandLine>",
+		xmlEscape(windowsCommandLine(w.shellExecutable, spec)))
+	resp, err := w.request(ctx, winrmActionCommand, shellID, map[string]string{
+		"WINRS_CONSOLEMODE_STDIN": "TRUE",
+		"WINRS_SKIP_CMD_SHELL":    "FALSE",
+	}, body)
+	if err != nil {
+		return "", "", 0, w.connError(err)
+	}
+	commandID := resp.Body.CommandResponse.CommandID
+	if spec.stdin != nil {
+		if err := w.sendStdin(ctx, shellID, commandID, spec.stdin); err != nil {
+			return "", "", 0, w.commandError(ctx, shellID, commandID, err)
+		}
+	}
+	code, err := w.receive(ctx, shellID, commandID, stdout, stderr)
+	if err != nil {
+		return "", "", 0, w.commandError(ctx, shellID, commandID, err)
+	}
+
+	return stdoutBuf.String(), stderrBuf.String(), code, nil
+}
+
+// createShell creates a remote shell and returns its ID.
+func (w *winrmRunner) createShell(ctx context.Context, dir string, env []string) (string, error) {
+	var body strings.Builder
+	body.WriteString("<rsp:Shell><rsp:InputStreams>stdin</rsp:InputStreams><rsp:OutputStreams>stdout stderr</rsp:OutputStreams>")
+	if dir != "" {
+		fmt.Fprintf(&body, "<rsp:WorkingDirectory>%s</rsp:WorkingDirectory>", xmlEscape(dir))
+	}
+	if len(env) > 0 {
+		body.WriteString("<rsp:Environment>")
+		for _, kv := range env {
+			parts := strings.SplitN(kv, "=", 2)
+			if len(parts) != 2 {
+				continue
+			}
+			fmt.Fprintf(&body, `<rsp:Variable Name="%s">%s</rsp:Variable>`, xmlEscape(parts[0]), xmlEscape(parts[1]))
+		}
+		body.WriteString("</rsp:Environment>")
+	}
+	body.WriteString("</rsp:Shell>")
+	resp, err := w.request(ctx, winrmActionCreate, "", map[string]string{
+		"WINRS_NOPROFILE": "FALSE",
+		"WINRS_CODEPAGE":  winrmUTF8CodePage,
+	}, body.String())
+	if err != nil {
+		return "", err
+	}
+	shellID := resp.Body.Shell.ShellID
+	if shellID == "" {
+		for _, s := range resp.Body.ResourceCreated.Selectors {
+			if s.Name == "ShellId" {
+				shellID = s.Value
+			}
+		}
+	}
+	if shellID == "" {
+		return "", fmt.Errorf("winrm: no shell ID in response")
+	}
+
+	return shellID, nil
+}
+
+// sendStdin sends the contents of stdin to the command.
+func (w *winrmRunner) sendStdin(ctx context.Context, shellID string, commandID string, stdin io.Reader) error {
+	buf := make([]byte, winrmStdinChunk)
+	for {
+		n, err := io.ReadFull(stdin, buf)
+		end := err == io.EOF || err == io.ErrUnexpectedEOF
+		if err != nil && !end {
+			return err
+		}
+		endAttr := ""
+		if end {
+			endAttr = ` End="true"`
+		}
+		body := fmt.Sprintf(`<rsp:Send><rsp:Stream Name="stdin" CommandId="%s"%s>%s</rsp:Stream></rsp:Send>`,
+			xmlEscape(commandID), endAttr, base64.StdEncoding.EncodeToString(buf[:n]))
+		if _, err := w.request(ctx, winrmActionSend, shellID, nil, body); err != nil {
+			return err
+		}
+		if end {
+			return nil
+		}
+	}
+}
+
+// receive copies the output of the command to stdout and stderr until
+// it is done and returns its exit code.
+func (w *winrmRunner) receive(ctx context.Context, shellID string, commandID string, stdout io.Writer, stderr io.Writer) (int, error) {
+	body := fmt.Sprintf(`<rsp:Receive><rsp:DesiredStream CommandId="%s">stdout stderr</rsp:DesiredStream></rsp:Receive>`,
+		xmlEscape(commandID))
+	for {
+		resp, err := w.request(ctx, winrmActionReceive, shellID, nil, body)
+		if fault, ok := err.(*winrmFault); ok && fault.timedOut() {
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+		received := resp.Body.ReceiveResponse
+		for _, s := range received.Streams {
+			data, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s.Data))
+			if err != nil {
+				return 0, fmt.Errorf("winrm: invalid %s data: %w", s.Name, err)
+			}
+			out := stdout
+			if s.Name == "stderr" {
+				out = stderr
+			}
+			if _, err := out.Write(data); err != nil {
+				return 0, err
+			}
+		}
+		if received.CommandState.State == winrmStateDone {
+			return received.CommandState.ExitCode, nil
+		}
+	}
+}
+
+// commandError terminates a running command after err, e.g., because
+// ctx was canceled, and returns the error to report.
+func (w *winrmRunner) commandError(ctx context.Context, shellID string, commandID string, err error) error {
+	w.cleanup(func(ctx context.Context) error {
+		body := fmt.Sprintf(`<rsp:Signal CommandId="%s"><rsp:Code>%s</rsp:Code></rsp:Signal>`,
+			xmlEscape(commandID), winrmSignalKill)
+		_, err := w.request(ctx, winrmActionSignal, shellID, nil, body)
+		return err
+	})
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	return w.connError(err)
+}
+
+// cleanup runs the cleanup request f, which must not be canceled
+// with the command, with a bounded time. Errors are ignored since the
+// server cleans up idle shells itself.
+func (w *winrmRunner) cleanup(f func(ctx context.Context) error) {
+	ctx, cancel := context.WithTimeout(context.Background(), winrmCleanupPeriod)
+	defer cancel()
+	f(ctx) // nolint: errcheck
+}
+
+// connError describes an error talking to the WinRM service.
+func (w *winrmRunner) connError(err error) error {
+	return fmt.Errorf("winrm %s@%s: %w", w.credentials.Username, w.credentials.Hostname, err)
+}
+
+// request sends a WS-Management request with action and body to the
+// shell with shellID, if any, and parses the response.
+func (w *winrmRunner) request(ctx context.Context, action string, shellID string, options map[string]string, body string) (*winrmResponse, error) {
+	envelope := w.envelope(action, shellID, options, body)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.endpoint, bytes.NewReader(envelope))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/soap+xml;charset=UTF-8")
+	req.SetBasicAuth(w.credentials.Username, w.credentials.Password)
+	httpResp, err := w.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer httpResp.Body.Close() // nolint: errcheck
+	data, err := ioutil.ReadAll(httpResp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if httpResp.StatusCode == http.StatusUnauthorized {
+		return nil, fmt.Errorf("authentication failed (basic authentication must be enabled)")
+	}
+	var resp winrmResponse
+	if err := xml.Unmarshal(data, &resp); err != nil {
+		if httpResp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("HTTP status %s", httpResp.Status)
+		}
+		return nil, fmt.Errorf("invalid response: %w", err)
+	}
+	if resp.Body.Fault != nil {
+		return nil, resp.Body.Fault
+	}
+	if httpResp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP status %s", httpResp.Status)
+	}
+
+	return &resp, nil
+}
+
+// envelope returns the SOAP envelope of a request.
+func (w *winrmRunner) envelope(action string, shellID string, options map[string]string, body string) []byte {
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8"?>`)
+	b.WriteString(`<env:Envelope xmlns:env="http://www.w3.org/2003/05/soap-envelope"` +
+		` xmlns:a="http://schemas.xmlsoap.org/ws/2004/08/addressing"` +
+		` xmlns:w="http://schemas.dmtf.org/wbem/wsman/1/wsman.xsd"` +
+		` xmlns:rsp="http://schemas.microsoft.com/wbem/wsman/1/windows/shell">`)
+	b.WriteString("<env:Header>")
+	fmt.Fprintf(&b, "<a:To>%s</a:To>", xmlEscape(w.endpoint))
+	b.WriteString(`<a:ReplyTo><a:Address env:mustUnderstand="true">` +
+		`http://schemas.xmlsoap.org/ws/2004/08/addressing/role/anonymous</a:Address></a:ReplyTo>`)
+	fmt.Fprintf(&b, `<a:Action env:mustUnderstand="true">%s</a:Action>`, action)
+	fmt.Fprintf(&b, "<a:MessageID>uuid:%s</a:MessageID>", newUUID())
+	fmt.Fprintf(&b, `<w:ResourceURI env:mustUnderstand="true">%s</w:ResourceURI>`, winrmResourceCmd)
+	fmt.Fprintf(&b, `<w:MaxEnvelopeSize env:mustUnderstand="true">%d</w:MaxEnvelopeSize>`, winrmMaxEnvelope)
+	fmt.Fprintf(&b, "<w:OperationTimeout>PT%dS</w:OperationTimeout>", int(WinRMOperationTimeout/time.Second))
+	if shellID != "" {
+		fmt.Fprintf(&b, `<w:SelectorSet><w:Selector Name="ShellId">%s</w:Selector></w:SelectorSet>`, xmlEscape(shellID))
+	}
+	if len(options) > 0 {
+		var names []string
+		for name := range options {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		b.WriteString("<w:OptionSet>")
+		for _, name := range names {
+			fmt.Fprintf(&b, `<w:Option Name="%s">%s</w:Option>`, name, xmlEscape(options[name]))
+		}
+		b.WriteString("</w:OptionSet>")
+	}
+	b.WriteString("</env:Header><env:Body>")
+	b.WriteString(body)
+	b.WriteString("</env:Body></env:Envelope>")
+
+	return []byte(b.String())
+}
+
+// winrmResponse holds the parts of WS-Management responses used by
+// the WinRM runner.
+type winrmResponse struct {
+	Body struct {
+		Fault *winrmFault `xml:"Fault"`
+
+		Shell struct {
+			ShellID string `xml:"ShellId"`
+		} `xml:"Shell"`
+
+		ResourceCreated struct {
+			Selectors []struct {
+				Name  string `xml:"Name,attr"`
+				Value string `xml:",chardata"`
+			} `xml:"ReferenceParameters>SelectorSet>Selector"`
+		} `xml:"ResourceCreated"`
+
+		CommandResponse struct {
+			CommandID string `xml:"CommandId"`
+		} `xml:"CommandResponse"`
+
+		ReceiveResponse struct {
+			Streams []struct {
+				Name string `xml:"Name,attr"`
+				Data string `xml:",chardata"`
+			} `xml:"Stream"`
+			CommandState struct {
+				State    string `xml:"State,attr"`
+				ExitCode int    `xml:"ExitCode"`
+			} `xml:"CommandState"`
+		} `xml:"ReceiveResponse"`
+	} `xml:"Body"`
+}
+
+// winrmFault is a SOAP fault returned by a WinRM server.
+type winrmFault struct {
+	Subcode string `xml:"Code>Subcode>Value"`
+	Reason  string `xml:"Reason>Text"`
+	Detail  struct {
+		Code    string `xml:"Code,attr"`
+		Message string `xml:",chardata"`
+	} `xml:"Detail>WSManFault"`
+}
+
+func (f *winrmFault) Error() string {
+	msg := strings.TrimSpace(f.Reason)
+	if msg == "" {
+		msg = strings.TrimSpace(f.Detail.Message)
+	}
+	if msg == "" {
+		msg = f.Subcode
+	}
+
+	return "winrm fault: " + msg
+}
+
+// timedOut returns true if the fault reports that no output was
+// available within the operation timeout.
+func (f *winrmFault) timedOut() bool {
+	return strings.HasSuffix(f.Subcode, ":TimedOut") || f.Detail.Code == winrmTimedOutCode
+}
+
+// xmlEscape escapes s for use in XML text and attribute values.
+func xmlEscape(s string) string {
+	var b bytes.Buffer
+	xml.EscapeText(&b, []byte(s)) // nolint: errcheck
+
+	return b.String()
+}
+
+// newUUID returns a random (version 4) UUID.
+func newUUID() string {
+	var u [16]byte
+	_, _ = rand.Read(u[:])
+	u[6] = u[6]&0x0f | 0x40
+	u[8] = u[8]&0x3f | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", u[0:4], u[4:6], u[6:8], u[8:10], u[10:])
+}
@@ -0,0 +1,378 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/apatters/go-logrun"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testWinRMServer is a WinRM server that runs the commands it receives
+// locally using /bin/sh. The "cmd /c" prefix of shell commands is
+// removed.
+type testWinRMServer struct {
+	*httptest.Server
+	t        *testing.T
+	mu       sync.Mutex
+	shells   map[string]*testWinRMShell
+	commands map[string]*testWinRMCommand
+	next     int
+	timeouts int
+	signals  int
+}
+
+type testWinRMShell struct {
+	dir string
+	env []string
+}
+
+type testWinRMCommand struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout bytes.Buffer
+	stderr bytes.Buffer
+	done   chan struct{}
+}
+
+type testWinRMRequest struct {
+	Header struct {
+		Action    string `xml:"Action"`
+		Selectors []struct {
+			Value string `xml:",chardata"`
+		} `xml:"SelectorSet>Selector"`
+	} `xml:"Header"`
+	Body struct {
+		Dir  string `xml:"Shell>WorkingDirectory"`
+		Vars []struct {
+			Name  string `xml:"Name,attr"`
+			Value string `xml:",chardata"`
+		} `xml:"Shell>Environment>Variable"`
+		Command string `xml:"CommandLine>Command"`
+		Stdin   struct {
+			CommandID string `xml:"CommandId,attr"`
+			End       bool   `xml:"End,attr"`
+			Data      string `xml:",chardata"`
+		} `xml:"Send>Stream"`
+		Receive struct {
+			CommandID string `xml:"CommandId,attr"`
+		} `xml:"Receive>DesiredStream"`
+		Signal struct {
+			CommandID string `xml:"CommandId,attr"`
+		} `xml:"Signal"`
+	} `xml:"Body"`
+}
+
+func newTestWinRMServer(t *testing.T) *testWinRMServer {
+	s := &testWinRMServer{
+		t:        t,
+		shells:   make(map[string]*testWinRMShell),
+		commands: make(map[string]*testWinRMCommand),
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+// Credentials returns the credentials accepted by the server.
+func (s *testWinRMServer) Credentials() logrun.Credentials {
+	host, port, _ := net.SplitHostPort(s.Listener.Addr().String())
+	p, _ := strconv.Atoi(port)
+
+	return logrun.Credentials{Hostname: host, Port: p, Username: "admin", Password: "secret"}
+}
+
+func (s *testWinRMServer) serve(w http.ResponseWriter, req *http.Request) {
+	if user, pass, ok := req.BasicAuth(); !ok || user != "admin" || pass != "secret" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	var r testWinRMRequest
+	if err := xml.NewDecoder(req.Body).Decode(&r); err != nil {
+		s.fault(w, "w:InvalidMessage", err.Error())
+		return
+	}
+	var id string
+	if len(r.Header.Selectors) > 0 {
+		id = r.Header.Selectors[0].Value
+	}
+	action := r.Header.Action[strings.LastIndex(r.Header.Action, "/")+1:]
+	s.mu.Lock()
+	shell := s.shells[id]
+	s.mu.Unlock()
+	if shell == nil && action != "Create" {
+		s.fault(w, "w:InvalidSelectors", "no such shell "+id)
+		return
+	}
+	switch action {
+	case "Create":
+		shell := &testWinRMShell{dir: r.Body.Dir, env: os.Environ()}
+		for _, v := range r.Body.Vars {
+			shell.env = append(shell.env, v.Name+"="+v.Value)
+		}
+		s.mu.Lock()
+		s.next++
+		id = fmt.Sprintf("SHELL-%d", s.next)
+		s.shells[id] = shell
+		s.mu.Unlock()
+		s.reply(w, `<x:ResourceCreated xmlns:x="http://schemas.xmlsoap.org/ws/2004/09/transfer">`+
+			`<a:Address>`+s.URL+`</a:Address><a:ReferenceParameters>`+
+			`<w:ResourceURI>http://schemas.microsoft.com/wbem/wsman/1/windows/shell/cmd</w:ResourceURI>`+
+			`<w:SelectorSet><w:Selector Name="ShellId">`+id+`</w:Selector></w:SelectorSet>`+
+			`</a:ReferenceParameters></x:ResourceCreated>`)
+	case "Delete":
+		s.mu.Lock()
+		delete(s.shells, id)
+		s.mu.Unlock()
+		s.reply(w, "")
+	case "Command":
+		cmdLine := r.Body.Command
+		c := &testWinRMCommand{done: make(chan struct{})}
+		for _, prefix := range []string{"cmd /c ", "cmd.exe /c "} {
+			cmdLine = strings.TrimPrefix(cmdLine, prefix)
+		}
+		c.cmd = exec.Command("/bin/sh", "-c", cmdLine)
+		c.cmd.Dir = shell.dir
+		c.cmd.Env = shell.env
+		c.cmd.Stdout = &c.stdout
+		c.cmd.Stderr = &c.stderr
+		c.stdin, _ = c.cmd.StdinPipe()
+		if err := c.cmd.Start(); err != nil {
+			s.fault(w, "w:InternalError", err.Error())
+			return
+		}
+		go func() {
+			c.cmd.Wait() // nolint: errcheck
+			close(c.done)
+		}()
+		s.mu.Lock()
+		s.next++
+		cid := fmt.Sprintf("CMD-%d", s.next)
+		s.commands[cid] = c
+		s.mu.Unlock()
+		s.reply(w, `<rsp:CommandResponse><rsp:CommandId>`+cid+`</rsp:CommandId></rsp:CommandResponse>`)
+	case "Send":
+		c := s.command(r.Body.Stdin.CommandID)
+		data, err := base64.StdEncoding.DecodeString(r.Body.Stdin.Data)
+		if err == nil {
+			_, err = c.stdin.Write(data)
+		}
+		if r.Body.Stdin.End {
+			c.stdin.Close() // nolint: errcheck
+		}
+		if err != nil {
+			s.fault(w, "w:InternalError", err.Error())
+			return
+		}
+		s.reply(w, "<rsp:SendResponse/>")
+	case "Receive":
+		c := s.command(r.Body.Receive.CommandID)
+		c.stdin.Close() // nolint: errcheck
+		s.mu.Lock()
+		s.timeouts++
+		first := s.timeouts == 1
+		s.mu.Unlock()
+		if first {
+			// Exercise the retry of timed out receives.
+			s.fault(w, "w:TimedOut", "The WS-Management service cannot complete the operation within the time specified in OperationTimeout.")
+			return
+		}
+		select {
+		case <-c.done:
+		case <-req.Context().Done():
+			return
+		}
+		code := c.cmd.ProcessState.ExitCode()
+		s.reply(w, fmt.Sprintf(`<rsp:ReceiveResponse>`+
+			`<rsp:Stream Name="stdout" CommandId="%[1]s">%[2]s</rsp:Stream>`+
+			`<rsp:Stream Name="stderr" CommandId="%[1]s">%[3]s</rsp:Stream>`+
+			`<rsp:CommandState CommandId="%[1]s" State="http://schemas.microsoft.com/wbem/wsman/1/windows/shell/CommandState/Done">`+
+			`<rsp:ExitCode>%[4]d</rsp:ExitCode></rsp:CommandState></rsp:ReceiveResponse>`,
+			r.Body.Receive.CommandID,
+			base64.StdEncoding.EncodeToString(c.stdout.Bytes()),
+			base64.StdEncoding.EncodeToString(c.stderr.Bytes()),
+			code))
+	case "Signal":
+		c := s.command(r.Body.Signal.CommandID)
+		c.cmd.Process.Kill() // nolint: errcheck
+		s.mu.Lock()
+		s.signals++
+		s.mu.Unlock()
+		s.reply(w, "<rsp:SignalResponse/>")
+	default:
+		s.fault(w, "w:ActionNotSupported", action)
+	}
+}
+
+func (s *testWinRMServer) command(id string) *testWinRMCommand {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.commands[id]
+}
+
+// openShells returns the number of shells that have not been deleted.
+func (s *testWinRMServer) openShells() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.shells)
+}
+
+func (s *testWinRMServer) reply(w http.ResponseWriter, body string) {
+	w.Header().Set("Content-Type", "application/soap+xml;charset=UTF-8")
+	fmt.Fprintf(w, `<s:Envelope xmlns:s="http://www.w3.org/2003/05/soap-envelope"`+
+		` xmlns:a="http://schemas.xmlsoap.org/ws/2004/08/addressing"`+
+		` xmlns:w="http://schemas.dmtf.org/wbem/wsman/1/wsman.xsd"`+
+		` xmlns:rsp="http://schemas.microsoft.com/wbem/wsman/1/windows/shell">`+
+		`<s:Header/><s:Body>%s</s:Body></s:Envelope>`, body)
+}
+
+func (s *testWinRMServer) fault(w http.ResponseWriter, subcode string, reason string) {
+	w.Header().Set("Content-Type", "application/soap+xml;charset=UTF-8")
+	w.WriteHeader(http.StatusInternalServerError)
+	fmt.Fprintf(w, `<s:Envelope xmlns:s="http://www.w3.org/2003/05/soap-envelope"`+
+		` xmlns:w="http://schemas.dmtf.org/wbem/wsman/1/wsman.xsd"><s:Body><s:Fault>`+
+		`<s:Code><s:Value>s:Receiver</s:Value><s:Subcode><s:Value>%s</s:Value></s:Subcode></s:Code>`+
+		`<s:Reason><s:Text xml:lang="en-US">%s</s:Text></s:Reason>`+
+		`</s:Fault></s:Body></s:Envelope>`, subcode, reason)
+}
+
+func TestWinRMLogRun_Run(t *testing.T) {
+	s := newTestWinRMServer(t)
+	log, out, errOut := newLogger()
+	r, err := logrun.NewWinRMLogRun(logrun.WinRMConfig{
+		LogFunc:         log.Println,
+		Credentials:     s.Credentials(),
+		ShellExecutable: "cmd",
+	})
+	require.NoError(t, err)
+
+	stdout, stderr, code := r.Run("echo", "hello world")
+	t.Logf("stdout = %q", stdout)
+	t.Logf("stderr = %q", stderr)
+	t.Logf("code = %d", code)
+	t.Logf("out = %q", out)
+	assert.Equal(t, "hello world\n", stdout)
+	assert.Empty(t, stderr)
+	assert.Zero(t, code)
+	assert.Equal(t, "winrm admin@127.0.0.1 echo \"hello world\"\n", out.String())
+	assert.Empty(t, errOut.String())
+	out.Reset()
+
+	stdout, stderr, code = r.Shell("echo out; echo err >&2; exit 3")
+	t.Logf("stdout = %q", stdout)
+	t.Logf("stderr = %q", stderr)
+	t.Logf("code = %d", code)
+	t.Logf("out = %q", out)
+	assert.Equal(t, "out\n", stdout)
+	assert.Equal(t, "err\n", stderr)
+	assert.Equal(t, 3, code)
+	assert.Equal(t, "winrm admin@127.0.0.1 cmd /c echo out; echo err >&2; exit 3\n", out.String())
+
+	stdout, _, code = r.With(logrun.WithStdin(strings.NewReader("from stdin\n"))).Run("cat")
+	t.Logf("stdout = %q", stdout)
+	assert.Zero(t, code)
+	assert.Equal(t, "from stdin\n", stdout)
+	assert.Zero(t, s.openShells())
+
+	c := r.Config()
+	t.Logf("config = %+v", c)
+	assert.Equal(t, logrun.RemoteWindows, c.RemoteOS)
+	assert.Equal(t, logrun.MaskedSecret, c.Credentials.Password)
+	assert.Equal(t, "admin@127.0.0.1:"+strconv.Itoa(s.Credentials().Port), c.Host)
+}
+
+func TestWinRMLogRun_EnvAndDir(t *testing.T) {
+	s := newTestWinRMServer(t)
+	dir := t.TempDir()
+	log, out, _ := newLogger()
+	r, err := logrun.NewWinRMLogRun(logrun.WinRMConfig{
+		LogFunc:         log.Println,
+		Credentials:     s.Credentials(),
+		ShellExecutable: "cmd.exe",
+		Env:             []string{"GREETING=hello", "API_TOKEN=s3cret"},
+		Dir:             dir,
+	})
+	require.NoError(t, err)
+
+	stdout, _, code := r.With(logrun.WithEnv("NAME=world")).Shell("echo $GREETING $NAME $API_TOKEN; pwd")
+	t.Logf("stdout = %q", stdout)
+	t.Logf("out = %q", out)
+	require.Zero(t, code)
+	assert.Equal(t, "hello world s3cret\n"+dir+"\n", stdout)
+	assert.Equal(t,
+		"winrm admin@127.0.0.1 cd /d "+dir+` && set "GREETING=hello" && set "API_TOKEN=********" && set "NAME=world" && cmd.exe /c echo $GREETING $NAME $API_TOKEN; pwd`+"\n",
+		out.String())
+}
+
+func TestWinRMLogRun_Timeout(t *testing.T) {
+	s := newTestWinRMServer(t)
+	r, err := logrun.NewWinRMLogRun(logrun.WinRMConfig{
+		Credentials:     s.Credentials(),
+		ShellExecutable: "cmd",
+		Timeout:         200 * time.Millisecond,
+	})
+	require.NoError(t, err)
+
+	start := time.Now()
+	_, err = r.ShellResult("sleep 5")
+	t.Logf("err = %v", err)
+	t.Logf("elapsed = %s", time.Since(start))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "timed out")
+	assert.True(t, time.Since(start) < 4*time.Second)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	assert.Equal(t, 1, s.signals)
+}
+
+func TestWinRMLogRun_Errors(t *testing.T) {
+	_, err := logrun.NewWinRMLogRun(logrun.WinRMConfig{})
+	t.Logf("err = %v", err)
+	assert.Error(t, err)
+
+	s := newTestWinRMServer(t)
+	creds := s.Credentials()
+	creds.Password = "wrong"
+	r, err := logrun.NewWinRMLogRun(logrun.WinRMConfig{Credentials: creds})
+	require.NoError(t, err)
+	_, err = r.RunResult("hostname")
+	t.Logf("err = %v", err)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "authentication failed")
+
+	r, err = logrun.NewWinRMLogRun(logrun.WinRMConfig{Credentials: s.Credentials()})
+	require.NoError(t, err)
+	_, err = r.With(logrun.WithPIDFunc(func(int) {})).RunResult("hostname")
+	t.Logf("err = %v", err)
+	assert.Error(t, err)
+
+	// The server is unreachable.
+	creds = s.Credentials()
+	s.Close()
+	r, err = logrun.NewWinRMLogRun(logrun.WinRMConfig{Credentials: creds})
+	require.NoError(t, err)
+	_, err = r.RunResult("hostname")
+	t.Logf("err = %v", err)
+	assert.Error(t, err)
+}
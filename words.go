@@ -0,0 +1,107 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SplitCommandLine splits line into words using the quoting rules of
+// a POSIX shell without executing a shell. Words are separated by
+// unquoted whitespace. Single quotes preserve everything between
+// them, double quotes preserve everything except backslash escapes
+// of ", \, $, and `, and a backslash outside of quotes escapes the
+// next character. No expansion of variables, globs, or command
+// substitutions is performed. An error is returned for unterminated
+// quotes and for unquoted shell operators (|, &, ;, <, >, (, ), and
+// `) since they would not have their shell meaning.
+func SplitCommandLine(line string) ([]string, error) {
+	var words []string
+	var word strings.Builder
+	inWord := false
+	runes := []rune(line)
+	for i := 0; i < len(runes); i++ {
+		c := runes[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		case c == '\\':
+			inWord = true
+			i++
+			if i == len(runes) {
+				return nil, fmt.Errorf("trailing backslash in %q", line)
+			}
+			if runes[i] != '\n' {
+				word.WriteRune(runes[i])
+			}
+		case c == '\'':
+			inWord = true
+			end := indexRune(runes, i+1, '\'')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated single quote in %q", line)
+			}
+			word.WriteString(string(runes[i+1 : end]))
+			i = end
+		case c == '"':
+			inWord = true
+			i++
+			for ; i < len(runes) && runes[i] != '"'; i++ {
+				if runes[i] == '\\' && i+1 < len(runes) && strings.ContainsRune("\"\\$`\n", runes[i+1]) {
+					i++
+					if runes[i] == '\n' {
+						continue
+					}
+				}
+				word.WriteRune(runes[i])
+			}
+			if i == len(runes) {
+				return nil, fmt.Errorf("unterminated double quote in %q", line)
+			}
+		case strings.ContainsRune("|&;<>()`", c):
+			return nil, fmt.Errorf("unquoted shell operator %q in %q", c, line)
+		default:
+			inWord = true
+			word.WriteRune(c)
+		}
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+
+	return words, nil
+}
+
+func indexRune(runes []rune, start int, r rune) int {
+	for i := start; i < len(runes); i++ {
+		if runes[i] == r {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// RunLine splits line into a command and its arguments using
+// SplitCommandLine() and then runs it using Run(). No shell is
+// involved, so it is safe to use with command lines from
+// configuration files. If line cannot be split, nothing is logged or
+// run, and the error is returned as the standard error along with
+// ExitErrorExecute.
+func (r *LogRun) RunLine(line string) (string, string, int) {
+	words, err := SplitCommandLine(line)
+	if err != nil {
+		return "", err.Error(), ExitErrorExecute
+	}
+	if len(words) == 0 {
+		return "", fmt.Sprintf("empty command line %q", line), ExitErrorExecute
+	}
+
+	return r.Run(words[0], words[1:]...)
+}
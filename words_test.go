@@ -0,0 +1,79 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package logrun_test
+
+import (
+	"testing"
+
+	"github.com/apatters/go-logrun"
+	"github.com/stretchr/testify/assert"
+)
+
+type splitTestEntry struct {
+	Description   string
+	Line          string
+	ExpectError   bool
+	ExpectedWords []string
+}
+
+var splitTestTable = []splitTestEntry{
+	{"Empty", "  ", false, nil},
+	{"Simple", "ls -l  /tmp", false, []string{"ls", "-l", "/tmp"}},
+	{"Single quotes", `echo 'a b' 'it"s'`, false, []string{"echo", "a b", `it"s`}},
+	{"Double quotes", `echo "a \"b\" \$HOME \n"`, false, []string{"echo", `a "b" $HOME \n`}},
+	{"Backslash", `echo a\ b \'c`, false, []string{"echo", "a b", "'c"}},
+	{"Adjacent quotes", `echo a'b'"c"`, false, []string{"echo", "abc"}},
+	{"Empty quotes", `echo '' ""`, false, []string{"echo", "", ""}},
+	{"Quoted operators", `grep 'a|b' "x;y"`, false, []string{"grep", "a|b", "x;y"}},
+	{"Unterminated single", `echo 'a`, true, nil},
+	{"Unterminated double", `echo "a`, true, nil},
+	{"Trailing backslash", `echo a\`, true, nil},
+	{"Pipe", `ls | grep x`, true, nil},
+	{"Redirect", `ls > /tmp/x`, true, nil},
+}
+
+func TestSplitCommandLine(t *testing.T) {
+	for _, e := range splitTestTable {
+		t.Log(e.Description)
+		t.Logf("Line = %q", e.Line)
+		words, err := logrun.SplitCommandLine(e.Line)
+		t.Logf("words = %q", words)
+		t.Logf("err = %v", err)
+		if e.ExpectError {
+			assert.Error(t, err)
+		} else {
+			assert.NoError(t, err)
+		}
+		assert.Equal(t, e.ExpectedWords, words)
+	}
+}
+
+func TestLocalLogRun_RunLine(t *testing.T) {
+	log, out, errOut := newLogger()
+	l := logrun.NewLocalLogRun(logrun.LocalConfig{
+		LogFunc: log.Println,
+	})
+	stdout, stderr, code := l.RunLine(`/usr/bin/printf '%s|%s\n' "a b" c`)
+	t.Logf("stdout = %q", stdout)
+	t.Logf("stderr = %q", stderr)
+	t.Logf("code = %d", code)
+	t.Logf("out = %q", out)
+	assert.Equal(t, "a b|c\n", stdout)
+	assert.Empty(t, stderr)
+	assert.Zero(t, code)
+	assert.EqualValues(t, "/usr/bin/printf '%s|%s\\n' 'a b' c\n", out.String())
+	assert.Empty(t, errOut.String())
+	out.Reset()
+
+	stdout, stderr, code = l.RunLine(`echo 'a`)
+	t.Logf("stderr = %q", stderr)
+	assert.Empty(t, stdout)
+	assert.NotEmpty(t, stderr)
+	assert.Equal(t, logrun.ExitErrorExecute, code)
+	assert.Empty(t, out.String())
+
+	_, _, code = l.RunLine("")
+	assert.Equal(t, logrun.ExitErrorExecute, code)
+}